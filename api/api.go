@@ -5,6 +5,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"sync/atomic"
 
 	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
 	v1 "github.com/gatewayd-io/gatewayd/api/v1"
@@ -27,6 +28,11 @@ type Options struct {
 	GRPCAddress string
 	HTTPAddress string
 	Servers     map[string]*network.Server
+	// WarmingUp is the number of pools that are still warming up, if any.
+	WarmingUp *atomic.Int32
+	// PluginRegistry is used for listing the hooks of the plugins and the scripts,
+	// and the availability of the plugins.
+	PluginRegistry *plugin.Registry
 }
 
 type API struct {
@@ -243,10 +249,17 @@ func (a *API) GetProxies(context.Context, *emptypb.Empty) (*structpb.Struct, err
 			busy = append(busy, conn)
 		}
 
+		backends := make(map[string]interface{})
+		for backend, status := range proxy.BackendStatus() {
+			backends[backend] = status
+		}
+
 		proxies[name] = map[string]interface{}{
 			"available": available,
 			"busy":      busy,
 			"total":     len(available) + len(busy),
+			"backends":  backends,
+			"readOnly":  proxy.IsReadOnly(),
 		}
 	}
 
@@ -275,6 +288,9 @@ func (a *API) GetServers(context.Context, *emptypb.Empty) (*structpb.Struct, err
 			"address":      server.Address,
 			"status":       uint(server.Status),
 			"tickInterval": server.TickInterval.Nanoseconds(),
+			"uptime":       server.Uptime().Nanoseconds(),
+			"connections":  server.CountConnections(),
+			"paused":       server.IsPaused(),
 		}
 	}
 
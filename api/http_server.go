@@ -10,6 +10,7 @@ import (
 
 	v1 "github.com/gatewayd-io/gatewayd/api/v1"
 	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/plugin"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
@@ -22,6 +23,40 @@ type Healthz struct {
 	Status string `json:"status"`
 }
 
+// Paused is the response of the pause and resume endpoints.
+type Paused struct {
+	Servers map[string]bool `json:"servers"`
+}
+
+// ReadOnly is the response of the read-only mode endpoints.
+type ReadOnly struct {
+	Servers map[string]bool `json:"servers"`
+}
+
+// Draining is the response of the drain endpoints, with the number of the busy
+// server connections of each drained backend that are still to be recycled.
+type Draining struct {
+	Backends map[string]int `json:"backends"`
+}
+
+// Captures is the response of the capture endpoints, with the capture files of the
+// captured connections by their remote address.
+type Captures struct {
+	Connections map[string]string `json:"connections"`
+}
+
+// Hooks is the response of the hooks endpoint, with the hooks of each hook name
+// in the order they run, by the name of the hook in the config.
+type Hooks struct {
+	Hooks map[string][]plugin.HookInfo `json:"hooks"`
+}
+
+// Plugins is the response of the plugins endpoint, with the availability of each
+// plugin by its name.
+type Plugins struct {
+	Plugins map[string]plugin.Availability `json:"plugins"`
+}
+
 type HTTPServer struct {
 	httpServer *http.Server
 	options    *Options
@@ -79,6 +114,38 @@ func createHTTPAPI(options *Options) *http.Server {
 		}
 	})
 
+	// Report whether the clients can be served, e.g. for the readiness probes.
+	mux.HandleFunc("/readyz", readyzHandler(options))
+
+	// Pause or resume accepting new connections on all the servers,
+	// or on the one in the "server" query parameter.
+	mux.HandleFunc("/pause", pauseHandler(options, true))
+	mux.HandleFunc("/resume", pauseHandler(options, false))
+
+	// Reject or accept again the writes to the database on all the servers,
+	// or on the one in the "server" query parameter, e.g. for maintenance.
+	mux.HandleFunc("/readonly", readOnlyHandler(options, true))
+	mux.HandleFunc("/readwrite", readOnlyHandler(options, false))
+
+	// Drain the backend in the "backend" query parameter, e.g. tcp://localhost:5432,
+	// for maintenance, or return it to service. A GET reports the drain progress.
+	mux.HandleFunc("/drain", drainHandler(options, true))
+	mux.HandleFunc("/undrain", drainHandler(options, false))
+
+	// Capture the traffic of the connections of the client in the "client" query
+	// parameter, either a remote address or an IP address, to the capture files for
+	// debugging, or stop capturing it. A GET lists the captured connections.
+	mux.HandleFunc("/capture", captureHandler(options, true))
+	mux.HandleFunc("/uncapture", captureHandler(options, false))
+
+	// List the hooks of each hook name in the order they run, with the plugins or the
+	// scripts that registered them, or only the ones in the "hook" query parameter.
+	mux.HandleFunc("/hooks", hooksHandler(options))
+
+	// List the plugins with their availability, i.e. whether their hooks are skipped
+	// after a failed call until they pass the health check.
+	mux.HandleFunc("/plugins", pluginsHandler(options))
+
 	mux.HandleFunc("/version", func(writer http.ResponseWriter, _ *http.Request) {
 		writer.WriteHeader(http.StatusOK)
 		if _, err := writer.Write([]byte(config.Version)); err != nil {
@@ -114,6 +181,178 @@ func createHTTPAPI(options *Options) *http.Server {
 	return server
 }
 
+// readyzHandler reports whether the servers are running and the pools are warmed up.
+func readyzHandler(options *Options) http.HandlerFunc {
+	return func(writer http.ResponseWriter, _ *http.Request) {
+		status, statusCode := "SERVING", http.StatusOK
+		if !readiness(options.Servers, options.WarmingUp) {
+			status, statusCode = "NOT_SERVING", http.StatusServiceUnavailable
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(statusCode)
+		if err := json.NewEncoder(writer).Encode(Healthz{Status: status}); err != nil {
+			options.Logger.Err(err).Msg("failed to serve readiness check")
+		}
+	}
+}
+
+// pauseHandler pauses or resumes the servers. The existing connections aren't affected.
+func pauseHandler(options *Options, pause bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		paused, ok := setPaused(options.Servers, request.URL.Query().Get("server"), pause)
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(writer).Encode(Paused{Servers: paused}); err != nil {
+			options.Logger.Err(err).Msg("failed to serve pause")
+		}
+	}
+}
+
+// readOnlyHandler turns the read-only mode of the servers' proxies on or off.
+// The reads are passed through in either case.
+func readOnlyHandler(options *Options, readOnly bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		servers, ok := setReadOnly(options.Servers, request.URL.Query().Get("server"), readOnly)
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(writer).Encode(ReadOnly{Servers: servers}); err != nil {
+			options.Logger.Err(err).Msg("failed to serve read-only mode")
+		}
+	}
+}
+
+// drainHandler drains the backend or returns it to service on all the servers, or on
+// the one in the "server" query parameter, and reports the drained backends.
+func drainHandler(options *Options, drain bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		var (
+			draining map[string]int
+			ok       bool
+		)
+		switch {
+		case request.Method == http.MethodGet && drain:
+			draining, ok = drainingBackends(options.Servers, query.Get("server"))
+		case request.Method == http.MethodPost && query.Get("backend") != "":
+			draining, ok = setDraining(
+				options.Servers, query.Get("server"), query.Get("backend"), drain)
+		case request.Method == http.MethodPost:
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		default:
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(writer).Encode(Draining{Backends: draining}); err != nil {
+			options.Logger.Err(err).Msg("failed to serve drain")
+		}
+	}
+}
+
+// captureHandler starts or stops capturing the connections of the client on all the
+// servers, or on the one in the "server" query parameter, and reports the captures.
+func captureHandler(options *Options, capture bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		var (
+			captured map[string]string
+			ok       bool
+		)
+		switch {
+		case request.Method == http.MethodGet && capture:
+			captured, ok = captures(options.Servers, query.Get("server"))
+		case request.Method == http.MethodPost && query.Get("client") != "":
+			captured, ok = setCapture(
+				options.Servers, query.Get("server"), query.Get("client"), capture)
+		case request.Method == http.MethodPost:
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		default:
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(writer).Encode(Captures{Connections: captured}); err != nil {
+			options.Logger.Err(err).Msg("failed to serve capture")
+		}
+	}
+}
+
+func hooksHandler(options *Options) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet {
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		chains, ok := hookChains(options.PluginRegistry, request.URL.Query().Get("hook"))
+		if !ok {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(writer).Encode(Hooks{Hooks: chains}); err != nil {
+			options.Logger.Err(err).Msg("failed to serve hooks")
+		}
+	}
+}
+
+func pluginsHandler(options *Options) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet {
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		availabilities := map[string]plugin.Availability{}
+		if options.PluginRegistry != nil {
+			availabilities = options.PluginRegistry.Availabilities()
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(writer).Encode(Plugins{Plugins: availabilities}); err != nil {
+			options.Logger.Err(err).Msg("failed to serve plugins")
+		}
+	}
+}
+
 // start starts the HTTP API.
 func (s *HTTPServer) start(options *Options, server *http.Server) {
 	// Start HTTP server (and proxy calls to gRPC server endpoint)
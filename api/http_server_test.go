@@ -5,10 +5,17 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	pluginV1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
 	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/network"
+	"github.com/gatewayd-io/gatewayd/plugin"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -90,3 +97,246 @@ func Test_HTTP_Server(t *testing.T) {
 	grpcServer.Shutdown(context.Background())
 	httpServer.Shutdown(context.Background())
 }
+
+// Test_readyzHandler tests reporting not ready while the pools are warming up.
+func Test_readyzHandler(t *testing.T) {
+	warmingUp := &atomic.Int32{}
+	options := &Options{
+		Logger:    zerolog.Nop(),
+		Servers:   map[string]*network.Server{},
+		WarmingUp: warmingUp,
+	}
+
+	warmingUp.Add(1)
+	recorder := httptest.NewRecorder()
+	readyzHandler(options).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	var healthz Healthz
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&healthz))
+	assert.Equal(t, "NOT_SERVING", healthz.Status)
+
+	warmingUp.Add(-1)
+	recorder = httptest.NewRecorder()
+	readyzHandler(options).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&healthz))
+	assert.Equal(t, "SERVING", healthz.Status)
+}
+
+// Test_readyzHandlerDraining tests reporting not ready as soon as the servers start
+// draining, while they're still live until they stop.
+func Test_readyzHandlerDraining(t *testing.T) {
+	api := getAPIConfig()
+	server := api.Servers[config.Default]
+	server.Status = config.Running
+
+	recorder := httptest.NewRecorder()
+	readyzHandler(api.Options).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	assert.True(t, server.Drain())
+	recorder = httptest.NewRecorder()
+	readyzHandler(api.Options).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	var healthz Healthz
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&healthz))
+	assert.Equal(t, "NOT_SERVING", healthz.Status)
+	assert.True(t, liveness(api.Servers))
+}
+
+// Test_pauseHandler tests pausing and resuming the servers through the HTTP API.
+func Test_pauseHandler(t *testing.T) {
+	api := getAPIConfig()
+	server := api.Servers[config.Default]
+
+	// Only POST requests pause the servers.
+	recorder := httptest.NewRecorder()
+	pauseHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/pause", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	assert.False(t, server.IsPaused())
+
+	recorder = httptest.NewRecorder()
+	pauseHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/pause", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.True(t, server.IsPaused())
+	var paused Paused
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&paused))
+	assert.Equal(t, map[string]bool{config.Default: true}, paused.Servers)
+
+	// An unknown server isn't found.
+	recorder = httptest.NewRecorder()
+	pauseHandler(api.Options, false).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/resume?server=unknown", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.True(t, server.IsPaused())
+
+	recorder = httptest.NewRecorder()
+	pauseHandler(api.Options, false).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/resume?server="+config.Default, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, server.IsPaused())
+}
+
+// Test_drainHandler tests draining a backend through the HTTP API.
+func Test_drainHandler(t *testing.T) {
+	api := getAPIConfig()
+
+	// The backend is required for draining.
+	recorder := httptest.NewRecorder()
+	drainHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/drain", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	// The backend must be one of the proxies' backends.
+	recorder = httptest.NewRecorder()
+	drainHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/drain?backend=tcp://localhost:1", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// No backend is drained.
+	recorder = httptest.NewRecorder()
+	drainHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/drain", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var draining Draining
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&draining))
+	assert.Empty(t, draining.Backends)
+
+	// The progress is only reported by the drain endpoint.
+	recorder = httptest.NewRecorder()
+	drainHandler(api.Options, false).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/undrain", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	// The servers must exist.
+	recorder = httptest.NewRecorder()
+	drainHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/drain?server=missing", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+// Test_captureHandler tests capturing the connections of a client through the HTTP API.
+func Test_captureHandler(t *testing.T) {
+	api := getAPIConfig()
+
+	// The client is required for capturing.
+	recorder := httptest.NewRecorder()
+	captureHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/capture", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	// The client must have a connection to one of the proxies.
+	recorder = httptest.NewRecorder()
+	captureHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/capture?client=10.0.0.1", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// No connection is captured.
+	recorder = httptest.NewRecorder()
+	captureHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/capture", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var captures Captures
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&captures))
+	assert.Empty(t, captures.Connections)
+
+	// The captures are only listed by the capture endpoint.
+	recorder = httptest.NewRecorder()
+	captureHandler(api.Options, false).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/uncapture", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	// The servers must exist.
+	recorder = httptest.NewRecorder()
+	captureHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/capture?server=missing", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+// Test_hooksHandler tests listing the hooks through the HTTP API.
+func Test_hooksHandler(t *testing.T) {
+	api := getAPIConfig()
+	api.Options.PluginRegistry = api.PluginRegistry
+	api.PluginRegistry.AddHook(pluginV1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 10, nil)
+
+	recorder := httptest.NewRecorder()
+	hooksHandler(api.Options).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/hooks", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var hooks Hooks
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&hooks))
+	assert.Equal(t,
+		map[string][]plugin.HookInfo{"onTrafficFromClient": {{Priority: 10}}}, hooks.Hooks)
+
+	// The hook names without hooks are listed if they're asked for.
+	recorder = httptest.NewRecorder()
+	hooksHandler(api.Options).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/hooks?hook=onTrafficToClient", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var hook Hooks
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&hook))
+	assert.Equal(t, map[string][]plugin.HookInfo{"onTrafficToClient": {}}, hook.Hooks)
+
+	recorder = httptest.NewRecorder()
+	hooksHandler(api.Options).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/hooks?hook=onUnknown", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	hooksHandler(api.Options).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/hooks", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+// Test_pluginsHandler tests listing the availability of the plugins through the HTTP API.
+func Test_pluginsHandler(t *testing.T) {
+	api := getAPIConfig()
+	api.Options.PluginRegistry = api.PluginRegistry
+	api.PluginRegistry.Add(&plugin.Plugin{ID: sdkPlugin.Identifier{Name: "cache"}})
+
+	recorder := httptest.NewRecorder()
+	pluginsHandler(api.Options).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/plugins", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var plugins Plugins
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&plugins))
+	assert.Equal(t,
+		map[string]plugin.Availability{"cache": {Available: true}}, plugins.Plugins)
+
+	recorder = httptest.NewRecorder()
+	pluginsHandler(api.Options).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/plugins", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+// Test_readOnlyHandler tests turning the read-only mode on and off through the HTTP API.
+func Test_readOnlyHandler(t *testing.T) {
+	api := getAPIConfig()
+
+	recorder := httptest.NewRecorder()
+	readOnlyHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, "/readonly", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	readOnlyHandler(api.Options, true).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/readonly", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var readOnly ReadOnly
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&readOnly))
+	assert.Equal(t, map[string]bool{config.Default: true}, readOnly.Servers)
+	assert.True(t, api.Options.Servers[config.Default].Proxy.IsReadOnly())
+
+	recorder = httptest.NewRecorder()
+	readOnlyHandler(api.Options, false).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/readwrite?server="+config.Default, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, api.Options.Servers[config.Default].Proxy.IsReadOnly())
+
+	recorder = httptest.NewRecorder()
+	readOnlyHandler(api.Options, false).ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodPost, "/readwrite?server=missing", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
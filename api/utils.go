@@ -1,7 +1,10 @@
 package api
 
 import (
+	"sync/atomic"
+
 	"github.com/gatewayd-io/gatewayd/network"
+	"github.com/gatewayd-io/gatewayd/plugin"
 )
 
 func liveness(servers map[string]*network.Server) bool {
@@ -12,3 +15,163 @@ func liveness(servers map[string]*network.Server) bool {
 	}
 	return true
 }
+
+// readiness returns true if the servers are running and all the pools are
+// warmed up, so that the clients can be served. The servers that are draining
+// aren't ready, even though they're still running, and neither are the servers
+// whose backends are all down.
+func readiness(servers map[string]*network.Server, warmingUp *atomic.Int32) bool {
+	if warmingUp != nil && warmingUp.Load() > 0 {
+		return false
+	}
+	for _, server := range servers {
+		if server.IsDraining() {
+			return false
+		}
+		if server.Proxy != nil && !server.Proxy.HasHealthyBackend() {
+			return false
+		}
+	}
+	return liveness(servers)
+}
+
+// setPaused pauses or resumes accepting new connections on the named server, or
+// on all the servers if the name is empty. It returns whether each affected server
+// is paused, or false if there's no server with the name.
+func setPaused(servers map[string]*network.Server, name string, pause bool) (map[string]bool, bool) {
+	paused := map[string]bool{}
+	for serverName, server := range servers {
+		if name != "" && serverName != name {
+			continue
+		}
+
+		if pause {
+			server.Pause()
+		} else {
+			server.Resume()
+		}
+		paused[serverName] = server.IsPaused()
+	}
+
+	return paused, name == "" || len(paused) > 0
+}
+
+// setReadOnly turns the read-only mode on or off on the proxy of the named server,
+// or of all the servers if the name is empty. It returns whether each affected server
+// is in read-only mode, or false if there's no server with the name.
+func setReadOnly(servers map[string]*network.Server, name string, readOnly bool) (map[string]bool, bool) {
+	modes := map[string]bool{}
+	for serverName, server := range servers {
+		if (name != "" && serverName != name) || server.Proxy == nil {
+			continue
+		}
+
+		server.Proxy.SetReadOnly(readOnly)
+		modes[serverName] = server.Proxy.IsReadOnly()
+	}
+
+	return modes, name == "" || len(modes) > 0
+}
+
+// setDraining drains the backend or returns it to service on the named server, or on
+// all the servers if the name is empty. It returns the drained backends of the affected
+// servers, or false if none of them has the backend.
+func setDraining(
+	servers map[string]*network.Server, name, backend string, drain bool,
+) (map[string]int, bool) {
+	found := false
+	for serverName, server := range servers {
+		if (name != "" && serverName != name) || server.Proxy == nil {
+			continue
+		}
+		if server.Proxy.DrainBackend(backend, drain) {
+			found = true
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	return drainingBackends(servers, name)
+}
+
+// drainingBackends returns the drained backends of the named server, or of all the
+// servers if the name is empty, with the number of their busy server connections.
+// It returns false if there's no server with the name.
+func drainingBackends(servers map[string]*network.Server, name string) (map[string]int, bool) {
+	draining, found := map[string]int{}, false
+	for serverName, server := range servers {
+		if name != "" && serverName != name {
+			continue
+		}
+		found = true
+		if server.Proxy == nil {
+			continue
+		}
+		for backend, remaining := range server.Proxy.DrainingBackends() {
+			draining[backend] += remaining
+		}
+	}
+
+	return draining, name == "" || found
+}
+
+// setCapture starts or stops capturing the connections of the client on the named
+// server, or on all the servers if the name is empty. It returns the captured
+// connections of the affected servers, or false if none of them has the client.
+func setCapture(
+	servers map[string]*network.Server, name, client string, capture bool,
+) (map[string]string, bool) {
+	found := false
+	for serverName, server := range servers {
+		if (name != "" && serverName != name) || server.Proxy == nil {
+			continue
+		}
+		if server.Proxy.SetCapture(client, capture) {
+			found = true
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	return captures(servers, name)
+}
+
+// captures returns the capture files of the captured connections of the named server,
+// or of all the servers if the name is empty, by the remote address of the connections.
+// It returns false if there's no server with the name.
+func captures(servers map[string]*network.Server, name string) (map[string]string, bool) {
+	captured, found := map[string]string{}, false
+	for serverName, server := range servers {
+		if name != "" && serverName != name {
+			continue
+		}
+		found = true
+		if server.Proxy == nil {
+			continue
+		}
+		for remote, path := range server.Proxy.Captures() {
+			captured[remote] = path
+		}
+	}
+
+	return captured, name == "" || found
+}
+
+// hookChains returns the chains of all the hooks, or only of the hook name if any.
+// It returns false if the hook name is unknown.
+func hookChains(registry *plugin.Registry, name string) (map[string][]plugin.HookInfo, bool) {
+	if registry == nil {
+		return map[string][]plugin.HookInfo{}, name == ""
+	}
+	if name == "" {
+		return registry.Chains(), true
+	}
+
+	hookName, ok := plugin.HookNameOf(name)
+	if !ok {
+		return nil, false
+	}
+	return map[string][]plugin.HookInfo{plugin.ConfigHookName(hookName): registry.Chain(hookName)}, true
+}
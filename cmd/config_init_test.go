@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"testing"
@@ -33,3 +34,26 @@ func Test_configInitCmd(t *testing.T) {
 	err = os.Remove(globalTestConfigFile)
 	assert.Nil(t, err)
 }
+
+func Test_configInitCmdJSON(t *testing.T) {
+	globalTestConfigFile := "./test_global_configInitCmd.json"
+	// The config file is created in the format of its extension.
+	output, err := executeCommandC(rootCmd, "config", "init", "-c", globalTestConfigFile)
+	require.NoError(t, err, "configInitCmd should not return an error")
+	assert.Equal(t,
+		fmt.Sprintf("Config file '%s' was created successfully.", globalTestConfigFile),
+		output,
+		"configInitCmd should print the correct output")
+	contents, err := os.ReadFile(globalTestConfigFile)
+	require.NoError(t, err)
+	assert.True(t, json.Valid(contents), "configInitCmd should create a JSON config file")
+
+	// The JSON config file is loaded like the YAML one.
+	output, err = executeCommandC(rootCmd, "config", "lint", "-c", globalTestConfigFile)
+	require.NoError(t, err, "configLintCmd should not return an error")
+	assert.Equal(t, "global config is valid\n", output, "configLintCmd should print the correct output")
+
+	// Clean up.
+	err = os.Remove(globalTestConfigFile)
+	assert.Nil(t, err)
+}
@@ -3,8 +3,10 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/gatewayd-io/gatewayd/config"
 	gerr "github.com/gatewayd-io/gatewayd/errors"
@@ -31,7 +33,7 @@ func generateConfig(
 		logger.Fatal(err)
 	}
 
-	// Marshal the config file to YAML.
+	// Marshal the config file in the format of its extension, YAML by default.
 	var konfig *koanf.Koanf
 	switch fileType {
 	case Global:
@@ -41,10 +43,14 @@ func generateConfig(
 	default:
 		logger.Fatal("Invalid config file type")
 	}
-	cfg, err := konfig.Marshal(yaml.Parser())
+	cfg, err := konfig.Marshal(config.Parser(configFile))
 	if err != nil {
 		logger.Fatal(err)
 	}
+	// Only YAML has comments.
+	if fileType == Plugins && config.IsYAML(configFile) {
+		cfg = commentPluginConfig(cfg)
+	}
 
 	// Check if the config file already exists and if we should overwrite it.
 	exists := false
@@ -67,6 +73,87 @@ func generateConfig(
 	cmd.Printf("Config file '%s' was %s successfully.", configFile, verb)
 }
 
+// pluginConfigComments are the comments of the keys of the generated plugins config.
+var pluginConfigComments = map[string]string{
+	"compatibilityPolicy": `The compatibility policy controls how GatewayD treats plugins' requirements:
+- "strict": the plugin is rejected if it requires a version of another plugin that isn't loaded.
+- "loose": the plugin is allowed to run anyway.`,
+	"enableMetricsMerger": `Collect the Prometheus metrics of the plugins via Unix domain sockets and
+expose them, merged, via the GatewayD metrics endpoint.`,
+	"metricsMergerPeriod": "How often the metrics of the plugins are collected and merged.",
+	"healthCheckPeriod":   "How often the plugins are pinged. The unhealthy plugins are removed.",
+	"reloadOnCrash":       "Restart the plugins that crash, as detected by the health check.",
+	"timeout":             "How long to wait for a plugin to respond to a hook.",
+	"startTimeout":        "How long to wait for a plugin to start.",
+	"defaultPolicy":       "The policy applied to the signals of the plugins: passthrough or terminate.",
+	"policyTimeout":       "How long to wait for the evaluation of a policy.",
+	"actionTimeout":       "The timeout of the actions that don't specify a timeout themselves.",
+	"actionRedis":         "A Redis server for publishing the async actions to, if enabled.",
+	"policies":            "The policies to apply to the signals received from the plugins.",
+	"disabledHooks": `The hook types whose hooks are skipped entirely, e.g. onTrafficFromClient,
+whatever registered them. Reloaded on SIGHUP and SIGUSR2.`,
+	"circuitBreaker": `Skip the hook of a plugin for the cooldown after the failed or slow runs
+in a row, then retest it with a single run.`,
+	"maxPayloadSize": `The largest request or response in bytes that the plugins can return in place
+of the original one. Larger, empty or malformed payloads are rejected.`,
+}
+
+// pluginConfigExample is the commented-out example plugin of the generated plugins config.
+const pluginConfigExample = `# The plugins to load, in the order of their priority. The plugins are Go executables
+# implementing the GatewayD plugin interface via the GatewayD plugin SDK using gRPC.
+# - name: the name of the plugin, which must be unique.
+# - enabled: whether to load the plugin.
+# - url: where "gatewayd plugin install" gets the plugin from, with its version.
+# - localPath: the path to the plugin's executable.
+# - args: the command line arguments passed to the plugin, optional.
+# - env: the environment variables passed to the plugin. MAGIC_COOKIE_KEY and
+#   MAGIC_COOKIE_VALUE verify the identity of the plugin and are required.
+# - checksum: the SHA256 hash of the plugin's executable, verified before it's loaded.
+# The plugins declare the hooks they register and the plugins they require themselves.
+plugins: []
+# plugins:
+#   - name: gatewayd-plugin-cache
+#     enabled: True
+#     url: github.com/gatewayd-io/gatewayd-plugin-cache@latest
+#     localPath: ../gatewayd-plugin-cache/gatewayd-plugin-cache
+#     args: ["--log-level", "info"]
+#     env:
+#       - MAGIC_COOKIE_KEY=GATEWAYD_PLUGIN
+#       - MAGIC_COOKIE_VALUE=5712b87aa5d7e9f9e9ab643e6603181c5b796015cb1c09d6f5ada882bf2a1872
+#     checksum: <sha256 of the executable>
+`
+
+// commentPluginConfig adds the comments to the top-level keys of the plugins config
+// and replaces the empty list of plugins with a commented-out example plugin.
+func commentPluginConfig(cfg []byte) []byte {
+	var commented strings.Builder
+	commented.WriteString("# GatewayD Plugin Configuration\n")
+
+	for _, line := range strings.Split(strings.TrimRight(string(cfg), "\n"), "\n") {
+		key, _, isKey := strings.Cut(line, ":")
+		if !isKey || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "-") {
+			commented.WriteString(line + "\n")
+			continue
+		}
+
+		if key == "plugins" {
+			// The plugins are added at the end.
+			continue
+		}
+
+		commented.WriteString("\n")
+		if comment, ok := pluginConfigComments[key]; ok {
+			for _, commentLine := range strings.Split(comment, "\n") {
+				commented.WriteString("# " + commentLine + "\n")
+			}
+		}
+		commented.WriteString(line + "\n")
+	}
+
+	commented.WriteString("\n" + pluginConfigExample)
+	return []byte(commented.String())
+}
+
 // lintConfig lints the given config file of the given type.
 func lintConfig(fileType configFileType, configFile string) *gerr.GatewayDError {
 	// Load the config file and check it for errors.
@@ -151,3 +238,19 @@ func lintConfig(fileType configFileType, configFile string) *gerr.GatewayDError
 
 	return nil
 }
+
+// printEffectiveConfig prints the global and plugin configs that GatewayD runs with,
+// as YAML documents, with the sensitive values redacted.
+func printEffectiveConfig(cmd *cobra.Command, conf *config.Config) error {
+	for index, konfig := range []*koanf.Koanf{conf.GlobalKoanf, conf.PluginKoanf} {
+		output, err := yaml.Parser().Marshal(config.Redact(konfig.Raw()))
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		if index > 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "---")
+		}
+		fmt.Fprint(cmd.OutOrStdout(), string(output))
+	}
+	return nil
+}
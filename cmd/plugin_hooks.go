@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/api"
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/maps"
+)
+
+var (
+	hookName  string
+	hooksJSON bool
+)
+
+// pluginHooksCmd represents the plugin hooks command.
+var pluginHooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "List the hooks of a running GatewayD instance in the order they run",
+	Long: "List the hooks of a running GatewayD instance, queried from its admin API, in the " +
+		"order they run, with the plugins or the scripts that registered them, e.g. for " +
+		"verifying that the priorities produce the intended order.",
+	Run: func(cmd *cobra.Command, _ []string) {
+		// Use the admin API address in the config file, unless it's given.
+		address := apiAddress
+		if !cmd.Flags().Changed("api-address") && cmd.Flags().Changed("config") {
+			configAddress, err := apiAddressFromConfig(globalConfigFile)
+			if err != nil {
+				cmd.PrintErrln("Failed to read the admin API address from the config file: ", err)
+				return
+			}
+			address = configAddress
+		}
+
+		hooks, err := getHooks(address, hookName, statusTimeout)
+		if err != nil {
+			cmd.PrintErrln("Failed to get the hooks: ", err)
+			return
+		}
+
+		if hooksJSON {
+			output, err := json.MarshalIndent(hooks, "", "  ")
+			if err != nil {
+				cmd.PrintErrln("Failed to marshal the hooks: ", err)
+				return
+			}
+			cmd.Println(string(output))
+			return
+		}
+
+		printHooks(cmd, hooks)
+	},
+}
+
+func init() {
+	pluginCmd.AddCommand(pluginHooksCmd)
+
+	pluginHooksCmd.Flags().StringVarP(
+		&apiAddress, // Already exists in status.go
+		"api-address", "a", config.DefaultHTTPAPIAddress,
+		"Address of the HTTP admin API of the running instance")
+	pluginHooksCmd.Flags().StringVarP(
+		&globalConfigFile, // Already exists in run.go
+		"config", "c", config.GetDefaultConfigFilePath(config.GlobalConfigFilename),
+		"Global config file, for reading the admin API address")
+	pluginHooksCmd.Flags().StringVar(
+		&hookName, "hook", "", "Only list the hooks of this hook name, e.g. onTrafficFromClient")
+	pluginHooksCmd.Flags().BoolVar(
+		&hooksJSON, "json", false, "Print the hooks as JSON")
+	pluginHooksCmd.Flags().DurationVar(
+		&statusTimeout, // Already exists in status.go
+		"timeout", config.DefaultStatusTimeout, "Timeout of the admin API request")
+}
+
+// getHooks queries the admin API at the address for the hooks of the instance.
+func getHooks(address, hook string, timeout time.Duration) (*api.Hooks, error) {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	endpoint := strings.TrimSuffix(address, "/") + "/hooks"
+	if hook != "" {
+		endpoint += "?hook=" + url.QueryEscape(hook)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	body, err := getAPI(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var hooks api.Hooks
+	if err := json.Unmarshal(body, &hooks); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return &hooks, nil
+}
+
+// printHooks prints the hooks of each hook name in the order they run.
+func printHooks(cmd *cobra.Command, hooks *api.Hooks) {
+	if len(hooks.Hooks) == 0 {
+		cmd.Println("No hooks registered")
+		return
+	}
+
+	names := maps.Keys(hooks.Hooks)
+	slices.Sort(names)
+	for _, name := range names {
+		cmd.Printf("%s:\n", name)
+		if len(hooks.Hooks[name]) == 0 {
+			cmd.Println("  No hooks registered")
+		}
+		for index, hook := range hooks.Hooks[name] {
+			owner, ownerType := hook.Name, hook.Type
+			if owner == "" {
+				owner, ownerType = "gatewayd", "builtin"
+			}
+			cmd.Printf("  %d. %s (%s, priority %d)\n", index+1, owner, ownerType, hook.Priority)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_pluginHooksCmd(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/hooks" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if request.URL.Query().Get("hook") == "onTrafficToClient" {
+			_, _ = writer.Write([]byte(`{"hooks":{"onTrafficToClient":[]}}`))
+			return
+		}
+		_, _ = writer.Write([]byte(`{"hooks":{` +
+			`"onTrafficFromClient":[{"priority":5,"name":"","type":""},` +
+			`{"priority":10,"name":"block-truncate","type":"script"},` +
+			`{"priority":20,"name":"gatewayd-plugin-cache","type":"plugin"}],` +
+			`"onNewLogger":[{"priority":20,"name":"gatewayd-plugin-cache","type":"plugin"}]}}`))
+	}))
+	defer server.Close()
+
+	output, err := executeCommandC(rootCmd, "plugin", "hooks", "--api-address", server.URL)
+	require.NoError(t, err, "plugin hooks command should not have returned an error")
+	assert.Equal(t,
+		"onNewLogger:\n"+
+			"  1. gatewayd-plugin-cache (plugin, priority 20)\n"+
+			"onTrafficFromClient:\n"+
+			"  1. gatewayd (builtin, priority 5)\n"+
+			"  2. block-truncate (script, priority 10)\n"+
+			"  3. gatewayd-plugin-cache (plugin, priority 20)\n",
+		output,
+		"plugin hooks command should have printed the hooks in order")
+
+	output, err = executeCommandC(
+		rootCmd, "plugin", "hooks", "--api-address", server.URL, "--hook", "onTrafficToClient")
+	require.NoError(t, err, "plugin hooks command should not have returned an error")
+	assert.Equal(t, "onTrafficToClient:\n  No hooks registered\n", output)
+	hookName = ""
+
+	// The admin API must be reachable.
+	server.Close()
+	output, err = executeCommandC(rootCmd, "plugin", "hooks", "--api-address", server.URL)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Failed to get the hooks")
+}
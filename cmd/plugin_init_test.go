@@ -20,6 +20,21 @@ func Test_pluginInitCmd(t *testing.T) {
 		"plugin init command should have returned the correct output")
 	assert.FileExists(t, pluginTestConfigFile, "plugin init command should have created a config file")
 
+	// The generated config is commented and valid.
+	contents, err := os.ReadFile(pluginTestConfigFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), "# The compatibility policy controls")
+	assert.Contains(t, string(contents), "#   - name: gatewayd-plugin-cache")
+	assert.Contains(t, string(contents), "\nplugins: []\n")
+	assert.Nil(t, lintConfig(Plugins, pluginTestConfigFile))
+
+	// The existing config is only overwritten with --force.
+	output, err = executeCommandC(rootCmd, "plugin", "init", "-p", pluginTestConfigFile, "--force")
+	require.NoError(t, err, "plugin init command should not have returned an error")
+	assert.Equal(t,
+		fmt.Sprintf("Config file '%s' was overwritten successfully.", pluginTestConfigFile),
+		output)
+
 	// Clean up.
 	err = os.Remove(pluginTestConfigFile)
 	assert.Nil(t, err)
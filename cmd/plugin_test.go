@@ -19,6 +19,7 @@ Usage:
 
 Available Commands:
   help        Help about any command
+  hooks       List the hooks of a running GatewayD instance in the order they run
   init        Create or overwrite the GatewayD plugins config
   install     Install a plugin from a local archive or a GitHub repository
   lint        Lint the GatewayD plugins config
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/network"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayFile    string
+	replayNetwork string
+	replayAddress string
+	replayTimeout time.Duration
+)
+
+// replayExchange is a request of the capture with the responses captured after it.
+type replayExchange struct {
+	request   []byte
+	responses []byte
+}
+
+// replayCmd represents the replay command.
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay a captured connection against a database server",
+	Long: "Replay the requests of a connection captured by GatewayD against a database server, " +
+		"e.g. for reproducing a bug, and compare the responses with the captured ones.",
+	Run: func(cmd *cobra.Command, _ []string) {
+		if replayFile == "" {
+			cmd.PrintErrln("The capture file is required")
+			return
+		}
+
+		file, err := os.Open(replayFile)
+		if err != nil {
+			cmd.PrintErrln("Failed to open the capture file: ", err)
+			return
+		}
+		defer file.Close()
+
+		exchanges, err := readExchanges(file)
+		if err != nil {
+			cmd.PrintErrln("Failed to read the capture file: ", err)
+			return
+		}
+
+		client := network.NewClient(
+			context.Background(),
+			&config.Client{
+				Network:          replayNetwork,
+				Address:          replayAddress,
+				ReceiveChunkSize: config.DefaultChunkSize,
+				ReceiveDeadline:  replayTimeout,
+				SendDeadline:     replayTimeout,
+				DialTimeout:      config.DefaultDialTimeout,
+				ReceiveStrategy:  config.ReadFramed,
+			},
+			zerolog.Nop(),
+			nil,
+		)
+		if client == nil {
+			cmd.PrintErrf("Failed to connect to %s://%s\n", replayNetwork, replayAddress)
+			return
+		}
+		defer client.Close()
+
+		replay(cmd, client, exchanges)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().StringVarP(
+		&replayFile, "file", "f", "", "Capture file to replay")
+	replayCmd.Flags().StringVarP(
+		&replayNetwork, "network", "n", config.DefaultNetwork, "Network of the database server")
+	replayCmd.Flags().StringVarP(
+		&replayAddress, "address", "a", config.DefaultAddress, "Address of the database server")
+	replayCmd.Flags().DurationVar(
+		&replayTimeout, "timeout", config.DefaultReplayTimeout, "Timeout of the replay")
+}
+
+// readExchanges reads the requests of the capture with the responses captured after each.
+func readExchanges(reader io.Reader) ([]replayExchange, error) {
+	capture, err := network.NewCaptureReader(reader)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	exchanges := []replayExchange{}
+	for {
+		frame, err := capture.Next()
+		if errors.Is(err, io.EOF) {
+			return exchanges, nil
+		}
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		if frame.Direction == network.CaptureFromClient {
+			exchanges = append(exchanges, replayExchange{request: frame.Payload})
+		} else if len(exchanges) > 0 {
+			last := &exchanges[len(exchanges)-1]
+			last.responses = append(last.responses, frame.Payload...)
+		}
+	}
+}
+
+// replay sends the requests to the server one by one, waits for as many bytes as
+// were captured in response to each, and prints how the responses compare.
+func replay(cmd *cobra.Command, client network.IClient, exchanges []replayExchange) {
+	cmd.Printf("Replaying %d request(s) against %s://%s\n",
+		len(exchanges), client.GetNetwork(), client.GetAddress())
+
+	for index, exchange := range exchanges {
+		if _, err := client.Send(exchange.request); err != nil {
+			cmd.PrintErrf("  #%d: failed to send the request: %s\n", index+1, err)
+			return
+		}
+
+		response := []byte{}
+		for len(response) < len(exchange.responses) {
+			_, received, err := client.Receive()
+			response = append(response, received...)
+			if err != nil {
+				cmd.PrintErrf("  #%d: failed to receive the response: %s\n", index+1, err)
+				return
+			}
+		}
+
+		comparison := "same response"
+		if !bytes.Equal(response, exchange.responses) {
+			comparison = "different response"
+		}
+		cmd.Printf("  #%d: sent %d bytes, received %d of %d bytes, %s\n",
+			index+1, len(exchange.request), len(response), len(exchange.responses), comparison)
+	}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/gatewayd-io/gatewayd/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_replayCmd(t *testing.T) {
+	ready := []byte{'Z', 0, 0, 0, 5, 'I'}
+	query := []byte{'Q', 0, 0, 0, 13, 'S', 'E', 'L', 'E', 'C', 'T', ' ', '1', 0}
+
+	// The capture of a query, to which the server responded differently.
+	path := filepath.Join(t.TempDir(), "capture"+network.CaptureExtension)
+	capture, err := network.NewCapture(path, 1024)
+	require.NoError(t, err)
+	capture.Record(network.CaptureFromClient, query)
+	capture.Record(network.CaptureFromServer, []byte{'Z', 0, 0, 0, 5, 'T'})
+	capture.Record(network.CaptureFromClient, query)
+	capture.Record(network.CaptureFromServer, ready)
+	require.NoError(t, capture.Close())
+
+	// The fake database server responds to every request with the same message.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buffer := make([]byte, 1024)
+		for {
+			if _, err := conn.Read(buffer); err != nil {
+				return
+			}
+			if _, err := conn.Write(ready); err != nil {
+				return
+			}
+		}
+	}()
+
+	output, err := executeCommandC(
+		rootCmd, "replay", "--file", path, "--address", listener.Addr().String())
+	require.NoError(t, err, "replay command should not have returned an error")
+	assert.Equal(t,
+		"Replaying 2 request(s) against tcp://"+listener.Addr().String()+"\n"+
+			"  #1: sent 14 bytes, received 6 of 6 bytes, different response\n"+
+			"  #2: sent 14 bytes, received 6 of 6 bytes, same response\n",
+		output,
+		"replay command should have printed the comparison of the responses")
+
+	// The capture file must be valid.
+	output, err = executeCommandC(rootCmd, "replay", "--file", filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	assert.Contains(t, output, "Failed to open the capture file")
+	replayFile = ""
+}
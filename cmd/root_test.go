@@ -19,7 +19,9 @@ Available Commands:
   config      Manage GatewayD global configuration
   help        Help about any command
   plugin      Manage plugins and their configuration
+  replay      Replay a captured connection against a database server
   run         Run a GatewayD instance
+  status      Show the status of a running GatewayD instance
   version     Show version information
 
 Flags:
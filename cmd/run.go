@@ -12,7 +12,10 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"slices"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -66,21 +69,27 @@ var (
 	enableSentry      bool
 	devMode           bool
 	enableUsageReport bool
+	checkConfig       bool
 	pluginConfigFile  string
 	globalConfigFile  string
 	conf              *config.Config
 	pluginRegistry    *plugin.Registry
 	actRegistry       *act.Registry
 	metricsServer     *http.Server
+	metricsPusher     *metrics.Pusher
 
 	UsageReportURL = "localhost:59091"
 
 	loggers              = make(map[string]zerolog.Logger)
 	pools                = make(map[string]*pool.Pool)
 	clients              = make(map[string]*config.Client)
+	retryBudgets         = make(map[string]*network.RetryBudget)
+	reconnectLimiters    = make(map[string]*network.ReconnectLimiter)
 	proxies              = make(map[string]*network.Proxy)
 	servers              = make(map[string]*network.Server)
 	healthCheckScheduler = gocron.NewScheduler(time.UTC)
+	// warmingUp is the number of pools that are still warming up.
+	warmingUp = &atomic.Int32{}
 
 	stopChan = make(chan struct{})
 )
@@ -98,24 +107,17 @@ func StopGracefully(
 	grpcServer *api.GRPCServer,
 ) {
 	_, span := otel.Tracer(config.TracerName).Start(runCtx, "Shutdown server")
-	currentSignal := "unknown"
-	if sig != nil {
-		currentSignal = sig.String()
-	}
+	currentSignal := signalName(sig)
 
 	logger.Info().Msg("Notifying the plugins that the server is shutting down")
+	notifySignal(sig, pluginRegistry, logger, span)
 	if pluginRegistry != nil {
-		pluginTimeoutCtx, cancel := context.WithTimeout(context.Background(), conf.Plugin.Timeout)
-		defer cancel()
-
-		//nolint:contextcheck
-		_, err := pluginRegistry.Run(
-			pluginTimeoutCtx,
+		if err := pluginRegistry.RunLifecycleHook(
+			plugin.OnShutdownStartHookName,
 			map[string]interface{}{"signal": currentSignal},
-			v1.HookName_HOOK_NAME_ON_SIGNAL,
-		)
-		if err != nil {
-			logger.Error().Err(err).Msg("Failed to run OnSignal hooks")
+			conf.Plugin.Timeout,
+		); err != nil {
+			logger.Error().Err(err).Msg("Failed to run OnShutdownStart hooks")
 			span.RecordError(err)
 		}
 	}
@@ -135,6 +137,11 @@ func StopGracefully(
 		logger.Info().Msg("Stopped metrics merger")
 		span.AddEvent("Stopped metrics merger")
 	}
+	if metricsPusher != nil {
+		metricsPusher.Stop()
+		logger.Info().Msg("Stopped metrics pusher")
+		span.AddEvent("Stopped metrics pusher")
+	}
 	if metricsServer != nil {
 		//nolint:contextcheck
 		if err := metricsServer.Shutdown(context.Background()); err != nil {
@@ -145,6 +152,26 @@ func StopGracefully(
 			span.AddEvent("Stopped metrics server")
 		}
 	}
+	// Stop accepting new connections and let the existing ones drain
+	// within the grace period of each server.
+	var drainGroup sync.WaitGroup
+	var drained atomic.Bool
+	drained.Store(true)
+	for name, server := range servers {
+		drainGroup.Add(1)
+		go func(name string, server *network.Server) {
+			defer drainGroup.Done()
+			logger.Info().Str("name", name).Msg("Draining server")
+			if !server.Drain() {
+				drained.Store(false)
+			}
+		}(name, server)
+	}
+	drainGroup.Wait()
+	span.AddEvent("Drained servers")
+
+	// Stop the servers, each of which runs the OnShutdown hooks of the plugins
+	// (HOOK_NAME_ON_SHUTDOWN), before the plugins are stopped.
 	for name, server := range servers {
 		logger.Info().Str("name", name).Msg("Stopping server")
 		server.Shutdown()
@@ -152,6 +179,15 @@ func StopGracefully(
 	}
 	logger.Info().Msg("Stopped all servers")
 	if pluginRegistry != nil {
+		if err := pluginRegistry.RunLifecycleHook(
+			plugin.OnShutdownCompleteHookName,
+			map[string]interface{}{"signal": currentSignal, "drained": drained.Load()},
+			conf.Plugin.Timeout,
+		); err != nil {
+			logger.Error().Err(err).Msg("Failed to run OnShutdownComplete hooks")
+			span.RecordError(err)
+		}
+
 		pluginRegistry.Shutdown()
 		logger.Info().Msg("Stopped plugin registry")
 		span.AddEvent("Stopped plugin registry")
@@ -175,6 +211,277 @@ func StopGracefully(
 	close(stopChan)
 }
 
+// signalName returns the name of the signal, or "unknown" if there's none.
+func signalName(sig os.Signal) string {
+	if sig == nil {
+		return "unknown"
+	}
+	return sig.String()
+}
+
+// notifySignal runs the OnSignal hooks with the name of the received signal.
+func notifySignal(
+	sig os.Signal, pluginRegistry *plugin.Registry, logger zerolog.Logger, span trace.Span,
+) {
+	if pluginRegistry == nil {
+		return
+	}
+
+	pluginTimeoutCtx, cancel := context.WithTimeout(context.Background(), conf.Plugin.Timeout)
+	defer cancel()
+
+	//nolint:contextcheck
+	_, err := pluginRegistry.Run(
+		pluginTimeoutCtx,
+		map[string]interface{}{"signal": signalName(sig)},
+		v1.HookName_HOOK_NAME_ON_SIGNAL,
+	)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to run OnSignal hooks")
+		span.RecordError(err)
+	}
+}
+
+// isReloadSignal returns true if the signal should reload the config
+// instead of shutting down GatewayD.
+func isReloadSignal(sig os.Signal) bool {
+	for _, reloadSignal := range reloadSignals {
+		if sig == reloadSignal {
+			return true
+		}
+	}
+	return false
+}
+
+// isPauseSignal returns true if the signal should pause or resume
+// accepting new connections.
+func isPauseSignal(sig os.Signal) bool {
+	for _, pauseSig := range pauseSignals {
+		if sig == pauseSig {
+			return true
+		}
+	}
+	return false
+}
+
+// poolSize returns the size of the pool that's preallocated on startup, which is at
+// least the minimum pool size, or the default pool size if it's not set.
+func poolSize(cfg *config.Pool) int {
+	if cfg == nil || cfg.Size <= 0 {
+		return config.DefaultPoolSize
+	}
+	return max(cfg.Size, config.MinimumPoolSize)
+}
+
+// newConnectionBudget creates the budget of the client connections of all the servers,
+// out of the file descriptors the process can still open, minus the reserved ones and
+// the ones the pools may still grow by. It returns nil if there's no reservation, or
+// if the file descriptors can't be counted on this platform.
+func newConnectionBudget(
+	reserved int, pools map[string]*config.Pool, logger zerolog.Logger,
+) *network.ConnectionBudget {
+	if reserved <= 0 {
+		return nil
+	}
+
+	available, err := network.AvailableFileDescriptors()
+	if err != nil {
+		logger.Warn().Err(err).Msg(
+			"Failed to count the file descriptors, no connections are reserved for the API")
+		return nil
+	}
+
+	growth := 0
+	for _, pool := range pools {
+		if pool != nil {
+			growth += max(pool.MaxSize-poolSize(pool), 0)
+		}
+	}
+
+	budget := network.NewConnectionBudget(available - reserved - growth)
+	if budget.Limit() == 0 {
+		logger.Error().Int("available", available).Int("reserved", reserved).Msg(
+			"The reserved connections take all the file descriptors, no client connections are accepted")
+	} else {
+		logger.Info().Fields(map[string]interface{}{
+			"budget":   budget.Limit(),
+			"reserved": reserved,
+		}).Msg("Reserved the connections of the API and the health checks")
+	}
+	return budget
+}
+
+// warmUpPool retries adding the missing clients of the pool at the given indexes
+// until all of them are added or the warmup period is over. It returns true if
+// the pool is filled.
+func warmUpPool(ctx context.Context, missing []int, period time.Duration, add func(int) bool) bool {
+	deadline := time.Now().Add(period)
+	ticker := time.NewTicker(config.DefaultWarmupInterval)
+	defer ticker.Stop()
+
+	for len(missing) > 0 {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+
+		missing = slices.DeleteFunc(missing, add)
+	}
+	return true
+}
+
+// pauseServers pauses the servers on the pause signal and resumes them on the
+// resume signal. The existing connections aren't affected.
+func pauseServers(
+	runCtx context.Context,
+	sig os.Signal,
+	pluginRegistry *plugin.Registry,
+	servers map[string]*network.Server,
+	logger zerolog.Logger,
+) {
+	_, span := otel.Tracer(config.TracerName).Start(runCtx, "Pause servers")
+	defer span.End()
+	span.SetAttributes(attribute.String("signal", signalName(sig)))
+
+	notifySignal(sig, pluginRegistry, logger, span)
+	for name, server := range servers {
+		if sig == pauseSignal {
+			server.Pause()
+		} else {
+			server.Resume()
+		}
+		logger.Debug().Str("name", name).Bool("paused", server.IsPaused()).Msg("Server state changed")
+	}
+}
+
+// reloadConfig reloads the config files and applies the changes that don't
+// require a restart: SIGHUP reloads the log levels and the plugins, and moves the
+// servers whose listen address changed, SIGUSR1 only reloads the log levels and
+// SIGUSR2 only reloads the plugins, along with the disabled hook types.
+// The current config is kept if the config files can't be loaded.
+func reloadConfig(
+	runCtx context.Context,
+	sig os.Signal,
+	pluginRegistry *plugin.Registry,
+	metricsMerger *metrics.Merger,
+	logger zerolog.Logger,
+) {
+	reloadCtx, span := otel.Tracer(config.TracerName).Start(runCtx, "Reload config")
+	defer span.End()
+	span.SetAttributes(attribute.String("signal", signalName(sig)))
+
+	logger.Info().Str("signal", signalName(sig)).Msg("Reloading the config")
+	notifySignal(sig, pluginRegistry, logger, span)
+
+	newConf := config.NewConfig(reloadCtx, config.Config{
+		GlobalConfigFile: globalConfigFile,
+		PluginConfigFile: pluginConfigFile,
+	})
+	if err := newConf.InitConfig(reloadCtx); err != nil {
+		logger.Error().Err(err).Msg("Failed to reload the config, keeping the current config")
+		span.RecordError(err)
+		return
+	}
+
+	if sig != reloadPluginsSignal {
+		if cfg, ok := newConf.Global.Loggers[config.Default]; ok {
+			level := config.If(
+				config.Exists(config.LogLevels, cfg.Level),
+				config.LogLevels[cfg.Level],
+				config.LogLevels[config.DefaultLogLevel],
+			)
+			zerolog.SetGlobalLevel(level)
+			logger.Info().Str("level", level.String()).Msg("Reloaded the log level")
+			span.AddEvent("Reloaded the log level")
+		}
+	}
+
+	if sig == reloadConfigSignal {
+		// Move the servers to their new listen addresses, keeping their connections.
+		// The servers that can't listen on their new address keep the old one.
+		for name, server := range servers {
+			cfg, ok := newConf.Global.Servers[name]
+			if !ok || cfg == nil {
+				continue
+			}
+			if rebound, err := server.Rebind(cfg.Address); err != nil {
+				span.RecordError(err)
+			} else if rebound {
+				conf.Global.Servers[name].Address = cfg.Address
+				span.AddEvent("Rebound the server " + name)
+			}
+		}
+	}
+
+	if sig != reloadLevelSignal && pluginRegistry != nil {
+		// Disable or enable the hook types before the plugins are loaded again.
+		conf.Plugin.DisabledHooks = newConf.Plugin.DisabledHooks
+		pluginRegistry.SetDisabledHooks(
+			plugin.DisabledHookNames(conf.Plugin.DisabledHooks, logger))
+
+		// Load the plugins of the new config next to the running ones, and then swap
+		// them in at once, so that the hooks keep running meanwhile. The running
+		// plugins are stopped once they're swapped out.
+		staged := pluginRegistry.Stage(
+			reloadCtx, newConf.Plugin.Plugins, newConf.Plugin.Scripts, conf.Plugin.StartTimeout)
+
+		// Merge the config defaults of the new plugins and validate their config sections,
+		// as on startup, and keep the running plugins if the config sections are invalid.
+		if err := newConf.MergePluginDefaults(reloadCtx, staged.Defaults()); err != nil {
+			logger.Error().Err(err).Msg("Failed to merge plugin config defaults")
+			span.RecordError(err)
+		}
+		if err := newConf.ValidatePluginConfigs(reloadCtx, staged.Schemas()); err != nil {
+			logger.Error().Err(err).Msg("Failed to validate the plugin configs, keeping the current plugins")
+			span.RecordError(err)
+			staged.Discard()
+			return
+		}
+
+		if metricsMerger != nil {
+			pluginRegistry.ForEach(func(pluginId sdkPlugin.Identifier, _ *plugin.Plugin) {
+				metricsMerger.Remove(pluginId.Name)
+			})
+		}
+		pluginRegistry.Swap(staged)
+		conf.Plugin.Plugins = newConf.Plugin.Plugins
+		conf.Plugin.Scripts = newConf.Plugin.Scripts
+		conf.Global.PluginConfigs = newConf.Global.PluginConfigs
+
+		if metricsMerger != nil {
+			pluginRegistry.ForEach(func(_ sdkPlugin.Identifier, plugin *plugin.Plugin) {
+				if metricsEnabled, err := strconv.ParseBool(plugin.Config["metricsEnabled"]); err == nil && metricsEnabled {
+					metricsMerger.Add(plugin.ID.Name, plugin.Config["metricsUnixDomainSocket"])
+				}
+			})
+		}
+
+		logger.Info().Int("count", pluginRegistry.Size()).Msg("Reloaded the plugins")
+		span.AddEvent("Reloaded the plugins")
+	}
+}
+
+// handleSignals runs the control function on every reload, pause and resume
+// signal, and runs the shutdown sequence exactly once on the first shutdown
+// signal. The signals received afterwards are ignored, since the shutdown is
+// already in progress.
+func handleSignals(signalsCh <-chan os.Signal, control, shutdown func(os.Signal)) {
+	for sig := range signalsCh {
+		if isReloadSignal(sig) || isPauseSignal(sig) {
+			control(sig)
+			continue
+		}
+
+		shutdown(sig)
+		return
+	}
+}
+
 // runCmd represents the run command.
 var runCmd = &cobra.Command{
 	Use:   "run",
@@ -209,7 +516,7 @@ var runCmd = &cobra.Command{
 			if err != nil {
 				span.RecordError(err)
 				cmd.Println("Sentry initialization failed: ", err)
-				return
+				os.Exit(gerr.FailedToStartSentry)
 			}
 
 			// Flush buffered events before the program terminates.
@@ -218,6 +525,23 @@ var runCmd = &cobra.Command{
 			defer sentry.Recover()
 		}
 
+		// Use the unified config file for both the global and the plugins config,
+		// unless a separate plugins config file is given.
+		if !cmd.Flags().Changed("plugin-config") {
+			unified, err := config.IsUnifiedConfigFile(globalConfigFile)
+			if err != nil {
+				log.Println(err)
+				os.Exit(gerr.FailedToLoadConfig)
+			}
+			if unified {
+				pluginConfigFile = globalConfigFile
+			}
+		}
+		if err := config.ValidateConfigLayout(globalConfigFile, pluginConfigFile); err != nil {
+			log.Println(err)
+			os.Exit(gerr.FailedToValidateConfig)
+		}
+
 		// Lint the configuration files before loading them.
 		if enableLinting {
 			_, span := otel.Tracer(config.TracerName).Start(runCtx, "Lint configuration files")
@@ -225,19 +549,22 @@ var runCmd = &cobra.Command{
 
 			// Lint the global configuration file and fail if it's not valid.
 			if err := lintConfig(Global, globalConfigFile); err != nil {
-				log.Fatal(err)
+				log.Println(err)
+				os.Exit(gerr.FailedToValidateConfig)
 			}
 
 			// Lint the plugin configuration file and fail if it's not valid.
 			if err := lintConfig(Plugins, pluginConfigFile); err != nil {
-				log.Fatal(err)
+				log.Println(err)
+				os.Exit(gerr.FailedToValidateConfig)
 			}
 		}
 
 		// Load global and plugin configuration.
 		conf = config.NewConfig(runCtx, config.Config{GlobalConfigFile: globalConfigFile, PluginConfigFile: pluginConfigFile})
 		if err := conf.InitConfig(runCtx); err != nil {
-			log.Fatal(err)
+			log.Println(err)
+			os.Exit(gerr.FailedToLoadConfig)
 		}
 
 		// Create and initialize loggers from the config.
@@ -246,6 +573,7 @@ var runCmd = &cobra.Command{
 		for name, cfg := range conf.Global.Loggers {
 			loggers[name] = logging.NewLogger(runCtx, logging.LoggerConfig{
 				Output:     cfg.GetOutput(),
+				Format:     cfg.GetFormat(),
 				ConsoleOut: cmdLogger,
 				Level: config.If(
 					config.Exists(config.LogLevels, cfg.Level),
@@ -274,9 +602,17 @@ var runCmd = &cobra.Command{
 				RSyslogNetwork: cfg.RSyslogNetwork,
 				RSyslogAddress: cfg.RSyslogAddress,
 				Name:           name,
+				ErrorLogWindow: cfg.ErrorLogWindow,
 			})
 		}
 
+		// The upstreams without a logger of their own use the default one.
+		for name := range conf.Global.Upstreams {
+			if _, ok := loggers[name]; !ok {
+				loggers[name] = loggers[config.Default]
+			}
+		}
+
 		// Set the default logger.
 		logger := loggers[config.Default]
 
@@ -348,13 +684,30 @@ var runCmd = &cobra.Command{
 					),
 					config.CompatibilityPolicies[conf.Plugin.CompatibilityPolicy],
 					config.DefaultCompatibilityPolicy),
-				Logger:  logger,
-				DevMode: devMode,
+				Verification: config.If(
+					config.Exists(
+						config.VerificationPolicies, conf.Plugin.VerificationPolicy,
+					),
+					config.VerificationPolicies[conf.Plugin.VerificationPolicy],
+					config.DefaultVerificationPolicy),
+				HookVerification: plugin.HookVerificationPolicies(
+					conf.Plugin.HookVerification, logger),
+				PublicKey:         conf.Plugin.PublicKey,
+				EnforceSignatures: conf.Plugin.EnforceSignatures,
+				GRPC:              conf.Plugin.GRPC,
+				CircuitBreaker:    conf.Plugin.CircuitBreaker,
+				Logger:            logger,
+				DevMode:           devMode,
 			},
 		)
+		// Skip the hooks of the disabled hook types, until they're enabled on reload.
+		pluginRegistry.SetDisabledHooks(
+			plugin.DisabledHookNames(conf.Plugin.DisabledHooks, logger))
 
 		// Load plugins and register their hooks.
 		pluginRegistry.LoadPlugins(runCtx, conf.Plugin.Plugins, conf.Plugin.StartTimeout)
+		// Register the scripts as hooks, after the plugins' hooks.
+		pluginRegistry.LoadScripts(conf.Plugin.Scripts)
 
 		// Start the metrics merger if enabled.
 		var metricsMerger *metrics.Merger
@@ -405,6 +758,7 @@ var runCmd = &cobra.Command{
 					}
 				} else {
 					logger.Trace().Str("name", pluginId.Name).Msg("Successfully pinged plugin")
+					pluginRegistry.MarkAvailable(pluginId.Name)
 					plugins = append(plugins, pluginId.Name)
 				}
 			})
@@ -413,6 +767,11 @@ var runCmd = &cobra.Command{
 			logger.Error().Err(err).Msg("Failed to start plugin health check scheduler")
 			span.RecordError(err)
 		}
+		// Health check the plugins right away when one of them becomes unavailable,
+		// so that it's either back or restarted without waiting for the next period.
+		pluginRegistry.OnUnavailable = func(string) {
+			healthCheckScheduler.RunAll()
+		}
 		if pluginRegistry.Size() > 0 {
 			logger.Info().Str(
 				"healthCheckPeriod", conf.Plugin.HealthCheckPeriod.String(),
@@ -426,6 +785,13 @@ var runCmd = &cobra.Command{
 		pluginTimeoutCtx, cancel := context.WithTimeout(context.Background(), conf.Plugin.Timeout)
 		defer cancel()
 
+		// Merge the config defaults contributed by the plugins, so that they are
+		// visible to the "OnConfigLoaded" hooks.
+		if err := conf.MergePluginDefaults(runCtx, pluginRegistry.Defaults()); err != nil {
+			logger.Error().Err(err).Msg("Failed to merge plugin config defaults")
+			span.RecordError(err)
+		}
+
 		// The config will be passed to the plugins that register to the "OnConfigLoaded" plugin.
 		// The plugins can modify the config and return it.
 		updatedGlobalConfig, err := pluginRegistry.Run(
@@ -442,10 +808,53 @@ var runCmd = &cobra.Command{
 			// Merge the config with the one loaded from the file (in memory).
 			// The changes won't be persisted to disk.
 			if err := conf.MergeGlobalConfig(runCtx, updatedGlobalConfig); err != nil {
-				log.Fatal(err)
+				logger.Error().Err(err).Msg("Failed to merge the config modified by the plugins")
+				os.Exit(gerr.FailedToValidateConfig)
 			}
 		}
 
+		// Validate the config sections of the plugins against their schemas, after all
+		// the merges, so that the plugins don't run with an invalid config.
+		if err := conf.ValidatePluginConfigs(runCtx, pluginRegistry.Schemas()); err != nil {
+			logger.Error().Err(err).Msg("Failed to validate the plugin configs")
+			pluginRegistry.Shutdown()
+			os.Exit(gerr.FailedToValidateConfig)
+		}
+
+		// Print the effective config, after all the merges, and exit.
+		if checkConfig {
+			err := printEffectiveConfig(cmd, conf)
+			pluginRegistry.Shutdown()
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to print the effective config")
+				os.Exit(gerr.FailedToValidateConfig)
+			}
+			return
+		}
+
+		// Export the Go runtime metrics with the metrics of GatewayD, if enabled.
+		if err := metrics.RegisterRuntimeMetrics(
+			prometheus.DefaultRegisterer, conf.Global.Metrics[config.Default].GoRuntime); err != nil {
+			logger.Error().Err(err).Msg("Failed to register the Go runtime metrics")
+		}
+
+		// Push the metrics to the Pushgateway periodically, if configured.
+		if metricsConfig := conf.Global.Metrics[config.Default]; metricsConfig.PushURL != "" {
+			metricsPusher = metrics.NewPusher(runCtx, metrics.Pusher{
+				Logger: logger,
+				URL:    metricsConfig.PushURL,
+				Job: config.If(
+					metricsConfig.PushJob != "", metricsConfig.PushJob, config.DefaultMetricsPushJob),
+				Interval: config.If(
+					metricsConfig.PushInterval > 0,
+					metricsConfig.PushInterval,
+					config.DefaultMetricsPushInterval,
+				),
+				Timeout: metricsConfig.Timeout,
+			})
+			metricsPusher.Start()
+		}
+
 		// Start the metrics server if enabled.
 		// TODO: Start multiple metrics servers. For now, only one default is supported.
 		// I should first find a use case for those multiple metrics servers.
@@ -624,34 +1033,32 @@ var runCmd = &cobra.Command{
 		// Create and initialize pools of connections.
 		for name, cfg := range conf.Global.Pools {
 			logger := loggers[name]
-			// Check if the pool size is greater than zero.
-			currentPoolSize := config.If(
-				cfg.Size > 0,
-				// Check if the pool size is greater than the minimum pool size.
-				config.If(
-					cfg.Size > config.MinimumPoolSize,
-					cfg.Size,
-					config.MinimumPoolSize,
-				),
-				config.DefaultPoolSize,
-			)
-			pools[name] = pool.NewPool(runCtx, currentPoolSize)
+			currentPoolSize := poolSize(cfg)
+			// The pool has room for the server connections it grows by lazily under load.
+			pools[name] = pool.NewPool(runCtx, max(currentPoolSize, cfg.MaxSize))
 
 			span.AddEvent("Create pool", trace.WithAttributes(
 				attribute.String("name", name),
 				attribute.Int("size", currentPoolSize),
+				attribute.Int("maxSize", cfg.MaxSize),
+				attribute.String("idleTimeout", cfg.IdleTimeout.String()),
+				attribute.Int("maxConcurrentDials", cfg.MaxConcurrentDials),
+				attribute.String("dialWaitTimeout", cfg.DialWaitTimeout.String()),
 			))
 
 			// Get client config from the config file.
 			if clientConfig, ok := conf.Global.Clients[name]; !ok {
 				// This ensures that the default client config is used if the pool name is not
-				// found in the clients section.
-				clients[name] = conf.Global.Clients[config.Default]
+				// found in the clients section. It's copied, since it's named after the pool.
+				defaultClient := *conf.Global.Clients[config.Default]
+				clients[name] = &defaultClient
 			} else {
 				// Merge the default client config with the one from the pool.
 				clients[name] = clientConfig
 			}
 
+			clients[name].Name = name
+
 			// Fill the missing and zero values with the default ones.
 			clients[name].TCPKeepAlivePeriod = config.If(
 				clients[name].TCPKeepAlivePeriod > 0,
@@ -684,10 +1091,17 @@ var runCmd = &cobra.Command{
 				config.DefaultDialTimeout,
 			)
 
-			// Add clients to the pool.
-			for range currentPoolSize {
-				clientConfig := clients[name]
-				client := network.NewClient(
+			// All the clients of the pool (and the proxy) share a single retry budget.
+			retryBudgets[name] = network.NewRetryBudget(
+				clients[name].RetryBudgetRate, clients[name].RetryBudgetBurst)
+			// ... and a single limit on the concurrent reconnections.
+			reconnectLimiters[name] = network.NewReconnectLimiter(clients[name].MaxReconnects)
+
+			// newClient creates the client of the pool at the index.
+			// The clients are spread over the backends, if any.
+			newClient := func(index int) (*network.Client, *config.Client) {
+				clientConfig := clients[name].GetWeightedBackend(index)
+				return network.NewClient(
 					runCtx, clientConfig, logger,
 					network.NewRetry(
 						network.Retry{
@@ -699,72 +1113,103 @@ var runCmd = &cobra.Command{
 							),
 							BackoffMultiplier:  clientConfig.BackoffMultiplier,
 							DisableBackoffCaps: clientConfig.DisableBackoffCaps,
+							Jitter:             clientConfig.BackoffJitter,
 							Logger:             loggers[name],
+							Budget:             retryBudgets[name],
+							Limiter:            reconnectLimiters[name],
 						},
 					),
-				)
+				), clientConfig
+			}
 
-				if client != nil {
-					eventOptions := trace.WithAttributes(
-						attribute.String("name", name),
-						attribute.String("network", client.Network),
-						attribute.String("address", client.Address),
-						attribute.Int("receiveChunkSize", client.ReceiveChunkSize),
-						attribute.String("receiveDeadline", client.ReceiveDeadline.String()),
-						attribute.String("receiveTimeout", client.ReceiveTimeout.String()),
-						attribute.String("sendDeadline", client.SendDeadline.String()),
-						attribute.String("dialTimeout", client.DialTimeout.String()),
-						attribute.Bool("tcpKeepAlive", client.TCPKeepAlive),
-						attribute.String("tcpKeepAlivePeriod", client.TCPKeepAlivePeriod.String()),
-						attribute.String("localAddress", client.LocalAddr()),
-						attribute.String("remoteAddress", client.RemoteAddr()),
-						attribute.Int("retries", clientConfig.Retries),
-						attribute.String("backoff", client.Retry().Backoff.String()),
-						attribute.Float64("backoffMultiplier", clientConfig.BackoffMultiplier),
-						attribute.Bool("disableBackoffCaps", clientConfig.DisableBackoffCaps),
+			// addClient runs the OnNewClient hooks and adds the client to the pool.
+			// The span is kept, since the pool may still be warming up after it ends.
+			poolSpan := span
+			addClient := func(client *network.Client, clientConfig *config.Client) {
+				eventOptions := trace.WithAttributes(
+					attribute.String("name", name),
+					attribute.String("network", client.Network),
+					attribute.String("address", client.Address),
+					attribute.Int("receiveChunkSize", client.ReceiveChunkSize),
+					attribute.Int("maxReceiveChunkSize", clientConfig.MaxReceiveChunkSize),
+					attribute.String("receiveChunkShrinkPeriod", clientConfig.ReceiveChunkShrinkPeriod.String()),
+					attribute.String("maxMessageAssemblyTime", clientConfig.MaxMessageAssemblyTime.String()),
+					attribute.String("receiveDeadline", client.ReceiveDeadline.String()),
+					attribute.String("receiveTimeout", client.ReceiveTimeout.String()),
+					attribute.String("receiveTimeoutPolicy", clientConfig.ReceiveTimeoutPolicy),
+					attribute.String("sendDeadline", client.SendDeadline.String()),
+					attribute.String("dialTimeout", client.DialTimeout.String()),
+					attribute.Bool("tcpKeepAlive", client.TCPKeepAlive),
+					attribute.Int("dscp", client.DSCP),
+					attribute.String("tcpUserTimeout", client.TCPUserTimeout.String()),
+					attribute.String("tcpKeepAlivePeriod", client.TCPKeepAlivePeriod.String()),
+					attribute.String("localAddress", client.LocalAddr()),
+					attribute.String("remoteAddress", client.RemoteAddr()),
+					attribute.Int("retries", clientConfig.Retries),
+					attribute.String("backoff", client.Retry().Backoff.String()),
+					attribute.Float64("backoffMultiplier", clientConfig.BackoffMultiplier),
+					attribute.Bool("disableBackoffCaps", clientConfig.DisableBackoffCaps),
+					attribute.Float64("backoffJitter", clientConfig.BackoffJitter),
+					attribute.Int("maxReconnects", clientConfig.MaxReconnects),
+					attribute.Float64("retryBudgetRate", clientConfig.RetryBudgetRate),
+					attribute.Int("retryBudgetBurst", clientConfig.RetryBudgetBurst),
+				)
+				if client.ID != "" {
+					eventOptions = trace.WithAttributes(
+						attribute.String("id", client.ID),
 					)
-					if client.ID != "" {
-						eventOptions = trace.WithAttributes(
-							attribute.String("id", client.ID),
-						)
-					}
+				}
 
-					span.AddEvent("Create client", eventOptions)
-
-					pluginTimeoutCtx, cancel = context.WithTimeout(
-						context.Background(), conf.Plugin.Timeout)
-					defer cancel()
-
-					clientCfg := map[string]interface{}{
-						"id":                 client.ID,
-						"network":            client.Network,
-						"address":            client.Address,
-						"receiveChunkSize":   client.ReceiveChunkSize,
-						"receiveDeadline":    client.ReceiveDeadline.String(),
-						"receiveTimeout":     client.ReceiveTimeout.String(),
-						"sendDeadline":       client.SendDeadline.String(),
-						"dialTimeout":        client.DialTimeout.String(),
-						"tcpKeepAlive":       client.TCPKeepAlive,
-						"tcpKeepAlivePeriod": client.TCPKeepAlivePeriod.String(),
-						"localAddress":       client.LocalAddr(),
-						"remoteAddress":      client.RemoteAddr(),
-						"retries":            clientConfig.Retries,
-						"backoff":            client.Retry().Backoff.String(),
-						"backoffMultiplier":  clientConfig.BackoffMultiplier,
-						"disableBackoffCaps": clientConfig.DisableBackoffCaps,
-					}
-					_, err := pluginRegistry.Run(
-						pluginTimeoutCtx, clientCfg, v1.HookName_HOOK_NAME_ON_NEW_CLIENT)
-					if err != nil {
-						logger.Error().Err(err).Msg("Failed to run OnNewClient hooks")
-						span.RecordError(err)
-					}
+				poolSpan.AddEvent("Create client", eventOptions)
+
+				pluginTimeoutCtx, cancel := context.WithTimeout(
+					context.Background(), conf.Plugin.Timeout)
+				defer cancel()
+
+				clientCfg := map[string]interface{}{
+					"id":                 client.ID,
+					"network":            client.Network,
+					"address":            client.Address,
+					"receiveChunkSize":   client.ReceiveChunkSize,
+					"receiveDeadline":    client.ReceiveDeadline.String(),
+					"receiveTimeout":     client.ReceiveTimeout.String(),
+					"sendDeadline":       client.SendDeadline.String(),
+					"dialTimeout":        client.DialTimeout.String(),
+					"tcpKeepAlive":       client.TCPKeepAlive,
+					"tcpKeepAlivePeriod": client.TCPKeepAlivePeriod.String(),
+					"localAddress":       client.LocalAddr(),
+					"remoteAddress":      client.RemoteAddr(),
+					"retries":            clientConfig.Retries,
+					"backoff":            client.Retry().Backoff.String(),
+					"backoffMultiplier":  clientConfig.BackoffMultiplier,
+					"disableBackoffCaps": clientConfig.DisableBackoffCaps,
+					"backoffJitter":      clientConfig.BackoffJitter,
+					"maxReconnects":      clientConfig.MaxReconnects,
+					"retryBudgetRate":    clientConfig.RetryBudgetRate,
+					"retryBudgetBurst":   clientConfig.RetryBudgetBurst,
+				}
+				_, err := pluginRegistry.Run(
+					pluginTimeoutCtx, clientCfg, v1.HookName_HOOK_NAME_ON_NEW_CLIENT)
+				if err != nil {
+					logger.Error().Err(err).Msg("Failed to run OnNewClient hooks")
+					poolSpan.RecordError(err)
+				}
 
-					err = pools[name].Put(client.ID, client)
-					if err != nil {
-						logger.Error().Err(err).Msg("Failed to add client to the pool")
-						span.RecordError(err)
-					}
+				err = pools[name].Put(client.ID, client)
+				if err != nil {
+					logger.Error().Err(err).Msg("Failed to add client to the pool")
+					poolSpan.RecordError(err)
+				}
+			}
+
+			// Add clients to the pool.
+			// The missing clients are added in the background if the pool warms up.
+			var missing []int
+			for index := range currentPoolSize {
+				if client, clientConfig := newClient(index); client != nil {
+					addClient(client, clientConfig)
+				} else if cfg.WarmupPeriod > 0 {
+					missing = append(missing, index)
 				} else {
 					logger.Error().Msg("Failed to create client, please check the configuration")
 					go func() {
@@ -795,7 +1240,34 @@ var runCmd = &cobra.Command{
 				"count": strconv.Itoa(pools[name].Size()),
 			}).Msg("There are clients available in the pool")
 
-			if pools[name].Size() != currentPoolSize {
+			if len(missing) > 0 {
+				logger.Warn().Fields(map[string]interface{}{
+					"name":         name,
+					"missing":      len(missing),
+					"warmupPeriod": cfg.WarmupPeriod.String(),
+				}).Msg("The pool is not full, warming it up in the background")
+
+				warmingUp.Add(1)
+				go func() {
+					defer warmingUp.Add(-1)
+
+					if !warmUpPool(runCtx, missing, cfg.WarmupPeriod, func(index int) bool {
+						client, clientConfig := newClient(index)
+						if client == nil {
+							return false
+						}
+						addClient(client, clientConfig)
+						return true
+					}) {
+						logger.Error().Str("name", name).Msg(
+							"Failed to warm up the pool within the warmup period. exiting...")
+						pluginRegistry.Shutdown()
+						os.Exit(gerr.FailedToInitializePool)
+					}
+
+					logger.Info().Str("name", name).Msg("The pool is warmed up")
+				}()
+			} else if pools[name].Size() != currentPoolSize {
 				logger.Error().Msg(
 					"The pool size is incorrect, either because " +
 						"the clients cannot connect due to no network connectivity " +
@@ -832,21 +1304,88 @@ var runCmd = &cobra.Command{
 				config.DefaultHealthCheckPeriod,
 			)
 
+			// The clients of the servers of the upstream are authenticated by the proxy.
+			var authenticator network.IAuthenticator
+			if serverConfig := conf.Global.UpstreamServer(name); serverConfig != nil {
+				authenticator = network.NewAuthenticator(
+					serverConfig.AuthMethod,
+					serverConfig.AuthTokens,
+					pluginRegistry,
+					conf.Plugin.Timeout,
+				)
+			}
+
+			// Verify the requests modified by the plugins after all of them ran.
+			var requestVerifier network.RequestVerifier
+			if cfg.VerifyModifiedRequests {
+				requestVerifier = network.VerifyMessageTypes
+			}
+
+			// The pool grows lazily from its size up to its max size, if any.
+			poolConfig := config.If(
+				conf.Global.Pools[name] != nil, conf.Global.Pools[name], &config.Pool{})
+
 			proxies[name] = network.NewProxy(
 				runCtx,
 				network.Proxy{
 					AvailableConnections: pools[name],
+					MinPoolSize:          poolSize(poolConfig),
+					MaxPoolSize:          poolConfig.MaxSize,
+					PoolIdleTimeout:      poolConfig.IdleTimeout,
+					MaxConcurrentDials:   poolConfig.MaxConcurrentDials,
+					DialWaitTimeout:      poolConfig.DialWaitTimeout,
 					PluginRegistry:       pluginRegistry,
 					HealthCheckPeriod:    cfg.HealthCheckPeriod,
+					HealthCheckJitter:    cfg.HealthCheckJitter,
+					CloseOnEmptyRequest:  cfg.CloseOnEmptyRequest,
+					ServerVersion:        cfg.ServerVersion,
+					CaptureClients:       cfg.CaptureClients,
+					CaptureDir:           cfg.CaptureDir,
+					CaptureMaxSize:       cfg.CaptureMaxSize,
+					LameDuckPeriod:       cfg.LameDuckPeriod,
+					RetryOnReset:         cfg.RetryOnReset,
+					PoolEvents:           cfg.PoolEvents,
+					LoadBalanceHook:      cfg.LoadBalanceHook,
+					TestOnBorrow:         cfg.TestOnBorrow,
+					TestOnReturn:         cfg.TestOnReturn,
+					AcquireTimeout:       cfg.AcquireTimeout,
+					InFlightTimeout:      cfg.InFlightTimeout,
+					ClientPriorities:     cfg.ClientPriorities,
+					CertificateRoutes:    cfg.CertificateRoutes,
+					SessionHandoff:       cfg.SessionHandoff,
+					HandoffSetupRequests: cfg.HandoffSetupRequests,
 					ClientConfig:         clientConfig,
+					RetryBudget:          retryBudgets[name],
+					ReconnectLimiter:     reconnectLimiters[name],
+					Authenticator:        authenticator,
 					Logger:               logger,
 					PluginTimeout:        conf.Plugin.Timeout,
+					MaxPayloadSize:       conf.Plugin.MaxPayloadSize,
+					MaxHookPayloadSize:   conf.Plugin.MaxHookPayloadSize,
+					RequestVerifier:      requestVerifier,
 				},
 			)
 
 			span.AddEvent("Create proxy", trace.WithAttributes(
 				attribute.String("name", name),
 				attribute.String("healthCheckPeriod", cfg.HealthCheckPeriod.String()),
+				attribute.Float64("healthCheckJitter", cfg.HealthCheckJitter),
+				attribute.Bool("closeOnEmptyRequest", cfg.CloseOnEmptyRequest),
+				attribute.String("serverVersion", cfg.ServerVersion),
+				attribute.StringSlice("captureClients", cfg.CaptureClients),
+				attribute.Int64("captureMaxSize", cfg.CaptureMaxSize),
+				attribute.String("lameDuckPeriod", cfg.LameDuckPeriod.String()),
+				attribute.Bool("verifyModifiedRequests", cfg.VerifyModifiedRequests),
+				attribute.Bool("retryOnReset", cfg.RetryOnReset),
+				attribute.Bool("poolEvents", cfg.PoolEvents),
+				attribute.Bool("loadBalanceHook", cfg.LoadBalanceHook),
+				attribute.Bool("testOnBorrow", cfg.TestOnBorrow),
+				attribute.Bool("testOnReturn", cfg.TestOnReturn),
+				attribute.String("acquireTimeout", cfg.AcquireTimeout.String()),
+				attribute.String("inFlightTimeout", cfg.InFlightTimeout.String()),
+				attribute.Bool("sessionHandoff", cfg.SessionHandoff),
+				attribute.Int("handoffSetupRequests", cfg.HandoffSetupRequests),
+				attribute.Int("certificateRoutes", len(cfg.CertificateRoutes)),
 			))
 
 			pluginTimeoutCtx, cancel = context.WithTimeout(
@@ -868,9 +1407,18 @@ var runCmd = &cobra.Command{
 		span.End()
 
 		_, span = otel.Tracer(config.TracerName).Start(runCtx, "Create servers")
+		// The client connections of all the servers share a budget, so that a flood of
+		// them can't starve the admin API and the health checks of file descriptors.
+		connectionBudget := newConnectionBudget(
+			conf.Global.API.ReservedConnections, conf.Global.Pools, logger)
+
 		// Create and initialize servers.
 		for name, cfg := range conf.Global.Servers {
 			logger := loggers[name]
+			keepAlive, keepAliveErr := network.KeepAlivePayload(cfg.KeepAlive, cfg.KeepAlivePayload)
+			if keepAliveErr != nil {
+				logger.Error().Err(keepAliveErr).Msg("Failed to create the keepalive payload, keepalives are disabled")
+			}
 			servers[name] = network.NewServer(
 				runCtx,
 				network.Server{
@@ -882,17 +1430,31 @@ var runCmd = &cobra.Command{
 						config.DefaultTickInterval,
 					),
 					Options: network.Option{
-						// Can be used to send keepalive messages to the client.
 						EnableTicker: cfg.EnableTicker,
 					},
-					Proxy:            proxies[name],
-					Logger:           logger,
-					PluginRegistry:   pluginRegistry,
-					PluginTimeout:    conf.Plugin.Timeout,
-					EnableTLS:        cfg.EnableTLS,
-					CertFile:         cfg.CertFile,
-					KeyFile:          cfg.KeyFile,
-					HandshakeTimeout: cfg.HandshakeTimeout,
+					KeepAlive:                    keepAlive,
+					Proxy:                        proxies[cfg.GetUpstream(name)],
+					Logger:                       logger,
+					PluginRegistry:               pluginRegistry,
+					PluginTimeout:                conf.Plugin.Timeout,
+					EnableTLS:                    cfg.EnableTLS,
+					CertFile:                     cfg.CertFile,
+					KeyFile:                      cfg.KeyFile,
+					HandshakeTimeout:             cfg.HandshakeTimeout,
+					EnableHTTPTunnel:             cfg.EnableHTTPTunnel,
+					ClientCAFile:                 cfg.ClientCAFile,
+					ShutdownGracePeriod:          cfg.ShutdownGracePeriod,
+					IdleTimeout:                  cfg.IdleTimeout,
+					MaxConnections:               cfg.MaxConnections,
+					MaxConnectionsPerIP:          cfg.MaxConnectionsPerIP,
+					MaxConnectionsPerIPAllowList: cfg.MaxConnectionsPerIPAllowList,
+					TCPFastOpen:                  cfg.TCPFastOpen,
+					TCPFastOpenQueueLength:       cfg.TCPFastOpenQueueLength,
+					DSCP:                         cfg.DSCP,
+					TCPUserTimeout:               cfg.TCPUserTimeout,
+					EnableCompression:            cfg.EnableCompression,
+					CompressionLevel:             cfg.CompressionLevel,
+					ConnectionBudget:             connectionBudget,
 				},
 			)
 
@@ -906,6 +1468,22 @@ var runCmd = &cobra.Command{
 				attribute.String("certFile", cfg.CertFile),
 				attribute.String("keyFile", cfg.KeyFile),
 				attribute.String("handshakeTimeout", cfg.HandshakeTimeout.String()),
+				attribute.Bool("enableHTTPTunnel", cfg.EnableHTTPTunnel),
+				attribute.String("clientCAFile", cfg.ClientCAFile),
+				attribute.String("authMethod", cfg.AuthMethod),
+				attribute.String("shutdownGracePeriod", cfg.ShutdownGracePeriod.String()),
+				attribute.String("idleTimeout", cfg.IdleTimeout.String()),
+				attribute.Int("maxConnections", cfg.MaxConnections),
+				attribute.Int("maxConnectionsPerIP", cfg.MaxConnectionsPerIP),
+				attribute.StringSlice("maxConnectionsPerIPAllowList", cfg.MaxConnectionsPerIPAllowList),
+				attribute.Bool("tcpFastOpen", cfg.TCPFastOpen),
+				attribute.Int("tcpFastOpenQueueLength", cfg.TCPFastOpenQueueLength),
+				attribute.Int("dscp", cfg.DSCP),
+				attribute.String("tcpUserTimeout", cfg.TCPUserTimeout.String()),
+				attribute.Bool("enableCompression", cfg.EnableCompression),
+				attribute.String("compressionLevel", cfg.CompressionLevel),
+				attribute.String("keepAlive", cfg.KeepAlive),
+				attribute.String("upstream", cfg.GetUpstream(name)),
 			))
 
 			pluginTimeoutCtx, cancel = context.WithTimeout(
@@ -934,6 +1512,9 @@ var runCmd = &cobra.Command{
 				GRPCAddress: conf.Global.API.GRPCAddress,
 				HTTPAddress: conf.Global.API.HTTPAddress,
 				Servers:     servers,
+				WarmingUp:   warmingUp,
+
+				PluginRegistry: pluginRegistry,
 			}
 
 			apiObj := &api.API{
@@ -1017,7 +1598,8 @@ var runCmd = &cobra.Command{
 			}()
 		}
 
-		// Shutdown the server gracefully.
+		// Reload the config on the reload signals and shutdown the server gracefully
+		// on the shutdown signals.
 		var signals []os.Signal
 		signals = append(signals,
 			os.Interrupt,
@@ -1025,9 +1607,10 @@ var runCmd = &cobra.Command{
 			syscall.SIGTERM,
 			syscall.SIGABRT,
 			syscall.SIGQUIT,
-			syscall.SIGHUP,
 			syscall.SIGINT,
 		)
+		signals = append(signals, reloadSignals...)
+		signals = append(signals, pauseSignals...)
 		signalsCh := make(chan os.Signal, 1)
 		signal.Notify(signalsCh, signals...)
 		go func(pluginRegistry *plugin.Registry,
@@ -1039,25 +1622,28 @@ var runCmd = &cobra.Command{
 			httpServer *api.HTTPServer,
 			grpcServer *api.GRPCServer,
 		) {
-			for sig := range signalsCh {
-				for _, s := range signals {
-					if sig != s {
-						StopGracefully(
-							runCtx,
-							sig,
-							metricsMerger,
-							metricsServer,
-							pluginRegistry,
-							logger,
-							servers,
-							stopChan,
-							httpServer,
-							grpcServer,
-						)
-						os.Exit(0)
-					}
+			control := func(sig os.Signal) {
+				if isPauseSignal(sig) {
+					pauseServers(runCtx, sig, pluginRegistry, servers, logger)
+					return
 				}
+				reloadConfig(runCtx, sig, pluginRegistry, metricsMerger, logger)
 			}
+			handleSignals(signalsCh, control, func(sig os.Signal) {
+				StopGracefully(
+					runCtx,
+					sig,
+					metricsMerger,
+					metricsServer,
+					pluginRegistry,
+					logger,
+					servers,
+					stopChan,
+					httpServer,
+					grpcServer,
+				)
+				os.Exit(0)
+			})
 		}(pluginRegistry, logger, servers, metricsMerger, metricsServer, stopChan, httpServer, grpcServer)
 
 		_, span = otel.Tracer(config.TracerName).Start(runCtx, "Start servers")
@@ -1083,6 +1669,9 @@ var runCmd = &cobra.Command{
 					}
 					server.Shutdown()
 					pluginRegistry.Shutdown()
+					if err.Code() == gerr.ErrCodeServerListenFailed {
+						os.Exit(gerr.FailedToListen)
+					}
 					os.Exit(gerr.FailedToStartServer)
 				}
 			}(span, server, logger, healthCheckScheduler, metricsMerger, pluginRegistry)
@@ -1118,4 +1707,7 @@ func init() {
 		&enableUsageReport, "usage-report", true, "Enable usage report")
 	runCmd.Flags().BoolVar(
 		&enableLinting, "lint", true, "Enable linting of configuration files")
+	runCmd.Flags().BoolVar(
+		&checkConfig, "check-config", false,
+		"Print the effective config, with the sensitive values redacted, and exit")
 }
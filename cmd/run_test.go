@@ -1,13 +1,16 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -272,3 +275,79 @@ func Test_runCmdWithCachePlugin(t *testing.T) {
 	require.NoError(t, os.Remove(pluginTestConfigFile))
 	require.NoError(t, os.Remove(pluginTestConfigFile+BackupFileExt))
 }
+
+// Test_handleSignals tests that the config is reloaded on every reload signal and
+// the shutdown sequence runs once, no matter how many signals are received.
+func Test_handleSignals(t *testing.T) {
+	signalsCh := make(chan os.Signal, 4)
+	signalsCh <- reloadConfigSignal
+	signalsCh <- syscall.SIGTERM
+	signalsCh <- syscall.SIGINT
+	signalsCh <- reloadConfigSignal
+
+	var reloaded, received []os.Signal
+	reload := func(sig os.Signal) {
+		reloaded = append(reloaded, sig)
+	}
+	shutdown := func(sig os.Signal) {
+		received = append(received, sig)
+	}
+	handleSignals(signalsCh, reload, shutdown)
+	assert.Equal(t, []os.Signal{reloadConfigSignal}, reloaded)
+	assert.Equal(t, []os.Signal{syscall.SIGTERM}, received)
+
+	// A closed channel doesn't run the shutdown sequence.
+	closedCh := make(chan os.Signal)
+	close(closedCh)
+	handleSignals(closedCh, reload, shutdown)
+	assert.Len(t, received, 1)
+}
+
+// Test_isReloadSignal tests that SIGHUP reloads the config instead of shutting down.
+func Test_isReloadSignal(t *testing.T) {
+	assert.True(t, isReloadSignal(syscall.SIGHUP))
+	assert.False(t, isReloadSignal(syscall.SIGTERM))
+	assert.False(t, isReloadSignal(os.Interrupt))
+	assert.False(t, isReloadSignal(nil))
+}
+
+// Test_isPauseSignal tests that the shutdown signals don't pause the servers.
+func Test_isPauseSignal(t *testing.T) {
+	assert.False(t, isPauseSignal(syscall.SIGTERM))
+	assert.False(t, isPauseSignal(reloadConfigSignal))
+	assert.False(t, isPauseSignal(nil))
+}
+
+// Test_warmUpPool tests retrying to add the missing clients of a pool.
+func Test_warmUpPool(t *testing.T) {
+	attempts := map[int]int{}
+	assert.True(t, warmUpPool(context.Background(), []int{1, 2}, 5*time.Second, func(index int) bool {
+		attempts[index]++
+		// The second client is only added on the second attempt.
+		return index == 1 || attempts[index] > 1
+	}))
+	assert.Equal(t, map[int]int{1: 1, 2: 2}, attempts)
+
+	assert.False(t, warmUpPool(context.Background(), []int{1}, 2*config.DefaultWarmupInterval,
+		func(int) bool { return false }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, warmUpPool(ctx, []int{1}, time.Minute, func(int) bool { return true }))
+}
+
+func Test_printEffectiveConfig(t *testing.T) {
+	conf := config.NewConfig(context.Background(), config.Config{})
+	require.Nil(t, conf.LoadDefaults(context.Background()))
+	conf.GlobalKoanf.Set("servers.default.authTokens", map[string]interface{}{"app": "secret"}) //nolint:errcheck
+
+	output := bytes.NewBuffer(nil)
+	cmd := &cobra.Command{}
+	cmd.SetOut(output)
+	require.NoError(t, printEffectiveConfig(cmd, conf))
+
+	assert.Contains(t, output.String(), "servers:")
+	assert.Contains(t, output.String(), "\n---\n")
+	assert.Contains(t, output.String(), config.RedactedValue)
+	assert.NotContains(t, output.String(), "secret")
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	// reloadConfigSignal reloads the log levels, the plugins and the server addresses.
+	reloadConfigSignal os.Signal = syscall.SIGHUP
+	// reloadLevelSignal only reloads the log levels.
+	reloadLevelSignal os.Signal = syscall.SIGUSR1
+	// reloadPluginsSignal only reloads the plugins.
+	reloadPluginsSignal os.Signal = syscall.SIGUSR2
+
+	reloadSignals = []os.Signal{reloadConfigSignal, reloadLevelSignal, reloadPluginsSignal}
+
+	// pauseSignal stops accepting new connections, e.g. Ctrl+Z in a terminal.
+	pauseSignal os.Signal = syscall.SIGTSTP
+	// resumeSignal accepts new connections again.
+	resumeSignal os.Signal = syscall.SIGCONT
+
+	pauseSignals = []os.Signal{pauseSignal, resumeSignal}
+)
@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	// reloadConfigSignal reloads the log levels, the plugins and the server addresses.
+	reloadConfigSignal os.Signal = syscall.SIGHUP
+	// SIGUSR1 and SIGUSR2 are not available on Windows.
+	reloadLevelSignal   os.Signal
+	reloadPluginsSignal os.Signal
+
+	reloadSignals = []os.Signal{reloadConfigSignal}
+
+	// SIGTSTP and SIGCONT are not available on Windows.
+	pauseSignal  os.Signal
+	resumeSignal os.Signal
+
+	pauseSignals = []os.Signal{}
+)
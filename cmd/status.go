@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/maps"
+)
+
+var (
+	apiAddress    string
+	statusJSON    bool
+	statusTimeout time.Duration
+)
+
+// statusEndpoints are the endpoints of the admin API queried for the status,
+// by the field of the status they're stored in.
+var statusEndpoints = map[string]string{
+	"health":  "/healthz",
+	"servers": "/v1/GatewayDPluginService/GetServers",
+	"pools":   "/v1/GatewayDPluginService/GetPools",
+	"proxies": "/v1/GatewayDPluginService/GetProxies",
+	"plugins": "/v1/GatewayDPluginService/GetPlugins",
+}
+
+// statusCmd represents the status command.
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of a running GatewayD instance",
+	Long: "Show the status of a running GatewayD instance, queried from its admin API: " +
+		"the uptime, the connections, the pools, the backends and the plugins.",
+	Run: func(cmd *cobra.Command, _ []string) {
+		// Use the admin API address in the config file, unless it's given.
+		address := apiAddress
+		if !cmd.Flags().Changed("api-address") && cmd.Flags().Changed("config") {
+			configAddress, err := apiAddressFromConfig(globalConfigFile)
+			if err != nil {
+				cmd.PrintErrln("Failed to read the admin API address from the config file: ", err)
+				return
+			}
+			address = configAddress
+		}
+
+		status, err := getStatus(address, statusTimeout)
+		if err != nil {
+			cmd.PrintErrln("Failed to get the status: ", err)
+			return
+		}
+
+		if statusJSON {
+			output, err := json.MarshalIndent(status, "", "  ")
+			if err != nil {
+				cmd.PrintErrln("Failed to marshal the status: ", err)
+				return
+			}
+			cmd.Println(string(output))
+			return
+		}
+
+		printStatus(cmd, status)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().StringVarP(
+		&apiAddress, "api-address", "a", config.DefaultHTTPAPIAddress,
+		"Address of the HTTP admin API of the running instance")
+	statusCmd.Flags().StringVarP(
+		&globalConfigFile, // Already exists in run.go
+		"config", "c", config.GetDefaultConfigFilePath(config.GlobalConfigFilename),
+		"Global config file, for reading the admin API address")
+	statusCmd.Flags().BoolVar(
+		&statusJSON, "json", false, "Print the status as JSON")
+	statusCmd.Flags().DurationVar(
+		&statusTimeout, "timeout", config.DefaultStatusTimeout, "Timeout of the admin API requests")
+}
+
+// apiAddressFromConfig returns the address of the HTTP admin API in the global config file.
+func apiAddressFromConfig(configFile string) (string, error) {
+	conf := config.NewConfig(context.TODO(), config.Config{GlobalConfigFile: configFile})
+	if err := conf.LoadDefaults(context.TODO()); err != nil {
+		return "", err
+	}
+	if err := conf.LoadGlobalConfigFile(context.TODO()); err != nil {
+		return "", err
+	}
+	if err := conf.UnmarshalGlobalConfig(context.TODO()); err != nil {
+		return "", err
+	}
+	return conf.Global.API.HTTPAddress, nil
+}
+
+// getStatus queries the admin API at the address for the status of the instance.
+func getStatus(address string, timeout time.Duration) (map[string]interface{}, error) {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	address = strings.TrimSuffix(address, "/")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	version, err := getAPI(ctx, address+"/version")
+	if err != nil {
+		return nil, err
+	}
+	status := map[string]interface{}{
+		"address": address,
+		"version": string(version),
+	}
+
+	for field, endpoint := range statusEndpoints {
+		body, err := getAPI(ctx, address+endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse the response of %s: %w", endpoint, err)
+		}
+		status[field] = value
+	}
+
+	return status, nil
+}
+
+// getAPI returns the body of the response of the admin API endpoint. The health
+// check responds with 503 if the servers aren't running, which is a valid status.
+func getAPI(ctx context.Context, url string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the request: %w", err)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the admin API: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response of %s: %w", url, err)
+	}
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusServiceUnavailable {
+		return nil, fmt.Errorf("%s responded with %s", url, response.Status) //nolint:goerr113
+	}
+
+	return body, nil
+}
+
+// printStatus prints the status in a human-readable form.
+func printStatus(cmd *cobra.Command, status map[string]interface{}) {
+	health, _ := status["health"].(map[string]interface{})
+	cmd.Printf("GatewayD %v at %v is %v\n", status["version"], status["address"], health["status"])
+
+	servers, _ := status["servers"].(map[string]interface{})
+	cmd.Println("Servers:")
+	for _, name := range sortedKeys(servers) {
+		server, _ := servers[name].(map[string]interface{})
+		state := "stopped"
+		if toInt(server["status"]) == int(config.Running) {
+			state = "running"
+		}
+		if paused, _ := server["paused"].(bool); paused {
+			state = "paused"
+		}
+		cmd.Printf("  %s: %v://%v, %s, uptime %s, %d connection(s)\n",
+			name, server["network"], server["address"], state,
+			time.Duration(toInt(server["uptime"])).Round(time.Second), toInt(server["connections"]))
+	}
+
+	pools, _ := status["pools"].(map[string]interface{})
+	proxies, _ := status["proxies"].(map[string]interface{})
+	cmd.Println("Pools:")
+	for _, name := range sortedKeys(pools) {
+		pool, _ := pools[name].(map[string]interface{})
+		proxy, _ := proxies[name].(map[string]interface{})
+		busy, _ := proxy["busy"].([]interface{})
+		available, _ := proxy["available"].([]interface{})
+		total := len(busy) + len(available)
+		utilization := 0.0
+		if total > 0 {
+			utilization = float64(len(busy)) / float64(total) * 100 //nolint:gomnd
+		}
+		cmd.Printf("  %s: %d busy, %d available, capacity %d, %.0f%% utilized\n",
+			name, len(busy), len(available), toInt(pool["cap"]), utilization)
+
+		if readOnly, _ := proxy["readOnly"].(bool); readOnly {
+			cmd.Println("    Read-only mode")
+		}
+		backends, _ := proxy["backends"].(map[string]interface{})
+		for _, backend := range sortedKeys(backends) {
+			cmd.Printf("    Backend %s: %v\n", backend, backends[backend])
+		}
+	}
+
+	plugins, _ := status["plugins"].(map[string]interface{})
+	configs, _ := plugins["configs"].([]interface{})
+	if len(configs) == 0 {
+		cmd.Println("No plugins loaded")
+		return
+	}
+	cmd.Println("Plugins:")
+	for _, plugin := range configs {
+		pluginConfig, _ := plugin.(map[string]interface{})
+		id, _ := pluginConfig["id"].(map[string]interface{})
+		cmd.Printf("  %v %v\n", id["name"], id["version"])
+	}
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys(values map[string]interface{}) []string {
+	keys := maps.Keys(values)
+	slices.Sort(keys)
+	return keys
+}
+
+// toInt converts the JSON number to an int, or returns zero if it isn't one.
+func toInt(value interface{}) int {
+	number, _ := value.(float64)
+	return int(number)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeAdminAPI returns a fake admin API of a running instance.
+func newFakeAdminAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	responses := map[string]string{
+		"/version": "v0.0.0",
+		"/healthz": `{"status":"SERVING"}`,
+		"/v1/GatewayDPluginService/GetServers": `{"default":{"network":"tcp","address":"0.0.0.0:15432",` +
+			`"status":0,"uptime":3723000000000,"connections":2,"paused":false}}`,
+		"/v1/GatewayDPluginService/GetPools": `{"default":{"cap":10,"size":3}}`,
+		"/v1/GatewayDPluginService/GetProxies": `{"default":{"available":["a","b","c"],"busy":["d"],` +
+			`"total":4,"readOnly":true,"backends":{"tcp://localhost:5432":"healthy","tcp://localhost:5433":"down"}}}`,
+		"/v1/GatewayDPluginService/GetPlugins": `{"configs":[{"id":{"name":"gatewayd-plugin-cache","version":"0.2.10"}}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		response, ok := responses[request.URL.Path]
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = writer.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func Test_statusCmd(t *testing.T) {
+	server := newFakeAdminAPI(t)
+
+	output, err := executeCommandC(rootCmd, "status", "--api-address", server.URL)
+	require.NoError(t, err, "status command should not have returned an error")
+	assert.Equal(t,
+		"GatewayD v0.0.0 at "+server.URL+" is SERVING\n"+
+			"Servers:\n"+
+			"  default: tcp://0.0.0.0:15432, running, uptime 1h2m3s, 2 connection(s)\n"+
+			"Pools:\n"+
+			"  default: 1 busy, 3 available, capacity 10, 25% utilized\n"+
+			"    Read-only mode\n"+
+			"    Backend tcp://localhost:5432: healthy\n"+
+			"    Backend tcp://localhost:5433: down\n"+
+			"Plugins:\n"+
+			"  gatewayd-plugin-cache 0.2.10\n",
+		output,
+		"status command should have printed the status")
+
+	output, err = executeCommandC(rootCmd, "status", "--api-address", server.URL, "--json")
+	require.NoError(t, err, "status command should not have returned an error")
+	var status map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(output), &status))
+	assert.Equal(t, "v0.0.0", status["version"])
+	assert.Contains(t, status, "servers")
+	assert.Contains(t, status, "plugins")
+	statusJSON = false
+
+	// The admin API must be reachable.
+	server.Close()
+	output, err = executeCommandC(rootCmd, "status", "--api-address", server.URL)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Failed to get the status")
+}
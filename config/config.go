@@ -2,21 +2,27 @@ package config
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	goerrors "errors"
 	"fmt"
 	"log"
+	"maps"
+	"net"
 	"os"
+	"path"
 	"reflect"
 	"sort"
 	"strings"
 
 	gerr "github.com/gatewayd-io/gatewayd/errors"
 	"github.com/knadh/koanf"
-	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/confmap"
 	"github.com/knadh/koanf/providers/env"
-	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/providers/structs"
+	jsonSchemaV5 "github.com/santhosh-tekuri/jsonschema/v5"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/exp/slices"
@@ -118,6 +124,7 @@ func (c *Config) LoadDefaults(ctx context.Context) *gerr.GatewayDError {
 		RSyslogNetwork:    DefaultRSyslogNetwork,
 		RSyslogAddress:    DefaultRSyslogAddress,
 		SyslogPriority:    DefaultSyslogPriority,
+		ErrorLogWindow:    DefaultErrorLogWindow,
 	}
 
 	defaultMetric := Metrics{
@@ -125,41 +132,89 @@ func (c *Config) LoadDefaults(ctx context.Context) *gerr.GatewayDError {
 		Address:           DefaultMetricsAddress,
 		Path:              DefaultMetricsPath,
 		ReadHeaderTimeout: DefaultReadHeaderTimeout,
+		PushJob:           DefaultMetricsPushJob,
+		PushInterval:      DefaultMetricsPushInterval,
+		GoRuntime:         DefaultMetricsGoRuntime,
 	}
 
 	defaultClient := Client{
-		Network:            DefaultNetwork,
-		Address:            DefaultAddress,
-		TCPKeepAlive:       DefaultTCPKeepAlive,
-		TCPKeepAlivePeriod: DefaultTCPKeepAlivePeriod,
-		ReceiveChunkSize:   DefaultChunkSize,
-		ReceiveDeadline:    DefaultReceiveDeadline,
-		ReceiveTimeout:     DefaultReceiveTimeout,
-		SendDeadline:       DefaultSendDeadline,
-		DialTimeout:        DefaultDialTimeout,
-		Retries:            DefaultRetries,
-		Backoff:            DefaultBackoff,
-		BackoffMultiplier:  DefaultBackoffMultiplier,
-		DisableBackoffCaps: DefaultDisableBackoffCaps,
+		Network:              DefaultNetwork,
+		Address:              DefaultAddress,
+		TCPKeepAlive:         DefaultTCPKeepAlive,
+		TCPFastOpen:          DefaultTCPFastOpen,
+		DSCP:                 DefaultDSCP,
+		TCPUserTimeout:       DefaultTCPUserTimeout,
+		TCPKeepAlivePeriod:   DefaultTCPKeepAlivePeriod,
+		ReceiveChunkSize:     DefaultChunkSize,
+		ReceiveDeadline:      DefaultReceiveDeadline,
+		ReceiveTimeout:       DefaultReceiveTimeout,
+		SendDeadline:         DefaultSendDeadline,
+		DialTimeout:          DefaultDialTimeout,
+		Retries:              DefaultRetries,
+		Backoff:              DefaultBackoff,
+		BackoffMultiplier:    DefaultBackoffMultiplier,
+		DisableBackoffCaps:   DefaultDisableBackoffCaps,
+		BackoffJitter:        DefaultBackoffJitter,
+		MaxReconnects:        DefaultMaxReconnects,
+		RetryBudgetRate:      DefaultRetryBudgetRate,
+		RetryBudgetBurst:     DefaultRetryBudgetBurst,
+		ReceiveStrategy:      DefaultReceiveStrategy,
+		ReceiveQuietPeriod:   DefaultReceiveQuietPeriod,
+		ReceiveTimeoutPolicy: DefaultReceiveTimeoutPolicy,
+		PreAuthenticate:      DefaultPreAuthenticate,
+		ResetQuery:           DefaultResetQuery,
+		IDStrategy:           DefaultIDStrategy,
+
+		MaxReceiveChunkSize:      DefaultMaxChunkSize,
+		ReceiveChunkShrinkPeriod: DefaultChunkShrinkPeriod,
+		MaxMessageAssemblyTime:   DefaultMaxMessageAssemblyTime,
 	}
 
 	defaultPool := Pool{
-		Size: DefaultPoolSize,
+		Size:         DefaultPoolSize,
+		WarmupPeriod: DefaultWarmupPeriod,
+		MaxSize:      DefaultPoolMaxSize,
+		IdleTimeout:  DefaultPoolIdleTimeout,
+
+		MaxConcurrentDials: DefaultMaxConcurrentDials,
+		DialWaitTimeout:    DefaultDialWaitTimeout,
 	}
 
 	defaultProxy := Proxy{
-		HealthCheckPeriod: DefaultHealthCheckPeriod,
+		HealthCheckPeriod:   DefaultHealthCheckPeriod,
+		HealthCheckJitter:   DefaultHealthCheckJitter,
+		CloseOnEmptyRequest: DefaultCloseOnEmptyRequest,
+		CaptureMaxSize:      DefaultCaptureMaxSize,
+		AcquireTimeout:      DefaultAcquireTimeout,
+
+		// The sessions are handed off by replaying their startup message by default.
+		HandoffSetupRequests: DefaultHandoffSetupRequest,
+		InFlightTimeout:      DefaultInFlightTimeout,
 	}
 
 	defaultServer := Server{
-		Network:          DefaultListenNetwork,
-		Address:          DefaultListenAddress,
-		EnableTicker:     false,
-		TickInterval:     DefaultTickInterval,
-		EnableTLS:        false,
-		CertFile:         "",
-		KeyFile:          "",
-		HandshakeTimeout: DefaultHandshakeTimeout,
+		Network:                DefaultListenNetwork,
+		Address:                DefaultListenAddress,
+		EnableTicker:           false,
+		TickInterval:           DefaultTickInterval,
+		EnableTLS:              false,
+		CertFile:               "",
+		KeyFile:                "",
+		HandshakeTimeout:       DefaultHandshakeTimeout,
+		EnableHTTPTunnel:       false,
+		ClientCAFile:           "",
+		AuthMethod:             NoAuth,
+		ShutdownGracePeriod:    DefaultShutdownGracePeriod,
+		IdleTimeout:            DefaultIdleTimeout,
+		MaxConnections:         DefaultMaxConnections,
+		MaxConnectionsPerIP:    DefaultMaxConnectionsPerIP,
+		TCPFastOpen:            DefaultTCPFastOpen,
+		TCPFastOpenQueueLength: DefaultTCPFastOpenQueueLength,
+		DSCP:                   DefaultDSCP,
+		TCPUserTimeout:         DefaultTCPUserTimeout,
+		EnableCompression:      false,
+		CompressionLevel:       DefaultCompression,
+		KeepAlive:              DefaultKeepAlive,
 	}
 
 	c.globalDefaults = GlobalConfig{
@@ -174,12 +229,17 @@ func (c *Config) LoadDefaults(ctx context.Context) *gerr.GatewayDError {
 			HTTPAddress: DefaultHTTPAPIAddress,
 			GRPCNetwork: DefaultGRPCAPINetwork,
 			GRPCAddress: DefaultGRPCAPIAddress,
+
+			ReservedConnections: DefaultReservedConnections,
 		},
+
+		// The upstreams in the config file get the defaults of their groups below.
+		Upstreams: map[string]*Upstream{},
 	}
 
 	//nolint:nestif
 	if contents, err := os.ReadFile(c.GlobalConfigFile); err == nil {
-		gconf, err := yaml.Parser().Unmarshal(contents)
+		gconf, err := Parser(c.GlobalConfigFile).Unmarshal(contents)
 		if err != nil {
 			span.RecordError(err)
 			span.End()
@@ -187,10 +247,16 @@ func (c *Config) LoadDefaults(ctx context.Context) *gerr.GatewayDError {
 				fmt.Errorf("failed to unmarshal global configuration: %w", err))
 		}
 
+		// The global config is in its section of a unified config file.
+		if global, ok := gconf[UnifiedGlobalSection].(map[string]interface{}); ok {
+			gconf = global
+		}
+
 		for configObject, configMap := range gconf {
 			if configGroup, ok := configMap.(map[string]interface{}); ok {
 				for configGroupKey := range configGroup {
-					if configGroupKey == Default {
+					// The default groups already have their defaults, unlike the upstreams.
+					if configGroupKey == Default && configObject != "upstreams" {
 						continue
 					}
 
@@ -207,6 +273,12 @@ func (c *Config) LoadDefaults(ctx context.Context) *gerr.GatewayDError {
 						c.globalDefaults.Proxies[configGroupKey] = &defaultProxy
 					case "servers":
 						c.globalDefaults.Servers[configGroupKey] = &defaultServer
+					case "upstreams":
+						c.globalDefaults.Upstreams[configGroupKey] = &Upstream{
+							Client: &defaultClient,
+							Pool:   &defaultPool,
+							Proxy:  &defaultProxy,
+						}
 					case "api":
 						// TODO: Add support for multiple API config groups.
 					default:
@@ -237,11 +309,25 @@ func (c *Config) LoadDefaults(ctx context.Context) *gerr.GatewayDError {
 		PolicyTimeout:       DefaultPolicyTimeout,
 		ActionTimeout:       DefaultActionTimeout,
 		Policies:            []Policy{},
+		MaxPayloadSize:      DefaultMaxPayloadSize,
+		MaxHookPayloadSize:  DefaultMaxHookPayloadSize,
+		VerificationPolicy:  string(DefaultVerificationPolicy),
+		HookVerification:    map[string]string{},
+		DisabledHooks:       []string{},
+		EnforceSignatures:   DefaultEnforceSignatures,
 		ActionRedis: ActionRedisConfig{
 			Enabled: DefaultActionRedisEnabled,
 			Address: DefaultRedisAddress,
 			Channel: DefaultRedisChannel,
 		},
+		GRPC: PluginGRPC{
+			KeepAliveTimeout: DefaultPluginKeepAliveTimeout,
+		},
+		CircuitBreaker: PluginCircuitBreaker{
+			Failures:      DefaultBreakerFailures,
+			SlowThreshold: DefaultBreakerSlowThreshold,
+			Cooldown:      DefaultBreakerCooldown,
+		},
 	}
 
 	if c.GlobalKoanf != nil {
@@ -307,11 +393,12 @@ func loadEnvVars() *env.Env {
 	})
 }
 
-// LoadGlobalConfigFile loads the plugin configuration file.
+// LoadGlobalConfigFile loads the global configuration file, or the global section
+// of the unified configuration file.
 func (c *Config) LoadGlobalConfigFile(ctx context.Context) *gerr.GatewayDError {
 	_, span := otel.Tracer(TracerName).Start(ctx, "Load global config file")
 
-	if err := c.GlobalKoanf.Load(file.Provider(c.GlobalConfigFile), yaml.Parser()); err != nil {
+	if err := loadConfigFile(c.GlobalKoanf, c.GlobalConfigFile, UnifiedGlobalSection); err != nil {
 		span.RecordError(err)
 		span.End()
 		return gerr.ErrConfigParseError.Wrap(
@@ -323,11 +410,12 @@ func (c *Config) LoadGlobalConfigFile(ctx context.Context) *gerr.GatewayDError {
 	return nil
 }
 
-// LoadPluginConfigFile loads the plugin configuration file.
+// LoadPluginConfigFile loads the plugin configuration file, or the plugins section
+// of the unified configuration file.
 func (c *Config) LoadPluginConfigFile(ctx context.Context) *gerr.GatewayDError {
 	_, span := otel.Tracer(TracerName).Start(ctx, "Load plugin config file")
 
-	if err := c.PluginKoanf.Load(file.Provider(c.PluginConfigFile), yaml.Parser()); err != nil {
+	if err := loadConfigFile(c.PluginKoanf, c.PluginConfigFile, UnifiedPluginsSection); err != nil {
 		span.RecordError(err)
 		span.End()
 		return gerr.ErrConfigParseError.Wrap(
@@ -351,6 +439,7 @@ func (c *Config) UnmarshalGlobalConfig(ctx context.Context) *gerr.GatewayDError
 		return gerr.ErrConfigParseError.Wrap(
 			fmt.Errorf("failed to unmarshal global configuration: %w", err))
 	}
+	c.Global.ResolveUpstreams()
 
 	span.End()
 
@@ -395,12 +484,85 @@ func (c *Config) MergeGlobalConfig(
 		return gerr.ErrConfigParseError.Wrap(
 			fmt.Errorf("failed to unmarshal global configuration: %w", err))
 	}
+	c.Global.ResolveUpstreams()
 
 	span.End()
 
 	return nil
 }
 
+// MergePluginDefaults merges the config defaults contributed by the plugins into
+// the global config under the pluginConfigs.<name> key. The values set in the
+// global config file take precedence over the defaults.
+func (c *Config) MergePluginDefaults(
+	ctx context.Context, defaults map[string]map[string]any,
+) *gerr.GatewayDError {
+	if len(defaults) == 0 {
+		return nil
+	}
+
+	pluginConfigs := map[string]interface{}{}
+	for name, pluginDefaults := range defaults {
+		merged := maps.Clone(pluginDefaults)
+		if merged == nil {
+			merged = map[string]any{}
+		}
+		// Values set by the user override the defaults.
+		maps.Copy(merged, c.GlobalKoanf.Cut("pluginConfigs."+name).Raw())
+		pluginConfigs[name] = merged
+	}
+
+	return c.MergeGlobalConfig(ctx, map[string]interface{}{"pluginConfigs": pluginConfigs})
+}
+
+// ValidatePluginConfigs validates the config sections of the plugins, under the
+// pluginConfigs.<name> key of the global config, against the JSON schemas the plugins
+// contributed, keyed by plugin name. The plugins without a schema aren't validated.
+func (c *Config) ValidatePluginConfigs(
+	ctx context.Context, schemas map[string]map[string]any,
+) *gerr.GatewayDError {
+	_, span := otel.Tracer(TracerName).Start(ctx, "Validate plugin configs")
+	defer span.End()
+
+	var errs []error
+	for name, schema := range schemas {
+		if err := validatePluginConfig(name, schema, c.GlobalKoanf.Cut("pluginConfigs."+name).Raw()); err != nil {
+			span.RecordError(err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return gerr.ErrValidationFailed.Wrap(goerrors.Join(errs...))
+	}
+	return nil
+}
+
+// validatePluginConfig validates the config section of a plugin against its JSON schema.
+func validatePluginConfig(name string, schema, pluginConfig map[string]any) error {
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("\"pluginConfigs.%s\" has an invalid schema: %w", name, err)
+	}
+	compiled, err := jsonSchemaV5.CompileString("pluginConfigs/"+name+".json", string(schemaJSON))
+	if err != nil {
+		return fmt.Errorf("\"pluginConfigs.%s\" has an invalid schema: %w", name, err)
+	}
+
+	// The schema validates the values as decoded from JSON, e.g. the numbers as float64.
+	configJSON, err := json.Marshal(pluginConfig)
+	if err != nil {
+		return fmt.Errorf("\"pluginConfigs.%s\" can't be encoded: %w", name, err)
+	}
+	var decoded any
+	if err := json.Unmarshal(configJSON, &decoded); err != nil {
+		return fmt.Errorf("\"pluginConfigs.%s\" can't be decoded: %w", name, err)
+	}
+	if err := compiled.Validate(decoded); err != nil {
+		return fmt.Errorf("\"pluginConfigs.%s\" is invalid: %w", name, err)
+	}
+	return nil
+}
+
 func (c *Config) ValidateGlobalConfig(ctx context.Context) *gerr.GatewayDError {
 	_, span := otel.Tracer(TracerName).Start(ctx, "Validate global config")
 
@@ -417,6 +579,40 @@ func (c *Config) ValidateGlobalConfig(ctx context.Context) *gerr.GatewayDError {
 	sort.Strings(configObjects)
 	var seenConfigObjects []string
 
+	// The upstreams can't have the names of the groups of the clients, pools and proxies,
+	// except the default ones, which they replace. The upstreams aren't config groups,
+	// since they're referenced by the servers instead of matched by name.
+	upstreams := 0
+	for name, upstream := range globalConfig.Upstreams {
+		if upstream == nil {
+			err := fmt.Errorf("\"upstreams.%s\" is nil or empty", name)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			continue
+		}
+		if name == Default {
+			continue
+		}
+		upstreams++
+
+		_, isClient := globalConfig.Clients[name]
+		_, isPool := globalConfig.Pools[name]
+		_, isProxy := globalConfig.Proxies[name]
+		if isClient || isPool || isProxy {
+			err := fmt.Errorf(
+				"\"upstreams.%s\" has the name of a config group of the clients, pools or proxies", name)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+	}
+	globalConfig.ResolveUpstreams()
+
+	if globalConfig.API.ReservedConnections < 0 {
+		err := goerrors.New("\"api.reservedConnections\" can't be negative")
+		span.RecordError(err)
+		errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+	}
+
 	for configGroup := range globalConfig.Loggers {
 		if globalConfig.Loggers[configGroup] == nil {
 			err := fmt.Errorf("\"logger.%s\" is nil or empty", configGroup)
@@ -446,22 +642,106 @@ func (c *Config) ValidateGlobalConfig(ctx context.Context) *gerr.GatewayDError {
 			err := fmt.Errorf("\"clients.%s\" is nil or empty", configGroup)
 			span.RecordError(err)
 			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			continue
+		}
+
+		if globalConfig.Clients[configGroup].PreAuthenticate && globalConfig.Clients[configGroup].User == "" {
+			err := fmt.Errorf(
+				"\"clients.%s.user\" is required for pre-authenticating the sessions", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if dscp := globalConfig.Clients[configGroup].DSCP; dscp < 0 || dscp > MaxDSCP {
+			err := fmt.Errorf(
+				"\"clients.%s.dscp\" must be between 0 and %d", configGroup, MaxDSCP)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if globalConfig.Clients[configGroup].TCPUserTimeout < 0 {
+			err := fmt.Errorf(
+				"\"clients.%s.tcpUserTimeout\" must not be negative", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if jitter := globalConfig.Clients[configGroup].BackoffJitter; jitter < 0 || jitter > 1 {
+			err := fmt.Errorf(
+				"\"clients.%s.backoffJitter\" must be between 0 and 1", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		switch globalConfig.Clients[configGroup].ReceiveTimeoutPolicy {
+		case "", CloseOnTimeout, ErrorOnTimeout, RetryOnTimeout:
+		default:
+			err := fmt.Errorf(
+				"\"clients.%s.receiveTimeoutPolicy\" must be close, error or retry", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if globalConfig.Clients[configGroup].MaxMessageAssemblyTime < 0 {
+			err := fmt.Errorf(
+				"\"clients.%s.maxMessageAssemblyTime\" can't be negative", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if err := validateBackendTLS(globalConfig.Clients[configGroup].TLS); err != nil {
+			err := fmt.Errorf("\"clients.%s.tls\" is invalid: %w", configGroup, err)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		for index, backend := range globalConfig.Clients[configGroup].Backends {
+			if err := ValidateBackend(backend); err != nil {
+				err := fmt.Errorf("\"clients.%s.backends.%d\" is invalid: %w", configGroup, index, err)
+				span.RecordError(err)
+				errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			}
 		}
 	}
 
-	if len(globalConfig.Clients) > 1 {
+	if len(globalConfig.Clients)-upstreams > 1 {
 		seenConfigObjects = append(seenConfigObjects, "clients")
 	}
 
-	for configGroup := range globalConfig.Pools {
-		if globalConfig.Pools[configGroup] == nil {
+	for configGroup, pool := range globalConfig.Pools {
+		if pool == nil {
 			err := fmt.Errorf("\"pools.%s\" is nil or empty", configGroup)
 			span.RecordError(err)
 			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			continue
+		}
+
+		if pool.MaxSize < 0 || (pool.MaxSize > 0 && pool.MaxSize < pool.Size) {
+			err := fmt.Errorf("\"pools.%s.maxSize\" can't be below the size of the pool", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if pool.IdleTimeout < 0 {
+			err := fmt.Errorf("\"pools.%s.idleTimeout\" can't be negative", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if pool.MaxConcurrentDials < 0 {
+			err := fmt.Errorf("\"pools.%s.maxConcurrentDials\" can't be negative", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if pool.DialWaitTimeout < 0 {
+			err := fmt.Errorf("\"pools.%s.dialWaitTimeout\" can't be negative", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
 		}
 	}
 
-	if len(globalConfig.Pools) > 1 {
+	if len(globalConfig.Pools)-upstreams > 1 {
 		seenConfigObjects = append(seenConfigObjects, "pools")
 	}
 
@@ -470,22 +750,201 @@ func (c *Config) ValidateGlobalConfig(ctx context.Context) *gerr.GatewayDError {
 			err := fmt.Errorf("\"proxies.%s\" is nil or empty", configGroup)
 			span.RecordError(err)
 			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			continue
+		}
+
+		if jitter := globalConfig.Proxies[configGroup].HealthCheckJitter; jitter < 0 || jitter > 1 {
+			err := fmt.Errorf(
+				"\"proxies.%s.healthCheckJitter\" must be between 0 and 1", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if globalConfig.Proxies[configGroup].LameDuckPeriod < 0 {
+			err := fmt.Errorf("\"proxies.%s.lameDuckPeriod\" can't be negative", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		for _, client := range globalConfig.Proxies[configGroup].CaptureClients {
+			if _, _, cidrErr := net.ParseCIDR(client); cidrErr != nil && net.ParseIP(client) == nil {
+				err := fmt.Errorf(
+					"\"proxies.%s.captureClients\" has an invalid IP address or CIDR: %s",
+					configGroup, client)
+				span.RecordError(err)
+				errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			}
+		}
+
+		if globalConfig.Proxies[configGroup].AcquireTimeout < 0 {
+			err := fmt.Errorf("\"proxies.%s.acquireTimeout\" can't be negative", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if globalConfig.Proxies[configGroup].InFlightTimeout < 0 {
+			err := fmt.Errorf("\"proxies.%s.inFlightTimeout\" can't be negative", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if globalConfig.Proxies[configGroup].HandoffSetupRequests < 0 {
+			err := fmt.Errorf("\"proxies.%s.handoffSetupRequests\" can't be negative", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		for index, clientPriority := range globalConfig.Proxies[configGroup].ClientPriorities {
+			if clientPriority.Priority < MinConnectionPriority ||
+				clientPriority.Priority > MaxConnectionPriority {
+				err := fmt.Errorf(
+					"\"proxies.%s.clientPriorities[%d].priority\" must be between %d and %d",
+					configGroup, index, MinConnectionPriority, MaxConnectionPriority)
+				span.RecordError(err)
+				errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			}
+			for _, client := range clientPriority.Clients {
+				if _, _, cidrErr := net.ParseCIDR(client); cidrErr != nil && net.ParseIP(client) == nil {
+					err := fmt.Errorf(
+						"\"proxies.%s.clientPriorities[%d].clients\" has an invalid IP address or CIDR: %s",
+						configGroup, index, client)
+					span.RecordError(err)
+					errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+				}
+			}
+		}
+
+		for index, route := range globalConfig.Proxies[configGroup].CertificateRoutes {
+			if route.CommonName == "" && route.OrganizationalUnit == "" && route.SAN == "" {
+				err := fmt.Errorf(
+					"\"proxies.%s.certificateRoutes[%d]\" must match a commonName, organizationalUnit or san",
+					configGroup, index)
+				span.RecordError(err)
+				errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			}
+			if route.Backend == "" && len(route.Labels) == 0 {
+				err := fmt.Errorf(
+					"\"proxies.%s.certificateRoutes[%d]\" must have a backend or labels", configGroup, index)
+				span.RecordError(err)
+				errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			}
+			if route.Backend != "" && !strings.Contains(route.Backend, "://") {
+				err := fmt.Errorf(
+					"\"proxies.%s.certificateRoutes[%d].backend\" must be in the network://address form: %s",
+					configGroup, index, route.Backend)
+				span.RecordError(err)
+				errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			}
+			for _, pattern := range []string{route.CommonName, route.OrganizationalUnit, route.SAN} {
+				if _, matchErr := path.Match(pattern, ""); matchErr != nil {
+					err := fmt.Errorf(
+						"\"proxies.%s.certificateRoutes[%d]\" has an invalid pattern: %s",
+						configGroup, index, pattern)
+					span.RecordError(err)
+					errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+				}
+			}
 		}
 	}
 
-	if len(globalConfig.Proxies) > 1 {
+	if len(globalConfig.Proxies)-upstreams > 1 {
 		seenConfigObjects = append(seenConfigObjects, "proxies")
 	}
 
+	// The servers that reference an upstream aren't config groups.
+	serverGroups := 0
 	for configGroup := range globalConfig.Servers {
 		if globalConfig.Servers[configGroup] == nil {
 			err := fmt.Errorf("\"servers.%s\" is nil or empty", configGroup)
 			span.RecordError(err)
 			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			continue
+		}
+
+		server := globalConfig.Servers[configGroup]
+		if server.Upstream == "" {
+			serverGroups++
+		} else {
+			_, isPool := globalConfig.Pools[server.Upstream]
+			_, isProxy := globalConfig.Proxies[server.Upstream]
+			if !isPool || !isProxy {
+				err := fmt.Errorf(
+					"\"servers.%s.upstream\" references an undefined upstream: %s",
+					configGroup, server.Upstream)
+				span.RecordError(err)
+				errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			}
+		}
+
+		// The pre-authenticated server sessions are handed out to any client whose startup
+		// message reaches them, so the server must authenticate the clients itself.
+		if client := globalConfig.Clients[server.GetUpstream(configGroup)]; client != nil &&
+			client.PreAuthenticate && (server.AuthMethod == "" || server.AuthMethod == NoAuth) {
+			err := fmt.Errorf(
+				"\"servers.%s.authMethod\" is required, because the sessions of \"clients.%s\" are pre-authenticated",
+				configGroup, server.GetUpstream(configGroup))
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if server.EnableCompression && !slices.Contains(
+			[]string{FastestCompression, DefaultCompression, BetterCompression, BestCompression},
+			server.CompressionLevel) {
+			err := fmt.Errorf(
+				"\"servers.%s.compressionLevel\" must be fastest, default, better or best", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if server.MaxConnectionsPerIP < 0 {
+			err := fmt.Errorf("\"servers.%s.maxConnectionsPerIP\" can't be negative", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		for _, client := range server.MaxConnectionsPerIPAllowList {
+			if _, _, cidrErr := net.ParseCIDR(client); cidrErr != nil && net.ParseIP(client) == nil {
+				err := fmt.Errorf(
+					"\"servers.%s.maxConnectionsPerIPAllowList\" has an invalid IP address or CIDR: %s",
+					configGroup, client)
+				span.RecordError(err)
+				errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			}
+		}
+
+		if server.DSCP < 0 || server.DSCP > MaxDSCP {
+			err := fmt.Errorf(
+				"\"servers.%s.dscp\" must be between 0 and %d", configGroup, MaxDSCP)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		if server.TCPUserTimeout < 0 {
+			err := fmt.Errorf(
+				"\"servers.%s.tcpUserTimeout\" must not be negative", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+		}
+
+		switch server.KeepAlive {
+		case "", NoKeepAlive, PostgresKeepAlive:
+		case RawKeepAlive:
+			if payload, err := hex.DecodeString(server.KeepAlivePayload); err != nil || len(payload) == 0 {
+				err := fmt.Errorf(
+					"\"servers.%s.keepAlivePayload\" must be a hex-encoded payload for the raw keepalives",
+					configGroup)
+				span.RecordError(err)
+				errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
+			}
+		default:
+			err := fmt.Errorf(
+				"\"servers.%s.keepAlive\" must be none, postgres or raw", configGroup)
+			span.RecordError(err)
+			errors = append(errors, gerr.ErrValidationFailed.Wrap(err))
 		}
 	}
 
-	if len(globalConfig.Servers) > 1 {
+	if serverGroups > 1 {
 		seenConfigObjects = append(seenConfigObjects, "servers")
 	}
 
@@ -524,3 +983,54 @@ func (c *Config) ValidateGlobalConfig(ctx context.Context) *gerr.GatewayDError {
 
 	return nil
 }
+
+// validateBackendTLS checks that the certificates of the TLS of a backend can be loaded,
+// so that a wrong file fails on start instead of on every connection to the backend.
+// ValidateBackend validates a backend of a client config, either in the config or
+// added at runtime, e.g. by the plugins.
+func ValidateBackend(backend Backend) error {
+	if backend.Network == "" || backend.Address == "" {
+		return goerrors.New("must have a network and an address")
+	}
+	if backend.Weight < 0 {
+		return goerrors.New("weight can't be negative")
+	}
+	if backend.WarmStandby < 0 {
+		return goerrors.New("warmStandby can't be negative")
+	}
+	if backend.MaxInFlight < 0 {
+		return goerrors.New("maxInFlight can't be negative")
+	}
+	if backend.TLS != nil {
+		if err := validateBackendTLS(*backend.TLS); err != nil {
+			return fmt.Errorf("tls is invalid: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateBackendTLS(backendTLS BackendTLS) error {
+	if !backendTLS.Enabled {
+		return nil
+	}
+
+	if (backendTLS.CertFile == "") != (backendTLS.KeyFile == "") {
+		return goerrors.New("certFile and keyFile must be set together")
+	}
+	if backendTLS.CertFile != "" {
+		if _, err := tls.LoadX509KeyPair(backendTLS.CertFile, backendTLS.KeyFile); err != nil {
+			return fmt.Errorf("failed to load the client certificate: %w", err)
+		}
+	}
+	if backendTLS.CAFile != "" {
+		caCert, err := os.ReadFile(backendTLS.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the CA file: %w", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(caCert) {
+			return goerrors.New("failed to parse the CA certificate")
+		}
+	}
+
+	return nil
+}
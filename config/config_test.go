@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/knadh/koanf"
@@ -85,6 +86,19 @@ func TestInitConfigMultiTenant(t *testing.T) {
 	assert.Empty(t, config.pluginDefaults.Plugins)
 }
 
+// TestGlobalConfigJSON tests that the default global config, which the "config init"
+// command writes as JSON, has no null values, because the config schema rejects them.
+func TestGlobalConfigJSON(t *testing.T) {
+	ctx := context.Background()
+	config := NewConfig(ctx, Config{GlobalConfigFile: parentDir + GlobalConfigFilename})
+	require.Nil(t, config.LoadDefaults(ctx))
+	require.Nil(t, config.UnmarshalGlobalConfig(ctx))
+
+	data, err := json.Marshal(config.Global)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "null")
+}
+
 // TestInitConfigMissingFile tests the InitConfig function with a missing file.
 func TestInitConfigMissingKeys(t *testing.T) {
 	ctx := context.Background()
@@ -100,6 +114,68 @@ func TestInitConfigMissingKeys(t *testing.T) {
 		"validation failed, OriginalError: failed to validate global configuration")
 }
 
+// TestInitConfigUpstreams tests resolving the upstreams referenced by the servers.
+func TestInitConfigUpstreams(t *testing.T) {
+	ctx := context.Background()
+	config := NewConfig(ctx,
+		Config{
+			GlobalConfigFile: "./testdata/upstreams.yaml",
+			PluginConfigFile: parentDir + PluginsConfigFilename,
+		},
+	)
+	require.Nil(t, config.InitConfig(ctx))
+
+	// The upstream is resolved into the config groups of its name, with the defaults.
+	require.Contains(t, config.Global.Clients, "orders")
+	assert.Equal(t, "localhost:5433", config.Global.Clients["orders"].Address)
+	assert.Equal(t, DefaultNetwork, config.Global.Clients["orders"].Network)
+	require.Contains(t, config.Global.Pools, "orders")
+	assert.Equal(t, 5, config.Global.Pools["orders"].Size)
+	require.Contains(t, config.Global.Proxies, "orders")
+	assert.Equal(t, DefaultHealthCheckPeriod, config.Global.Proxies["orders"].HealthCheckPeriod)
+
+	// The servers reference the upstream, or use the config groups of their names.
+	assert.Equal(t, "orders", config.Global.Servers["orders-socket"].GetUpstream("orders-socket"))
+	assert.Equal(t, Default, config.Global.Servers[Default].GetUpstream(Default))
+	assert.Equal(t, config.Global.Servers["orders"], config.Global.UpstreamServer("orders"))
+	assert.Equal(t, config.Global.Pools["orders"], config.Global.Filter("orders-socket").Pools["orders-socket"])
+}
+
+// TestValidatePreAuthenticate tests requiring the servers to authenticate the clients
+// themselves if the server sessions of their pool are pre-authenticated.
+func TestValidatePreAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	config := NewConfig(ctx,
+		Config{
+			GlobalConfigFile: parentDir + "cmd/testdata/gatewayd.yaml",
+			PluginConfigFile: parentDir + PluginsConfigFilename,
+		},
+	)
+	require.Nil(t, config.InitConfig(ctx))
+
+	require.NoError(t, config.GlobalKoanf.Set("clients.default.preAuthenticate", true))
+	require.NoError(t, config.GlobalKoanf.Set("clients.default.user", "postgres"))
+	require.NotNil(t, config.ValidateGlobalConfig(ctx))
+
+	require.NoError(t, config.GlobalKoanf.Set("servers.default.authMethod", TokenAuth))
+	require.Nil(t, config.ValidateGlobalConfig(ctx))
+}
+
+// TestInitConfigUndefinedUpstream tests the validation of the references to the upstreams.
+func TestInitConfigUndefinedUpstream(t *testing.T) {
+	ctx := context.Background()
+	config := NewConfig(ctx,
+		Config{
+			GlobalConfigFile: "./testdata/undefined_upstream.yaml",
+			PluginConfigFile: parentDir + PluginsConfigFilename,
+		},
+	)
+	err := config.InitConfig(ctx)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(),
+		"validation failed, OriginalError: failed to validate global configuration")
+}
+
 // TestInitConfigMissingFile tests the InitConfig function with a missing file.
 func TestInitConfigMissingFile(t *testing.T) {
 	ctx := context.Background()
@@ -142,3 +218,90 @@ func TestMergeGlobalConfig(t *testing.T) {
 	// The log level should now be debug.
 	assert.Equal(t, "debug", config.Global.Loggers[Default].Level)
 }
+
+// TestMergePluginDefaults tests the MergePluginDefaults function.
+func TestMergePluginDefaults(t *testing.T) {
+	ctx := context.Background()
+	config := NewConfig(ctx,
+		Config{GlobalConfigFile: parentDir + GlobalConfigFilename, PluginConfigFile: parentDir + PluginsConfigFilename})
+	err := config.InitConfig(ctx)
+	require.Nil(t, err)
+
+	// Simulate a value set by the user in the global config file.
+	err = config.MergeGlobalConfig(ctx, map[string]interface{}{
+		"pluginConfigs": map[string]interface{}{
+			"test-plugin": map[string]interface{}{
+				"cacheSize": 20,
+			},
+		},
+	})
+	require.Nil(t, err)
+
+	err = config.MergePluginDefaults(ctx, map[string]map[string]any{
+		"test-plugin": {
+			"cacheSize": 10,
+			"enabled":   true,
+		},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 20, config.Global.PluginConfigs["test-plugin"]["cacheSize"])
+	assert.Equal(t, true, config.Global.PluginConfigs["test-plugin"]["enabled"])
+	assert.Equal(t, 20, config.GlobalKoanf.Int("pluginConfigs.test-plugin.cacheSize"))
+}
+
+// TestValidateBackendTLS tests that the certificates of the TLS of a backend are loaded on start.
+func TestValidateBackendTLS(t *testing.T) {
+	assert.NoError(t, validateBackendTLS(BackendTLS{CAFile: "missing.pem"}))
+	assert.NoError(t, validateBackendTLS(BackendTLS{Enabled: true, ServerName: "localhost"}))
+	assert.Error(t, validateBackendTLS(BackendTLS{Enabled: true, CAFile: "missing.pem"}))
+	assert.Error(t, validateBackendTLS(BackendTLS{Enabled: true, CertFile: "client.pem"}))
+	assert.Error(t, validateBackendTLS(BackendTLS{Enabled: true, CAFile: parentDir + GlobalConfigFilename}))
+}
+
+// TestValidatePluginConfigs tests validating the config sections of the plugins
+// against their schemas.
+func TestValidatePluginConfigs(t *testing.T) {
+	ctx := context.Background()
+	config := NewConfig(ctx,
+		Config{GlobalConfigFile: parentDir + GlobalConfigFilename, PluginConfigFile: parentDir + PluginsConfigFilename})
+	err := config.InitConfig(ctx)
+	require.Nil(t, err)
+
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"cacheSize": map[string]any{"type": "integer", "minimum": 1},
+			"enabled":   map[string]any{"type": "boolean"},
+		},
+		"required": []any{"cacheSize"},
+	}
+	err = config.MergePluginDefaults(ctx, map[string]map[string]any{
+		"test-plugin": {"cacheSize": 10, "enabled": true},
+	})
+	require.Nil(t, err)
+	assert.Nil(t, config.ValidatePluginConfigs(ctx, map[string]map[string]any{"test-plugin": schema}))
+
+	// The config sections of the plugins without a schema aren't validated.
+	assert.Nil(t, config.ValidatePluginConfigs(ctx, nil))
+
+	// The value set by the user is validated, not the default.
+	err = config.MergeGlobalConfig(ctx, map[string]interface{}{
+		"pluginConfigs": map[string]interface{}{
+			"test-plugin": map[string]interface{}{"cacheSize": 0},
+		},
+	})
+	require.Nil(t, err)
+	err = config.ValidatePluginConfigs(ctx, map[string]map[string]any{"test-plugin": schema})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "pluginConfigs.test-plugin")
+
+	// A plugin without a config section fails its required fields.
+	err = config.ValidatePluginConfigs(ctx, map[string]map[string]any{"other-plugin": schema})
+	assert.NotNil(t, err)
+
+	// An invalid schema fails the validation.
+	err = config.ValidatePluginConfigs(ctx, map[string]map[string]any{
+		"test-plugin": {"type": "unknown"},
+	})
+	assert.NotNil(t, err)
+}
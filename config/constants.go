@@ -7,7 +7,9 @@ import (
 type (
 	Status              uint
 	CompatibilityPolicy string
+	VerificationPolicy  string
 	LogOutput           uint
+	LogFormat           uint
 )
 
 // Status is the status of the server.
@@ -22,6 +24,61 @@ const (
 	Loose  CompatibilityPolicy = "loose"  // Load the plugin, even if the requirements are not met
 )
 
+// VerificationPolicy is the policy for the hook results that fail verification,
+// i.e. the hook returned an error or a result without the fields it was given.
+const (
+	PassDown VerificationPolicy = "passdown" // Pass the result down to the next hook
+	Ignore   VerificationPolicy = "ignore"   // Ignore the result and continue with the next hook
+	Abort    VerificationPolicy = "abort"    // Ignore the result and skip the rest of the hooks
+	Remove   VerificationPolicy = "remove"   // Remove the hook from the registry and ignore the result
+)
+
+// Auth methods for authenticating the clients.
+const (
+	NoAuth     = "none"   // Don't authenticate the clients
+	CertAuth   = "cert"   // Authenticate the clients by their TLS client certificate (mTLS)
+	TokenAuth  = "token"  // Authenticate the clients by a static token in the startup message
+	PluginAuth = "plugin" // Authenticate the clients by the plugins
+)
+
+// Receive strategies for reading the responses from the server.
+const (
+	ReadOnce          = "once"          // Read until a chunk is smaller than the chunk size
+	ReadUntilDeadline = "untilDeadline" // Keep reading until the server is quiet for the quiet period
+	ReadFramed        = "framed"        // Keep reading until the response ends with a complete message
+)
+
+// Receive timeout policies for the requests whose response doesn't arrive within
+// the receive deadline.
+const (
+	CloseOnTimeout = "close" // Close the client connection
+	ErrorOnTimeout = "error" // Reply with an error and recycle the server connection
+	RetryOnTimeout = "retry" // Retry the request once on a new server connection, then reply with an error
+)
+
+// ID strategies for generating the IDs of the server connections.
+const (
+	HashIDs       = "hash"       // SHA-256 hash of the local address of the connection
+	SequentialIDs = "sequential" // Sequence shared by all the connections, e.g. 42
+	UUIDs         = "uuid"       // Random UUID
+	ReadableIDs   = "readable"   // Name of the client config and an index, e.g. client-default-03
+)
+
+// Keepalives sent to the idle client connections on the ticks of the server.
+const (
+	NoKeepAlive       = "none"     // Don't send keepalives
+	PostgresKeepAlive = "postgres" // Send a ParameterStatus message, which the drivers accept at any time
+	RawKeepAlive      = "raw"      // Send the keepalive payload as is, e.g. for the raw protocols
+)
+
+// Compression levels of the client connections, from the fastest to the smallest.
+const (
+	FastestCompression = "fastest" // Roughly zstd level 1
+	DefaultCompression = "default" // Roughly zstd level 3
+	BetterCompression  = "better"  // Roughly zstd level 7
+	BestCompression    = "best"    // Roughly zstd level 11
+)
+
 // LogOutput is the output type for the logger.
 const (
 	Console LogOutput = iota
@@ -32,6 +89,14 @@ const (
 	RSyslog
 )
 
+// LogFormat is the format of the logs written to an output of the logger.
+const (
+	DefaultFormat LogFormat = iota // Console for the console output, JSON for the others
+	JSONFormat
+	LogfmtFormat
+	ConsoleFormat
+)
+
 const (
 	// Config constants.
 	Default               = "default"
@@ -56,6 +121,7 @@ const (
 	DefaultRSyslogNetwork    = "tcp"
 	DefaultRSyslogAddress    = "localhost:514"
 	DefaultSyslogPriority    = "info"
+	DefaultErrorLogWindow    = 10 * time.Second
 
 	// Plugin constants.
 	DefaultMinPort                 = 50000
@@ -66,42 +132,100 @@ const (
 	DefaultPluginHealthCheckPeriod = 5 * time.Second
 	DefaultPluginTimeout           = 30 * time.Second
 	DefaultPluginStartTimeout      = 1 * time.Minute
+	DefaultMaxPayloadSize          = 16 * 1024 * 1024 // 16 MiB
+	DefaultMaxHookPayloadSize      = 3 * 1024 * 1024  // 3 MiB, fits in 4 MiB gRPC messages base64-encoded
+	DefaultEnforceSignatures       = false
+	DefaultScriptTimeout           = 100 * time.Millisecond
+	DefaultPluginKeepAliveTimeout  = 20 * time.Second
+	DefaultBreakerFailures         = 5
+	DefaultBreakerSlowThreshold    = 1 * time.Second
+	DefaultBreakerCooldown         = 30 * time.Second
 
 	// Client constants.
-	DefaultNetwork            = "tcp"
-	DefaultAddress            = "localhost:5432"
-	DefaultChunkSize          = 8192
-	DefaultReceiveDeadline    = 0 // 0 means no deadline (timeout)
-	DefaultSendDeadline       = 0
-	DefaultTCPKeepAlivePeriod = 30 * time.Second
-	DefaultTCPKeepAlive       = false
-	DefaultReceiveTimeout     = 0
-	DefaultDialTimeout        = 60 * time.Second
-	DefaultRetries            = 3
-	DefaultBackoff            = 1 * time.Second
-	DefaultBackoffMultiplier  = 2.0
-	DefaultDisableBackoffCaps = false
+	DefaultNetwork              = "tcp"
+	DefaultAddress              = "localhost:5432"
+	DefaultChunkSize            = 8192
+	DefaultMaxChunkSize         = 128 * 1024 // 0 means the chunk size never grows
+	DefaultChunkShrinkPeriod    = time.Minute
+	DefaultReceiveDeadline      = 0 // 0 means no deadline (timeout)
+	DefaultSendDeadline         = 0
+	DefaultTCPKeepAlivePeriod   = 30 * time.Second
+	DefaultTCPKeepAlive         = false
+	DefaultTCPFastOpen          = false
+	DefaultDSCP                 = 0  // 0 means the packets aren't marked
+	MaxDSCP                     = 63 // the DSCP class is the 6 upper bits of the traffic class
+	DefaultTCPUserTimeout       = 0  // 0 means the OS default
+	DefaultReceiveTimeout       = 0
+	DefaultDialTimeout          = 60 * time.Second
+	DefaultRetries              = 3
+	DefaultBackoff              = 1 * time.Second
+	DefaultBackoffMultiplier    = 2.0
+	DefaultDisableBackoffCaps   = false
+	DefaultBackoffJitter        = 0.5  // fraction of the backoff, 0 means no jitter
+	DefaultMaxReconnects        = 10   // concurrent reconnections, 0 means no limit
+	DefaultRetryBudgetRate      = 10.0 // retries per second, 0 means no budget
+	DefaultRetryBudgetBurst     = 100
+	DefaultReceiveStrategy      = ReadOnce
+	DefaultReceiveQuietPeriod   = 10 * time.Millisecond
+	DefaultReceiveTimeoutPolicy = CloseOnTimeout
+	DefaultPreAuthenticate      = false
+	DefaultSessionResetTimeout  = 5 * time.Second
+	DefaultResetQuery           = "DISCARD ALL"
+	DefaultIDStrategy           = HashIDs
+
+	DefaultMaxMessageAssemblyTime = 0 // 0 means no limit
 
 	// Pool constants.
-	EmptyPoolCapacity        = 0
-	DefaultPoolSize          = 10
-	MinimumPoolSize          = 2
-	DefaultHealthCheckPeriod = 60 * time.Second // This must match PostgreSQL authentication timeout.
+	EmptyPoolCapacity          = 0
+	DefaultPoolSize            = 10
+	MinimumPoolSize            = 2
+	DefaultHealthCheckPeriod   = 60 * time.Second // This must match PostgreSQL authentication timeout.
+	DefaultHealthCheckJitter   = 0.0              // 0 means all the clients are recycled at once
+	DefaultCloseOnEmptyRequest = false
+	DefaultCaptureMaxSize      = 10 * 1024 * 1024 // 10 MiB per captured connection
+	DefaultMaxCaptures         = 10               // concurrently captured connections per proxy
+	DefaultWarmupPeriod        = 0                // 0 means the pool must be filled on startup
+	DefaultWarmupInterval      = time.Second
+	DefaultAcquireTimeout      = 0 // 0 means the connections are rejected right away
+	MinConnectionPriority      = 0 // the priority of the connections without one
+	MaxConnectionPriority      = 9
+	DefaultHandoffSetupRequest = 1 // the startup message of the trust authentication
+	DefaultPoolMaxSize         = 0 // 0 means the pool has a fixed size
+	DefaultPoolIdleTimeout     = 5 * time.Minute
+	DefaultMaxConcurrentDials  = 0 // 0 means the dials of the pools aren't limited
+	DefaultDialWaitTimeout     = 0 // 0 means the dials beyond the limit are rejected right away
+	DefaultInFlightTimeout     = 0 // 0 means the requests wait for their backend
 
 	// Server constants.
-	DefaultListenNetwork    = "tcp"
-	DefaultListenAddress    = "0.0.0.0:15432"
-	DefaultTickInterval     = 5 * time.Second
-	DefaultHandshakeTimeout = 5 * time.Second
+	DefaultListenNetwork          = "tcp"
+	DefaultListenAddress          = "0.0.0.0:15432"
+	DefaultTickInterval           = 5 * time.Second
+	DefaultHandshakeTimeout       = 5 * time.Second
+	DefaultShutdownGracePeriod    = 30 * time.Second
+	DefaultDrainCheckInterval     = 100 * time.Millisecond
+	DefaultIdleTimeout            = 0 // Disabled
+	DefaultIdleCheckInterval      = time.Second
+	DefaultMaxConnections         = 0 // Unlimited
+	DefaultMaxConnectionsPerIP    = 0 // Unlimited
+	DefaultTCPFastOpenQueueLength = 256
+	DefaultKeepAlive              = NoKeepAlive
 
 	// Utility constants.
 	DefaultSeed = 1000
 
+	// Label constants.
+	DefaultMaxLabels      = 4   // per connection
+	DefaultMaxLabelLength = 64  // per label value
+	DefaultMaxLabelValues = 100 // per label name in the metrics
+
 	// Metrics constants.
 	DefaultMetricsAddress       = "localhost:9090"
 	DefaultMetricsPath          = "/metrics"
 	DefaultReadHeaderTimeout    = 10 * time.Second
 	DefaultMetricsServerTimeout = 10 * time.Second
+	DefaultMetricsPushJob       = "gatewayd"
+	DefaultMetricsPushInterval  = 15 * time.Second
+	DefaultMetricsGoRuntime     = true
 
 	// Sentry constants.
 	DefaultTraceSampleRate  = 0.2
@@ -112,9 +236,14 @@ const (
 	DefaultHTTPAPIAddress = "localhost:18080"
 	DefaultGRPCAPINetwork = "tcp"
 	DefaultGRPCAPIAddress = "localhost:19090"
+	DefaultStatusTimeout  = 5 * time.Second
+	DefaultReplayTimeout  = 30 * time.Second
+	// The file descriptors aren't reserved by default.
+	DefaultReservedConnections = 0
 
 	// Policies.
 	DefaultCompatibilityPolicy = Strict
+	DefaultVerificationPolicy  = PassDown
 
 	// Act.
 	DefaultPolicy             = "passthrough"
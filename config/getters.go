@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -13,6 +14,12 @@ var (
 		"strict": Strict,
 		"loose":  Loose,
 	}
+	VerificationPolicies = map[string]VerificationPolicy{
+		"passdown": PassDown,
+		"ignore":   Ignore,
+		"abort":    Abort,
+		"remove":   Remove,
+	}
 	logOutputs = map[string]LogOutput{
 		"console": Console,
 		"stdout":  Stdout,
@@ -21,6 +28,11 @@ var (
 		"syslog":  Syslog,
 		"rsyslog": RSyslog,
 	}
+	logFormats = map[string]LogFormat{
+		"json":    JSONFormat,
+		"logfmt":  LogfmtFormat,
+		"console": ConsoleFormat,
+	}
 	TimeFormats = map[string]string{
 		"":          zerolog.TimeFormatUnix,
 		"unix":      zerolog.TimeFormatUnix,
@@ -76,6 +88,84 @@ func (l Logger) GetOutput() []LogOutput {
 	return outputs
 }
 
+// GetFormat returns the log format of each output from config file. The outputs
+// without a known format use the default one, i.e. console for the console output
+// and JSON for the others.
+func (l Logger) GetFormat() map[LogOutput]LogFormat {
+	formats := map[LogOutput]LogFormat{}
+	for output, format := range l.Format {
+		logOutput, ok := logOutputs[output]
+		if !ok {
+			continue
+		}
+		if logFormat, ok := logFormats[format]; ok {
+			formats[logOutput] = logFormat
+		}
+	}
+	return formats
+}
+
+// GetBackend returns the client config of the backend with the given index, in
+// round-robin order, so that the clients of a pool are spread over the backends.
+// Without backends, it returns the client config itself.
+func (c Client) GetBackend(index int) *Client {
+	if len(c.Backends) == 0 {
+		return &c
+	}
+
+	backend := c.Backends[index%len(c.Backends)]
+	c.Network = backend.Network
+	c.Address = backend.Address
+	c.User = If(backend.User != "", backend.User, c.User)
+	c.Database = If(backend.Database != "", backend.Database, c.Database)
+	c.Password = If(backend.Password != "", backend.Password, c.Password)
+	if backend.TLS != nil {
+		c.TLS = *backend.TLS
+	}
+	c.Backends = nil
+	return &c
+}
+
+// GetWeightedBackend returns the client config of the backend with the given index,
+// like GetBackend, but in weighted round-robin order, so that each backend gets its
+// share of the clients of a pool by its weight.
+func (c Client) GetWeightedBackend(index int) *Client {
+	total := 0
+	for _, backend := range c.Backends {
+		total += backend.GetWeight()
+	}
+	if total == 0 {
+		return c.GetBackend(index)
+	}
+
+	slot := index % total
+	for backendIndex, backend := range c.Backends {
+		if slot < backend.GetWeight() {
+			return c.GetBackend(backendIndex)
+		}
+		slot -= backend.GetWeight()
+	}
+	return c.GetBackend(index)
+}
+
+// GetWeight returns the weight of the backend, 1 if it's not set.
+func (b Backend) GetWeight() int {
+	return If(b.Weight > 0, b.Weight, 1)
+}
+
+// GetStartupParameters returns the startup parameters that replace the ones sent
+// by the clients, i.e. the credentials of the backend.
+func (c Client) GetStartupParameters() map[string]string {
+	parameters := map[string]string{}
+	if c.User != "" {
+		parameters["user"] = c.User
+	}
+	if c.Database != "" {
+		parameters["database"] = c.Database
+	}
+	return parameters
+}
+
 // GetPlugins returns the plugins from config file.
 func (p PluginConfig) GetPlugins(name ...string) []Plugin {
 	var plugins []Plugin
@@ -107,16 +197,77 @@ func GetDefaultConfigFilePath(filename string) string {
 	return filepath.Join("./", filename)
 }
 
-// Filter returns a filtered global config based on the group name.
+// GetUpstream returns the name of the upstream of the server with the name, i.e. of
+// the config group of its client, pool and proxy.
+func (s Server) GetUpstream(name string) string {
+	return If(s.Upstream != "", s.Upstream, name)
+}
+
+// ResolveUpstreams resolves the upstreams into the config groups of the clients, pools
+// and proxies with their names, which replace the groups of the same name, i.e. the
+// default ones, since the other names can't be used by both.
+func (gc *GlobalConfig) ResolveUpstreams() {
+	for name, upstream := range gc.Upstreams {
+		if upstream == nil {
+			continue
+		}
+		if gc.Clients == nil {
+			gc.Clients = map[string]*Client{}
+		}
+		if gc.Pools == nil {
+			gc.Pools = map[string]*Pool{}
+		}
+		if gc.Proxies == nil {
+			gc.Proxies = map[string]*Proxy{}
+		}
+		if upstream.Client != nil {
+			gc.Clients[name] = upstream.Client
+		}
+		if upstream.Pool != nil {
+			gc.Pools[name] = upstream.Pool
+		}
+		if upstream.Proxy != nil {
+			gc.Proxies[name] = upstream.Proxy
+		}
+	}
+}
+
+// UpstreamServer returns the server of the upstream whose settings apply to the whole
+// upstream, e.g. the authentication of its proxy: the server with the name of the
+// upstream, or else the first one by name of the servers that reference it.
+func (gc GlobalConfig) UpstreamServer(upstream string) *Server {
+	if server, ok := gc.Servers[upstream]; ok && server != nil && server.GetUpstream(upstream) == upstream {
+		return server
+	}
+	names := make([]string, 0, len(gc.Servers))
+	for name := range gc.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if server := gc.Servers[name]; server != nil && server.GetUpstream(name) == upstream {
+			return server
+		}
+	}
+	return nil
+}
+
+// Filter returns a filtered global config based on the group name, with the client,
+// pool and proxy of the upstream of its server.
 func (gc GlobalConfig) Filter(groupName string) *GlobalConfig {
-	if _, ok := gc.Servers[groupName]; !ok {
+	server, ok := gc.Servers[groupName]
+	if !ok {
 		return nil
 	}
+	upstream := groupName
+	if server != nil {
+		upstream = server.GetUpstream(groupName)
+	}
 	return &GlobalConfig{
 		Loggers: map[string]*Logger{groupName: gc.Loggers[groupName]},
-		Clients: map[string]*Client{groupName: gc.Clients[groupName]},
-		Pools:   map[string]*Pool{groupName: gc.Pools[groupName]},
-		Proxies: map[string]*Proxy{groupName: gc.Proxies[groupName]},
+		Clients: map[string]*Client{groupName: gc.Clients[upstream]},
+		Pools:   map[string]*Pool{groupName: gc.Pools[upstream]},
+		Proxies: map[string]*Proxy{groupName: gc.Proxies[upstream]},
 		Servers: map[string]*Server{groupName: gc.Servers[groupName]},
 		Metrics: map[string]*Metrics{groupName: gc.Metrics[groupName]},
 		API:     gc.API,
@@ -20,6 +20,17 @@ func TestGetOutputWithMultipleLoggers(t *testing.T) {
 	assert.Equal(t, []LogOutput{Console, File}, logger.GetOutput())
 }
 
+// TestGetFormat tests the GetFormat function, skipping the unknown outputs and formats.
+func TestGetFormat(t *testing.T) {
+	logger := Logger{Format: map[string]string{
+		"stdout":  "logfmt",
+		"file":    "json",
+		"console": "xml",
+		"printer": "logfmt",
+	}}
+	assert.Equal(t, map[LogOutput]LogFormat{Stdout: LogfmtFormat, File: JSONFormat}, logger.GetFormat())
+}
+
 // TestGetPlugins tests the GetPlugins function.
 func TestGetPlugins(t *testing.T) {
 	plugin := Plugin{Name: "plugin1"}
@@ -27,6 +38,79 @@ func TestGetPlugins(t *testing.T) {
 	assert.Equal(t, []Plugin{plugin}, pluginConfig.GetPlugins("plugin1"))
 }
 
+// TestGetBackend tests spreading the clients over the backends.
+func TestGetBackend(t *testing.T) {
+	client := Client{
+		Network:  "tcp",
+		Address:  "localhost:5432",
+		User:     "postgres",
+		Password: "secret",
+		Backends: []Backend{
+			{Network: "tcp", Address: "localhost:5433", User: "tenant1", Database: "db1", Password: "tenant1"},
+			{Network: "unix", Address: "/tmp/.s.PGSQL.5432"},
+		},
+		TLS: BackendTLS{Enabled: true, CAFile: "ca.pem"},
+	}
+	client.Backends[0].TLS = &BackendTLS{Enabled: true, ServerName: "tenant1.example.com"}
+
+	first := client.GetBackend(0)
+	assert.Equal(t, "localhost:5433", first.Address)
+	assert.Equal(t, map[string]string{"user": "tenant1", "database": "db1"}, first.GetStartupParameters())
+	assert.Equal(t, "tenant1", first.Password)
+	assert.Equal(t, BackendTLS{Enabled: true, ServerName: "tenant1.example.com"}, first.TLS)
+	assert.Empty(t, first.Backends)
+
+	second := client.GetBackend(1)
+	assert.Equal(t, "unix", second.Network)
+	assert.Equal(t, map[string]string{"user": "postgres"}, second.GetStartupParameters())
+	assert.Equal(t, "secret", second.Password)
+	assert.Equal(t, client.TLS, second.TLS)
+
+	assert.Equal(t, first, client.GetBackend(2))
+
+	// Without backends, the client config itself is used.
+	assert.Equal(t, &Client{Address: "localhost:5432"}, Client{Address: "localhost:5432"}.GetBackend(1))
+	assert.Empty(t, Client{}.GetStartupParameters())
+}
+
+// TestGetWeightedBackend tests spreading the clients over the backends by weight.
+func TestGetWeightedBackend(t *testing.T) {
+	client := Client{
+		Network: "tcp",
+		Address: "localhost:5432",
+		Backends: []Backend{
+			{Network: "tcp", Address: "localhost:5433", Weight: 2},
+			{Network: "tcp", Address: "localhost:5434"},
+		},
+	}
+
+	addresses := []string{}
+	for index := range 6 {
+		addresses = append(addresses, client.GetWeightedBackend(index).Address)
+	}
+	assert.Equal(t, []string{
+		"localhost:5433", "localhost:5433", "localhost:5434",
+		"localhost:5433", "localhost:5433", "localhost:5434",
+	}, addresses)
+	assert.Equal(t, 1, Backend{}.GetWeight())
+
+	// Without backends, the client config itself is used.
+	assert.Equal(t, &Client{Address: "localhost:5432"}, Client{Address: "localhost:5432"}.GetWeightedBackend(1))
+}
+
+// TestValidateBackend tests validating the backends added at runtime like the
+// ones in the config.
+func TestValidateBackend(t *testing.T) {
+	require.NoError(t, ValidateBackend(Backend{Network: "tcp", Address: "localhost:5433", Weight: 2}))
+	require.Error(t, ValidateBackend(Backend{Network: "tcp"}))
+	require.Error(t, ValidateBackend(Backend{Network: "tcp", Address: "localhost:5433", Weight: -1}))
+	require.Error(t, ValidateBackend(Backend{Network: "tcp", Address: "localhost:5433", WarmStandby: -1}))
+	require.Error(t, ValidateBackend(Backend{Network: "tcp", Address: "localhost:5433", MaxInFlight: -1}))
+	require.Error(t, ValidateBackend(Backend{
+		Network: "tcp", Address: "localhost:5433", TLS: &BackendTLS{Enabled: true, CertFile: "cert.pem"},
+	}))
+}
+
 // TestGetDefaultConfigFilePath tests the GetDefaultConfigFilePath function.
 func TestGetDefaultConfigFilePath(t *testing.T) {
 	assert.Equal(t, GlobalConfigFilename, GetDefaultConfigFilePath(GlobalConfigFilename))
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/parsers/json"
+	"github.com/knadh/koanf/parsers/toml"
+	"github.com/knadh/koanf/parsers/yaml"
+)
+
+// Parser returns the koanf parser of the config file, by its extension: JSON for
+// .json, TOML for .toml and YAML for .yaml, .yml or any other extension.
+func Parser(path string) koanf.Parser {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return json.Parser()
+	case ".toml":
+		return toml.Parser()
+	default:
+		return yaml.Parser()
+	}
+}
+
+// IsYAML returns true if the config file is parsed as YAML.
+func IsYAML(path string) bool {
+	_, ok := Parser(path).(*yaml.YAML)
+	return ok
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConfigFormats tests loading the global config file in the format of its extension.
+func TestConfigFormats(t *testing.T) {
+	ctx := context.Background()
+	for filename, contents := range map[string]string{
+		"gatewayd.yaml": "loggers:\n  default:\n    level: debug\n",
+		"gatewayd.yml":  "loggers:\n  default:\n    level: debug\n",
+		"gatewayd.json": `{"loggers": {"default": {"level": "debug"}}}`,
+		"gatewayd.toml": "[loggers.default]\nlevel = \"debug\"\n",
+	} {
+		t.Run(filename, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), filename)
+			require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+			config := NewConfig(ctx, Config{GlobalConfigFile: path})
+			require.Nil(t, config.LoadDefaults(ctx))
+			require.Nil(t, config.LoadGlobalConfigFile(ctx))
+			require.Nil(t, config.UnmarshalGlobalConfig(ctx))
+			assert.Equal(t, "debug", config.Global.Loggers[Default].Level)
+		})
+	}
+}
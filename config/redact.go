@@ -0,0 +1,98 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+// RedactedValue replaces the values of the sensitive keys when the config is printed.
+const RedactedValue = "<redacted>"
+
+// sensitiveKeys are the parts of the key names whose values are redacted, matched
+// case-insensitively. This also applies to the NAME=value environment variables
+// of the plugins.
+var sensitiveKeys = []string{
+	"password", "passwd", "secret", "token", "apikey", "api_key", "credential", "dsn", "privatekey",
+}
+
+// IsSensitiveKey returns true if the value of the key must be redacted.
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns a copy of the config values with the values of the sensitive keys
+// and the passwords in the URLs redacted. The original values are not modified.
+func Redact(values map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if IsSensitiveKey(key) {
+			redacted[key] = RedactedValue
+			continue
+		}
+		redacted[key] = redactValue(value)
+	}
+	return redacted
+}
+
+// redactValue redacts the nested maps and lists, and the strings.
+func redactValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return Redact(typed)
+	case map[string]string:
+		redacted := make(map[string]interface{}, len(typed))
+		for key, value := range typed {
+			if IsSensitiveKey(key) {
+				redacted[key] = RedactedValue
+				continue
+			}
+			redacted[key] = redactString(value)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, 0, len(typed))
+		for _, item := range typed {
+			redacted = append(redacted, redactValue(item))
+		}
+		return redacted
+	case []string:
+		redacted := make([]interface{}, 0, len(typed))
+		for _, item := range typed {
+			redacted = append(redacted, redactValue(item))
+		}
+		return redacted
+	case string:
+		return redactString(typed)
+	default:
+		return value
+	}
+}
+
+// redactString redacts the value of a NAME=value environment variable with a
+// sensitive name, and the password of a URL.
+func redactString(value string) string {
+	if name, _, found := strings.Cut(value, "="); found && IsSensitiveKey(name) {
+		return name + "=" + RedactedValue
+	}
+
+	if strings.Contains(value, "://") {
+		if parsed, err := url.Parse(value); err == nil && parsed.User != nil {
+			if _, hasPassword := parsed.User.Password(); hasPassword {
+				return parsed.Redacted()
+			}
+		}
+	}
+
+	// The URLs may also be the values of the environment variables.
+	if name, rest, found := strings.Cut(value, "="); found && strings.Contains(rest, "://") {
+		return name + "=" + redactString(rest)
+	}
+
+	return value
+}
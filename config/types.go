@@ -6,13 +6,28 @@ import (
 )
 
 type Plugin struct {
-	Name      string   `json:"name" jsonschema:"required"`
-	Enabled   bool     `json:"enabled"`
-	LocalPath string   `json:"localPath" jsonschema:"required"`
-	Args      []string `json:"args"`
-	Env       []string `json:"env" jsonschema:"required"`
-	Checksum  string   `json:"checksum" jsonschema:"required"`
-	URL       string   `json:"url"`
+	Name            string   `json:"name" jsonschema:"required"`
+	Enabled         bool     `json:"enabled"`
+	LocalPath       string   `json:"localPath" jsonschema:"required"`
+	Args            []string `json:"args"`
+	Env             []string `json:"env" jsonschema:"required"`
+	Checksum        string   `json:"checksum" jsonschema:"required"`
+	URL             string   `json:"url"`
+	VerifySignature bool     `json:"verifySignature"`
+	Signature       string   `json:"signature,omitempty"`
+	// Mirror streams the proxied traffic to the traffic mirror service of the plugin,
+	// in plugin/v1, instead of calling its traffic hooks once per message.
+	Mirror bool `json:"mirror,omitempty"`
+}
+
+// Script is a Lua script that runs in-process as a hook, without a plugin.
+type Script struct {
+	Name     string        `json:"name" jsonschema:"required"`
+	Enabled  bool          `json:"enabled"`
+	Hooks    []string      `json:"hooks" jsonschema:"required"`
+	Priority uint          `json:"priority"`
+	Timeout  time.Duration `json:"timeout" jsonschema:"oneof_type=string;integer"`
+	Script   string        `json:"script" jsonschema:"required"`
 }
 
 type Policy struct {
@@ -21,6 +36,32 @@ type Policy struct {
 	Metadata map[string]any `json:"metadata,omitempty"`
 }
 
+// PluginGRPC are the settings of the gRPC connections to the plugins. The unset
+// settings keep the defaults of go-plugin.
+type PluginGRPC struct {
+	// MaxRecvMsgSize and MaxSendMsgSize are the largest messages received from and
+	// sent to the plugins, in bytes, 0 means no limit.
+	MaxRecvMsgSize int `json:"maxRecvMsgSize"`
+	MaxSendMsgSize int `json:"maxSendMsgSize"`
+	// KeepAliveTime is how long a connection is idle before it's pinged, 0 means it's
+	// never pinged, and KeepAliveTimeout is how long the ping waits for the plugin.
+	KeepAliveTime                time.Duration `json:"keepAliveTime" jsonschema:"oneof_type=string;integer"`
+	KeepAliveTimeout             time.Duration `json:"keepAliveTimeout" jsonschema:"oneof_type=string;integer"`
+	KeepAlivePermitWithoutStream bool          `json:"keepAlivePermitWithoutStream"`
+}
+
+// PluginCircuitBreaker isolates the plugins whose hooks keep failing or being slow
+// from the request path: after Failures consecutive failed or slow runs of a hook of a
+// plugin, the hook is skipped for the Cooldown, then a single run retests it.
+type PluginCircuitBreaker struct {
+	Enabled  bool `json:"enabled"`
+	Failures int  `json:"failures"`
+	// SlowThreshold is how long a run of a hook can take before it counts as failed,
+	// 0 means only the errors count.
+	SlowThreshold time.Duration `json:"slowThreshold" jsonschema:"oneof_type=string;integer"`
+	Cooldown      time.Duration `json:"cooldown" jsonschema:"oneof_type=string;integer"`
+}
+
 type PluginConfig struct {
 	CompatibilityPolicy string            `json:"compatibilityPolicy" jsonschema:"enum=strict,enum=loose"`
 	EnableMetricsMerger bool              `json:"enableMetricsMerger"`
@@ -35,6 +76,18 @@ type PluginConfig struct {
 	ActionTimeout       time.Duration     `json:"actionTimeout" jsonschema:"oneof_type=string;integer"`
 	ActionRedis         ActionRedisConfig `json:"actionRedis"`
 	Policies            []Policy          `json:"policies"`
+	MaxPayloadSize      int               `json:"maxPayloadSize"`
+	MaxHookPayloadSize  int               `json:"maxHookPayloadSize"`
+	VerificationPolicy  string            `json:"verificationPolicy" jsonschema:"enum=passdown,enum=ignore,enum=abort,enum=remove"`
+	HookVerification    map[string]string `json:"hookVerification"`
+	DisabledHooks       []string          `json:"disabledHooks"`
+	PublicKey           string            `json:"publicKey"`
+	EnforceSignatures   bool              `json:"enforceSignatures"`
+	Scripts             []Script          `json:"scripts"`
+	GRPC                PluginGRPC        `json:"grpc"`
+
+	// CircuitBreaker skips the hooks of the plugins that keep failing or being slow.
+	CircuitBreaker PluginCircuitBreaker `json:"circuitBreaker"`
 }
 
 type ActionRedisConfig struct {
@@ -44,9 +97,12 @@ type ActionRedisConfig struct {
 }
 
 type Client struct {
-	Network            string        `json:"network" jsonschema:"enum=tcp,enum=udp,enum=unix"`
+	Network            string        `json:"network" jsonschema:"enum=tcp,enum=udp,enum=unix,enum=memory"`
 	Address            string        `json:"address"`
 	TCPKeepAlive       bool          `json:"tcpKeepAlive"`
+	TCPFastOpen        bool          `json:"tcpFastOpen"`
+	DSCP               int           `json:"dscp"`
+	TCPUserTimeout     time.Duration `json:"tcpUserTimeout" jsonschema:"oneof_type=string;integer"`
 	TCPKeepAlivePeriod time.Duration `json:"tcpKeepAlivePeriod" jsonschema:"oneof_type=string;integer"`
 	ReceiveChunkSize   int           `json:"receiveChunkSize"`
 	ReceiveDeadline    time.Duration `json:"receiveDeadline" jsonschema:"oneof_type=string;integer"`
@@ -57,6 +113,77 @@ type Client struct {
 	Backoff            time.Duration `json:"backoff" jsonschema:"oneof_type=string;integer"`
 	BackoffMultiplier  float64       `json:"backoffMultiplier"`
 	DisableBackoffCaps bool          `json:"disableBackoffCaps"`
+	BackoffJitter      float64       `json:"backoffJitter"`
+	MaxReconnects      int           `json:"maxReconnects"`
+	RetryBudgetRate    float64       `json:"retryBudgetRate"`
+	RetryBudgetBurst   int           `json:"retryBudgetBurst"`
+	ReceiveStrategy    string        `json:"receiveStrategy" jsonschema:"enum=once,enum=untilDeadline,enum=framed"`
+	ReceiveQuietPeriod time.Duration `json:"receiveQuietPeriod" jsonschema:"oneof_type=string;integer"`
+	// ReceiveTimeoutPolicy decides what happens to a request whose response doesn't
+	// arrive within the receive deadline.
+	ReceiveTimeoutPolicy string    `json:"receiveTimeoutPolicy" jsonschema:"enum=close,enum=error,enum=retry"`
+	User                 string    `json:"user,omitempty"`
+	Database             string    `json:"database,omitempty"`
+	Password             string    `json:"password,omitempty"`
+	PreAuthenticate      bool      `json:"preAuthenticate"`
+	ResetQuery           string    `json:"resetQuery"`
+	IDStrategy           string    `json:"idStrategy" jsonschema:"enum=hash,enum=sequential,enum=uuid,enum=readable"`
+	Backends             []Backend `json:"backends,omitempty"`
+
+	// The receive chunk grows from the receive chunk size up to the max on large
+	// responses, and shrinks back after the shrink period without any.
+	MaxReceiveChunkSize      int           `json:"maxReceiveChunkSize"`
+	ReceiveChunkShrinkPeriod time.Duration `json:"receiveChunkShrinkPeriod" jsonschema:"oneof_type=string;integer"`
+
+	// The framed receive strategy fails the responses that aren't complete messages
+	// within the max message assembly time after their first bytes.
+	MaxMessageAssemblyTime time.Duration `json:"maxMessageAssemblyTime" jsonschema:"oneof_type=string;integer"`
+
+	// TLS is the TLS of the connections to the database, unless the backend has its own.
+	TLS BackendTLS `json:"tls"`
+
+	// Name is the key of the client config in the global config, set on start.
+	Name string `json:"-"`
+}
+
+// Backend is a database server of a client config. The backends share the settings
+// of the client config, but have their own connection parameters and credentials.
+type Backend struct {
+	Network  string `json:"network" jsonschema:"enum=tcp,enum=udp,enum=unix,enum=memory"`
+	Address  string `json:"address"`
+	User     string `json:"user,omitempty"`
+	Database string `json:"database,omitempty"`
+	Password string `json:"password,omitempty"`
+	// TLS replaces the TLS of the client config, e.g. for the managed databases
+	// with their own certificates.
+	TLS *BackendTLS `json:"tls,omitempty"`
+	// Weight is the share of the server connections of the backend relative to
+	// the other backends, 1 if it's not set.
+	Weight int `json:"weight,omitempty"`
+	// WarmStandby is the number of the connections to the backend that are kept
+	// connected, even if it's not serving, for promoting them on a failover to it.
+	WarmStandby int `json:"warmStandby,omitempty"`
+	// MaxInFlight is the max number of the requests in flight to the backend at once,
+	// over all its server connections, e.g. for a replica that serializes them
+	// internally. A request is in flight until the server is ready for the next query,
+	// or until its first response for other protocols than PostgreSQL, and the requests
+	// above it are queued, as measured by gatewayd_backend_in_flight_requests{backend}
+	// and gatewayd_backend_in_flight_queued_total{backend}. 0 means no limit.
+	MaxInFlight int `json:"maxInFlight,omitempty"`
+}
+
+// BackendTLS is the TLS of the connections to a database server, which is requested
+// with an SSLRequest message right after connecting.
+type BackendTLS struct {
+	Enabled bool `json:"enabled"`
+	// ServerName is sent as SNI and verified against the server's certificate.
+	// It defaults to the host of the address.
+	ServerName string `json:"serverName,omitempty"`
+	// CAFile verifies the server's certificate, instead of the system's CAs.
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are the client certificate, if the server requires one.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
 }
 
 type Logger struct {
@@ -65,6 +192,8 @@ type Logger struct {
 	Level             string   `json:"level" jsonschema:"enum=trace,enum=debug,enum=info,enum=warn,enum=error,enum=fatal,enum=panic,enum=disabled"`
 	ConsoleTimeFormat string   `json:"consoleTimeFormat" jsonschema:"enum=Layout,enum=ANSIC,enum=UnixDate,enum=RubyDate,enum=RFC822,enum=RFC822Z,enum=RFC850,enum=RFC1123,enum=RFC1123Z,enum=RFC3339,enum=RFC3339Nano,enum=Kitchen,enum=Stamp,enum=StampMilli,enum=StampMicro,enum=StampNano"`
 	NoColor           bool     `json:"noColor"`
+	// The format of the logs of each output, e.g. logfmt for stdout.
+	Format map[string]string `json:"format,omitempty"`
 
 	FileName   string `json:"fileName"`
 	MaxSize    int    `json:"maxSize"`
@@ -76,6 +205,9 @@ type Logger struct {
 	RSyslogNetwork string `json:"rsyslogNetwork" jsonschema:"enum=tcp,enum=udp,enum=unix"`
 	RSyslogAddress string `json:"rsyslogAddress"`
 	SyslogPriority string `json:"syslogPriority" jsonschema:"enum=debug,enum=info,enum=notice,enum=warning,enum=err,enum=crit,enum=alert,enum=emerg"`
+
+	// The repeated errors within the window are collapsed into a summary.
+	ErrorLogWindow time.Duration `json:"errorLogWindow" jsonschema:"oneof_type=string;integer"`
 }
 
 type Metrics struct {
@@ -86,25 +218,177 @@ type Metrics struct {
 	Timeout           time.Duration `json:"timeout" jsonschema:"oneof_type=string;integer"`
 	CertFile          string        `json:"certFile"`
 	KeyFile           string        `json:"keyFile"`
+	PushURL           string        `json:"pushURL"`
+	PushJob           string        `json:"pushJob"`
+	PushInterval      time.Duration `json:"pushInterval" jsonschema:"oneof_type=string;integer"`
+	GoRuntime         bool          `json:"goRuntime"`
 }
 
 type Pool struct {
-	Size int `json:"size"`
+	Size         int           `json:"size"`
+	WarmupPeriod time.Duration `json:"warmupPeriod" jsonschema:"oneof_type=string;integer"`
+
+	// MaxSize is the size the pool grows to lazily under load, from the size that's
+	// preallocated on startup, or 0 for a fixed size pool.
+	MaxSize int `json:"maxSize"`
+	// IdleTimeout is how long the server connections above the size are idle in the
+	// pool before they're closed, shrinking the pool back toward its size.
+	IdleTimeout time.Duration `json:"idleTimeout" jsonschema:"oneof_type=string;integer"`
+	// MaxConcurrentDials is the max number of the server connections dialed at once to
+	// grow the pool, or 0 for no limit. The connections beyond it wait for a dial for
+	// the dial wait timeout, or aren't dialed for if it's 0.
+	MaxConcurrentDials int           `json:"maxConcurrentDials"`
+	DialWaitTimeout    time.Duration `json:"dialWaitTimeout" jsonschema:"oneof_type=string;integer"`
 }
 
 type Proxy struct {
-	HealthCheckPeriod time.Duration `json:"healthCheckPeriod" jsonschema:"oneof_type=string;integer"`
+	HealthCheckPeriod   time.Duration `json:"healthCheckPeriod" jsonschema:"oneof_type=string;integer"`
+	HealthCheckJitter   float64       `json:"healthCheckJitter"`
+	CloseOnEmptyRequest bool          `json:"closeOnEmptyRequest"`
+	ServerVersion       string        `json:"serverVersion"`
+	CaptureClients      []string      `json:"captureClients,omitempty"`
+	CaptureDir          string        `json:"captureDir"`
+	CaptureMaxSize      int64         `json:"captureMaxSize"`
+
+	// VerifyModifiedRequests rejects the requests modified by the plugins into other
+	// messages than the original ones, and forwards the original requests instead.
+	VerifyModifiedRequests bool `json:"verifyModifiedRequests"`
+
+	// LameDuckPeriod is how long a drained backend is kept in lame duck after its
+	// last server connection is recycled, before it's reported as drained.
+	LameDuckPeriod time.Duration `json:"lameDuckPeriod" jsonschema:"oneof_type=string;integer"`
+
+	// RetryOnReset retries the reads once on a new server connection if the server
+	// closed or reset the connection before responding, e.g. on a restart, instead of
+	// recycling the server connection when the client disconnects. The requests are
+	// only retried outside of a transaction and if the server sessions are
+	// pre-authenticated, so that they can be restored, and their prepared statements
+	// are prepared again. The writes aren't retried, since the server may have run
+	// them before the connection was reset, but the reads calling functions that
+	// write, e.g. SELECT nextval('seq'), can still run twice.
+	RetryOnReset bool `json:"retryOnReset"`
+
+	// PoolEvents runs the OnPoolAcquire and OnPoolRelease hooks on every connection.
+	PoolEvents bool `json:"poolEvents"`
+
+	// LoadBalanceHook runs the OnLoadBalance hooks on every connection, which choose
+	// the backend of its server connection instead of the built-in strategy.
+	LoadBalanceHook bool `json:"loadBalanceHook"`
+
+	// TestOnBorrow checks that the server connections taken from the pool are still
+	// open and their backend responds to a ping, before they're handed out, and
+	// replaces the ones that fail the check.
+	TestOnBorrow bool `json:"testOnBorrow"`
+	// TestOnReturn checks the server connections the same way before they're put back
+	// in the pool, independently of the check on borrow.
+	TestOnReturn bool `json:"testOnReturn"`
+
+	// AcquireTimeout is how long the connections wait for a server connection when
+	// the pool is exhausted, in the order of their priority, before they're rejected.
+	AcquireTimeout time.Duration `json:"acquireTimeout" jsonschema:"oneof_type=string;integer"`
+	// ClientPriorities are the priorities of the connections by the IP addresses and
+	// CIDR ranges of the clients, unless the OnOpening hooks set their priority.
+	ClientPriorities []ClientPriority `json:"clientPriorities"`
+
+	// SessionHandoff hands the sessions off to another backend between the requests,
+	// when their backend is drained or down, by replaying their setup requests. The
+	// sessions in a transaction stay on their backend, their prepared statements are
+	// prepared again, and any other state, e.g. the parameters set with SET or the
+	// temporary tables, is lost. The handoffs are counted by
+	// gatewayd_session_handoffs_total{result}.
+	SessionHandoff bool `json:"sessionHandoff"`
+	// HandoffSetupRequests is the number of the first requests of the sessions that
+	// are recorded and replayed on handoff, e.g. the startup and password messages.
+	// They're replayed with the credentials of the new backend and their responses are
+	// discarded, so each of them must get a single response. With PostgreSQL, only the
+	// sessions authenticated with trust or a cleartext password, i.e. 1 or 2 requests,
+	// can be handed off, unless the server connections are pre-authenticated. The MD5
+	// and SCRAM sessions stay on their backend, since their handshakes can't be replayed.
+	HandoffSetupRequests int `json:"handoffSetupRequests"`
+
+	// InFlightTimeout is how long the requests wait for their backend to be below its
+	// max in-flight requests, before they're rejected with an error, which doesn't abort
+	// the transaction of the client, or their connections are closed for other protocols
+	// than PostgreSQL. The timeouts are counted by
+	// gatewayd_backend_in_flight_timeouts_total{backend}. 0 means they wait until it is.
+	InFlightTimeout time.Duration `json:"inFlightTimeout" jsonschema:"oneof_type=string;integer"`
+
+	// CertificateRoutes tag and route the connections by the attributes of their client
+	// certificates, verified in the TLS handshake against the client CAs of the server,
+	// so the untrusted certificates fail the handshake. The first matching route tags
+	// the connection with its labels and moves it to a server connection of its backend,
+	// or rejects it if the backend has no available server connections. The others fall
+	// back to the OnLoadBalance hooks, which get the attributes of the certificate in the
+	// "certificate" field of the client, like the traffic hooks. The routes are counted
+	// by gatewayd_certificate_routes_total{result}.
+	CertificateRoutes []CertificateRoute `json:"certificateRoutes,omitempty"`
+}
+
+// ClientPriority is the priority of the connections of the clients, by their IP
+// addresses and CIDR ranges.
+type ClientPriority struct {
+	Clients  []string `json:"clients"`
+	Priority int      `json:"priority"`
+}
+
+// CertificateRoute routes the connections whose client certificates match all of its
+// attributes to a backend, e.g. "tcp://localhost:5433", and tags them with its labels.
+// The attributes are shell patterns, e.g. "*.example.com", matched against the common
+// name, any of the organizational units and any of the subject alternative names, i.e.
+// the DNS names, emails, URIs and IP addresses, and all of them must match.
+type CertificateRoute struct {
+	CommonName         string            `json:"commonName,omitempty"`
+	OrganizationalUnit string            `json:"organizationalUnit,omitempty"`
+	SAN                string            `json:"san,omitempty"`
+	Backend            string            `json:"backend,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
 }
 
 type Server struct {
 	EnableTicker     bool          `json:"enableTicker"`
 	TickInterval     time.Duration `json:"tickInterval" jsonschema:"oneof_type=string;integer"`
-	Network          string        `json:"network" jsonschema:"enum=tcp,enum=udp,enum=unix"`
+	Network          string        `json:"network" jsonschema:"enum=tcp,enum=udp,enum=unix,enum=memory"`
 	Address          string        `json:"address"`
 	EnableTLS        bool          `json:"enableTLS"` //nolint:tagliatelle
 	CertFile         string        `json:"certFile"`
 	KeyFile          string        `json:"keyFile"`
 	HandshakeTimeout time.Duration `json:"handshakeTimeout" jsonschema:"oneof_type=string;integer"`
+	EnableHTTPTunnel bool          `json:"enableHTTPTunnel"` //nolint:tagliatelle
+	ClientCAFile     string        `json:"clientCAFile"`
+	AuthMethod       string        `json:"authMethod" jsonschema:"enum=none,enum=cert,enum=token,enum=plugin"`
+	// AuthTokens maps the identity of the clients to their tokens.
+	AuthTokens          map[string]string `json:"authTokens,omitempty"`
+	ShutdownGracePeriod time.Duration     `json:"shutdownGracePeriod" jsonschema:"oneof_type=string;integer"`
+	IdleTimeout         time.Duration     `json:"idleTimeout" jsonschema:"oneof_type=string;integer"`
+	MaxConnections      int               `json:"maxConnections"`
+	// MaxConnectionsPerIP caps the connections of each client IP address, except for
+	// the IP addresses and CIDR ranges of the allow list.
+	MaxConnectionsPerIP          int           `json:"maxConnectionsPerIP"`                    //nolint:tagliatelle
+	MaxConnectionsPerIPAllowList []string      `json:"maxConnectionsPerIPAllowList,omitempty"` //nolint:tagliatelle
+	TCPFastOpen                  bool          `json:"tcpFastOpen"`
+	TCPFastOpenQueueLength       int           `json:"tcpFastOpenQueueLength"`
+	DSCP                         int           `json:"dscp"`
+	TCPUserTimeout               time.Duration `json:"tcpUserTimeout" jsonschema:"oneof_type=string;integer"`
+	EnableCompression            bool          `json:"enableCompression"`
+	CompressionLevel             string        `json:"compressionLevel" jsonschema:"enum=fastest,enum=default,enum=better,enum=best"`
+
+	// KeepAlive is sent on every tick to the client connections with no traffic since
+	// the previous tick, and KeepAlivePayload is the hex-encoded payload of the raw ones.
+	KeepAlive        string `json:"keepAlive" jsonschema:"enum=none,enum=postgres,enum=raw"`
+	KeepAlivePayload string `json:"keepAlivePayload"`
+
+	// Upstream is the name of the upstream of the server, i.e. of the config group of its
+	// client, pool and proxy, which defaults to the name of the server.
+	Upstream string `json:"upstream"`
+}
+
+// Upstream is a named group of the settings of the server connections: the client with
+// the backends, TLS and protocol of the server connections, their pool and their proxy.
+// The servers reference the upstreams by name, so that they share them.
+type Upstream struct {
+	Client *Client `json:"client"`
+	Pool   *Pool   `json:"pool"`
+	Proxy  *Proxy  `json:"proxy"`
 }
 
 type API struct {
@@ -112,6 +396,10 @@ type API struct {
 	HTTPAddress string `json:"httpAddress"`
 	GRPCAddress string `json:"grpcAddress"`
 	GRPCNetwork string `json:"grpcNetwork" jsonschema:"enum=tcp,enum=udp,enum=unix"`
+
+	// ReservedConnections are the file descriptors kept for the API and the health
+	// checks, which the client connections of the servers can't take.
+	ReservedConnections int `json:"reservedConnections"`
 }
 
 type GlobalConfig struct {
@@ -122,4 +410,10 @@ type GlobalConfig struct {
 	Proxies map[string]*Proxy   `json:"proxies"`
 	Servers map[string]*Server  `json:"servers"`
 	Metrics map[string]*Metrics `json:"metrics"`
+
+	// PluginConfigs holds the config sections of the plugins, keyed by plugin name.
+	PluginConfigs map[string]map[string]any `json:"pluginConfigs,omitempty"`
+
+	// Upstreams are resolved into the clients, pools and proxies of their names on load.
+	Upstreams map[string]*Upstream `json:"upstreams,omitempty"`
 }
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/providers/file"
+	"golang.org/x/exp/maps"
+)
+
+// The sections of the unified config file, which has both the global and the
+// plugins config in a single file.
+const (
+	UnifiedGlobalSection  = "global"
+	UnifiedPluginsSection = "plugins"
+)
+
+var errAmbiguousConfigLayout = errors.New("ambiguous config layout")
+
+// loadConfigFile loads the config file into the given koanf instance. If the config
+// file is a unified config file, only the given section of it is loaded.
+func loadConfigFile(konfig *koanf.Koanf, path, section string) error {
+	fileKoanf := koanf.New(".")
+	if err := fileKoanf.Load(file.Provider(path), Parser(path)); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	unified, err := isUnified(fileKoanf)
+	if err != nil {
+		return err
+	}
+	if unified {
+		fileKoanf = fileKoanf.Cut(section)
+	}
+
+	return konfig.Merge(fileKoanf) //nolint:wrapcheck
+}
+
+// isUnified returns true if the config has a global section. A unified config
+// can only have the global and the plugins sections at the top level.
+func isUnified(konfig *koanf.Koanf) (bool, error) {
+	keys := maps.Keys(konfig.Raw())
+	if !slices.Contains(keys, UnifiedGlobalSection) {
+		return false, nil
+	}
+
+	for _, key := range keys {
+		if key != UnifiedGlobalSection && key != UnifiedPluginsSection {
+			return false, fmt.Errorf(
+				"%w: the unified config file has \"%s\" outside of the \"%s\" and \"%s\" sections",
+				errAmbiguousConfigLayout, key, UnifiedGlobalSection, UnifiedPluginsSection)
+		}
+	}
+	return true, nil
+}
+
+// IsUnifiedConfigFile returns true if the config file is a unified config file,
+// with both the global and the plugins config. A missing file isn't unified.
+func IsUnifiedConfigFile(path string) (bool, *gerr.GatewayDError) {
+	if _, err := os.Stat(path); err != nil {
+		return false, nil
+	}
+
+	konfig := koanf.New(".")
+	if err := konfig.Load(file.Provider(path), Parser(path)); err != nil {
+		return false, gerr.ErrConfigParseError.Wrap(
+			fmt.Errorf("failed to load configuration: %w", err))
+	}
+
+	unified, err := isUnified(konfig)
+	if err != nil {
+		return false, gerr.ErrConfigParseError.Wrap(err)
+	}
+	return unified, nil
+}
+
+// ValidateConfigLayout checks that either a single unified config file or separate
+// global and plugins config files are used, but not both: the global and the plugins
+// config files must either be the same unified config file or both be plain ones.
+func ValidateConfigLayout(globalConfigFile, pluginConfigFile string) *gerr.GatewayDError {
+	globalUnified, err := IsUnifiedConfigFile(globalConfigFile)
+	if err != nil {
+		return err
+	}
+
+	if globalConfigFile == pluginConfigFile {
+		if !globalUnified {
+			return gerr.ErrValidationFailed.Wrap(fmt.Errorf(
+				"%w: \"%s\" is used for both the global and the plugins config, but it has no \"%s\" section",
+				errAmbiguousConfigLayout, globalConfigFile, UnifiedGlobalSection))
+		}
+		return nil
+	}
+
+	pluginUnified, err := IsUnifiedConfigFile(pluginConfigFile)
+	if err != nil {
+		return err
+	}
+	if globalUnified || pluginUnified {
+		return gerr.ErrValidationFailed.Wrap(fmt.Errorf(
+			"%w: either use the unified config file \"%s\" alone or separate global and plugins config files",
+			errAmbiguousConfigLayout, If(globalUnified, globalConfigFile, pluginConfigFile)))
+	}
+	return nil
+}
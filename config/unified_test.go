@@ -0,0 +1,81 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeUnifiedConfig writes a unified config file with the global and plugins config
+// files of the project as its sections, and the extra lines at the end.
+func writeUnifiedConfig(t *testing.T, extra string) string {
+	t.Helper()
+
+	var unified strings.Builder
+	for section, path := range map[string]string{
+		UnifiedGlobalSection:  parentDir + GlobalConfigFilename,
+		UnifiedPluginsSection: parentDir + PluginsConfigFilename,
+	} {
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		unified.WriteString(section + ":\n")
+		for _, line := range strings.Split(string(contents), "\n") {
+			unified.WriteString("  " + line + "\n")
+		}
+	}
+	unified.WriteString(extra)
+
+	path := filepath.Join(t.TempDir(), "gatewayd.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(unified.String()), 0o600))
+	return path
+}
+
+// TestInitConfigUnified tests loading the global and plugins config from a unified config file.
+func TestInitConfigUnified(t *testing.T) {
+	ctx := context.Background()
+	path := writeUnifiedConfig(t, "")
+
+	unified, err := IsUnifiedConfigFile(path)
+	require.Nil(t, err)
+	assert.True(t, unified)
+	require.Nil(t, ValidateConfigLayout(path, path))
+
+	config := NewConfig(ctx, Config{GlobalConfigFile: path, PluginConfigFile: path})
+	require.Nil(t, config.InitConfig(ctx))
+	assert.Contains(t, config.Global.Servers, Default)
+	assert.Len(t, config.Plugin.Plugins, 1)
+	assert.Equal(t, string(Strict), config.Plugin.CompatibilityPolicy)
+	assert.False(t, config.GlobalKoanf.Exists(UnifiedGlobalSection))
+	assert.False(t, config.PluginKoanf.Exists(UnifiedPluginsSection+".plugins"))
+}
+
+// TestValidateConfigLayout tests rejecting the ambiguous config layouts.
+func TestValidateConfigLayout(t *testing.T) {
+	globalConfigFile := parentDir + GlobalConfigFilename
+	pluginConfigFile := parentDir + PluginsConfigFilename
+	unifiedConfigFile := writeUnifiedConfig(t, "")
+
+	unified, err := IsUnifiedConfigFile(globalConfigFile)
+	require.Nil(t, err)
+	assert.False(t, unified)
+
+	// The two-file layout.
+	assert.Nil(t, ValidateConfigLayout(globalConfigFile, pluginConfigFile))
+	// The unified config file can't be used with another config file.
+	assert.NotNil(t, ValidateConfigLayout(unifiedConfigFile, pluginConfigFile))
+	assert.NotNil(t, ValidateConfigLayout(globalConfigFile, unifiedConfigFile))
+	// A plain config file can't be used for both.
+	assert.NotNil(t, ValidateConfigLayout(globalConfigFile, globalConfigFile))
+
+	// The unified config file can't have anything outside of its sections.
+	mixed := writeUnifiedConfig(t, "loggers:\n  default:\n    level: debug\n")
+	_, err = IsUnifiedConfigFile(mixed)
+	assert.NotNil(t, err)
+	assert.NotNil(t, ValidateConfigLayout(mixed, mixed))
+}
@@ -0,0 +1,90 @@
+//nolint:lll
+package errors
+
+// Info is the stable name and the default message of an error code.
+type Info struct {
+	Name    string
+	Message string
+}
+
+// registry maps the error codes to their names and messages, so that the logs,
+// the API and the hooks report the same error identifiers. Both the numeric
+// codes and the names are stable, so new codes must be appended to the end.
+var registry = map[ErrCode]Info{
+	ErrCodeUnknown:                           {"UNKNOWN", "unknown error"},
+	ErrCodeNilContext:                        {"NIL_CONTEXT", "context is nil"},
+	ErrCodeClientNotFound:                    {"CLIENT_NOT_FOUND", "client not found"},
+	ErrCodeClientNotConnected:                {"CLIENT_NOT_CONNECTED", "client is not connected"},
+	ErrCodeClientConnectionFailed:            {"CLIENT_CONNECTION_FAILED", "failed to create a new connection"},
+	ErrCodeNetworkNotSupported:               {"NETWORK_NOT_SUPPORTED", "network is not supported"},
+	ErrCodeResolveFailed:                     {"RESOLVE_FAILED", "failed to resolve address"},
+	ErrCodePoolExhausted:                     {"POOL_EXHAUSTED", "pool is exhausted"},
+	ErrCodePluginNotFound:                    {"PLUGIN_NOT_FOUND", "plugin not found"},
+	ErrCodePluginNotReady:                    {"PLUGIN_NOT_READY", "plugin is not ready"},
+	ErrCodeStartPluginFailed:                 {"START_PLUGIN_FAILED", "failed to start plugin"},
+	ErrCodeGetRPCClientFailed:                {"GET_RPC_CLIENT_FAILED", "failed to get RPC client"},
+	ErrCodeDispensePluginFailed:              {"DISPENSE_PLUGIN_FAILED", "failed to dispense plugin"},
+	ErrCodePluginMetricsMergeFailed:          {"PLUGIN_METRICS_MERGE_FAILED", "failed to merge plugin metrics"},
+	ErrCodePluginPingFailed:                  {"PLUGIN_PING_FAILED", "failed to ping plugin"},
+	ErrCodePluginScaffoldFailed:              {"PLUGIN_SCAFFOLD_FAILED", "failed to scaffold plugin"},
+	ErrCopyEmbeddedFilesFailed:               {"COPY_EMBEDDED_FILES_FAILED", "failed to copy embedded files"},
+	ErrCodePluginScaffoldInputFileReadFailed: {"PLUGIN_SCAFFOLD_INPUT_FILE_READ_FAILED", "failed to read plugin scaffold input file"},
+	ErrCodeClientReceiveFailed:               {"CLIENT_RECEIVE_FAILED", "couldn't receive data from the server"},
+	ErrCodeClientSendFailed:                  {"CLIENT_SEND_FAILED", "couldn't send data to the server"},
+	ErrCodeServerReceiveFailed:               {"SERVER_RECEIVE_FAILED", "couldn't receive data from the client"},
+	ErrCodeServerSendFailed:                  {"SERVER_SEND_FAILED", "couldn't send data to the client"},
+	ErrCodeServerListenFailed:                {"SERVER_LISTEN_FAILED", "couldn't listen on the server"},
+	ErrCodeSplitHostPortFailed:               {"SPLIT_HOST_PORT_FAILED", "failed to split host:port"},
+	ErrCodeAcceptFailed:                      {"ACCEPT_FAILED", "failed to accept connection"},
+	ErrCodeGetTLSConfigFailed:                {"GET_TLS_CONFIG_FAILED", "failed to get TLS config"},
+	ErrCodeTLSDisabled:                       {"TLS_DISABLED", "TLS is disabled"},
+	ErrCodeUpgradeToTLSFailed:                {"UPGRADE_TO_TLS_FAILED", "failed to upgrade to TLS"},
+	ErrCodeReadFailed:                        {"READ_FAILED", "failed to read from the client"},
+	ErrCodePutFailed:                         {"PUT_FAILED", "failed to put in the pool"},
+	ErrCodeNilPointer:                        {"NIL_POINTER", "nil pointer"},
+	ErrCodeCastFailed:                        {"CAST_FAILED", "failed to cast"},
+	ErrCodeHookReturnedError:                 {"HOOK_RETURNED_ERROR", "hook returned an error"},
+	ErrCodeHookTerminatedConnection:          {"HOOK_TERMINATED_CONNECTION", "hook terminated connection"},
+	ErrCodeFileNotFound:                      {"FILE_NOT_FOUND", "file not found"},
+	ErrCodeFileOpenFailed:                    {"FILE_OPEN_FAILED", "failed to open the file"},
+	ErrCodeFileReadFailed:                    {"FILE_READ_FAILED", "failed to read the file"},
+	ErrCodeDuplicateMetricsCollector:         {"DUPLICATE_METRICS_COLLECTOR", "duplicate metrics collector"},
+	ErrCodeInvalidMetricType:                 {"INVALID_METRIC_TYPE", "invalid metric type"},
+	ErrCodeValidationFailed:                  {"VALIDATION_FAILED", "validation failed"},
+	ErrCodeLintingFailed:                     {"LINTING_FAILED", "linting failed"},
+	ErrCodeExtractFailed:                     {"EXTRACT_FAILED", "failed to extract the archive"},
+	ErrCodeDownloadFailed:                    {"DOWNLOAD_FAILED", "failed to download the file"},
+	ErrCodeKeyNotFound:                       {"KEY_NOT_FOUND", "action does not exist"},
+	ErrCodeRunError:                          {"RUN_ERROR", "error running action"},
+	ErrCodeAsyncAction:                       {"ASYNC_ACTION", "async action"},
+	ErrCodeEvalError:                         {"EVAL_ERROR", "error evaluating expression"},
+	ErrCodeMsgEncodeError:                    {"MSG_ENCODE_ERROR", "error encoding message"},
+	ErrCodeConfigParseError:                  {"CONFIG_PARSE_ERROR", "error parsing config"},
+	ErrCodePublishAsyncAction:                {"PUBLISH_ASYNC_ACTION", "error publishing async action"},
+	ErrCodeAuthFailed:                        {"AUTH_FAILED", "client authentication failed"},
+	ErrCodeHookTimeout:                       {"HOOK_TIMEOUT", "timed out running the hooks"},
+	ErrCodeCancelRequest:                     {"CANCEL_REQUEST", "connection is closed after the cancel request"},
+	ErrCodeActionNotMatched:                  {"ACTION_NOT_MATCHED", "no matching action"},
+	ErrCodePolicyNotMatched:                  {"POLICY_NOT_MATCHED", "no matching policy"},
+	ErrCodeActionTimeout:                     {"ACTION_TIMEOUT", "timeout running action"},
+	ErrCodeMetricsPushFailed:                 {"METRICS_PUSH_FAILED", "failed to push metrics"},
+	ErrCodeSessionAuthFailed:                 {"SESSION_AUTH_FAILED", "failed to authenticate the server session"},
+	ErrCodeSessionResetFailed:                {"SESSION_RESET_FAILED", "failed to reset the server session"},
+	ErrCodeEmptyRequest:                      {"EMPTY_REQUEST", "client sent an empty request"},
+	ErrCodeCompressionFailed:                 {"COMPRESSION_FAILED", "failed to enable the compression of the connection"},
+	ErrCodeNoHealthyUpstream:                 {"NO_HEALTHY_UPSTREAM", "no healthy upstream"},
+}
+
+// Lookup returns the name and the default message of the error code.
+func Lookup(code ErrCode) (Info, bool) {
+	info, ok := registry[code]
+	return info, ok
+}
+
+// String returns the stable name of the error code.
+func (c ErrCode) String() string {
+	if info, ok := registry[c]; ok {
+		return info.Name
+	}
+	return registry[ErrCodeUnknown].Name
+}
@@ -0,0 +1,34 @@
+package errors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRegistry tests that every error code is registered with a unique name.
+func TestRegistry(t *testing.T) {
+	names := map[string]ErrCode{}
+	for code := ErrCodeUnknown; code < errCodeLast; code++ {
+		info, ok := Lookup(code)
+		assert.True(t, ok, "error code %d is not registered", code)
+		assert.NotEmpty(t, info.Message)
+		assert.NotContains(t, names, info.Name)
+		names[info.Name] = code
+	}
+	assert.Len(t, registry, len(names))
+
+	_, ok := Lookup(errCodeLast)
+	assert.False(t, ok)
+	assert.Equal(t, "UNKNOWN", (errCodeLast).String())
+}
+
+// TestGatewayDErrorCode tests the code and the default message of the errors.
+func TestGatewayDErrorCode(t *testing.T) {
+	assert.Equal(t, ErrCodeClientNotFound, ErrClientNotFound.Code())
+	assert.Equal(t, "CLIENT_NOT_FOUND", ErrClientNotFound.Code().String())
+	assert.Equal(t, "client not found", ErrClientNotFound.Message)
+
+	err := NewGatewayDError(errCodeLast)
+	assert.Equal(t, "unknown error", err.Error())
+}
@@ -53,155 +53,122 @@ const (
 	ErrCodeMsgEncodeError
 	ErrCodeConfigParseError
 	ErrCodePublishAsyncAction
+	ErrCodeAuthFailed
+	ErrCodeHookTimeout
+	ErrCodeCancelRequest
+	ErrCodeActionNotMatched
+	ErrCodePolicyNotMatched
+	ErrCodeActionTimeout
+	ErrCodeMetricsPushFailed
+	ErrCodeSessionAuthFailed
+	ErrCodeSessionResetFailed
+	ErrCodeEmptyRequest
+	ErrCodeCompressionFailed
+	ErrCodeNoHealthyUpstream
+
+	// errCodeLast is past the last error code, so the new codes go above it.
+	errCodeLast
 )
 
 var (
-	ErrClientNotFound = &GatewayDError{
-		ErrCodeClientNotFound, "client not found", nil,
-	}
-	ErrNilContext = &GatewayDError{
-		ErrCodeNilContext, "context is nil", nil,
-	}
-	ErrClientNotConnected = &GatewayDError{
-		ErrCodeClientNotConnected, "client is not connected", nil,
-	}
-	ErrClientConnectionFailed = &GatewayDError{
-		ErrCodeClientConnectionFailed, "failed to create a new connection", nil,
-	}
-	ErrNetworkNotSupported = &GatewayDError{
-		ErrCodeNetworkNotSupported, "network is not supported", nil,
-	}
-	ErrResolveFailed = &GatewayDError{
-		ErrCodeResolveFailed, "failed to resolve address", nil,
-	}
-	ErrPoolExhausted = &GatewayDError{
-		ErrCodePoolExhausted, "pool is exhausted", nil,
-	}
-
-	ErrPluginNotReady = &GatewayDError{
-		ErrCodePluginNotReady, "plugin is not ready", nil,
-	}
-	ErrFailedToStartPlugin = &GatewayDError{
-		ErrCodeStartPluginFailed, "failed to start plugin", nil,
-	}
-	ErrFailedToGetRPCClient = &GatewayDError{
-		ErrCodeGetRPCClientFailed, "failed to get RPC client", nil,
-	}
-	ErrFailedToDispensePlugin = &GatewayDError{
-		ErrCodeDispensePluginFailed, "failed to dispense plugin", nil,
-	}
-	ErrFailedToMergePluginMetrics = &GatewayDError{
-		ErrCodePluginMetricsMergeFailed, "failed to merge plugin metrics", nil,
-	}
-	ErrFailedToPingPlugin = &GatewayDError{
-		ErrCodePluginPingFailed, "failed to ping plugin", nil,
-	}
-	ErrFailedToScaffoldPlugin = &GatewayDError{
-		ErrCodePluginScaffoldFailed, "failed to scaffold plugin", nil,
-	}
-	ErrFailedToCopyEmbeddedFiles = &GatewayDError{
-		ErrCopyEmbeddedFilesFailed, "failed to copy embedded files", nil,
-	}
-	ErrFailedToReadPluginScaffoldInputFile = &GatewayDError{
-		ErrCodePluginScaffoldInputFileReadFailed, "failed to read plugin scaffold input file", nil,
-	}
-
-	ErrClientReceiveFailed = &GatewayDError{
-		ErrCodeClientReceiveFailed, "couldn't receive data from the server", nil,
-	}
-	ErrClientSendFailed = &GatewayDError{
-		ErrCodeClientSendFailed, "couldn't send data to the server", nil,
-	}
-
-	ErrServerSendFailed = &GatewayDError{
-		ErrCodeServerSendFailed, "couldn't send data to the client", nil,
-	}
-	ErrServerListenFailed = &GatewayDError{
-		ErrCodeServerListenFailed, "couldn't listen on the server", nil,
-	}
-	ErrSplitHostPortFailed = &GatewayDError{
-		ErrCodeSplitHostPortFailed, "failed to split host:port", nil,
-	}
-	ErrAcceptFailed = &GatewayDError{
-		ErrCodeAcceptFailed, "failed to accept connection", nil,
-	}
-	ErrGetTLSConfigFailed = &GatewayDError{
-		ErrCodeGetTLSConfigFailed, "failed to get TLS config", nil,
-	}
-	ErrUpgradeToTLSFailed = &GatewayDError{
-		ErrCodeUpgradeToTLSFailed, "failed to upgrade to TLS", nil,
-	}
-
-	ErrReadFailed = &GatewayDError{
-		ErrCodeReadFailed, "failed to read from the client", nil,
-	}
-
-	ErrNilPointer = &GatewayDError{
-		ErrCodeNilPointer, "nil pointer", nil,
-	}
-
-	ErrCastFailed = &GatewayDError{
-		ErrCodeCastFailed, "failed to cast", nil,
-	}
-
-	ErrHookTerminatedConnection = &GatewayDError{
-		ErrCodeHookTerminatedConnection, "hook terminated connection", nil,
-	}
-
-	ErrValidationFailed = &GatewayDError{
-		ErrCodeValidationFailed, "validation failed", nil,
-	}
-	ErrLintingFailed = &GatewayDError{
-		ErrCodeLintingFailed, "linting failed", nil,
-	}
-
-	ErrExtractFailed = &GatewayDError{
-		ErrCodeExtractFailed, "failed to extract the archive", nil,
-	}
-	ErrDownloadFailed = &GatewayDError{
-		ErrCodeDownloadFailed, "failed to download the file", nil,
-	}
-
-	ErrActionNotExist = &GatewayDError{
-		ErrCodeKeyNotFound, "action does not exist", nil,
-	}
-	ErrRunningAction = &GatewayDError{
-		ErrCodeRunError, "error running action", nil,
-	}
-	ErrAsyncAction = &GatewayDError{
-		ErrCodeAsyncAction, "async action", nil,
-	}
-	ErrRunningActionTimeout = &GatewayDError{
-		ErrCodeRunError, "timeout running action", nil,
-	}
-	ErrActionNotMatched = &GatewayDError{
-		ErrCodeKeyNotFound, "no matching action", nil,
-	}
-	ErrPolicyNotMatched = &GatewayDError{
-		ErrCodeKeyNotFound, "no matching policy", nil,
-	}
-	ErrEvalError = &GatewayDError{
-		ErrCodeEvalError, "error evaluating expression", nil,
-	}
-	ErrMsgEncodeError = &GatewayDError{
-		ErrCodeMsgEncodeError, "error encoding message", nil,
-	}
-
-	ErrConfigParseError = &GatewayDError{
-		ErrCodeConfigParseError, "error parsing config", nil,
-	}
-	ErrPublishingAsyncAction = &GatewayDError{
-		ErrCodePublishAsyncAction, "error publishing async action", nil,
-	}
+	ErrClientNotFound         = NewGatewayDError(ErrCodeClientNotFound)
+	ErrNilContext             = NewGatewayDError(ErrCodeNilContext)
+	ErrClientNotConnected     = NewGatewayDError(ErrCodeClientNotConnected)
+	ErrClientConnectionFailed = NewGatewayDError(ErrCodeClientConnectionFailed)
+	ErrNetworkNotSupported    = NewGatewayDError(ErrCodeNetworkNotSupported)
+	ErrResolveFailed          = NewGatewayDError(ErrCodeResolveFailed)
+	ErrPoolExhausted          = NewGatewayDError(ErrCodePoolExhausted)
+
+	ErrPluginNotReady                      = NewGatewayDError(ErrCodePluginNotReady)
+	ErrFailedToStartPlugin                 = NewGatewayDError(ErrCodeStartPluginFailed)
+	ErrFailedToGetRPCClient                = NewGatewayDError(ErrCodeGetRPCClientFailed)
+	ErrFailedToDispensePlugin              = NewGatewayDError(ErrCodeDispensePluginFailed)
+	ErrFailedToMergePluginMetrics          = NewGatewayDError(ErrCodePluginMetricsMergeFailed)
+	ErrFailedToPingPlugin                  = NewGatewayDError(ErrCodePluginPingFailed)
+	ErrFailedToScaffoldPlugin              = NewGatewayDError(ErrCodePluginScaffoldFailed)
+	ErrFailedToCopyEmbeddedFiles           = NewGatewayDError(ErrCopyEmbeddedFilesFailed)
+	ErrFailedToReadPluginScaffoldInputFile = NewGatewayDError(ErrCodePluginScaffoldInputFileReadFailed)
+
+	ErrClientReceiveFailed = NewGatewayDError(ErrCodeClientReceiveFailed)
+	ErrClientSendFailed    = NewGatewayDError(ErrCodeClientSendFailed)
+
+	ErrServerSendFailed    = NewGatewayDError(ErrCodeServerSendFailed)
+	ErrServerListenFailed  = NewGatewayDError(ErrCodeServerListenFailed)
+	ErrSplitHostPortFailed = NewGatewayDError(ErrCodeSplitHostPortFailed)
+	ErrAcceptFailed        = NewGatewayDError(ErrCodeAcceptFailed)
+	ErrGetTLSConfigFailed  = NewGatewayDError(ErrCodeGetTLSConfigFailed)
+	ErrUpgradeToTLSFailed  = NewGatewayDError(ErrCodeUpgradeToTLSFailed)
+
+	ErrReadFailed = NewGatewayDError(ErrCodeReadFailed)
+
+	ErrNilPointer = NewGatewayDError(ErrCodeNilPointer)
+
+	ErrCastFailed = NewGatewayDError(ErrCodeCastFailed)
+
+	ErrHookTerminatedConnection = NewGatewayDError(ErrCodeHookTerminatedConnection)
+
+	ErrValidationFailed = NewGatewayDError(ErrCodeValidationFailed)
+	ErrLintingFailed    = NewGatewayDError(ErrCodeLintingFailed)
+
+	ErrExtractFailed  = NewGatewayDError(ErrCodeExtractFailed)
+	ErrDownloadFailed = NewGatewayDError(ErrCodeDownloadFailed)
+
+	ErrActionNotExist       = NewGatewayDError(ErrCodeKeyNotFound)
+	ErrRunningAction        = NewGatewayDError(ErrCodeRunError)
+	ErrAsyncAction          = NewGatewayDError(ErrCodeAsyncAction)
+	ErrRunningActionTimeout = NewGatewayDError(ErrCodeActionTimeout)
+	ErrActionNotMatched     = NewGatewayDError(ErrCodeActionNotMatched)
+	ErrPolicyNotMatched     = NewGatewayDError(ErrCodePolicyNotMatched)
+	ErrEvalError            = NewGatewayDError(ErrCodeEvalError)
+	ErrMsgEncodeError       = NewGatewayDError(ErrCodeMsgEncodeError)
+
+	ErrConfigParseError      = NewGatewayDError(ErrCodeConfigParseError)
+	ErrPublishingAsyncAction = NewGatewayDError(ErrCodePublishAsyncAction)
+
+	ErrAuthFailed = NewGatewayDError(ErrCodeAuthFailed)
+
+	ErrHookTimeout = NewGatewayDError(ErrCodeHookTimeout)
+
+	ErrCancelRequest = NewGatewayDError(ErrCodeCancelRequest)
+
+	ErrFailedToPushMetrics = NewGatewayDError(ErrCodeMetricsPushFailed)
+
+	ErrSessionAuthFailed  = NewGatewayDError(ErrCodeSessionAuthFailed)
+	ErrSessionResetFailed = NewGatewayDError(ErrCodeSessionResetFailed)
+
+	ErrEmptyRequest = NewGatewayDError(ErrCodeEmptyRequest)
+
+	ErrCompressionFailed = NewGatewayDError(ErrCodeCompressionFailed)
+
+	ErrNoHealthyUpstream = NewGatewayDError(ErrCodeNoHealthyUpstream)
 
 	// Unwrapped errors.
 	ErrLoggerRequired = errors.New("terminate action requires a logger parameter")
 )
 
+// Exit codes of GatewayD, one for each startup failure. The codes are stable,
+// so that they can be relied on by the scripts and the service managers:
+//
+//	0  Stopped gracefully
+//	1  Failed to create a client (connect to the database)
+//	2  Failed to initialize (fill) the pool
+//	3  Failed to start the server
+//	4  Failed to start the tracer
+//	5  Failed to create the act registry
+//	6  Failed to load the global or plugin configuration
+//	7  The configuration is not valid, either on linting or after being modified by the plugins
+//	8  Failed to bind the listener of the server
+//	9  Failed to start Sentry
+//
+// The plugins that fail to load are skipped, so they don't stop GatewayD.
 const (
 	FailedToCreateClient      = 1
 	FailedToInitializePool    = 2
 	FailedToStartServer       = 3
 	FailedToStartTracer       = 4
 	FailedToCreateActRegistry = 5
+	FailedToLoadConfig        = 6
+	FailedToValidateConfig    = 7
+	FailedToListen            = 8
+	FailedToStartSentry       = 9
 )
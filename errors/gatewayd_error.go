@@ -5,11 +5,25 @@ import "fmt"
 type ErrCode uint32
 
 type GatewayDError struct {
-	Code          ErrCode
+	code          ErrCode
 	Message       string
 	OriginalError error
 }
 
+// NewGatewayDError creates a new GatewayDError with the default message of the error code.
+func NewGatewayDError(code ErrCode) *GatewayDError {
+	info, ok := Lookup(code)
+	if !ok {
+		info, _ = Lookup(ErrCodeUnknown)
+	}
+	return &GatewayDError{code, info.Message, nil}
+}
+
+// Code returns the error code of the GatewayDError.
+func (e *GatewayDError) Code() ErrCode {
+	return e.code
+}
+
 // Error returns the error message of the GatewayDError.
 func (e *GatewayDError) Error() string {
 	if e.OriginalError == nil {
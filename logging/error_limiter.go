@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ErrorLimiter is a hook of the logger that collapses the repeated errors, e.g. on
+// every request while the database is down, so that the logs stay usable. The first
+// occurrence of an error is logged in full, and the next ones with the same message
+// within the window are dropped and counted, and logged as a summary at the end of
+// the window.
+type ErrorLimiter struct {
+	logger zerolog.Logger
+	window time.Duration
+
+	mu          sync.Mutex
+	occurrences map[string]int
+}
+
+var _ zerolog.Hook = (*ErrorLimiter)(nil)
+
+// NewErrorLimiter creates a new error limiter with the window. The summaries are
+// written to the logger, which shouldn't have the hook itself.
+func NewErrorLimiter(logger zerolog.Logger, window time.Duration) *ErrorLimiter {
+	return &ErrorLimiter{
+		logger:      logger,
+		window:      window,
+		occurrences: map[string]int{},
+	}
+}
+
+// Run drops the error if the same error is already logged within the window.
+func (l *ErrorLimiter) Run(event *zerolog.Event, level zerolog.Level, msg string) {
+	if level < zerolog.ErrorLevel || level > zerolog.FatalLevel || msg == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if occurrences, ok := l.occurrences[msg]; ok {
+		l.occurrences[msg] = occurrences + 1
+		event.Discard()
+		return
+	}
+
+	l.occurrences[msg] = 1
+	time.AfterFunc(l.window, func() { l.summarize(msg) })
+}
+
+// summarize logs the number of the occurrences of the error at the end of the window,
+// if it's repeated, and lets the next occurrence be logged in full.
+func (l *ErrorLimiter) summarize(msg string) {
+	l.mu.Lock()
+	occurrences := l.occurrences[msg]
+	delete(l.occurrences, msg)
+	l.mu.Unlock()
+
+	if occurrences > 1 {
+		l.logger.Error().Str("error", msg).Int("occurrences", occurrences).Msgf(
+			"%s: %d occurrences in last %s", msg, occurrences, l.window)
+	}
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// syncBuffer is a buffer that the summaries can be written to concurrently.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(data) //nolint:wrapcheck
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
+}
+
+// TestErrorLimiter tests collapsing the repeated errors into a summary.
+func TestErrorLimiter(t *testing.T) {
+	out := &syncBuffer{}
+	logger := zerolog.New(out)
+	logger = logger.Hook(NewErrorLimiter(logger, 50*time.Millisecond))
+
+	for range 5 {
+		logger.Error().Str("remote", "127.0.0.1:5432").Msg("Error sending request to database")
+		logger.Info().Msg("Received data from client")
+	}
+	logger.Error().Msg("Error writing to client")
+
+	// The first occurrence is logged in full, and the other errors are different.
+	assert.Equal(t, 1, strings.Count(out.String(), "Error sending request to database"))
+	assert.Contains(t, out.String(), `"remote":"127.0.0.1:5432"`)
+	assert.Equal(t, 5, strings.Count(out.String(), "Received data from client"))
+	assert.Equal(t, 1, strings.Count(out.String(), "Error writing to client"))
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(
+			out.String(), "Error sending request to database: 5 occurrences in last 50ms")
+	}, time.Second, 10*time.Millisecond)
+	// The errors that aren't repeated aren't summarized.
+	assert.NotContains(t, out.String(), "Error writing to client: ")
+
+	// The next occurrence after the window is logged in full again, after the
+	// summary that has the error twice, as its field and in its message.
+	logger.Error().Msg("Error sending request to database")
+	assert.Equal(t, 4, strings.Count(out.String(), "Error sending request to database"))
+}
@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/rs/zerolog"
+)
+
+// LogfmtWriter renders the JSON events of the logger as logfmt lines, i.e.
+// key=value pairs separated by spaces, for the log pipelines that expect it.
+// The time, level and message come first, and the other fields follow in the
+// order of their keys.
+type LogfmtWriter struct {
+	Out io.Writer
+}
+
+var _ zerolog.LevelWriter = (*LogfmtWriter)(nil)
+
+// NewLogfmtWriter creates a new logfmt writer that writes to the output.
+func NewLogfmtWriter(out io.Writer) *LogfmtWriter {
+	return &LogfmtWriter{Out: out}
+}
+
+// Write renders the event as a logfmt line and writes it to the output.
+func (w *LogfmtWriter) Write(p []byte) (int, error) {
+	line, err := formatLogfmt(p)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.Out.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteLevel renders the event as a logfmt line and writes it to the output with
+// its level, if the output is a level writer, e.g. rsyslog.
+func (w *LogfmtWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	levelWriter, ok := w.Out.(zerolog.LevelWriter)
+	if !ok {
+		return w.Write(p)
+	}
+
+	line, err := formatLogfmt(p)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := levelWriter.WriteLevel(level, line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// formatLogfmt renders the JSON event as a logfmt line.
+func formatLogfmt(p []byte) ([]byte, error) {
+	var event map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if err := decoder.Decode(&event); err != nil {
+		return nil, fmt.Errorf("cannot decode event: %w", err)
+	}
+
+	keys := make([]string, 0, len(event))
+	for key := range event {
+		switch key {
+		case zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	keys = append(
+		[]string{zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName},
+		keys...)
+
+	line := &bytes.Buffer{}
+	for _, key := range keys {
+		value, ok := event[key]
+		if !ok {
+			continue
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(key)
+		line.WriteByte('=')
+		line.WriteString(logfmtValue(value))
+	}
+	line.WriteByte('\n')
+	return line.Bytes(), nil
+}
+
+// logfmtValue renders the value of a field, quoting and escaping it if it's empty
+// or contains spaces, quotes, equal signs or control characters. The objects and
+// arrays are rendered as JSON.
+func logfmtValue(value any) string {
+	var text string
+	switch value := value.(type) {
+	case string:
+		text = value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	case nil:
+		return "null"
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return strconv.Quote(fmt.Sprint(value))
+		}
+		text = string(encoded)
+	}
+
+	if text == "" || strings.IndexFunc(text, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar || !unicode.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(text)
+	}
+	return text
+}
+
+// formatWriter wraps the output in the writer of the log format. The default
+// format writes the JSON events as is.
+func formatWriter(out io.Writer, format config.LogFormat, cfg LoggerConfig) io.Writer {
+	switch format {
+	case config.LogfmtFormat:
+		return NewLogfmtWriter(out)
+	case config.ConsoleFormat:
+		return zerolog.ConsoleWriter{
+			Out:        out,
+			TimeFormat: cfg.ConsoleTimeFormat,
+			NoColor:    cfg.NoColor,
+		}
+	case config.DefaultFormat, config.JSONFormat:
+		return out
+	default:
+		return out
+	}
+}
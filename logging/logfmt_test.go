@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLogfmtWriter tests rendering the events as logfmt lines, with the time, level
+// and message first, and the values with spaces, quotes or equal signs escaped.
+func TestLogfmtWriter(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	out := &bytes.Buffer{}
+	logger := zerolog.New(NewLogfmtWriter(out))
+
+	logger.Error().
+		Str("query", `SELECT "id" FROM users WHERE name = 'x'`).
+		Str("client", "127.0.0.1:5432").
+		Str("empty", "").
+		Str("multiline", "a\nb").
+		Int("count", 3).
+		Bool("ok", false).
+		Strs("tags", []string{"a", "b"}).
+		Msg("Failed to run the query")
+
+	assert.Equal(t,
+		`level=error message="Failed to run the query" client=127.0.0.1:5432 count=3 empty="" `+
+			`multiline="a\nb" ok=false query="SELECT \"id\" FROM users WHERE name = 'x'" `+
+			`tags="[\"a\",\"b\"]"`+"\n",
+		out.String())
+
+	_, err := NewLogfmtWriter(out).Write([]byte("not json"))
+	assert.Error(t, err)
+}
+
+// TestNewLogger_Logfmt tests choosing the logfmt format for an output.
+func TestNewLogger_Logfmt(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewLogger(
+		context.Background(),
+		LoggerConfig{
+			Output:     []config.LogOutput{config.Console},
+			Format:     map[config.LogOutput]config.LogFormat{config.Console: config.LogfmtFormat},
+			ConsoleOut: out,
+			Level:      zerolog.DebugLevel,
+			TimeFormat: zerolog.TimeFormatUnix,
+			Name:       "default",
+		},
+	)
+
+	logger.Info().Str("key", "value with spaces").Msg("This is an info")
+	got := out.String()
+	require.Regexp(t, `^time=\d+ level=info `, got)
+	assert.Contains(t, got, `message="This is an info" group=default key="value with spaces"`)
+}
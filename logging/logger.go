@@ -9,6 +9,7 @@ import (
 	"log"
 	"log/syslog"
 	"os"
+	"time"
 
 	"github.com/gatewayd-io/gatewayd/config"
 	"github.com/rs/zerolog"
@@ -28,6 +29,10 @@ type LoggerConfig struct {
 	RSyslogAddress string
 	SyslogPriority syslog.Priority
 
+	// Format is the format of the logs of each output. The outputs without one
+	// use the console format for the console output and JSON for the others.
+	Format map[config.LogOutput]config.LogFormat
+
 	// File output configuration.
 	FileName   string
 	MaxSize    int
@@ -41,6 +46,10 @@ type LoggerConfig struct {
 
 	// group name
 	Name string
+
+	// ErrorLogWindow collapses the repeated errors within it into a summary,
+	// or zero to log every error.
+	ErrorLogWindow time.Duration
 }
 
 // NewLogger creates a new logger with the given configuration.
@@ -53,11 +62,12 @@ func NewLogger(ctx context.Context, cfg LoggerConfig) zerolog.Logger {
 		consoleOut = cfg.ConsoleOut
 	}
 
-	consoleWriter := zerolog.ConsoleWriter{
-		Out:        consoleOut,
-		TimeFormat: cfg.ConsoleTimeFormat,
-		NoColor:    cfg.NoColor,
+	// The console output is human-readable, unless another format is set for it.
+	consoleFormat := cfg.Format[config.Console]
+	if consoleFormat == config.DefaultFormat {
+		consoleFormat = config.ConsoleFormat
 	}
+	consoleWriter := formatWriter(consoleOut, consoleFormat, cfg)
 
 	var outputs []io.Writer
 	for _, out := range cfg.Output {
@@ -65,19 +75,19 @@ func NewLogger(ctx context.Context, cfg LoggerConfig) zerolog.Logger {
 		case config.Console:
 			outputs = append(outputs, consoleWriter)
 		case config.Stdout:
-			outputs = append(outputs, os.Stdout)
+			outputs = append(outputs, formatWriter(os.Stdout, cfg.Format[out], cfg))
 		case config.Stderr:
-			outputs = append(outputs, os.Stderr)
+			outputs = append(outputs, formatWriter(os.Stderr, cfg.Format[out], cfg))
 		case config.File:
 			outputs = append(
-				outputs, &lumberjack.Logger{
+				outputs, formatWriter(&lumberjack.Logger{
 					Filename:   cfg.FileName,
 					MaxSize:    cfg.MaxSize,
 					MaxBackups: cfg.MaxBackups,
 					MaxAge:     cfg.MaxAge,
 					Compress:   cfg.Compress,
 					LocalTime:  cfg.LocalTime,
-				},
+				}, cfg.Format[out], cfg),
 			)
 		case config.Syslog:
 			syslogWriter, err := syslog.New(cfg.SyslogPriority, config.DefaultSyslogTag)
@@ -86,7 +96,7 @@ func NewLogger(ctx context.Context, cfg LoggerConfig) zerolog.Logger {
 				span.End()
 				log.Fatal(err)
 			}
-			outputs = append(outputs, syslogWriter)
+			outputs = append(outputs, formatWriter(syslogWriter, cfg.Format[out], cfg))
 		case config.RSyslog:
 			// TODO: Add support for TLS.
 			// See: https://github.com/RackSec/srslog (deprecated)
@@ -95,7 +105,8 @@ func NewLogger(ctx context.Context, cfg LoggerConfig) zerolog.Logger {
 			if err != nil {
 				log.Fatal(err)
 			}
-			outputs = append(outputs, zerolog.SyslogLevelWriter(rsyslogWriter))
+			outputs = append(outputs, formatWriter(
+				zerolog.SyslogLevelWriter(rsyslogWriter), cfg.Format[out], cfg))
 		default:
 			outputs = append(outputs, consoleWriter)
 		}
@@ -107,6 +118,9 @@ func NewLogger(ctx context.Context, cfg LoggerConfig) zerolog.Logger {
 	logger := zerolog.New(multiWriter)
 	logger = logger.With().Timestamp().Logger()
 	logger = logger.With().Str("group", cfg.Name).Logger()
+	if cfg.ErrorLogWindow > 0 {
+		logger = logger.Hook(NewErrorLimiter(logger, cfg.ErrorLogWindow))
+	}
 
 	span.End()
 
@@ -25,6 +25,11 @@ var (
 		Name:      "tls_connections",
 		Help:      "Number of TLS connections",
 	})
+	CompressedConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "compressed_connections",
+		Help:      "Number of compressed client connections",
+	})
 	ServerTicksFired = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: Namespace,
 		Name:      "server_ticks_fired_total",
@@ -60,6 +65,11 @@ var (
 		Name:      "plugins_loaded_total",
 		Help:      "Number of plugins loaded",
 	})
+	PluginsUnavailable = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "plugins_unavailable",
+		Help:      "Number of plugins whose hooks are skipped after a failed call, until they're back",
+	})
 	PluginHooksRegistered = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: Namespace,
 		Name:      "plugin_hooks_registered_total",
@@ -70,16 +80,130 @@ var (
 		Name:      "plugin_hooks_executed_total",
 		Help:      "Number of plugin hooks executed",
 	})
+	PluginHookVerificationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "plugin_hook_verification_failures_total",
+		Help:      "Number of the hook results that failed verification, by the hook, the plugin and the policy",
+	}, []string{"hook", "plugin", "policy"})
+	PluginHooksSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "plugin_hooks_skipped_total",
+		Help:      "Number of hook runs skipped because their hook type is disabled, by the hook",
+	}, []string{"hook"})
+	PluginCircuitBreakers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "plugin_circuit_breakers_open",
+		Help:      "Whether the circuit breaker of the hook of the plugin is open (1) or closed (0)",
+	}, []string{"plugin", "hook"})
 	ProxyHealthChecks = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: Namespace,
 		Name:      "proxy_health_checks_total",
 		Help:      "Number of proxy health checks",
 	})
+	BackendFailovers = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "backend_failovers_total",
+		Help:      "Number of failovers from a backend that is down to another backend",
+	})
+	WarmStandbyConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "warm_standby_connections",
+		Help:      "Number of the warm standby connections of the backend",
+	}, []string{"backend"})
+	WarmStandbyPromotions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "warm_standby_promotions_total",
+		Help:      "Number of the warm standby connections promoted on a failover",
+	})
+	BackendHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "backend_healthy",
+		Help:      "Whether the backend passed its last health check (1) or not (0)",
+	}, []string{"backend"})
+	BackendHealthTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "backend_health_transitions_total",
+		Help:      "Number of times the backend became healthy or down, by the new state",
+	}, []string{"backend", "state"})
+	BackendInFlightRequests = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "backend_in_flight_requests",
+		Help:      "Number of the requests in flight to the backend with a max in-flight requests",
+	}, []string{"backend"})
+	BackendInFlightQueued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "backend_in_flight_queued_total",
+		Help:      "Number of the requests queued because their backend was at its max in-flight requests",
+	}, []string{"backend"})
+	BackendInFlightTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "backend_in_flight_timeouts_total",
+		Help:      "Number of the queued requests rejected after the in-flight timeout",
+	}, []string{"backend"})
+	EmptyRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "empty_requests_total",
+		Help:      "Number of empty requests from the clients, which aren't passed through",
+	})
+	LabeledRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "labeled_requests_total",
+		Help:      "Number of requests sent to the server, by the labels the plugins attached to them",
+	}, []string{"name", "value"})
+	RejectedConnections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "rejected_connections_total",
+		Help:      "Number of client connections rejected by the servers, by the reason",
+	}, []string{"reason"})
 	ProxiedConnections = promauto.NewGauge(prometheus.GaugeOpts{
 		Namespace: Namespace,
 		Name:      "proxied_connections",
 		Help:      "Number of proxy connects",
 	})
+	PoolAcquireWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "pool_acquire_wait_seconds",
+		Help:      "Time the clients waited for a server connection of the exhausted pool, by priority",
+		// From 1ms to 100s.
+		Buckets: prometheus.ExponentialBuckets(0.001, 10, 6), //nolint:gomnd
+	}, []string{"priority"})
+	PoolAcquireTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "pool_acquire_timeouts_total",
+		Help:      "Number of clients rejected after waiting for a server connection, by priority",
+	}, []string{"priority"})
+	PoolAcquireDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "pool_acquire_duration_seconds",
+		Help:      "Time taken to acquire a server connection from the pool for a client",
+		// From 10µs to 10s.
+		Buckets: prometheus.ExponentialBuckets(0.00001, 10, 7), //nolint:gomnd
+	})
+	PoolAcquisitions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "pool_acquisitions_total",
+		Help:      "Number of server connections acquired from the pool for the clients",
+	})
+	PoolExhaustedRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "pool_exhausted_rejections_total",
+		Help:      "Number of clients that couldn't acquire a server connection, because the pool is exhausted",
+	})
+	PoolGrownConnections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "pool_grown_connections_total",
+		Help:      "Number of server connections the pools grew by above their size under load",
+	})
+	PoolRejectedDials = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "pool_rejected_dials_total",
+		Help:      "Number of server connections not dialed to grow the pools, because too many were being dialed",
+	})
+	PoolReapedConnections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "pool_reaped_connections_total",
+		Help:      "Number of idle server connections closed to shrink the pools back toward their size",
+	})
 	ProxyPassThroughsToClient = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: Namespace,
 		Name:      "proxy_passthroughs_to_client_total",
@@ -95,6 +219,106 @@ var (
 		Name:      "proxy_passthrough_terminations_total",
 		Help:      "Number of proxy passthrough terminations by plugins",
 	})
+	RetryBudgetExhausted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "retry_budget_exhausted_total",
+		Help:      "Number of retries dropped due to retry budget exhaustion",
+	})
+	MirroredMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "mirrored_messages_total",
+		Help:      "Number of messages mirrored to plugins",
+	})
+	MirroredMessagesDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "mirrored_messages_dropped_total",
+		Help:      "Number of mirrored messages dropped due to slow plugins",
+	})
+	CancelRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "cancel_requests_total",
+		Help:      "Number of cancel requests forwarded to the servers",
+	})
+	ReadOnlyRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "read_only_rejections_total",
+		Help:      "Number of requests that write to the database rejected in read-only mode",
+	})
+	KeepAlivesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "keepalives_sent_total",
+		Help:      "Number of keepalives sent to the idle client connections",
+	})
+	IdleConnectionsClosed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "idle_connections_closed_total",
+		Help:      "Number of client connections closed due to inactivity",
+	})
+	RoutingChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "routing_changes_total",
+		Help:      "Number of changes to the backends returned by the plugins, applied or rejected",
+	}, []string{"action", "result"})
+	TruncatedHookPayloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "truncated_hook_payloads_total",
+		Help:      "Number of payloads truncated to the max hook payload size, by hook and field",
+	}, []string{"hook", "field"})
+	HookPayloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "hook_payloads_total",
+		Help:      "Number of payloads modified by the traffic hooks or passed through unchanged, by hook, field and result",
+	}, []string{"hook", "field", "result"})
+	LoadBalanceDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "load_balance_decisions_total",
+		Help:      "Number of backends chosen by the plugins for the connections, by result",
+	}, []string{"result"})
+	CertificateRoutes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "certificate_routes_total",
+		Help:      "Number of connections routed by their client certificates, by result",
+	}, []string{"result"})
+	FailedBorrowChecks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "failed_borrow_checks_total",
+		Help:      "Number of server connections that failed the check on borrow from the pool",
+	})
+	FailedReturnChecks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "failed_return_checks_total",
+		Help:      "Number of server connections that failed the check on return to the pool",
+	})
+	SessionHandoffs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "session_handoffs_total",
+		Help:      "Number of sessions handed off to another backend mid-session, by result",
+	}, []string{"result"})
+	RetriedRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "retried_requests_total",
+		Help:      "Number of requests retried after the server closed or reset the connection",
+	})
+	IncompleteMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "incomplete_messages_total",
+		Help:      "Number of responses not completed within the max message assembly time",
+	})
+	ReceiveTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "receive_timeouts_total",
+		Help:      "Number of requests whose response didn't arrive within the receive deadline, by action",
+	}, []string{"action"})
+	ReceiveChunkResizes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "receive_chunk_resizes_total",
+		Help:      "Number of times the receive chunk size of the server connections grew or shrank",
+	}, []string{"direction"})
+	RejectedHookModifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "rejected_hook_modifications_total",
+		Help:      "Number of requests and responses modified by the plugins that failed validation",
+	}, []string{"field", "reason"})
 	APIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: Namespace,
 		Name:      "api_requests_total",
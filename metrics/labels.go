@@ -0,0 +1,49 @@
+package metrics
+
+import "sync"
+
+// OtherLabelValue replaces the label values above the limit of a label name.
+const OtherLabelValue = "other"
+
+// LabelValueLimiter bounds the number of distinct values of each label name in
+// the metrics, to protect the cardinality of the metrics from the labels the
+// plugins attach to the requests, e.g. the tenant IDs.
+type LabelValueLimiter struct {
+	maxValues int
+	values    map[string]map[string]struct{}
+	mu        sync.Mutex
+}
+
+// NewLabelValueLimiter creates a new limiter that allows up to maxValues distinct
+// values per label name. Zero or less means no limit.
+func NewLabelValueLimiter(maxValues int) *LabelValueLimiter {
+	return &LabelValueLimiter{
+		maxValues: maxValues,
+		values:    map[string]map[string]struct{}{},
+	}
+}
+
+// Value returns the value of the label for the metrics: the value itself if it's
+// already seen or if there is still room for it, otherwise OtherLabelValue.
+func (l *LabelValueLimiter) Value(name, value string) string {
+	if l.maxValues <= 0 {
+		return value
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	values, ok := l.values[name]
+	if !ok {
+		values = map[string]struct{}{}
+		l.values[name] = values
+	}
+	if _, ok := values[value]; ok {
+		return value
+	}
+	if len(values) >= l.maxValues {
+		return OtherLabelValue
+	}
+	values[value] = struct{}{}
+	return value
+}
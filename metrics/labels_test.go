@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLabelValueLimiter tests bounding the values of the labels.
+func TestLabelValueLimiter(t *testing.T) {
+	limiter := NewLabelValueLimiter(2)
+	assert.Equal(t, "acme", limiter.Value("tenant", "acme"))
+	assert.Equal(t, "globex", limiter.Value("tenant", "globex"))
+	assert.Equal(t, OtherLabelValue, limiter.Value("tenant", "initech"))
+	assert.Equal(t, "acme", limiter.Value("tenant", "acme"))
+	assert.Equal(t, "billing", limiter.Value("app", "billing"))
+
+	unlimited := NewLabelValueLimiter(0)
+	for _, value := range []string{"a", "b", "c"} {
+		assert.Equal(t, value, unlimited.Value("tenant", value))
+	}
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/getsentry/sentry-go"
+	"github.com/go-co-op/gocron"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+)
+
+type IPusher interface {
+	Push() *gerr.GatewayDError
+	Start()
+	Stop()
+}
+
+// Pusher periodically pushes the metrics to a Prometheus Pushgateway, for the
+// instances that are short-lived or behind a firewall and can't be scraped.
+// It pushes the same metrics that are exposed by the metrics server.
+type Pusher struct {
+	scheduler *gocron.Scheduler
+	ctx       context.Context //nolint:containedctx
+	pusher    *push.Pusher
+
+	Logger   zerolog.Logger
+	URL      string
+	Job      string
+	Interval time.Duration
+	Timeout  time.Duration
+	Gatherer prometheus.Gatherer
+}
+
+var _ IPusher = (*Pusher)(nil)
+
+// NewPusher creates a new metrics pusher. The metrics are grouped by the hostname,
+// so that the instances don't overwrite each other's metrics.
+func NewPusher(ctx context.Context, pusher Pusher) *Pusher {
+	pusherCtx, span := otel.Tracer(config.TracerName).Start(ctx, "NewPusher")
+	defer span.End()
+
+	gatherer := config.If[prometheus.Gatherer](
+		pusher.Gatherer != nil, pusher.Gatherer, prometheus.DefaultGatherer)
+	timeout := config.If(pusher.Timeout > 0, pusher.Timeout, config.DefaultMetricsServerTimeout)
+
+	instance, err := os.Hostname()
+	if err != nil {
+		pusher.Logger.Debug().Err(err).Msg("Failed to get the hostname for grouping the metrics")
+		instance = config.Default
+	}
+
+	return &Pusher{
+		scheduler: gocron.NewScheduler(time.UTC),
+		ctx:       pusherCtx,
+		pusher: push.New(pusher.URL, pusher.Job).
+			Gatherer(gatherer).
+			Grouping("instance", instance).
+			Client(&http.Client{Timeout: timeout}),
+		Logger:   pusher.Logger,
+		URL:      pusher.URL,
+		Job:      pusher.Job,
+		Interval: pusher.Interval,
+		Timeout:  timeout,
+		Gatherer: gatherer,
+	}
+}
+
+// Push pushes the metrics to the Pushgateway, replacing the ones pushed before.
+func (p *Pusher) Push() *gerr.GatewayDError {
+	_, span := otel.Tracer(config.TracerName).Start(p.ctx, "Push metrics")
+	defer span.End()
+
+	if err := p.pusher.Push(); err != nil {
+		span.RecordError(err)
+		return gerr.ErrFailedToPushMetrics.Wrap(err)
+	}
+
+	return nil
+}
+
+// Start starts pushing the metrics periodically.
+func (p *Pusher) Start() {
+	_, span := otel.Tracer(config.TracerName).Start(p.ctx, "Metrics pusher")
+	defer span.End()
+
+	if _, err := p.scheduler.
+		Every(p.Interval).
+		SingletonMode().
+		Do(func() {
+			if err := p.Push(); err != nil {
+				p.Logger.Error().Err(err.Unwrap()).Str("url", p.URL).Msg("Failed to push metrics")
+			}
+		}); err != nil {
+		p.Logger.Error().Err(err).Msg("Failed to start metrics pusher scheduler")
+		span.RecordError(err)
+		sentry.CaptureException(err)
+		return
+	}
+
+	p.scheduler.StartAsync()
+	p.Logger.Info().Fields(
+		map[string]interface{}{
+			"url":      p.URL,
+			"job":      p.Job,
+			"interval": p.Interval.String(),
+		},
+	).Msg("Started the metrics pusher scheduler")
+}
+
+// Stop stops pushing the metrics and pushes them one last time, so that the
+// final values of a short-lived instance aren't lost.
+func (p *Pusher) Stop() {
+	_, span := otel.Tracer(config.TracerName).Start(p.ctx, "Stop metrics pusher")
+	defer span.End()
+
+	p.scheduler.Clear()
+	p.scheduler.Stop()
+
+	if err := p.Push(); err != nil {
+		p.Logger.Error().Err(err.Unwrap()).Str("url", p.URL).Msg("Failed to push metrics")
+	}
+}
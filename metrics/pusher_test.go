@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPusher tests pushing the metrics to a fake Pushgateway.
+func TestPusher(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		body   string
+	}
+	requests := make(chan request, 1)
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{method: r.Method, path: r.URL.Path, body: string(body)}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer pushgateway.Close()
+
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "test_pushed_total",
+		Help:      "Test counter",
+	})
+	registry.MustRegister(counter)
+	counter.Inc()
+
+	pusher := NewPusher(context.Background(), Pusher{
+		Logger:   zerolog.Nop(),
+		URL:      pushgateway.URL,
+		Job:      "test",
+		Interval: time.Minute,
+		Gatherer: registry,
+	})
+	require.Nil(t, pusher.Push())
+
+	pushed := <-requests
+	assert.Equal(t, http.MethodPut, pushed.method)
+	assert.True(t, strings.HasPrefix(pushed.path, "/metrics/job/test/instance/"))
+	assert.NotEmpty(t, pushed.body)
+
+	// The Pushgateway is gone, so the push fails.
+	pushgateway.Close()
+	assert.NotNil(t, pusher.Push())
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// newRuntimeCollector returns the Go collector that also exports the runtime metrics
+// of the GC, the memory and the scheduler, e.g. the GC pauses and the scheduling latencies.
+func newRuntimeCollector() prometheus.Collector {
+	return collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(
+		collectors.MetricsGC, collectors.MetricsMemory, collectors.MetricsScheduler))
+}
+
+// RegisterRuntimeMetrics exports the Go runtime metrics, e.g. the number of goroutines,
+// the heap and the GC pauses, with the other metrics of the registerer, or stops
+// exporting them if they're disabled. The rising number of goroutines is the early
+// warning of the leaks of the background goroutines. It replaces the default Go
+// collector of the default registerer, and can be called again on reload.
+func RegisterRuntimeMetrics(registerer prometheus.Registerer, enabled bool) error {
+	registerer.Unregister(collectors.NewGoCollector())
+	registerer.Unregister(newRuntimeCollector())
+	if !enabled {
+		return nil
+	}
+
+	if err := registerer.Register(newRuntimeCollector()); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			return nil
+		}
+		return err //nolint:wrapcheck
+	}
+	return nil
+}
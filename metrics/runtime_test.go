@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRegisterRuntimeMetrics tests replacing the default Go collector with the one
+// exporting the runtime metrics, and removing it.
+func TestRegisterRuntimeMetrics(t *testing.T) {
+	names := func(registry *prometheus.Registry) []string {
+		t.Helper()
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		names := []string{}
+		for _, family := range families {
+			names = append(names, family.GetName())
+		}
+		return names
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector())
+	assert.Contains(t, names(registry), "go_goroutines")
+	assert.NotContains(t, names(registry), "go_gc_pauses_seconds")
+
+	require.NoError(t, RegisterRuntimeMetrics(registry, true))
+	assert.Contains(t, names(registry), "go_goroutines")
+	assert.Contains(t, names(registry), "go_gc_pauses_seconds")
+	assert.Contains(t, names(registry), "go_sched_goroutines_goroutines")
+	require.NoError(t, RegisterRuntimeMetrics(registry, true), "it can be called again")
+
+	require.NoError(t, RegisterRuntimeMetrics(registry, false))
+	assert.NotContains(t, names(registry), "go_goroutines")
+	assert.NotContains(t, names(registry), "go_gc_pauses_seconds")
+}
@@ -0,0 +1,286 @@
+package network
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/gatewayd-io/gatewayd/plugin"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/spf13/cast"
+)
+
+const (
+	// TokenParameter is the startup parameter that carries the auth token.
+	// It can also be passed as a custom setting via the options parameter,
+	// e.g. options='-c gatewayd.token=secret'.
+	TokenParameter = "gatewayd.token"
+	// AuthHookName is the name passed to the OnHook hooks for authenticating clients.
+	AuthHookName = "onAuthenticate"
+)
+
+var (
+	errNoPeerCertificate = errors.New("client did not present a verified certificate")
+	errNoToken           = errors.New("client did not present a token")
+	errInvalidToken      = errors.New("client presented an invalid token")
+	errRejectedByPlugin  = errors.New("client is rejected by the plugins")
+)
+
+// Identity is the identity of an authenticated client.
+type Identity struct {
+	Name   string
+	Method string
+}
+
+type IAuthenticator interface {
+	Authenticate(conn *ConnWrapper, startup map[string]string) (*Identity, *gerr.GatewayDError)
+}
+
+// CertAuthenticator authenticates the clients by their verified TLS client
+// certificate (mTLS). The identity is the common name of the certificate.
+type CertAuthenticator struct{}
+
+var _ IAuthenticator = (*CertAuthenticator)(nil)
+
+// Authenticate authenticates the client by its TLS client certificate.
+func (a *CertAuthenticator) Authenticate(
+	conn *ConnWrapper, _ map[string]string,
+) (*Identity, *gerr.GatewayDError) {
+	tlsConn, ok := conn.Conn().(*tls.Conn)
+	if !ok {
+		return nil, gerr.ErrAuthFailed.Wrap(errNoPeerCertificate)
+	}
+
+	state := tlsConn.ConnectionState()
+	if len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil, gerr.ErrAuthFailed.Wrap(errNoPeerCertificate)
+	}
+
+	return &Identity{
+		Name:   state.PeerCertificates[0].Subject.CommonName,
+		Method: config.CertAuth,
+	}, nil
+}
+
+// TokenAuthenticator authenticates the clients by a static token sent in the
+// startup message. The tokens are keyed by the identity they belong to.
+type TokenAuthenticator struct {
+	Tokens map[string]string
+}
+
+var _ IAuthenticator = (*TokenAuthenticator)(nil)
+
+// Authenticate authenticates the client by the token in the startup message.
+func (a *TokenAuthenticator) Authenticate(
+	_ *ConnWrapper, startup map[string]string,
+) (*Identity, *gerr.GatewayDError) {
+	token := GetStartupToken(startup)
+	if token == "" {
+		return nil, gerr.ErrAuthFailed.Wrap(errNoToken)
+	}
+
+	for name, expected := range a.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			return &Identity{Name: name, Method: config.TokenAuth}, nil
+		}
+	}
+
+	return nil, gerr.ErrAuthFailed.Wrap(errInvalidToken)
+}
+
+// PluginAuthenticator delegates authenticating the clients to the plugins
+// registered to the OnHook hook. The plugins receive the client address and
+// the startup parameters, and they should return "authenticated" and optionally
+// "identity" in the result.
+type PluginAuthenticator struct {
+	PluginRegistry *plugin.Registry
+	PluginTimeout  time.Duration
+}
+
+var _ IAuthenticator = (*PluginAuthenticator)(nil)
+
+// Authenticate authenticates the client by running the OnHook hooks.
+func (a *PluginAuthenticator) Authenticate(
+	conn *ConnWrapper, startup map[string]string,
+) (*Identity, *gerr.GatewayDError) {
+	pluginTimeoutCtx, cancel := context.WithTimeout(context.Background(), a.PluginTimeout)
+	defer cancel()
+
+	parameters := map[string]interface{}{}
+	for key, value := range startup {
+		parameters[key] = value
+	}
+
+	result, err := a.PluginRegistry.Run(
+		pluginTimeoutCtx,
+		map[string]interface{}{
+			"hook": AuthHookName,
+			"client": map[string]interface{}{
+				"local":  LocalAddr(conn.Conn()),
+				"remote": RemoteAddr(conn.Conn()),
+			},
+			"parameters": parameters,
+		},
+		v1.HookName_HOOK_NAME_ON_HOOK)
+	if err != nil {
+		return nil, gerr.ErrAuthFailed.Wrap(err)
+	}
+
+	if result == nil || !cast.ToBool(result["authenticated"]) {
+		return nil, gerr.ErrAuthFailed.Wrap(errRejectedByPlugin)
+	}
+
+	return &Identity{
+		Name:   cast.ToString(result["identity"]),
+		Method: config.PluginAuth,
+	}, nil
+}
+
+// NewAuthenticator returns the authenticator for the given auth method,
+// or nil if the clients should not be authenticated.
+func NewAuthenticator(
+	method string, tokens map[string]string, registry *plugin.Registry, pluginTimeout time.Duration,
+) IAuthenticator {
+	switch method {
+	case config.CertAuth:
+		return &CertAuthenticator{}
+	case config.TokenAuth:
+		return &TokenAuthenticator{Tokens: tokens}
+	case config.PluginAuth:
+		return &PluginAuthenticator{PluginRegistry: registry, PluginTimeout: pluginTimeout}
+	default:
+		return nil
+	}
+}
+
+// withIdentity adds the identity of the authenticated client and the attributes of
+// its verified client certificate to the hook fields.
+func withIdentity(conn *ConnWrapper, fields []Field) []Field {
+	if identity := conn.Identity(); identity != nil {
+		fields = append(fields, Field{
+			Name: "identity",
+			Value: map[string]interface{}{
+				"name":   identity.Name,
+				"method": identity.Method,
+			},
+		})
+	}
+	if certificate := conn.ClientCertificate(); certificate != nil {
+		fields = append(fields, Field{Name: CertificateField, Value: certificate.Fields()})
+	}
+	return fields
+}
+
+// DecodeStartupMessage decodes the parameters of a PostgreSQL startup message.
+func DecodeStartupMessage(data []byte) (map[string]string, bool) {
+	if len(data) < 8 || int(binary.BigEndian.Uint32(data[0:4])) != len(data) {
+		return nil, false
+	}
+
+	startup := &pgproto3.StartupMessage{}
+	if err := startup.Decode(data[4:]); err != nil {
+		return nil, false
+	}
+
+	return startup.Parameters, true
+}
+
+// GetStartupToken returns the token from the startup parameters, either as
+// a parameter of its own or as a setting in the options parameter.
+func GetStartupToken(startup map[string]string) string {
+	if token, ok := startup[TokenParameter]; ok {
+		return token
+	}
+
+	fields := strings.Fields(startup["options"])
+	for idx, field := range fields {
+		setting := strings.TrimPrefix(field, "--")
+		if field == "-c" && idx+1 < len(fields) {
+			setting = fields[idx+1]
+		}
+		if token, found := strings.CutPrefix(setting, TokenParameter+"="); found {
+			return token
+		}
+	}
+
+	return ""
+}
+
+// StripStartupToken removes the token from a PostgreSQL startup message, both the
+// parameter and the setting in the options parameter, so that it isn't sent to the
+// server, where it would become a custom setting shown by SHOW and in the logs.
+// Other messages are returned as is.
+func StripStartupToken(data []byte) []byte {
+	if len(data) < 8 || int(binary.BigEndian.Uint32(data[0:4])) != len(data) {
+		return data
+	}
+
+	startup := &pgproto3.StartupMessage{}
+	if err := startup.Decode(data[4:]); err != nil {
+		return data
+	}
+
+	_, stripped := startup.Parameters[TokenParameter]
+	delete(startup.Parameters, TokenParameter)
+
+	if options, ok := startup.Parameters["options"]; ok {
+		fields := strings.Fields(options)
+		kept := make([]string, 0, len(fields))
+		for idx := 0; idx < len(fields); idx++ {
+			setting, next := strings.TrimPrefix(fields[idx], "--"), idx
+			if fields[idx] == "-c" && idx+1 < len(fields) {
+				setting, next = fields[idx+1], idx+1
+			}
+			if strings.HasPrefix(setting, TokenParameter+"=") {
+				stripped = true
+				idx = next
+				continue
+			}
+			kept = append(kept, fields[idx])
+		}
+		if len(kept) == 0 {
+			delete(startup.Parameters, "options")
+		} else {
+			startup.Parameters["options"] = strings.Join(kept, " ")
+		}
+	}
+
+	if !stripped {
+		return data
+	}
+	rewritten, err := startup.Encode(nil)
+	if err != nil {
+		return data
+	}
+	return rewritten
+}
+
+// RewriteStartupMessage replaces the parameters of a PostgreSQL startup message,
+// e.g. with the credentials of the backend. Other messages are returned as is.
+func RewriteStartupMessage(data []byte, parameters map[string]string) []byte {
+	if len(parameters) == 0 || len(data) < 8 || int(binary.BigEndian.Uint32(data[0:4])) != len(data) {
+		return data
+	}
+
+	startup := &pgproto3.StartupMessage{}
+	if err := startup.Decode(data[4:]); err != nil {
+		return data
+	}
+
+	for name, value := range parameters {
+		startup.Parameters[name] = value
+	}
+
+	rewritten, err := startup.Encode(nil)
+	if err != nil {
+		return data
+	}
+	return rewritten
+}
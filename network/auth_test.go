@@ -0,0 +1,169 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/plugin"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecodeStartupMessage tests decoding the parameters of a startup message.
+func TestDecodeStartupMessage(t *testing.T) {
+	startup, ok := DecodeStartupMessage(CreatePgStartupPacket())
+	require.True(t, ok)
+	assert.Equal(t, "postgres", startup["user"])
+	assert.Equal(t, "postgres", startup["database"])
+	assert.Equal(t, "gatewayd", startup["application_name"])
+
+	_, ok = DecodeStartupMessage([]byte{0x00, 0x00, 0x00, 0x8, 0x04, 0xd2, 0x16, 0x2f})
+	assert.False(t, ok)
+
+	_, ok = DecodeStartupMessage(CreatePgTerminatePacket())
+	assert.False(t, ok)
+}
+
+// TestRewriteStartupMessage tests replacing the parameters of a startup message.
+func TestRewriteStartupMessage(t *testing.T) {
+	rewritten := RewriteStartupMessage(
+		CreatePgStartupPacket(), map[string]string{"user": "tenant", "database": "tenant_db"})
+	startup, ok := DecodeStartupMessage(rewritten)
+	require.True(t, ok)
+	assert.Equal(t, "tenant", startup["user"])
+	assert.Equal(t, "tenant_db", startup["database"])
+	assert.Equal(t, "gatewayd", startup["application_name"])
+
+	// Other messages and empty parameters are left as is.
+	sslRequest := []byte{0x00, 0x00, 0x00, 0x8, 0x04, 0xd2, 0x16, 0x2f}
+	assert.Equal(t, sslRequest, RewriteStartupMessage(sslRequest, map[string]string{"user": "tenant"}))
+	assert.Equal(t, CreatePgStartupPacket(), RewriteStartupMessage(CreatePgStartupPacket(), nil))
+}
+
+// TestGetStartupToken tests extracting the token from the startup parameters.
+func TestGetStartupToken(t *testing.T) {
+	assert.Equal(t, "secret", GetStartupToken(map[string]string{TokenParameter: "secret"}))
+	assert.Equal(t, "secret", GetStartupToken(
+		map[string]string{"options": "-c gatewayd.token=secret"}))
+	assert.Equal(t, "secret", GetStartupToken(
+		map[string]string{"options": "-c search_path=test --gatewayd.token=secret"}))
+	assert.Empty(t, GetStartupToken(map[string]string{"options": "-c search_path=test"}))
+	assert.Empty(t, GetStartupToken(map[string]string{}))
+}
+
+// newStartupPacket creates a PostgreSQL startup packet with the parameters.
+func newStartupPacket(t *testing.T, parameters map[string]string) []byte {
+	t.Helper()
+
+	startup := &pgproto3.StartupMessage{
+		ProtocolVersion: pgproto3.ProtocolVersionNumber,
+		Parameters:      parameters,
+	}
+	data, err := startup.Encode(nil)
+	require.NoError(t, err)
+	return data
+}
+
+// TestStripStartupToken tests removing the token from the startup parameters and
+// the options parameter before the startup message is sent to the server.
+func TestStripStartupToken(t *testing.T) {
+	startup, ok := DecodeStartupMessage(StripStartupToken(newStartupPacket(t, map[string]string{
+		"user":         "postgres",
+		TokenParameter: "secret",
+		"options":      "-c search_path=test -c gatewayd.token=secret --gatewayd.token=secret -c work_mem=4MB",
+	})))
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"user":    "postgres",
+		"options": "-c search_path=test -c work_mem=4MB",
+	}, startup)
+
+	startup, ok = DecodeStartupMessage(StripStartupToken(newStartupPacket(t, map[string]string{
+		"user":    "postgres",
+		"options": "-c gatewayd.token=secret",
+	})))
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"user": "postgres"}, startup)
+
+	// The messages without a token and the other messages are left as is.
+	assert.Equal(t, CreatePgStartupPacket(), StripStartupToken(CreatePgStartupPacket()))
+	assert.Equal(t, CreatePgTerminatePacket(), StripStartupToken(CreatePgTerminatePacket()))
+}
+
+// TestProxyStripsStartupToken tests that the token of the authenticated client isn't
+// forwarded to the server, nor mirrored to the plugins.
+func TestProxyStripsStartupToken(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+	proxy.Authenticator = NewAuthenticator(
+		config.TokenAuth, map[string]string{"alice": "secret"}, nil, config.DefaultPluginTimeout)
+	mirrored := proxy.PluginRegistry.Mirror.Subscribe(sdkPlugin.Identifier{Name: "mirror"})
+
+	request := newStartupPacket(t, map[string]string{
+		"user":         "postgres",
+		"database":     "postgres",
+		TokenParameter: "secret",
+		"options":      "-c gatewayd.token=secret",
+	})
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn(request)})
+	require.Nil(t, proxy.Connect(conn))
+
+	received := make(chan []byte, 1)
+	go func() {
+		buffer := make([]byte, config.DefaultChunkSize)
+		n, _ := server.Read(buffer)
+		received <- buffer[:n]
+	}()
+	require.Nil(t, proxy.PassThroughToServer(conn, NewStack()))
+	assert.Equal(t, "alice", conn.Identity().Name)
+
+	forwarded := <-received
+	assert.NotContains(t, string(forwarded), "secret")
+	startup, ok := DecodeStartupMessage(forwarded)
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"user": "postgres", "database": "postgres"}, startup)
+
+	msg := <-mirrored
+	assert.Equal(t, plugin.MirrorIngress, msg.Direction)
+	assert.Equal(t, forwarded, msg.Payload)
+}
+
+// TestTokenAuthenticator tests authenticating the clients by a static token.
+func TestTokenAuthenticator(t *testing.T) {
+	authenticator := NewAuthenticator(
+		config.TokenAuth, map[string]string{"alice": "secret"}, nil, config.DefaultPluginTimeout)
+	require.IsType(t, &TokenAuthenticator{}, authenticator)
+
+	identity, err := authenticator.Authenticate(nil, map[string]string{TokenParameter: "secret"})
+	require.Nil(t, err)
+	assert.Equal(t, &Identity{Name: "alice", Method: config.TokenAuth}, identity)
+
+	_, err = authenticator.Authenticate(nil, map[string]string{TokenParameter: "wrong"})
+	assert.NotNil(t, err)
+
+	_, err = authenticator.Authenticate(nil, map[string]string{})
+	assert.NotNil(t, err)
+}
+
+// TestCertAuthenticator tests that clients without a TLS client certificate are rejected.
+func TestCertAuthenticator(t *testing.T) {
+	authenticator := NewAuthenticator(config.CertAuth, nil, nil, config.DefaultPluginTimeout)
+	require.IsType(t, &CertAuthenticator{}, authenticator)
+
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+
+	_, err := authenticator.Authenticate(NewConnWrapper(ConnWrapper{NetConn: serverSide}), nil)
+	assert.NotNil(t, err)
+}
+
+// TestNewAuthenticatorNone tests that no authenticator is created by default.
+func TestNewAuthenticatorNone(t *testing.T) {
+	assert.Nil(t, NewAuthenticator(config.NoAuth, nil, nil, config.DefaultPluginTimeout))
+	assert.Nil(t, NewAuthenticator("", nil, nil, config.DefaultPluginTimeout))
+}
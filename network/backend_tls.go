@@ -0,0 +1,97 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+)
+
+// SSLRequestCode is the code of the SSLRequest message of the Postgres protocol.
+const SSLRequestCode = 1234<<16 | 5679
+
+var errTLSNotSupported = errors.New("TLS is not supported by the server")
+
+// NewBackendTLSConfig returns the TLS config of the connections to the backend at the
+// address, or nil if the TLS of the backend isn't enabled. The server name defaults to
+// the host of the address, so that the address in the config, not the resolved IP,
+// is verified against the server's certificate.
+func NewBackendTLSConfig(backendTLS config.BackendTLS, address string) (*tls.Config, error) {
+	if !backendTLS.Enabled {
+		return nil, nil //nolint:nilnil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: backendTLS.ServerName,
+	}
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		tlsConfig.ServerName = host
+	}
+
+	if backendTLS.CAFile != "" {
+		certPool, err := LoadCertPool(backendTLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the CA file: %w", err)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	if backendTLS.CertFile != "" && backendTLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(backendTLS.CertFile, backendTLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// UpgradeToTLS sends an SSLRequest on the connection to the server and does the
+// TLS handshake if the server accepts it. A zero timeout means no timeout.
+//
+//nolint:gomnd
+func UpgradeToTLS(conn net.Conn, tlsConfig *tls.Config, timeout time.Duration) (*tls.Conn, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, fmt.Errorf("failed to set the deadline of the SSL request: %w", err)
+		}
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], SSLRequestCode)
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("failed to send the SSL request: %w", err)
+	}
+
+	response := make([]byte, 1)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, fmt.Errorf("failed to receive the SSL response: %w", err)
+	}
+	if response[0] != 'S' {
+		return nil, errTLSNotSupported
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to do the TLS handshake: %w", err)
+	}
+	return tlsConn, nil
+}
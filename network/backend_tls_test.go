@@ -0,0 +1,152 @@
+package network
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCertificate writes a self-signed certificate of localhost and its key
+// to the temporary directory of the test.
+func writeTestCertificate(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(t.TempDir(), "server.pem")
+	keyFile := filepath.Join(t.TempDir(), "server-key.pem")
+	require.NoError(t, os.WriteFile(
+		certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0o600))
+	require.NoError(t, os.WriteFile(
+		keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0o600))
+	return certFile, keyFile
+}
+
+// newTLSUpstream returns a fake database server that accepts the SSLRequest and echoes
+// the traffic over TLS, and reports the server name that the clients sent.
+func newTLSUpstream(t *testing.T, certFile, keyFile string) (*fakeUpstream, chan string) {
+	t.Helper()
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+
+	serverNames := make(chan string, 1)
+	upstream := newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		request := make([]byte, 8)
+		if _, err := io.ReadFull(conn, request); err != nil ||
+			binary.BigEndian.Uint32(request[4:8]) != SSLRequestCode {
+			return
+		}
+		if _, err := conn.Write([]byte{'S'}); err != nil {
+			return
+		}
+
+		tlsConn := tls.Server(conn, &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		serverNames <- tlsConn.ConnectionState().ServerName
+		_, _ = io.Copy(tlsConn, tlsConn)
+	})
+	return upstream, serverNames
+}
+
+// TestClientTLS tests connecting to the server over TLS with the server name of the backend.
+func TestClientTLS(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	upstream, serverNames := newTLSUpstream(t, certFile, keyFile)
+
+	_, port, err := net.SplitHostPort(upstream.Address())
+	require.NoError(t, err)
+	clientConfig := &config.Client{
+		Network:          "tcp",
+		Address:          net.JoinHostPort("localhost", port),
+		ReceiveChunkSize: config.DefaultChunkSize,
+		DialTimeout:      time.Second,
+		TLS:              config.BackendTLS{Enabled: true, CAFile: certFile},
+	}
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	defer client.Close()
+
+	// The host of the address in the config is verified, not the resolved IP.
+	assert.Equal(t, "localhost", <-serverNames)
+	assert.IsType(t, &tls.Conn{}, client.conn)
+
+	sent, gErr := client.Send([]byte("select 1"))
+	require.Nil(t, gErr)
+	assert.Equal(t, 8, sent)
+	received, data, gErr := client.Receive()
+	require.Nil(t, gErr)
+	assert.Equal(t, []byte("select 1"), data[:received])
+
+	require.NoError(t, client.Reconnect())
+	assert.Equal(t, "localhost", <-serverNames)
+	assert.IsType(t, &tls.Conn{}, client.conn)
+}
+
+// TestClientTLSVerification tests that the server's certificate is verified against
+// the server name of the backend.
+func TestClientTLSVerification(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	upstream, _ := newTLSUpstream(t, certFile, keyFile)
+
+	clientConfig := &config.Client{
+		Network:     "tcp",
+		Address:     upstream.Address(),
+		DialTimeout: time.Second,
+		TLS: config.BackendTLS{
+			Enabled: true, CAFile: certFile, ServerName: "tenant1.example.com",
+		},
+	}
+	assert.Nil(t, NewClient(context.Background(), clientConfig, zerolog.Nop(), nil))
+
+	// The server doesn't support TLS.
+	plain := newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		_, _ = io.ReadFull(conn, make([]byte, 8))
+		_, _ = conn.Write([]byte{'N'})
+	})
+	clientConfig.Address = plain.Address()
+	clientConfig.TLS.ServerName = ""
+	assert.Nil(t, NewClient(context.Background(), clientConfig, zerolog.Nop(), nil))
+}
@@ -0,0 +1,296 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/gatewayd-io/gatewayd/plugin"
+	"github.com/spf13/cast"
+	"go.opentelemetry.io/otel"
+)
+
+// These are the actions of the changes to the backends returned by the plugins.
+const (
+	RoutingAdd    = "add"
+	RoutingRemove = "remove"
+	RoutingWeight = "weight"
+)
+
+var (
+	errBackendExists   = errors.New("backend already exists")
+	errBackendNotFound = errors.New("backend not found")
+	errLastBackend     = errors.New("the last backend can't be removed")
+)
+
+// backendList returns a copy of the backends of the proxy, empty if the proxy only
+// has the backend of its client config.
+func (pr *Proxy) backendList() []config.Backend {
+	pr.backendsMu.RLock()
+	defer pr.backendsMu.RUnlock()
+	return slices.Clone(pr.backends)
+}
+
+// changeBackends changes the backends of the proxy with the change function, which
+// gets the backends with the backend of the client config if it has no backends.
+func (pr *Proxy) changeBackends(change func([]config.Backend) ([]config.Backend, error)) error {
+	pr.backendsMu.Lock()
+	defer pr.backendsMu.Unlock()
+
+	backends := slices.Clone(pr.backends)
+	if len(backends) == 0 && pr.ClientConfig != nil {
+		backends = []config.Backend{{Network: pr.ClientConfig.Network, Address: pr.ClientConfig.Address}}
+	}
+	backends, err := change(backends)
+	if err != nil {
+		return err
+	}
+	pr.backends = backends
+	return nil
+}
+
+// AddBackend adds the backend to the proxy at runtime, after validating it like the
+// backends in the config. The backend gets its share of the server connections by
+// weight as the server connections of the other backends are recycled.
+func (pr *Proxy) AddBackend(backend config.Backend) error {
+	if err := config.ValidateBackend(backend); err != nil {
+		return fmt.Errorf("invalid backend: %w", err)
+	}
+
+	name := backend.Network + "://" + backend.Address
+	if err := pr.changeBackends(func(backends []config.Backend) ([]config.Backend, error) {
+		for _, existing := range backends {
+			if existing.Network+"://"+existing.Address == name {
+				return nil, fmt.Errorf("%w: %s", errBackendExists, name)
+			}
+		}
+		return append(backends, backend), nil
+	}); err != nil {
+		return err
+	}
+
+	// The backend may have been removed before.
+	pr.backendHealth.SetDraining(backend.Network, backend.Address, false)
+	pr.Logger.Info().Str("backend", name).Int("weight", backend.GetWeight()).Msg("Added a backend")
+	return nil
+}
+
+// RemoveBackend removes the backend, e.g. "tcp://localhost:5432", from the proxy at
+// runtime. The backend is drained first, so its available server connections are
+// moved to the other backends right away, and its busy ones when their clients disconnect.
+func (pr *Proxy) RemoveBackend(backend string) error {
+	backends := pr.backendConfigs()
+	index := slices.IndexFunc(backends, func(clientConfig *config.Client) bool {
+		return clientConfig.Network+"://"+clientConfig.Address == backend
+	})
+	if index < 0 {
+		return fmt.Errorf("%w: %s", errBackendNotFound, backend)
+	}
+	if len(backends) == 1 {
+		return fmt.Errorf("%w: %s", errLastBackend, backend)
+	}
+
+	pr.DrainBackend(backend, true)
+	if err := pr.changeBackends(func(backends []config.Backend) ([]config.Backend, error) {
+		return slices.DeleteFunc(backends, func(existing config.Backend) bool {
+			return existing.Network+"://"+existing.Address == backend
+		}), nil
+	}); err != nil {
+		return err
+	}
+
+	pr.closeStandby(backend)
+	pr.Logger.Info().Str("backend", backend).Msg("Removed a backend")
+	return nil
+}
+
+// SetBackendWeight sets the weight of the backend, e.g. "tcp://localhost:5432", at
+// runtime. The server connections are moved to keep the shares of the backends by
+// weight as they're recycled.
+func (pr *Proxy) SetBackendWeight(backend string, weight int) error {
+	if weight < 0 {
+		return fmt.Errorf("invalid backend: %w", errors.New("weight can't be negative"))
+	}
+
+	if err := pr.changeBackends(func(backends []config.Backend) ([]config.Backend, error) {
+		for index := range backends {
+			if backends[index].Network+"://"+backends[index].Address == backend {
+				backends[index].Weight = weight
+				return backends, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", errBackendNotFound, backend)
+	}); err != nil {
+		return err
+	}
+
+	pr.Logger.Info().Str("backend", backend).Int("weight", weight).Msg("Changed the weight of a backend")
+	return nil
+}
+
+// RoutingTable returns the backends of the proxy with their status, weight and number
+// of the available and busy server connections.
+func (pr *Proxy) RoutingTable() []map[string]interface{} {
+	weights := map[string]int{}
+	for _, backend := range pr.backendList() {
+		weights[backend.Network+"://"+backend.Address] = backend.GetWeight()
+	}
+	available, busy := pr.connectionsByBackend()
+	status := pr.BackendStatus()
+
+	table := []map[string]interface{}{}
+	for _, backend := range pr.backendConfigs() {
+		name := backend.Network + "://" + backend.Address
+		table = append(table, map[string]interface{}{
+			"backend":   name,
+			"network":   backend.Network,
+			"address":   backend.Address,
+			"user":      backend.User,
+			"database":  backend.Database,
+			"status":    status[name],
+			"weight":    config.If(weights[name] > 0, weights[name], 1),
+			"available": available[name],
+			"busy":      busy[name],
+		})
+	}
+	return table
+}
+
+// connectionsByBackend returns the numbers of the available and busy server
+// connections of the backends.
+func (pr *Proxy) connectionsByBackend() (map[string]int, map[string]int) {
+	count := func(connections map[string]int) func(_, value interface{}) bool {
+		return func(_, value interface{}) bool {
+			if client, ok := value.(IClient); ok && client != nil && client.GetAddress() != "" {
+				connections[client.GetNetwork()+"://"+client.GetAddress()]++
+			}
+			return true
+		}
+	}
+
+	available, busy := map[string]int{}, map[string]int{}
+	pr.AvailableConnections.ForEach(count(available))
+	pr.busyConnections.ForEach(count(busy))
+	return available, busy
+}
+
+// weightedBackend returns the backend of a new server connection in place of one of
+// the current backend: the available backend the farthest below its share of the
+// server connections by weight, or the current backend if none is farther below.
+func (pr *Proxy) weightedBackend(current *config.Client) *config.Client {
+	backends := pr.backendConfigs()
+	if len(backends) < 2 {
+		return current
+	}
+
+	weights := map[string]int{}
+	for _, backend := range pr.backendList() {
+		weights[backend.Network+"://"+backend.Address] = backend.GetWeight()
+	}
+	available, busy := pr.connectionsByBackend()
+
+	// The new server connection is counted in the total.
+	total, totalWeight := 1, 0
+	for _, backend := range backends {
+		if pr.backendHealth.IsAvailable(backend.Network, backend.Address) {
+			name := backend.Network + "://" + backend.Address
+			total += available[name] + busy[name]
+			totalWeight += weights[name]
+		}
+	}
+	if totalWeight == 0 {
+		return current
+	}
+
+	deficit := func(name string) float64 {
+		return float64(total*weights[name])/float64(totalWeight) -
+			float64(available[name]+busy[name])
+	}
+
+	// The current backend is kept on ties, unless it's removed.
+	chosen, chosenDeficit := current, 0.0
+	if _, ok := weights[current.Network+"://"+current.Address]; ok {
+		chosenDeficit = deficit(current.Network + "://" + current.Address)
+	} else {
+		chosen = nil
+	}
+	for _, backend := range backends {
+		if !pr.backendHealth.IsAvailable(backend.Network, backend.Address) {
+			continue
+		}
+		if share := deficit(backend.Network + "://" + backend.Address); chosen == nil || share > chosenDeficit {
+			chosen, chosenDeficit = backend, share
+		}
+	}
+	return config.If(chosen != nil, chosen, current)
+}
+
+// syncRoutingTable runs the OnRoutingTable hooks with the routing table of the proxy,
+// and applies the changes to the backends returned by the plugins, in order.
+func (pr *Proxy) syncRoutingTable() {
+	if pr.PluginRegistry == nil {
+		return
+	}
+
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "syncRoutingTable")
+	defer span.End()
+
+	table := []interface{}{}
+	for _, backend := range pr.RoutingTable() {
+		table = append(table, backend)
+	}
+	result, err := pr.PluginRegistry.RunLifecycleHookWithResult(
+		plugin.OnRoutingTableHookName, map[string]interface{}{"backends": table}, pr.PluginTimeout)
+	if err != nil {
+		pr.Logger.Error().Err(err).Msg("Failed to run the OnRoutingTable hooks")
+		span.RecordError(err)
+		return
+	}
+
+	changes, ok := result["changes"].([]interface{})
+	if !ok {
+		return
+	}
+	for _, change := range changes {
+		fields, ok := change.(map[string]interface{})
+		if !ok {
+			pr.Logger.Warn().Interface("change", change).Msg("Ignoring an invalid change to the backends")
+			metrics.RoutingChanges.WithLabelValues("unknown", "rejected").Inc()
+			continue
+		}
+		pr.applyRoutingChange(fields)
+	}
+}
+
+// applyRoutingChange applies a change to the backends returned by the plugins.
+func (pr *Proxy) applyRoutingChange(change map[string]interface{}) {
+	action := cast.ToString(change["action"])
+	var err error
+	switch action {
+	case RoutingAdd:
+		err = pr.AddBackend(config.Backend{
+			Network:  cast.ToString(change["network"]),
+			Address:  cast.ToString(change["address"]),
+			User:     cast.ToString(change["user"]),
+			Database: cast.ToString(change["database"]),
+			Password: cast.ToString(change["password"]),
+			Weight:   cast.ToInt(change["weight"]),
+		})
+	case RoutingRemove:
+		err = pr.RemoveBackend(cast.ToString(change["backend"]))
+	case RoutingWeight:
+		err = pr.SetBackendWeight(cast.ToString(change["backend"]), cast.ToInt(change["weight"]))
+	default:
+		action = "unknown"
+		err = errors.New("unknown action")
+	}
+
+	if err != nil {
+		pr.Logger.Warn().Err(err).Fields(change).Msg("Rejected a change to the backends")
+		metrics.RoutingChanges.WithLabelValues(action, "rejected").Inc()
+		return
+	}
+	metrics.RoutingChanges.WithLabelValues(action, "applied").Inc()
+}
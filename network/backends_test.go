@@ -0,0 +1,81 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRoutingTable tests inspecting and changing the backends of the proxy at runtime.
+func TestRoutingTable(t *testing.T) {
+	first := newFakeUpstream(t, func(net.Conn) {})
+	second := newFakeUpstream(t, func(net.Conn) {})
+
+	clientConfig := newTestClientConfig(first.Address())
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	proxy := newTestProxyWithClients(t, clientConfig, client)
+
+	firstBackend, secondBackend := "tcp://"+first.Address(), "tcp://"+second.Address()
+	table := proxy.RoutingTable()
+	require.Len(t, table, 1)
+	assert.Equal(t, firstBackend, table[0]["backend"])
+	assert.Equal(t, BackendHealthy, table[0]["status"])
+	assert.Equal(t, 1, table[0]["weight"])
+	assert.Equal(t, 1, table[0]["available"])
+	assert.Equal(t, 0, table[0]["busy"])
+
+	// The backends are validated like the ones in the config.
+	require.Error(t, proxy.AddBackend(config.Backend{Network: "tcp"}))
+	require.ErrorIs(t, proxy.AddBackend(config.Backend{Network: "tcp", Address: first.Address()}), errBackendExists)
+	require.Error(t, proxy.SetBackendWeight(firstBackend, -1))
+	require.ErrorIs(t, proxy.SetBackendWeight("tcp://localhost:1", 1), errBackendNotFound)
+	require.ErrorIs(t, proxy.RemoveBackend(firstBackend), errLastBackend)
+
+	// The new server connections go to the backend the farthest below its share.
+	require.NoError(t, proxy.AddBackend(config.Backend{Network: "tcp", Address: second.Address(), Weight: 3}))
+	table = proxy.RoutingTable()
+	require.Len(t, table, 2)
+	assert.Equal(t, secondBackend, table[1]["backend"])
+	assert.Equal(t, 3, table[1]["weight"])
+	assert.Equal(t, 0, table[1]["available"])
+	assert.Equal(t, second.Address(), proxy.weightedBackend(clientConfig).Address)
+	require.NoError(t, proxy.SetBackendWeight(secondBackend, 1))
+	require.NoError(t, proxy.SetBackendWeight(firstBackend, 3))
+	assert.Equal(t, first.Address(), proxy.weightedBackend(clientConfig).Address, "the current backend is kept on ties")
+	require.NoError(t, proxy.SetBackendWeight(firstBackend, 1))
+
+	// The server connections of the removed backend are moved to the other backends.
+	require.NoError(t, proxy.RemoveBackend(firstBackend))
+	table = proxy.RoutingTable()
+	require.Len(t, table, 1)
+	assert.Equal(t, secondBackend, table[0]["backend"])
+	assert.Equal(t, 1, table[0]["available"])
+	require.ErrorIs(t, proxy.RemoveBackend(firstBackend), errBackendNotFound)
+
+	// The changes returned by the plugins are applied in order, and the invalid
+	// ones are rejected.
+	added := testutil.ToFloat64(metrics.RoutingChanges.WithLabelValues(RoutingAdd, "applied"))
+	rejected := testutil.ToFloat64(metrics.RoutingChanges.WithLabelValues(RoutingWeight, "rejected"))
+	proxy.applyRoutingChange(map[string]interface{}{
+		"action": RoutingAdd, "network": "tcp", "address": first.Address(), "weight": 2.0,
+	})
+	proxy.applyRoutingChange(map[string]interface{}{
+		"action": RoutingWeight, "backend": secondBackend, "weight": -1.0,
+	})
+	assert.Equal(t, added+1, testutil.ToFloat64(metrics.RoutingChanges.WithLabelValues(RoutingAdd, "applied")))
+	assert.Equal(t, rejected+1, testutil.ToFloat64(metrics.RoutingChanges.WithLabelValues(RoutingWeight, "rejected")))
+	table = proxy.RoutingTable()
+	require.Len(t, table, 2)
+	assert.Equal(t, firstBackend, table[1]["backend"])
+	assert.Equal(t, 2, table[1]["weight"])
+	assert.Equal(t, BackendHealthy, table[1]["status"])
+	assert.Equal(t, 1, table[0]["weight"])
+}
@@ -0,0 +1,61 @@
+package network
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+var errFileDescriptorsNotSupported = errors.New(
+	"counting the file descriptors is not supported on this platform")
+
+// ConnectionBudget is the number of the client connections that all the servers may
+// accept together, so that a flood of them can't take the file descriptors reserved
+// for the admin API and the health checks. It's enforced in the accept path, where
+// the connections above it are closed right away.
+type ConnectionBudget struct {
+	limit int64
+	used  atomic.Int64
+}
+
+// NewConnectionBudget creates a new connection budget of the given number of
+// connections, which may be zero if all of them are taken by the reservation.
+func NewConnectionBudget(limit int) *ConnectionBudget {
+	return &ConnectionBudget{limit: int64(max(limit, 0))}
+}
+
+// Acquire takes a connection from the budget, or returns false if it's exhausted.
+// A nil budget is never exhausted.
+func (b *ConnectionBudget) Acquire() bool {
+	if b == nil {
+		return true
+	}
+	if b.used.Add(1) > b.limit {
+		b.used.Add(-1)
+		return false
+	}
+	return true
+}
+
+// Release gives the connection taken by Acquire back to the budget.
+func (b *ConnectionBudget) Release() {
+	if b == nil {
+		return
+	}
+	b.used.Add(-1)
+}
+
+// Used returns the number of the connections taken from the budget.
+func (b *ConnectionBudget) Used() int {
+	if b == nil {
+		return 0
+	}
+	return int(b.used.Load())
+}
+
+// Limit returns the number of the connections of the budget.
+func (b *ConnectionBudget) Limit() int {
+	if b == nil {
+		return 0
+	}
+	return int(b.limit)
+}
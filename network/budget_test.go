@@ -0,0 +1,33 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConnectionBudget tests taking the connections from the budget up to its limit,
+// and giving them back.
+func TestConnectionBudget(t *testing.T) {
+	budget := NewConnectionBudget(2)
+	assert.True(t, budget.Acquire())
+	assert.True(t, budget.Acquire())
+	assert.False(t, budget.Acquire())
+	assert.Equal(t, 2, budget.Used())
+
+	budget.Release()
+	assert.True(t, budget.Acquire())
+	assert.False(t, budget.Acquire())
+
+	// The budget taken by the reservation accepts no connections.
+	budget = NewConnectionBudget(-10)
+	assert.Equal(t, 0, budget.Limit())
+	assert.False(t, budget.Acquire())
+	assert.Equal(t, 0, budget.Used())
+
+	// A nil budget is never exhausted.
+	var unlimited *ConnectionBudget
+	assert.True(t, unlimited.Acquire())
+	unlimited.Release()
+	assert.Equal(t, 0, unlimited.Used())
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package network
+
+import (
+	"math"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// AvailableFileDescriptors returns the number of the file descriptors that the process
+// can still open, i.e. its soft limit minus the ones already open.
+func AvailableFileDescriptors() (int, error) {
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+	if limit.Cur == unix.RLIM_INFINITY || limit.Cur > math.MaxInt32 {
+		return math.MaxInt32, nil
+	}
+
+	open, err := os.ReadDir("/dev/fd")
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+	return int(limit.Cur) - len(open), nil
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package network
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAvailableFileDescriptors tests counting the file descriptors the process can
+// still open, which go down by the ones it opens.
+func TestAvailableFileDescriptors(t *testing.T) {
+	before, err := AvailableFileDescriptors()
+	require.NoError(t, err)
+	assert.Positive(t, before)
+
+	file, err := os.Open(os.DevNull)
+	require.NoError(t, err)
+	defer file.Close()
+
+	after, err := AvailableFileDescriptors()
+	require.NoError(t, err)
+	assert.Less(t, after, before)
+}
@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package network
+
+// AvailableFileDescriptors is not supported on this platform, which has no limit on
+// the file descriptors of the process like the unix platforms.
+func AvailableFileDescriptors() (int, error) {
+	return 0, errFileDescriptorsNotSupported
+}
@@ -0,0 +1,246 @@
+package network
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+)
+
+const (
+	// pgBackendKeyData is the type of the BackendKeyData backend message.
+	pgBackendKeyData = 'K'
+	// pgBackendKeyDataLength is the length of the BackendKeyData message, excluding its type.
+	pgBackendKeyDataLength = 12
+	// pgCancelRequestCode is the request code of the CancelRequest message.
+	pgCancelRequestCode = 80877102
+	// pgCancelRequestLength is the length of the CancelRequest message.
+	pgCancelRequestLength = 16
+)
+
+// BackendKey is the process ID and the secret key of a PostgreSQL session,
+// which the clients use for canceling the running queries.
+type BackendKey struct {
+	ProcessID uint32
+	SecretKey uint32
+}
+
+// cancelTarget is the server session a cancel request is forwarded to.
+type cancelTarget struct {
+	key     BackendKey
+	network string
+	address string
+}
+
+// CancelKeys translates the backend keys of the server sessions to the keys
+// issued by GatewayD. The clients only see the issued keys, so that their
+// cancel requests, which are sent on a separate connection, can be forwarded
+// to the server session they belong to.
+type CancelKeys struct {
+	targets map[BackendKey]cancelTarget
+	mu      sync.RWMutex
+}
+
+// NewCancelKeys creates a new cancel key translator.
+func NewCancelKeys() *CancelKeys {
+	return &CancelKeys{
+		targets: map[BackendKey]cancelTarget{},
+	}
+}
+
+// Translate replaces the BackendKeyData message in the server response, if any,
+// with a newly issued key that maps to the server session at the given address.
+// The response is modified in place. It returns the issued key.
+func (ck *CancelKeys) Translate(response []byte, network, address string) (BackendKey, bool) {
+	if ck == nil {
+		return BackendKey{}, false
+	}
+
+	body, ok := backendKeyData(response)
+	if !ok {
+		return BackendKey{}, false
+	}
+
+	issued, ok := ck.issue(cancelTarget{key: decodeBackendKey(body), network: network, address: address})
+	if !ok {
+		return BackendKey{}, false
+	}
+
+	binary.BigEndian.PutUint32(body[0:4], issued.ProcessID)
+	binary.BigEndian.PutUint32(body[4:8], issued.SecretKey)
+	return issued, true
+}
+
+// Retarget maps the issued key to the server session of the BackendKeyData message
+// in the server response, e.g. after the client's session is handed off to another
+// backend, so that the client keeps canceling its queries with the same key.
+func (ck *CancelKeys) Retarget(issued BackendKey, response []byte, network, address string) bool {
+	if ck == nil {
+		return false
+	}
+
+	body, ok := backendKeyData(response)
+	if !ok {
+		return false
+	}
+
+	ck.mu.Lock()
+	defer ck.mu.Unlock()
+	if _, exists := ck.targets[issued]; !exists {
+		return false
+	}
+	ck.targets[issued] = cancelTarget{key: decodeBackendKey(body), network: network, address: address}
+	return true
+}
+
+// backendKeyData returns the body of the BackendKeyData message in the server
+// response, if any.
+func backendKeyData(response []byte) ([]byte, bool) {
+	for offset := 0; offset+pgHeaderLength <= len(response); {
+		length := int(binary.BigEndian.Uint32(response[offset+1 : offset+pgHeaderLength]))
+		end := offset + 1 + length
+		if length < 4 || end > len(response) {
+			break
+		}
+
+		if response[offset] == pgBackendKeyData && length == pgBackendKeyDataLength {
+			return response[offset+pgHeaderLength : end], true
+		}
+
+		offset = end
+	}
+
+	return nil, false
+}
+
+// decodeBackendKey decodes the body of a BackendKeyData message.
+func decodeBackendKey(body []byte) BackendKey {
+	return BackendKey{
+		ProcessID: binary.BigEndian.Uint32(body[0:4]),
+		SecretKey: binary.BigEndian.Uint32(body[4:8]),
+	}
+}
+
+// lookup returns the server session of the issued key.
+func (ck *CancelKeys) lookup(issued BackendKey) (cancelTarget, bool) {
+	if ck == nil {
+		return cancelTarget{}, false
+	}
+
+	ck.mu.RLock()
+	defer ck.mu.RUnlock()
+	target, ok := ck.targets[issued]
+	return target, ok
+}
+
+// Remove forgets the issued key, e.g. when the client disconnects.
+func (ck *CancelKeys) Remove(issued BackendKey) {
+	if ck == nil {
+		return
+	}
+
+	ck.mu.Lock()
+	defer ck.mu.Unlock()
+	delete(ck.targets, issued)
+}
+
+// Size returns the number of issued keys.
+func (ck *CancelKeys) Size() int {
+	if ck == nil {
+		return 0
+	}
+
+	ck.mu.RLock()
+	defer ck.mu.RUnlock()
+	return len(ck.targets)
+}
+
+// issue issues a new random key for the server session.
+func (ck *CancelKeys) issue(target cancelTarget) (BackendKey, bool) {
+	ck.mu.Lock()
+	defer ck.mu.Unlock()
+
+	random := make([]byte, 8)
+	for {
+		if _, err := rand.Read(random); err != nil {
+			return BackendKey{}, false
+		}
+
+		issued := BackendKey{
+			ProcessID: binary.BigEndian.Uint32(random[0:4]),
+			SecretKey: binary.BigEndian.Uint32(random[4:8]),
+		}
+		if _, exists := ck.targets[issued]; !exists {
+			ck.targets[issued] = target
+			return issued, true
+		}
+	}
+}
+
+// DecodeCancelRequest returns the backend key of a PostgreSQL CancelRequest message.
+func DecodeCancelRequest(data []byte) (BackendKey, bool) {
+	if len(data) != pgCancelRequestLength {
+		return BackendKey{}, false
+	}
+
+	if binary.BigEndian.Uint32(data[0:4]) != pgCancelRequestLength ||
+		binary.BigEndian.Uint32(data[4:8]) != pgCancelRequestCode {
+		return BackendKey{}, false
+	}
+
+	return BackendKey{
+		ProcessID: binary.BigEndian.Uint32(data[8:12]),
+		SecretKey: binary.BigEndian.Uint32(data[12:16]),
+	}, true
+}
+
+// CreatePgCancelRequest creates a PostgreSQL CancelRequest message for the backend key.
+func CreatePgCancelRequest(key BackendKey) []byte {
+	request := make([]byte, pgCancelRequestLength)
+	binary.BigEndian.PutUint32(request[0:4], pgCancelRequestLength)
+	binary.BigEndian.PutUint32(request[4:8], pgCancelRequestCode)
+	binary.BigEndian.PutUint32(request[8:12], key.ProcessID)
+	binary.BigEndian.PutUint32(request[12:16], key.SecretKey)
+	return request
+}
+
+// sendCancelRequest sends the cancel request to the server session on a new
+// connection, as required by PostgreSQL. The server closes the connection
+// without a response.
+func sendCancelRequest(target cancelTarget, dialTimeout time.Duration) error {
+	conn, err := dialNetwork(target.network, target.address, dialTimeout)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(CreatePgCancelRequest(target.key))
+	return err //nolint:wrapcheck
+}
+
+// cancelServerSession sends a CancelRequest to the server session of the server
+// connection, before the server connection is recycled, so that the server doesn't
+// keep running the request of the client, e.g. after a receive timeout. It needs the
+// BackendKeyData message of the pre-authenticated session. The cancel request is best
+// effort, since the server may finish the request before it arrives.
+func (pr *Proxy) cancelServerSession(client IClient) {
+	body, ok := backendKeyData(client.StartupResponse())
+	if !ok {
+		return
+	}
+
+	dialTimeout := config.DefaultDialTimeout
+	if pr.ClientConfig != nil {
+		dialTimeout = pr.ClientConfig.DialTimeout
+	}
+	target := cancelTarget{key: decodeBackendKey(body), network: client.GetNetwork(), address: client.GetAddress()}
+	if err := sendCancelRequest(target, dialTimeout); err != nil {
+		pr.Logger.Warn().Err(err).Str("address", target.address).Msg(
+			"Failed to cancel the request on the server connection before recycling it")
+		return
+	}
+	metrics.CancelRequests.Inc()
+}
@@ -0,0 +1,75 @@
+package network
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecodeCancelRequest tests decoding the backend key of a cancel request.
+func TestDecodeCancelRequest(t *testing.T) {
+	key := BackendKey{ProcessID: 1234, SecretKey: 5678}
+	decoded, ok := DecodeCancelRequest(CreatePgCancelRequest(key))
+	require.True(t, ok)
+	assert.Equal(t, key, decoded)
+
+	_, ok = DecodeCancelRequest(CreatePgStartupPacket())
+	assert.False(t, ok)
+	_, ok = DecodeCancelRequest([]byte{0x00, 0x00, 0x00, 0x8, 0x04, 0xd2, 0x16, 0x2f})
+	assert.False(t, ok)
+}
+
+// TestCancelKeys tests that the cancel requests of the clients reach the server session
+// whose backend key is translated.
+func TestCancelKeys(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer backend.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	keyData, err := (&pgproto3.BackendKeyData{ProcessID: 1234, SecretKey: 5678}).Encode(nil)
+	require.NoError(t, err)
+	ready, err := (&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)}).Encode(nil)
+	require.NoError(t, err)
+	response := append(append([]byte{}, keyData...), ready...)
+
+	cancelKeys := NewCancelKeys()
+	issued, ok := cancelKeys.Translate(response, "tcp", backend.Addr().String())
+	require.True(t, ok)
+	assert.Equal(t, 1, cancelKeys.Size())
+
+	// The client receives the issued key instead of the backend key.
+	msg := &pgproto3.BackendKeyData{}
+	require.NoError(t, msg.Decode(response[pgHeaderLength:len(keyData)]))
+	assert.Equal(t, issued, BackendKey{ProcessID: msg.ProcessID, SecretKey: msg.SecretKey})
+	assert.Equal(t, ready, response[len(keyData):])
+
+	// The cancel request is forwarded with the backend key.
+	target, ok := cancelKeys.lookup(issued)
+	require.True(t, ok)
+	require.NoError(t, sendCancelRequest(target, time.Second))
+	assert.Equal(t, CreatePgCancelRequest(BackendKey{ProcessID: 1234, SecretKey: 5678}), <-received)
+
+	cancelKeys.Remove(issued)
+	_, ok = cancelKeys.lookup(issued)
+	assert.False(t, ok)
+
+	// Responses without a BackendKeyData message are left intact.
+	_, ok = cancelKeys.Translate(ready, "tcp", backend.Addr().String())
+	assert.False(t, ok)
+}
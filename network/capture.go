@@ -0,0 +1,372 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+)
+
+// CaptureDirection is the direction of the traffic in a captured frame.
+type CaptureDirection byte
+
+const (
+	// CaptureFromClient is the traffic received from the client.
+	CaptureFromClient CaptureDirection = 'C'
+	// CaptureFromServer is the traffic received from the server.
+	CaptureFromServer CaptureDirection = 'S'
+
+	// CaptureExtension is the extension of the capture files.
+	CaptureExtension = ".gwdcap"
+
+	// captureFrameHeaderSize is the size of the direction, the timestamp
+	// and the length of the payload of a frame.
+	captureFrameHeaderSize = 1 + 8 + 4
+	// captureWriteBufferSize is the size of the buffer of the capture file,
+	// so that the proxy doesn't make a system call for every frame.
+	captureWriteBufferSize = 64 * 1024
+)
+
+var (
+	// captureMagic is the header of the capture files, followed by the version of the format.
+	captureMagic = []byte("GWDCAP\x01")
+
+	errInvalidCapture = errors.New("invalid capture file")
+
+	// captureFileName replaces the characters of the remote addresses that
+	// aren't allowed in the names of the capture files.
+	captureFileName = strings.NewReplacer(":", "_", "/", "_", "[", "", "]", "")
+)
+
+// CaptureFrame is a chunk of the traffic of a connection, as received by the proxy.
+type CaptureFrame struct {
+	Direction CaptureDirection
+	Timestamp time.Time
+	Payload   []byte
+}
+
+// Capture writes the traffic of a connection to a capture file for debugging, e.g.
+// for reproducing a bug in the protocol by replaying the capture. The file starts
+// with a header, followed by the frames, each with its direction, the timestamp in
+// nanoseconds and the length of the payload in big-endian, and the payload.
+// The frames are written as they are, so the capture contains the passwords and
+// the data of the connection. The file never grows past the max size: once a frame
+// doesn't fit, the capture is truncated and the rest of the traffic is dropped.
+type Capture struct {
+	Path    string
+	MaxSize int64
+
+	file      *os.File
+	writer    *bufio.Writer
+	size      int64
+	truncated bool
+	closed    bool
+	mu        sync.Mutex
+}
+
+// NewCapture creates the capture file at the path, which must not exist.
+func NewCapture(path string, maxSize int64) (*Capture, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the capture file: %w", err)
+	}
+
+	capture := &Capture{
+		Path:    path,
+		MaxSize: maxSize,
+		file:    file,
+		writer:  bufio.NewWriterSize(file, captureWriteBufferSize),
+		size:    int64(len(captureMagic)),
+	}
+	if _, err := capture.writer.Write(captureMagic); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write the capture header: %w", err)
+	}
+
+	return capture, nil
+}
+
+// Record writes the payload to the capture as a frame. It returns false if the
+// frame is dropped, because the capture is closed or the max size is reached.
+func (c *Capture) Record(direction CaptureDirection, payload []byte) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || c.truncated {
+		return false
+	}
+	frameSize := int64(captureFrameHeaderSize + len(payload))
+	if c.size+frameSize > c.MaxSize {
+		c.truncated = true
+		return false
+	}
+
+	var header [captureFrameHeaderSize]byte
+	header[0] = byte(direction)
+	binary.BigEndian.PutUint64(header[1:9], uint64(time.Now().UnixNano()))
+	binary.BigEndian.PutUint32(header[9:], uint32(len(payload)))
+	if _, err := c.writer.Write(header[:]); err != nil {
+		c.truncated = true
+		return false
+	}
+	if _, err := c.writer.Write(payload); err != nil {
+		c.truncated = true
+		return false
+	}
+	c.size += frameSize
+
+	return true
+}
+
+// Size returns the size of the capture file in bytes.
+func (c *Capture) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// IsTruncated returns true if the traffic is dropped because the max size is reached.
+func (c *Capture) IsTruncated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.truncated
+}
+
+// Close flushes the capture and closes the capture file.
+func (c *Capture) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	flushErr := c.writer.Flush()
+	if err := c.file.Close(); err != nil {
+		return fmt.Errorf("failed to close the capture file: %w", err)
+	}
+	if flushErr != nil {
+		return fmt.Errorf("failed to flush the capture file: %w", flushErr)
+	}
+	return nil
+}
+
+// CaptureReader reads the frames of a capture file.
+type CaptureReader struct {
+	reader *bufio.Reader
+}
+
+// NewCaptureReader returns a reader of the capture, after checking its header.
+func NewCaptureReader(reader io.Reader) (*CaptureReader, error) {
+	buffered := bufio.NewReader(reader)
+	header := make([]byte, len(captureMagic))
+	if _, err := io.ReadFull(buffered, header); err != nil || !bytes.Equal(header, captureMagic) {
+		return nil, errInvalidCapture
+	}
+	return &CaptureReader{reader: buffered}, nil
+}
+
+// Next returns the next frame of the capture, or io.EOF at the end of the capture.
+func (r *CaptureReader) Next() (*CaptureFrame, error) {
+	var header [captureFrameHeaderSize]byte
+	if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: truncated frame", errInvalidCapture)
+	}
+
+	direction := CaptureDirection(header[0])
+	if direction != CaptureFromClient && direction != CaptureFromServer {
+		return nil, fmt.Errorf("%w: unknown direction %q", errInvalidCapture, header[0])
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[9:]))
+	if _, err := io.ReadFull(r.reader, payload); err != nil {
+		return nil, fmt.Errorf("%w: truncated frame", errInvalidCapture)
+	}
+
+	return &CaptureFrame{
+		Direction: direction,
+		Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(header[1:9]))),
+		Payload:   payload,
+	}, nil
+}
+
+// ParseCaptureClients parses the IP addresses and the CIDR ranges of the clients
+// whose connections are captured. The invalid entries are returned separately.
+func ParseCaptureClients(clients []string) ([]*net.IPNet, []string) {
+	networks := []*net.IPNet{}
+	invalid := []string{}
+	for _, client := range clients {
+		if _, network, err := net.ParseCIDR(client); err == nil {
+			networks = append(networks, network)
+			continue
+		}
+		if ip := net.ParseIP(client); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		invalid = append(invalid, client)
+	}
+	return networks, invalid
+}
+
+// defaultCaptureDir returns the directory of the capture files if none is configured.
+func defaultCaptureDir() string {
+	return filepath.Join(os.TempDir(), "gatewayd-captures")
+}
+
+// SetCapture starts or stops capturing the traffic of the client's connections, either
+// the remote address of a connection, e.g. "10.0.0.1:51234", or an IP address for all
+// its connections. The connections opened later by the client are only captured if the
+// client is one of the capture clients in the config. It returns false if the client
+// has no connection to the proxy.
+func (pr *Proxy) SetCapture(client string, capture bool) bool {
+	conns := []*ConnWrapper{}
+	pr.busyConnections.ForEach(func(key, _ interface{}) bool {
+		if conn, ok := key.(*ConnWrapper); ok {
+			remote := RemoteAddr(conn.Conn())
+			if host, _, err := net.SplitHostPort(remote); remote == client || (err == nil && host == client) {
+				conns = append(conns, conn)
+			}
+		}
+		return true
+	})
+
+	for _, conn := range conns {
+		if capture {
+			pr.startCapture(conn)
+		} else {
+			pr.stopCapture(conn)
+		}
+	}
+
+	return len(conns) > 0
+}
+
+// Captures returns the capture files of the captured connections by their remote address.
+func (pr *Proxy) Captures() map[string]string {
+	captures := map[string]string{}
+	pr.busyConnections.ForEach(func(key, _ interface{}) bool {
+		if conn, ok := key.(*ConnWrapper); ok {
+			if capture := conn.Capture(); capture != nil {
+				captures[RemoteAddr(conn.Conn())] = capture.Path
+			}
+		}
+		return true
+	})
+	return captures
+}
+
+// shouldCapture returns true if the client of the connection is one of the capture clients.
+func (pr *Proxy) shouldCapture(conn net.Conn) bool {
+	if len(pr.captureNetworks) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(RemoteAddr(conn))
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range pr.captureNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// startCapture starts capturing the traffic of the connection to a new capture file,
+// unless it's already captured or the max number of captured connections is reached.
+func (pr *Proxy) startCapture(conn *ConnWrapper) {
+	if conn.Capture() != nil {
+		return
+	}
+
+	if pr.activeCaptures.Add(1) > config.DefaultMaxCaptures {
+		pr.activeCaptures.Add(-1)
+		pr.Logger.Warn().Str("remote", RemoteAddr(conn.Conn())).Msg(
+			"Not capturing the connection, the max number of captured connections is reached")
+		return
+	}
+
+	remote := RemoteAddr(conn.Conn())
+	name := fmt.Sprintf("%s-%d", captureFileName.Replace(remote), time.Now().UnixNano())
+	capture, err := func() (*Capture, error) {
+		if err := os.MkdirAll(pr.CaptureDir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create the capture directory: %w", err)
+		}
+		return NewCapture(filepath.Join(pr.CaptureDir, name+CaptureExtension), pr.CaptureMaxSize)
+	}()
+	if err != nil {
+		pr.activeCaptures.Add(-1)
+		pr.Logger.Error().Err(err).Str("remote", remote).Msg("Failed to capture the connection")
+		return
+	}
+
+	if previous := conn.SetCapture(capture); previous != nil {
+		// Another capture is started in the meantime.
+		conn.SetCapture(previous)
+		pr.activeCaptures.Add(-1)
+		capture.Close()
+		os.Remove(capture.Path)
+		return
+	}
+
+	pr.Logger.Info().Fields(
+		map[string]interface{}{
+			"remote":  remote,
+			"path":    capture.Path,
+			"maxSize": capture.MaxSize,
+		},
+	).Msg("Capturing the connection")
+}
+
+// stopCapture stops capturing the traffic of the connection, if it's captured.
+func (pr *Proxy) stopCapture(conn *ConnWrapper) {
+	capture := conn.SetCapture(nil)
+	if capture == nil {
+		return
+	}
+	pr.activeCaptures.Add(-1)
+
+	if err := capture.Close(); err != nil {
+		pr.Logger.Error().Err(err).Str("path", capture.Path).Msg("Failed to close the capture")
+	}
+	pr.Logger.Info().Fields(
+		map[string]interface{}{
+			"remote":    RemoteAddr(conn.Conn()),
+			"path":      capture.Path,
+			"size":      capture.Size(),
+			"truncated": capture.IsTruncated(),
+		},
+	).Msg("Stopped capturing the connection")
+}
@@ -0,0 +1,139 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readCapture returns the frames of the capture file.
+func readCapture(t *testing.T, path string) []*CaptureFrame {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	reader, err := NewCaptureReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	frames := []*CaptureFrame{}
+	for {
+		frame, err := reader.Next()
+		if err == io.EOF {
+			return frames
+		}
+		require.NoError(t, err)
+		frames = append(frames, frame)
+	}
+}
+
+// TestCapture tests writing the frames to a capture file and reading them back.
+func TestCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture"+CaptureExtension)
+	capture, err := NewCapture(path, 64)
+	require.NoError(t, err)
+
+	assert.True(t, capture.Record(CaptureFromClient, []byte("request")))
+	assert.True(t, capture.Record(CaptureFromServer, []byte("response")))
+	// The frame doesn't fit, so the capture is truncated.
+	assert.False(t, capture.Record(CaptureFromClient, bytes.Repeat([]byte("x"), 64)))
+	assert.True(t, capture.IsTruncated())
+	assert.False(t, capture.Record(CaptureFromServer, []byte("r")))
+	require.NoError(t, capture.Close())
+	assert.False(t, capture.Record(CaptureFromClient, []byte("request")))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, capture.Size(), info.Size())
+	assert.LessOrEqual(t, info.Size(), int64(64))
+
+	frames := readCapture(t, path)
+	require.Len(t, frames, 2)
+	assert.Equal(t, CaptureFromClient, frames[0].Direction)
+	assert.Equal(t, []byte("request"), frames[0].Payload)
+	assert.Equal(t, CaptureFromServer, frames[1].Direction)
+	assert.Equal(t, []byte("response"), frames[1].Payload)
+	assert.False(t, frames[1].Timestamp.Before(frames[0].Timestamp))
+
+	// The capture file must not be overwritten.
+	_, err = NewCapture(path, 64)
+	assert.Error(t, err)
+
+	_, err = NewCaptureReader(bytes.NewReader([]byte("not a capture")))
+	assert.ErrorIs(t, err, errInvalidCapture)
+}
+
+// TestParseCaptureClients tests parsing the IP addresses and CIDR ranges of the captured clients.
+func TestParseCaptureClients(t *testing.T) {
+	networks, invalid := ParseCaptureClients(
+		[]string{"10.0.0.1", "192.168.0.0/16", "::1", "localhost"})
+	assert.Equal(t, []string{"localhost"}, invalid)
+	require.Len(t, networks, 3)
+	assert.True(t, networks[0].Contains(net.ParseIP("10.0.0.1")))
+	assert.False(t, networks[0].Contains(net.ParseIP("10.0.0.2")))
+	assert.True(t, networks[1].Contains(net.ParseIP("192.168.1.1")))
+	assert.True(t, networks[2].Contains(net.ParseIP("::1")))
+}
+
+// TestProxyCapture tests capturing the traffic of a connection through the proxy.
+func TestProxyCapture(t *testing.T) {
+	query, err := (&pgproto3.Query{String: "SELECT 1"}).Encode(nil)
+	require.NoError(t, err)
+	ready, err := (&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)}).Encode(nil)
+	require.NoError(t, err)
+
+	upstream := newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		buffer := make([]byte, config.DefaultChunkSize)
+		if _, err := conn.Read(buffer); err != nil {
+			return
+		}
+		_, _ = conn.Write(ready)
+		// Keep the connection open until the client goes away.
+		_, _ = conn.Read(buffer)
+	})
+	proxy := newTestProxy(t, upstream.Address())
+	proxy.CaptureDir = t.TempDir()
+
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn(query)})
+	require.Nil(t, proxy.Connect(conn))
+	assert.Empty(t, proxy.Captures())
+
+	// The client must have a connection to the proxy.
+	assert.False(t, proxy.SetCapture("10.0.0.1", true))
+	require.True(t, proxy.SetCapture("127.0.0.1", true))
+	captures := proxy.Captures()
+	require.Contains(t, captures, "127.0.0.1:54321")
+	path := captures["127.0.0.1:54321"]
+
+	require.Nil(t, proxy.PassThroughToServer(conn, NewStack()))
+	require.Nil(t, proxy.PassThroughToClient(conn, NewStack()))
+
+	// The capture is closed on disconnect.
+	require.Nil(t, proxy.Disconnect(conn))
+	assert.Empty(t, proxy.Captures())
+	assert.Zero(t, proxy.activeCaptures.Load())
+
+	frames := readCapture(t, path)
+	require.Len(t, frames, 2)
+	assert.Equal(t, CaptureFromClient, frames[0].Direction)
+	assert.Equal(t, query, frames[0].Payload)
+	assert.Equal(t, CaptureFromServer, frames[1].Direction)
+	assert.Equal(t, ready, frames[1].Payload)
+
+	// The connections of the capture clients in the config are captured on connect.
+	proxy.captureNetworks, _ = ParseCaptureClients([]string{"127.0.0.0/8"})
+	conn = NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	assert.NotNil(t, conn.Capture())
+	assert.True(t, proxy.SetCapture("127.0.0.1:54321", false))
+	assert.Nil(t, conn.Capture())
+	require.Nil(t, proxy.Disconnect(conn))
+}
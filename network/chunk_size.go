@@ -0,0 +1,62 @@
+package network
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/metrics"
+)
+
+// ChunkSizer adapts the size of the chunks the responses are read in. The chunk
+// doubles, up to the max, every time a read fills it, so that the large responses
+// are read in fewer system calls, and shrinks back to the min once no read has
+// filled it for the shrink period, so that the connections don't keep the memory
+// for the occasional large response.
+type ChunkSizer struct {
+	Min          int
+	Max          int
+	ShrinkPeriod time.Duration
+
+	size     atomic.Int64
+	lastFull atomic.Int64
+}
+
+// NewChunkSizer creates a new chunk sizer, or returns nil if the chunk can't grow.
+func NewChunkSizer(minSize, maxSize int, shrinkPeriod time.Duration) *ChunkSizer {
+	if maxSize <= minSize || minSize <= 0 {
+		return nil
+	}
+
+	sizer := &ChunkSizer{
+		Min:          minSize,
+		Max:          maxSize,
+		ShrinkPeriod: shrinkPeriod,
+	}
+	sizer.size.Store(int64(minSize))
+	return sizer
+}
+
+// Size returns the size of the next chunk, after shrinking it if it's been idle.
+func (s *ChunkSizer) Size() int {
+	size := s.size.Load()
+	if size > int64(s.Min) && s.ShrinkPeriod > 0 &&
+		time.Since(time.Unix(0, s.lastFull.Load())) > s.ShrinkPeriod &&
+		s.size.CompareAndSwap(size, int64(s.Min)) {
+		metrics.ReceiveChunkResizes.WithLabelValues("shrink").Inc()
+		return s.Min
+	}
+	return int(size)
+}
+
+// Observe records the read of the chunk and grows the chunk if the read filled it.
+func (s *ChunkSizer) Observe(read, chunkSize int) {
+	if read < chunkSize {
+		return
+	}
+	s.lastFull.Store(time.Now().UnixNano())
+
+	grown := min(int64(chunkSize)*2, int64(s.Max))
+	if size := s.size.Load(); grown > size && s.size.CompareAndSwap(size, grown) {
+		metrics.ReceiveChunkResizes.WithLabelValues("grow").Inc()
+	}
+}
@@ -0,0 +1,67 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChunkSizer tests growing the chunk on the full reads and shrinking it when idle.
+func TestChunkSizer(t *testing.T) {
+	// The chunk can't grow past a smaller max.
+	assert.Nil(t, NewChunkSizer(1024, 0, time.Minute))
+	assert.Nil(t, NewChunkSizer(1024, 512, time.Minute))
+
+	sizer := NewChunkSizer(1024, 3000, time.Minute)
+	require.NotNil(t, sizer)
+	assert.Equal(t, 1024, sizer.Size())
+
+	// The chunk isn't filled.
+	sizer.Observe(100, 1024)
+	assert.Equal(t, 1024, sizer.Size())
+
+	sizer.Observe(1024, 1024)
+	assert.Equal(t, 2048, sizer.Size())
+	sizer.Observe(2048, 2048)
+	assert.Equal(t, 3000, sizer.Size())
+	sizer.Observe(3000, 3000)
+	assert.Equal(t, 3000, sizer.Size())
+
+	// The chunk shrinks back after the shrink period without a full read.
+	sizer.lastFull.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	assert.Equal(t, 1024, sizer.Size())
+}
+
+// TestClientReceiveGrowsChunk tests that the client reads a large response in growing chunks.
+func TestClientReceiveGrowsChunk(t *testing.T) {
+	response := bytes.Repeat([]byte("x"), 4096)
+	upstream := newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		_, _ = conn.Write(response)
+		// Keep the connection open until the client goes away.
+		_, _ = conn.Read(make([]byte, 1))
+	})
+
+	clientConfig := newTestClientConfig(upstream.Address())
+	clientConfig.ReceiveChunkSize = 512
+	clientConfig.MaxReceiveChunkSize = 2048
+	clientConfig.ReceiveChunkShrinkPeriod = time.Minute
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	defer client.Close()
+
+	received := []byte{}
+	for len(received) < len(response) {
+		_, data, err := client.Receive()
+		require.Nil(t, err)
+		received = append(received, data...)
+	}
+	assert.Equal(t, response, received)
+	assert.Equal(t, 2048, client.chunkSizer.Size())
+}
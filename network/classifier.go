@@ -0,0 +1,267 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+
+	pgAnalyze "github.com/pganalyze/pg_query_go/v5"
+	pgParser "github.com/wasilibs/go-pgquery"
+)
+
+// pgBind is the message type of the Bind message of the extended query protocol.
+const pgBind = 'B'
+
+const (
+	// IncompleteRequest is the command of the requests whose last message is incomplete
+	// or malformed, which can't be classified, so they count as writes.
+	IncompleteRequest = "an incomplete message"
+	// UnparsableQuery is the command of the queries that fail to parse, which can't be
+	// classified, so they count as writes. The server would reject them anyway.
+	UnparsableQuery = "an unparsable query"
+	// UnknownStatement is the command of the prepared statements that aren't tracked,
+	// e.g. the ones prepared by the PREPARE statement in an earlier request, which
+	// can't be classified, so they count as writes.
+	UnknownStatement = "an unknown prepared statement"
+)
+
+// commandTags are the commands of the statements whose command isn't the name of
+// their node type, e.g. CreateStmt for CREATE TABLE.
+var commandTags = map[string]string{
+	"CreateStmt":            "CREATE TABLE",
+	"IndexStmt":             "CREATE INDEX",
+	"ViewStmt":              "CREATE VIEW",
+	"CreateSeqStmt":         "CREATE SEQUENCE",
+	"CreateFunctionStmt":    "CREATE FUNCTION",
+	"RuleStmt":              "CREATE RULE",
+	"DefineStmt":            "CREATE",
+	"AlterSeqStmt":          "ALTER SEQUENCE",
+	"RenameStmt":            "ALTER",
+	"AlterObjectSchemaStmt": "ALTER",
+	"AlterOwnerStmt":        "ALTER",
+	"RefreshMatViewStmt":    "REFRESH MATERIALIZED VIEW",
+	"SecLabelStmt":          "SECURITY LABEL",
+	"CheckPointStmt":        "CHECKPOINT",
+}
+
+// wordBoundary is the boundary of the words of the names of the node types.
+var wordBoundary = regexp.MustCompile(`([a-z])([A-Z])`)
+
+// WriteCommand returns the command of the first statement in the query that
+// writes to the database, e.g. INSERT, or false if the query only reads. The query
+// is parsed by the PostgreSQL parser, and only the statements known to read, e.g.
+// SELECT, SHOW and BEGIN, count as reads. The functions that write, e.g.
+// SELECT nextval('seq'), count as reads too, since they can't be told apart.
+func WriteCommand(query string) (string, bool) {
+	return newClassifier(nil).queryWriteCommand(query)
+}
+
+// RequestWriteCommand returns the command of the first statement that writes to
+// the database in the simple queries, the Parse messages and the Bind messages of
+// the request. The prepared statements executed by name, with EXECUTE or Bind, are
+// classified by their query, as tracked in the prepared statements of the session or
+// prepared earlier in the request. The untyped messages, e.g. the startup message,
+// are reads, and the requests with an incomplete or malformed message fail closed
+// with IncompleteRequest, since the rest of the message may be a write.
+func RequestWriteCommand(request []byte, statements *PreparedStatements) (string, bool) {
+	if len(request) >= 4 && int(binary.BigEndian.Uint32(request[0:4])) == len(request) {
+		return "", false
+	}
+
+	requestClassifier := newClassifier(statements)
+	for offset := 0; offset < len(request); {
+		if offset+pgHeaderLength > len(request) {
+			return IncompleteRequest, true
+		}
+		length := int(binary.BigEndian.Uint32(request[offset+1 : offset+pgHeaderLength]))
+		end := offset + 1 + length
+		if length < 4 || end > len(request) {
+			return IncompleteRequest, true
+		}
+
+		body := request[offset+pgHeaderLength : end]
+		var command string
+		var write bool
+		switch request[offset] {
+		case pgQuery:
+			command, write = requestClassifier.queryWriteCommand(cString(body))
+		case pgParse:
+			// The name of the prepared statement is followed by the query.
+			name := cString(body)
+			command, write = requestClassifier.queryWriteCommand(cString(body[min(len(name)+1, len(body)):]))
+			if name != "" {
+				requestClassifier.prepared[name] = command
+			}
+		case pgBind:
+			// The name of the portal is followed by the name of the prepared statement.
+			// The unnamed statement is parsed, and so classified, right before.
+			portal := cString(body)
+			if name := cString(body[min(len(portal)+1, len(body)):]); name != "" {
+				command, write = requestClassifier.statementWriteCommand(name)
+			}
+		}
+		if write {
+			return command, true
+		}
+
+		offset = end
+	}
+	return "", false
+}
+
+// classifier classifies the statements of a request, with the prepared statements
+// of the session and the ones prepared by the request, by name, with the commands
+// of the prepared statements that write.
+type classifier struct {
+	session  *PreparedStatements
+	prepared map[string]string
+}
+
+// newClassifier creates a new classifier with the prepared statements of the session.
+func newClassifier(session *PreparedStatements) *classifier {
+	return &classifier{session: session, prepared: map[string]string{}}
+}
+
+// queryWriteCommand returns the command of the first statement in the query that
+// writes to the database.
+func (c *classifier) queryWriteCommand(query string) (string, bool) {
+	if strings.TrimSpace(query) == "" {
+		return "", false
+	}
+
+	tree, err := pgParser.Parse(query)
+	if err != nil {
+		return UnparsableQuery, true
+	}
+	for _, statement := range tree.GetStmts() {
+		if command, ok := c.writeCommand(statement.GetStmt()); ok {
+			return command, true
+		}
+	}
+	return "", false
+}
+
+// statementWriteCommand returns the command of the prepared statement if it writes
+// to the database. The prepared statements that aren't tracked fail closed.
+func (c *classifier) statementWriteCommand(name string) (string, bool) {
+	if command, ok := c.prepared[name]; ok {
+		return command, command != ""
+	}
+	query, ok := c.session.Query(name)
+	if !ok {
+		return UnknownStatement, true
+	}
+
+	// The statements of the session are classified without the ones of the request.
+	command, write := newClassifier(c.session).queryWriteCommand(query)
+	c.prepared[name] = command
+	return command, write
+}
+
+// writeCommand returns the command of the statement if it writes to the database.
+//
+//nolint:cyclop
+func (c *classifier) writeCommand(node *pgAnalyze.Node) (string, bool) {
+	switch statement := node.GetNode().(type) {
+	case nil:
+		return "", false
+	case *pgAnalyze.Node_SelectStmt:
+		// SELECT INTO creates a table.
+		if statement.SelectStmt.GetIntoClause() != nil {
+			return "SELECT INTO", true
+		}
+		return c.selectWriteCommand(statement.SelectStmt)
+	case *pgAnalyze.Node_ExplainStmt:
+		// EXPLAIN only runs the statement if it's analyzed.
+		for _, option := range statement.ExplainStmt.GetOptions() {
+			if option.GetDefElem().GetDefname() == "analyze" && isEnabled(option.GetDefElem()) {
+				return c.writeCommand(statement.ExplainStmt.GetQuery())
+			}
+		}
+		return "", false
+	case *pgAnalyze.Node_PrepareStmt:
+		command, write := c.writeCommand(statement.PrepareStmt.GetQuery())
+		c.prepared[statement.PrepareStmt.GetName()] = command
+		return command, write
+	case *pgAnalyze.Node_ExecuteStmt:
+		return c.statementWriteCommand(statement.ExecuteStmt.GetName())
+	case *pgAnalyze.Node_DeclareCursorStmt:
+		return c.writeCommand(statement.DeclareCursorStmt.GetQuery())
+	case *pgAnalyze.Node_CopyStmt:
+		// COPY FROM writes to the table, COPY TO reads from it or from the query.
+		if statement.CopyStmt.GetIsFrom() {
+			return "COPY", true
+		}
+		return c.writeCommand(statement.CopyStmt.GetQuery())
+	case *pgAnalyze.Node_VariableSetStmt, *pgAnalyze.Node_VariableShowStmt,
+		*pgAnalyze.Node_TransactionStmt, *pgAnalyze.Node_DeallocateStmt,
+		*pgAnalyze.Node_FetchStmt, *pgAnalyze.Node_ClosePortalStmt,
+		*pgAnalyze.Node_ListenStmt, *pgAnalyze.Node_UnlistenStmt,
+		*pgAnalyze.Node_NotifyStmt, *pgAnalyze.Node_DiscardStmt,
+		*pgAnalyze.Node_ConstraintsSetStmt:
+		return "", false
+	case *pgAnalyze.Node_VacuumStmt:
+		if statement.VacuumStmt.GetIsVacuumcmd() {
+			return "VACUUM", true
+		}
+		return "ANALYZE", true
+	case *pgAnalyze.Node_GrantStmt:
+		if statement.GrantStmt.GetIsGrant() {
+			return "GRANT", true
+		}
+		return "REVOKE", true
+	}
+
+	// The other statements write, e.g. INSERT, CREATE TABLE and CALL.
+	return commandTag(node), true
+}
+
+// selectWriteCommand returns the command of the data-modifying statements in the
+// WITH queries of the SELECT, e.g. DELETE in WITH gone AS (DELETE ...) SELECT.
+func (c *classifier) selectWriteCommand(statement *pgAnalyze.SelectStmt) (string, bool) {
+	for _, cte := range statement.GetWithClause().GetCtes() {
+		if command, ok := c.writeCommand(cte.GetCommonTableExpr().GetCtequery()); ok {
+			return command, true
+		}
+	}
+	for _, operand := range []*pgAnalyze.SelectStmt{statement.GetLarg(), statement.GetRarg()} {
+		if operand != nil {
+			if command, ok := c.selectWriteCommand(operand); ok {
+				return command, true
+			}
+		}
+	}
+	return "", false
+}
+
+// isEnabled returns true if the boolean option is enabled, e.g. ANALYZE in
+// EXPLAIN (ANALYZE) and EXPLAIN (ANALYZE true), but not in EXPLAIN (ANALYZE off).
+func isEnabled(option *pgAnalyze.DefElem) bool {
+	arg := option.GetArg()
+	switch {
+	case arg == nil:
+		return true
+	case arg.GetBoolean() != nil:
+		return arg.GetBoolean().GetBoolval()
+	case arg.GetInteger() != nil:
+		return arg.GetInteger().GetIval() != 0
+	case arg.GetString_() != nil:
+		switch strings.ToLower(arg.GetString_().GetSval()) {
+		case "false", "off", "no", "0":
+			return false
+		}
+	}
+	return true
+}
+
+// commandTag returns the command of the statement, e.g. INSERT for InsertStmt and
+// ALTER TABLE for AlterTableStmt.
+func commandTag(node *pgAnalyze.Node) string {
+	typeName := fmt.Sprintf("%T", node.GetNode())
+	typeName = strings.TrimPrefix(typeName[strings.LastIndex(typeName, ".")+1:], "Node_")
+	if tag, ok := commandTags[typeName]; ok {
+		return tag
+	}
+	return strings.ToUpper(wordBoundary.ReplaceAllString(strings.TrimSuffix(typeName, "Stmt"), "$1 $2"))
+}
@@ -0,0 +1,127 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWriteCommand tests classifying the queries as reads or writes.
+func TestWriteCommand(t *testing.T) {
+	tests := []struct {
+		query   string
+		command string
+	}{
+		{"SELECT 1", ""},
+		{"select * from users where name = 'DELETE'", ""},
+		{"SELECT $1::text -- INSERT", ""},
+		{"/* UPDATE */ SELECT 1", ""},
+		{`SELECT "insert" FROM t`, ""},
+		{"SELECT $body$ DROP TABLE t $body$", ""},
+		{"COPY users TO STDOUT", ""},
+		{"COPY (SELECT * FROM users) TO STDOUT", ""},
+		{"EXPLAIN DELETE FROM users", ""},
+		{"EXPLAIN (ANALYZE off) DELETE FROM users", ""},
+		{"WITH recent AS (SELECT * FROM users) SELECT * FROM recent", ""},
+		{"BEGIN; SELECT 1; COMMIT", ""},
+		{"SET search_path = test; SHOW search_path", ""},
+		{"PREPARE stmt AS SELECT 1; EXECUTE stmt", ""},
+		{"insert into users values (1)", "INSERT"},
+		{"  UPDATE users SET name = 'a'", "UPDATE"},
+		{"SELECT 1; DELETE FROM users", "DELETE"},
+		{"CREATE TABLE t (id int)", "CREATE TABLE"},
+		{"ALTER TABLE t ADD COLUMN name text", "ALTER TABLE"},
+		{"TRUNCATE users", "TRUNCATE"},
+		{"COPY users FROM STDIN", "COPY"},
+		{"COPY (DELETE FROM users RETURNING *) TO STDOUT", "DELETE"},
+		{"SELECT * INTO copy FROM users", "SELECT INTO"},
+		{"EXPLAIN (ANALYZE) DELETE FROM users", "DELETE"},
+		{"EXPLAIN ANALYZE UPDATE users SET name = 'a'", "UPDATE"},
+		{"WITH gone AS (DELETE FROM users RETURNING *) SELECT * FROM gone", "DELETE"},
+		{"PREPARE stmt AS INSERT INTO users VALUES ($1)", "INSERT"},
+		{"PREPARE stmt AS INSERT INTO users VALUES (1); EXECUTE stmt", "INSERT"},
+		{"EXECUTE unknown", UnknownStatement},
+		{"DO $$ BEGIN END $$", "DO"},
+		{"VACUUM users", "VACUUM"},
+		{"SELEC 1", UnparsableQuery},
+		{`SELECT E'\''; DELETE FROM t; --'`, "DELETE"},
+		{`SELECT e'\\'; UPDATE t SET a = 1`, "UPDATE"},
+		{`SELECT E'it''s \' DELETE'`, ""},
+		{`SELECT name FROM t WHERE name = 'E'`, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			command, ok := WriteCommand(test.query)
+			assert.Equal(t, test.command != "", ok)
+			assert.Equal(t, test.command, command)
+		})
+	}
+}
+
+// TestRequestWriteCommand tests classifying the simple queries and the Parse messages.
+func TestRequestWriteCommand(t *testing.T) {
+	_, ok := RequestWriteCommand(CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00")), nil)
+	assert.False(t, ok)
+
+	command, ok := RequestWriteCommand(CreatePostgreSQLPacket('Q', []byte("DELETE FROM users\x00")), nil)
+	assert.True(t, ok)
+	assert.Equal(t, "DELETE", command)
+
+	request := CreatePostgreSQLPacket('P', []byte("stmt\x00INSERT INTO users VALUES ($1)\x00\x00\x00"))
+	request = append(request, CreatePostgreSQLPacket('S', nil)...)
+	command, ok = RequestWriteCommand(request, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "INSERT", command)
+	assert.True(t, endsQueryCycle(request))
+	assert.False(t, endsQueryCycle(request[:len(request)-pgHeaderLength]))
+
+	// The untyped messages are reads.
+	_, ok = RequestWriteCommand(CreatePgStartupPacket(), nil)
+	assert.False(t, ok)
+
+	// The incomplete and malformed messages fail closed.
+	query := CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00"))
+	for _, incomplete := range [][]byte{query[:len(query)-1], query[:3], {'Q', 0, 0, 0, 1}} {
+		command, ok = RequestWriteCommand(incomplete, nil)
+		assert.True(t, ok)
+		assert.Equal(t, IncompleteRequest, command)
+	}
+}
+
+// TestRequestWriteCommandPreparedStatements tests classifying the prepared statements
+// executed by name by their tracked queries.
+func TestRequestWriteCommandPreparedStatements(t *testing.T) {
+	statements := NewPreparedStatements()
+	statements.Track(CreatePostgreSQLPacket('P', []byte("read\x00SELECT 1\x00\x00\x00")))
+	statements.Track(CreatePostgreSQLPacket('P', []byte("write\x00DELETE FROM users\x00\x00\x00")))
+
+	bind := func(name string) []byte {
+		request := CreatePostgreSQLPacket('B', []byte("\x00"+name+"\x00\x00\x00\x00\x00\x00\x00"))
+		request = append(request, CreatePostgreSQLPacket('E', []byte("\x00\x00\x00\x00\x00"))...)
+		return append(request, CreatePostgreSQLPacket('S', nil)...)
+	}
+
+	// The statements executed with Bind.
+	_, ok := RequestWriteCommand(bind("read"), statements)
+	assert.False(t, ok)
+	command, ok := RequestWriteCommand(bind("write"), statements)
+	assert.True(t, ok)
+	assert.Equal(t, "DELETE", command)
+	command, ok = RequestWriteCommand(bind("unknown"), statements)
+	assert.True(t, ok)
+	assert.Equal(t, UnknownStatement, command)
+
+	// The statements prepared earlier in the request, and the unnamed statement.
+	request := CreatePostgreSQLPacket('P', []byte("new\x00SELECT 2\x00\x00\x00"))
+	_, ok = RequestWriteCommand(append(request, bind("new")...), statements)
+	assert.False(t, ok)
+	_, ok = RequestWriteCommand(bind(""), statements)
+	assert.False(t, ok)
+
+	// The statements executed with EXECUTE.
+	_, ok = RequestWriteCommand(CreatePostgreSQLPacket('Q', []byte("EXECUTE read\x00")), statements)
+	assert.False(t, ok)
+	command, ok = RequestWriteCommand(CreatePostgreSQLPacket('Q', []byte("EXECUTE write\x00")), statements)
+	assert.True(t, ok)
+	assert.Equal(t, "DELETE", command)
+}
@@ -3,6 +3,8 @@ package network
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
@@ -16,15 +18,25 @@ import (
 	"go.opentelemetry.io/otel"
 )
 
+// IClient is a connection to the database server. The proxy only depends on this
+// interface, so that the server connections can use other transports and be
+// replaced in the tests. Client is the TCP/Unix socket implementation.
 type IClient interface {
 	Send(data []byte) (int, *gerr.GatewayDError)
 	Receive() (int, []byte, *gerr.GatewayDError)
 	Reconnect() error
 	Close()
 	IsConnected() bool
+	Ping() *gerr.GatewayDError
 	RemoteAddr() string
 	LocalAddr() string
 	Retry() *Retry
+	StartupParameters() map[string]string
+	StartupResponse() []byte
+	Reuse() (IClient, *gerr.GatewayDError)
+	GetID() string
+	GetNetwork() string
+	GetAddress() string
 }
 
 type Client struct {
@@ -33,14 +45,34 @@ type Client struct {
 	ctx       context.Context //nolint:containedctx
 	connected atomic.Bool
 	mu        sync.Mutex
+	receiveMu sync.Mutex
 	retry     IRetry
+	// config is the config of the client's backend, used for recreating the client.
+	config *config.Client
+	// idGenerator generates the ID of the client on connecting and reconnecting.
+	idGenerator IIDGenerator
+	// startupParameters replace the parameters of the startup messages.
+	startupParameters map[string]string
+	// startupResponse is the server's response to the startup message of the
+	// pre-authenticated server session.
+	startupResponse []byte
+	// chunkSizer grows the receive chunk on large responses, or is nil if it can't grow.
+	chunkSizer *ChunkSizer
+	// tlsConfig is the TLS of the connections to the server, or nil if it isn't enabled.
+	tlsConfig *tls.Config
 
 	TCPKeepAlive       bool
 	TCPKeepAlivePeriod time.Duration
+	TCPFastOpen        bool
+	DSCP               int // the DSCP class of the packets, 0 means unmarked
+	TCPUserTimeout     time.Duration
 	ReceiveChunkSize   int
 	ReceiveDeadline    time.Duration
 	SendDeadline       time.Duration
 	ReceiveTimeout     time.Duration
+	ReceiveStrategy    string
+	ReceiveQuietPeriod time.Duration
+	ResetQuery         string
 	DialTimeout        time.Duration
 	ID                 string
 	Network            string // tcp/udp/unix
@@ -49,6 +81,18 @@ type Client struct {
 
 var _ IClient = (*Client)(nil)
 
+// errMessageAssemblyTimeout is returned when the server doesn't finish a message within
+// the max message assembly time, e.g. a broken or malicious server.
+var errMessageAssemblyTimeout = errors.New("message isn't complete within the max message assembly time")
+
+// errUnexpectedData is returned by the ping of an idle server connection, if the
+// server sent something on it, which means its session isn't clean.
+var errUnexpectedData = errors.New("the server sent data on the idle connection")
+
+// pingTimeout is how long the ping of an idle server connection waits for the server
+// to close it. An idle connection times out, which means it's still open.
+const pingTimeout = time.Millisecond
+
 // NewClient creates a new client.
 func NewClient(
 	ctx context.Context, clientConfig *config.Client, logger zerolog.Logger, retry *Retry,
@@ -79,25 +123,35 @@ func NewClient(
 		retry:       retry,
 		Network:     clientConfig.Network,
 		Address:     addr,
-		DialTimeout: clientConfig.DialTimeout,
+		TCPFastOpen: clientConfig.TCPFastOpen,
+		DSCP:        clientConfig.DSCP,
+		// Detect a dead server while a request is in flight, if supported.
+		TCPUserTimeout: clientConfig.TCPUserTimeout,
+		// Fail fast on the unreachable backends instead of waiting for the OS timeout.
+		DialTimeout: config.If(
+			clientConfig.DialTimeout > 0, clientConfig.DialTimeout, config.DefaultDialTimeout),
 	}
 
 	// Fall back to the original network and address if the address can't be resolved.
 	if client.Address == "" || client.Network == "" {
-		client = Client{
-			Network: clientConfig.Network,
-			Address: clientConfig.Address,
-		}
+		client.Network = clientConfig.Network
+		client.Address = clientConfig.Address
+	}
+
+	// Load the TLS of the backend, with the server name of the address in the config.
+	tlsConfig, tlsErr := NewBackendTLSConfig(clientConfig.TLS, clientConfig.Address)
+	if tlsErr != nil {
+		err := gerr.ErrClientConnectionFailed.Wrap(tlsErr)
+		logger.Error().Err(err).Msg("Failed to load the TLS config of the server")
+		span.RecordError(err)
+		return nil
 	}
+	client.tlsConfig = tlsConfig
 
 	var origErr error
 	// Create a new connection and retry a few times if needed.
 	if conn, err := client.retry.Retry(func() (any, error) {
-		if client.DialTimeout > 0 {
-			return net.DialTimeout(client.Network, client.Address, client.DialTimeout)
-		} else {
-			return net.Dial(client.Network, client.Address)
-		}
+		return client.dial()
 	}); err != nil {
 		origErr = err
 	} else {
@@ -120,7 +174,11 @@ func NewClient(
 	client.TCPKeepAlive = clientConfig.TCPKeepAlive
 	client.TCPKeepAlivePeriod = clientConfig.TCPKeepAlivePeriod
 
-	if c, ok := client.conn.(*net.TCPConn); ok {
+	netConn := client.conn
+	if tlsConn, ok := netConn.(*tls.Conn); ok {
+		netConn = tlsConn.NetConn()
+	}
+	if c, ok := netConn.(*net.TCPConn); ok {
 		if err := c.SetKeepAlive(client.TCPKeepAlive); err != nil {
 			logger.Error().Err(err).Msg("Failed to set keep alive")
 			span.RecordError(err)
@@ -159,13 +217,38 @@ func NewClient(
 	// Set the receive chunk size. This is the size of the buffer that is read from the connection
 	// in chunks.
 	client.ReceiveChunkSize = clientConfig.ReceiveChunkSize
+	client.chunkSizer = NewChunkSizer(
+		client.ReceiveChunkSize, clientConfig.MaxReceiveChunkSize, clientConfig.ReceiveChunkShrinkPeriod)
+
+	// Set the receive strategy, which decides when a response is completely read.
+	client.ReceiveStrategy = config.If(
+		clientConfig.ReceiveStrategy != "", clientConfig.ReceiveStrategy, config.DefaultReceiveStrategy)
+	client.ReceiveQuietPeriod = config.If(
+		clientConfig.ReceiveQuietPeriod > 0, clientConfig.ReceiveQuietPeriod, config.DefaultReceiveQuietPeriod)
+
+	// Set the query that resets the pre-authenticated session before it's reused.
+	client.ResetQuery = clientConfig.ResetQuery
+
+	client.config = clientConfig
+	client.startupParameters = clientConfig.GetStartupParameters()
+
+	// Authenticate the server session, so that it's ready to use.
+	if clientConfig.PreAuthenticate {
+		if err := client.authenticate(); err != nil {
+			logger.Error().Err(err).Msg("Failed to pre-authenticate the server session")
+			span.RecordError(err)
+			if err := client.conn.Close(); err != nil {
+				logger.Debug().Err(err).Msg("Failed to close connection")
+			}
+			return nil
+		}
+	}
 
 	logger.Trace().Str("address", client.Address).Msg("New client created")
-	client.ID = GetID(
+	client.idGenerator = NewIDGenerator(clientConfig, logger)
+	client.ID = client.idGenerator.GenerateID(
 		client.conn.LocalAddr().Network(),
 		client.conn.LocalAddr().String(),
-		config.DefaultSeed,
-		logger,
 	)
 
 	metrics.ServerConnections.Inc()
@@ -217,6 +300,11 @@ func (c *Client) Receive() (int, []byte, *gerr.GatewayDError) {
 	_, span := otel.Tracer(config.TracerName).Start(c.ctx, "Receive")
 	defer span.End()
 
+	// The connection may be detached from the client for reuse, which waits
+	// for the pending receive.
+	c.receiveMu.Lock()
+	defer c.receiveMu.Unlock()
+
 	if !c.connected.Load() {
 		span.RecordError(gerr.ErrClientNotConnected)
 		return 0, nil, gerr.ErrClientNotConnected
@@ -232,27 +320,168 @@ func (c *Client) Receive() (int, []byte, *gerr.GatewayDError) {
 	}
 
 	var received int
+	var err error
 	buffer := bytes.NewBuffer(nil)
-	// Read the data in chunks.
+	switch c.ReceiveStrategy {
+	case config.ReadUntilDeadline:
+		received, err = c.receiveUntilQuiet(ctx, buffer)
+	case config.ReadFramed:
+		received, err = c.receiveFramed(ctx, buffer)
+	default:
+		received, err = c.receiveOnce(ctx, buffer)
+	}
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Couldn't receive data from the server")
+		span.RecordError(err)
+		return received, buffer.Bytes(), gerr.ErrClientReceiveFailed.Wrap(err)
+	}
+
+	span.AddEvent("Received data from server")
+
+	return received, buffer.Bytes(), nil
+}
+
+// readChunk reads a chunk of the response, in the chunk size adapted to the
+// size of the responses if the chunk can grow.
+func (c *Client) readChunk() ([]byte, int, error) {
+	size := c.ReceiveChunkSize
+	if c.chunkSizer != nil {
+		size = c.chunkSizer.Size()
+	}
+
+	chunk := make([]byte, size)
+	read, err := c.conn.Read(chunk)
+	if c.chunkSizer != nil {
+		c.chunkSizer.Observe(read, size)
+	}
+	return chunk, read, err //nolint:wrapcheck
+}
+
+// receiveOnce reads the data in chunks until a chunk is smaller than the chunk
+// size. This is efficient for simple request/reply, but it may return a part of
+// a response that is larger than a chunk or sent in multiple packets.
+func (c *Client) receiveOnce(ctx context.Context, buffer *bytes.Buffer) (int, error) {
+	received := 0
 	for ctx.Err() == nil {
-		chunk := make([]byte, c.ReceiveChunkSize)
-		read, err := c.conn.Read(chunk)
+		chunk, read, err := c.readChunk()
 		if err != nil {
-			c.logger.Error().Err(err).Msg("Couldn't receive data from the server")
-			span.RecordError(err)
-			return received, buffer.Bytes(), gerr.ErrClientReceiveFailed.Wrap(err)
+			return received, err //nolint:wrapcheck
 		}
 		received += read
 		buffer.Write(chunk[:read])
 
-		if read == 0 || read < c.ReceiveChunkSize {
+		if read == 0 || read < len(chunk) {
 			break
 		}
 	}
 
-	span.AddEvent("Received data from server")
+	return received, nil
+}
 
-	return received, buffer.Bytes(), nil
+// receiveUntilQuiet waits for the first chunk and then keeps reading until the
+// server sends nothing for the quiet period. Every response is delayed by the
+// quiet period, but the responses sent in multiple packets are read as a whole.
+func (c *Client) receiveUntilQuiet(ctx context.Context, buffer *bytes.Buffer) (int, error) {
+	// Restore the receive deadline, which is replaced by the quiet period.
+	defer c.restoreReadDeadline()
+
+	received := 0
+	for ctx.Err() == nil {
+		chunk, read, err := c.readChunk()
+		received += read
+		buffer.Write(chunk[:read])
+		if err != nil {
+			var netErr net.Error
+			if received > 0 && errors.As(err, &netErr) && netErr.Timeout() {
+				// The server is quiet, so the response is complete.
+				break
+			}
+			return received, err //nolint:wrapcheck
+		}
+
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.ReceiveQuietPeriod)); err != nil {
+			return received, err //nolint:wrapcheck
+		}
+	}
+
+	return received, nil
+}
+
+// receiveFramed reads the data in chunks until it ends with a complete PostgreSQL
+// message, so that a message is never split between two responses.
+func (c *Client) receiveFramed(ctx context.Context, buffer *bytes.Buffer) (int, error) {
+	var assemblyDeadline time.Time
+	// Restore the receive deadline, if it's replaced by the assembly deadline.
+	defer func() {
+		if !assemblyDeadline.IsZero() {
+			c.restoreReadDeadline()
+		}
+	}()
+
+	received := 0
+	for ctx.Err() == nil {
+		chunk, read, err := c.readChunk()
+		if err != nil {
+			var netErr net.Error
+			if !assemblyDeadline.IsZero() && errors.As(err, &netErr) && netErr.Timeout() {
+				return received, errMessageAssemblyTimeout
+			}
+			return received, err //nolint:wrapcheck
+		}
+		received += read
+		buffer.Write(chunk[:read])
+
+		if read == 0 || IsPostgresMessages(buffer.Bytes()) {
+			break
+		}
+
+		// The rest of the message must arrive within the max message assembly time,
+		// so that a server that never finishes it can't hold the request forever.
+		if assemblyDeadline.IsZero() && c.config != nil && c.config.MaxMessageAssemblyTime > 0 {
+			assemblyDeadline = time.Now().Add(c.config.MaxMessageAssemblyTime)
+			if err := c.conn.SetReadDeadline(assemblyDeadline); err != nil {
+				return received, err //nolint:wrapcheck
+			}
+		}
+	}
+
+	return received, nil
+}
+
+// restoreReadDeadline restores the receive deadline of the connection, after it's
+// replaced while receiving a response.
+func (c *Client) restoreReadDeadline() {
+	deadline := time.Time{}
+	if c.ReceiveDeadline > 0 {
+		deadline = time.Now().Add(c.ReceiveDeadline)
+	}
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		c.logger.Debug().Err(err).Msg("Failed to restore the receive deadline")
+	}
+}
+
+// dial connects to the server, and upgrades the connection to TLS if it's enabled.
+func (c *Client) dial() (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if c.Network == MemoryNetwork {
+		conn, err = DialMemory(c.Address, c.DialTimeout)
+	} else {
+		conn, err = newDialer(
+			c.DialTimeout, c.TCPFastOpen, c.DSCP, c.TCPUserTimeout, c.logger).Dial(c.Network, c.Address)
+	}
+	if err != nil || c.tlsConfig == nil {
+		return conn, err //nolint:wrapcheck
+	}
+
+	tlsConn, err := UpgradeToTLS(conn, c.tlsConfig, c.DialTimeout)
+	if err != nil {
+		if err := conn.Close(); err != nil {
+			c.logger.Debug().Err(err).Msg("Failed to close connection")
+		}
+		return nil, err
+	}
+	return tlsConn, nil
 }
 
 // Reconnect reconnects to the server.
@@ -278,11 +507,7 @@ func (c *Client) Reconnect() error {
 	var origErr error
 	// Create a new connection and retry a few times if needed.
 	if conn, err := c.retry.Retry(func() (any, error) {
-		if c.DialTimeout > 0 {
-			return net.DialTimeout(c.Network, c.Address, c.DialTimeout)
-		} else {
-			return net.Dial(c.Network, c.Address)
-		}
+		return c.dial()
 	}); err != nil {
 		origErr = err
 	} else {
@@ -298,11 +523,33 @@ func (c *Client) Reconnect() error {
 		return gerr.ErrClientConnectionFailed.Wrap(origErr)
 	}
 
-	c.ID = GetID(
+	// The new connection has the same deadlines as the connection of a new client.
+	c.restoreReadDeadline()
+	if c.SendDeadline > 0 {
+		if err := c.conn.SetWriteDeadline(time.Now().Add(c.SendDeadline)); err != nil {
+			c.logger.Debug().Err(err).Msg("Failed to set the send deadline")
+		}
+	}
+
+	// Authenticate the new server session, so that it's ready to use.
+	if c.config != nil && c.config.PreAuthenticate {
+		if err := c.authenticate(); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to pre-authenticate the server session")
+			span.RecordError(err)
+			if err := c.conn.Close(); err != nil {
+				c.logger.Debug().Err(err).Msg("Failed to close connection")
+			}
+			c.conn = nil
+			return err
+		}
+	}
+
+	if c.idGenerator == nil {
+		c.idGenerator = &HashIDGenerator{Seed: config.DefaultSeed, Logger: c.logger}
+	}
+	c.ID = c.idGenerator.GenerateID(
 		c.conn.LocalAddr().Network(),
 		c.conn.LocalAddr().String(),
-		config.DefaultSeed,
-		c.logger,
 	)
 	c.connected.Store(true)
 	c.logger.Debug().Str("address", c.Address).Msg("Reconnected to server")
@@ -403,6 +650,74 @@ func (c *Client) LocalAddr() string {
 	return ""
 }
 
+// Ping checks if the idle server connection is still open, by reading from it with a
+// short deadline: the server closing the connection ends the read before the deadline,
+// and anything it sends means the session isn't clean. The connection is closed if it
+// fails the ping. It must only be called on the connections that aren't in use, e.g.
+// in the pool.
+func (c *Client) Ping() *gerr.GatewayDError {
+	_, span := otel.Tracer(config.TracerName).Start(c.ctx, "Ping")
+	defer span.End()
+
+	if !c.connected.Load() {
+		span.RecordError(gerr.ErrClientNotConnected)
+		return gerr.ErrClientNotConnected
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		span.RecordError(gerr.ErrClientNotConnected)
+		return gerr.ErrClientNotConnected
+	}
+	if err := c.conn.SetReadDeadline(time.Now().Add(pingTimeout)); err != nil {
+		span.RecordError(err)
+		return gerr.ErrClientConnectionFailed.Wrap(err)
+	}
+	_, err := c.conn.Read(make([]byte, 1))
+	if IsTimeout(err) {
+		c.restoreReadDeadline()
+		return nil
+	}
+	if err == nil {
+		err = errUnexpectedData
+	}
+	span.RecordError(err)
+
+	// The connection can't be used anymore, since the read may have consumed a part of
+	// a message of the server, e.g. a notification. It's closed, and keeps the backend,
+	// so that it's replaced or reconnected.
+	if closeErr := c.conn.Close(); closeErr != nil {
+		c.logger.Debug().Err(closeErr).Msg("Failed to close connection")
+	}
+	c.conn = nil
+	c.connected.Store(false)
+
+	return gerr.ErrClientConnectionFailed.Wrap(err)
+}
+
+// StartupParameters returns the parameters that replace the ones in the startup
+// messages of the clients, i.e. the credentials of the backend.
+func (c *Client) StartupParameters() map[string]string {
+	return c.startupParameters
+}
+
+// GetID returns the ID of the client, which changes on every reconnection.
+func (c *Client) GetID() string {
+	return c.ID
+}
+
+// GetNetwork returns the network of the server.
+func (c *Client) GetNetwork() string {
+	return c.Network
+}
+
+// GetAddress returns the address of the server.
+func (c *Client) GetAddress() string {
+	return c.Address
+}
+
 // Retry returns the retry object.
 //
 //nolint:revive
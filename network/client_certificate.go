@@ -0,0 +1,230 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"path"
+
+	"github.com/gatewayd-io/gatewayd-plugin-sdk/databases/postgres"
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"go.opentelemetry.io/otel"
+)
+
+// CertificateField is the field of the client connection in the OnLoadBalance hooks
+// and of the traffic hooks, in which the attributes of the client certificate are.
+const CertificateField = "certificate"
+
+var errNoCertificateBackend = errors.New("the backend of the certificate route has no available connections")
+
+// ClientCertificate is the attributes of a client certificate verified against the
+// client CAs of the server, for tagging and routing the connection.
+type ClientCertificate struct {
+	CommonName          string
+	OrganizationalUnits []string
+	Organizations       []string
+	DNSNames            []string
+	EmailAddresses      []string
+	URIs                []string
+	IPAddresses         []string
+}
+
+// NewClientCertificate returns the attributes of the client certificate.
+func NewClientCertificate(certificate *x509.Certificate) *ClientCertificate {
+	if certificate == nil {
+		return nil
+	}
+
+	uris := make([]string, 0, len(certificate.URIs))
+	for _, uri := range certificate.URIs {
+		uris = append(uris, uri.String())
+	}
+	addresses := make([]string, 0, len(certificate.IPAddresses))
+	for _, address := range certificate.IPAddresses {
+		addresses = append(addresses, address.String())
+	}
+
+	return &ClientCertificate{
+		CommonName:          certificate.Subject.CommonName,
+		OrganizationalUnits: certificate.Subject.OrganizationalUnit,
+		Organizations:       certificate.Subject.Organization,
+		DNSNames:            certificate.DNSNames,
+		EmailAddresses:      certificate.EmailAddresses,
+		URIs:                uris,
+		IPAddresses:         addresses,
+	}
+}
+
+// SANs returns the subject alternative names of the certificate: the DNS names, email
+// addresses, URIs and IP addresses.
+func (c *ClientCertificate) SANs() []string {
+	sans := make([]string, 0, len(c.DNSNames)+len(c.EmailAddresses)+len(c.URIs)+len(c.IPAddresses))
+	sans = append(sans, c.DNSNames...)
+	sans = append(sans, c.EmailAddresses...)
+	sans = append(sans, c.URIs...)
+	return append(sans, c.IPAddresses...)
+}
+
+// Fields returns the attributes of the certificate as the fields of the hooks.
+func (c *ClientCertificate) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"commonName":          c.CommonName,
+		"organizationalUnits": toInterfaces(c.OrganizationalUnits),
+		"organizations":       toInterfaces(c.Organizations),
+		"sans":                toInterfaces(c.SANs()),
+	}
+}
+
+// Matches returns true if the certificate matches all the attributes of the route.
+func (c *ClientCertificate) Matches(route config.CertificateRoute) bool {
+	if route.CommonName == "" && route.OrganizationalUnit == "" && route.SAN == "" {
+		return false
+	}
+
+	return (route.CommonName == "" || matchPattern(route.CommonName, c.CommonName)) &&
+		(route.OrganizationalUnit == "" || matchAnyPattern(route.OrganizationalUnit, c.OrganizationalUnits)) &&
+		(route.SAN == "" || matchAnyPattern(route.SAN, c.SANs()))
+}
+
+// matchPattern returns true if the value matches the shell pattern.
+func matchPattern(pattern, value string) bool {
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+// matchAnyPattern returns true if any of the values matches the shell pattern.
+func matchAnyPattern(pattern string, values []string) bool {
+	for _, value := range values {
+		if matchPattern(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// toInterfaces converts the strings to a list that the hook payloads accept.
+func toInterfaces(values []string) []interface{} {
+	list := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		list = append(list, value)
+	}
+	return list
+}
+
+// verifiedClientCertificate returns the attributes of the client certificate of the
+// TLS connection, if it's verified against the client CAs of the server. The untrusted
+// certificates fail the handshake, and the certificates verified against the system
+// roots, if no client CAs are configured, aren't trusted for routing.
+func verifiedClientCertificate(state tls.ConnectionState, tlsConfig *tls.Config) *ClientCertificate {
+	if tlsConfig == nil || tlsConfig.ClientCAs == nil ||
+		len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return NewClientCertificate(state.PeerCertificates[0])
+}
+
+// certificateRoute returns the first certificate route matching the client
+// certificate, or nil.
+func (pr *Proxy) certificateRoute(certificate *ClientCertificate) *config.CertificateRoute {
+	for index := range pr.CertificateRoutes {
+		if certificate.Matches(pr.CertificateRoutes[index]) {
+			return &pr.CertificateRoutes[index]
+		}
+	}
+	return nil
+}
+
+// routeByCertificate tags the client connection with the labels of the certificate
+// route matching its verified client certificate, and moves it to a server connection
+// of the backend of the route, or of the backend chosen by the OnLoadBalance hooks,
+// which get the attributes of the certificate, if no route matches. It's called right
+// after the TLS handshake, before the client sends its startup message, so the server
+// connection assigned in Connect is still unused. The connection is rejected if the
+// backend of its route has no available server connections, and stays on its server
+// connection if the backend chosen by the plugins has none.
+func (pr *Proxy) routeByCertificate(conn *ConnWrapper) *gerr.GatewayDError {
+	certificate := conn.ClientCertificate()
+	if certificate == nil {
+		return nil
+	}
+
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "routeByCertificate")
+	defer span.End()
+
+	backend, strategy := "", RoutingPlugin
+	if route := pr.certificateRoute(certificate); route != nil {
+		if len(route.Labels) > 0 {
+			labels := make(map[string]interface{}, len(route.Labels))
+			for name, value := range route.Labels {
+				labels[name] = value
+			}
+			conn.SetLabels(MergeLabels(conn.Labels(), GetLabels(map[string]interface{}{LabelsField: labels})))
+		}
+		backend, strategy = route.Backend, RoutingCertificate
+	} else {
+		backend = pr.loadBalance(conn)
+	}
+	if backend == "" {
+		return nil
+	}
+
+	current, ok := pr.busyConnections.Get(conn).(IClient)
+	if !ok || current == nil {
+		return nil
+	}
+	if current.GetNetwork()+"://"+current.GetAddress() == backend {
+		pr.balanced.Store(conn, strategy)
+		metrics.CertificateRoutes.WithLabelValues("routed").Inc()
+		return nil
+	}
+
+	client, ok := pr.AvailableConnections.Pop(pr.backendClientID(backend)).(IClient)
+	if !ok || client == nil {
+		if strategy == RoutingPlugin {
+			return nil
+		}
+
+		pr.Logger.Warn().Fields(map[string]interface{}{
+			"backend":    backend,
+			"commonName": certificate.CommonName,
+			"remote":     RemoteAddr(conn.Conn()),
+		}).Msg("Rejected a connection, because the backend of its certificate route has no available connections")
+		span.RecordError(errNoCertificateBackend)
+		metrics.CertificateRoutes.WithLabelValues("rejected").Inc()
+
+		// https://www.postgresql.org/docs/current/errcodes-appendix.html
+		response := postgres.ErrorResponse(
+			"no available connections to the database server", "FATAL", pgInsufficientResources,
+			"The backend of the client certificate has no available connections")
+		if _, err := conn.Write(response); err != nil {
+			pr.Logger.Debug().Err(err).Msg("Failed to send the error response to the client")
+		}
+		return gerr.ErrNoHealthyUpstream.Wrap(errNoCertificateBackend)
+	}
+
+	if err := pr.busyConnections.Put(conn, client); err != nil {
+		span.RecordError(err)
+		if putErr := pr.AvailableConnections.Put(client.GetID(), client); putErr != nil {
+			client.Close()
+		}
+		return nil
+	}
+	pr.balanced.Store(conn, strategy)
+
+	// The pending read of the connection on its previous server connection is woken up
+	// by closing it, and a new server connection takes its place in the pool.
+	current.Close()
+	pr.replaceClient(current)
+
+	pr.Logger.Debug().Fields(map[string]interface{}{
+		"backend":    backend,
+		"strategy":   strategy,
+		"commonName": certificate.CommonName,
+		"remote":     RemoteAddr(conn.Conn()),
+	}).Msg("Routed the connection by its client certificate")
+	span.AddEvent("Routed the connection by its client certificate")
+	metrics.CertificateRoutes.WithLabelValues("routed").Inc()
+	return nil
+}
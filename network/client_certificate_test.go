@@ -0,0 +1,123 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClientCertificate returns the attributes of a client certificate of a tenant.
+func newTestClientCertificate() *ClientCertificate {
+	return NewClientCertificate(&x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:         "app.tenant1.example.com",
+			OrganizationalUnit: []string{"payments", "billing"},
+			Organization:       []string{"Tenant 1"},
+		},
+		DNSNames:       []string{"app.tenant1.internal"},
+		EmailAddresses: []string{"app@tenant1.example.com"},
+		URIs:           []*url.URL{{Scheme: "spiffe", Host: "tenant1", Path: "/app"}},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+	})
+}
+
+// TestClientCertificate tests exposing the attributes of the client certificate and
+// matching them against the certificate routes.
+func TestClientCertificate(t *testing.T) {
+	certificate := newTestClientCertificate()
+
+	assert.Equal(t, []string{
+		"app.tenant1.internal", "app@tenant1.example.com", "spiffe://tenant1/app", "10.0.0.1",
+	}, certificate.SANs())
+	assert.Equal(t, map[string]interface{}{
+		"commonName":          "app.tenant1.example.com",
+		"organizationalUnits": []interface{}{"payments", "billing"},
+		"organizations":       []interface{}{"Tenant 1"},
+		"sans": []interface{}{
+			"app.tenant1.internal", "app@tenant1.example.com", "spiffe://tenant1/app", "10.0.0.1",
+		},
+	}, certificate.Fields())
+
+	assert.True(t, certificate.Matches(config.CertificateRoute{CommonName: "*.tenant1.example.com"}))
+	assert.True(t, certificate.Matches(config.CertificateRoute{OrganizationalUnit: "billing"}))
+	assert.True(t, certificate.Matches(config.CertificateRoute{SAN: "10.0.0.*"}))
+	assert.True(t, certificate.Matches(config.CertificateRoute{
+		CommonName: "app.*", OrganizationalUnit: "pay*", SAN: "*.internal",
+	}))
+	// All the attributes of the route must match.
+	assert.False(t, certificate.Matches(config.CertificateRoute{
+		CommonName: "app.*", OrganizationalUnit: "support",
+	}))
+	assert.False(t, certificate.Matches(config.CertificateRoute{CommonName: "*.tenant2.example.com"}))
+	assert.False(t, certificate.Matches(config.CertificateRoute{SAN: "["}))
+	assert.False(t, certificate.Matches(config.CertificateRoute{Backend: "tcp://localhost:5433"}))
+}
+
+// TestVerifiedClientCertificate tests only trusting the client certificates verified
+// against the client CAs of the server.
+func TestVerifiedClientCertificate(t *testing.T) {
+	peer := &x509.Certificate{Subject: pkix.Name{CommonName: "app"}}
+	verified := tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{peer},
+		VerifiedChains:   [][]*x509.Certificate{{peer}},
+	}
+	withCAs := &tls.Config{ClientCAs: x509.NewCertPool()}
+
+	certificate := verifiedClientCertificate(verified, withCAs)
+	require.NotNil(t, certificate)
+	assert.Equal(t, "app", certificate.CommonName)
+
+	assert.Nil(t, verifiedClientCertificate(verified, &tls.Config{}))
+	assert.Nil(t, verifiedClientCertificate(verified, nil))
+	assert.Nil(t, verifiedClientCertificate(
+		tls.ConnectionState{PeerCertificates: []*x509.Certificate{peer}}, withCAs))
+}
+
+// TestRouteByCertificate tests tagging the connection with the labels of its
+// certificate route, and rejecting it if the backend of the route has no available
+// server connections.
+func TestRouteByCertificate(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+	proxy.CertificateRoutes = []config.CertificateRoute{
+		{CommonName: "*.tenant2.example.com", Backend: "tcp://replica"},
+		{OrganizationalUnit: "payments", Backend: "memory://memory", Labels: map[string]string{"team": "payments"}},
+	}
+
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+
+	// The connections without a verified client certificate aren't routed.
+	require.Nil(t, proxy.routeByCertificate(conn))
+	assert.Equal(t, RoutingFirstAvailable, proxy.Routing(conn)["strategy"])
+
+	conn.certificate.Store(newTestClientCertificate())
+	require.Nil(t, proxy.routeByCertificate(conn))
+	assert.Equal(t, map[string]string{"team": "payments"}, conn.Labels())
+	assert.Equal(t, RoutingCertificate, proxy.Routing(conn)["strategy"])
+	assert.Equal(t, memClient, proxy.busyConnections.Get(conn))
+
+	// The backend of the route has no available server connections.
+	client := newMockConn()
+	rejected := NewConnWrapper(ConnWrapper{NetConn: client})
+	require.Nil(t, proxy.busyConnections.Put(rejected, memClient))
+	rejected.certificate.Store(&ClientCertificate{CommonName: "app.tenant2.example.com"})
+	require.NotNil(t, proxy.routeByCertificate(rejected))
+
+	frontend := pgproto3.NewFrontend(newMockConn(client.Written()), nil)
+	message, err := frontend.Receive()
+	require.NoError(t, err)
+	errorResponse, ok := message.(*pgproto3.ErrorResponse)
+	require.True(t, ok)
+	assert.Equal(t, "FATAL", errorResponse.Severity)
+	assert.Equal(t, pgInsufficientResources, errorResponse.Code)
+}
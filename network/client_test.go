@@ -2,6 +2,7 @@ package network
 
 import (
 	"context"
+	"net"
 	"testing"
 	"time"
 
@@ -129,6 +130,55 @@ func TestReconnect(t *testing.T) {
 	assert.NotEqual(t, localAddr, client.LocalAddr()) // This is a new connection.
 }
 
+// TestPing tests pinging the idle server connection without opening a new one, and
+// closing it if the server sent something on it, e.g. a notification.
+func TestPing(t *testing.T) {
+	conns := make(chan net.Conn, 2)
+	upstream := newFakeUpstream(t, func(conn net.Conn) { conns <- conn })
+
+	client := NewClient(context.Background(), newTestClientConfig(upstream.Address()), zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	defer client.Close()
+
+	assert.Nil(t, client.Ping())
+	assert.True(t, client.IsConnected())
+	requireAccepted(t, upstream, 1)
+
+	_, err := (<-conns).Write(CreatePostgreSQLPacket('A', []byte("\x00\x00\x00\x01channel\x00\x00")))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return client.Ping() != nil }, time.Second, 10*time.Millisecond)
+	assert.False(t, client.IsConnected())
+	assert.Equal(t, upstream.Address(), client.GetAddress(), "the backend is kept")
+
+	require.NoError(t, client.Reconnect())
+	assert.Nil(t, client.Ping())
+	requireAccepted(t, upstream, 2)
+}
+
+// TestNewClientDialTimeout tests that the dial fails within the dial timeout if
+// nothing listens on the port, and that the default dial timeout is used if unset.
+func TestNewClientDialTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	clientConfig := newTestClientConfig(address)
+	clientConfig.DialTimeout = 200 * time.Millisecond
+	start := time.Now()
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	assert.Nil(t, client)
+	assert.Less(t, time.Since(start), clientConfig.DialTimeout+time.Second)
+
+	upstream := newFakeUpstream(t, func(net.Conn) {})
+	clientConfig = newTestClientConfig(upstream.Address())
+	clientConfig.DialTimeout = 0
+	client = NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	defer client.Close()
+	assert.Equal(t, config.DefaultDialTimeout, client.DialTimeout)
+}
+
 func BenchmarkNewClient(b *testing.B) {
 	cfg := logging.LoggerConfig{
 		Output:            []config.LogOutput{config.Console},
@@ -244,3 +294,72 @@ func BenchmarkIsConnected(b *testing.B) {
 		client.IsConnected()
 	}
 }
+
+// TestReceiveStrategies tests reading a response that is sent in two packets
+// with each of the receive strategies.
+func TestReceiveStrategies(t *testing.T) {
+	response := append(
+		CreatePostgreSQLPacket('C', []byte("SELECT 1\x00")),
+		CreatePostgreSQLPacket('Z', []byte{byte(TxIdle)})...)
+	split := len(response) - 3
+
+	upstream := newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		_, _ = conn.Write(response[:split])
+		time.Sleep(50 * time.Millisecond)
+		_, _ = conn.Write(response[split:])
+		// Keep the connection open until the client goes away.
+		_, _ = conn.Read(make([]byte, 1))
+	})
+
+	tests := []struct {
+		strategy string
+		expected []byte
+	}{
+		{config.ReadOnce, response[:split]},
+		{config.ReadUntilDeadline, response},
+		{config.ReadFramed, response},
+	}
+	for _, test := range tests {
+		t.Run(test.strategy, func(t *testing.T) {
+			clientConfig := newTestClientConfig(upstream.Address())
+			clientConfig.ReceiveStrategy = test.strategy
+			clientConfig.ReceiveQuietPeriod = 200 * time.Millisecond
+			client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+			require.NotNil(t, client)
+			defer client.Close()
+
+			received, data, err := client.Receive()
+			require.Nil(t, err)
+			assert.Equal(t, len(test.expected), received)
+			assert.Equal(t, test.expected, data)
+		})
+	}
+}
+
+// TestMaxMessageAssemblyTime tests failing the responses that the server doesn't
+// complete within the max message assembly time.
+func TestMaxMessageAssemblyTime(t *testing.T) {
+	response := CreatePostgreSQLPacket('C', []byte("SELECT 1\x00"))
+	upstream := newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		// The message is never completed.
+		_, _ = conn.Write(response[:len(response)-3])
+		_, _ = conn.Read(make([]byte, 1))
+	})
+
+	clientConfig := newTestClientConfig(upstream.Address())
+	clientConfig.ReceiveStrategy = config.ReadFramed
+	clientConfig.MaxMessageAssemblyTime = 50 * time.Millisecond
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	defer client.Close()
+
+	start := time.Now()
+	received, data, err := client.Receive()
+	require.NotNil(t, err)
+	require.ErrorIs(t, err, errMessageAssemblyTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, len(response)-3, received)
+	assert.Equal(t, response[:len(response)-3], data)
+}
@@ -0,0 +1,157 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CompressionRequestCode is the code of the CompressionRequest message, which the
+	// clients send instead of the StartupMessage to compress the connection, just like
+	// the SSLRequest message. It isn't part of the Postgres protocol, so the clients
+	// need a driver or a sidecar that supports it.
+	CompressionRequestCode = 1234<<16 | 5699
+
+	// CompressionAccepted is the response to the CompressionRequest message, after
+	// which both sides compress their traffic. Otherwise, 'N' is sent in response
+	// and the connection is left uncompressed.
+	CompressionAccepted = 'C'
+	// CompressionRejected is the response to the CompressionRequest message if the
+	// server doesn't support compression.
+	CompressionRejected = 'N'
+
+	// compressionMaxWindow is the max window of the client's compressed traffic, which
+	// bounds the memory used for decompressing the traffic of a connection.
+	compressionMaxWindow = 8 << 20
+)
+
+var errCompressionRejected = errors.New("compression is rejected by the server")
+
+// IsCompressionRequest returns true if the message is a CompressionRequest.
+//
+//nolint:gomnd
+func IsCompressionRequest(data []byte) bool {
+	return len(data) >= 8 &&
+		binary.BigEndian.Uint32(data[0:4]) == 8 &&
+		binary.BigEndian.Uint32(data[4:8]) == CompressionRequestCode
+}
+
+// ParseCompressionLevel returns the zstd level of the compression by its name,
+// i.e. fastest, default, better or best.
+func ParseCompressionLevel(name string) (zstd.EncoderLevel, bool) {
+	ok, level := zstd.EncoderLevelFromString(name)
+	return level, ok
+}
+
+// CompressedConn is a connection whose traffic is compressed with zstd in both
+// directions. Every write is flushed as a block, so that the other side can read
+// the message without waiting for more data, and the reads return the decompressed
+// data, so the protocol is parsed as if the connection isn't compressed.
+type CompressedConn struct {
+	net.Conn
+
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	writeMu sync.Mutex
+	readMu  sync.Mutex
+}
+
+var _ net.Conn = (*CompressedConn)(nil)
+
+// NewCompressedConn compresses the traffic of the connection with the level.
+func NewCompressedConn(conn net.Conn, level zstd.EncoderLevel) (*CompressedConn, error) {
+	encoder, err := zstd.NewWriter(conn,
+		zstd.WithEncoderLevel(level),
+		zstd.WithEncoderConcurrency(1),
+		zstd.WithLowerEncoderMem(true),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the compressor: %w", err)
+	}
+
+	// A single goroutine decodes the blocks as they are read, so the reads don't
+	// wait for the blocks that the other side hasn't sent yet.
+	decoder, err := zstd.NewReader(conn,
+		zstd.WithDecoderConcurrency(1),
+		zstd.WithDecoderLowmem(true),
+		zstd.WithDecoderMaxWindow(compressionMaxWindow),
+	)
+	if err != nil {
+		encoder.Close()
+		return nil, fmt.Errorf("failed to create the decompressor: %w", err)
+	}
+
+	return &CompressedConn{Conn: conn, encoder: encoder, decoder: decoder}, nil
+}
+
+// Read reads and decompresses the data from the connection.
+func (c *CompressedConn) Read(data []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	read, err := c.decoder.Read(data)
+	if err != nil {
+		return read, fmt.Errorf("failed to decompress the data: %w", err)
+	}
+	return read, nil
+}
+
+// Write compresses the data and writes it to the connection.
+func (c *CompressedConn) Write(data []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	written, err := c.encoder.Write(data)
+	if err != nil {
+		return written, fmt.Errorf("failed to compress the data: %w", err)
+	}
+	if err := c.encoder.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to compress the data: %w", err)
+	}
+	return written, nil
+}
+
+// Close closes the connection and releases the compressor and the decompressor.
+func (c *CompressedConn) Close() error {
+	err := c.Conn.Close()
+
+	c.writeMu.Lock()
+	// The end of the stream can't be written to the closed connection.
+	c.encoder.Reset(io.Discard)
+	c.encoder.Close()
+	c.writeMu.Unlock()
+
+	c.readMu.Lock()
+	c.decoder.Close()
+	c.readMu.Unlock()
+
+	return err //nolint:wrapcheck
+}
+
+// RequestCompression sends a CompressionRequest on the connection and returns the
+// compressed connection if the server accepts it. It's used by the clients, e.g.
+// the sidecars that compress the traffic of the clients to GatewayD.
+func RequestCompression(conn net.Conn, level zstd.EncoderLevel) (*CompressedConn, error) {
+	request := make([]byte, 8) //nolint:gomnd
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], CompressionRequestCode)
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("failed to send the compression request: %w", err)
+	}
+
+	response := make([]byte, 1)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, fmt.Errorf("failed to receive the compression response: %w", err)
+	}
+	if response[0] != CompressionAccepted {
+		return nil, errCompressionRejected
+	}
+
+	return NewCompressedConn(conn, level)
+}
@@ -0,0 +1,131 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCompressedConn tests that every write can be read on the other side without more data.
+func TestCompressedConn(t *testing.T) {
+	left, right := net.Pipe()
+	defer left.Close()
+	defer right.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		writer, err := NewCompressedConn(left, zstd.SpeedDefault)
+		if !assert.NoError(t, err) {
+			return
+		}
+		for _, message := range []string{"SELECT 1", "SELECT 2"} {
+			written, err := writer.Write(bytes.Repeat([]byte(message), 100))
+			assert.NoError(t, err)
+			assert.Equal(t, len(message)*100, written)
+		}
+	}()
+
+	reader, err := NewCompressedConn(right, zstd.SpeedDefault)
+	require.NoError(t, err)
+	for _, message := range []string{"SELECT 1", "SELECT 2"} {
+		data := make([]byte, len(message)*100)
+		_, err := io.ReadFull(reader, data)
+		require.NoError(t, err)
+		assert.Equal(t, bytes.Repeat([]byte(message), 100), data)
+	}
+	<-done
+	require.NoError(t, reader.Close())
+}
+
+// TestParseCompressionLevel tests parsing the names of the compression levels.
+func TestParseCompressionLevel(t *testing.T) {
+	for _, name := range []string{
+		config.FastestCompression, config.DefaultCompression, config.BetterCompression, config.BestCompression,
+	} {
+		level, ok := ParseCompressionLevel(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, name, level.String())
+	}
+	_, ok := ParseCompressionLevel("fast")
+	assert.False(t, ok)
+}
+
+// TestProxyCompression tests that the proxy decompresses the traffic of the client
+// before forwarding it to the server, and compresses the responses.
+func TestProxyCompression(t *testing.T) {
+	query, err := (&pgproto3.Query{String: "SELECT 1"}).Encode(nil)
+	require.NoError(t, err)
+	ready, err := (&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)}).Encode(nil)
+	require.NoError(t, err)
+
+	received := make(chan []byte, 1)
+	upstream := newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		buffer := make([]byte, config.DefaultChunkSize)
+		read, err := conn.Read(buffer)
+		if err != nil {
+			return
+		}
+		received <- buffer[:read]
+		_, _ = conn.Write(ready)
+		// Keep the connection open until the client goes away.
+		_, _ = conn.Read(buffer)
+	})
+	proxy := newTestProxy(t, upstream.Address())
+
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+	conn := NewConnWrapper(ConnWrapper{NetConn: serverSide, CompressionLevel: zstd.SpeedFastest})
+	require.Nil(t, proxy.Connect(conn))
+
+	response := make(chan []byte, 1)
+	go func() {
+		client, err := RequestCompression(clientSide, zstd.SpeedFastest)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = client.Write(query)
+		assert.NoError(t, err)
+		data := make([]byte, len(ready))
+		_, err = io.ReadFull(client, data)
+		assert.NoError(t, err)
+		response <- data
+	}()
+
+	// The first request enables the compression, and the next one is decompressed.
+	require.Nil(t, proxy.PassThroughToServer(conn, NewStack()))
+	assert.True(t, conn.IsCompressed())
+	require.Nil(t, proxy.PassThroughToServer(conn, NewStack()))
+	assert.Equal(t, query, <-received)
+
+	require.Nil(t, proxy.PassThroughToClient(conn, NewStack()))
+	assert.Equal(t, ready, <-response)
+	require.Nil(t, proxy.Disconnect(conn))
+}
+
+// TestProxyCompressionRejected tests that the compression request is rejected
+// if the compression isn't enabled.
+func TestProxyCompressionRejected(t *testing.T) {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], CompressionRequestCode)
+	require.True(t, IsCompressionRequest(request))
+
+	proxy := newTestProxy(t, newFakeUpstream(t, func(conn net.Conn) { conn.Close() }).Address())
+	mock := newMockConn(request)
+	conn := NewConnWrapper(ConnWrapper{NetConn: mock})
+	require.Nil(t, proxy.Connect(conn))
+	require.Nil(t, proxy.PassThroughToServer(conn, NewStack()))
+	assert.False(t, conn.IsCompressed())
+	assert.Equal(t, []byte{CompressionRejected}, mock.Written())
+	require.Nil(t, proxy.Disconnect(conn))
+}
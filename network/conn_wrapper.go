@@ -4,10 +4,16 @@ package network
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"net"
+	"os"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/klauspost/compress/zstd"
 )
 
 // UpgraderFunc is a function that upgrades a connection to TLS.
@@ -27,6 +33,31 @@ type IConnWrapper interface {
 	RemoteAddr() net.Addr
 	LocalAddr() net.Addr
 	IsTLSEnabled() bool
+	EnableCompression(acknowledge UpgraderFunc) *gerr.GatewayDError
+	IsCompressionEnabled() bool
+	IsCompressed() bool
+	Identity() *Identity
+	SetIdentity(identity *Identity)
+	ClientCertificate() *ClientCertificate
+	PreparedStatements() *PreparedStatements
+	TxStatus() TxStatus
+	SetTxStatus(status TxStatus)
+	LastActivity() time.Time
+	Touch()
+	CancelKey() *BackendKey
+	SetCancelKey(key *BackendKey)
+	Labels() map[string]string
+	SetLabels(labels map[string]string)
+	Priority() (int, bool)
+	SetPriority(priority int)
+	SessionSetup() [][]byte
+	RecordSessionSetup(request []byte, limit int)
+	Capture() *Capture
+	SetCapture(capture *Capture) *Capture
+	BytesReceived() uint64
+	BytesSent() uint64
+	CountReceived(n int)
+	CountSent(n int)
 }
 
 type ConnWrapper struct {
@@ -35,12 +66,32 @@ type ConnWrapper struct {
 	TLSConfig        *tls.Config
 	isTLSEnabled     bool
 	HandshakeTimeout time.Duration
+	identity         *atomic.Pointer[Identity]
+	certificate      *atomic.Pointer[ClientCertificate]
+	statements       *PreparedStatements
+	txStatus         *atomic.Uint32
+	lastActivity     *atomic.Int64
+	cancelKey        *atomic.Pointer[BackendKey]
+	labels           *atomic.Pointer[map[string]string]
+	priority         *atomic.Pointer[int]
+	sessionSetup     *atomic.Pointer[[][]byte]
+	capture          *atomic.Pointer[Capture]
+	bytesReceived    *atomic.Uint64
+	bytesSent        *atomic.Uint64
+
+	// CompressionLevel is the level of the compression the client may request,
+	// or zero if the compression isn't enabled.
+	CompressionLevel zstd.EncoderLevel
+	compressedConn   *CompressedConn
 }
 
 var _ IConnWrapper = (*ConnWrapper)(nil)
 
 // Conn returns the underlying connection.
 func (cw *ConnWrapper) Conn() net.Conn {
+	if cw.compressedConn != nil {
+		return cw.compressedConn
+	}
 	if cw.tlsConn != nil {
 		return net.Conn(cw.tlsConn)
 	}
@@ -71,31 +122,67 @@ func (cw *ConnWrapper) UpgradeToTLS(upgrader UpgraderFunc) *gerr.GatewayDError {
 	}
 	cw.tlsConn = tlsConn
 	cw.isTLSEnabled = true
+	if cw.certificate != nil {
+		cw.certificate.Store(verifiedClientCertificate(tlsConn.ConnectionState(), cw.TLSConfig))
+	}
+	return nil
+}
+
+// EnableCompression compresses the traffic of the connection with zstd, after the
+// client sends a CompressionRequest message. The acknowledge function is called to
+// accept the request before the traffic is compressed. If the client requested TLS
+// as well, the compression must be requested after the TLS handshake.
+func (cw *ConnWrapper) EnableCompression(acknowledge UpgraderFunc) *gerr.GatewayDError {
+	if cw.compressedConn != nil || !cw.IsCompressionEnabled() {
+		return nil
+	}
+
+	conn := cw.Conn()
+	if acknowledge != nil {
+		acknowledge(conn)
+	}
+
+	compressedConn, err := NewCompressedConn(conn, cw.CompressionLevel)
+	if err != nil {
+		return gerr.ErrCompressionFailed.Wrap(err)
+	}
+	cw.compressedConn = compressedConn
 	return nil
 }
 
+// IsCompressionEnabled returns true if the client may compress the connection.
+func (cw *ConnWrapper) IsCompressionEnabled() bool {
+	return cw.CompressionLevel != 0
+}
+
+// IsCompressed returns true if the traffic of the connection is compressed.
+func (cw *ConnWrapper) IsCompressed() bool {
+	return cw.compressedConn != nil
+}
+
 // Close closes the connection.
 func (cw *ConnWrapper) Close() error {
+	if cw.compressedConn != nil {
+		return cw.compressedConn.Close()
+	}
 	if cw.tlsConn != nil {
 		return cw.tlsConn.Close()
 	}
 	return cw.NetConn.Close()
 }
 
-// Write writes data to the connection.
+// Write writes data to the connection and counts the bytes sent to the client.
 func (cw *ConnWrapper) Write(data []byte) (int, error) {
-	if cw.tlsConn != nil {
-		return cw.tlsConn.Write(data)
-	}
-	return cw.NetConn.Write(data)
+	written, err := cw.Conn().Write(data)
+	cw.CountSent(written)
+	return written, err
 }
 
-// Read reads data from the connection.
+// Read reads data from the connection and counts the bytes received from the client.
 func (cw *ConnWrapper) Read(data []byte) (int, error) {
-	if cw.tlsConn != nil {
-		return cw.tlsConn.Read(data)
-	}
-	return cw.NetConn.Read(data)
+	read, err := cw.Conn().Read(data)
+	cw.CountReceived(read)
+	return read, err
 }
 
 // RemoteAddr returns the remote address.
@@ -119,17 +206,232 @@ func (cw *ConnWrapper) IsTLSEnabled() bool {
 	return cw.tlsConn != nil || cw.isTLSEnabled
 }
 
+// Identity returns the identity of the authenticated client, or nil.
+func (cw *ConnWrapper) Identity() *Identity {
+	if cw.identity == nil {
+		return nil
+	}
+	return cw.identity.Load()
+}
+
+// SetIdentity sets the identity of the authenticated client.
+func (cw *ConnWrapper) SetIdentity(identity *Identity) {
+	if cw.identity == nil {
+		return
+	}
+	cw.identity.Store(identity)
+}
+
+// ClientCertificate returns the attributes of the client certificate verified against
+// the client CAs in the TLS handshake, or nil.
+func (cw *ConnWrapper) ClientCertificate() *ClientCertificate {
+	if cw.certificate == nil {
+		return nil
+	}
+	return cw.certificate.Load()
+}
+
+// PreparedStatements returns the prepared statements of the client session.
+func (cw *ConnWrapper) PreparedStatements() *PreparedStatements {
+	return cw.statements
+}
+
+// TxStatus returns the transaction status of the client session.
+func (cw *ConnWrapper) TxStatus() TxStatus {
+	if cw.txStatus == nil {
+		return TxIdle
+	}
+	return TxStatus(cw.txStatus.Load())
+}
+
+// SetTxStatus sets the transaction status of the client session.
+func (cw *ConnWrapper) SetTxStatus(status TxStatus) {
+	if cw.txStatus == nil {
+		return
+	}
+	cw.txStatus.Store(uint32(status))
+}
+
+// LastActivity returns the last time traffic was received from or sent to the client.
+func (cw *ConnWrapper) LastActivity() time.Time {
+	if cw.lastActivity == nil {
+		return time.Time{}
+	}
+	return time.Unix(0, cw.lastActivity.Load())
+}
+
+// Touch records traffic from or to the client as the last activity on the connection.
+func (cw *ConnWrapper) Touch() {
+	if cw.lastActivity == nil {
+		return
+	}
+	cw.lastActivity.Store(time.Now().UnixNano())
+}
+
+// CancelKey returns the backend key issued to the client for canceling its queries, or nil.
+func (cw *ConnWrapper) CancelKey() *BackendKey {
+	if cw.cancelKey == nil {
+		return nil
+	}
+	return cw.cancelKey.Load()
+}
+
+// SetCancelKey sets the backend key issued to the client.
+func (cw *ConnWrapper) SetCancelKey(key *BackendKey) {
+	if cw.cancelKey == nil {
+		return
+	}
+	cw.cancelKey.Store(key)
+}
+
+// Labels returns the labels the plugins attached to the connection, or nil.
+// The returned map must not be modified.
+func (cw *ConnWrapper) Labels() map[string]string {
+	if cw.labels == nil {
+		return nil
+	}
+	if labels := cw.labels.Load(); labels != nil {
+		return *labels
+	}
+	return nil
+}
+
+// SetLabels replaces the labels of the connection.
+func (cw *ConnWrapper) SetLabels(labels map[string]string) {
+	if cw.labels == nil {
+		return
+	}
+	cw.labels.Store(&labels)
+}
+
+// Priority returns the priority of the connection set by the plugins, or false if
+// they didn't set one.
+func (cw *ConnWrapper) Priority() (int, bool) {
+	if cw.priority == nil {
+		return 0, false
+	}
+	if priority := cw.priority.Load(); priority != nil {
+		return *priority, true
+	}
+	return 0, false
+}
+
+// SetPriority sets the priority of the connection.
+func (cw *ConnWrapper) SetPriority(priority int) {
+	if cw.priority == nil {
+		return
+	}
+	cw.priority.Store(&priority)
+}
+
+// SessionSetup returns the requests of the client that set up its server session,
+// e.g. the startup message, for replaying them on another backend, or nil.
+func (cw *ConnWrapper) SessionSetup() [][]byte {
+	if cw.sessionSetup == nil {
+		return nil
+	}
+	if setup := cw.sessionSetup.Load(); setup != nil {
+		return *setup
+	}
+	return nil
+}
+
+// RecordSessionSetup records a copy of the request as a request that sets up the
+// server session, unless the limit of the requests is already recorded.
+func (cw *ConnWrapper) RecordSessionSetup(request []byte, limit int) {
+	if cw.sessionSetup == nil || len(cw.SessionSetup()) >= limit {
+		return
+	}
+	setup := append(slices.Clone(cw.SessionSetup()), slices.Clone(request))
+	cw.sessionSetup.Store(&setup)
+}
+
+// Capture returns the capture of the connection's traffic, or nil if it isn't captured.
+func (cw *ConnWrapper) Capture() *Capture {
+	if cw.capture == nil {
+		return nil
+	}
+	return cw.capture.Load()
+}
+
+// SetCapture replaces the capture of the connection's traffic and returns the previous one.
+func (cw *ConnWrapper) SetCapture(capture *Capture) *Capture {
+	if cw.capture == nil {
+		return nil
+	}
+	return cw.capture.Swap(capture)
+}
+
+// BytesReceived returns the total bytes received from the client over the session.
+func (cw *ConnWrapper) BytesReceived() uint64 {
+	if cw.bytesReceived == nil {
+		return 0
+	}
+	return cw.bytesReceived.Load()
+}
+
+// BytesSent returns the total bytes sent to the client over the session.
+func (cw *ConnWrapper) BytesSent() uint64 {
+	if cw.bytesSent == nil {
+		return 0
+	}
+	return cw.bytesSent.Load()
+}
+
+// CountReceived adds the bytes received from the client to the total of the session.
+// The totals are kept on the client connection, so they include the traffic to and
+// from all the server connections of the session, e.g. after reconnecting.
+func (cw *ConnWrapper) CountReceived(n int) {
+	if cw.bytesReceived == nil || n <= 0 {
+		return
+	}
+	cw.bytesReceived.Add(uint64(n))
+}
+
+// CountSent adds the bytes sent to the client to the total of the session.
+func (cw *ConnWrapper) CountSent(n int) {
+	if cw.bytesSent == nil || n <= 0 {
+		return
+	}
+	cw.bytesSent.Add(uint64(n))
+}
+
+// trafficTotals returns the total bytes received from and sent to the client,
+// for the payloads of the hooks, e.g. for metering the usage of the clients.
+func trafficTotals(conn *ConnWrapper) map[string]interface{} {
+	return map[string]interface{}{
+		"received": conn.BytesReceived(),
+		"sent":     conn.BytesSent(),
+	}
+}
+
 // NewConnWrapper creates a new connection wrapper. The connection
 // wrapper is used to upgrade the connection to TLS if need be.
 func NewConnWrapper(
 	connWrapper ConnWrapper,
 ) *ConnWrapper {
-	return &ConnWrapper{
+	wrapper := &ConnWrapper{
 		NetConn:          connWrapper.NetConn,
 		TLSConfig:        connWrapper.TLSConfig,
 		isTLSEnabled:     connWrapper.TLSConfig != nil && connWrapper.TLSConfig.Certificates != nil,
 		HandshakeTimeout: connWrapper.HandshakeTimeout,
+		identity:         &atomic.Pointer[Identity]{},
+		certificate:      &atomic.Pointer[ClientCertificate]{},
+		statements:       NewPreparedStatements(),
+		txStatus:         &atomic.Uint32{},
+		lastActivity:     &atomic.Int64{},
+		cancelKey:        &atomic.Pointer[BackendKey]{},
+		labels:           &atomic.Pointer[map[string]string]{},
+		priority:         &atomic.Pointer[int]{},
+		sessionSetup:     &atomic.Pointer[[][]byte]{},
+		capture:          &atomic.Pointer[Capture]{},
+		bytesReceived:    &atomic.Uint64{},
+		bytesSent:        &atomic.Uint64{},
+		CompressionLevel: connWrapper.CompressionLevel,
 	}
+	wrapper.SetTxStatus(TxIdle)
+	wrapper.Touch()
+	return wrapper
 }
 
 // CreateTLSConfig returns a TLS config from the given cert and key.
@@ -147,3 +449,18 @@ func CreateTLSConfig(certFile, keyFile string) (*tls.Config, error) {
 		PreferServerCipherSuites: true,
 	}, nil
 }
+
+// LoadCertPool returns a certificate pool from the given CA file in PEM format.
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	caCert, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("failed to parse the CA certificate")
+	}
+
+	return certPool, nil
+}
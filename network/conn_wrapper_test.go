@@ -37,6 +37,22 @@ func Test_ConnWrapper_NoTLS(t *testing.T) {
 	assert.Equal(t, clientWrapper.RemoteAddr(), client.RemoteAddr())
 }
 
+// Test_ConnWrapper_Traffic tests counting the bytes received from and sent to the client.
+func Test_ConnWrapper_Traffic(t *testing.T) {
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn([]byte("select 1"))})
+	read, err := conn.Read(make([]byte, 4))
+	require.NoError(t, err)
+	assert.Equal(t, 4, read)
+	_, err = conn.Write([]byte("response"))
+	require.NoError(t, err)
+
+	// The traffic written to the connection directly is counted by the proxy.
+	conn.CountReceived(4)
+	conn.CountSent(-1)
+	assert.Equal(t, uint64(8), conn.BytesReceived())
+	assert.Equal(t, uint64(8), conn.BytesSent())
+}
+
 // Test_ConnWrapper_TLS tests that the CreateTLSConfig function correctly
 // creates a TLS config given a certificate and a private key.
 func Test_CreateTLSConfig(t *testing.T) {
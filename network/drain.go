@@ -0,0 +1,103 @@
+package network
+
+import (
+	"github.com/gatewayd-io/gatewayd/config"
+	"go.opentelemetry.io/otel"
+)
+
+// DrainBackend marks the backend, e.g. "tcp://localhost:5432", as drained for
+// maintenance, or returns it to service. No new server connections are opened to
+// a drained backend: its available server connections are moved to another backend
+// right away, and its busy ones when their clients disconnect. The other backends
+// aren't affected. It returns false if the backend isn't one of the proxy's backends.
+func (pr *Proxy) DrainBackend(backend string, drain bool) bool {
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "DrainBackend")
+	defer span.End()
+
+	var drained *config.Client
+	for _, clientConfig := range pr.backendConfigs() {
+		if clientConfig.Network+"://"+clientConfig.Address == backend {
+			drained = clientConfig
+			break
+		}
+	}
+	if drained == nil {
+		return false
+	}
+
+	if !pr.backendHealth.SetDraining(drained.Network, drained.Address, drain) {
+		return true
+	}
+
+	if !drain {
+		pr.Logger.Info().Str("backend", backend).Msg("Backend is back in service")
+		span.AddEvent("Returned the backend to service")
+		return true
+	}
+
+	pr.Logger.Info().Fields(
+		map[string]interface{}{
+			"backend":   backend,
+			"remaining": pr.busyConnectionsOf(backend),
+		},
+	).Msg("Draining the backend")
+	span.AddEvent("Draining the backend")
+
+	// The clients are collected first, since the recycled ones are put in the pool.
+	clients := []IClient{}
+	pr.AvailableConnections.ForEach(func(_, value interface{}) bool {
+		if client, ok := value.(IClient); ok &&
+			client.GetNetwork()+"://"+client.GetAddress() == backend {
+			clients = append(clients, client)
+		}
+		return true
+	})
+	for _, client := range clients {
+		pr.recycleClient(client)
+	}
+	pr.finishDrain(drained.Network, drained.Address)
+
+	return true
+}
+
+// finishDrain puts the drained backend in lame duck for the lame duck period once
+// its last busy server connection is recycled, after which it's reported as drained.
+func (pr *Proxy) finishDrain(network, address string) {
+	backend := network + "://" + address
+	if pr.busyConnectionsOf(backend) > 0 || !pr.backendHealth.SetDrained(network, address) {
+		return
+	}
+
+	pr.Logger.Info().Fields(
+		map[string]interface{}{
+			"backend":  backend,
+			"lameDuck": pr.LameDuckPeriod.String(),
+		},
+	).Msg("The backend is drained, and can be removed after the lame duck period")
+}
+
+// DrainingBackends returns the drained backends of the proxy with the number of
+// their busy server connections that are still to be recycled.
+func (pr *Proxy) DrainingBackends() map[string]int {
+	draining := map[string]int{}
+	for _, backend := range pr.backendConfigs() {
+		if pr.backendHealth.IsDraining(backend.Network, backend.Address) {
+			key := backend.Network + "://" + backend.Address
+			draining[key] = pr.busyConnectionsOf(key)
+		}
+	}
+	return draining
+}
+
+// busyConnectionsOf returns the number of the busy server connections of the backend.
+func (pr *Proxy) busyConnectionsOf(backend string) int {
+	busy := 0
+	pr.busyConnections.ForEach(func(_, value interface{}) bool {
+		if client, ok := value.(IClient); ok && client != nil &&
+			client.GetNetwork()+"://"+client.GetAddress() == backend {
+			busy++
+		}
+		return true
+	})
+	return busy
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/rs/zerolog"
+)
+
+var errDSCPNotSupported = errors.New("DSCP marking is not supported on this platform")
+
+// socketControl is the control function of a listener or a dialer, which is run on
+// the sockets before they're bound or connected.
+type socketControl func(network, address string, rawConn syscall.RawConn) error
+
+// dscpControl returns the control function of a listener or a dialer that marks the
+// IP packets of the TCP sockets with the DSCP class, for the QoS of the network.
+// The marking is best effort: if it fails, e.g. because the platform doesn't support
+// it, the error is logged and the socket is used without it.
+func dscpControl(dscp int, logger zerolog.Logger) socketControl {
+	return func(network, _ string, rawConn syscall.RawConn) error {
+		if !strings.HasPrefix(network, "tcp") {
+			return nil
+		}
+
+		markSocket(rawConn, network == "tcp6", dscp, logger)
+		return nil
+	}
+}
+
+// markDSCP marks the IP packets of the accepted connection with the DSCP class, in
+// case the platform doesn't mark the accepted sockets like the listening socket.
+func markDSCP(conn net.Conn, dscp int, logger zerolog.Logger) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok || dscp <= 0 {
+		return
+	}
+	rawConn, err := tcpConn.SyscallConn()
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to mark the connection with the DSCP class")
+		return
+	}
+
+	ipv6 := false
+	if addr, ok := tcpConn.LocalAddr().(*net.TCPAddr); ok {
+		ipv6 = addr.IP.To4() == nil
+	}
+	markSocket(rawConn, ipv6, dscp, logger)
+}
+
+// markSocket sets the DSCP class of the socket, logging the error if it fails.
+func markSocket(rawConn syscall.RawConn, ipv6 bool, dscp int, logger zerolog.Logger) {
+	var err error
+	if controlErr := rawConn.Control(func(fd uintptr) {
+		err = setDSCP(fd, ipv6, dscp)
+	}); controlErr != nil {
+		err = controlErr
+	}
+	if err != nil {
+		logger.Warn().Err(err).Int("dscp", dscp).Msg(
+			"Failed to mark the connection with the DSCP class, continuing without it")
+	}
+}
+
+// chainControls returns the control function that runs the control functions in
+// order, skipping the nil ones, or nil if there are none.
+func chainControls(controls ...socketControl) socketControl {
+	chain := []socketControl{}
+	for _, control := range controls {
+		if control != nil {
+			chain = append(chain, control)
+		}
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+
+	return func(network, address string, rawConn syscall.RawConn) error {
+		for _, control := range chain {
+			if err := control(network, address, rawConn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
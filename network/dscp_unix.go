@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package network
+
+import "golang.org/x/sys/unix"
+
+// setDSCP sets the DSCP class of the IP packets of the socket, in the upper 6 bits
+// of the type of service (IPv4) or the traffic class (IPv6). The IPv6 sockets may
+// also carry IPv4 traffic, which is marked too, if the platform supports it.
+func setDSCP(fd uintptr, ipv6 bool, dscp int) error {
+	if !ipv6 {
+		return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, dscp<<2) //nolint:wrapcheck
+	}
+
+	if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, dscp<<2); err != nil {
+		return err //nolint:wrapcheck
+	}
+	_ = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, dscp<<2)
+	return nil
+}
@@ -0,0 +1,70 @@
+//go:build !windows
+// +build !windows
+
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// tosOf returns the type of service of the IPv4 packets of the connection.
+func tosOf(t *testing.T, conn net.Conn) int {
+	t.Helper()
+
+	tcpConn, ok := conn.(*net.TCPConn)
+	require.True(t, ok)
+	rawConn, err := tcpConn.SyscallConn()
+	require.NoError(t, err)
+
+	var tos int
+	require.NoError(t, rawConn.Control(func(fd uintptr) {
+		tos, err = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS)
+	}))
+	require.NoError(t, err)
+	return tos
+}
+
+// TestDSCP tests marking the packets of the dialed, listening and accepted sockets
+// with the DSCP class.
+func TestDSCP(t *testing.T) {
+	const dscp = 46 // Expedited Forwarding
+
+	listenConfig := newListenConfig(false, 0, dscp, 0, zerolog.Nop())
+	listener, err := listenConfig.Listen(context.Background(), "tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn
+	}()
+
+	conn, err := newDialer(time.Second, false, dscp, 0, zerolog.Nop()).Dial("tcp4", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, dscp<<2, tosOf(t, conn))
+
+	server := <-accepted
+	defer server.Close()
+	markDSCP(server, dscp, zerolog.Nop())
+	assert.Equal(t, dscp<<2, tosOf(t, server))
+
+	// The sockets aren't marked without a DSCP class.
+	assert.Nil(t, newDialer(time.Second, false, 0, 0, zerolog.Nop()).Control)
+	unmarked, err := net.Dial("tcp4", listener.Addr().String())
+	require.NoError(t, err)
+	defer unmarked.Close()
+	assert.Equal(t, 0, tosOf(t, unmarked))
+}
@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package network
+
+// setDSCP is not supported on this platform, where the packets are marked by the
+// QoS policies of the system instead.
+func setDSCP(uintptr, bool, int) error {
+	return errDSCPNotSupported
+}
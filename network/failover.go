@@ -0,0 +1,285 @@
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/gatewayd-io/gatewayd/plugin"
+)
+
+// The status of the backends.
+const (
+	BackendHealthy  = "healthy"
+	BackendDown     = "down"
+	BackendDraining = "draining"
+	BackendLameDuck = "lameDuck"
+	BackendDrained  = "drained"
+)
+
+// BackendHealth keeps track of the backends of a client config that failed their
+// health check, so that the clients can fail over to a healthy backend, and of the
+// backends that are drained for maintenance.
+type BackendHealth struct {
+	unhealthy map[string]bool
+	draining  map[string]bool
+	// drainedAt is when the drained backends recycled their last server connection.
+	drainedAt map[string]time.Time
+	mu        sync.RWMutex
+}
+
+// NewBackendHealth creates a new backend health tracker, with all backends healthy.
+func NewBackendHealth() *BackendHealth {
+	return &BackendHealth{
+		unhealthy: map[string]bool{},
+		draining:  map[string]bool{},
+		drainedAt: map[string]time.Time{},
+	}
+}
+
+// IsHealthy returns false if the backend failed its last health check.
+func (bh *BackendHealth) IsHealthy(network, address string) bool {
+	if bh == nil {
+		return true
+	}
+
+	bh.mu.RLock()
+	defer bh.mu.RUnlock()
+	return !bh.unhealthy[network+"://"+address]
+}
+
+// Set records the result of the health check of the backend and returns true
+// if the backend's health changed.
+func (bh *BackendHealth) Set(network, address string, healthy bool) bool {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+
+	key := network + "://" + address
+	if healthy == !bh.unhealthy[key] {
+		return false
+	}
+
+	if healthy {
+		delete(bh.unhealthy, key)
+	} else {
+		bh.unhealthy[key] = true
+	}
+	return true
+}
+
+// IsDraining returns true if the backend is drained for maintenance.
+func (bh *BackendHealth) IsDraining(network, address string) bool {
+	if bh == nil {
+		return false
+	}
+
+	bh.mu.RLock()
+	defer bh.mu.RUnlock()
+	return bh.draining[network+"://"+address]
+}
+
+// IsAvailable returns true if the backend can be assigned new server connections,
+// i.e. it's healthy and it isn't drained.
+func (bh *BackendHealth) IsAvailable(network, address string) bool {
+	return bh.IsHealthy(network, address) && !bh.IsDraining(network, address)
+}
+
+// SetDraining marks the backend as drained or not, and returns true if it changed.
+func (bh *BackendHealth) SetDraining(network, address string, draining bool) bool {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+
+	key := network + "://" + address
+	if draining == bh.draining[key] {
+		return false
+	}
+
+	if draining {
+		bh.draining[key] = true
+	} else {
+		delete(bh.draining, key)
+	}
+	delete(bh.drainedAt, key)
+	return true
+}
+
+// SetDrained records that the drained backend recycled its last server connection,
+// and returns true if it wasn't recorded already.
+func (bh *BackendHealth) SetDrained(network, address string) bool {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+
+	key := network + "://" + address
+	if _, ok := bh.drainedAt[key]; ok || !bh.draining[key] {
+		return false
+	}
+	bh.drainedAt[key] = time.Now()
+	return true
+}
+
+// DrainedAt returns when the drained backend recycled its last server connection,
+// or false if it's still draining or isn't drained.
+func (bh *BackendHealth) DrainedAt(network, address string) (time.Time, bool) {
+	if bh == nil {
+		return time.Time{}, false
+	}
+
+	bh.mu.RLock()
+	defer bh.mu.RUnlock()
+	drainedAt, ok := bh.drainedAt[network+"://"+address]
+	return drainedAt, ok
+}
+
+// ping checks if the server is reachable by opening a new connection to it.
+func ping(network, address string, timeout time.Duration) error {
+	conn, err := dialNetwork(network, address, timeout)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	return conn.Close() //nolint:wrapcheck
+}
+
+// backendConfigs returns the client configs of the backends of the proxy.
+func (pr *Proxy) backendConfigs() []*config.Client {
+	if pr.ClientConfig == nil {
+		return nil
+	}
+
+	clientConfig := *pr.ClientConfig
+	clientConfig.Backends = pr.backendList()
+	backends := make([]*config.Client, 0, len(clientConfig.Backends))
+	for index := range max(len(clientConfig.Backends), 1) {
+		backends = append(backends, clientConfig.GetBackend(index))
+	}
+	return backends
+}
+
+// clientConfigOf returns the client config of the client's backend.
+func (pr *Proxy) clientConfigOf(client IClient) *config.Client {
+	if cl, ok := client.(*Client); ok && cl.config != nil {
+		return cl.config
+	}
+	return pr.ClientConfig
+}
+
+// BackendStatus returns the backends of the proxy with their status: healthy,
+// down, draining, lame duck or drained.
+func (pr *Proxy) BackendStatus() map[string]string {
+	backends := map[string]string{}
+	for _, backend := range pr.backendConfigs() {
+		status := BackendHealthy
+		switch {
+		case pr.backendHealth.IsDraining(backend.Network, backend.Address):
+			status = BackendDraining
+			if drainedAt, ok := pr.backendHealth.DrainedAt(backend.Network, backend.Address); ok {
+				status = config.If(
+					time.Since(drainedAt) < pr.LameDuckPeriod, BackendLameDuck, BackendDrained)
+			}
+		case !pr.backendHealth.IsHealthy(backend.Network, backend.Address):
+			status = BackendDown
+		}
+		backends[backend.Network+"://"+backend.Address] = status
+	}
+	return backends
+}
+
+// failoverBackend returns the config of the first healthy backend that isn't drained,
+// in the order of the config, or nil if all the backends are down or drained.
+func (pr *Proxy) failoverBackend() *config.Client {
+	for _, backend := range pr.backendConfigs() {
+		if pr.backendHealth.IsAvailable(backend.Network, backend.Address) {
+			return backend
+		}
+	}
+	return nil
+}
+
+// HasHealthyBackend returns false if all the backends of the proxy failed their
+// last health check, in which case the new connections are rejected right away.
+func (pr *Proxy) HasHealthyBackend() bool {
+	for _, backend := range pr.backendConfigs() {
+		if pr.backendHealth.IsHealthy(backend.Network, backend.Address) {
+			return true
+		}
+	}
+	return pr.ClientConfig == nil
+}
+
+// checkBackends checks the health of the backends and fails over from the backends
+// that are down to the first healthy one. The clients of the backends that are down
+// are moved when they're recycled.
+func (pr *Proxy) checkBackends() {
+	for _, backend := range pr.backendConfigs() {
+		err := ping(backend.Network, backend.Address, config.If(
+			backend.DialTimeout > 0, backend.DialTimeout, config.DefaultDialTimeout))
+		name := backend.Network + "://" + backend.Address
+		metrics.BackendHealthy.WithLabelValues(name).Set(config.If(err == nil, 1.0, 0.0))
+		if !pr.backendHealth.Set(backend.Network, backend.Address, err == nil) {
+			continue
+		}
+		pr.backendHealthChanged(backend, err)
+
+		fields := map[string]interface{}{
+			"network": backend.Network,
+			"address": backend.Address,
+		}
+		if err == nil {
+			pr.Logger.Info().Fields(fields).Msg("Backend is healthy again")
+			continue
+		}
+
+		pr.Logger.Warn().Err(err).Fields(fields).Msg("Backend is down")
+		failover := pr.failoverBackend()
+		if failover == nil {
+			pr.Logger.Error().Msg(
+				"All backends are down, no backend to fail over to, rejecting the new connections")
+			continue
+		}
+
+		pr.Logger.Warn().Fields(map[string]interface{}{
+			"from": name,
+			"to":   failover.Network + "://" + failover.Address,
+		}).Msg("Failing over to another backend")
+		metrics.BackendFailovers.Inc()
+
+		pr.runLifecycleHook(plugin.OnFailoverHookName, map[string]interface{}{
+			"old": map[string]interface{}{
+				"network":  backend.Network,
+				"address":  backend.Address,
+				"error":    err.Error(),
+				"user":     backend.User,
+				"database": backend.Database,
+			},
+			"new": map[string]interface{}{
+				"network":  failover.Network,
+				"address":  failover.Address,
+				"user":     failover.User,
+				"database": failover.Database,
+			},
+		})
+	}
+}
+
+// backendHealthChanged counts the transition of the backend to its new state, healthy
+// if the error of its health check is nil or down otherwise, and runs the
+// OnBackendHealthChange hooks.
+func (pr *Proxy) backendHealthChanged(backend *config.Client, err error) {
+	state, errMsg := BackendHealthy, ""
+	if err != nil {
+		state, errMsg = BackendDown, err.Error()
+	}
+	metrics.BackendHealthTransitions.WithLabelValues(
+		backend.Network+"://"+backend.Address, state).Inc()
+
+	pr.runLifecycleHook(plugin.OnBackendHealthChangeHookName, map[string]interface{}{
+		"backend": map[string]interface{}{
+			"network":  backend.Network,
+			"address":  backend.Address,
+			"user":     backend.User,
+			"database": backend.Database,
+		},
+		"state": state,
+		"error": errMsg,
+	})
+}
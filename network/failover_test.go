@@ -0,0 +1,177 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBackendHealth tests keeping track of the backends that are down.
+func TestBackendHealth(t *testing.T) {
+	health := NewBackendHealth()
+	assert.True(t, health.IsHealthy("tcp", "localhost:5432"))
+
+	assert.False(t, health.Set("tcp", "localhost:5432", true), "the backend is already healthy")
+	assert.True(t, health.Set("tcp", "localhost:5432", false))
+	assert.False(t, health.IsHealthy("tcp", "localhost:5432"))
+	assert.True(t, health.IsHealthy("tcp", "localhost:5433"))
+	assert.False(t, health.Set("tcp", "localhost:5432", false), "the backend is already down")
+	assert.True(t, health.Set("tcp", "localhost:5432", true))
+	assert.True(t, health.IsHealthy("tcp", "localhost:5432"))
+
+	assert.True(t, (*BackendHealth)(nil).IsHealthy("tcp", "localhost:5432"))
+
+	// The drained backends are healthy, but not available.
+	assert.True(t, health.SetDraining("tcp", "localhost:5432", true))
+	assert.False(t, health.SetDraining("tcp", "localhost:5432", true), "the backend is already drained")
+	assert.True(t, health.IsDraining("tcp", "localhost:5432"))
+	assert.True(t, health.IsHealthy("tcp", "localhost:5432"))
+	assert.False(t, health.IsAvailable("tcp", "localhost:5432"))
+	assert.True(t, health.IsAvailable("tcp", "localhost:5433"))
+	_, drained := health.DrainedAt("tcp", "localhost:5432")
+	assert.False(t, drained)
+	assert.True(t, health.SetDrained("tcp", "localhost:5432"))
+	assert.False(t, health.SetDrained("tcp", "localhost:5432"), "the backend is already drained")
+	assert.False(t, health.SetDrained("tcp", "localhost:5433"), "the backend isn't drained")
+	_, drained = health.DrainedAt("tcp", "localhost:5432")
+	assert.True(t, drained)
+	assert.True(t, health.SetDraining("tcp", "localhost:5432", false))
+	assert.True(t, health.IsAvailable("tcp", "localhost:5432"))
+	_, drained = health.DrainedAt("tcp", "localhost:5432")
+	assert.False(t, drained)
+}
+
+// TestFailover tests moving the clients of a backend that is down to a healthy one.
+func TestFailover(t *testing.T) {
+	upstream := newFakeUpstream(t, func(net.Conn) {})
+
+	// Get the address of a backend that is down.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	down := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	clientConfig := newTestClientConfig(down)
+	clientConfig.Backends = []config.Backend{
+		{Network: "tcp", Address: down},
+		{Network: "tcp", Address: upstream.Address()},
+	}
+	client, _ := newMemoryClient("client")
+	proxy := newTestProxyWithClients(t, clientConfig, client)
+
+	downTransitions := testutil.ToFloat64(
+		metrics.BackendHealthTransitions.WithLabelValues("tcp://"+down, BackendDown))
+
+	assert.Equal(t, down, proxy.failoverBackend().Address)
+	proxy.checkBackends()
+	assert.False(t, proxy.backendHealth.IsHealthy("tcp", down))
+	assert.True(t, proxy.backendHealth.IsHealthy("tcp", upstream.Address()))
+	assert.Equal(t, upstream.Address(), proxy.failoverBackend().Address)
+
+	// The health of each backend is exported, and only the transitions are counted.
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.BackendHealthy.WithLabelValues("tcp://"+down)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(
+		metrics.BackendHealthy.WithLabelValues("tcp://"+upstream.Address())))
+	proxy.checkBackends()
+	assert.Equal(t, downTransitions+1, testutil.ToFloat64(
+		metrics.BackendHealthTransitions.WithLabelValues("tcp://"+down, BackendDown)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.BackendHealthTransitions.WithLabelValues(
+		"tcp://"+upstream.Address(), BackendDown)))
+
+	// The client of the backend that is down is recreated on the healthy backend.
+	proxy.recycleClient(client)
+	require.Equal(t, 1, proxy.AvailableConnections.Size())
+	proxy.AvailableConnections.ForEach(func(_, value interface{}) bool {
+		assert.Equal(t, upstream.Address(), value.(IClient).GetAddress())
+		return true
+	})
+}
+
+// TestNoHealthyUpstream tests rejecting the new connections when all the backends are down.
+func TestNoHealthyUpstream(t *testing.T) {
+	// Get the address of a backend that is down.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	down := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	client, _ := newMemoryClient("client")
+	proxy := newTestProxyWithClients(t, newTestClientConfig(down), client)
+
+	// The backends are healthy until they fail a health check.
+	assert.True(t, proxy.HasHealthyBackend())
+	proxy.checkBackends()
+	assert.False(t, proxy.HasHealthyBackend())
+	assert.Equal(t, map[string]string{"tcp://" + down: BackendDown}, proxy.BackendStatus())
+
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	assert.ErrorIs(t, proxy.Connect(conn), gerr.ErrNoHealthyUpstream)
+	assert.Equal(t, 1, proxy.AvailableConnections.Size(), "the client isn't handed out")
+	assert.Equal(t, 0, proxy.busyConnections.Size())
+
+	// The proxy without a client config has no backends to check.
+	assert.True(t, (&Proxy{}).HasHealthyBackend())
+}
+
+// TestDrainBackend tests moving the server connections of a drained backend to
+// another backend, the available ones right away and the busy ones on disconnect.
+func TestDrainBackend(t *testing.T) {
+	drained := newFakeUpstream(t, func(net.Conn) {})
+	other := newFakeUpstream(t, func(net.Conn) {})
+
+	clientConfig := newTestClientConfig(drained.Address())
+	clientConfig.Backends = []config.Backend{
+		{Network: "tcp", Address: drained.Address()},
+		{Network: "tcp", Address: other.Address()},
+	}
+	busy := NewClient(context.Background(), clientConfig.GetBackend(0), zerolog.Nop(), nil)
+	require.NotNil(t, busy)
+	available := NewClient(context.Background(), clientConfig.GetBackend(0), zerolog.Nop(), nil)
+	require.NotNil(t, available)
+	proxy := newTestProxyWithClients(t, clientConfig, busy, available)
+	proxy.LameDuckPeriod = time.Hour
+
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+
+	backend := "tcp://" + drained.Address()
+	assert.False(t, proxy.DrainBackend("tcp://localhost:1", true), "not a backend of the proxy")
+	assert.True(t, proxy.DrainBackend(backend, true))
+	assert.Equal(t, map[string]int{backend: 1}, proxy.DrainingBackends())
+	assert.Equal(t, BackendDraining, proxy.BackendStatus()[backend])
+
+	// The available server connection is moved right away.
+	assertBackends := func(address string) {
+		t.Helper()
+		proxy.AvailableConnections.ForEach(func(_, value interface{}) bool {
+			assert.Equal(t, address, value.(IClient).GetAddress())
+			return true
+		})
+	}
+	require.Equal(t, 1, proxy.AvailableConnections.Size())
+	assertBackends(other.Address())
+
+	// The busy server connection is moved when its client disconnects.
+	require.Nil(t, proxy.Disconnect(conn))
+	assert.Equal(t, map[string]int{backend: 0}, proxy.DrainingBackends())
+	require.Equal(t, 2, proxy.AvailableConnections.Size())
+	assertBackends(other.Address())
+
+	// The backend is in lame duck, and then drained after the lame duck period.
+	assert.Equal(t, BackendLameDuck, proxy.BackendStatus()[backend])
+	proxy.LameDuckPeriod = 0
+	assert.Equal(t, BackendDrained, proxy.BackendStatus()[backend])
+
+	assert.True(t, proxy.DrainBackend(backend, false))
+	assert.Empty(t, proxy.DrainingBackends())
+	assert.Equal(t, BackendHealthy, proxy.BackendStatus()[backend])
+}
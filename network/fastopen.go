@@ -0,0 +1,80 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var errTCPFastOpenNotSupported = errors.New("TCP Fast Open is not supported on this platform")
+
+// fastOpenControl returns the control function of a listener or a dialer that
+// enables TCP Fast Open on the TCP sockets. TCP Fast Open is best effort: if it
+// can't be enabled, e.g. because the platform or the kernel doesn't support it,
+// the error is logged and the socket is used without it.
+func fastOpenControl(enable func(fd uintptr) error, logger zerolog.Logger) socketControl {
+	return func(network, _ string, rawConn syscall.RawConn) error {
+		if !strings.HasPrefix(network, "tcp") {
+			return nil
+		}
+
+		var err error
+		if controlErr := rawConn.Control(func(fd uintptr) {
+			err = enable(fd)
+		}); controlErr != nil {
+			err = controlErr
+		}
+		if err != nil {
+			logger.Warn().Err(err).Msg("Failed to enable TCP Fast Open, continuing without it")
+		}
+		return nil
+	}
+}
+
+// newListenConfig returns the config of the server's listener. If fastOpen is set,
+// TCP Fast Open is enabled on the listener, with queueLength as the maximum number
+// of pending Fast Open requests. If dscp is positive, the packets are marked with it.
+// If userTimeout is positive, it's the TCP user timeout of the connections.
+func newListenConfig(
+	fastOpen bool, queueLength, dscp int, userTimeout time.Duration, logger zerolog.Logger,
+) net.ListenConfig {
+	var fastOpenCtl, dscpCtl, userTimeoutCtl socketControl
+	if fastOpen {
+		fastOpenCtl = fastOpenControl(func(fd uintptr) error {
+			return setTCPFastOpen(fd, queueLength)
+		}, logger)
+	}
+	if dscp > 0 {
+		dscpCtl = dscpControl(dscp, logger)
+	}
+	if userTimeout > 0 {
+		userTimeoutCtl = userTimeoutControl(userTimeout, logger)
+	}
+
+	return net.ListenConfig{Control: chainControls(fastOpenCtl, dscpCtl, userTimeoutCtl)}
+}
+
+// newDialer returns the dialer of the client's connections. If fastOpen is set,
+// TCP Fast Open is enabled on the connections. If dscp is positive, the packets are
+// marked with it. If userTimeout is positive, it's the TCP user timeout of the
+// connections. A zero timeout means no timeout.
+func newDialer(
+	timeout time.Duration, fastOpen bool, dscp int, userTimeout time.Duration, logger zerolog.Logger,
+) *net.Dialer {
+	var fastOpenCtl, dscpCtl, userTimeoutCtl socketControl
+	if fastOpen {
+		fastOpenCtl = fastOpenControl(setTCPFastOpenConnect, logger)
+	}
+	if dscp > 0 {
+		dscpCtl = dscpControl(dscp, logger)
+	}
+	if userTimeout > 0 {
+		userTimeoutCtl = userTimeoutControl(userTimeout, logger)
+	}
+
+	return &net.Dialer{Timeout: timeout, Control: chainControls(fastOpenCtl, dscpCtl, userTimeoutCtl)}
+}
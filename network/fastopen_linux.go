@@ -0,0 +1,17 @@
+//go:build linux
+// +build linux
+
+package network
+
+import "golang.org/x/sys/unix"
+
+// setTCPFastOpen enables TCP Fast Open on the listening socket.
+func setTCPFastOpen(fd uintptr, queueLength int) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN, queueLength) //nolint:wrapcheck
+}
+
+// setTCPFastOpenConnect enables TCP Fast Open on the connecting socket, so that
+// the first write is sent with the SYN, once the server's cookie is cached.
+func setTCPFastOpenConnect(fd uintptr) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT, 1) //nolint:wrapcheck
+}
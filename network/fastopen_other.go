@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package network
+
+// setTCPFastOpen is not supported on this platform.
+func setTCPFastOpen(uintptr, int) error {
+	return errTCPFastOpenNotSupported
+}
+
+// setTCPFastOpenConnect is not supported on this platform.
+func setTCPFastOpenConnect(uintptr) error {
+	return errTCPFastOpenNotSupported
+}
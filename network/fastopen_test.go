@@ -0,0 +1,52 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTCPFastOpen tests that the listener and the dialer work with TCP Fast Open,
+// whether or not the platform supports it.
+func TestTCPFastOpen(t *testing.T) {
+	listenConfig := newListenConfig(true, 16, 0, 0, zerolog.Nop())
+	listener, err := listenConfig.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	accepted := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		buf := make([]byte, 5)
+		if _, err := conn.Read(buf); err == nil {
+			accepted <- buf
+		}
+	}()
+
+	conn, err := newDialer(time.Second, true, 0, 0, zerolog.Nop()).Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), <-accepted)
+}
+
+// TestNewDialer tests that TCP Fast Open is only enabled if it's configured.
+func TestNewDialer(t *testing.T) {
+	dialer := newDialer(time.Second, false, 0, 0, zerolog.Nop())
+	assert.Equal(t, time.Second, dialer.Timeout)
+	assert.Nil(t, dialer.Control)
+
+	assert.NotNil(t, newDialer(0, true, 0, 0, zerolog.Nop()).Control)
+	assert.Nil(t, newListenConfig(false, 16, 0, 0, zerolog.Nop()).Control)
+}
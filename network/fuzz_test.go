@@ -0,0 +1,93 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// FuzzFramer tests that the PostgreSQL message framing doesn't panic on malformed
+// input from untrusted clients and servers, and that its memory usage is bounded
+// by the size of the input.
+func FuzzFramer(f *testing.F) {
+	messages := []pgproto3.Message{
+		&pgproto3.Parse{Name: "stmt1", Query: "SELECT $1"},
+		&pgproto3.Close{ObjectType: 'S', Name: "stmt1"},
+		&pgproto3.Sync{},
+		&pgproto3.Query{String: "SELECT 1"},
+		&pgproto3.ReadyForQuery{TxStatus: byte(TxInTransaction)},
+		&pgproto3.BackendKeyData{ProcessID: 1234, SecretKey: 5678},
+	}
+	for _, msg := range messages {
+		encoded, err := msg.Encode(nil)
+		require.NoError(f, err)
+		f.Add(encoded)
+		// Truncated messages.
+		f.Add(encoded[:len(encoded)-1])
+		f.Add(encoded[:pgHeaderLength])
+	}
+	f.Add(CreatePgStartupPacket())
+	f.Add(CreatePgTerminatePacket())
+	f.Add(CreatePgCancelRequest(BackendKey{ProcessID: 1, SecretKey: 2}))
+	f.Add([]byte{0x00, 0x00, 0x00, 0x8, 0x04, 0xd2, 0x16, 0x2f})
+	f.Add([]byte{'P', 0xff, 0xff, 0xff, 0xff})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		GetTxStatus(data)
+		IsPostgresSSLRequest(data)
+		DecodeCancelRequest(data)
+		if startup, ok := DecodeStartupMessage(data); ok {
+			GetStartupToken(startup)
+		}
+
+		statements := NewPreparedStatements()
+		statements.Track(data)
+		assert.LessOrEqual(t, len(statements.Replay(nil)), len(data)+pgHeaderLength)
+
+		response := append([]byte{}, data...)
+		NewCancelKeys().Translate(response, "tcp", "localhost:5432")
+		assert.Len(t, response, len(data))
+	})
+}
+
+// FuzzExtractFieldValue tests that the hook results of any shape don't panic
+// when the fields are extracted.
+func FuzzExtractFieldValue(f *testing.F) {
+	f.Add("response", []byte("data"), "error", int64(0))
+	f.Add("request", []byte{}, "", int64(1))
+	f.Add("", []byte(nil), "error", int64(2))
+
+	f.Fuzz(func(t *testing.T, name string, value []byte, errMsg string, kind int64) {
+		result := map[string]interface{}{}
+		switch kind % 4 {
+		case 0:
+			result[name] = value
+			result["error"] = errMsg
+		case 1:
+			result[name] = string(value)
+			result["error"] = value
+		case 2:
+			result[name] = kind
+			result["error"] = nil
+		default:
+			result = nil
+		}
+
+		data, _ := extractFieldValue(result, name)
+		if data != nil {
+			assert.Equal(t, value, data)
+		}
+
+		serverSide, clientSide := net.Pipe()
+		defer serverSide.Close()
+		defer clientSide.Close()
+		client := &Client{}
+		for _, err := range []interface{}{errMsg, value, kind, nil} {
+			assert.NotNil(t, trafficData(serverSide, client, []Field{{Name: name, Value: value}}, err))
+		}
+	})
+}
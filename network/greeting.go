@@ -0,0 +1,51 @@
+package network
+
+import (
+	"encoding/binary"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+const (
+	// pgParameterStatus is the message type of the server's parameter status messages.
+	pgParameterStatus = 'S'
+
+	// serverVersionParameter is the parameter of the server's version.
+	serverVersionParameter = "server_version"
+)
+
+// RewriteServerVersion replaces the server version advertised to the client in the
+// parameter status messages of the server's response, which are part of the server's
+// greeting after the startup, with the given version. The other messages are kept
+// as is. It returns the rewritten response and true if the version was replaced.
+func RewriteServerVersion(response []byte, version string) ([]byte, bool) {
+	var rewritten []byte
+	copied := 0
+	for offset := 0; offset+pgHeaderLength <= len(response); {
+		length := int(binary.BigEndian.Uint32(response[offset+1 : offset+pgHeaderLength]))
+		end := offset + 1 + length
+		if length < 4 || end > len(response) {
+			break
+		}
+
+		body := response[offset+pgHeaderLength : end]
+		if response[offset] == pgParameterStatus && cString(body) == serverVersionParameter {
+			status, err := (&pgproto3.ParameterStatus{
+				Name:  serverVersionParameter,
+				Value: version,
+			}).Encode(append(rewritten, response[copied:offset]...))
+			if err != nil {
+				return response, false
+			}
+			rewritten = status
+			copied = end
+		}
+
+		offset = end
+	}
+
+	if rewritten == nil {
+		return response, false
+	}
+	return append(rewritten, response[copied:]...), true
+}
@@ -0,0 +1,57 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeMessages encodes the backend messages into a single response.
+func encodeMessages(t *testing.T, messages ...pgproto3.BackendMessage) []byte {
+	t.Helper()
+
+	var response []byte
+	for _, message := range messages {
+		var err error
+		response, err = message.Encode(response)
+		require.NoError(t, err)
+	}
+	return response
+}
+
+// TestRewriteServerVersion tests advertising another server version in the greeting.
+func TestRewriteServerVersion(t *testing.T) {
+	response := encodeMessages(t,
+		&pgproto3.AuthenticationOk{},
+		&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"},
+		&pgproto3.ParameterStatus{Name: "server_version", Value: "16.3 (Debian 16.3-1.pgdg120+1)"},
+		&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 2},
+		&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)},
+	)
+
+	rewritten, ok := RewriteServerVersion(response, "15.0")
+	require.True(t, ok)
+	assert.Equal(t, encodeMessages(t,
+		&pgproto3.AuthenticationOk{},
+		&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"},
+		&pgproto3.ParameterStatus{Name: "server_version", Value: "15.0"},
+		&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 2},
+		&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)},
+	), rewritten)
+
+	// The protocol flow continues after the greeting.
+	status, ok := GetTxStatus(rewritten)
+	assert.True(t, ok)
+	assert.Equal(t, TxIdle, status)
+
+	// The responses without the server version aren't changed.
+	query := encodeMessages(t,
+		&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")},
+		&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)},
+	)
+	unchanged, ok := RewriteServerVersion(query, "15.0")
+	assert.False(t, ok)
+	assert.Equal(t, query, unchanged)
+}
@@ -0,0 +1,184 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	errNoHandoffBackend  = errors.New("no backend to hand the session off to")
+	errHandoffConnection = errors.New("failed to connect to the backend")
+	errHandoffReplay     = errors.New("failed to replay the session setup")
+	errHandoffAuth       = errors.New("only the trust and cleartext password sessions can be handed off")
+)
+
+const (
+	// The PostgreSQL messages and authentication requests of the session setup.
+	pgAuthentication       = 'R'
+	pgPasswordMessage      = 'p'
+	pgAuthOk               = 0
+	pgAuthCleartext        = 3
+	pgMD5PasswordLength    = len("md5") + 32 + 1 // the hex digest and the terminator
+	pgSASLMechanismPrefix  = "SCRAM-"
+	pgAuthenticationLength = pgHeaderLength + 4
+)
+
+// handoff hands the session of the client connection off to a new server connection
+// of another backend, if its backend is drained or down, and returns the server
+// connection of the session. The handoff is opt-in, since it's only safe for the
+// protocols whose sessions are fully set up by the recorded setup requests: the
+// sessions are only handed off between the requests and outside a transaction. The
+// pre-authenticated sessions are already set up, and the others get the recorded setup
+// requests replayed, with the credentials of the new backend, and their responses
+// discarded. Only the sessions authenticated with trust or a cleartext password can
+// be replayed, since the MD5 and SCRAM responses are bound to the salt or the nonce of
+// the original server, so the others are never handed off. The prepared statements of
+// the session are prepared again. Any other state of the session, e.g. the parameters
+// set with SET, is lost. If the handoff fails, the session stays on its server
+// connection, and the handoff is retried on the next request.
+func (pr *Proxy) handoff(conn *ConnWrapper, client IClient) IClient {
+	if !pr.SessionHandoff {
+		return client
+	}
+
+	backend := pr.clientConfigOf(client)
+	if backend == nil || pr.backendHealth.IsAvailable(backend.Network, backend.Address) {
+		return client
+	}
+
+	// The session can't be handed off mid-handshake or with state it can't restore.
+	preAuthenticated := client.StartupResponse() != nil
+	setup := conn.SessionSetup()
+	setupComplete := pr.HandoffSetupRequests > 0 && len(setup) >= pr.HandoffSetupRequests
+	if conn.TxStatus().InTransaction() || (!preAuthenticated && !setupComplete) {
+		return client
+	}
+	if !preAuthenticated && !isReplayableSetup(setup) {
+		pr.Logger.Debug().Str("client", RemoteAddr(conn.Conn())).Msg(
+			"Not handing the session off, because its authentication can't be replayed")
+		return client
+	}
+
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "handoff")
+	defer span.End()
+
+	from := backend.Network + "://" + backend.Address
+	newClient, err := pr.handoffClient(conn, preAuthenticated, setup)
+	if err != nil {
+		pr.Logger.Warn().Err(err).Str("from", from).Msg(
+			"Failed to hand the session off to another backend, retrying on the next request")
+		span.RecordError(err)
+		metrics.SessionHandoffs.WithLabelValues("failed").Inc()
+		return client
+	}
+
+	if err := pr.busyConnections.Put(conn, newClient); err != nil {
+		span.RecordError(err)
+		newClient.Close()
+		return client
+	}
+	pr.balanced.Delete(conn)
+
+	// The old server connection is closed instead of being recycled, since the new one
+	// takes its place in the pool when the client disconnects.
+	network, address := client.GetNetwork(), client.GetAddress()
+	client.Close()
+	pr.finishDrain(network, address)
+
+	pr.Logger.Info().Fields(map[string]interface{}{
+		"from":   from,
+		"to":     newClient.GetNetwork() + "://" + newClient.GetAddress(),
+		"client": RemoteAddr(conn.Conn()),
+	}).Msg("Handed the session off to another backend")
+	span.AddEvent("Handed the session off to another backend")
+	metrics.SessionHandoffs.WithLabelValues("succeeded").Inc()
+	return newClient
+}
+
+// handoffClient connects to the first healthy backend that isn't drained, and sets
+// up the session of the client connection on it.
+func (pr *Proxy) handoffClient(conn *ConnWrapper, preAuthenticated bool, setup [][]byte) (IClient, error) {
+	target := pr.failoverBackend()
+	if target == nil {
+		return nil, errNoHandoffBackend
+	}
+
+	newClient := pr.newClient(target)
+	if newClient == nil {
+		return nil, errHandoffConnection
+	}
+
+	// The pre-authenticated session is already set up, but the client must be too.
+	if preAuthenticated {
+		if newClient.StartupResponse() == nil {
+			newClient.Close()
+			return nil, errHandoffReplay
+		}
+		if key := conn.CancelKey(); key != nil {
+			pr.cancelKeys.Retarget(*key, newClient.StartupResponse(), newClient.GetNetwork(), newClient.GetAddress())
+		}
+		return pr.replayHandoffStatements(conn, newClient)
+	}
+
+	for _, request := range setup {
+		request = RewriteStartupMessage(request, newClient.StartupParameters())
+		if _, err := pr.sendTrafficToServer(withLabels(pr.Logger, conn), newClient, request); err != nil {
+			newClient.Close()
+			return nil, errors.Join(errHandoffReplay, err)
+		}
+		_, response, err := pr.receiveTrafficFromServer(newClient)
+		if err != nil {
+			newClient.Close()
+			return nil, errors.Join(errHandoffReplay, err)
+		}
+		// The new backend may ask for another authentication than the original one.
+		if code, ok := authenticationRequest(response); ok && code != pgAuthOk && code != pgAuthCleartext {
+			newClient.Close()
+			return nil, errHandoffAuth
+		}
+		if key := conn.CancelKey(); key != nil {
+			pr.cancelKeys.Retarget(*key, response, newClient.GetNetwork(), newClient.GetAddress())
+		}
+	}
+	return pr.replayHandoffStatements(conn, newClient)
+}
+
+// replayHandoffStatements prepares the prepared statements of the session again on
+// the server connection it's handed off to, and closes it if that fails.
+func (pr *Proxy) replayHandoffStatements(conn *ConnWrapper, newClient IClient) (IClient, error) {
+	if err := pr.replayPreparedStatements(conn, newClient, nil); err != nil {
+		newClient.Close()
+		return nil, err
+	}
+	return newClient, nil
+}
+
+// isReplayableSetup returns false if the recorded setup requests of the session have
+// an MD5 password or a SASL response, which can't be replayed to another server.
+func isReplayableSetup(setup [][]byte) bool {
+	for _, request := range setup {
+		if len(request) <= pgHeaderLength || request[0] != pgPasswordMessage || !IsPostgresMessages(request) {
+			continue
+		}
+		body := request[pgHeaderLength:]
+		if bytes.HasPrefix(body, []byte(pgSASLMechanismPrefix)) ||
+			(len(body) == pgMD5PasswordLength && bytes.HasPrefix(body, []byte("md5"))) {
+			return false
+		}
+	}
+	return true
+}
+
+// authenticationRequest returns the code of the authentication request at the
+// beginning of the response of the server, if it starts with one.
+func authenticationRequest(response []byte) (uint32, bool) {
+	if len(response) < pgAuthenticationLength || response[0] != pgAuthentication {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(response[pgHeaderLength:pgAuthenticationLength]), true
+}
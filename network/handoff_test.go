@@ -0,0 +1,150 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSessionHandoff tests handing the session off to another backend when its
+// backend is drained, by replaying the recorded startup message.
+func TestSessionHandoff(t *testing.T) {
+	first := newFakeUpstream(t, func(net.Conn) {})
+	replayed := make(chan []byte, 1)
+	second := newFakeUpstream(t, func(conn net.Conn) {
+		buf := make([]byte, 1024)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		replayed <- buf[:n]
+
+		response, _ := (&pgproto3.AuthenticationOk{}).Encode(nil)
+		response, _ = (&pgproto3.BackendKeyData{ProcessID: 4321, SecretKey: 8765}).Encode(response)
+		response, _ = (&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)}).Encode(response)
+		_, _ = conn.Write(response)
+	})
+
+	clientConfig := newTestClientConfig(first.Address())
+	clientConfig.Backends = []config.Backend{
+		{Network: "tcp", Address: first.Address()},
+		{Network: "tcp", Address: second.Address()},
+	}
+	firstClient := NewClient(context.Background(), clientConfig.GetBackend(0), zerolog.Nop(), nil)
+	require.NotNil(t, firstClient)
+	proxy := newTestProxyWithClients(t, clientConfig, firstClient)
+	proxy.HandoffSetupRequests = 1
+
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	client, ok := proxy.busyConnections.Get(conn).(IClient)
+	require.True(t, ok)
+
+	// The issued cancel key of the session follows it to the new backend.
+	keyData, err := (&pgproto3.BackendKeyData{ProcessID: 1234, SecretKey: 5678}).Encode(nil)
+	require.NoError(t, err)
+	issued, ok := proxy.cancelKeys.Translate(keyData, "tcp", first.Address())
+	require.True(t, ok)
+	conn.SetCancelKey(&issued)
+
+	startup := CreatePgStartupPacket()
+	conn.RecordSessionSetup(startup, proxy.HandoffSetupRequests)
+	conn.RecordSessionSetup(CreatePgTerminatePacket(), proxy.HandoffSetupRequests)
+	assert.Len(t, conn.SessionSetup(), 1)
+
+	// The handoff is disabled by default.
+	require.True(t, proxy.DrainBackend("tcp://"+first.Address(), true))
+	assert.Equal(t, client, proxy.handoff(conn, client))
+
+	// The sessions in a transaction stay on their backend.
+	proxy.SessionHandoff = true
+	conn.SetTxStatus(TxInTransaction)
+	assert.Equal(t, client, proxy.handoff(conn, client))
+	conn.SetTxStatus(TxIdle)
+
+	succeeded := testutil.ToFloat64(metrics.SessionHandoffs.WithLabelValues("succeeded"))
+	handedOff := proxy.handoff(conn, client)
+	require.NotEqual(t, client, handedOff)
+	assert.Equal(t, second.Address(), handedOff.GetAddress())
+	assert.Equal(t, handedOff, proxy.busyConnections.Get(conn))
+	assert.False(t, client.IsConnected())
+	assert.Equal(t, startup, <-replayed)
+	assert.Equal(t, succeeded+1, testutil.ToFloat64(metrics.SessionHandoffs.WithLabelValues("succeeded")))
+
+	target, ok := proxy.cancelKeys.lookup(issued)
+	require.True(t, ok)
+	assert.Equal(t, BackendKey{ProcessID: 4321, SecretKey: 8765}, target.key)
+	assert.Equal(t, second.Address(), target.address)
+
+	require.Nil(t, proxy.Disconnect(conn))
+}
+
+// TestSessionHandoffAuthentication tests that only the sessions authenticated with
+// trust or a cleartext password are handed off.
+func TestSessionHandoffAuthentication(t *testing.T) {
+	startup := CreatePgStartupPacket()
+	cleartext, err := (&pgproto3.PasswordMessage{Password: "secret"}).Encode(nil)
+	require.NoError(t, err)
+	md5Password, err := (&pgproto3.PasswordMessage{
+		Password: MD5Password("postgres", "secret", [4]byte{1, 2, 3, 4}),
+	}).Encode(nil)
+	require.NoError(t, err)
+	scram, err := (&pgproto3.SASLInitialResponse{
+		AuthMechanism: "SCRAM-SHA-256", Data: []byte("n,,n=,r=nonce"),
+	}).Encode(nil)
+	require.NoError(t, err)
+
+	assert.True(t, isReplayableSetup([][]byte{startup}))
+	assert.True(t, isReplayableSetup([][]byte{startup, cleartext}))
+	assert.False(t, isReplayableSetup([][]byte{startup, md5Password}))
+	assert.False(t, isReplayableSetup([][]byte{startup, scram}))
+
+	authOk, err := (&pgproto3.AuthenticationOk{}).Encode(nil)
+	require.NoError(t, err)
+	code, found := authenticationRequest(authOk)
+	assert.True(t, found)
+	assert.Equal(t, uint32(pgAuthOk), code)
+	sasl, err := (&pgproto3.AuthenticationSASL{AuthMechanisms: []string{"SCRAM-SHA-256"}}).Encode(nil)
+	require.NoError(t, err)
+	code, found = authenticationRequest(sasl)
+	assert.True(t, found)
+	assert.Equal(t, uint32(10), code)
+	_, found = authenticationRequest(CreatePgTerminatePacket())
+	assert.False(t, found)
+
+	// The MD5 sessions stay on their drained backend.
+	first := newFakeUpstream(t, func(net.Conn) {})
+	second := newFakeUpstream(t, func(net.Conn) {})
+	clientConfig := newTestClientConfig(first.Address())
+	clientConfig.Backends = []config.Backend{
+		{Network: "tcp", Address: first.Address()},
+		{Network: "tcp", Address: second.Address()},
+	}
+	firstClient := NewClient(context.Background(), clientConfig.GetBackend(0), zerolog.Nop(), nil)
+	require.NotNil(t, firstClient)
+	proxy := newTestProxyWithClients(t, clientConfig, firstClient)
+	proxy.SessionHandoff = true
+	proxy.HandoffSetupRequests = 2
+
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	client, ok := proxy.busyConnections.Get(conn).(IClient)
+	require.True(t, ok)
+	conn.RecordSessionSetup(startup, proxy.HandoffSetupRequests)
+	conn.RecordSessionSetup(md5Password, proxy.HandoffSetupRequests)
+
+	require.True(t, proxy.DrainBackend("tcp://"+first.Address(), true))
+	assert.Equal(t, client, proxy.handoff(conn, client))
+	assert.True(t, client.IsConnected())
+	assert.Zero(t, second.Accepted())
+
+	require.Nil(t, proxy.Disconnect(conn))
+}
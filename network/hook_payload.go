@@ -0,0 +1,88 @@
+package network
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/metrics"
+)
+
+// These are the payload fields of the traffic hooks, which are truncated to the max
+// hook payload size.
+var hookPayloadFields = []string{"request", "response", QueryField}
+
+// truncateHookPayloads truncates the payloads of the traffic hook data to the max hook
+// payload size, so that the data of the large requests and responses still fits in the
+// gRPC messages to the plugins. The truncated payloads are flagged with the
+// "<field>Truncated" field and their original size in the "<field>Size" field.
+func (pr *Proxy) truncateHookPayloads(hook v1.HookName, data map[string]interface{}) map[string]interface{} {
+	if pr.MaxHookPayloadSize <= 0 {
+		return data
+	}
+
+	for _, field := range hookPayloadFields {
+		size := 0
+		switch payload := data[field].(type) {
+		case []byte:
+			if size = len(payload); size > pr.MaxHookPayloadSize {
+				data[field] = payload[:pr.MaxHookPayloadSize]
+			}
+		case string:
+			if size = len(payload); size > pr.MaxHookPayloadSize {
+				// Cut at a rune boundary, since the strings sent to the plugins must be valid UTF-8.
+				end := pr.MaxHookPayloadSize
+				for end > 0 && !utf8.RuneStart(payload[end]) {
+					end--
+				}
+				data[field] = payload[:end]
+			}
+		}
+		if size <= pr.MaxHookPayloadSize {
+			continue
+		}
+
+		data[field+"Truncated"] = true
+		data[field+"Size"] = size
+		metrics.TruncatedHookPayloads.WithLabelValues(hook.String(), field).Inc()
+		pr.Logger.Debug().Fields(map[string]interface{}{
+			"hook":  hook.String(),
+			"field": field,
+			"size":  size,
+			"limit": pr.MaxHookPayloadSize,
+		}).Msg("Truncated the payload of the hook to the max hook payload size")
+	}
+	return data
+}
+
+// isTruncated returns true if the payload field of the hook result is still truncated,
+// in which case it's ignored instead of replacing the original payload.
+func isTruncated(result map[string]interface{}, field string) bool {
+	truncated, ok := result[field+"Truncated"].(bool)
+	return ok && truncated
+}
+
+// These are the results of the payloads returned by the traffic hooks.
+const (
+	HookPayloadModified    = "modified"
+	HookPayloadPassthrough = "passthrough"
+)
+
+// countHookModification counts the payload of the traffic hook as modified by the
+// plugins, if the payload they returned differs from the original one, or else as
+// passed through unchanged, e.g. if the plugins only observe the traffic.
+func (pr *Proxy) countHookModification(hook v1.HookName, field string, original, modified []byte) {
+	if modified == nil || bytes.Equal(modified, original) {
+		metrics.HookPayloads.WithLabelValues(hook.String(), field, HookPayloadPassthrough).Inc()
+		return
+	}
+
+	metrics.HookPayloads.WithLabelValues(hook.String(), field, HookPayloadModified).Inc()
+	pr.Logger.Debug().Fields(map[string]interface{}{
+		"hook":         hook.String(),
+		"field":        field,
+		"originalSize": len(original),
+		"modifiedSize": len(modified),
+		"delta":        len(modified) - len(original),
+	}).Msg("The plugins modified the payload of the hook")
+}
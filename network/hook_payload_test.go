@@ -0,0 +1,72 @@
+package network
+
+import (
+	"testing"
+
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTruncateHookPayloads tests truncating the payloads of the traffic hooks to
+// the max hook payload size, and ignoring the truncated payloads in the results.
+func TestTruncateHookPayloads(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+
+	request := CreatePostgreSQLPacket('Q', []byte("SELECT 'ééé'\x00"))
+	data := func() map[string]interface{} {
+		return trafficData(newMockConn(), memClient, requestFields(request), nil)
+	}
+
+	// The payloads aren't truncated without a limit.
+	assert.Equal(t, data(), proxy.truncateHookPayloads(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, data()))
+
+	proxy.MaxHookPayloadSize = 11
+	truncated := testutil.ToFloat64(metrics.TruncatedHookPayloads.WithLabelValues(
+		v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT.String(), "request"))
+	result := proxy.truncateHookPayloads(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, data())
+	assert.Equal(t, request[:11], result["request"])
+	assert.Equal(t, true, result["requestTruncated"])
+	assert.Equal(t, len(request), result["requestSize"])
+	assert.Equal(t, truncated+1, testutil.ToFloat64(metrics.TruncatedHookPayloads.WithLabelValues(
+		v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT.String(), "request")))
+
+	// The query is cut at a rune boundary, so that it's still valid UTF-8.
+	assert.Equal(t, "SELECT 'é", result[QueryField])
+	assert.Equal(t, true, result["queryTruncated"])
+	_, err := v1.NewStruct(result)
+	require.NoError(t, err)
+
+	// The truncated request returned as is isn't used in place of the original one.
+	assert.Nil(t, proxy.getPluginModifiedRequest(result, request))
+	modified := CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00"))
+	assert.Equal(t, modified, proxy.getPluginModifiedRequest(
+		map[string]interface{}{"request": modified, "requestTruncated": false}, request))
+}
+
+// TestCountHookModification tests counting the payloads modified by the traffic hooks
+// apart from the ones passed through unchanged.
+func TestCountHookModification(t *testing.T) {
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"))
+	hook := v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT
+	request := CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00"))
+
+	modified := testutil.ToFloat64(metrics.HookPayloads.WithLabelValues(
+		hook.String(), "request", HookPayloadModified))
+	passthrough := testutil.ToFloat64(metrics.HookPayloads.WithLabelValues(
+		hook.String(), "request", HookPayloadPassthrough))
+
+	// The payloads echoed by the plugins or not returned at all are passed through.
+	proxy.countHookModification(hook, "request", request, request)
+	proxy.countHookModification(hook, "request", request, nil)
+	proxy.countHookModification(hook, "request", request, CreatePostgreSQLPacket('Q', []byte("SELECT 2\x00")))
+
+	assert.Equal(t, modified+1, testutil.ToFloat64(metrics.HookPayloads.WithLabelValues(
+		hook.String(), "request", HookPayloadModified)))
+	assert.Equal(t, passthrough+2, testutil.ToFloat64(metrics.HookPayloads.WithLabelValues(
+		hook.String(), "request", HookPayloadPassthrough)))
+}
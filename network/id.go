@@ -0,0 +1,90 @@
+package network
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// IIDGenerator generates the unique IDs of the server connections.
+type IIDGenerator interface {
+	// GenerateID returns the ID of the connection with the local network and address.
+	GenerateID(network, address string) string
+}
+
+// HashIDGenerator generates the SHA-256 hash of the local address of the connection.
+type HashIDGenerator struct {
+	Seed   int
+	Logger zerolog.Logger
+}
+
+// SequentialIDGenerator generates the IDs from a sequence shared by all the connections.
+type SequentialIDGenerator struct{}
+
+// UUIDGenerator generates random UUIDs.
+type UUIDGenerator struct{}
+
+// ReadableIDGenerator generates the IDs from the name of the client config and an
+// index, e.g. client-primary-03, which are easier to follow in the logs than hashes.
+type ReadableIDGenerator struct {
+	Name  string
+	index atomic.Uint64
+}
+
+var (
+	_ IIDGenerator = (*HashIDGenerator)(nil)
+	_ IIDGenerator = (*SequentialIDGenerator)(nil)
+	_ IIDGenerator = (*UUIDGenerator)(nil)
+	_ IIDGenerator = (*ReadableIDGenerator)(nil)
+
+	// idSequence is shared by the sequential IDs of all the clients, so that the
+	// IDs are unique across the pools.
+	idSequence atomic.Uint64
+	// readableIDGenerators are the readable ID generators by the name of the client
+	// config, so that the clients of a pool share the index.
+	readableIDGenerators sync.Map
+)
+
+// GenerateID returns the hash of the local network and address of the connection.
+func (g *HashIDGenerator) GenerateID(network, address string) string {
+	return GetID(network, address, g.Seed, g.Logger)
+}
+
+// GenerateID returns the next number of the sequence.
+func (g *SequentialIDGenerator) GenerateID(_, _ string) string {
+	return fmt.Sprint(idSequence.Add(1))
+}
+
+// GenerateID returns a random UUID.
+func (g *UUIDGenerator) GenerateID(_, _ string) string {
+	return uuid.NewString()
+}
+
+// GenerateID returns the name of the client config with the next index.
+func (g *ReadableIDGenerator) GenerateID(_, _ string) string {
+	return fmt.Sprintf("client-%s-%02d", g.Name, g.index.Add(1))
+}
+
+// NewIDGenerator returns the ID generator of the strategy in the client config.
+// The IDs are unique with every strategy, as long as the names of the client
+// configs are, which are the keys of the clients in the global config.
+func NewIDGenerator(clientConfig *config.Client, logger zerolog.Logger) IIDGenerator {
+	switch clientConfig.IDStrategy {
+	case config.SequentialIDs:
+		return &SequentialIDGenerator{}
+	case config.UUIDs:
+		return &UUIDGenerator{}
+	case config.ReadableIDs:
+		name := config.If(clientConfig.Name != "", clientConfig.Name, config.Default)
+		generator, _ := readableIDGenerators.LoadOrStore(name, &ReadableIDGenerator{Name: name})
+		if readable, ok := generator.(*ReadableIDGenerator); ok {
+			return readable
+		}
+	}
+
+	return &HashIDGenerator{Seed: config.DefaultSeed, Logger: logger}
+}
@@ -0,0 +1,73 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewIDGenerator tests that the ID generators of all the strategies generate unique IDs.
+func TestNewIDGenerator(t *testing.T) {
+	logger := zerolog.Nop()
+
+	for _, strategy := range []string{
+		"", config.HashIDs, config.SequentialIDs, config.UUIDs, config.ReadableIDs,
+	} {
+		t.Run(strategy, func(t *testing.T) {
+			clientConfig := &config.Client{IDStrategy: strategy, Name: "unique-" + strategy}
+			ids := map[string]bool{}
+			for port := range 100 {
+				// Each client has its own generator, as in NewClient.
+				id := NewIDGenerator(clientConfig, logger).GenerateID(
+					"tcp", fmt.Sprintf("127.0.0.1:%d", 10000+port))
+				assert.NotEmpty(t, id)
+				assert.NotContains(t, ids, id)
+				ids[id] = true
+			}
+		})
+	}
+
+	t.Run("hash", func(t *testing.T) {
+		generator := NewIDGenerator(&config.Client{}, logger)
+		assert.Equal(t,
+			GetID("tcp", "localhost:5432", config.DefaultSeed, logger),
+			generator.GenerateID("tcp", "localhost:5432"))
+	})
+
+	t.Run("readable", func(t *testing.T) {
+		clientConfig := &config.Client{IDStrategy: config.ReadableIDs, Name: "primary"}
+		assert.Equal(t, "client-primary-01", NewIDGenerator(clientConfig, logger).GenerateID("", ""))
+		// The clients of the same config share the index.
+		assert.Equal(t, "client-primary-02", NewIDGenerator(clientConfig, logger).GenerateID("", ""))
+	})
+}
+
+// TestConnectIDStrategies tests assigning the server connections with the IDs of all
+// the strategies to the clients, including the short sequential IDs.
+func TestConnectIDStrategies(t *testing.T) {
+	upstream := newFakeUpstream(t, func(net.Conn) {})
+
+	for _, strategy := range []string{
+		config.HashIDs, config.SequentialIDs, config.UUIDs, config.ReadableIDs,
+	} {
+		t.Run(strategy, func(t *testing.T) {
+			clientConfig := newTestClientConfig(upstream.Address())
+			clientConfig.IDStrategy = strategy
+			clientConfig.Name = "connect-" + strategy
+			client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+			require.NotNil(t, client)
+			proxy := newTestProxyWithClients(t, clientConfig, client)
+
+			conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+			require.Nil(t, proxy.Connect(conn))
+			assert.Equal(t, client, proxy.busyConnections.Get(conn))
+			require.Nil(t, proxy.Disconnect(conn))
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package network
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd-plugin-sdk/databases/postgres"
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"go.opentelemetry.io/otel"
+)
+
+// pgInsufficientResources is the SQLSTATE of the requests rejected for the lack of
+// resources: https://www.postgresql.org/docs/current/errcodes-appendix.html
+const pgInsufficientResources = "53000"
+
+// errInFlightTimeout is returned for the requests of other protocols than PostgreSQL
+// that timed out waiting for their backend, since they can't be replied to with an error.
+var errInFlightTimeout = errors.New("timed out waiting for the backend to be below its max in-flight requests")
+
+// inFlightSlot is the slot of a backend held by a connection for its request in flight.
+type inFlightSlot struct {
+	backend string
+	slots   chan struct{}
+	// untilReady holds the slot until the server is ready for the next query, instead
+	// of releasing it on the first response.
+	untilReady bool
+}
+
+// InFlightLimiter caps the requests in flight to each backend at its max in-flight
+// requests, over all the server connections to it. A PostgreSQL request is in flight
+// from when it's sent to the server until the server is ready for the next query, so
+// that a connection holds a single slot at a time, e.g. for all the messages of an
+// extended query up to its Sync. The requests of other protocols, which have no
+// ReadyForQuery message, are in flight until their first response. The requests above
+// the cap are queued until a slot is free,
+// instead of being rerouted, since the session of a connection is bound to its
+// server connection.
+type InFlightLimiter struct {
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+	// held are the slots held by the connections for their requests in flight.
+	held sync.Map
+}
+
+// NewInFlightLimiter creates a new in-flight limiter.
+func NewInFlightLimiter() *InFlightLimiter {
+	return &InFlightLimiter{slots: map[string]chan struct{}{}}
+}
+
+// slotsOf returns the slots of the backend, which are replaced if its max in-flight
+// requests changed, e.g. if it's added again at runtime. The requests holding the
+// old slots release them to the old ones.
+func (l *InFlightLimiter) slotsOf(backend string, limit int) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slots, ok := l.slots[backend]
+	if !ok || cap(slots) != limit {
+		slots = make(chan struct{}, limit)
+		l.slots[backend] = slots
+	}
+	return slots
+}
+
+// Acquire takes a slot of the backend for the request of the connection, if the
+// backend has a max in-flight requests. If the backend is at its max, the request
+// waits for a slot up to the timeout, or until there's one if it's 0, and false is
+// returned if it times out. The connection that already holds a slot, e.g. for the
+// previous messages of an extended query, doesn't take another one. The slot is held
+// until the server is ready for the next query if untilReady is true, or else until
+// the first response.
+func (l *InFlightLimiter) Acquire(
+	conn *ConnWrapper, backend string, limit int, timeout time.Duration, untilReady bool,
+) bool {
+	if l == nil || limit <= 0 {
+		return true
+	}
+	if _, ok := l.held.Load(conn); ok {
+		return true
+	}
+
+	slots := l.slotsOf(backend, limit)
+	select {
+	case slots <- struct{}{}:
+	default:
+		metrics.BackendInFlightQueued.WithLabelValues(backend).Inc()
+		var expired <-chan time.Time
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-expired:
+			metrics.BackendInFlightTimeouts.WithLabelValues(backend).Inc()
+			return false
+		}
+	}
+
+	l.held.Store(conn, inFlightSlot{backend: backend, slots: slots, untilReady: untilReady})
+	metrics.BackendInFlightRequests.WithLabelValues(backend).Inc()
+	return true
+}
+
+// Release frees the slot held by the connection, if any, once the server is ready for
+// the next query, the request failed or the connection is closed.
+func (l *InFlightLimiter) Release(conn *ConnWrapper) {
+	if l == nil {
+		return
+	}
+
+	value, ok := l.held.LoadAndDelete(conn)
+	if !ok {
+		return
+	}
+	if slot, ok := value.(inFlightSlot); ok {
+		<-slot.slots
+		metrics.BackendInFlightRequests.WithLabelValues(slot.backend).Dec()
+	}
+}
+
+// ReleaseOnResponse frees the slot held by the connection on a response of the server,
+// unless it's held until the server is ready for the next query.
+func (l *InFlightLimiter) ReleaseOnResponse(conn *ConnWrapper) {
+	if l == nil {
+		return
+	}
+	if value, ok := l.held.Load(conn); ok {
+		if slot, ok := value.(inFlightSlot); ok && !slot.untilReady {
+			l.Release(conn)
+		}
+	}
+}
+
+// acquireInFlight takes a slot of the backend of the server connection for the
+// request of the connection, and returns false if the request timed out waiting. The
+// slot of a PostgreSQL request is held until the server is ready for the next query.
+func (pr *Proxy) acquireInFlight(conn *ConnWrapper, client IClient, request []byte) bool {
+	limit := 0
+	for _, backend := range pr.backendList() {
+		if backend.Network == client.GetNetwork() && backend.Address == client.GetAddress() {
+			limit = backend.MaxInFlight
+			break
+		}
+	}
+
+	return pr.inFlight.Acquire(
+		conn, client.GetNetwork()+"://"+client.GetAddress(), limit, pr.InFlightTimeout,
+		IsPostgresMessages(request))
+}
+
+// rejectInFlight replies to the request that timed out waiting for its backend with
+// an error, followed by a ReadyForQuery message if the request ends a query cycle.
+// The transaction of the client, if any, isn't aborted, since the request isn't sent
+// to the server. The connections of other protocols than PostgreSQL are closed
+// instead, since they can't be replied to with an error.
+func (pr *Proxy) rejectInFlight(conn *ConnWrapper, request []byte) *gerr.GatewayDError {
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "rejectInFlight")
+	defer span.End()
+
+	pr.Logger.Warn().Fields(
+		map[string]interface{}{
+			"timeout": pr.InFlightTimeout.String(),
+			"remote":  RemoteAddr(conn.Conn()),
+		},
+	).Msg("Rejected a request, because its backend is at its max in-flight requests")
+	span.AddEvent("Rejected a request, because its backend is at its max in-flight requests")
+
+	if !IsPostgresMessages(request) {
+		span.RecordError(errInFlightTimeout)
+		return gerr.ErrNoHealthyUpstream.Wrap(errInFlightTimeout)
+	}
+
+	response := postgres.ErrorResponse(
+		"too many requests in flight to the database server", "ERROR", pgInsufficientResources,
+		"The request timed out waiting for the other requests to the server to complete")
+	if endsQueryCycle(request) {
+		response = append(response, pgReadyForQuery, 0, 0, 0, 5, byte(conn.TxStatus()))
+	}
+
+	return pr.sendTrafficToClient(conn, response, len(response))
+}
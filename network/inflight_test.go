@@ -0,0 +1,140 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestInFlightLimiter tests queueing the requests above the max in-flight requests
+// of a backend until a slot is free, and timing them out.
+func TestInFlightLimiter(t *testing.T) {
+	limiter := NewInFlightLimiter()
+	first := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	second := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	queued := testutil.ToFloat64(metrics.BackendInFlightQueued.WithLabelValues("tcp://replica"))
+
+	// The backends without a max aren't limited.
+	assert.True(t, limiter.Acquire(first, "tcp://primary", 0, 0, true))
+
+	// The connection holding a slot doesn't take another one.
+	assert.True(t, limiter.Acquire(first, "tcp://replica", 1, 0, true))
+	assert.True(t, limiter.Acquire(first, "tcp://replica", 1, 0, true))
+	assert.Equal(t, float64(1), testutil.ToFloat64(
+		metrics.BackendInFlightRequests.WithLabelValues("tcp://replica")))
+
+	// The request above the max times out waiting.
+	assert.False(t, limiter.Acquire(second, "tcp://replica", 1, 10*time.Millisecond, true))
+	assert.Equal(t, queued+1, testutil.ToFloat64(
+		metrics.BackendInFlightQueued.WithLabelValues("tcp://replica")))
+
+	// The queued request gets the slot once it's released.
+	acquired := make(chan bool)
+	go func() { acquired <- limiter.Acquire(second, "tcp://replica", 1, 0, true) }()
+	time.Sleep(10 * time.Millisecond)
+	limiter.Release(first)
+	assert.True(t, <-acquired)
+	limiter.Release(second)
+	limiter.Release(second)
+	assert.Equal(t, float64(0), testutil.ToFloat64(
+		metrics.BackendInFlightRequests.WithLabelValues("tcp://replica")))
+}
+
+// TestProxyMaxInFlight tests rejecting the request that timed out waiting for its
+// backend, and holding the slot of the backend until the server is ready for the
+// next query.
+func TestProxyMaxInFlight(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+	proxy.backends = []config.Backend{{Network: "memory", Address: "memory", MaxInFlight: 1}}
+	proxy.InFlightTimeout = 10 * time.Millisecond
+
+	query := CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00"))
+	client := newMockConn(query, query)
+	conn := NewConnWrapper(ConnWrapper{NetConn: client})
+	require.Nil(t, proxy.Connect(conn))
+
+	// The request of another connection takes the only slot of the backend.
+	other := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.True(t, proxy.inFlight.Acquire(other, "memory://memory", 1, 0, true))
+
+	stack := NewStack()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	assert.Nil(t, stack.GetLastRequest())
+	frontend := pgproto3.NewFrontend(newMockConn(client.Written()), nil)
+	message, err := frontend.Receive()
+	require.NoError(t, err)
+	errorResponse, ok := message.(*pgproto3.ErrorResponse)
+	require.True(t, ok)
+	assert.Equal(t, "53000", errorResponse.Code)
+	message, err = frontend.Receive()
+	require.NoError(t, err)
+	assert.Equal(t, &pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)}, message)
+
+	// The request is sent once the slot is released, and holds it until the response.
+	proxy.inFlight.Release(other)
+	received := make(chan []byte, 1)
+	go func() {
+		buffer := make([]byte, config.DefaultChunkSize)
+		n, _ := server.Read(buffer)
+		received <- buffer[:n]
+	}()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	assert.Equal(t, query, <-received)
+	assert.False(t, proxy.inFlight.Acquire(other, "memory://memory", 1, time.Millisecond, true))
+
+	go func() {
+		_, _ = server.Write([]byte{pgReadyForQuery, 0, 0, 0, 5, byte(TxIdle)})
+	}()
+	require.Nil(t, proxy.PassThroughToClient(conn, stack))
+	assert.True(t, proxy.inFlight.Acquire(other, "memory://memory", 1, 0, true))
+	proxy.inFlight.Release(other)
+}
+
+// TestProxyMaxInFlightRaw tests releasing the slot of the backend on the first response
+// to the requests of other protocols than PostgreSQL, which have no ReadyForQuery, and
+// closing their connections instead of replying with a PostgreSQL error.
+func TestProxyMaxInFlightRaw(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+	proxy.backends = []config.Backend{{Network: "memory", Address: "memory", MaxInFlight: 1}}
+	proxy.InFlightTimeout = 10 * time.Millisecond
+
+	request := []byte("PING\r\n")
+	client := newMockConn(request, request)
+	conn := NewConnWrapper(ConnWrapper{NetConn: client})
+	require.Nil(t, proxy.Connect(conn))
+
+	other := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.True(t, proxy.inFlight.Acquire(other, "memory://memory", 1, 0, true))
+
+	stack := NewStack()
+	require.NotNil(t, proxy.PassThroughToServer(conn, stack))
+	assert.Empty(t, client.Written())
+
+	proxy.inFlight.Release(other)
+	received := make(chan []byte, 1)
+	go func() {
+		buffer := make([]byte, config.DefaultChunkSize)
+		n, _ := server.Read(buffer)
+		received <- buffer[:n]
+	}()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	assert.Equal(t, request, <-received)
+	assert.False(t, proxy.inFlight.Acquire(other, "memory://memory", 1, time.Millisecond, true))
+
+	go func() {
+		_, _ = server.Write([]byte("PONG\r\n"))
+	}()
+	require.Nil(t, proxy.PassThroughToClient(conn, stack))
+	assert.True(t, proxy.inFlight.Acquire(other, "memory://memory", 1, time.Millisecond, true))
+	proxy.inFlight.Release(other)
+}
@@ -0,0 +1,74 @@
+package network
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"go.opentelemetry.io/otel"
+)
+
+// keepAliveParameter is the name of the parameter of the PostgreSQL keepalives,
+// which the drivers keep along with the parameters reported by the server.
+const keepAliveParameter = "gatewayd.keepalive"
+
+// KeepAlivePayload returns the payload of the keepalives of the given kind, which
+// is nil if the keepalives are disabled. The payload of the raw keepalives is
+// hex-encoded.
+func KeepAlivePayload(kind, payload string) ([]byte, error) {
+	switch kind {
+	case config.PostgresKeepAlive:
+		// A ParameterStatus message, which the server may send at any time.
+		body := keepAliveParameter + "\x00" + "\x00"
+		message := []byte{pgParameterStatus}
+		message = binary.BigEndian.AppendUint32(message, uint32(len(body)+4)) //nolint:gosec
+		return append(message, body...), nil
+	case config.RawKeepAlive:
+		raw, err := hex.DecodeString(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keepalive payload: %w", err)
+		}
+		return raw, nil
+	default:
+		return nil, nil
+	}
+}
+
+// SendKeepAlives sends the keepalive payload to the client connections with no traffic
+// in either direction for longer than the idle period, so that the firewalls and the NATs
+// in between don't drop them. The keepalives don't count as activity, so they don't keep
+// the idle connections from being closed. It returns the number of sent keepalives.
+func (pr *Proxy) SendKeepAlives(payload []byte, idle time.Duration) int {
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "SendKeepAlives")
+	defer span.End()
+
+	if len(payload) == 0 {
+		return 0
+	}
+
+	sent := 0
+	pr.busyConnections.ForEach(func(key, _ interface{}) bool {
+		conn, ok := key.(*ConnWrapper)
+		if !ok || time.Since(conn.LastActivity()) < idle {
+			return true
+		}
+
+		if _, err := conn.Write(payload); err != nil {
+			pr.Logger.Debug().Err(err).Str("remote", RemoteAddr(conn.Conn())).Msg(
+				"Failed to send the keepalive to the client")
+			return true
+		}
+		sent++
+		return true
+	})
+
+	if sent > 0 {
+		metrics.KeepAlivesSent.Add(float64(sent))
+		pr.Logger.Trace().Int("count", sent).Msg("Sent keepalives to the idle client connections")
+	}
+
+	return sent
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestKeepAlivePayload tests the payloads of the keepalives.
+func TestKeepAlivePayload(t *testing.T) {
+	payload, err := KeepAlivePayload(config.PostgresKeepAlive, "")
+	require.NoError(t, err)
+	expected, err := (&pgproto3.ParameterStatus{Name: keepAliveParameter}).Encode(nil)
+	require.NoError(t, err)
+	assert.Equal(t, expected, payload)
+
+	payload, err = KeepAlivePayload(config.RawKeepAlive, "00ff")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0xff}, payload)
+
+	_, err = KeepAlivePayload(config.RawKeepAlive, "not hex")
+	require.Error(t, err)
+
+	payload, err = KeepAlivePayload(config.NoKeepAlive, "00")
+	require.NoError(t, err)
+	assert.Nil(t, payload)
+}
+
+// TestSendKeepAlives tests that the keepalives are only sent to the client
+// connections without traffic for longer than the idle period.
+func TestSendKeepAlives(t *testing.T) {
+	proxy := newProxyWithBackend(t, []byte("response"), false)
+
+	client := newMockConn()
+	conn := NewConnWrapper(ConnWrapper{NetConn: client})
+	require.Nil(t, proxy.Connect(conn))
+
+	// The connection was just opened, so it's not idle.
+	assert.Equal(t, 0, proxy.SendKeepAlives([]byte{0}, time.Minute))
+	assert.Empty(t, client.Written())
+
+	lastActivity := time.Now().Add(-2 * time.Minute)
+	conn.lastActivity.Store(lastActivity.UnixNano())
+	assert.Equal(t, 1, proxy.SendKeepAlives([]byte{0}, time.Minute))
+	assert.Equal(t, []byte{0}, client.Written())
+	// The keepalives don't count as activity.
+	assert.Equal(t, lastActivity.UnixNano(), conn.LastActivity().UnixNano())
+}
@@ -0,0 +1,93 @@
+package network
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cast"
+	"golang.org/x/exp/maps"
+)
+
+// LabelsField is the field of the OnTrafficFromClient hook result, in which the
+// plugins return the labels of the connection, e.g. the tenant ID or the app name.
+const LabelsField = "labels"
+
+// labelNamePattern is the pattern of the label names, as in Prometheus.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// GetLabels returns the labels in the result of a hook. The labels with invalid
+// names are skipped, and the values are truncated to the maximum length.
+func GetLabels(result map[string]interface{}) map[string]string {
+	values, ok := result[LabelsField].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	labels := make(map[string]string, len(values))
+	for name, value := range values {
+		if !labelNamePattern.MatchString(name) {
+			continue
+		}
+
+		label, err := cast.ToStringE(value)
+		if err != nil {
+			continue
+		}
+		if len(label) > config.DefaultMaxLabelLength {
+			label = label[:config.DefaultMaxLabelLength]
+		}
+		labels[name] = label
+	}
+
+	return labels
+}
+
+// MergeLabels adds the new labels to the current labels of the connection and
+// returns the merged labels. The current labels can be updated, but new labels
+// are only added up to the maximum number of labels, in the order of their names.
+func MergeLabels(current, labels map[string]string) map[string]string {
+	merged := maps.Clone(current)
+	if merged == nil {
+		merged = make(map[string]string, len(labels))
+	}
+
+	names := maps.Keys(labels)
+	slices.Sort(names)
+	for _, name := range names {
+		if _, exists := merged[name]; !exists && len(merged) >= config.DefaultMaxLabels {
+			continue
+		}
+		merged[name] = labels[name]
+	}
+
+	return merged
+}
+
+// withLabels returns the logger with the labels of the connection, if any.
+func withLabels(logger zerolog.Logger, conn *ConnWrapper) zerolog.Logger {
+	labels := conn.Labels()
+	if len(labels) == 0 {
+		return logger
+	}
+
+	fields := make(map[string]interface{}, len(labels))
+	for name, value := range labels {
+		fields[name] = value
+	}
+	return logger.With().Fields(map[string]interface{}{LabelsField: fields}).Logger()
+}
+
+// countLabeledRequest counts the request in the metrics by the labels of the
+// connection, with the label values bounded by the proxy's limiter.
+func (pr *Proxy) countLabeledRequest(conn *ConnWrapper) {
+	if pr.labelValues == nil {
+		return
+	}
+
+	for name, value := range conn.Labels() {
+		metrics.LabeledRequests.WithLabelValues(name, pr.labelValues.Value(name, value)).Inc()
+	}
+}
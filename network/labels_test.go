@@ -0,0 +1,59 @@
+package network
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetLabels tests getting the labels from the result of a hook.
+func TestGetLabels(t *testing.T) {
+	assert.Nil(t, GetLabels(map[string]interface{}{}))
+	assert.Nil(t, GetLabels(map[string]interface{}{LabelsField: "tenant"}))
+
+	labels := GetLabels(map[string]interface{}{
+		LabelsField: map[string]interface{}{
+			"tenant":   "acme",
+			"app_id":   42,
+			"bad-name": "skipped",
+			"long":     strings.Repeat("x", config.DefaultMaxLabelLength+1),
+		},
+	})
+	assert.Equal(t, map[string]string{
+		"tenant": "acme",
+		"app_id": "42",
+		"long":   strings.Repeat("x", config.DefaultMaxLabelLength),
+	}, labels)
+}
+
+// TestMergeLabels tests that the labels of a connection are bounded.
+func TestMergeLabels(t *testing.T) {
+	labels := MergeLabels(nil, map[string]string{"tenant": "acme"})
+	assert.Equal(t, map[string]string{"tenant": "acme"}, labels)
+
+	labels = MergeLabels(labels, map[string]string{"tenant": "globex", "app": "billing"})
+	assert.Equal(t, map[string]string{"tenant": "globex", "app": "billing"}, labels)
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		labels = MergeLabels(labels, map[string]string{name: name})
+	}
+	assert.Len(t, labels, config.DefaultMaxLabels)
+	assert.Equal(t, "globex", labels["tenant"])
+	assert.Equal(t, "a", labels["a"])
+	assert.NotContains(t, labels, "c")
+
+	// The existing labels can still be updated.
+	labels = MergeLabels(labels, map[string]string{"app": "reports"})
+	assert.Equal(t, "reports", labels["app"])
+}
+
+// TestConnWrapperLabels tests attaching the labels to a connection.
+func TestConnWrapperLabels(t *testing.T) {
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	assert.Nil(t, conn.Labels())
+
+	conn.SetLabels(map[string]string{"tenant": "acme"})
+	assert.Equal(t, map[string]string{"tenant": "acme"}, conn.Labels())
+}
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGoroutineLeaks tests that the goroutines of the connections, e.g. the readers
+// of the clients, the reconnections of the server connections and the health checks
+// of the proxy, are all gone after the connections are closed and the server is
+// shut down, by running many connections through the proxy in memory.
+func TestGoroutineLeaks(t *testing.T) {
+	const (
+		connections = 48
+		workers     = 4
+	)
+
+	baseline := runtime.NumGoroutine()
+
+	ready, err := (&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)}).Encode(nil)
+	require.NoError(t, err)
+	query, err := (&pgproto3.Query{String: "SELECT 1"}).Encode(nil)
+	require.NoError(t, err)
+
+	upstream := newMemoryDatabase(t, "leak-database", ready)
+
+	clientConfig := newTestClientConfig("leak-database")
+	clientConfig.Network = MemoryNetwork
+	clients := []IClient{}
+	// The server connections of the closed connections are recycled in the background.
+	for range 2 * workers {
+		client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+		require.NotNil(t, client)
+		clients = append(clients, client)
+	}
+	proxy := newTestProxyWithClients(t, clientConfig, clients...)
+
+	server := NewServer(
+		context.Background(),
+		Server{
+			Network:        MemoryNetwork,
+			Address:        "leak-gatewayd",
+			Proxy:          proxy,
+			Logger:         zerolog.Nop(),
+			PluginRegistry: proxy.PluginRegistry,
+			PluginTimeout:  config.DefaultPluginTimeout,
+		},
+	)
+	require.NotNil(t, server)
+	go func() { _ = server.Run() }()
+	require.Eventually(t, server.IsRunning, time.Second, 10*time.Millisecond)
+
+	// The connections are opened and closed a few at a time, so that the server
+	// connections are recycled many times.
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range connections / workers {
+				assert.Eventually(t, func() bool {
+					return proxy.AvailableConnections.Size() >= workers
+				}, time.Second, time.Millisecond)
+				conn, err := DialMemory("leak-gatewayd", time.Second)
+				if !assert.NoError(t, err) {
+					return
+				}
+				_, err = conn.Write(query)
+				assert.NoError(t, err)
+				response := make([]byte, len(ready))
+				_, err = io.ReadFull(conn, response)
+				assert.NoError(t, err)
+				assert.NoError(t, conn.Close())
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Eventually(t, func() bool {
+		return server.CountConnections() == 0
+	}, time.Second, 10*time.Millisecond)
+	server.Shutdown()
+	require.NoError(t, upstream.Close())
+
+	// The goroutines may take a moment to return after their connections are closed.
+	// assert.Eventually isn't used, since it checks in a goroutine of its own.
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if goroutines := runtime.NumGoroutine(); goroutines > baseline {
+		var stacks bytes.Buffer
+		_ = pprof.Lookup("goroutine").WriteTo(&stacks, 1)
+		t.Errorf("%d goroutines are leaked:\n%s", goroutines-baseline, stacks.String())
+	}
+}
@@ -0,0 +1,115 @@
+package network
+
+import (
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/gatewayd-io/gatewayd/plugin"
+	"github.com/spf13/cast"
+	"go.opentelemetry.io/otel"
+)
+
+// RoutingPlugin is the strategy of assigning a server connection of the backend
+// chosen by the OnLoadBalance hooks to the client.
+const RoutingPlugin = "plugin"
+
+// LoadBalanceField is the field of the OnLoadBalance hook result, in which the plugins
+// return the backend of the client connection, e.g. "tcp://localhost:5432".
+const LoadBalanceField = "backend"
+
+// loadBalance runs the OnLoadBalance hooks with the routing table of the proxy and the
+// client connection, and returns the backend chosen by the plugins, or an empty string
+// to fall back to the built-in strategy: if the hooks are disabled or fail, or if the
+// chosen backend doesn't exist, or has no available server connections.
+func (pr *Proxy) loadBalance(conn *ConnWrapper) string {
+	if !pr.LoadBalanceHook || pr.PluginRegistry == nil {
+		return ""
+	}
+
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "loadBalance")
+	defer span.End()
+
+	table := []interface{}{}
+	available := map[string]bool{}
+	for _, backend := range pr.RoutingTable() {
+		table = append(table, backend)
+		name := cast.ToString(backend["backend"])
+		available[name] = backend["status"] == BackendHealthy && cast.ToInt(backend["available"]) > 0
+	}
+
+	client := map[string]interface{}{
+		"remote":   RemoteAddr(conn.Conn()),
+		"local":    LocalAddr(conn.Conn()),
+		"priority": pr.connectionPriority(conn),
+	}
+	if identity := conn.Identity(); identity != nil {
+		client["identity"] = identity.Name
+	}
+	if certificate := conn.ClientCertificate(); certificate != nil {
+		client[CertificateField] = certificate.Fields()
+	}
+	if labels := conn.Labels(); len(labels) > 0 {
+		fields := make(map[string]interface{}, len(labels))
+		for name, value := range labels {
+			fields[name] = value
+		}
+		client[LabelsField] = fields
+	}
+
+	result, err := pr.PluginRegistry.RunLifecycleHookWithResult(
+		plugin.OnLoadBalanceHookName,
+		map[string]interface{}{"backends": table, "client": client},
+		pr.PluginTimeout)
+	if err != nil {
+		pr.Logger.Error().Err(err).Msg("Failed to run the OnLoadBalance hooks, using the built-in strategy")
+		span.RecordError(err)
+		metrics.LoadBalanceDecisions.WithLabelValues("failed").Inc()
+		return ""
+	}
+
+	backend := cast.ToString(result[LoadBalanceField])
+	switch {
+	case backend == "":
+		metrics.LoadBalanceDecisions.WithLabelValues("skipped").Inc()
+		return ""
+	case !available[backend]:
+		pr.Logger.Warn().Str("backend", backend).Msg(
+			"The backend chosen by the plugins doesn't exist or isn't available, using the built-in strategy")
+		metrics.LoadBalanceDecisions.WithLabelValues("rejected").Inc()
+		return ""
+	}
+
+	metrics.LoadBalanceDecisions.WithLabelValues("applied").Inc()
+	return backend
+}
+
+// availableClientIDOf returns the ID of the first available client of the backend in
+// the pool, e.g. "tcp://localhost:5432", or of the first available client if the
+// backend has none.
+func (pr *Proxy) availableClientIDOf(backend string) string {
+	if backend == "" {
+		return pr.availableClientID()
+	}
+
+	if clientID := pr.backendClientID(backend); clientID != "" {
+		return clientID
+	}
+	return pr.availableClientID()
+}
+
+// backendClientID returns the ID of the first available client of the backend in the
+// pool, or an empty string if the backend has none.
+func (pr *Proxy) backendClientID(backend string) string {
+	var clientID string
+	pr.AvailableConnections.ForEach(func(key, value interface{}) bool {
+		cid, ok := key.(string)
+		if !ok {
+			return true
+		}
+		if client, ok := value.(IClient); ok && client.GetNetwork()+"://"+client.GetAddress() == backend {
+			clientID = cid
+			return false // stop the loop.
+		}
+		return true
+	})
+	return clientID
+}
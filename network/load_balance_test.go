@@ -0,0 +1,81 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/gatewayd-io/gatewayd/plugin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// TestLoadBalanceHook tests assigning the server connections of the backends chosen
+// by the OnLoadBalance hooks, and falling back to the built-in strategy.
+func TestLoadBalanceHook(t *testing.T) {
+	first := newFakeUpstream(t, func(net.Conn) {})
+	second := newFakeUpstream(t, func(net.Conn) {})
+
+	clientConfig := newTestClientConfig(first.Address())
+	clientConfig.Backends = []config.Backend{
+		{Network: "tcp", Address: first.Address()},
+		{Network: "tcp", Address: second.Address()},
+	}
+	firstClient := NewClient(context.Background(), clientConfig.GetBackend(0), zerolog.Nop(), nil)
+	require.NotNil(t, firstClient)
+	secondClient := NewClient(context.Background(), clientConfig.GetBackend(1), zerolog.Nop(), nil)
+	require.NotNil(t, secondClient)
+	proxy := newTestProxyWithClients(t, clientConfig, firstClient, secondClient)
+
+	chosen := "tcp://" + second.Address()
+	calls := 0
+	proxy.PluginRegistry.AddHook(v1.HookName_HOOK_NAME_ON_HOOK, 0, func(
+		_ context.Context,
+		args *v1.Struct,
+		_ ...grpc.CallOption,
+	) (*v1.Struct, error) {
+		fields := args.AsMap()
+		if fields["hook"] != plugin.OnLoadBalanceHookName {
+			return args, nil
+		}
+		calls++
+		assert.Len(t, fields["backends"], 2)
+		assert.Equal(t, "127.0.0.1:54321", fields["client"].(map[string]interface{})["remote"])
+		fields[LoadBalanceField] = chosen
+		return v1.NewStruct(fields)
+	})
+
+	// The hooks are disabled by default.
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	assert.Equal(t, RoutingFirstAvailable, proxy.Routing(conn)["strategy"])
+	require.Nil(t, proxy.Disconnect(conn))
+	assert.Zero(t, calls)
+
+	// The server connection of the backend chosen by the plugins is assigned.
+	proxy.LoadBalanceHook = true
+	applied := testutil.ToFloat64(metrics.LoadBalanceDecisions.WithLabelValues("applied"))
+	conn = NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	assert.Equal(t, 1, calls)
+	routing := proxy.Routing(conn)
+	assert.Equal(t, chosen, routing["backend"])
+	assert.Equal(t, RoutingPlugin, routing["strategy"])
+	assert.Equal(t, applied+1, testutil.ToFloat64(metrics.LoadBalanceDecisions.WithLabelValues("applied")))
+	require.Nil(t, proxy.Disconnect(conn))
+
+	// The backends that don't exist are rejected, and the built-in strategy is used.
+	chosen = "tcp://localhost:1"
+	rejected := testutil.ToFloat64(metrics.LoadBalanceDecisions.WithLabelValues("rejected"))
+	conn = NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	assert.Equal(t, RoutingFirstAvailable, proxy.Routing(conn)["strategy"])
+	assert.Equal(t, rejected+1, testutil.ToFloat64(metrics.LoadBalanceDecisions.WithLabelValues("rejected")))
+	require.Nil(t, proxy.Disconnect(conn))
+}
@@ -0,0 +1,139 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoryNetwork is the network of the in-memory connections, for embedding GatewayD,
+// e.g. in the integration tests, without real sockets. The servers listen on a name,
+// e.g. "gatewayd", and the clients connect to the server with the same name in the
+// same process. The backends can be in memory too, e.g. a fake database server.
+const MemoryNetwork = "memory"
+
+var (
+	errMemoryAddressInUse = errors.New("memory address already in use")
+	errMemoryRefused      = errors.New("no memory listener on the address")
+
+	// memoryListeners are the memory listeners by their address.
+	memoryListeners sync.Map
+	// memoryConnections numbers the memory connections, for their addresses.
+	memoryConnections atomic.Uint64
+)
+
+// memoryAddr is the address of a memory listener or a memory connection.
+type memoryAddr string
+
+func (a memoryAddr) Network() string { return MemoryNetwork }
+func (a memoryAddr) String() string  { return string(a) }
+
+// memoryConn is one end of a memory connection, with the addresses of the memory
+// network instead of the ones of the pipe, so that each connection has its own.
+type memoryConn struct {
+	net.Conn
+
+	local  memoryAddr
+	remote memoryAddr
+}
+
+func (c *memoryConn) LocalAddr() net.Addr  { return c.local }
+func (c *memoryConn) RemoteAddr() net.Addr { return c.remote }
+
+// MemoryListener accepts the memory connections to its address.
+type MemoryListener struct {
+	address   string
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+var _ net.Listener = (*MemoryListener)(nil)
+
+// ListenMemory listens on the address in memory. Only one listener can listen on
+// an address at a time.
+func ListenMemory(address string) (*MemoryListener, error) {
+	listener := &MemoryListener{
+		address: address,
+		conns:   make(chan net.Conn),
+		closed:  make(chan struct{}),
+	}
+	if _, loaded := memoryListeners.LoadOrStore(address, listener); loaded {
+		return nil, fmt.Errorf("%w: %s", errMemoryAddressInUse, address)
+	}
+	return listener, nil
+}
+
+// Accept waits for the next memory connection to the listener.
+func (l *MemoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting the memory connections and frees the address.
+func (l *MemoryListener) Close() error {
+	err := net.ErrClosed
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		memoryListeners.CompareAndDelete(l.address, l)
+		err = nil
+	})
+	return err
+}
+
+// Addr returns the address of the listener.
+func (l *MemoryListener) Addr() net.Addr {
+	return memoryAddr(l.address)
+}
+
+// DialMemory connects to the memory listener on the address, waiting up to the
+// timeout for the listener to accept the connection. A zero timeout means no timeout.
+func DialMemory(address string, timeout time.Duration) (net.Conn, error) {
+	value, ok := memoryListeners.Load(address)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errMemoryRefused, address)
+	}
+	listener, ok := value.(*MemoryListener)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errMemoryRefused, address)
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	client := memoryAddr(fmt.Sprintf("client-%d", memoryConnections.Add(1)))
+	clientSide, serverSide := net.Pipe()
+	select {
+	case listener.conns <- &memoryConn{Conn: serverSide, local: memoryAddr(address), remote: client}:
+		return &memoryConn{Conn: clientSide, local: client, remote: memoryAddr(address)}, nil
+	case <-listener.closed:
+		clientSide.Close()
+		serverSide.Close()
+		return nil, fmt.Errorf("%w: %s", errMemoryRefused, address)
+	case <-ctx.Done():
+		clientSide.Close()
+		serverSide.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, ctx.Err())
+	}
+}
+
+// dialNetwork connects to the address on the network, in memory if the network
+// is the memory network.
+func dialNetwork(network, address string, timeout time.Duration) (net.Conn, error) {
+	if network == MemoryNetwork {
+		return DialMemory(address, timeout)
+	}
+	return net.DialTimeout(network, address, timeout) //nolint:wrapcheck
+}
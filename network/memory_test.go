@@ -0,0 +1,142 @@
+package network
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMemoryListener tests connecting to a memory listener by its address.
+func TestMemoryListener(t *testing.T) {
+	listener, err := ListenMemory("memory-listener")
+	require.NoError(t, err)
+	_, err = ListenMemory("memory-listener")
+	require.Error(t, err, "the address is in use")
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	first, err := DialMemory("memory-listener", time.Second)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := DialMemory("memory-listener", time.Second)
+	require.NoError(t, err)
+	defer second.Close()
+	assert.NotEqual(t, first.LocalAddr().String(), second.LocalAddr().String())
+	assert.Equal(t, MemoryNetwork, first.RemoteAddr().Network())
+	assert.Equal(t, "memory-listener", first.RemoteAddr().String())
+
+	server := <-accepted
+	defer server.Close()
+	assert.Equal(t, first.LocalAddr().String(), server.RemoteAddr().String())
+	go func() { _, _ = first.Write([]byte("select 1")) }()
+	data := make([]byte, 8)
+	_, err = io.ReadFull(server, data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("select 1"), data)
+
+	// The address is free again after the listener is closed.
+	require.NoError(t, listener.Close())
+	require.ErrorIs(t, listener.Close(), net.ErrClosed)
+	_, err = DialMemory("memory-listener", time.Second)
+	require.Error(t, err)
+	listener, err = ListenMemory("memory-listener")
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+}
+
+// newMemoryDatabase returns a memory listener on the address that answers every
+// request with the response, like a database server.
+func newMemoryDatabase(t *testing.T, address string, response []byte) *MemoryListener {
+	t.Helper()
+
+	listener, err := ListenMemory(address)
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				buffer := make([]byte, config.DefaultChunkSize)
+				for {
+					if _, err := conn.Read(buffer); err != nil {
+						return
+					}
+					if _, err := conn.Write(response); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener
+}
+
+// TestMemoryServer tests running the whole proxy pipeline in memory, from the client
+// to the server and from the proxy to the database server.
+func TestMemoryServer(t *testing.T) {
+	ready, err := (&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)}).Encode(nil)
+	require.NoError(t, err)
+
+	upstream := newMemoryDatabase(t, "memory-database", ready)
+	t.Cleanup(func() { upstream.Close() })
+
+	clientConfig := newTestClientConfig("memory-database")
+	clientConfig.Network = MemoryNetwork
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	proxy := newTestProxyWithClients(t, clientConfig, client)
+
+	server := NewServer(
+		context.Background(),
+		Server{
+			Network:        MemoryNetwork,
+			Address:        "memory-gatewayd",
+			Proxy:          proxy,
+			Logger:         zerolog.Nop(),
+			PluginRegistry: proxy.PluginRegistry,
+			PluginTimeout:  config.DefaultPluginTimeout,
+		},
+	)
+	require.NotNil(t, server)
+	go func() { _ = server.Run() }()
+	require.Eventually(t, server.IsRunning, time.Second, 10*time.Millisecond)
+
+	conn, err := DialMemory("memory-gatewayd", time.Second)
+	require.NoError(t, err)
+
+	query, err := (&pgproto3.Query{String: "SELECT 1"}).Encode(nil)
+	require.NoError(t, err)
+	_, err = conn.Write(query)
+	require.NoError(t, err)
+	response := make([]byte, len(ready))
+	_, err = io.ReadFull(conn, response)
+	require.NoError(t, err)
+	assert.Equal(t, ready, response)
+
+	// The connection is closed before the server and the proxy are shut down.
+	require.NoError(t, conn.Close())
+	require.Eventually(t, func() bool {
+		return server.CountConnections() == 0
+	}, time.Second, 10*time.Millisecond)
+	server.Shutdown()
+}
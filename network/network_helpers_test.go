@@ -1,12 +1,25 @@
 package network
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"io"
+	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gatewayd-io/gatewayd/act"
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/gatewayd-io/gatewayd/plugin"
+	"github.com/gatewayd-io/gatewayd/pool"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 )
 
@@ -154,3 +167,285 @@ func CollectAndComparePrometheusMetrics(t *testing.T) {
 	require.NoError(t,
 		testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(want), metrics...))
 }
+
+// mockConn is an in-memory client connection for testing the proxy without a server.
+// The reads return the queued messages one by one, followed by io.EOF, and the
+// writes are recorded.
+type mockConn struct {
+	inbound [][]byte
+	written bytes.Buffer
+	closed  bool
+	mu      sync.Mutex
+}
+
+var _ net.Conn = (*mockConn)(nil)
+
+// newMockConn creates a new mock connection with the given messages queued for reading.
+func newMockConn(inbound ...[]byte) *mockConn {
+	return &mockConn{inbound: inbound}
+}
+
+func (c *mockConn) Read(data []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	if len(c.inbound) == 0 {
+		return 0, io.EOF
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	read := copy(data, c.inbound[0])
+	if read < len(c.inbound[0]) {
+		c.inbound[0] = c.inbound[0][read:]
+	} else {
+		c.inbound = c.inbound[1:]
+	}
+	return read, nil
+}
+
+func (c *mockConn) Write(data []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	return c.written.Write(data) //nolint:wrapcheck
+}
+
+func (c *mockConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *mockConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 15432}
+}
+
+func (c *mockConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 54321}
+}
+
+func (c *mockConn) SetDeadline(time.Time) error      { return nil }
+func (c *mockConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *mockConn) SetWriteDeadline(time.Time) error { return nil }
+
+// Written returns the data written to the connection so far.
+func (c *mockConn) Written() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.written.Bytes())
+}
+
+// IsClosed returns true if the connection is closed.
+func (c *mockConn) IsClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// fakeUpstream is a fake database server, which runs the handler on every
+// accepted connection.
+type fakeUpstream struct {
+	listener net.Listener
+	accepted atomic.Int32
+}
+
+// newFakeUpstream starts a fake database server, which is stopped when the test ends.
+func newFakeUpstream(t *testing.T, handler func(conn net.Conn)) *fakeUpstream {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	upstream := &fakeUpstream{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			upstream.accepted.Add(1)
+			go handler(conn)
+		}
+	}()
+
+	return upstream
+}
+
+// Address returns the address of the fake database server.
+func (u *fakeUpstream) Address() string {
+	return u.listener.Addr().String()
+}
+
+// Accepted returns the number of connections accepted so far.
+func (u *fakeUpstream) Accepted() int {
+	return int(u.accepted.Load())
+}
+
+// requireAccepted waits for the fake database server to accept the number of
+// connections, since it counts them after the clients are connected.
+func requireAccepted(t *testing.T, upstream *fakeUpstream, accepted int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return upstream.Accepted() == accepted },
+		time.Second, time.Millisecond, "accepted %d connections", upstream.Accepted())
+}
+
+// serverConnOf returns the server side of the connection that the client currently
+// holds, out of the connections accepted by the fake database server.
+func serverConnOf(t *testing.T, conns <-chan net.Conn, client IClient) net.Conn {
+	t.Helper()
+	for {
+		select {
+		case conn := <-conns:
+			if conn.RemoteAddr().String() == client.LocalAddr() {
+				return conn
+			}
+		case <-time.After(time.Second):
+			require.FailNow(t, "the connection of the client wasn't accepted")
+		}
+	}
+}
+
+// newTestClientConfig returns a client config for connecting to the given address.
+func newTestClientConfig(address string) *config.Client {
+	return &config.Client{
+		Network:            "tcp",
+		Address:            address,
+		ReceiveChunkSize:   config.DefaultChunkSize,
+		ReceiveDeadline:    config.DefaultReceiveDeadline,
+		ReceiveTimeout:     config.DefaultReceiveTimeout,
+		SendDeadline:       config.DefaultSendDeadline,
+		DialTimeout:        config.DefaultDialTimeout,
+		TCPKeepAlive:       false,
+		TCPKeepAlivePeriod: config.DefaultTCPKeepAlivePeriod,
+	}
+}
+
+// newTestProxy creates a proxy with a single client connected to the given address
+// and no plugins. The proxy is shut down when the test ends.
+func newTestProxy(t *testing.T, address string) *Proxy {
+	t.Helper()
+
+	clientConfig := newTestClientConfig(address)
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+
+	return newTestProxyWithClients(t, clientConfig, client)
+}
+
+// newTestProxyWithClients creates a proxy with the given clients in its pool
+// and no plugins. The proxy is shut down when the test ends.
+func newTestProxyWithClients(t *testing.T, clientConfig *config.Client, clients ...IClient) *Proxy {
+	t.Helper()
+
+	logger := zerolog.Nop()
+
+	newPool := pool.NewPool(context.Background(), config.EmptyPoolCapacity)
+	for _, client := range clients {
+		require.Nil(t, newPool.Put(client.GetID(), client))
+	}
+
+	proxy := NewProxy(
+		context.Background(),
+		Proxy{
+			AvailableConnections: newPool,
+			PluginRegistry: plugin.NewRegistry(
+				context.Background(),
+				plugin.Registry{
+					ActRegistry: act.NewActRegistry(
+						act.Registry{
+							Signals:              act.BuiltinSignals(),
+							Policies:             act.BuiltinPolicies(),
+							Actions:              act.BuiltinActions(),
+							DefaultPolicyName:    config.DefaultPolicy,
+							PolicyTimeout:        config.DefaultPolicyTimeout,
+							DefaultActionTimeout: config.DefaultActionTimeout,
+							Logger:               logger,
+						}),
+					Compatibility: config.Loose,
+					Logger:        logger,
+				},
+			),
+			HealthCheckPeriod: config.DefaultHealthCheckPeriod,
+			ClientConfig:      clientConfig,
+			Logger:            logger,
+			PluginTimeout:     config.DefaultPluginTimeout,
+		},
+	)
+	t.Cleanup(proxy.Shutdown)
+
+	return proxy
+}
+
+// memoryClient is an in-memory server connection for testing the proxy without
+// a database server. The test plays the server on the other end of the pipe.
+type memoryClient struct {
+	id        string
+	conn      net.Conn
+	connected atomic.Bool
+}
+
+var _ IClient = (*memoryClient)(nil)
+
+// newMemoryClient creates a new in-memory client and returns the server end of it.
+func newMemoryClient(id string) (*memoryClient, net.Conn) {
+	clientSide, serverSide := net.Pipe()
+	client := &memoryClient{id: id, conn: clientSide}
+	client.connected.Store(true)
+	return client, serverSide
+}
+
+func (c *memoryClient) Send(data []byte) (int, *gerr.GatewayDError) {
+	sent, err := c.conn.Write(data)
+	if err != nil {
+		return sent, gerr.ErrClientSendFailed.Wrap(err)
+	}
+	return sent, nil
+}
+
+func (c *memoryClient) Receive() (int, []byte, *gerr.GatewayDError) {
+	buffer := make([]byte, config.DefaultChunkSize)
+	received, err := c.conn.Read(buffer)
+	if err != nil {
+		return received, buffer[:received], gerr.ErrClientReceiveFailed.Wrap(err)
+	}
+	return received, buffer[:received], nil
+}
+
+func (c *memoryClient) Reconnect() error {
+	c.connected.Store(true)
+	return nil
+}
+
+func (c *memoryClient) Close() {
+	c.connected.Store(false)
+	c.conn.Close()
+}
+
+func (c *memoryClient) IsConnected() bool         { return c.connected.Load() }
+func (c *memoryClient) Ping() *gerr.GatewayDError { return nil }
+func (c *memoryClient) RemoteAddr() string        { return "memory" }
+func (c *memoryClient) LocalAddr() string         { return "memory" }
+func (c *memoryClient) Retry() *Retry             { return nil }
+func (c *memoryClient) GetID() string             { return c.id }
+func (c *memoryClient) GetNetwork() string        { return "memory" }
+func (c *memoryClient) GetAddress() string        { return "memory" }
+
+func (c *memoryClient) StartupParameters() map[string]string {
+	return nil
+}
+
+func (c *memoryClient) StartupResponse() []byte { return nil }
+
+func (c *memoryClient) Reuse() (IClient, *gerr.GatewayDError) {
+	return nil, gerr.ErrSessionResetFailed
+}
@@ -0,0 +1,94 @@
+package network
+
+import (
+	"net"
+	"sync"
+)
+
+// ConnectionsPerIP counts the concurrent client connections of each client IP address,
+// to cap them, so that a single client can't take all the connections of the server.
+type ConnectionsPerIP struct {
+	mu          sync.Mutex
+	connections map[string]int
+	// allowList are the IP addresses and CIDR ranges of the clients that aren't capped.
+	allowList []*net.IPNet
+}
+
+// NewConnectionsPerIP creates a new counter of the connections per IP address, with the
+// parsed allow list of the clients that aren't capped.
+func NewConnectionsPerIP(allowList []*net.IPNet) *ConnectionsPerIP {
+	return &ConnectionsPerIP{
+		connections: map[string]int{},
+		allowList:   allowList,
+	}
+}
+
+// Acquire counts a new connection of the client IP address, unless the client already
+// has the max number of connections and isn't allow-listed, in which case it returns
+// false. A max of 0 means unlimited, and a nil counter doesn't count.
+func (c *ConnectionsPerIP) Acquire(address string, maxConnections int) bool {
+	if c == nil {
+		return true
+	}
+	host := remoteHost(address)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if maxConnections > 0 && c.connections[host] >= maxConnections && !c.isAllowed(host) {
+		return false
+	}
+	c.connections[host]++
+	return true
+}
+
+// Release uncounts a connection of the client IP address.
+func (c *ConnectionsPerIP) Release(address string) {
+	if c == nil {
+		return
+	}
+	host := remoteHost(address)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.connections[host] <= 1 {
+		delete(c.connections, host)
+		return
+	}
+	c.connections[host]--
+}
+
+// Count returns the number of the connections of the client IP address.
+func (c *ConnectionsPerIP) Count(address string) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.connections[remoteHost(address)]
+}
+
+// isAllowed returns true if the client IP address is allow-listed.
+func (c *ConnectionsPerIP) isAllowed(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range c.allowList {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteHost returns the host of the remote address, or the address as is if it
+// has no port, e.g. of the Unix domain sockets and the in-memory connections.
+func remoteHost(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
@@ -0,0 +1,80 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConnectionsPerIP tests that the connections of each client IP address are capped,
+// unless the client is allow-listed.
+func TestConnectionsPerIP(t *testing.T) {
+	allowList, invalid := ParseCaptureClients([]string{"10.0.0.0/8"})
+	assert.Empty(t, invalid)
+	counter := NewConnectionsPerIP(allowList)
+
+	assert.True(t, counter.Acquire("192.168.1.1:5000", 2))
+	assert.True(t, counter.Acquire("192.168.1.1:5001", 2))
+	assert.False(t, counter.Acquire("192.168.1.1:5002", 2))
+	assert.Equal(t, 2, counter.Count("192.168.1.1:5003"))
+
+	// The other clients have their own connections.
+	assert.True(t, counter.Acquire("192.168.1.2:5000", 2))
+
+	// The allow-listed clients aren't capped.
+	for range 3 {
+		assert.True(t, counter.Acquire("10.1.2.3:5000", 2))
+	}
+
+	// A released connection makes room for a new one.
+	counter.Release("192.168.1.1:5000")
+	assert.Equal(t, 1, counter.Count("192.168.1.1"))
+	assert.True(t, counter.Acquire("192.168.1.1:5002", 2))
+
+	// A max of 0 means unlimited.
+	assert.True(t, counter.Acquire("192.168.1.1:5003", 0))
+
+	// A nil counter doesn't count.
+	var nilCounter *ConnectionsPerIP
+	assert.True(t, nilCounter.Acquire("192.168.1.1:5000", 1))
+	nilCounter.Release("192.168.1.1:5000")
+	assert.Equal(t, 0, nilCounter.Count("192.168.1.1:5000"))
+}
+
+// TestServerMaxConnectionsPerIP tests that the server rejects the new connections of
+// a client above its max connections, and counts them until they're closed.
+func TestServerMaxConnectionsPerIP(t *testing.T) {
+	upstream := newFakeUpstream(t, func(net.Conn) {})
+	proxy := newTestProxy(t, upstream.Address())
+
+	server := &Server{
+		ctx:                 context.Background(),
+		Logger:              zerolog.Nop(),
+		Proxy:               proxy,
+		PluginRegistry:      proxy.PluginRegistry,
+		PluginTimeout:       config.DefaultPluginTimeout,
+		MaxConnectionsPerIP: 1,
+		connectionsPerIP:    NewConnectionsPerIP(nil),
+		mu:                  &sync.RWMutex{},
+		paused:              &atomic.Bool{},
+	}
+
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	_, action := server.OnOpen(conn)
+	assert.Equal(t, None, action)
+	assert.Equal(t, 1, server.connectionsPerIP.Count(RemoteAddr(conn.Conn())))
+
+	out, action := server.OnOpen(NewConnWrapper(ConnWrapper{NetConn: newMockConn()}))
+	assert.Equal(t, Close, action)
+	assert.Contains(t, string(out), "53300")
+	assert.Equal(t, 1, server.connectionsPerIP.Count(RemoteAddr(conn.Conn())))
+
+	server.OnClose(conn, nil)
+	assert.Equal(t, 0, server.connectionsPerIP.Count(RemoteAddr(conn.Conn())))
+}
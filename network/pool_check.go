@@ -0,0 +1,68 @@
+package network
+
+import (
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+)
+
+// testOnBorrow validates the server connection taken from the pool before it's handed
+// out, if enabled, and returns it, or a new server connection in its place if it fails.
+func (pr *Proxy) testOnBorrow(client IClient) IClient {
+	if !pr.TestOnBorrow {
+		return client
+	}
+	return pr.validateClient(client, "borrow", metrics.FailedBorrowChecks)
+}
+
+// testOnReturn validates the server connection before it's put back in the pool, if
+// enabled, and returns it, or a new server connection in its place if it fails, so that
+// the next client doesn't get a broken server connection.
+func (pr *Proxy) testOnReturn(client IClient) IClient {
+	if !pr.TestOnReturn {
+		return client
+	}
+	return pr.validateClient(client, "return", metrics.FailedReturnChecks)
+}
+
+// validateClient returns the server connection, or a new server connection of its
+// backend in its place if it's closed or it fails the ping. The
+// failed server connection is kept if the new one can't be connected either, so that
+// it's reconnected on the first request like before.
+func (pr *Proxy) validateClient(client IClient, check string, failures prometheus.Counter) IClient {
+	if client == nil {
+		return client
+	}
+
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "validateClient")
+	defer span.End()
+
+	if client.IsConnected() {
+		err := client.Ping()
+		if err == nil {
+			return client
+		}
+		span.RecordError(err)
+	}
+
+	failures.Inc()
+	backend := client.GetNetwork() + "://" + client.GetAddress()
+	pr.Logger.Warn().Str("backend", backend).Msgf(
+		"The server connection failed the check on %s, replacing it", check)
+
+	clientConfig := pr.clientConfigOf(client)
+	if clientConfig == nil {
+		return client
+	}
+	newClient := pr.newClient(clientConfig)
+	if newClient == nil {
+		pr.Logger.Error().Str("backend", backend).Msgf(
+			"Failed to replace the server connection that failed the check on %s", check)
+		return client
+	}
+
+	client.Close()
+	span.AddEvent("Replaced the server connection that failed the check on " + check)
+	return newClient
+}
@@ -0,0 +1,93 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTestOnBorrow tests replacing the server connections that fail the check on
+// borrow from the pool.
+func TestTestOnBorrow(t *testing.T) {
+	conns := make(chan net.Conn, 10)
+	upstream := newFakeUpstream(t, func(conn net.Conn) { conns <- conn })
+
+	clientConfig := newTestClientConfig(upstream.Address())
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	proxy := newTestProxyWithClients(t, clientConfig, client)
+	requireAccepted(t, upstream, 1)
+
+	// The server connections aren't checked by default, and are reconnected on return.
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	require.Nil(t, proxy.Disconnect(conn))
+	requireAccepted(t, upstream, 2)
+
+	// The server connection that passes the check is handed out, after it's pinged
+	// without opening a new connection.
+	proxy.TestOnBorrow = true
+	failed := testutil.ToFloat64(metrics.FailedBorrowChecks)
+	conn = NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	assert.Equal(t, client, proxy.busyConnections.Get(conn))
+	assert.Equal(t, failed, testutil.ToFloat64(metrics.FailedBorrowChecks))
+	require.Nil(t, proxy.Disconnect(conn))
+	requireAccepted(t, upstream, 3)
+
+	// The server connection closed by the server is replaced by a new one.
+	require.NoError(t, serverConnOf(t, conns, client).Close())
+	require.Eventually(t, func() bool { return !isStandbyAlive(client) }, time.Second, 10*time.Millisecond)
+	conn = NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	borrowed, ok := proxy.busyConnections.Get(conn).(IClient)
+	require.True(t, ok)
+	assert.NotEqual(t, client, borrowed)
+	assert.True(t, borrowed.IsConnected())
+	assert.False(t, client.IsConnected())
+	assert.Equal(t, failed+1, testutil.ToFloat64(metrics.FailedBorrowChecks))
+	requireAccepted(t, upstream, 4)
+	require.Nil(t, proxy.Disconnect(conn))
+}
+
+// TestTestOnReturn tests replacing the server connections that fail the check on
+// return to the pool.
+func TestTestOnReturn(t *testing.T) {
+	conns := make(chan net.Conn, 10)
+	upstream := newFakeUpstream(t, func(conn net.Conn) { conns <- conn })
+
+	clientConfig := newTestClientConfig(upstream.Address())
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	proxy := newTestProxyWithClients(t, clientConfig, client)
+
+	// The server connections are checked independently of the check on borrow.
+	proxy.TestOnReturn = true
+	failed := testutil.ToFloat64(metrics.FailedReturnChecks)
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	requireAccepted(t, upstream, 1)
+	require.Nil(t, proxy.Disconnect(conn))
+	// The server connection is reconnected on return, and then pinged.
+	requireAccepted(t, upstream, 2)
+	assert.Equal(t, failed, testutil.ToFloat64(metrics.FailedReturnChecks))
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+
+	// The server connection closed by the server is replaced by a new one.
+	require.NoError(t, serverConnOf(t, conns, client).Close())
+	require.Eventually(t, func() bool { return !isStandbyAlive(client) }, time.Second, 10*time.Millisecond)
+	returned := proxy.testOnReturn(client)
+	requireAccepted(t, upstream, 3)
+	assert.NotEqual(t, client, returned)
+	assert.True(t, returned.IsConnected())
+	assert.False(t, client.IsConnected())
+	assert.Equal(t, failed+1, testutil.ToFloat64(metrics.FailedReturnChecks))
+	returned.Close()
+}
@@ -0,0 +1,19 @@
+package network
+
+import (
+	"time"
+)
+
+// poolEvent runs the OnPoolAcquire or OnPoolRelease hooks for the server connection
+// in the background, with the given duration in seconds. The backend is read before
+// the server connection is recycled, which clears it.
+func (pr *Proxy) poolEvent(hook string, client IClient, field string, duration time.Duration) {
+	pr.runLifecycleHook(hook, map[string]interface{}{
+		"client": client.GetID(),
+		"backend": map[string]interface{}{
+			"network": client.GetNetwork(),
+			"address": client.GetAddress(),
+		},
+		field: duration.Seconds(),
+	})
+}
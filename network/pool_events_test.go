@@ -0,0 +1,71 @@
+package network
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// TestProxyPoolEvents tests that the OnPoolAcquire and OnPoolRelease hooks are run
+// when the server connection is acquired and released, if the pool events are enabled.
+func TestProxyPoolEvents(t *testing.T) {
+	upstream := newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		_, _ = io.Copy(io.Discard, conn)
+	})
+	proxy := newTestProxy(t, upstream.Address())
+
+	events := make(chan map[string]interface{}, 2)
+	proxy.PluginRegistry.AddHook(v1.HookName_HOOK_NAME_ON_HOOK, 0, func(
+		_ context.Context,
+		args *v1.Struct,
+		_ ...grpc.CallOption,
+	) (*v1.Struct, error) {
+		events <- args.AsMap()
+		return args, nil
+	})
+
+	// The pool events are disabled by default.
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	require.Nil(t, proxy.Disconnect(conn))
+	assert.Never(t, func() bool { return len(events) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	proxy.PoolEvents = true
+	conn = NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	client, ok := proxy.busyConnections.Get(conn).(IClient)
+	require.True(t, ok)
+	clientID := client.GetID()
+
+	select {
+	case event := <-events:
+		assert.Equal(t, plugin.OnPoolAcquireHookName, event["hook"])
+		assert.Equal(t, clientID, event["client"])
+		assert.Equal(t, map[string]interface{}{
+			"network": "tcp",
+			"address": upstream.Address(),
+		}, event["backend"])
+		assert.Contains(t, event, "wait")
+	case <-time.After(time.Second):
+		t.Fatal("OnPoolAcquire hooks didn't run")
+	}
+
+	require.Nil(t, proxy.Disconnect(conn))
+	select {
+	case event := <-events:
+		assert.Equal(t, plugin.OnPoolReleaseHookName, event["hook"])
+		assert.Equal(t, clientID, event["client"])
+		assert.Contains(t, event, "held")
+	case <-time.After(time.Second):
+		t.Fatal("OnPoolRelease hooks didn't run")
+	}
+}
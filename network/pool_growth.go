@@ -0,0 +1,151 @@
+package network
+
+import (
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/metrics"
+)
+
+// canGrow returns true if the pool grows lazily above its min size under load.
+func (pr *Proxy) canGrow() bool {
+	return pr.MaxPoolSize > pr.MinPoolSize && pr.MinPoolSize >= 0
+}
+
+// growPool creates a new server connection when the pool is exhausted, instead of
+// waiting for one, as long as the pool hasn't grown to its max size. It returns nil
+// if the pool can't grow, no dial slot is free within the dial wait timeout, or the
+// server connection can't be created.
+func (pr *Proxy) growPool() IClient {
+	if !pr.canGrow() || pr.ClientConfig == nil {
+		return nil
+	}
+
+	// Reserve the server connection first, so that the pool doesn't grow above its
+	// max size with the concurrent connections.
+	for {
+		grown := pr.grown.Load()
+		if int(grown) >= pr.MaxPoolSize-pr.MinPoolSize {
+			return nil
+		}
+		if pr.grown.CompareAndSwap(grown, grown+1) {
+			break
+		}
+	}
+
+	// The dials are slow while the backend is overloaded, so they're capped, instead
+	// of piling up on it with the connection storms.
+	if !pr.acquireDial() {
+		pr.grown.Add(-1)
+		metrics.PoolRejectedDials.Inc()
+		pr.Logger.Debug().Int("maxConcurrentDials", pr.MaxConcurrentDials).Msg(
+			"Didn't grow the pool, because too many server connections are being dialed")
+		return nil
+	}
+	defer pr.releaseDial()
+
+	clientConfig := pr.failoverBackend()
+	if clientConfig == nil {
+		pr.grown.Add(-1)
+		return nil
+	}
+	client := pr.newClient(pr.weightedBackend(clientConfig))
+	if client == nil || client.ID == "" {
+		pr.grown.Add(-1)
+		pr.Logger.Error().Msg("Failed to grow the pool by a new server connection")
+		return nil
+	}
+
+	metrics.PoolGrownConnections.Inc()
+	pr.Logger.Debug().Fields(
+		map[string]interface{}{
+			"client": client.ID,
+			"grown":  pr.grown.Load(),
+		},
+	).Msg("Grew the pool by a new server connection")
+	return client
+}
+
+// acquireDial takes a slot of the concurrent dials, waiting for the dial wait timeout
+// at most if all of them are taken. It returns false if no slot is free in time.
+func (pr *Proxy) acquireDial() bool {
+	if pr.dials == nil {
+		return true
+	}
+
+	select {
+	case pr.dials <- struct{}{}:
+		return true
+	default:
+	}
+	if pr.DialWaitTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(pr.DialWaitTimeout)
+	defer timer.Stop()
+	select {
+	case pr.dials <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-pr.ctx.Done():
+		return false
+	}
+}
+
+// releaseDial frees the slot of the concurrent dials taken by acquireDial.
+func (pr *Proxy) releaseDial() {
+	if pr.dials != nil {
+		<-pr.dials
+	}
+}
+
+// markIdle records when the server connection was put back in the pool, for closing
+// it once it's idle for the idle timeout, if the pool grew above its min size.
+func (pr *Proxy) markIdle(client IClient) {
+	if pr.canGrow() {
+		pr.idleSince.Store(client.GetID(), time.Now())
+	}
+}
+
+// reapIdleClients closes the server connections that have been idle in the pool for
+// the idle timeout, as long as the pool is above its min size, so that it shrinks
+// back toward its min size once the load is over.
+func (pr *Proxy) reapIdleClients() {
+	pr.AvailableConnections.ForEach(func(key, value interface{}) bool {
+		if pr.grown.Load() <= 0 {
+			return false
+		}
+
+		client, ok := value.(IClient)
+		if !ok {
+			return true
+		}
+		// The idle time of the server connections that weren't used yet starts now.
+		idleSince, loaded := pr.idleSince.LoadOrStore(client.GetID(), time.Now())
+		if since, ok := idleSince.(time.Time); !loaded || !ok || time.Since(since) < pr.PoolIdleTimeout {
+			return true
+		}
+
+		// Another connection may have taken the server connection meanwhile.
+		grown := pr.grown.Load()
+		if grown <= 0 || !pr.grown.CompareAndSwap(grown, grown-1) {
+			return true
+		}
+		if pr.AvailableConnections.Pop(key) == nil {
+			pr.grown.Add(1)
+			return true
+		}
+		pr.idleSince.Delete(client.GetID())
+		client.Close()
+
+		metrics.PoolReapedConnections.Inc()
+		pr.Logger.Debug().Fields(
+			map[string]interface{}{
+				"client": client.GetID(),
+				"grown":  pr.grown.Load(),
+			},
+		).Msg("Closed the idle server connection to shrink the pool")
+		return true
+	})
+}
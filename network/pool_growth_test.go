@@ -0,0 +1,110 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/gatewayd-io/gatewayd/pool"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGrowPool tests growing the pool lazily above its min size under load, up to
+// its max size, and shrinking it back once the server connections are idle.
+func TestGrowPool(t *testing.T) {
+	upstream := newFakeUpstream(t, func(net.Conn) {})
+
+	clientConfig := newTestClientConfig(upstream.Address())
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	proxy := newTestProxyWithClients(t, clientConfig)
+	proxy.AvailableConnections = pool.NewPool(context.Background(), 2)
+	require.Nil(t, proxy.AvailableConnections.Put(client.GetID(), client))
+	proxy.MinPoolSize = 1
+	proxy.MaxPoolSize = 2
+	proxy.PoolIdleTimeout = 50 * time.Millisecond
+
+	grown := testutil.ToFloat64(metrics.PoolGrownConnections)
+	reaped := testutil.ToFloat64(metrics.PoolReapedConnections)
+
+	// The preallocated server connection is handed out first.
+	first := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(first))
+	assert.Equal(t, client, proxy.busyConnections.Get(first))
+	assert.Equal(t, grown, testutil.ToFloat64(metrics.PoolGrownConnections))
+
+	// The exhausted pool grows by a new server connection, up to its max size.
+	second := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(second))
+	added, ok := proxy.busyConnections.Get(second).(IClient)
+	require.True(t, ok)
+	assert.NotEqual(t, client, added)
+	assert.True(t, added.IsConnected())
+	assert.Equal(t, grown+1, testutil.ToFloat64(metrics.PoolGrownConnections))
+
+	third := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	assert.Equal(t, gerr.ErrPoolExhausted, proxy.Connect(third))
+
+	require.Nil(t, proxy.Disconnect(first))
+	require.Nil(t, proxy.Disconnect(second))
+	assert.Equal(t, 2, proxy.AvailableConnections.Size())
+
+	// The server connections that aren't idle for the idle timeout are kept.
+	proxy.reapIdleClients()
+	assert.Equal(t, 2, proxy.AvailableConnections.Size())
+
+	// The pool shrinks back to its min size, and no further.
+	time.Sleep(2 * proxy.PoolIdleTimeout)
+	proxy.reapIdleClients()
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+	assert.Equal(t, int32(0), proxy.grown.Load())
+	assert.Equal(t, reaped+1, testutil.ToFloat64(metrics.PoolReapedConnections))
+	proxy.reapIdleClients()
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+}
+
+// TestGrowPoolFixedSize tests that the pools without a max size don't grow.
+func TestGrowPoolFixedSize(t *testing.T) {
+	proxy := newTestProxy(t, newFakeUpstream(t, func(net.Conn) {}).Address())
+	proxy.MinPoolSize = config.DefaultPoolSize
+	assert.False(t, proxy.canGrow())
+	assert.Nil(t, proxy.growPool())
+}
+
+// TestGrowPoolMaxConcurrentDials tests that the pool doesn't dial more server
+// connections at once than its max concurrent dials, waiting for a dial for the dial
+// wait timeout at most.
+func TestGrowPoolMaxConcurrentDials(t *testing.T) {
+	upstream := newFakeUpstream(t, func(net.Conn) {})
+
+	proxy := newTestProxyWithClients(t, newTestClientConfig(upstream.Address()))
+	proxy.AvailableConnections = pool.NewPool(context.Background(), 2)
+	proxy.MinPoolSize = 0
+	proxy.MaxPoolSize = 2
+	proxy.MaxConcurrentDials = 1
+	proxy.dials = make(chan struct{}, proxy.MaxConcurrentDials)
+
+	rejected := testutil.ToFloat64(metrics.PoolRejectedDials)
+
+	// Another server connection is being dialed, so the connection isn't dialed for.
+	proxy.dials <- struct{}{}
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	assert.Equal(t, gerr.ErrPoolExhausted, proxy.Connect(conn))
+	assert.Equal(t, int32(0), proxy.grown.Load())
+	assert.Equal(t, rejected+1, testutil.ToFloat64(metrics.PoolRejectedDials))
+
+	// The connection waits for the other dial to finish.
+	proxy.DialWaitTimeout = time.Second
+	time.AfterFunc(50*time.Millisecond, proxy.releaseDial)
+	require.Nil(t, proxy.Connect(conn))
+	assert.Equal(t, int32(1), proxy.grown.Load())
+	assert.Empty(t, proxy.dials)
+	assert.Equal(t, rejected+1, testutil.ToFloat64(metrics.PoolRejectedDials))
+}
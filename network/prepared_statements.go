@@ -0,0 +1,214 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+const (
+	// PostgreSQL frontend message types used for tracking prepared statements.
+	pgParse = 'P'
+	pgClose = 'C'
+	pgSync  = 'S'
+
+	// pgCloseStatement is the kind of object closed by a Close message.
+	pgCloseStatement = 'S'
+
+	// pgErrorResponse is the type of the ErrorResponse backend message.
+	pgErrorResponse = 'E'
+
+	// pgHeaderLength is the length of the message type and message length.
+	pgHeaderLength = 5
+)
+
+var errReplayStatements = errors.New("failed to prepare the statements of the session again")
+
+// PreparedStatements tracks the named prepared statements of a client session,
+// so that they can be prepared again on another server connection.
+type PreparedStatements struct {
+	statements map[string][]byte
+	mu         sync.RWMutex
+}
+
+// NewPreparedStatements creates a new prepared statements tracker.
+func NewPreparedStatements() *PreparedStatements {
+	return &PreparedStatements{
+		statements: map[string][]byte{},
+	}
+}
+
+// Track records the Parse messages and forgets the statements closed by the Close
+// messages in the given client request. A request may contain multiple messages.
+func (ps *PreparedStatements) Track(request []byte) {
+	if ps == nil {
+		return
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for offset := 0; offset+pgHeaderLength <= len(request); {
+		msgType := request[offset]
+		length := int(binary.BigEndian.Uint32(request[offset+1 : offset+pgHeaderLength]))
+		end := offset + 1 + length
+		if length < 4 || end > len(request) {
+			// Not a complete message, e.g. a startup message.
+			return
+		}
+
+		body := request[offset+pgHeaderLength : end]
+		switch msgType {
+		case pgParse:
+			if name := cString(body); name != "" {
+				statement := make([]byte, end-offset)
+				copy(statement, request[offset:end])
+				ps.statements[name] = statement
+			}
+		case pgClose:
+			if len(body) > 1 && body[0] == pgCloseStatement {
+				delete(ps.statements, cString(body[1:]))
+			}
+		}
+
+		offset = end
+	}
+}
+
+// Has returns true if the prepared statement is tracked.
+func (ps *PreparedStatements) Has(name string) bool {
+	if ps == nil {
+		return false
+	}
+
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	_, ok := ps.statements[name]
+	return ok
+}
+
+// Query returns the query of the tracked prepared statement, as in its Parse message.
+func (ps *PreparedStatements) Query(name string) (string, bool) {
+	if ps == nil {
+		return "", false
+	}
+
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	statement, ok := ps.statements[name]
+	if !ok {
+		return "", false
+	}
+	// The name of the prepared statement is followed by the query.
+	body := statement[pgHeaderLength:]
+	return cString(body[min(len(name)+1, len(body)):]), true
+}
+
+// Size returns the number of tracked prepared statements.
+func (ps *PreparedStatements) Size() int {
+	if ps == nil {
+		return 0
+	}
+
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return len(ps.statements)
+}
+
+// Replay returns the Parse messages of all the tracked prepared statements, except for
+// the ones prepared by the given request, e.g. the request being retried, followed by
+// a Sync message, to be sent to a new server connection. The server responds with a
+// ParseComplete message per statement and a ReadyForQuery message, which must not be
+// relayed to the client.
+func (ps *PreparedStatements) Replay(except []byte) []byte {
+	if ps.Size() == 0 {
+		return nil
+	}
+
+	excluded := NewPreparedStatements()
+	excluded.Track(except)
+
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	replay := make([]byte, 0)
+	for name, statement := range ps.statements {
+		if !excluded.Has(name) {
+			replay = append(replay, statement...)
+		}
+	}
+	if len(replay) == 0 {
+		return nil
+	}
+	return append(replay, pgSync, 0, 0, 0, 4)
+}
+
+// Clear forgets all the tracked prepared statements.
+func (ps *PreparedStatements) Clear() {
+	if ps == nil {
+		return
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.statements = map[string][]byte{}
+}
+
+// replayPreparedStatements prepares the prepared statements of the session of the
+// client connection again on the server connection it moved to, except for the ones
+// prepared by the given request, which is sent next, and discards the responses of
+// the server. It fails if the server doesn't prepare all of them, since the session
+// can't continue without them.
+func (pr *Proxy) replayPreparedStatements(conn *ConnWrapper, client IClient, except []byte) error {
+	replay := conn.PreparedStatements().Replay(except)
+	if replay == nil {
+		return nil
+	}
+
+	if _, err := pr.sendTrafficToServer(withLabels(pr.Logger, conn), client, replay); err != nil {
+		return errors.Join(errReplayStatements, err)
+	}
+	response := []byte{}
+	for {
+		_, received, err := pr.receiveTrafficFromServer(client)
+		if err != nil {
+			return errors.Join(errReplayStatements, err)
+		}
+		response = append(response, received...)
+		if _, ok := GetTxStatus(response); ok {
+			break
+		}
+	}
+	if hasMessage(response, pgErrorResponse) {
+		return errReplayStatements
+	}
+
+	pr.Logger.Debug().Str("remote", RemoteAddr(conn.Conn())).Msg(
+		"Prepared the statements of the session again on its new server connection")
+	return nil
+}
+
+// hasMessage returns true if the messages have a message of the type.
+func hasMessage(messages []byte, msgType byte) bool {
+	for offset := 0; offset+pgHeaderLength <= len(messages); {
+		length := int(binary.BigEndian.Uint32(messages[offset+1 : offset+pgHeaderLength]))
+		if length < 4 || offset+1+length > len(messages) {
+			return false
+		}
+		if messages[offset] == msgType {
+			return true
+		}
+		offset += 1 + length
+	}
+	return false
+}
+
+// cString returns the null-terminated string at the beginning of data.
+func cString(data []byte) string {
+	for idx, b := range data {
+		if b == 0 {
+			return string(data[:idx])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,126 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPreparedStatements tests tracking the prepared statements of a session.
+func TestPreparedStatements(t *testing.T) {
+	statements := NewPreparedStatements()
+
+	parse, err := (&pgproto3.Parse{Name: "stmt1", Query: "SELECT $1"}).Encode(nil)
+	require.NoError(t, err)
+	unnamed, err := (&pgproto3.Parse{Query: "SELECT 1"}).Encode(nil)
+	require.NoError(t, err)
+	sync, err := (&pgproto3.Sync{}).Encode(nil)
+	require.NoError(t, err)
+
+	// Multiple messages in a single request, and unnamed statements are ignored.
+	request := append(append(append([]byte{}, parse...), unnamed...), sync...)
+	statements.Track(request)
+	assert.Equal(t, 1, statements.Size())
+	assert.True(t, statements.Has("stmt1"))
+
+	// Startup and SSL requests are ignored.
+	statements.Track(CreatePgStartupPacket())
+	statements.Track([]byte{0x00, 0x00, 0x00, 0x8, 0x04, 0xd2, 0x16, 0x2f})
+	assert.Equal(t, 1, statements.Size())
+
+	// The replay contains the Parse message followed by a Sync message.
+	assert.Equal(t, append(append([]byte{}, parse...), sync...), statements.Replay(nil))
+	// The statements prepared by the request that's sent next aren't replayed.
+	assert.Nil(t, statements.Replay(request))
+
+	// Closing a portal with the same name doesn't close the statement.
+	closePortal, err := (&pgproto3.Close{ObjectType: 'P', Name: "stmt1"}).Encode(nil)
+	require.NoError(t, err)
+	statements.Track(closePortal)
+	assert.True(t, statements.Has("stmt1"))
+
+	closeStatement, err := (&pgproto3.Close{ObjectType: 'S', Name: "stmt1"}).Encode(nil)
+	require.NoError(t, err)
+	statements.Track(closeStatement)
+	assert.False(t, statements.Has("stmt1"))
+	assert.Nil(t, statements.Replay(nil))
+
+	statements.Track(parse)
+	statements.Clear()
+	assert.Equal(t, 0, statements.Size())
+}
+
+// TestSessionHandoffPreparedStatements tests preparing a statement on a server
+// connection, and executing it on another one after the session is handed off to it.
+func TestSessionHandoffPreparedStatements(t *testing.T) {
+	first := newFakeUpstream(t, func(net.Conn) {})
+	received := make(chan []byte, 3)
+	second := newFakeUpstream(t, func(conn net.Conn) {
+		ready, _ := (&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)}).Encode(nil)
+		authenticated, _ := (&pgproto3.AuthenticationOk{}).Encode(nil)
+		prepared, _ := (&pgproto3.ParseComplete{}).Encode(nil)
+		for _, response := range [][]byte{append(authenticated, ready...), append(prepared, ready...), nil} {
+			buf := make([]byte, 1024)
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			received <- buf[:n]
+			if response != nil {
+				_, _ = conn.Write(response)
+			}
+		}
+	})
+
+	clientConfig := newTestClientConfig(first.Address())
+	clientConfig.Backends = []config.Backend{
+		{Network: "tcp", Address: first.Address()},
+		{Network: "tcp", Address: second.Address()},
+	}
+	firstClient := NewClient(context.Background(), clientConfig.GetBackend(0), zerolog.Nop(), nil)
+	require.NotNil(t, firstClient)
+	proxy := newTestProxyWithClients(t, clientConfig, firstClient)
+	proxy.SessionHandoff = true
+	proxy.HandoffSetupRequests = 1
+
+	prepare, err := (&pgproto3.Parse{Name: "stmt1", Query: "SELECT $1"}).Encode(nil)
+	require.NoError(t, err)
+	prepare, err = (&pgproto3.Sync{}).Encode(prepare)
+	require.NoError(t, err)
+	execute, err := (&pgproto3.Bind{PreparedStatement: "stmt1", Parameters: [][]byte{[]byte("1")}}).Encode(nil)
+	require.NoError(t, err)
+	execute, err = (&pgproto3.Execute{}).Encode(execute)
+	require.NoError(t, err)
+	execute, err = (&pgproto3.Sync{}).Encode(execute)
+	require.NoError(t, err)
+
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn(prepare, execute)})
+	require.Nil(t, proxy.Connect(conn))
+	startup := CreatePgStartupPacket()
+	conn.RecordSessionSetup(startup, proxy.HandoffSetupRequests)
+
+	// The statement is prepared on the first backend.
+	stack := NewStack()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	assert.True(t, conn.PreparedStatements().Has("stmt1"))
+
+	// The session is handed off to the second backend before the statement is executed,
+	// and the statement is prepared there again first.
+	require.True(t, proxy.DrainBackend("tcp://"+first.Address(), true))
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	client, ok := proxy.busyConnections.Get(conn).(IClient)
+	require.True(t, ok)
+	assert.Equal(t, second.Address(), client.GetAddress())
+
+	assert.Equal(t, startup, <-received)
+	assert.Equal(t, prepare, <-received)
+	assert.Equal(t, execute, <-received)
+
+	require.Nil(t, proxy.Disconnect(conn))
+}
@@ -0,0 +1,239 @@
+package network
+
+import (
+	"container/heap"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/spf13/cast"
+)
+
+// PriorityField is the field of the OnOpening hook result, in which the plugins return
+// the priority of the connection for getting a server connection when the pool is exhausted.
+const PriorityField = "priority"
+
+// GetPriority returns the priority in the result of a hook, clamped to the priorities
+// from config.MinConnectionPriority to config.MaxConnectionPriority, or false if the
+// result has no priority.
+func GetPriority(result map[string]interface{}) (int, bool) {
+	value, ok := result[PriorityField]
+	if !ok {
+		return 0, false
+	}
+	priority, err := cast.ToIntE(value)
+	if err != nil {
+		return 0, false
+	}
+	return min(max(priority, config.MinConnectionPriority), config.MaxConnectionPriority), true
+}
+
+// priorityNetwork is the priority of the connections of the clients in the network.
+type priorityNetwork struct {
+	network  *net.IPNet
+	priority int
+}
+
+// parseClientPriorities parses the networks of the client priorities, and returns the
+// invalid IP addresses and CIDR ranges.
+func parseClientPriorities(priorities []config.ClientPriority) ([]priorityNetwork, []string) {
+	var networks []priorityNetwork
+	var invalid []string
+	for _, clientPriority := range priorities {
+		parsed, invalidClients := ParseCaptureClients(clientPriority.Clients)
+		invalid = append(invalid, invalidClients...)
+		for _, network := range parsed {
+			networks = append(networks, priorityNetwork{network: network, priority: clientPriority.Priority})
+		}
+	}
+	return networks, invalid
+}
+
+// connectionPriority returns the priority of the connection set by the OnOpening hooks,
+// or else the priority of its client in the client priorities, or else the lowest one.
+func (pr *Proxy) connectionPriority(conn *ConnWrapper) int {
+	if priority, ok := conn.Priority(); ok {
+		return priority
+	}
+	if len(pr.priorityNetworks) == 0 {
+		return config.MinConnectionPriority
+	}
+
+	host, _, err := net.SplitHostPort(RemoteAddr(conn.Conn()))
+	if err != nil {
+		return config.MinConnectionPriority
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return config.MinConnectionPriority
+	}
+	for _, network := range pr.priorityNetworks {
+		if network.network.Contains(ip) {
+			return network.priority
+		}
+	}
+	return config.MinConnectionPriority
+}
+
+// waitForClient waits for a server connection to be released to the exhausted pool,
+// up to the acquire timeout, and returns false if it times out. The waiting connections
+// are woken by priority, and in the order they arrived within a priority.
+func (pr *Proxy) waitForClient(conn *ConnWrapper) bool {
+	if pr.AcquireTimeout <= 0 || pr.acquireQueue == nil {
+		return false
+	}
+
+	priority := pr.connectionPriority(conn)
+	class := strconv.Itoa(priority)
+	start := time.Now()
+	timeout := time.NewTimer(pr.AcquireTimeout)
+	defer timeout.Stop()
+
+	waiter := pr.acquireQueue.Push(priority)
+	defer pr.acquireQueue.Remove(waiter)
+	// The pool is checked after the waiter is queued, so that a server connection
+	// released in between isn't missed.
+	for pr.IsExhausted() {
+		select {
+		case <-waiter.ready:
+			// Another connection may have taken the server connection first,
+			// so the waiter is queued again, ahead of the later ones.
+			pr.acquireQueue.Requeue(waiter)
+		case <-timeout.C:
+			// Pass the wakeup on to the next waiter, if the waiter was woken meanwhile.
+			pr.acquireQueue.Remove(waiter)
+			select {
+			case <-waiter.ready:
+				pr.clientReleased()
+			default:
+			}
+			metrics.PoolAcquireWait.WithLabelValues(class).Observe(time.Since(start).Seconds())
+			metrics.PoolAcquireTimeouts.WithLabelValues(class).Inc()
+			return false
+		}
+	}
+
+	metrics.PoolAcquireWait.WithLabelValues(class).Observe(time.Since(start).Seconds())
+	return true
+}
+
+// clientReleased wakes the connection with the highest priority waiting for
+// a server connection, if any, after a server connection is put in the pool.
+func (pr *Proxy) clientReleased() {
+	pr.acquireQueue.Pop()
+}
+
+// acquireWaiter is a connection waiting for a server connection.
+type acquireWaiter struct {
+	priority int
+	// sequence is the order of the waiter's arrival, for the waiters of the same priority.
+	sequence uint64
+	// index is the index of the waiter in the queue, or -1 if it isn't queued.
+	index int
+	ready chan struct{}
+}
+
+// AcquireQueue is the priority queue of the connections waiting for a server connection
+// when the pool is exhausted. The waiters with the highest priority are woken first,
+// and the waiters of the same priority in the order they arrived.
+type AcquireQueue struct {
+	waiters  acquireWaiters
+	sequence uint64
+	mu       sync.Mutex
+}
+
+// NewAcquireQueue creates a new empty acquire queue.
+func NewAcquireQueue() *AcquireQueue {
+	return &AcquireQueue{}
+}
+
+// Push queues a new waiter with the priority.
+func (q *AcquireQueue) Push(priority int) *acquireWaiter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.sequence++
+	waiter := &acquireWaiter{priority: priority, sequence: q.sequence, index: -1, ready: make(chan struct{}, 1)}
+	heap.Push(&q.waiters, waiter)
+	return waiter
+}
+
+// Requeue queues the woken waiter again, with its original place in the queue.
+func (q *AcquireQueue) Requeue(waiter *acquireWaiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if waiter.index < 0 {
+		heap.Push(&q.waiters, waiter)
+	}
+}
+
+// Remove removes the waiter from the queue, if it's still queued.
+func (q *AcquireQueue) Remove(waiter *acquireWaiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if waiter.index >= 0 {
+		heap.Remove(&q.waiters, waiter.index)
+	}
+}
+
+// Pop wakes the waiter with the highest priority and removes it from the queue,
+// if any. A nil queue has no waiters.
+func (q *AcquireQueue) Pop() {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.waiters.Len() == 0 {
+		return
+	}
+	waiter, _ := heap.Pop(&q.waiters).(*acquireWaiter)
+	waiter.ready <- struct{}{}
+}
+
+// Len returns the number of the waiters in the queue.
+func (q *AcquireQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.waiters.Len()
+}
+
+// acquireWaiters implements heap.Interface, with the waiter to wake first at the top.
+type acquireWaiters []*acquireWaiter
+
+func (w acquireWaiters) Len() int { return len(w) }
+
+func (w acquireWaiters) Less(i, j int) bool {
+	if w[i].priority != w[j].priority {
+		return w[i].priority > w[j].priority
+	}
+	return w[i].sequence < w[j].sequence
+}
+
+func (w acquireWaiters) Swap(i, j int) {
+	w[i], w[j] = w[j], w[i]
+	w[i].index = i
+	w[j].index = j
+}
+
+func (w *acquireWaiters) Push(x any) {
+	waiter, _ := x.(*acquireWaiter)
+	waiter.index = len(*w)
+	*w = append(*w, waiter)
+}
+
+func (w *acquireWaiters) Pop() any {
+	old := *w
+	waiter := old[len(old)-1]
+	old[len(old)-1] = nil
+	waiter.index = -1
+	*w = old[:len(old)-1]
+	return waiter
+}
@@ -0,0 +1,152 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/gatewayd-io/gatewayd/pool"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetPriority tests getting the priority of the connection from the result of a hook.
+func TestGetPriority(t *testing.T) {
+	priority, ok := GetPriority(map[string]interface{}{PriorityField: 5})
+	assert.True(t, ok)
+	assert.Equal(t, 5, priority)
+
+	priority, ok = GetPriority(map[string]interface{}{PriorityField: "7"})
+	assert.True(t, ok)
+	assert.Equal(t, 7, priority)
+
+	// The priorities are clamped to the priority classes.
+	priority, _ = GetPriority(map[string]interface{}{PriorityField: 100})
+	assert.Equal(t, config.MaxConnectionPriority, priority)
+	priority, _ = GetPriority(map[string]interface{}{PriorityField: -1})
+	assert.Equal(t, config.MinConnectionPriority, priority)
+
+	_, ok = GetPriority(map[string]interface{}{PriorityField: "high"})
+	assert.False(t, ok)
+	_, ok = GetPriority(nil)
+	assert.False(t, ok)
+}
+
+// TestAcquireQueue tests waking the waiters by priority, and in the order they
+// arrived within a priority.
+func TestAcquireQueue(t *testing.T) {
+	queue := NewAcquireQueue()
+	low := queue.Push(0)
+	first := queue.Push(5)
+	second := queue.Push(5)
+	high := queue.Push(9)
+	removed := queue.Push(9)
+	queue.Remove(removed)
+	assert.Equal(t, 4, queue.Len())
+
+	for _, waiter := range []*acquireWaiter{high, first, second, low} {
+		queue.Pop()
+		select {
+		case <-waiter.ready:
+		default:
+			t.Fatalf("the waiter of priority %d isn't woken", waiter.priority)
+		}
+	}
+	assert.Equal(t, 0, queue.Len())
+	queue.Pop() // no waiters
+
+	// A requeued waiter keeps its place in the queue.
+	later := queue.Push(5)
+	queue.Requeue(first)
+	queue.Pop()
+	assert.Len(t, first.ready, 1)
+	assert.Empty(t, later.ready)
+}
+
+// TestProxyAcquirePriority tests that the connections waiting for a server connection
+// of the exhausted pool get it by priority, or are rejected after the acquire timeout.
+func TestProxyAcquirePriority(t *testing.T) {
+	upstream := newFakeUpstream(t, func(net.Conn) {})
+	proxy := newTestProxy(t, upstream.Address())
+	proxy.AcquireTimeout = 5 * time.Second
+	proxy.priorityNetworks, _ = parseClientPriorities([]config.ClientPriority{
+		{Clients: []string{"10.0.0.0/8"}, Priority: 3},
+	})
+
+	// Cap the pool at its single server connection, so that it can be exhausted.
+	capped := pool.NewPool(context.Background(), 1)
+	proxy.AvailableConnections.ForEach(func(key, value interface{}) bool {
+		require.Nil(t, capped.Put(key, value))
+		return true
+	})
+	proxy.AvailableConnections = capped
+
+	busy := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(busy))
+
+	connected := make(chan *ConnWrapper, 2)
+	connect := func(conn *ConnWrapper) {
+		if err := proxy.Connect(conn); err == nil {
+			connected <- conn
+		}
+	}
+
+	low := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	go connect(low)
+	require.Eventually(t, func() bool { return proxy.acquireQueue.Len() == 1 }, time.Second, time.Millisecond)
+	high := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	high.SetPriority(config.MaxConnectionPriority)
+	go connect(high)
+	require.Eventually(t, func() bool { return proxy.acquireQueue.Len() == 2 }, time.Second, time.Millisecond)
+
+	// The connection with the higher priority gets the released server connection first.
+	require.Nil(t, proxy.Disconnect(busy))
+	assert.Equal(t, high, <-connected)
+	require.Nil(t, proxy.Disconnect(high))
+	assert.Equal(t, low, <-connected)
+
+	// The connections are rejected after waiting for the acquire timeout.
+	proxy.AcquireTimeout = 10 * time.Millisecond
+	timeouts := testutil.ToFloat64(metrics.PoolAcquireTimeouts.WithLabelValues("0"))
+	assert.ErrorIs(t, proxy.Connect(NewConnWrapper(ConnWrapper{NetConn: newMockConn()})), gerr.ErrPoolExhausted)
+	assert.Equal(t, timeouts+1, testutil.ToFloat64(metrics.PoolAcquireTimeouts.WithLabelValues("0")))
+	assert.Equal(t, 0, proxy.acquireQueue.Len())
+}
+
+// TestConnectionPriority tests the priority of the connections by their clients,
+// unless the plugins set it.
+func TestConnectionPriority(t *testing.T) {
+	proxy := &Proxy{Logger: zerolog.Nop()}
+	proxy.priorityNetworks, _ = parseClientPriorities([]config.ClientPriority{
+		{Clients: []string{"10.0.0.0/8", "192.168.1.1"}, Priority: 3},
+	})
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	conn := NewConnWrapper(ConnWrapper{NetConn: &remoteAddrConn{Conn: server, addr: "10.1.2.3:5432"}})
+	assert.Equal(t, 3, proxy.connectionPriority(conn))
+
+	conn.SetPriority(8)
+	assert.Equal(t, 8, proxy.connectionPriority(conn))
+
+	other := NewConnWrapper(ConnWrapper{NetConn: &remoteAddrConn{Conn: server, addr: "172.16.0.1:5432"}})
+	assert.Equal(t, config.MinConnectionPriority, proxy.connectionPriority(other))
+}
+
+// remoteAddrConn is a connection with the given remote address.
+type remoteAddrConn struct {
+	net.Conn
+	addr string
+}
+
+func (c *remoteAddrConn) RemoteAddr() net.Addr {
+	addr, _ := net.ResolveTCPAddr("tcp", c.addr)
+	return addr
+}
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"errors"
-	"io"
+	"math/rand"
 	"net"
 	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	sdkAct "github.com/gatewayd-io/gatewayd-plugin-sdk/act"
+	"github.com/gatewayd-io/gatewayd-plugin-sdk/databases/postgres"
 	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
 	"github.com/gatewayd-io/gatewayd/act"
 	"github.com/gatewayd-io/gatewayd/config"
@@ -22,6 +25,8 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/spf13/cast"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/maps"
 )
 
@@ -30,11 +35,24 @@ type IProxy interface {
 	Disconnect(conn *ConnWrapper) *gerr.GatewayDError
 	PassThroughToServer(conn *ConnWrapper, stack *Stack) *gerr.GatewayDError
 	PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.GatewayDError
-	IsHealthy(cl *Client) (*Client, *gerr.GatewayDError)
+	IsHealthy(cl IClient) (IClient, *gerr.GatewayDError)
 	IsExhausted() bool
 	Shutdown()
 	AvailableConnectionsString() []string
 	BusyConnectionsString() []string
+	CloseIdleConnections(idleTimeout time.Duration) int
+	SendKeepAlives(payload []byte, idle time.Duration) int
+	PoolSize() int
+	BackendAddresses() []string
+	BackendStatus() map[string]string
+	HasHealthyBackend() bool
+	Routing(conn *ConnWrapper) map[string]interface{}
+	DrainBackend(backend string, drain bool) bool
+	DrainingBackends() map[string]int
+	SetReadOnly(readOnly bool) bool
+	IsReadOnly() bool
+	SetCapture(client string, capture bool) bool
+	Captures() map[string]string
 }
 
 type Proxy struct {
@@ -46,13 +64,128 @@ type Proxy struct {
 	ctx                  context.Context //nolint:containedctx
 	PluginTimeout        time.Duration
 	HealthCheckPeriod    time.Duration
+	// HealthCheckJitter is the fraction of the health check period over which
+	// the recycling of the clients is spread.
+	HealthCheckJitter float64
+	// CloseOnEmptyRequest closes the client connection on an empty request.
+	CloseOnEmptyRequest bool
+	// ServerVersion is the server version advertised to the clients, if set,
+	// instead of the version of the server.
+	ServerVersion string
 
 	// ClientConfig is used for reconnection
 	ClientConfig *config.Client
+	// RetryBudget is shared by all the retries of the proxy's clients.
+	RetryBudget *RetryBudget
+	// ReconnectLimiter caps the concurrent reconnections of the proxy's clients.
+	ReconnectLimiter *ReconnectLimiter
+	// Authenticator authenticates the clients, if set.
+	Authenticator IAuthenticator
+	// MaxPayloadSize is the largest request or response the plugins can return.
+	MaxPayloadSize int
+	// MaxHookPayloadSize is the largest request or response sent to the plugins as is,
+	// or 0 for no limit. The larger ones are truncated.
+	MaxHookPayloadSize int
+	// RequestVerifier verifies the request modified by the plugins, after all the
+	// plugins ran, if set. The original request is forwarded if it returns an error.
+	RequestVerifier RequestVerifier
+	// CaptureClients are the IP addresses and CIDR ranges of the clients whose
+	// connections are captured to the capture directory for debugging.
+	CaptureClients []string
+	CaptureDir     string
+	// CaptureMaxSize is the max size of the capture file of a connection.
+	CaptureMaxSize int64
+	// LameDuckPeriod is how long a drained backend is kept in lame duck after its
+	// last server connection is recycled.
+	LameDuckPeriod time.Duration
+	// RetryOnReset retries the request on a new server connection once,
+	// if the server closed or reset the connection before responding.
+	RetryOnReset bool
+	// PoolEvents runs the OnPoolAcquire and OnPoolRelease hooks on every connection.
+	PoolEvents bool
+	// LoadBalanceHook runs the OnLoadBalance hooks on every connection, which choose
+	// the backend of its server connection.
+	LoadBalanceHook bool
+	// TestOnBorrow validates the server connections taken from the pool before they're
+	// handed out, and replaces the ones that fail.
+	TestOnBorrow bool
+	// TestOnReturn validates the server connections before they're put back in the pool,
+	// and replaces the ones that fail.
+	TestOnReturn bool
+	// AcquireTimeout is how long the connections wait for a server connection when the
+	// pool is exhausted, by priority, or 0 to reject them right away.
+	AcquireTimeout time.Duration
+	// ClientPriorities are the priorities of the connections by their clients.
+	ClientPriorities []config.ClientPriority
+	// SessionHandoff hands the sessions off to another backend between the requests,
+	// when their backend is drained or down.
+	SessionHandoff bool
+	// HandoffSetupRequests is the number of the first requests of the sessions that
+	// are replayed on handoff.
+	HandoffSetupRequests int
+	// MinPoolSize is the number of the server connections preallocated in the pool, and
+	// the pool grows lazily under load up to MaxPoolSize, if it's above it. The server
+	// connections above MinPoolSize are closed after they're idle for PoolIdleTimeout.
+	MinPoolSize     int
+	MaxPoolSize     int
+	PoolIdleTimeout time.Duration
+	// MaxConcurrentDials is the max number of the server connections dialed at once to
+	// grow the pool, or 0 for no limit. The connections beyond it wait for a dial for
+	// DialWaitTimeout, and then for a server connection put back in the pool.
+	MaxConcurrentDials int
+	DialWaitTimeout    time.Duration
+	// InFlightTimeout is how long the requests wait for their backend to be below its
+	// max in-flight requests, or 0 to wait until it is.
+	InFlightTimeout time.Duration
+	// CertificateRoutes route the connections by their verified client certificates.
+	CertificateRoutes []config.CertificateRoute
+
+	// cancelKeys translates the backend keys of the sessions for the cancel requests.
+	cancelKeys *CancelKeys
+	// backendHealth keeps track of the backends that are down, for failing over,
+	// and of the backends that are drained.
+	backendHealth *BackendHealth
+	// standby are the warm standby connections of the backends, for failing over.
+	standby *StandbyPool
+	// backends are the backends of the client config, which can be changed at
+	// runtime by the plugins.
+	backends   []config.Backend
+	backendsMu *sync.RWMutex
+	// labelValues bounds the values of the labels in the metrics.
+	labelValues *metrics.LabelValueLimiter
+	// readOnly rejects the writes to the database, e.g. for maintenance.
+	readOnly *atomic.Bool
+	// captureNetworks are the parsed capture clients.
+	captureNetworks []*net.IPNet
+	// activeCaptures is the number of the captured connections.
+	activeCaptures *atomic.Int32
+	// acquired is when the server connections of the connections were acquired,
+	// for the pool events.
+	acquired *sync.Map
+	// balanced are the connections assigned to the backends chosen by the plugins or
+	// by their client certificates, with the strategy of the assignment.
+	balanced *sync.Map
+	// priorityNetworks are the parsed client priorities.
+	priorityNetworks []priorityNetwork
+	// acquireQueue are the connections waiting for a server connection by priority.
+	acquireQueue *AcquireQueue
+	// grown is the number of the server connections the pool grew by above its min size.
+	grown *atomic.Int32
+	// dials are the slots of the server connections being dialed to grow the pool, if
+	// MaxConcurrentDials is set.
+	dials chan struct{}
+	// idleSince is when the server connections were put back in the pool, by their IDs.
+	idleSince *sync.Map
+	// inFlight caps the requests in flight to the backends with a max in-flight requests.
+	inFlight *InFlightLimiter
 }
 
 var _ IProxy = (*Proxy)(nil)
 
+// RequestVerifier verifies the request modified by the plugins against the original
+// request, e.g. VerifyMessageTypes, and returns an error to reject the modification.
+type RequestVerifier func(modified, original []byte) error
+
 // NewProxy creates a new proxy.
 func NewProxy(
 	ctx context.Context,
@@ -70,49 +203,115 @@ func NewProxy(
 		ctx:                  proxyCtx,
 		PluginTimeout:        pxy.PluginTimeout,
 		ClientConfig:         pxy.ClientConfig,
+		RetryBudget:          pxy.RetryBudget,
+		ReconnectLimiter:     pxy.ReconnectLimiter,
+		Authenticator:        pxy.Authenticator,
+		MaxPayloadSize:       pxy.MaxPayloadSize,
+		MaxHookPayloadSize:   pxy.MaxHookPayloadSize,
+		RequestVerifier:      pxy.RequestVerifier,
 		HealthCheckPeriod:    pxy.HealthCheckPeriod,
+		HealthCheckJitter:    pxy.HealthCheckJitter,
+		CloseOnEmptyRequest:  pxy.CloseOnEmptyRequest,
+		ServerVersion:        pxy.ServerVersion,
+		CaptureClients:       pxy.CaptureClients,
+		CaptureDir:           config.If(pxy.CaptureDir != "", pxy.CaptureDir, defaultCaptureDir()),
+		CaptureMaxSize:       config.If(pxy.CaptureMaxSize > 0, pxy.CaptureMaxSize, config.DefaultCaptureMaxSize),
+		LameDuckPeriod:       pxy.LameDuckPeriod,
+		RetryOnReset:         pxy.RetryOnReset,
+		PoolEvents:           pxy.PoolEvents,
+		LoadBalanceHook:      pxy.LoadBalanceHook,
+		TestOnBorrow:         pxy.TestOnBorrow,
+		TestOnReturn:         pxy.TestOnReturn,
+		AcquireTimeout:       pxy.AcquireTimeout,
+		ClientPriorities:     pxy.ClientPriorities,
+		SessionHandoff:       pxy.SessionHandoff,
+		HandoffSetupRequests: pxy.HandoffSetupRequests,
+		MinPoolSize:          pxy.MinPoolSize,
+		MaxPoolSize:          pxy.MaxPoolSize,
+		PoolIdleTimeout:      config.If(pxy.PoolIdleTimeout > 0, pxy.PoolIdleTimeout, config.DefaultPoolIdleTimeout),
+		MaxConcurrentDials:   pxy.MaxConcurrentDials,
+		DialWaitTimeout:      pxy.DialWaitTimeout,
+		InFlightTimeout:      pxy.InFlightTimeout,
+		CertificateRoutes:    pxy.CertificateRoutes,
+		cancelKeys:           NewCancelKeys(),
+		backendHealth:        NewBackendHealth(),
+		standby:              NewStandbyPool(),
+		backendsMu:           &sync.RWMutex{},
+		labelValues:          metrics.NewLabelValueLimiter(config.DefaultMaxLabelValues),
+		readOnly:             &atomic.Bool{},
+		activeCaptures:       &atomic.Int32{},
+		acquired:             &sync.Map{},
+		balanced:             &sync.Map{},
+		acquireQueue:         NewAcquireQueue(),
+		grown:                &atomic.Int32{},
+		idleSince:            &sync.Map{},
+		inFlight:             NewInFlightLimiter(),
+	}
+
+	// The client config is needed for reading the requests and reconnecting, so
+	// the proxy rejects all the clients without it, instead of failing on them.
+	if proxy.ClientConfig != nil {
+		proxy.backends = slices.Clone(proxy.ClientConfig.Backends)
+	} else {
+		proxy.Logger.Error().Msg("Proxy has no client config, all the clients will be rejected")
+	}
+
+	captureNetworks, invalid := ParseCaptureClients(proxy.CaptureClients)
+	proxy.captureNetworks = captureNetworks
+	if len(invalid) > 0 {
+		proxy.Logger.Error().Strs("clients", invalid).Msg(
+			"Ignoring the invalid IP addresses and CIDR ranges of the captured clients")
+	}
+
+	if proxy.MaxConcurrentDials > 0 {
+		proxy.dials = make(chan struct{}, proxy.MaxConcurrentDials)
+	}
+
+	priorityNetworks, invalid := parseClientPriorities(proxy.ClientPriorities)
+	proxy.priorityNetworks = priorityNetworks
+	if len(invalid) > 0 {
+		proxy.Logger.Error().Strs("clients", invalid).Msg(
+			"Ignoring the invalid IP addresses and CIDR ranges of the client priorities")
 	}
 
+	// Connect the warm standby connections of the backends right away, so that
+	// they're ready for a failover before the first health check.
+	proxy.checkStandby()
+
 	startDelay := time.Now().Add(proxy.HealthCheckPeriod)
 	// Schedule the client health check.
 	if _, err := proxy.scheduler.Every(proxy.HealthCheckPeriod).SingletonMode().StartAt(startDelay).Do(
 		func() {
 			now := time.Now()
 			proxy.Logger.Trace().Msg("Running the client health check to recycle connection(s).")
+			proxy.checkBackends()
+			proxy.syncRoutingTable()
+			// Don't recycle the clients while all the backends are down, since their
+			// new connections would fail too.
+			if !proxy.HasHealthyBackend() {
+				proxy.Logger.Trace().Msg("All backends are down, skipping the recycling of the clients")
+				proxy.checkStandby()
+				metrics.ProxyHealthChecks.Inc()
+				return
+			}
 			proxy.AvailableConnections.ForEach(func(_, value interface{}) bool {
-				if client, ok := value.(*Client); ok {
-					// Connection is probably dead by now.
-					proxy.AvailableConnections.Remove(client.ID)
-					client.Close()
-					// Create a new client.
-					client = NewClient(
-						proxyCtx, proxy.ClientConfig, proxy.Logger,
-						NewRetry(
-							Retry{
-								Retries: proxy.ClientConfig.Retries,
-								Backoff: config.If(
-									proxy.ClientConfig.Backoff > 0,
-									proxy.ClientConfig.Backoff,
-									config.DefaultBackoff,
-								),
-								BackoffMultiplier:  proxy.ClientConfig.BackoffMultiplier,
-								DisableBackoffCaps: proxy.ClientConfig.DisableBackoffCaps,
-								Logger:             proxy.Logger,
-							},
-						),
-					)
-					if client != nil && client.ID != "" {
-						if err := proxy.AvailableConnections.Put(client.ID, client); err != nil {
-							proxy.Logger.Err(err).Msg("Failed to update the client connection")
-							// Close the client, because we don't want to have orphaned connections.
-							client.Close()
-						}
+				if client, ok := value.(IClient); ok {
+					// Spread the recycling of the clients over the jitter, so that
+					// the server doesn't get all the new connections at once.
+					// The clients of the backends that are down or drained are moved right away.
+					backend := proxy.clientConfigOf(client)
+					if delay := proxy.healthCheckDelay(); delay > 0 &&
+						proxy.backendHealth.IsAvailable(backend.Network, backend.Address) {
+						time.AfterFunc(delay, func() { proxy.recycleClient(client) })
 					} else {
-						proxy.Logger.Error().Msg("Failed to create a new client connection")
+						proxy.recycleClient(client)
 					}
 				}
 				return true
 			})
+			// Refill the warm standby connections after the clients that failed
+			// over are promoted to them.
+			proxy.checkStandby()
 			proxy.Logger.Trace().Str("duration", time.Since(now).String()).Msg(
 				"Finished the client health check")
 			metrics.ProxyHealthChecks.Inc()
@@ -123,45 +322,169 @@ func NewProxy(
 		span.RecordError(err)
 	}
 
+	// Schedule closing the idle server connections the pool grew by.
+	if proxy.canGrow() {
+		if _, err := proxy.scheduler.Every(
+			min(proxy.PoolIdleTimeout, config.DefaultIdleCheckInterval),
+		).SingletonMode().Do(proxy.reapIdleClients); err != nil {
+			proxy.Logger.Error().Err(err).Msg("Failed to schedule closing the idle server connections")
+			span.RecordError(err)
+		}
+	}
+
 	// Start the scheduler.
 	proxy.scheduler.StartAsync()
 	proxy.Logger.Info().Fields(
 		map[string]interface{}{
 			"startDelay":        startDelay.Format(time.RFC3339),
 			"healthCheckPeriod": proxy.HealthCheckPeriod.String(),
+			"healthCheckJitter": proxy.HealthCheckJitter,
 		},
 	).Msg("Started the client health check scheduler")
 
 	return &proxy
 }
 
+// healthCheckDelay returns a random delay within the jitter of the health check period.
+func (pr *Proxy) healthCheckDelay() time.Duration {
+	if pr.HealthCheckJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Float64() * pr.HealthCheckJitter * float64(pr.HealthCheckPeriod)) //nolint:gosec
+}
+
+// recycleClient replaces the available client with a new one for the same backend,
+// unless the client is no longer available, e.g. it's assigned to a connection.
+func (pr *Proxy) recycleClient(client IClient) {
+	// Connection is probably dead by now.
+	if pr.AvailableConnections.Pop(client.GetID()) == nil {
+		return
+	}
+	pr.idleSince.Delete(client.GetID())
+	client.Close()
+	pr.replaceClient(client)
+}
+
+// replaceClient puts a new client in the pool in place of the closed client, for
+// the same backend, or for a healthy backend if the client's backend is down or drained,
+// or is above its share of the server connections by weight.
+func (pr *Proxy) replaceClient(client IClient) {
+	if pr.ClientConfig == nil {
+		pr.Logger.Error().Msg("Can't replace the client without the client config of the proxy")
+		return
+	}
+
+	clientConfig := pr.clientConfigOf(client)
+	var newClient IClient
+	if !pr.backendHealth.IsAvailable(clientConfig.Network, clientConfig.Address) {
+		if failover := pr.failoverBackend(); failover != nil {
+			clientConfig = failover
+			// Promote a warm standby connection of the backend, if it has one,
+			// instead of waiting for a new connection.
+			newClient = pr.promoteStandby(failover)
+		}
+	} else {
+		clientConfig = pr.weightedBackend(clientConfig)
+	}
+	if newClient == nil {
+		// Create a new client.
+		if cl := pr.newClient(clientConfig); cl != nil {
+			newClient = cl
+		}
+	}
+	if newClient != nil && newClient.GetID() != "" {
+		if err := pr.AvailableConnections.Put(newClient.GetID(), newClient); err != nil {
+			pr.Logger.Err(err).Msg("Failed to update the client connection")
+			// Close the client, because we don't want to have orphaned connections.
+			newClient.Close()
+		} else {
+			pr.clientReleased()
+		}
+	} else {
+		pr.Logger.Error().Msg("Failed to create a new client connection")
+	}
+}
+
+// newClient creates a new client of the backend with the retries of the proxy.
+func (pr *Proxy) newClient(clientConfig *config.Client) *Client {
+	return NewClient(
+		pr.ctx, clientConfig, pr.Logger,
+		NewRetry(
+			Retry{
+				Retries: pr.ClientConfig.Retries,
+				Backoff: config.If(
+					pr.ClientConfig.Backoff > 0,
+					pr.ClientConfig.Backoff,
+					config.DefaultBackoff,
+				),
+				BackoffMultiplier:  pr.ClientConfig.BackoffMultiplier,
+				DisableBackoffCaps: pr.ClientConfig.DisableBackoffCaps,
+				Jitter:             pr.ClientConfig.BackoffJitter,
+				Logger:             pr.Logger,
+				Budget:             pr.RetryBudget,
+				Limiter:            pr.ReconnectLimiter,
+			},
+		),
+	)
+}
+
 // Connect maps a server connection from the available connection pool to a incoming connection.
 // It returns an error if the pool is exhausted.
 func (pr *Proxy) Connect(conn *ConnWrapper) *gerr.GatewayDError {
 	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "Connect")
 	defer span.End()
 
-	var clientID string
-	// Get the first available client from the pool.
-	pr.AvailableConnections.ForEach(func(key, _ interface{}) bool {
-		if cid, ok := key.(string); ok {
-			clientID = cid
-			return false // stop the loop.
-		}
-		return true
-	})
+	if pr.ClientConfig == nil {
+		span.RecordError(gerr.ErrNilPointer)
+		return gerr.ErrNilPointer
+	}
 
-	var client *Client
-	if pr.IsExhausted() {
-		// Pool is exhausted
-		span.AddEvent(gerr.ErrPoolExhausted.Error())
-		return gerr.ErrPoolExhausted
+	// Reject the connection right away if all the backends are down, instead of
+	// handing out a server connection to a backend that is down.
+	if !pr.HasHealthyBackend() {
+		span.AddEvent(gerr.ErrNoHealthyUpstream.Error())
+		return gerr.ErrNoHealthyUpstream
 	}
-	// Get the client from the pool with the given clientID.
-	if cl, ok := pr.AvailableConnections.Pop(clientID).(*Client); ok {
-		client = cl
+
+	// Let the plugins choose the backend of the server connection, if enabled.
+	backend := pr.loadBalance(conn)
+
+	acquireStart := time.Now()
+	var client IClient
+	for client == nil {
+		// Grow the pool by a new server connection, up to its max size, before waiting.
+		if pr.IsExhausted() {
+			if grown := pr.growPool(); grown != nil {
+				client = grown
+				metrics.PoolAcquisitions.Inc()
+				metrics.PoolAcquireDuration.Observe(time.Since(acquireStart).Seconds())
+				break
+			}
+		}
+
+		// Wait for a server connection if the pool is exhausted, up to the acquire timeout.
+		if pr.IsExhausted() && !pr.waitForClient(conn) {
+			// Pool is exhausted
+			metrics.PoolExhaustedRejections.Inc()
+			span.AddEvent(gerr.ErrPoolExhausted.Error())
+			return gerr.ErrPoolExhausted
+		}
+
+		// Get the client from the pool.
+		if cl, ok := pr.AvailableConnections.Pop(pr.availableClientIDOf(backend)).(IClient); ok {
+			client = cl
+			metrics.PoolAcquisitions.Inc()
+			metrics.PoolAcquireDuration.Observe(time.Since(acquireStart).Seconds())
+		} else if pr.AvailableConnections.Size() == 0 && !pr.IsExhausted() {
+			// The pool has no clients, but isn't exhausted, since it has no capacity.
+			break
+		}
+		// Otherwise, another connection took the client first.
 	}
 
+	// Validate the server connection before handing it out, if enabled.
+	client = pr.testOnBorrow(client)
+
 	client, err := pr.IsHealthy(client)
 	if err != nil {
 		pr.Logger.Error().Err(err).Msg("Failed to connect to the client")
@@ -176,13 +499,34 @@ func (pr *Proxy) Connect(conn *ConnWrapper) *gerr.GatewayDError {
 
 	metrics.ProxiedConnections.Inc()
 
+	if backend != "" && client != nil && client.GetNetwork()+"://"+client.GetAddress() == backend {
+		pr.balanced.Store(conn, RoutingPlugin)
+	}
+
+	if pr.PoolEvents && client != nil {
+		wait := time.Since(acquireStart)
+		pr.acquired.Store(conn, time.Now())
+		span.AddEvent("Acquired the server connection", trace.WithAttributes(
+			attribute.String("client", client.GetID()),
+			attribute.Float64("wait", wait.Seconds()),
+		))
+		pr.poolEvent(plugin.OnPoolAcquireHookName, client, "wait", wait)
+	}
+
+	if pr.shouldCapture(conn.Conn()) {
+		pr.startCapture(conn)
+	}
+
 	fields := map[string]interface{}{
 		"function": "proxy.connect",
 		"client":   "unknown",
 		"server":   RemoteAddr(conn.Conn()),
 	}
-	if client.ID != "" {
-		fields["client"] = client.ID[:7]
+	if client != nil && client.GetID() != "" {
+		fields["client"] = client.GetID()
+	}
+	if routing := pr.Routing(conn); routing != nil {
+		fields["routing"] = routing
 	}
 	pr.Logger.Debug().Fields(fields).Msg("Client has been assigned")
 
@@ -202,12 +546,38 @@ func (pr *Proxy) Connect(conn *ConnWrapper) *gerr.GatewayDError {
 	return nil
 }
 
+// availableClientID returns the ID of the first available client in the pool,
+// preferring the clients of the backends that aren't down or drained.
+func (pr *Proxy) availableClientID() string {
+	var clientID string
+	pr.AvailableConnections.ForEach(func(key, value interface{}) bool {
+		cid, ok := key.(string)
+		if !ok {
+			return true
+		}
+		if clientID == "" {
+			clientID = cid
+		}
+		if client, ok := value.(IClient); ok &&
+			pr.backendHealth.IsAvailable(client.GetNetwork(), client.GetAddress()) {
+			clientID = cid
+			return false // stop the loop.
+		}
+		return true
+	})
+	return clientID
+}
+
 // Disconnect removes the client from the busy connection pool and tries to recycle
 // the server connection.
 func (pr *Proxy) Disconnect(conn *ConnWrapper) *gerr.GatewayDError {
 	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "Disconnect")
 	defer span.End()
 
+	pr.stopCapture(conn)
+	pr.balanced.Delete(conn)
+	pr.inFlight.Release(conn)
+
 	client := pr.busyConnections.Pop(conn)
 	if client == nil {
 		// If this ever happens, it means that the client connection
@@ -217,22 +587,77 @@ func (pr *Proxy) Disconnect(conn *ConnWrapper) *gerr.GatewayDError {
 		return gerr.ErrClientNotFound
 	}
 
-	if client, ok := client.(*Client); ok {
-		// Recycle the server connection by reconnecting.
-		if err := client.Reconnect(); err != nil {
-			pr.Logger.Error().Err(err).Msg("Failed to reconnect to the client")
-			span.RecordError(err)
+	// The client can't cancel the queries of the recycled server connection.
+	if key := conn.CancelKey(); key != nil {
+		pr.cancelKeys.Remove(*key)
+		conn.SetCancelKey(nil)
+	}
+
+	if client, ok := client.(IClient); ok {
+		if acquired, ok := pr.acquired.LoadAndDelete(conn); ok {
+			if acquiredAt, ok := acquired.(time.Time); ok {
+				held := time.Since(acquiredAt)
+				span.AddEvent("Released the server connection", trace.WithAttributes(
+					attribute.String("client", client.GetID()),
+					attribute.Float64("held", held.Seconds()),
+				))
+				pr.poolEvent(plugin.OnPoolReleaseHookName, client, "held", held)
+			}
 		}
 
-		// If the client is not in the pool, put it back.
-		if err := pr.AvailableConnections.Put(client.ID, client); err != nil {
-			pr.Logger.Error().Err(err).Msg("Failed to put the client back in the pool")
-			span.RecordError(err)
+		if conn.TxStatus().InTransaction() {
+			// Resetting or closing the server session rolls back the transaction.
+			pr.Logger.Debug().Str("status", conn.TxStatus().String()).Msg(
+				"Client disconnected during a transaction, rolling back")
+		}
+
+		// The backend is saved, since closing the client clears it.
+		network, address := client.GetNetwork(), client.GetAddress()
+		if pr.backendHealth.IsDraining(network, address) {
+			// Move the server connection of the drained backend to another backend.
+			client.Close()
+			pr.replaceClient(client)
+			span.AddEvent("Recycled the server connection of the drained backend")
+			pr.finishDrain(network, address)
+		} else {
+			// Reuse the pre-authenticated server session, since authenticating
+			// a new one is expensive, unless it can't be reset.
+			reused := false
+			if client.StartupResponse() != nil {
+				if session, err := client.Reuse(); err != nil {
+					pr.Logger.Debug().Err(err).Msg("Failed to reset the server session, reconnecting")
+				} else {
+					client = session
+					reused = true
+					span.AddEvent("Reset the server session")
+					pr.connectionReset(conn, client)
+				}
+			}
+
+			// Recycle the server connection by reconnecting.
+			if !reused {
+				if err := client.Reconnect(); err != nil {
+					pr.Logger.Error().Err(err).Msg("Failed to reconnect to the client")
+					span.RecordError(err)
+				}
+			}
+
+			// Validate the server connection before it's put back, if enabled.
+			client = pr.testOnReturn(client)
+
+			// If the client is not in the pool, put it back.
+			if err := pr.AvailableConnections.Put(client.GetID(), client); err != nil {
+				pr.Logger.Error().Err(err).Msg("Failed to put the client back in the pool")
+				span.RecordError(err)
+			} else {
+				pr.markIdle(client)
+				pr.clientReleased()
+			}
 		}
 	} else {
 		// This should never happen, but if it does,
 		// then there are some serious issues with the pool.
-		pr.Logger.Error().Msg("Failed to cast the client to the Client type")
+		pr.Logger.Error().Msg("Failed to cast the client to the IClient type")
 		span.RecordError(gerr.ErrCastFailed)
 		return gerr.ErrCastFailed
 	}
@@ -255,12 +680,16 @@ func (pr *Proxy) Disconnect(conn *ConnWrapper) *gerr.GatewayDError {
 	return nil
 }
 
-// PassThroughToServer sends the data from the client to the server.
+// PassThroughToServer sends the data from the client to the server. The requests of
+// the clients aren't multiplexed over a shared server connection: each client has its
+// own server connection while it's connected, since the PostgreSQL messages carry no
+// request IDs to match the responses to their requests, and the sessions are stateful,
+// e.g. their transactions, settings and prepared statements.
 func (pr *Proxy) PassThroughToServer(conn *ConnWrapper, stack *Stack) *gerr.GatewayDError {
 	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "PassThrough")
 	defer span.End()
 
-	var client *Client
+	var client IClient
 	// Check if the proxy has a egress client for the incoming connection.
 	if pr.busyConnections.Get(conn) == nil {
 		span.RecordError(gerr.ErrClientNotFound)
@@ -268,7 +697,7 @@ func (pr *Proxy) PassThroughToServer(conn *ConnWrapper, stack *Stack) *gerr.Gate
 	}
 
 	// Get the client from the busy connection pool.
-	if cl, ok := pr.busyConnections.Get(conn).(*Client); ok {
+	if cl, ok := pr.busyConnections.Get(conn).(IClient); ok {
 		client = cl
 	} else {
 		span.RecordError(gerr.ErrCastFailed)
@@ -284,22 +713,62 @@ func (pr *Proxy) PassThroughToServer(conn *ConnWrapper, stack *Stack) *gerr.Gate
 	request, origErr := pr.receiveTrafficFromClient(conn.Conn())
 	span.AddEvent("Received traffic from client")
 
+	// There's nothing to pass through to the plugins or the server, if the client
+	// sent nothing. The connection is closed if the proxy is configured to do so.
+	if origErr == nil && len(request) == 0 {
+		metrics.EmptyRequests.Inc()
+		span.AddEvent("Received an empty request")
+		if pr.CloseOnEmptyRequest {
+			span.RecordError(gerr.ErrEmptyRequest)
+			return gerr.ErrEmptyRequest
+		}
+		return nil
+	}
+
+	// The token is only meant for GatewayD, so it's never mirrored, captured or sent
+	// to the server. The client is authenticated on the request with the token.
+	forwarded := StripStartupToken(request)
+
+	if origErr == nil {
+		conn.Touch()
+		conn.CountReceived(len(request))
+		pr.mirror(plugin.MirrorIngress, conn.Conn(), forwarded)
+		conn.Capture().Record(CaptureFromClient, forwarded)
+	}
+
+	// Cancel requests are sent on a new connection, so they're forwarded to the
+	// server session they belong to instead of the server connection of this client.
+	if origErr == nil {
+		if key, ok := DecodeCancelRequest(request); ok {
+			pr.cancelRequest(key)
+			span.AddEvent("Forwarded the cancel request")
+			return gerr.ErrCancelRequest
+		}
+	}
+
+	// Authenticate the client on its startup message, before anything is sent to the server.
+	if origErr == nil && pr.Authenticator != nil && conn.Identity() == nil &&
+		!IsPostgresSSLRequest(request) && !IsCompressionRequest(request) {
+		if err := pr.authenticate(conn, request); err != nil {
+			span.RecordError(err)
+			return err
+		}
+		span.AddEvent("Authenticated the client")
+	}
+
+	request = forwarded
+
 	// Run the OnTrafficFromClient hooks.
 	pluginTimeoutCtx, cancel := context.WithTimeout(context.Background(), pr.PluginTimeout)
 	defer cancel()
 
 	result, err := pr.PluginRegistry.Run(
 		pluginTimeoutCtx,
-		trafficData(
+		pr.truncateHookPayloads(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, trafficData(
 			conn.Conn(),
 			client,
-			[]Field{
-				{
-					Name:  "request",
-					Value: request,
-				},
-			},
-			origErr),
+			withIdentity(conn, requestFields(request)),
+			origErr)),
 		v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)
 	if err != nil {
 		pr.Logger.Error().Err(err).Msg("Error running hook")
@@ -307,7 +776,13 @@ func (pr *Proxy) PassThroughToServer(conn *ConnWrapper, stack *Stack) *gerr.Gate
 	}
 	span.AddEvent("Ran the OnTrafficFromClient hooks")
 
-	if origErr != nil && errors.Is(origErr, io.EOF) {
+	// Attach the labels returned by the plugins to the connection.
+	if labels := GetLabels(result); len(labels) > 0 {
+		conn.SetLabels(MergeLabels(conn.Labels(), labels))
+		span.AddEvent("Attached the labels to the connection")
+	}
+
+	if origErr != nil && IsConnClosed(origErr) {
 		// Client closed the connection.
 		span.AddEvent("Client closed the connection")
 		return gerr.ErrClientNotConnected.Wrap(origErr)
@@ -334,8 +809,11 @@ func (pr *Proxy) PassThroughToServer(conn *ConnWrapper, stack *Stack) *gerr.Gate
 				).Msg("Sent data to database")
 			}
 		}); err != nil {
+			// The client certificates not signed by the client CAs fail the handshake,
+			// so the connection is closed instead of being routed.
 			pr.Logger.Error().Err(err).Msg("Failed to perform the TLS handshake")
 			span.RecordError(err)
+			return err
 		}
 
 		// Check if the TLS handshake was successful.
@@ -348,6 +826,12 @@ func (pr *Proxy) PassThroughToServer(conn *ConnWrapper, stack *Stack) *gerr.Gate
 			).Msg("Performed the TLS handshake")
 			span.AddEvent("Performed the TLS handshake")
 			metrics.TLSConnections.Inc()
+
+			// Tag and route the connection by its verified client certificate.
+			if err := pr.routeByCertificate(conn); err != nil {
+				span.RecordError(err)
+				return err
+			}
 		} else {
 			pr.Logger.Error().Fields(
 				map[string]interface{}{
@@ -385,6 +869,50 @@ func (pr *Proxy) PassThroughToServer(conn *ConnWrapper, stack *Stack) *gerr.Gate
 		return nil
 	}
 
+	// Check if the client sent a compression request.
+	if IsCompressionRequest(request) {
+		if !conn.IsCompressionEnabled() {
+			pr.Logger.Warn().Fields(
+				map[string]interface{}{
+					"local":  LocalAddr(conn.Conn()),
+					"remote": RemoteAddr(conn.Conn()),
+				},
+			).Msg("Server does not support compression, but compression was requested by the client")
+			span.AddEvent("Server does not support compression, but compression was requested by the client")
+
+			if _, err := conn.Write([]byte{CompressionRejected}); err != nil {
+				pr.Logger.Warn().Err(err).Msg("Failed to reject the compression request")
+				span.RecordError(err)
+			}
+			return nil
+		}
+
+		if err := conn.EnableCompression(func(net.Conn) {
+			// Acknowledge the compression request before compressing the traffic.
+			if _, err := conn.Write([]byte{CompressionAccepted}); err != nil {
+				pr.Logger.Error().Err(err).Msg("Failed to acknowledge the compression request")
+				span.RecordError(err)
+			}
+		}); err != nil {
+			pr.Logger.Error().Err(err).Msg("Failed to compress the connection")
+			span.RecordError(err)
+			return err
+		}
+
+		pr.Logger.Debug().Fields(
+			map[string]interface{}{
+				"local":  LocalAddr(conn.Conn()),
+				"remote": RemoteAddr(conn.Conn()),
+			},
+		).Msg("Compressed the connection")
+		span.AddEvent("Compressed the connection")
+		metrics.CompressedConnections.Inc()
+
+		// This return causes the client to start sending
+		// StartupMessage over the compressed connection.
+		return nil
+	}
+
 	// Push the client's request to the stack.
 	stack.Push(&Request{Data: request})
 
@@ -402,7 +930,7 @@ func (pr *Proxy) PassThroughToServer(conn *ConnWrapper, stack *Stack) *gerr.Gate
 			result = resp
 		}
 
-		if modResponse, modReceived := pr.getPluginModifiedResponse(result); modResponse != nil {
+		if modResponse, modReceived := pr.getPluginModifiedResponse(result, nil); modResponse != nil {
 			metrics.ProxyPassThroughsToClient.Inc()
 			metrics.ProxyPassThroughTerminations.Inc()
 			metrics.BytesSentToClient.Observe(float64(modReceived))
@@ -413,22 +941,73 @@ func (pr *Proxy) PassThroughToServer(conn *ConnWrapper, stack *Stack) *gerr.Gate
 			// Remove the request from the stack if the response is modified.
 			stack.PopLastRequest()
 
-			return pr.sendTrafficToClient(conn.Conn(), modResponse, modReceived)
+			return pr.sendTrafficToClient(conn, modResponse, modReceived)
 		}
 		span.RecordError(gerr.ErrHookTerminatedConnection)
 		return gerr.ErrHookTerminatedConnection
 	}
 	// If the hook modified the request, use the modified request.
-	if modRequest := pr.getPluginModifiedRequest(result); modRequest != nil {
+	modRequest := pr.getPluginModifiedRequest(result, request)
+	pr.countHookModification(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, "request", request, modRequest)
+	if modRequest != nil {
 		request = modRequest
 		span.AddEvent("Plugin(s) modified the request")
 	}
 
+	// Hand the session off to another backend, if its backend is drained or down.
+	client = pr.handoff(conn, client)
+
+	// Use the credentials of the client's backend in the startup message.
+	request = RewriteStartupMessage(request, client.StartupParameters())
+
+	// The pre-authenticated server session is already started, so the startup
+	// message is answered with the server's response instead of being sent.
+	if startupResponse := client.StartupResponse(); startupResponse != nil {
+		if _, ok := DecodeStartupMessage(request); ok {
+			stack.PopLastRequest()
+			span.AddEvent("Replied to the startup message")
+			return pr.replyToStartup(conn, client, startupResponse)
+		}
+	}
+
+	// Reject the writes to the database in read-only mode. The request is classified
+	// as a whole, so the rest of its last message is read first if it's split.
+	if pr.IsReadOnly() {
+		completed, readErr := pr.completeRequest(conn, request)
+		if readErr != nil {
+			span.RecordError(readErr)
+			return readErr
+		}
+		request = completed
+		if command, ok := RequestWriteCommand(request, conn.PreparedStatements()); ok {
+			stack.PopLastRequest()
+			return pr.rejectWrite(conn, request, command)
+		}
+	}
+
+	// Wait for the backend to be below its max in-flight requests, if it has one.
+	if !pr.acquireInFlight(conn, client, request) {
+		stack.PopLastRequest()
+		return pr.rejectInFlight(conn, request)
+	}
+
 	stack.UpdateLastRequest(&Request{Data: request})
 
+	// Record the requests that set up the server session, to replay them on handoff.
+	if pr.SessionHandoff && client.StartupResponse() == nil {
+		conn.RecordSessionSetup(request, pr.HandoffSetupRequests)
+	}
+
+	// Keep track of the prepared statements of the session, to prepare them again when
+	// it moves to another server connection. Only the PostgreSQL messages have them.
+	if IsPostgresMessages(request) {
+		conn.PreparedStatements().Track(request)
+	}
+
 	// Send the request to the server.
-	_, err = pr.sendTrafficToServer(client, request)
+	_, err = pr.sendTrafficToServer(withLabels(pr.Logger, conn), client, request)
 	span.AddEvent("Sent traffic to server")
+	pr.countLabeledRequest(conn)
 
 	pluginTimeoutCtx, cancel = context.WithTimeout(context.Background(), pr.PluginTimeout)
 	defer cancel()
@@ -436,16 +1015,16 @@ func (pr *Proxy) PassThroughToServer(conn *ConnWrapper, stack *Stack) *gerr.Gate
 	// Run the OnTrafficToServer hooks.
 	_, err = pr.PluginRegistry.Run(
 		pluginTimeoutCtx,
-		trafficData(
+		pr.truncateHookPayloads(v1.HookName_HOOK_NAME_ON_TRAFFIC_TO_SERVER, trafficData(
 			conn.Conn(),
 			client,
-			[]Field{
+			withIdentity(conn, []Field{
 				{
 					Name:  "request",
 					Value: request,
 				},
-			},
-			err),
+			}),
+			err)),
 		v1.HookName_HOOK_NAME_ON_TRAFFIC_TO_SERVER)
 	if err != nil {
 		pr.Logger.Error().Err(err).Msg("Error running hook")
@@ -463,7 +1042,7 @@ func (pr *Proxy) PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.Gate
 	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "PassThrough")
 	defer span.End()
 
-	var client *Client
+	var client IClient
 	// Check if the proxy has a egress client for the incoming connection.
 	if pr.busyConnections.Get(conn) == nil {
 		span.RecordError(gerr.ErrClientNotFound)
@@ -471,7 +1050,7 @@ func (pr *Proxy) PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.Gate
 	}
 
 	// Get the client from the busy connection pool.
-	if cl, ok := pr.busyConnections.Get(conn).(*Client); ok {
+	if cl, ok := pr.busyConnections.Get(conn).(IClient); ok {
 		client = cl
 	} else {
 		span.RecordError(gerr.ErrCastFailed)
@@ -486,6 +1065,43 @@ func (pr *Proxy) PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.Gate
 	// Receive the response from the server.
 	received, response, err := pr.receiveTrafficFromServer(client)
 	span.AddEvent("Received traffic from server")
+	// The server connection was replaced while waiting for its response, e.g. on a
+	// handoff or when routed by the client certificate, so the next response is read
+	// from the new one.
+	if err != nil && received == 0 && pr.busyConnections.Get(conn) != client {
+		return nil
+	}
+	// The server may have closed or reset the connection, e.g. on a restart.
+	received, response, err = pr.retryRequest(conn, client, stack, received, response, err)
+	// The server may not have responded within the receive deadline, e.g. on a slow query.
+	received, response, err = pr.handleReceiveTimeout(conn, client, stack, received, response, err)
+	if err == nil {
+		pr.mirror(plugin.MirrorEgress, conn.Conn(), response)
+		conn.Capture().Record(CaptureFromServer, response[:received])
+
+		// Keep track of the transaction status of the session. The request is complete
+		// once the server is ready for the next query, or on its first response for the
+		// other protocols than PostgreSQL.
+		if status, ok := GetTxStatus(response); ok {
+			conn.SetTxStatus(status)
+			pr.inFlight.Release(conn)
+		} else {
+			pr.inFlight.ReleaseOnResponse(conn)
+		}
+
+		// Issue a key of our own to the client for canceling its queries.
+		if key, ok := pr.cancelKeys.Translate(response[:received], client.GetNetwork(), client.GetAddress()); ok {
+			conn.SetCancelKey(&key)
+		}
+
+		// Advertise the configured server version instead of the server's.
+		if pr.ServerVersion != "" {
+			if rewritten, ok := RewriteServerVersion(response[:received], pr.ServerVersion); ok {
+				response, received = rewritten, len(rewritten)
+				span.AddEvent("Rewrote the server version")
+			}
+		}
+	}
 
 	// If the response is empty, don't send anything, instead just close the ingress connection.
 	if received == 0 || err != nil {
@@ -500,7 +1116,38 @@ func (pr *Proxy) PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.Gate
 		span.AddEvent("No data to send to client")
 		span.RecordError(err)
 
+		if errors.Is(err, errMessageAssemblyTimeout) {
+			// The rest of the message may still arrive, so the server connection is
+			// recycled right away, and the client is failed explicitly.
+			pr.Logger.Warn().Fields(fields).Msg("Server didn't complete the message in time")
+			metrics.IncompleteMessages.Inc()
+			if reconnectErr := client.Reconnect(); reconnectErr != nil {
+				pr.Logger.Error().Err(reconnectErr).Msg("Failed to recycle the server connection")
+			}
+			// https://www.postgresql.org/docs/current/errcodes-appendix.html
+			response := postgres.ErrorResponse(
+				"server didn't complete the message in time", "FATAL", "08P01",
+				"The server connection is closed")
+			if _, err := conn.Write(response); err != nil {
+				pr.Logger.Debug().Err(err).Msg("Failed to send the error response to the client")
+			}
+			conn.SetTxStatus(TxIdle)
+		} else if conn.TxStatus().InTransaction() {
+			// The server connection is lost in the middle of a transaction, which can't be
+			// continued on another server connection, so the client is failed explicitly.
+			pr.Logger.Warn().Fields(fields).Msg("Server connection is lost during a transaction")
+			// https://www.postgresql.org/docs/current/errcodes-appendix.html
+			response := postgres.ErrorResponse(
+				"server connection is lost during a transaction", "FATAL", "08006",
+				"The transaction is rolled back by the server")
+			if _, err := conn.Write(response); err != nil {
+				pr.Logger.Debug().Err(err).Msg("Failed to send the error response to the client")
+			}
+			conn.SetTxStatus(TxIdle)
+		}
+
 		stack.PopLastRequest()
+		pr.inFlight.Release(conn)
 
 		return err
 	}
@@ -518,10 +1165,10 @@ func (pr *Proxy) PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.Gate
 	// Run the OnTrafficFromServer hooks.
 	result, err := pr.PluginRegistry.Run(
 		pluginTimeoutCtx,
-		trafficData(
+		pr.truncateHookPayloads(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_SERVER, trafficData(
 			conn.Conn(),
 			client,
-			[]Field{
+			withIdentity(conn, []Field{
 				{
 					Name:  "request",
 					Value: request,
@@ -530,8 +1177,8 @@ func (pr *Proxy) PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.Gate
 					Name:  "response",
 					Value: response[:received],
 				},
-			},
-			err),
+			}),
+			err)),
 		v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_SERVER)
 	if err != nil {
 		pr.Logger.Error().Err(err).Msg("Error running hook")
@@ -540,15 +1187,21 @@ func (pr *Proxy) PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.Gate
 	span.AddEvent("Ran the OnTrafficFromServer hooks")
 
 	// If the hook modified the response, use the modified response.
-	if modResponse, modReceived := pr.getPluginModifiedResponse(result); modResponse != nil {
+	modResponse, modReceived := pr.getPluginModifiedResponse(result, response[:received])
+	pr.countHookModification(
+		v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_SERVER, "response", response[:received], modResponse)
+	if modResponse != nil {
 		response = modResponse
 		received = modReceived
 		span.AddEvent("Plugin(s) modified the response")
 	}
 
 	// Send the response to the client.
-	errVerdict := pr.sendTrafficToClient(conn.Conn(), response, received)
+	errVerdict := pr.sendTrafficToClient(conn, response, received)
 	span.AddEvent("Sent traffic to client")
+	if errVerdict == nil {
+		conn.Touch()
+	}
 
 	// Run the OnTrafficToClient hooks.
 	pluginTimeoutCtx, cancel = context.WithTimeout(context.Background(), pr.PluginTimeout)
@@ -556,10 +1209,10 @@ func (pr *Proxy) PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.Gate
 
 	_, err = pr.PluginRegistry.Run(
 		pluginTimeoutCtx,
-		trafficData(
+		pr.truncateHookPayloads(v1.HookName_HOOK_NAME_ON_TRAFFIC_TO_CLIENT, trafficData(
 			conn.Conn(),
 			client,
-			[]Field{
+			withIdentity(conn, []Field{
 				{
 					Name:  "request",
 					Value: request,
@@ -568,9 +1221,13 @@ func (pr *Proxy) PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.Gate
 					Name:  "response",
 					Value: response[:received],
 				},
-			},
-			nil,
-		),
+				{
+					Name:  "traffic",
+					Value: trafficTotals(conn),
+				},
+			}),
+			errVerdict,
+		)),
 		v1.HookName_HOOK_NAME_ON_TRAFFIC_TO_CLIENT)
 	if err != nil {
 		pr.Logger.Error().Err(err).Msg("Error running hook")
@@ -578,7 +1235,17 @@ func (pr *Proxy) PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.Gate
 	}
 
 	if errVerdict != nil {
+		pr.Logger.Error().Err(errVerdict).Msg("Failed to send traffic to client")
 		span.RecordError(errVerdict)
+
+		// The client went away while the response was in flight, so the server
+		// connection is recycled right away instead of being left orphaned.
+		if IsConnClosed(errVerdict) {
+			if err := pr.Disconnect(conn); err != nil {
+				pr.Logger.Error().Err(err).Msg("Failed to recycle the server connection")
+				span.RecordError(err)
+			}
+		}
 	}
 
 	metrics.ProxyPassThroughsToClient.Inc()
@@ -587,7 +1254,7 @@ func (pr *Proxy) PassThroughToClient(conn *ConnWrapper, stack *Stack) *gerr.Gate
 }
 
 // IsHealthy checks if the pool is exhausted or the client is disconnected.
-func (pr *Proxy) IsHealthy(client *Client) (*Client, *gerr.GatewayDError) {
+func (pr *Proxy) IsHealthy(client IClient) (IClient, *gerr.GatewayDError) {
 	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "IsHealthy")
 	defer span.End()
 
@@ -597,7 +1264,7 @@ func (pr *Proxy) IsHealthy(client *Client) (*Client, *gerr.GatewayDError) {
 		return client, gerr.ErrPoolExhausted
 	}
 
-	if !client.IsConnected() {
+	if client == nil || !client.IsConnected() {
 		pr.Logger.Error().Msg("Client is disconnected")
 		span.RecordError(gerr.ErrClientNotConnected)
 	}
@@ -618,7 +1285,7 @@ func (pr *Proxy) Shutdown() {
 	defer span.End()
 
 	pr.AvailableConnections.ForEach(func(_, value interface{}) bool {
-		if client, ok := value.(*Client); ok {
+		if client, ok := value.(IClient); ok {
 			if client.IsConnected() {
 				client.Close()
 			}
@@ -626,6 +1293,7 @@ func (pr *Proxy) Shutdown() {
 		return true
 	})
 	pr.AvailableConnections.Clear()
+	pr.closeStandby("")
 	pr.Logger.Debug().Msg("All available connections have been closed")
 
 	pr.busyConnections.ForEach(func(key, value interface{}) bool {
@@ -640,7 +1308,7 @@ func (pr *Proxy) Shutdown() {
 				span.RecordError(err)
 			}
 		}
-		if client, ok := value.(*Client); ok {
+		if client, ok := value.(IClient); ok {
 			if client != nil {
 				client.Close()
 			}
@@ -660,7 +1328,7 @@ func (pr *Proxy) AvailableConnectionsString() []string {
 
 	connections := make([]string, 0)
 	pr.AvailableConnections.ForEach(func(_, value interface{}) bool {
-		if cl, ok := value.(*Client); ok {
+		if cl, ok := value.(IClient); ok {
 			connections = append(connections, cl.LocalAddr())
 		}
 		return true
@@ -683,19 +1351,103 @@ func (pr *Proxy) BusyConnectionsString() []string {
 	return connections
 }
 
+// PoolSize returns the number of server connections, both available and busy.
+func (pr *Proxy) PoolSize() int {
+	return pr.AvailableConnections.Size() + pr.busyConnections.Size()
+}
+
+// BackendAddresses returns the sorted list of the addresses of the database servers
+// the proxy is connected to.
+func (pr *Proxy) BackendAddresses() []string {
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "BackendAddresses")
+	defer span.End()
+
+	addresses := map[string]struct{}{}
+	collect := func(_, value interface{}) bool {
+		if client, ok := value.(IClient); ok && client.GetAddress() != "" {
+			addresses[client.GetNetwork()+"://"+client.GetAddress()] = struct{}{}
+		}
+		return true
+	}
+	pr.AvailableConnections.ForEach(collect)
+	pr.busyConnections.ForEach(collect)
+
+	backends := maps.Keys(addresses)
+	slices.Sort(backends)
+	return backends
+}
+
+// CloseIdleConnections closes the client connections with no traffic in either
+// direction for longer than the idle timeout. Closing the client connection ends
+// its pass-through, which in turn recycles its server connection.
+// It returns the number of closed connections.
+func (pr *Proxy) CloseIdleConnections(idleTimeout time.Duration) int {
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "CloseIdleConnections")
+	defer span.End()
+
+	closed := 0
+	pr.busyConnections.ForEach(func(key, _ interface{}) bool {
+		conn, ok := key.(*ConnWrapper)
+		if !ok {
+			return true
+		}
+
+		idle := time.Since(conn.LastActivity())
+		if idle <= idleTimeout {
+			return true
+		}
+
+		pr.Logger.Debug().Fields(
+			map[string]interface{}{
+				"remote": RemoteAddr(conn.Conn()),
+				"idle":   idle.String(),
+			},
+		).Msg("Closing idle client connection")
+		if err := conn.Close(); err != nil && !IsConnClosed(err) {
+			pr.Logger.Error().Err(err).Msg("Failed to close the idle client connection")
+			span.RecordError(err)
+			return true
+		}
+		closed++
+		return true
+	})
+
+	if closed > 0 {
+		metrics.IdleConnectionsClosed.Add(float64(closed))
+		pr.Logger.Info().Int("count", closed).Msg("Closed idle client connections")
+	}
+
+	return closed
+}
+
 // receiveTrafficFromClient is a function that waits to receive data from the client.
 func (pr *Proxy) receiveTrafficFromClient(conn net.Conn) ([]byte, *gerr.GatewayDError) {
 	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "receiveTrafficFromClient")
 	defer span.End()
 
+	chunkSize := config.DefaultChunkSize
+	if pr.ClientConfig != nil {
+		chunkSize = pr.ClientConfig.ReceiveChunkSize
+	}
+
 	// request contains the data from the client.
 	received := 0
 	buffer := bytes.NewBuffer(nil)
 	for {
-		chunk := make([]byte, pr.ClientConfig.ReceiveChunkSize)
+		chunk := make([]byte, chunkSize)
 		read, err := conn.Read(chunk)
+		if read == 0 && err == nil && received == 0 {
+			// The client sent nothing.
+			break
+		}
 		if read == 0 || err != nil {
-			pr.Logger.Debug().Err(err).Msg("Error reading from client")
+			// The clients disconnecting is part of the lifecycle of the connections,
+			// so only the other errors are logged as errors.
+			if err == nil || IsConnClosed(err) {
+				pr.Logger.Debug().Err(err).Msg("Client closed the connection")
+			} else {
+				pr.Logger.Error().Err(err).Msg("Error reading from client")
+			}
 			span.RecordError(err)
 
 			metrics.BytesReceivedFromClient.Observe(float64(read))
@@ -707,7 +1459,7 @@ func (pr *Proxy) receiveTrafficFromClient(conn net.Conn) ([]byte, *gerr.GatewayD
 		received += read
 		buffer.Write(chunk[:read])
 
-		if received == 0 || received < pr.ClientConfig.ReceiveChunkSize {
+		if received == 0 || received < chunkSize {
 			break
 		}
 
@@ -734,22 +1486,24 @@ func (pr *Proxy) receiveTrafficFromClient(conn net.Conn) ([]byte, *gerr.GatewayD
 }
 
 // sendTrafficToServer is a function that sends data to the server.
-func (pr *Proxy) sendTrafficToServer(client *Client, request []byte) (int, *gerr.GatewayDError) {
+func (pr *Proxy) sendTrafficToServer(
+	logger zerolog.Logger, client IClient, request []byte,
+) (int, *gerr.GatewayDError) {
 	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "sendTrafficToServer")
 	defer span.End()
 
 	if len(request) == 0 {
-		pr.Logger.Trace().Msg("Empty request")
+		logger.Trace().Msg("Empty request")
 		return 0, nil
 	}
 
 	// Send the request to the server.
 	sent, err := client.Send(request)
 	if err != nil {
-		pr.Logger.Error().Err(err).Msg("Error sending request to database")
+		logger.Error().Err(err).Msg("Error sending request to database")
 		span.RecordError(err)
 	}
-	pr.Logger.Debug().Fields(
+	logger.Debug().Fields(
 		map[string]interface{}{
 			"function": "proxy.passthrough",
 			"length":   sent,
@@ -767,7 +1521,7 @@ func (pr *Proxy) sendTrafficToServer(client *Client, request []byte) (int, *gerr
 }
 
 // receiveTrafficFromServer is a function that receives data from the server.
-func (pr *Proxy) receiveTrafficFromServer(client *Client) (int, []byte, *gerr.GatewayDError) {
+func (pr *Proxy) receiveTrafficFromServer(client IClient) (int, []byte, *gerr.GatewayDError) {
 	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "receiveTrafficFromServer")
 	defer span.End()
 
@@ -797,7 +1551,7 @@ func (pr *Proxy) receiveTrafficFromServer(client *Client) (int, []byte, *gerr.Ga
 
 // sendTrafficToClient is a function that sends data to the client.
 func (pr *Proxy) sendTrafficToClient(
-	conn net.Conn, response []byte, received int,
+	conn *ConnWrapper, response []byte, received int,
 ) *gerr.GatewayDError {
 	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "sendTrafficToClient")
 	defer span.End()
@@ -809,7 +1563,8 @@ func (pr *Proxy) sendTrafficToClient(
 			break
 		}
 
-		written, origErr := conn.Write(response[:received])
+		written, origErr := conn.Conn().Write(response[:received])
+		conn.CountSent(written)
 		if origErr != nil {
 			pr.Logger.Error().Err(origErr).Msg("Error writing to client")
 			span.RecordError(origErr)
@@ -823,8 +1578,8 @@ func (pr *Proxy) sendTrafficToClient(
 		map[string]interface{}{
 			"function": "proxy.passthrough",
 			"length":   sent,
-			"local":    LocalAddr(conn),
-			"remote":   RemoteAddr(conn),
+			"local":    LocalAddr(conn.Conn()),
+			"remote":   RemoteAddr(conn.Conn()),
 		},
 	).Msg("Sent data to client")
 
@@ -836,6 +1591,139 @@ func (pr *Proxy) sendTrafficToClient(
 	return nil
 }
 
+// connectionReset runs the OnConnectionReset hooks for the reset server session.
+func (pr *Proxy) connectionReset(conn *ConnWrapper, client IClient) {
+	pr.runLifecycleHook(plugin.OnConnectionResetHookName, map[string]interface{}{
+		"client": map[string]interface{}{
+			"local":  LocalAddr(conn.Conn()),
+			"remote": RemoteAddr(conn.Conn()),
+		},
+		"server": map[string]interface{}{
+			"local":  client.LocalAddr(),
+			"remote": client.RemoteAddr(),
+		},
+	})
+}
+
+// runLifecycleHook runs the lifecycle hooks in the background, so that the
+// plugins can't delay the proxy.
+func (pr *Proxy) runLifecycleHook(hook string, args map[string]interface{}) {
+	if pr.PluginRegistry == nil {
+		return
+	}
+
+	go func(registry *plugin.Registry, timeout time.Duration) {
+		if err := registry.RunLifecycleHook(hook, args, timeout); err != nil {
+			pr.Logger.Error().Err(err).Str("hook", hook).Msg("Failed to run lifecycle hooks")
+		}
+	}(pr.PluginRegistry, pr.PluginTimeout)
+}
+
+// replyToStartup sends the server's response to the startup message of the
+// pre-authenticated server session to the client, with a cancel key of its own.
+func (pr *Proxy) replyToStartup(conn *ConnWrapper, client IClient, startupResponse []byte) *gerr.GatewayDError {
+	response := slices.Clone(startupResponse)
+	if key, ok := pr.cancelKeys.Translate(response, client.GetNetwork(), client.GetAddress()); ok {
+		conn.SetCancelKey(&key)
+	}
+	if pr.ServerVersion != "" {
+		response, _ = RewriteServerVersion(response, pr.ServerVersion)
+	}
+	conn.SetTxStatus(TxIdle)
+
+	return pr.sendTrafficToClient(conn, response, len(response))
+}
+
+// cancelRequest forwards the cancel request to the server session of the issued key.
+// The cancel requests of unknown keys are dropped, as PostgreSQL does.
+func (pr *Proxy) cancelRequest(key BackendKey) {
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "cancelRequest")
+	defer span.End()
+
+	target, ok := pr.cancelKeys.lookup(key)
+	if !ok {
+		pr.Logger.Debug().Msg("Dropped a cancel request with an unknown key")
+		span.AddEvent("Dropped a cancel request with an unknown key")
+		return
+	}
+
+	dialTimeout := config.DefaultDialTimeout
+	if pr.ClientConfig != nil {
+		dialTimeout = pr.ClientConfig.DialTimeout
+	}
+	if err := sendCancelRequest(target, dialTimeout); err != nil {
+		pr.Logger.Error().Err(err).Str("address", target.address).Msg(
+			"Failed to forward the cancel request to the server")
+		span.RecordError(err)
+		return
+	}
+
+	pr.Logger.Debug().Str("address", target.address).Msg("Forwarded the cancel request to the server")
+	metrics.CancelRequests.Inc()
+}
+
+// authenticate authenticates the client by its startup message. If the client
+// is rejected, an error response is sent to the client before the connection is closed.
+func (pr *Proxy) authenticate(conn *ConnWrapper, request []byte) *gerr.GatewayDError {
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "authenticate")
+	defer span.End()
+
+	var identity *Identity
+	var err *gerr.GatewayDError
+	if startup, ok := DecodeStartupMessage(request); ok {
+		identity, err = pr.Authenticator.Authenticate(conn, startup)
+	} else {
+		err = gerr.ErrAuthFailed.Wrap(errors.New("expected a startup message"))
+	}
+
+	if err != nil {
+		pr.Logger.Warn().Err(err).Fields(
+			map[string]interface{}{
+				"local":  LocalAddr(conn.Conn()),
+				"remote": RemoteAddr(conn.Conn()),
+			},
+		).Msg("Client authentication failed")
+		span.RecordError(err)
+
+		// https://www.postgresql.org/docs/current/errcodes-appendix.html
+		response := postgres.ErrorResponse(
+			"authentication failed", "FATAL", "28000", "Client is rejected by GatewayD")
+		if _, err := conn.Write(response); err != nil {
+			pr.Logger.Debug().Err(err).Msg("Failed to send the error response to the client")
+		}
+
+		return err
+	}
+
+	conn.SetIdentity(identity)
+
+	pr.Logger.Debug().Fields(
+		map[string]interface{}{
+			"local":    LocalAddr(conn.Conn()),
+			"remote":   RemoteAddr(conn.Conn()),
+			"identity": identity.Name,
+			"method":   identity.Method,
+		},
+	).Msg("Client authenticated")
+
+	return nil
+}
+
+// mirror publishes the traffic to the plugins subscribed to the traffic mirror.
+func (pr *Proxy) mirror(direction plugin.MirrorDirection, conn net.Conn, payload []byte) {
+	if pr.PluginRegistry == nil || pr.PluginRegistry.Mirror.Subscribers() == 0 {
+		return
+	}
+
+	pr.PluginRegistry.Mirror.Publish(&plugin.MirrorMessage{
+		Direction:  direction,
+		LocalAddr:  LocalAddr(conn),
+		RemoteAddr: RemoteAddr(conn),
+		Payload:    payload,
+		Timestamp:  time.Now(),
+	})
+}
+
 // shouldTerminate is a function that retrieves the terminate field from the hook result.
 // Only the OnTrafficFromClient hook will terminate the request.
 func (pr *Proxy) shouldTerminate(result map[string]interface{}) (bool, map[string]interface{}) {
@@ -889,14 +1777,31 @@ func (pr *Proxy) shouldTerminate(result map[string]interface{}) (bool, map[strin
 
 // getPluginModifiedRequest is a function that retrieves the modified request
 // from the hook result.
-func (pr *Proxy) getPluginModifiedRequest(result map[string]interface{}) []byte {
+func (pr *Proxy) getPluginModifiedRequest(result map[string]interface{}, original []byte) []byte {
 	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "getPluginModifiedRequest")
 	defer span.End()
 
+	// The truncated request returned as is by the plugins isn't the original request.
+	if isTruncated(result, "request") {
+		return nil
+	}
+
 	// If the hook modified the request, use the modified request.
 	if modRequest, errMsg := extractFieldValue(result, "request"); errMsg != "" {
 		pr.Logger.Error().Str("error", errMsg).Msg("Error in hook")
 	} else if modRequest != nil {
+		if err := validatePayload(modRequest, original, pr.MaxPayloadSize); err != nil {
+			pr.rejectModifiedPayload("request", err)
+			span.RecordError(err)
+			return nil
+		}
+		if pr.RequestVerifier != nil {
+			if err := pr.RequestVerifier(modRequest, original); err != nil {
+				pr.rejectModifiedPayload("request", err)
+				span.RecordError(err)
+				return nil
+			}
+		}
 		return modRequest
 	}
 
@@ -905,20 +1810,42 @@ func (pr *Proxy) getPluginModifiedRequest(result map[string]interface{}) []byte
 
 // getPluginModifiedResponse is a function that retrieves the modified response
 // from the hook result.
-func (pr *Proxy) getPluginModifiedResponse(result map[string]interface{}) ([]byte, int) {
+func (pr *Proxy) getPluginModifiedResponse(
+	result map[string]interface{}, original []byte,
+) ([]byte, int) {
 	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "getPluginModifiedResponse")
 	defer span.End()
 
+	// The truncated response returned as is by the plugins isn't the original response.
+	if isTruncated(result, "response") {
+		return nil, 0
+	}
+
 	// If the hook returns a response, use it instead of the original response.
 	if modResponse, errMsg := extractFieldValue(result, "response"); errMsg != "" {
 		pr.Logger.Error().Str("error", errMsg).Msg("Error in hook")
 	} else if modResponse != nil {
+		if err := validatePayload(modResponse, original, pr.MaxPayloadSize); err != nil {
+			pr.rejectModifiedPayload("response", err)
+			span.RecordError(err)
+			return nil, 0
+		}
 		return modResponse, len(modResponse)
 	}
 
 	return nil, 0
 }
 
+// rejectModifiedPayload logs and counts a payload modified by the plugins that
+// failed validation, in which case the original payload is used instead.
+func (pr *Proxy) rejectModifiedPayload(field string, err error) {
+	pr.Logger.Warn().Err(err).Str("field", field).Msg(
+		"Rejected the payload modified by the plugins, using the original payload")
+	// The errors of the request verifiers are bounded, like the labels of the plugins.
+	metrics.RejectedHookModifications.WithLabelValues(
+		field, pr.labelValues.Value("reason", err.Error())).Inc()
+}
+
 func (pr *Proxy) isConnectionHealthy(conn net.Conn) bool {
 	if n, err := conn.Read([]byte{}); n == 0 && err != nil {
 		pr.Logger.Debug().Fields(
@@ -1,17 +1,27 @@
 package network
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"net"
+	"os"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/gatewayd-io/gatewayd/act"
 	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
 	"github.com/gatewayd-io/gatewayd/logging"
+	"github.com/gatewayd-io/gatewayd/metrics"
 	"github.com/gatewayd-io/gatewayd/plugin"
 	"github.com/gatewayd-io/gatewayd/pool"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestNewProxy tests the creation of a new proxy with a fixed connection pool.
@@ -408,3 +418,415 @@ func BenchmarkProxyAvailableAndBusyConnectionsString(b *testing.B) {
 		proxy.BusyConnectionsString()
 	}
 }
+
+// newProxyWithBackend creates a proxy with a single client connected to a fake
+// backend, which sends the given response on every new connection and closes it
+// if closeAfterResponse is set.
+func newProxyWithBackend(t *testing.T, response []byte, closeAfterResponse bool) *Proxy {
+	t.Helper()
+
+	upstream := newFakeUpstream(t, func(conn net.Conn) {
+		_, _ = conn.Write(response)
+		if closeAfterResponse {
+			conn.Close()
+		}
+	})
+
+	return newTestProxy(t, upstream.Address())
+}
+
+// TestPassThroughToClientClosedConnection tests that the server connection is recycled
+// when the client closes the connection while the response is in flight.
+func TestPassThroughToClientClosedConnection(t *testing.T) {
+	proxy := newProxyWithBackend(t, []byte("response"), false)
+
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnWrapper(ConnWrapper{NetConn: serverSide})
+	require.Nil(t, proxy.Connect(conn))
+	assert.Equal(t, 0, proxy.AvailableConnections.Size())
+	assert.Equal(t, 1, proxy.busyConnections.Size())
+
+	// The client goes away before the response is sent.
+	require.NoError(t, clientSide.Close())
+
+	err := proxy.PassThroughToClient(conn, NewStack())
+	require.NotNil(t, err)
+	assert.True(t, IsConnClosed(err))
+
+	// The server connection is recycled back to the pool.
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+	assert.Equal(t, 0, proxy.busyConnections.Size())
+}
+
+// TestPassThroughToClientLostTransaction tests that the client is failed when the
+// server connection is lost during a transaction.
+func TestPassThroughToClientLostTransaction(t *testing.T) {
+	ready, err := (&pgproto3.ReadyForQuery{TxStatus: byte(TxInTransaction)}).Encode(nil)
+	require.NoError(t, err)
+	proxy := newProxyWithBackend(t, ready, true)
+
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+	conn := NewConnWrapper(ConnWrapper{NetConn: serverSide})
+	require.Nil(t, proxy.Connect(conn))
+
+	received := make(chan []byte)
+	go func() {
+		for {
+			buffer := make([]byte, config.DefaultChunkSize)
+			read, err := clientSide.Read(buffer)
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- buffer[:read]
+		}
+	}()
+
+	// The server reports that the session is in a transaction.
+	require.Nil(t, proxy.PassThroughToClient(conn, NewStack()))
+	assert.Equal(t, ready, <-received)
+	assert.Equal(t, TxInTransaction, conn.TxStatus())
+
+	// The server connection is lost, so the client receives a fatal error.
+	assert.NotNil(t, proxy.PassThroughToClient(conn, NewStack()))
+	msg := <-received
+	require.NotEmpty(t, msg)
+	assert.Equal(t, byte('E'), msg[0])
+	assert.Equal(t, TxIdle, conn.TxStatus())
+}
+
+// TestCloseIdleConnections tests that only the client connections without
+// traffic for longer than the idle timeout are closed.
+func TestCloseIdleConnections(t *testing.T) {
+	proxy := newProxyWithBackend(t, []byte("response"), false)
+
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+	conn := NewConnWrapper(ConnWrapper{NetConn: serverSide})
+	require.Nil(t, proxy.Connect(conn))
+
+	// The connection was just opened, so it's not idle.
+	assert.Equal(t, 0, proxy.CloseIdleConnections(time.Minute))
+
+	conn.lastActivity.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	assert.Equal(t, 1, proxy.CloseIdleConnections(time.Minute))
+
+	// The client sees the connection closed.
+	_, err := clientSide.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+// TestProxyPassThrough tests a request and its response passing through the proxy
+// between a mock client connection and a fake database server.
+func TestProxyPassThrough(t *testing.T) {
+	query, err := (&pgproto3.Query{String: "SELECT 1"}).Encode(nil)
+	require.NoError(t, err)
+	ready, err := (&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)}).Encode(nil)
+	require.NoError(t, err)
+
+	requests := make(chan []byte, 1)
+	upstream := newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		buffer := make([]byte, config.DefaultChunkSize)
+		read, err := conn.Read(buffer)
+		if err != nil {
+			return
+		}
+		requests <- buffer[:read]
+		_, _ = conn.Write(ready)
+		// Keep the connection open until the client goes away.
+		_, _ = conn.Read(buffer)
+	})
+	proxy := newTestProxy(t, upstream.Address())
+
+	client := newMockConn(query)
+	conn := NewConnWrapper(ConnWrapper{NetConn: client})
+	require.Nil(t, proxy.Connect(conn))
+
+	require.Nil(t, proxy.PassThroughToServer(conn, NewStack()))
+	assert.Equal(t, query, <-requests)
+
+	require.Nil(t, proxy.PassThroughToClient(conn, NewStack()))
+	assert.Equal(t, ready, client.Written())
+	assert.Equal(t, TxIdle, conn.TxStatus())
+
+	// The client is done, so the server connection is recycled.
+	require.Nil(t, proxy.Disconnect(conn))
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+	assert.Equal(t, 0, proxy.busyConnections.Size())
+}
+
+// TestProxyPassThroughReconnectOnEOF tests that the server connection is reconnected
+// and put back in the pool after the server closes it.
+func TestProxyPassThroughReconnectOnEOF(t *testing.T) {
+	upstream := newFakeUpstream(t, func(conn net.Conn) {
+		conn.Close()
+	})
+	proxy := newTestProxy(t, upstream.Address())
+
+	client := newMockConn()
+	conn := NewConnWrapper(ConnWrapper{NetConn: client})
+	require.Nil(t, proxy.Connect(conn))
+	assert.Equal(t, 1, upstream.Accepted())
+
+	// The server closed the connection, so nothing is sent to the client.
+	require.NotNil(t, proxy.PassThroughToClient(conn, NewStack()))
+	assert.Empty(t, client.Written())
+
+	require.Nil(t, proxy.Disconnect(conn))
+	// The reconnection may be established before the upstream accepts it.
+	assert.Eventually(t, func() bool { return upstream.Accepted() == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+	assert.Equal(t, 0, proxy.busyConnections.Size())
+}
+
+// TestProxyWithMemoryClient tests that the proxy works with any IClient implementation.
+func TestProxyWithMemoryClient(t *testing.T) {
+	query, err := (&pgproto3.Query{String: "SELECT 1"}).Encode(nil)
+	require.NoError(t, err)
+	ready, err := (&pgproto3.ReadyForQuery{TxStatus: byte(TxInTransaction)}).Encode(nil)
+	require.NoError(t, err)
+
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+
+	client := newMockConn(query)
+	conn := NewConnWrapper(ConnWrapper{NetConn: client})
+	require.Nil(t, proxy.Connect(conn))
+	assert.Equal(t, 0, proxy.AvailableConnections.Size())
+
+	// The pipe is synchronous, so the server reads and responds in the background.
+	go func() {
+		buffer := make([]byte, config.DefaultChunkSize)
+		if _, err := server.Read(buffer); err != nil {
+			return
+		}
+		_, _ = server.Write(ready)
+	}()
+
+	require.Nil(t, proxy.PassThroughToServer(conn, NewStack()))
+	require.Nil(t, proxy.PassThroughToClient(conn, NewStack()))
+	assert.Equal(t, ready, client.Written())
+	assert.Equal(t, TxInTransaction, conn.TxStatus())
+	assert.Equal(t, uint64(len(query)), conn.BytesReceived())
+	assert.Equal(t, uint64(len(ready)), conn.BytesSent())
+	assert.Equal(t, map[string]interface{}{
+		"received": uint64(len(query)), "sent": uint64(len(ready)),
+	}, trafficTotals(conn))
+
+	require.Nil(t, proxy.Disconnect(conn))
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+}
+
+// TestProxyRouting tests reporting the backend the client connection is assigned to.
+func TestProxyRouting(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	assert.Nil(t, proxy.Routing(conn))
+
+	require.Nil(t, proxy.Connect(conn))
+	assert.Equal(t, map[string]interface{}{
+		"client":   "memory-client",
+		"backend":  "memory://memory",
+		"network":  "memory",
+		"address":  "memory",
+		"strategy": RoutingFirstAvailable,
+	}, proxy.Routing(conn))
+
+	require.Nil(t, proxy.Disconnect(conn))
+	assert.Nil(t, proxy.Routing(conn))
+}
+
+// TestProxyEmptyRequest tests that the empty requests aren't passed through to the server.
+func TestProxyEmptyRequest(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+
+	client := newMockConn([]byte{}, []byte{})
+	conn := NewConnWrapper(ConnWrapper{NetConn: client})
+	require.Nil(t, proxy.Connect(conn))
+
+	emptyRequests := testutil.ToFloat64(metrics.EmptyRequests)
+	stack := NewStack()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	assert.Equal(t, emptyRequests+1, testutil.ToFloat64(metrics.EmptyRequests))
+	assert.Nil(t, stack.GetLastRequest())
+
+	// The server isn't contacted.
+	require.NoError(t, server.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, err := server.Read(make([]byte, config.DefaultChunkSize))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	// The client connection is closed on an empty request, if configured.
+	proxy.CloseOnEmptyRequest = true
+	assert.ErrorIs(t, proxy.PassThroughToServer(conn, stack), gerr.ErrEmptyRequest)
+}
+
+// failingConn is a client connection that fails to be read from.
+type failingConn struct {
+	*mockConn
+}
+
+func (c *failingConn) Read([]byte) (int, error) {
+	return 0, syscall.ETIMEDOUT
+}
+
+// TestProxyClientDisconnect tests that the clients disconnecting aren't logged as errors.
+func TestProxyClientDisconnect(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+	logs := &bytes.Buffer{}
+	proxy.Logger = zerolog.New(logs).Level(zerolog.WarnLevel)
+
+	// The client closed the connection, either gracefully or not.
+	client := newMockConn()
+	conn := NewConnWrapper(ConnWrapper{NetConn: client})
+	require.Nil(t, proxy.Connect(conn))
+	err := proxy.PassThroughToServer(conn, NewStack())
+	require.ErrorIs(t, err, io.EOF)
+	require.NoError(t, client.Close())
+	err = proxy.PassThroughToServer(conn, NewStack())
+	require.ErrorIs(t, err, net.ErrClosed)
+	assert.ErrorIs(t, err, gerr.ErrClientNotConnected)
+	assert.Empty(t, logs.String())
+
+	// The other errors are logged as errors.
+	_, err = proxy.receiveTrafficFromClient(&failingConn{newMockConn()})
+	require.ErrorIs(t, err, syscall.ETIMEDOUT)
+	assert.Contains(t, logs.String(), "Error reading from client")
+}
+
+// TestProxyRequestVerifier tests forwarding the original request if the request
+// modified by the plugins is rejected by the request verifier.
+func TestProxyRequestVerifier(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+
+	query := CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00"))
+	modified := CreatePostgreSQLPacket('Q', []byte("SELECT 2\x00"))
+	parse := CreatePostgreSQLPacket('P', []byte("\x00SELECT 1\x00\x00\x00"))
+
+	// Without a verifier, the request can be modified into other messages.
+	assert.Equal(t, parse, proxy.getPluginModifiedRequest(
+		map[string]interface{}{"request": parse}, query))
+
+	proxy.RequestVerifier = VerifyMessageTypes
+	assert.Equal(t, modified, proxy.getPluginModifiedRequest(
+		map[string]interface{}{"request": modified}, query))
+
+	rejected := testutil.ToFloat64(metrics.RejectedHookModifications.WithLabelValues(
+		"request", errMessagesChanged.Error()))
+	assert.Nil(t, proxy.getPluginModifiedRequest(map[string]interface{}{"request": parse}, query))
+	assert.Equal(t, rejected+1, testutil.ToFloat64(metrics.RejectedHookModifications.WithLabelValues(
+		"request", errMessagesChanged.Error())))
+}
+
+// TestProxyIncompleteMessage tests failing the client and recycling the server
+// connection if the server doesn't complete a message in time.
+func TestProxyIncompleteMessage(t *testing.T) {
+	response := CreatePostgreSQLPacket('C', []byte("SELECT 1\x00"))
+	upstream := newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		if _, err := conn.Read(make([]byte, config.DefaultChunkSize)); err != nil {
+			return
+		}
+		// The message is never completed.
+		_, _ = conn.Write(response[:len(response)-3])
+		_, _ = conn.Read(make([]byte, 1))
+	})
+
+	clientConfig := newTestClientConfig(upstream.Address())
+	clientConfig.ReceiveStrategy = config.ReadFramed
+	clientConfig.MaxMessageAssemblyTime = 50 * time.Millisecond
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	proxy := newTestProxyWithClients(t, clientConfig, client)
+
+	incomplete := testutil.ToFloat64(metrics.IncompleteMessages)
+	clientConn := newMockConn(CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00")))
+	conn := NewConnWrapper(ConnWrapper{NetConn: clientConn})
+	require.Nil(t, proxy.Connect(conn))
+	stack := NewStack()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	gErr := proxy.PassThroughToClient(conn, stack)
+	require.NotNil(t, gErr)
+	require.ErrorIs(t, gErr, errMessageAssemblyTimeout)
+
+	// The client gets an error instead of the incomplete message.
+	assert.Contains(t, string(clientConn.Written()), "08P01")
+	assert.NotContains(t, string(clientConn.Written()), "SELECT 1")
+	assert.Equal(t, incomplete+1, testutil.ToFloat64(metrics.IncompleteMessages))
+
+	// The server connection is recycled right away.
+	assert.Eventually(t, func() bool { return upstream.Accepted() == 2 }, time.Second, 10*time.Millisecond)
+	require.Nil(t, proxy.Disconnect(conn))
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+}
+
+// TestProxyWithoutClientConfig tests that a proxy without a client config rejects
+// the clients instead of failing on them.
+func TestProxyWithoutClientConfig(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, nil, memClient)
+
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.ErrorIs(t, proxy.Connect(conn), gerr.ErrNilPointer)
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+	assert.Equal(t, 0, proxy.busyConnections.Size())
+
+	// The rest doesn't depend on the client config either.
+	memClient.Close()
+	proxy.replaceClient(memClient)
+	request, err := proxy.receiveTrafficFromClient(newMockConn([]byte("SELECT 1")))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("SELECT 1"), request)
+}
+
+// TestHealthCheckJitter tests spreading the recycling of the clients over the
+// jitter of the health check period.
+func TestHealthCheckJitter(t *testing.T) {
+	upstream := newFakeUpstream(t, func(net.Conn) {})
+	available, _ := newMemoryClient("available")
+	busy, _ := newMemoryClient("busy")
+	proxy := newTestProxyWithClients(t, newTestClientConfig(upstream.Address()), available)
+
+	assert.Zero(t, proxy.healthCheckDelay())
+	proxy.HealthCheckJitter = 0.5
+	for range 100 {
+		delay := proxy.healthCheckDelay()
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.Less(t, delay, proxy.HealthCheckPeriod/2)
+	}
+
+	// The clients that aren't available any more are left alone.
+	proxy.recycleClient(busy)
+	assert.True(t, busy.IsConnected())
+
+	proxy.recycleClient(available)
+	assert.False(t, available.IsConnected())
+	assert.Nil(t, proxy.AvailableConnections.Get("available"))
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+	assert.Eventually(t, func() bool { return upstream.Accepted() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+// TestProxyPoolAcquisitions tests counting the server connections acquired from the pool.
+func TestProxyPoolAcquisitions(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+
+	acquisitions := testutil.ToFloat64(metrics.PoolAcquisitions)
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn()})
+	require.Nil(t, proxy.Connect(conn))
+	assert.Equal(t, acquisitions+1, testutil.ToFloat64(metrics.PoolAcquisitions))
+	require.Nil(t, proxy.Disconnect(conn))
+}
@@ -0,0 +1,106 @@
+package network
+
+import (
+	"encoding/binary"
+
+	"github.com/gatewayd-io/gatewayd-plugin-sdk/databases/postgres"
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"go.opentelemetry.io/otel"
+)
+
+// pgReadOnlySQLTransaction is the SQLSTATE of the writes in a read-only transaction:
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const pgReadOnlySQLTransaction = "25006"
+
+// SetReadOnly turns the read-only mode of the proxy on or off. In read-only mode,
+// the requests that write to the database are rejected with an error, while the
+// reads are passed through as usual. It returns true if the mode changed.
+func (pr *Proxy) SetReadOnly(readOnly bool) bool {
+	if pr.readOnly.Swap(readOnly) == readOnly {
+		return false
+	}
+
+	if readOnly {
+		pr.Logger.Info().Msg("Proxy is in read-only mode, the writes are rejected")
+	} else {
+		pr.Logger.Info().Msg("Proxy is out of read-only mode")
+	}
+	return true
+}
+
+// IsReadOnly returns true if the proxy is in read-only mode.
+func (pr *Proxy) IsReadOnly() bool {
+	return pr.readOnly.Load()
+}
+
+// rejectWrite replies to the request that writes to the database with an error,
+// as PostgreSQL does in a read-only transaction, followed by a ReadyForQuery message
+// if the request ends a query cycle. The transaction of the client, if any, isn't
+// aborted, since the request isn't sent to the server.
+func (pr *Proxy) rejectWrite(conn *ConnWrapper, request []byte, command string) *gerr.GatewayDError {
+	_, span := otel.Tracer(config.TracerName).Start(pr.ctx, "rejectWrite")
+	defer span.End()
+
+	pr.Logger.Debug().Fields(
+		map[string]interface{}{
+			"command": command,
+			"remote":  RemoteAddr(conn.Conn()),
+		},
+	).Msg("Rejected a write in read-only mode")
+	span.AddEvent("Rejected a write in read-only mode")
+	metrics.ReadOnlyRejections.Inc()
+
+	response := postgres.ErrorResponse(
+		"cannot execute "+command+" while GatewayD is in read-only mode",
+		"ERROR", pgReadOnlySQLTransaction, "The writes are rejected for maintenance")
+	if endsQueryCycle(request) {
+		response = append(response, pgReadyForQuery, 0, 0, 0, 5, byte(conn.TxStatus()))
+	}
+
+	return pr.sendTrafficToClient(conn, response, len(response))
+}
+
+// completeRequest reads the rest of the last message of the request from the client,
+// if it's split across reads, e.g. a long query, so that it's classified and passed
+// through as a whole, and the next request starts at a message boundary. The malformed
+// messages aren't read further, and they're rejected as incomplete.
+func (pr *Proxy) completeRequest(conn *ConnWrapper, request []byte) ([]byte, *gerr.GatewayDError) {
+	for !IsPostgresMessages(request) && !isMalformedRequest(request) {
+		chunk, err := pr.receiveTrafficFromClient(conn.Conn())
+		if err != nil {
+			return request, err
+		}
+		request = append(request, chunk...)
+	}
+	return request, nil
+}
+
+// isMalformedRequest returns true if a message of the request has an invalid length.
+func isMalformedRequest(request []byte) bool {
+	for offset := 0; offset+pgHeaderLength <= len(request); {
+		length := int(binary.BigEndian.Uint32(request[offset+1 : offset+pgHeaderLength]))
+		if length < 4 {
+			return true
+		}
+		offset += 1 + length
+	}
+	return false
+}
+
+// endsQueryCycle returns true if the request has a simple query or a Sync message,
+// to which the server responds with a ReadyForQuery message.
+func endsQueryCycle(request []byte) bool {
+	for offset := 0; offset+pgHeaderLength <= len(request); {
+		length := int(binary.BigEndian.Uint32(request[offset+1 : offset+pgHeaderLength]))
+		if request[offset] == pgQuery || request[offset] == pgSync {
+			return true
+		}
+		if length < 4 {
+			return false
+		}
+		offset += 1 + length
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProxyReadOnly tests rejecting the writes and passing the reads through in read-only mode.
+func TestProxyReadOnly(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+
+	write := CreatePostgreSQLPacket('Q', []byte("DELETE FROM users\x00"))
+	read := CreatePostgreSQLPacket('Q', []byte("SELECT * FROM users\x00"))
+	client := newMockConn(write, read)
+	conn := NewConnWrapper(ConnWrapper{NetConn: client})
+	require.Nil(t, proxy.Connect(conn))
+
+	assert.False(t, proxy.IsReadOnly())
+	assert.True(t, proxy.SetReadOnly(true))
+	assert.False(t, proxy.SetReadOnly(true), "the proxy is already in read-only mode")
+	assert.True(t, proxy.IsReadOnly())
+
+	// The write is rejected with an error, without contacting the server.
+	stack := NewStack()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	assert.Nil(t, stack.GetLastRequest())
+	frontend := pgproto3.NewFrontend(newMockConn(client.Written()), nil)
+	message, err := frontend.Receive()
+	require.NoError(t, err)
+	errorResponse, ok := message.(*pgproto3.ErrorResponse)
+	require.True(t, ok)
+	assert.Equal(t, "25006", errorResponse.Code)
+	assert.Contains(t, errorResponse.Message, "DELETE")
+	message, err = frontend.Receive()
+	require.NoError(t, err)
+	assert.Equal(t, &pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)}, message)
+
+	require.NoError(t, server.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, err = server.Read(make([]byte, config.DefaultChunkSize))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	// The read is passed through to the server.
+	require.NoError(t, server.SetReadDeadline(time.Time{}))
+	received := make(chan []byte, 1)
+	go func() {
+		buffer := make([]byte, config.DefaultChunkSize)
+		n, _ := server.Read(buffer)
+		received <- buffer[:n]
+	}()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	assert.Equal(t, read, <-received)
+}
+
+// TestProxyReadOnlySplitRequest tests classifying a write split across reads as a
+// whole in read-only mode, instead of passing its first part through as a read.
+func TestProxyReadOnlySplitRequest(t *testing.T) {
+	memClient, server := newMemoryClient("memory-client")
+	defer server.Close()
+	proxy := newTestProxyWithClients(t, newTestClientConfig("memory"), memClient)
+
+	write := CreatePostgreSQLPacket('Q', []byte("SELECT 1; DELETE FROM users\x00"))
+	client := newMockConn(write[:10], write[10:])
+	conn := NewConnWrapper(ConnWrapper{NetConn: client})
+	require.Nil(t, proxy.Connect(conn))
+	proxy.SetReadOnly(true)
+
+	stack := NewStack()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	assert.Nil(t, stack.GetLastRequest())
+	frontend := pgproto3.NewFrontend(newMockConn(client.Written()), nil)
+	message, err := frontend.Receive()
+	require.NoError(t, err)
+	errorResponse, ok := message.(*pgproto3.ErrorResponse)
+	require.True(t, ok)
+	assert.Contains(t, errorResponse.Message, "DELETE")
+
+	require.NoError(t, server.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, err = server.Read(make([]byte, config.DefaultChunkSize))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
@@ -0,0 +1,85 @@
+package network
+
+import (
+	"errors"
+	"net"
+
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"go.opentelemetry.io/otel"
+)
+
+// errNotRunning is the error of rebinding the server that isn't running.
+var errNotRunning = errors.New("the server isn't running")
+
+// Rebind moves the server to a new listen address, e.g. on reload, without dropping
+// the existing connections: the new listener is started first and the new connections
+// are accepted from it, then the old listener is closed, which only stops accepting
+// on the old address. If the server can't listen on the new address, it keeps its old
+// listener and returns an error. It returns false if the address didn't change.
+func (s *Server) Rebind(address string) (bool, *gerr.GatewayDError) {
+	_, span := otel.Tracer("gatewayd").Start(s.ctx, "Rebind")
+	defer span.End()
+
+	if !s.running.Load() {
+		return false, gerr.ErrServerListenFailed.Wrap(errNotRunning)
+	}
+
+	addr, err := Resolve(s.Network, address, s.Logger)
+	if err != nil {
+		s.Logger.Error().Err(err).Str("address", address).Msg(
+			"Failed to resolve the new address, keeping the old listener")
+		span.RecordError(err)
+		return false, err
+	}
+
+	s.mu.RLock()
+	oldAddress := s.Address
+	s.mu.RUnlock()
+	if addr == oldAddress {
+		return false, nil
+	}
+
+	listener, origErr := s.listen(addr)
+	if origErr != nil {
+		s.Logger.Error().Err(origErr).Fields(
+			map[string]interface{}{
+				"address":    addr,
+				"oldAddress": oldAddress,
+			},
+		).Msg("Failed to listen on the new address, keeping the old listener")
+		span.RecordError(origErr)
+		return false, gerr.ErrServerListenFailed.Wrap(origErr)
+	}
+
+	// The server may be stopped meanwhile, in which case it doesn't listen anymore.
+	s.mu.Lock()
+	if !s.running.Load() || s.listener == nil {
+		s.mu.Unlock()
+		listener.Close()
+		return false, gerr.ErrServerListenFailed.Wrap(errNotRunning)
+	}
+	oldListener := s.listener
+	s.listener = listener
+	s.Address = addr
+	s.mu.Unlock()
+
+	if err := s.setHostPort(listener); err != nil {
+		span.RecordError(err)
+	}
+
+	// Closing the old listener stops accepting on the old address, and moves the accept
+	// loop to the new listener. The connections accepted on it aren't affected.
+	if err := oldListener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.Logger.Error().Err(err).Msg("Failed to close the old listener")
+		span.RecordError(err)
+	}
+
+	s.Logger.Info().Fields(
+		map[string]interface{}{
+			"address":     addr,
+			"oldAddress":  oldAddress,
+			"connections": s.CountConnections(),
+		},
+	).Msg("Rebound the server to the new address, the existing connections are kept")
+	return true, nil
+}
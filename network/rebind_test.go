@@ -0,0 +1,80 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestServerRebind tests moving the server to a new listen address without
+// dropping the existing connections, and keeping the old address on failure.
+func TestServerRebind(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &Server{
+		ctx:      context.Background(),
+		Logger:   zerolog.Nop(),
+		Network:  "tcp",
+		Address:  listener.Addr().String(),
+		listener: listener,
+		mu:       &sync.RWMutex{},
+		running:  &atomic.Bool{},
+	}
+	server.running.Store(true)
+	t.Cleanup(func() { server.currentListener().Close() })
+
+	// A connection accepted on the old address.
+	client, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	accepted, err := listener.Accept()
+	require.NoError(t, err)
+	defer accepted.Close()
+
+	// The same address isn't rebound.
+	rebound, gErr := server.Rebind(listener.Addr().String())
+	assert.Nil(t, gErr)
+	assert.False(t, rebound)
+
+	// The address in use can't be listened on, so the old listener is kept.
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer occupied.Close()
+	rebound, gErr = server.Rebind(occupied.Addr().String())
+	assert.NotNil(t, gErr)
+	assert.False(t, rebound)
+	assert.Equal(t, listener, server.currentListener())
+	assert.Equal(t, listener.Addr().String(), server.Address)
+
+	// The new connections are accepted on the new address only.
+	rebound, gErr = server.Rebind("127.0.0.1:0")
+	assert.Nil(t, gErr)
+	assert.True(t, rebound)
+	assert.NotEqual(t, listener, server.currentListener())
+	_, err = net.Dial("tcp", listener.Addr().String())
+	assert.Error(t, err)
+	newClient, err := net.Dial("tcp", server.currentListener().Addr().String())
+	require.NoError(t, err)
+	newClient.Close()
+
+	// The existing connection isn't affected.
+	_, err = client.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = accepted.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+
+	// The stopped server isn't rebound.
+	require.NoError(t, server.stopListening())
+	rebound, gErr = server.Rebind("127.0.0.1:0")
+	assert.NotNil(t, gErr)
+	assert.False(t, rebound)
+}
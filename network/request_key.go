@@ -0,0 +1,80 @@
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// pgQuery is the message type of the simple query protocol.
+	pgQuery = 'Q'
+
+	// QueryField is the field of the OnTrafficFromClient hook payload with the
+	// query of a simple query request, if the request is one.
+	QueryField = "query"
+	// RequestKeyField is the field of the OnTrafficFromClient hook payload with
+	// the key of the request, for caching the responses in the plugins.
+	//
+	// A plugin serves a cached response by returning it in the "response" field
+	// of its result, with the terminate action, so that the request isn't sent
+	// to the server. The proxy doesn't cache the responses itself, so the expiry
+	// of the cached responses is up to the plugin.
+	RequestKeyField = "requestKey"
+)
+
+// GetQuery returns the query of a request that is a single simple query message.
+func GetQuery(request []byte) (string, bool) {
+	if len(request) < pgHeaderLength+1 || request[0] != pgQuery {
+		return "", false
+	}
+
+	length := int(binary.BigEndian.Uint32(request[1:pgHeaderLength]))
+	if 1+length != len(request) || request[len(request)-1] != 0 {
+		return "", false
+	}
+
+	return string(request[pgHeaderLength : len(request)-1]), true
+}
+
+// RequestKey returns a stable key of the request, as a hex-encoded SHA-256 hash.
+// The key of a simple query is the hash of the query without the surrounding
+// whitespace, so that the same query sent by different clients has the same key.
+// The key of any other request is the hash of its raw bytes.
+func RequestKey(request []byte) string {
+	hash := sha256.New()
+	if query, ok := GetQuery(request); ok {
+		hash.Write([]byte{pgQuery})
+		hash.Write([]byte(strings.TrimSpace(query)))
+	} else {
+		hash.Write(request)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// requestFields returns the fields of the OnTrafficFromClient hook payload that
+// describe the request: the request itself, its key and its query, if any.
+func requestFields(request []byte) []Field {
+	fields := []Field{
+		{
+			Name:  "request",
+			Value: request,
+		},
+	}
+	if len(request) == 0 {
+		return fields
+	}
+
+	fields = append(fields, Field{
+		Name:  RequestKeyField,
+		Value: RequestKey(request),
+	})
+	if query, ok := GetQuery(request); ok {
+		fields = append(fields, Field{
+			Name:  QueryField,
+			Value: query,
+		})
+	}
+	return fields
+}
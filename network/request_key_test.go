@@ -0,0 +1,50 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetQuery tests getting the query of a simple query request.
+func TestGetQuery(t *testing.T) {
+	query, ok := GetQuery(CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00")))
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT 1", query)
+
+	_, ok = GetQuery(CreatePostgreSQLPacket('P', []byte("\x00SELECT 1\x00\x00\x00")))
+	assert.False(t, ok)
+	_, ok = GetQuery(CreatePostgreSQLPacket('Q', []byte("SELECT 1")))
+	assert.False(t, ok, "the query must be null-terminated")
+	_, ok = GetQuery([]byte{'Q'})
+	assert.False(t, ok)
+}
+
+// TestRequestKey tests that the request keys are stable.
+func TestRequestKey(t *testing.T) {
+	key := RequestKey(CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00")))
+	assert.Len(t, key, 64)
+	assert.Equal(t, key, RequestKey(CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00"))))
+	assert.Equal(t, key, RequestKey(CreatePostgreSQLPacket('Q', []byte("  SELECT 1\n\x00"))))
+	assert.NotEqual(t, key, RequestKey(CreatePostgreSQLPacket('Q', []byte("SELECT 2\x00"))))
+
+	parse := CreatePostgreSQLPacket('P', []byte("\x00SELECT 1\x00\x00\x00"))
+	assert.Equal(t, RequestKey(parse), RequestKey(parse))
+	assert.NotEqual(t, key, RequestKey(parse))
+}
+
+// TestRequestFields tests the fields of the request in the hook payload.
+func TestRequestFields(t *testing.T) {
+	client, server := newMemoryClient("client")
+	defer server.Close()
+
+	request := CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00"))
+	data := trafficData(newMockConn(), client, requestFields(request), nil)
+	assert.Equal(t, request, data["request"])
+	assert.Equal(t, RequestKey(request), data[RequestKeyField])
+	assert.Equal(t, "SELECT 1", data[QueryField])
+
+	data = trafficData(newMockConn(), client, requestFields(nil), nil)
+	assert.NotContains(t, data, RequestKeyField)
+	assert.NotContains(t, data, QueryField)
+}
@@ -0,0 +1,49 @@
+package network
+
+import (
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/gatewayd-io/gatewayd/metrics"
+)
+
+// retryRequest resends the last request of the client on a new server connection, if
+// the server closed or reset the connection before responding, e.g. after a restart of
+// the server or an idle timeout of a load balancer in between. The request is retried
+// once and only if it's outside a transaction and the server session can be restored,
+// i.e. it's pre-authenticated, and its prepared statements are prepared again. Only the
+// reads are retried, since the server may have run the request before the connection
+// was reset, and the old session is canceled first, in case it's still running it.
+// Otherwise, the error is returned as is, and the server connection is recycled on
+// disconnect.
+func (pr *Proxy) retryRequest(
+	conn *ConnWrapper, client IClient, stack *Stack,
+	received int, response []byte, err *gerr.GatewayDError,
+) (int, []byte, *gerr.GatewayDError) {
+	if !pr.RetryOnReset || err == nil || received > 0 || !IsConnClosed(err) {
+		return received, response, err
+	}
+
+	lastRequest := stack.GetLastRequest()
+	if lastRequest == nil || conn.TxStatus().InTransaction() || client.StartupResponse() == nil {
+		return received, response, err
+	}
+	if _, write := RequestWriteCommand(lastRequest.Data, conn.PreparedStatements()); write {
+		return received, response, err
+	}
+
+	pr.Logger.Debug().Err(err).Msg("Server closed the connection, retrying the request")
+	pr.cancelServerSession(client)
+	if reconnectErr := client.Reconnect(); reconnectErr != nil {
+		pr.Logger.Error().Err(reconnectErr).Msg("Failed to reconnect to retry the request")
+		return received, response, err
+	}
+	if replayErr := pr.replayPreparedStatements(conn, client, lastRequest.Data); replayErr != nil {
+		pr.Logger.Error().Err(replayErr).Msg("Failed to restore the session to retry the request")
+		return received, response, err
+	}
+	metrics.RetriedRequests.Inc()
+
+	if _, sendErr := pr.sendTrafficToServer(withLabels(pr.Logger, conn), client, lastRequest.Data); sendErr != nil {
+		return 0, nil, sendErr
+	}
+	return pr.receiveTrafficFromServer(client)
+}
@@ -0,0 +1,129 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newResettingPostgres returns a fake PostgreSQL server that trusts the clients and
+// resets the connection on the queries while resets is positive, and otherwise
+// answers the queries with their command tags.
+func newResettingPostgres(t *testing.T, resets *atomic.Int32) *fakeUpstream {
+	t.Helper()
+
+	return newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		backend := pgproto3.NewBackend(conn, conn)
+		if _, err := backend.ReceiveStartupMessage(); err != nil {
+			return
+		}
+		backend.Send(&pgproto3.AuthenticationOk{})
+		backend.Send(&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)})
+		if backend.Flush() != nil {
+			return
+		}
+
+		for {
+			message, err := backend.Receive()
+			if err != nil {
+				return
+			}
+			query, ok := message.(*pgproto3.Query)
+			if !ok {
+				continue
+			}
+			if resets.Add(-1) >= 0 {
+				// Closing the connection with no linger sends an RST instead of a FIN.
+				if tcpConn, ok := conn.(*net.TCPConn); ok {
+					_ = tcpConn.SetLinger(0)
+				}
+				return
+			}
+			backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(query.String)})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)})
+			if backend.Flush() != nil {
+				return
+			}
+		}
+	})
+}
+
+// TestProxyConnectionReset tests that the server connection reset by the server is
+// recycled like a closed one, and that the request is retried if configured.
+func TestProxyConnectionReset(t *testing.T) {
+	resets := &atomic.Int32{}
+	upstream := newResettingPostgres(t, resets)
+
+	clientConfig := newTestClientConfig(upstream.Address())
+	clientConfig.User = "postgres"
+	clientConfig.PreAuthenticate = true
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+	proxy := newTestProxyWithClients(t, clientConfig, client)
+
+	query, err := (&pgproto3.Query{String: "SELECT 1"}).Encode(nil)
+	require.NoError(t, err)
+
+	// The server resets the connection in the middle of the request.
+	resets.Store(1)
+	conn := NewConnWrapper(ConnWrapper{NetConn: newMockConn(query)})
+	require.Nil(t, proxy.Connect(conn))
+	stack := NewStack()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	gErr := proxy.PassThroughToClient(conn, stack)
+	require.NotNil(t, gErr)
+	assert.True(t, IsConnClosed(gErr))
+
+	// The server connection is recycled, as if the server closed it.
+	require.Nil(t, proxy.Disconnect(conn))
+	// The reconnection may be established before the upstream accepts it.
+	assert.Eventually(t, func() bool { return upstream.Accepted() == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+
+	// The request is retried on a new server connection.
+	proxy.RetryOnReset = true
+	retried := testutil.ToFloat64(metrics.RetriedRequests)
+	resets.Store(1)
+	client2 := newMockConn(query, query)
+	conn = NewConnWrapper(ConnWrapper{NetConn: client2})
+	require.Nil(t, proxy.Connect(conn))
+	stack = NewStack()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	require.Nil(t, proxy.PassThroughToClient(conn, stack))
+	assert.Contains(t, string(client2.Written()), "SELECT 1")
+	assert.Eventually(t, func() bool { return upstream.Accepted() == 3 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, retried+1, testutil.ToFloat64(metrics.RetriedRequests))
+
+	// The requests in a transaction aren't retried.
+	resets.Store(1)
+	conn.SetTxStatus(TxInTransaction)
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	require.NotNil(t, proxy.PassThroughToClient(conn, stack))
+	assert.Equal(t, 3, upstream.Accepted())
+	assert.Equal(t, retried+1, testutil.ToFloat64(metrics.RetriedRequests))
+	require.Nil(t, proxy.Disconnect(conn))
+	assert.Eventually(t, func() bool { return upstream.Accepted() == 4 }, time.Second, 10*time.Millisecond)
+
+	// The writes aren't retried, since the server may have run them before the reset.
+	write, err := (&pgproto3.Query{String: "DELETE FROM users"}).Encode(nil)
+	require.NoError(t, err)
+	resets.Store(1)
+	conn = NewConnWrapper(ConnWrapper{NetConn: newMockConn(write)})
+	require.Nil(t, proxy.Connect(conn))
+	stack = NewStack()
+	require.Nil(t, proxy.PassThroughToServer(conn, stack))
+	require.NotNil(t, proxy.PassThroughToClient(conn, stack))
+	assert.Equal(t, retried+1, testutil.ToFloat64(metrics.RetriedRequests))
+	require.Nil(t, proxy.Disconnect(conn))
+	assert.Eventually(t, func() bool { return upstream.Accepted() == 5 }, time.Second, 10*time.Millisecond)
+}
@@ -3,8 +3,11 @@ package network
 import (
 	"errors"
 	"math"
+	"math/rand"
+	"sync"
 	"time"
 
+	"github.com/gatewayd-io/gatewayd/metrics"
 	"github.com/rs/zerolog"
 )
 
@@ -24,7 +27,10 @@ type Retry struct {
 	Backoff            time.Duration
 	BackoffMultiplier  float64
 	DisableBackoffCaps bool
+	Jitter             float64 // Fraction of the backoff duration that is randomized.
 	Logger             zerolog.Logger
+	Budget             *RetryBudget
+	Limiter            *ReconnectLimiter
 }
 
 var _ IRetry = (*Retry)(nil)
@@ -76,7 +82,26 @@ func (r *Retry) Retry(callback RetryCallback) (any, error) {
 			backoffDuration = BackoffDurationCap
 		}
 
+		// Spread the retries of the clients that failed at the same time, e.g. when
+		// a backend comes back after an outage, by shortening the backoff at random.
+		if r.Jitter > 0 {
+			backoffDuration -= time.Duration(
+				rand.Float64() * math.Min(r.Jitter, 1) * float64(backoffDuration)) //nolint:gosec
+		}
+
 		if retry > 0 {
+			// Retries (not the first attempt) are gated by the shared retry budget,
+			// so that a failing backend isn't hammered by retries from every client.
+			if !r.Budget.Allow() {
+				r.Logger.Debug().Fields(
+					map[string]interface{}{
+						"retry": retry,
+					},
+				).Msg("Retry budget is exhausted, dropping the retry")
+				metrics.RetryBudgetExhausted.Inc()
+				break
+			}
+
 			r.Logger.Debug().Fields(
 				map[string]interface{}{
 					"retry": retry,
@@ -87,8 +112,11 @@ func (r *Retry) Retry(callback RetryCallback) (any, error) {
 			r.Logger.Trace().Msg("First attempt to run callback")
 		}
 
-		// Try and retry the callback.
+		// Try and retry the callback, with at most as many attempts running at
+		// the same time as the limiter allows.
+		r.Limiter.Acquire()
 		object, err = callback()
+		r.Limiter.Release()
 		if err == nil {
 			return object, nil
 		}
@@ -109,7 +137,10 @@ func NewRetry(
 		Backoff:            rty.Backoff,
 		BackoffMultiplier:  rty.BackoffMultiplier,
 		DisableBackoffCaps: rty.DisableBackoffCaps,
+		Jitter:             rty.Jitter,
 		Logger:             rty.Logger,
+		Budget:             rty.Budget,
+		Limiter:            rty.Limiter,
 	}
 
 	// If the number of retries is less than 0, set it to 0 to disable retries.
@@ -123,3 +154,97 @@ func NewRetry(
 
 	return &retry
 }
+
+// RetryBudget is a token bucket shared by all the retries of a proxy. Each retry
+// consumes a token and tokens are refilled at a constant rate, up to the burst size.
+// When the bucket is empty, retries are dropped instead of being attempted.
+type RetryBudget struct {
+	Rate  float64 // Tokens added per second.
+	Burst int     // Maximum number of tokens in the bucket.
+
+	tokens   float64
+	lastFill time.Time
+	mu       sync.Mutex
+}
+
+// Allow consumes a token from the budget and returns true if a retry is allowed.
+// A nil budget or a budget with a non-positive rate never drops retries.
+func (b *RetryBudget) Allow() bool {
+	if b == nil || b.Rate <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(
+		float64(b.Burst), b.tokens+now.Sub(b.lastFill).Seconds()*b.Rate)
+	b.lastFill = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// Tokens returns the number of tokens currently available in the budget.
+func (b *RetryBudget) Tokens() float64 {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.tokens
+}
+
+// NewRetryBudget creates a new retry budget with a full bucket.
+func NewRetryBudget(rate float64, burst int) *RetryBudget {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RetryBudget{
+		Rate:     rate,
+		Burst:    burst,
+		tokens:   float64(burst),
+		lastFill: time.Now(),
+	}
+}
+
+// ReconnectLimiter is a semaphore shared by all the retries of a proxy, which caps
+// the number of connection attempts running at the same time, so that a recovering
+// backend isn't hammered by the reconnections of the whole pool at once.
+type ReconnectLimiter struct {
+	slots chan struct{}
+}
+
+// Acquire waits for a free slot. A nil limiter never waits.
+func (l *ReconnectLimiter) Acquire() {
+	if l == nil {
+		return
+	}
+	l.slots <- struct{}{}
+}
+
+// Release frees the slot taken by Acquire.
+func (l *ReconnectLimiter) Release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// NewReconnectLimiter creates a new reconnect limiter that allows up to the given
+// number of concurrent connection attempts, or returns nil if there's no limit.
+func NewReconnectLimiter(maxConcurrent int) *ReconnectLimiter {
+	if maxConcurrent <= 0 {
+		return nil
+	}
+
+	return &ReconnectLimiter{slots: make(chan struct{}, maxConcurrent)}
+}
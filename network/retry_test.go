@@ -2,7 +2,10 @@ package network
 
 import (
 	"context"
+	"errors"
 	"net"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -30,7 +33,7 @@ func TestRetry(t *testing.T) {
 			assert.ErrorContains(t, err, "callback is nil")
 		})
 		t.Run("retry without timeout", func(t *testing.T) {
-			retry := NewRetry(Retry{0, 0, 0, false, logger})
+			retry := NewRetry(Retry{0, 0, 0, false, 0, logger, nil, nil})
 			assert.Equal(t, 0, retry.Retries)
 			assert.Equal(t, time.Duration(0), retry.Backoff)
 			assert.Equal(t, float64(0), retry.BackoffMultiplier)
@@ -55,13 +58,17 @@ func TestRetry(t *testing.T) {
 					config.DefaultBackoff,
 					config.DefaultBackoffMultiplier,
 					config.DefaultDisableBackoffCaps,
+					config.DefaultBackoffJitter,
 					logger,
+					nil,
+					nil,
 				},
 			)
 			assert.Equal(t, config.DefaultRetries, retry.Retries)
 			assert.Equal(t, config.DefaultBackoff, retry.Backoff)
 			assert.Equal(t, config.DefaultBackoffMultiplier, retry.BackoffMultiplier)
 			assert.False(t, retry.DisableBackoffCaps)
+			assert.Equal(t, config.DefaultBackoffJitter, retry.Jitter)
 
 			conn, err := retry.Retry(func() (any, error) {
 				return net.DialTimeout("tcp", "localhost:5432", config.DefaultDialTimeout)
@@ -77,3 +84,96 @@ func TestRetry(t *testing.T) {
 		})
 	})
 }
+
+func TestRetryBudget(t *testing.T) {
+	t.Run("nil budget", func(t *testing.T) {
+		var budget *RetryBudget
+		assert.True(t, budget.Allow())
+		assert.Equal(t, float64(0), budget.Tokens())
+	})
+	t.Run("disabled budget", func(t *testing.T) {
+		budget := NewRetryBudget(0, 1)
+		for range 10 {
+			assert.True(t, budget.Allow())
+		}
+	})
+	t.Run("exhausted budget", func(t *testing.T) {
+		budget := NewRetryBudget(0.001, 2)
+		assert.True(t, budget.Allow())
+		assert.True(t, budget.Allow())
+		assert.False(t, budget.Allow())
+	})
+	t.Run("refilled budget", func(t *testing.T) {
+		budget := NewRetryBudget(1000, 1)
+		assert.True(t, budget.Allow())
+		time.Sleep(10 * time.Millisecond)
+		assert.True(t, budget.Allow())
+	})
+	t.Run("retries are dropped", func(t *testing.T) {
+		logger := zerolog.Nop()
+		retry := NewRetry(
+			Retry{
+				Retries:           5,
+				Backoff:           time.Millisecond,
+				BackoffMultiplier: 1,
+				Logger:            logger,
+				Budget:            NewRetryBudget(0.001, 1),
+			},
+		)
+
+		attempts := 0
+		_, err := retry.Retry(func() (any, error) {
+			attempts++
+			return nil, errors.New("failed")
+		})
+		assert.Error(t, err)
+		// The first attempt plus a single retry allowed by the budget.
+		assert.Equal(t, 2, attempts)
+	})
+}
+
+func TestReconnectLimiter(t *testing.T) {
+	t.Run("nil limiter", func(t *testing.T) {
+		assert.Nil(t, NewReconnectLimiter(0))
+		var limiter *ReconnectLimiter
+		limiter.Acquire()
+		limiter.Release()
+	})
+	t.Run("concurrent attempts are capped", func(t *testing.T) {
+		retry := NewRetry(
+			Retry{
+				Retries:           2,
+				Backoff:           time.Millisecond,
+				BackoffMultiplier: 1,
+				Jitter:            1,
+				Logger:            zerolog.Nop(),
+				Limiter:           NewReconnectLimiter(2),
+			},
+		)
+
+		var running, maxRunning atomic.Int32
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := retry.Retry(func() (any, error) {
+					current := running.Add(1)
+					defer running.Add(-1)
+					for {
+						highest := maxRunning.Load()
+						if current <= highest || maxRunning.CompareAndSwap(highest, current) {
+							break
+						}
+					}
+					time.Sleep(5 * time.Millisecond)
+					return nil, errors.New("failed")
+				})
+				assert.Error(t, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(2), maxRunning.Load())
+	})
+}
@@ -0,0 +1,36 @@
+package network
+
+// RoutingFirstAvailable is the strategy of assigning the first available server
+// connection of the pool to the client. The server connections are spread over
+// the backends when the pool is filled, and moved to a healthy backend on failover.
+const RoutingFirstAvailable = "firstAvailable"
+
+// RoutingCertificate is the strategy of assigning a server connection of the backend
+// of the certificate route matching the client certificate to the client.
+const RoutingCertificate = "certificate"
+
+// Routing returns the routing decision of the client connection as structured
+// fields: the server connection it's assigned to, the backend of the server
+// connection and the strategy of the assignment. It returns nil if the client
+// connection isn't assigned to a server connection.
+func (pr *Proxy) Routing(conn *ConnWrapper) map[string]interface{} {
+	client, ok := pr.busyConnections.Get(conn).(IClient)
+	if !ok || client == nil {
+		return nil
+	}
+
+	strategy := RoutingFirstAvailable
+	if value, ok := pr.balanced.Load(conn); ok {
+		if balanced, ok := value.(string); ok {
+			strategy = balanced
+		}
+	}
+
+	return map[string]interface{}{
+		"client":   client.GetID(),
+		"backend":  client.GetNetwork() + "://" + client.GetAddress(),
+		"network":  client.GetNetwork(),
+		"address":  client.GetAddress(),
+		"strategy": strategy,
+	}
+}
@@ -7,16 +7,20 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"slices"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/gatewayd-io/gatewayd-plugin-sdk/databases/postgres"
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
 	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
 	"github.com/gatewayd-io/gatewayd/config"
 	gerr "github.com/gatewayd-io/gatewayd/errors"
 	"github.com/gatewayd-io/gatewayd/metrics"
 	"github.com/gatewayd-io/gatewayd/plugin"
+	"github.com/klauspost/compress/zstd"
 	"github.com/rs/zerolog"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -43,8 +47,14 @@ type IServer interface {
 	OnTick() (time.Duration, Action)
 	Run() *gerr.GatewayDError
 	Shutdown()
+	Drain() bool
+	Pause()
+	Resume()
+	IsPaused() bool
+	IsDraining() bool
 	IsRunning() bool
 	CountConnections() int
+	Uptime() time.Duration
 }
 
 type Server struct {
@@ -67,12 +77,56 @@ type Server struct {
 	KeyFile          string
 	HandshakeTimeout time.Duration
 
+	// EnableHTTPTunnel accepts the clients through HTTP CONNECT tunnels.
+	EnableHTTPTunnel bool
+	// ClientCAFile is used for verifying the client certificates (mTLS).
+	ClientCAFile string
+	// ShutdownGracePeriod is the time to wait for the connections to drain on shutdown.
+	ShutdownGracePeriod time.Duration
+	// IdleTimeout is the time after which the idle client connections are closed.
+	IdleTimeout time.Duration
+	// MaxConnections is the maximum number of client connections, 0 means unlimited.
+	MaxConnections int
+	// MaxConnectionsPerIP is the maximum number of client connections of a client IP
+	// address, 0 means unlimited. The clients of the allow list aren't capped.
+	MaxConnectionsPerIP          int
+	MaxConnectionsPerIPAllowList []string
+	// TCPFastOpen enables TCP Fast Open on the listener, if supported.
+	TCPFastOpen bool
+	// TCPFastOpenQueueLength is the maximum number of pending Fast Open requests.
+	TCPFastOpenQueueLength int
+	// DSCP is the DSCP class of the packets to the clients, 0 means unmarked.
+	DSCP int
+	// TCPUserTimeout is how long the data sent to the clients may stay unacknowledged
+	// before their connections are closed, 0 means the OS default.
+	TCPUserTimeout time.Duration
+	// EnableCompression lets the clients compress their connections with zstd.
+	EnableCompression bool
+	// CompressionLevel is the level of the compression, i.e. fastest, default, better or best.
+	CompressionLevel string
+	// KeepAlive is the payload of the keepalives sent to the idle client connections
+	// on every tick, if the ticker is enabled. Nil means no keepalives.
+	KeepAlive []byte
+	// ConnectionBudget is shared by all the servers, and caps their client connections
+	// to keep the file descriptors reserved for the admin API and the health checks.
+	// Nil means no budget.
+	ConnectionBudget *ConnectionBudget
+
 	listener    net.Listener
+	startedAt   time.Time
 	host        string
 	port        int
 	connections uint32
-	running     *atomic.Bool
-	stopServer  chan struct{}
+	// connectionsPerIP counts the client connections of each client IP address.
+	connectionsPerIP *ConnectionsPerIP
+	running          *atomic.Bool
+	paused           *atomic.Bool
+	stopServer       chan struct{}
+	// draining is set when the server starts draining, so that it's reported as not
+	// ready while the existing connections are still served.
+	draining *atomic.Bool
+	// shutdownDone is closed when the OnShutdown hooks have run.
+	shutdownDone chan struct{}
 }
 
 var _ IServer = (*Server)(nil)
@@ -132,6 +186,50 @@ func (s *Server) OnOpen(conn *ConnWrapper) ([]byte, Action) {
 	s.Logger.Debug().Str("from", RemoteAddr(conn.Conn())).Msg(
 		"GatewayD is opening a connection")
 
+	// Reject the new connections while the server is paused.
+	if s.IsPaused() {
+		s.Logger.Debug().Str("from", RemoteAddr(conn.Conn())).Msg(
+			"Rejected the connection, because the server is paused")
+		span.AddEvent("Rejected the connection, because the server is paused")
+		metrics.RejectedConnections.WithLabelValues("paused").Inc()
+		// https://www.postgresql.org/docs/current/errcodes-appendix.html
+		return postgres.ErrorResponse(
+			"the server is not accepting new connections", "FATAL", "57P03",
+			"GatewayD is paused for maintenance"), Close
+	}
+
+	// Reject the new connections above the maximum number of connections. The
+	// connection being opened isn't counted yet.
+	if s.MaxConnections > 0 && s.CountConnections() >= s.MaxConnections {
+		s.Logger.Warn().Fields(
+			map[string]interface{}{
+				"from":           RemoteAddr(conn.Conn()),
+				"maxConnections": s.MaxConnections,
+			},
+		).Msg("Rejected the connection, because there are too many connections")
+		span.AddEvent("Rejected the connection, because there are too many connections")
+		metrics.RejectedConnections.WithLabelValues("maxConnections").Inc()
+		return tooManyConnections(), Close
+	}
+
+	// Reject the new connections of a client above its maximum number of connections,
+	// so that a single client can't take all the connections. The connection is counted
+	// until it's closed.
+	if !s.connectionsPerIP.Acquire(RemoteAddr(conn.Conn()), s.MaxConnectionsPerIP) {
+		s.Logger.Warn().Fields(
+			map[string]interface{}{
+				"from":                RemoteAddr(conn.Conn()),
+				"maxConnectionsPerIP": s.MaxConnectionsPerIP,
+			},
+		).Msg("Rejected the connection, because the client has too many connections")
+		span.AddEvent("Rejected the connection, because the client has too many connections")
+		metrics.RejectedConnections.WithLabelValues("maxConnectionsPerIP").Inc()
+		// https://www.postgresql.org/docs/current/errcodes-appendix.html
+		return postgres.ErrorResponse(
+			"too many connections from the client's IP address", "FATAL", "53300",
+			"GatewayD limits the connections of each client"), Close
+	}
+
 	pluginTimeoutCtx, cancel := context.WithTimeout(context.Background(), s.PluginTimeout)
 	defer cancel()
 	// Run the OnOpening hooks.
@@ -141,12 +239,17 @@ func (s *Server) OnOpen(conn *ConnWrapper) ([]byte, Action) {
 			"remote": RemoteAddr(conn.Conn()),
 		},
 	}
-	_, err := s.PluginRegistry.Run(
+	result, err := s.PluginRegistry.Run(
 		pluginTimeoutCtx, onOpeningData, v1.HookName_HOOK_NAME_ON_OPENING)
 	if err != nil {
 		s.Logger.Error().Err(err).Msg("Failed to run OnOpening hook")
 		span.RecordError(err)
 	}
+	// The plugins may set the priority of the connection for getting a server
+	// connection when the pool is exhausted.
+	if priority, ok := GetPriority(result); ok {
+		conn.SetPriority(priority)
+	}
 	span.AddEvent("Ran the OnOpening hooks")
 
 	// Use the proxy to connect to the backend. Close the connection if the pool is exhausted.
@@ -154,8 +257,21 @@ func (s *Server) OnOpen(conn *ConnWrapper) ([]byte, Action) {
 	// connections in the pool of the busy connections.
 	if err := s.Proxy.Connect(conn); err != nil {
 		if errors.Is(err, gerr.ErrPoolExhausted) {
+			s.connectionsPerIP.Release(RemoteAddr(conn.Conn()))
 			span.RecordError(err)
-			return nil, Close
+			metrics.RejectedConnections.WithLabelValues("poolExhausted").Inc()
+			return tooManyConnections(), Close
+		}
+		if errors.Is(err, gerr.ErrNoHealthyUpstream) {
+			s.connectionsPerIP.Release(RemoteAddr(conn.Conn()))
+			s.Logger.Warn().Str("from", RemoteAddr(conn.Conn())).Msg(
+				"Rejected the connection, because all the backends are down")
+			span.RecordError(err)
+			metrics.RejectedConnections.WithLabelValues("noHealthyUpstream").Inc()
+			// https://www.postgresql.org/docs/current/errcodes-appendix.html
+			return postgres.ErrorResponse(
+				"no healthy upstream", "FATAL", "08001",
+				"All the database servers behind GatewayD are down"), Close
 		}
 
 		// This should never happen.
@@ -175,6 +291,10 @@ func (s *Server) OnOpen(conn *ConnWrapper) ([]byte, Action) {
 			"remote": RemoteAddr(conn.Conn()),
 		},
 	}
+	// The routing decision of the proxy, i.e. which backend the client is assigned to.
+	if routing := s.Proxy.Routing(conn); routing != nil {
+		onOpenedData["routing"] = routing
+	}
 	_, err = s.PluginRegistry.Run(
 		pluginTimeoutCtx, onOpenedData, v1.HookName_HOOK_NAME_ON_OPENED)
 	if err != nil {
@@ -196,6 +316,7 @@ func (s *Server) OnClose(conn *ConnWrapper, err error) Action {
 
 	s.Logger.Debug().Str("from", RemoteAddr(conn.Conn())).Msg(
 		"GatewayD is closing a connection")
+	s.connectionsPerIP.Release(RemoteAddr(conn.Conn()))
 
 	// Run the OnClosing hooks.
 	pluginTimeoutCtx, cancel := context.WithTimeout(context.Background(), s.PluginTimeout)
@@ -206,7 +327,8 @@ func (s *Server) OnClose(conn *ConnWrapper, err error) Action {
 			"local":  LocalAddr(conn.Conn()),
 			"remote": RemoteAddr(conn.Conn()),
 		},
-		"error": "",
+		"traffic": trafficTotals(conn),
+		"error":   "",
 	}
 	if err != nil {
 		data["error"] = err.Error()
@@ -228,7 +350,8 @@ func (s *Server) OnClose(conn *ConnWrapper, err error) Action {
 	// Disconnect the connection from the proxy. This effectively removes the mapping between
 	// the incoming and the server connections in the pool of the busy connections and either
 	// recycles or disconnects the connections.
-	if err := s.Proxy.Disconnect(conn); err != nil {
+	// If the client is not found, the server connection has already been recycled by the proxy.
+	if err := s.Proxy.Disconnect(conn); err != nil && !errors.Is(err, gerr.ErrClientNotFound) {
 		s.Logger.Error().Err(err).Msg("Failed to disconnect the server connection")
 		span.RecordError(err)
 		return Close
@@ -237,6 +360,9 @@ func (s *Server) OnClose(conn *ConnWrapper, err error) Action {
 	if conn.IsTLSEnabled() {
 		metrics.TLSConnections.Dec()
 	}
+	if conn.IsCompressed() {
+		metrics.CompressedConnections.Dec()
+	}
 
 	// Close the incoming connection.
 	if err := conn.Close(); err != nil {
@@ -383,6 +509,11 @@ func (s *Server) OnTick() (time.Duration, Action) {
 	}
 	span.AddEvent("Ran the OnTick hooks")
 
+	// Keep the connections that were idle since the previous tick alive.
+	if len(s.KeepAlive) > 0 {
+		s.Proxy.SendKeepAlives(s.KeepAlive, s.TickInterval)
+	}
+
 	// TODO: Investigate whether to move schedulers here or not
 
 	metrics.ServerTicksFired.Inc()
@@ -436,37 +567,57 @@ func (s *Server) Run() *gerr.GatewayDError {
 		return nil
 	}
 
-	listener, origErr := net.Listen(s.Network, addr)
+	listener, origErr := s.listen(addr)
 	if origErr != nil {
 		s.Logger.Error().Err(origErr).Msg("Server failed to start listening")
 		return gerr.ErrServerListenFailed.Wrap(origErr)
 	}
+	if s.EnableHTTPTunnel {
+		s.Logger.Info().Msg("HTTP tunnel is enabled")
+	}
 	s.mu.Lock()
 	s.listener = listener
+	s.startedAt = time.Now()
 	s.mu.Unlock()
-	defer s.listener.Close()
+	// The listener may be replaced by another one on rebind.
+	defer func() { s.currentListener().Close() }()
 
 	if s.listener == nil {
 		s.Logger.Error().Msg("Server is not properly initialized")
 		return nil
 	}
 
-	var port string
-	s.host, port, origErr = net.SplitHostPort(s.listener.Addr().String())
-	if origErr != nil {
-		s.Logger.Error().Err(origErr).Msg("Failed to split host and port")
-		return gerr.ErrSplitHostPortFailed.Wrap(origErr)
-	}
-
-	if s.port, origErr = strconv.Atoi(port); origErr != nil {
-		s.Logger.Error().Err(origErr).Msg("Failed to convert port to integer")
-		return gerr.ErrCastFailed.Wrap(origErr)
+	if err := s.setHostPort(s.listener); err != nil {
+		return err
 	}
 
-	go func(server *Server) {
+	s.mu.Lock()
+	s.shutdownDone = make(chan struct{})
+	s.mu.Unlock()
+	go func(server *Server, shutdownDone chan struct{}) {
 		<-server.stopServer
 		server.OnShutdown()
+		close(shutdownDone)
 		server.Logger.Debug().Msg("Server stopped")
+	}(s, s.shutdownDone)
+
+	go func(server *Server) {
+		if server.IdleTimeout <= 0 {
+			return
+		}
+
+		server.Logger.Info().Str(
+			"idleTimeout", server.IdleTimeout.String()).Msg("Idle connection watchdog is enabled")
+		ticker := time.NewTicker(min(server.IdleTimeout, config.DefaultIdleCheckInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-server.stopServer:
+				return
+			case <-ticker.C:
+				server.Proxy.CloseIdleConnections(server.IdleTimeout)
+			}
+		}
 	}(s)
 
 	go func(server *Server) {
@@ -501,69 +652,121 @@ func (s *Server) Run() *gerr.GatewayDError {
 			s.Logger.Error().Err(origErr).Msg("Failed to create TLS config")
 			return gerr.ErrGetTLSConfigFailed.Wrap(origErr)
 		}
+		if s.ClientCAFile != "" {
+			if tlsConfig.ClientCAs, origErr = LoadCertPool(s.ClientCAFile); origErr != nil {
+				s.Logger.Error().Err(origErr).Msg("Failed to load the client CA certificate")
+				return gerr.ErrGetTLSConfigFailed.Wrap(origErr)
+			}
+			s.Logger.Info().Msg("Client certificates are verified")
+		}
 		s.Logger.Info().Msg("TLS is enabled")
 	} else {
 		s.Logger.Debug().Msg("TLS is disabled")
 	}
 
+	// The clients may compress their connections if the compression is enabled.
+	var compressionLevel zstd.EncoderLevel
+	if s.EnableCompression {
+		level, ok := ParseCompressionLevel(s.CompressionLevel)
+		if !ok {
+			level = zstd.SpeedDefault
+			s.Logger.Warn().Str("level", s.CompressionLevel).Msg(
+				"Unknown compression level, using the default level")
+		}
+		compressionLevel = level
+		s.Logger.Info().Str("level", level.String()).Msg("Compression is enabled")
+	}
+
+	s.startupComplete()
+
 	for {
 		select {
 		case <-s.stopServer:
 			s.Logger.Info().Msg("Server stopped")
 			return nil
 		default:
-			netConn, err := s.listener.Accept()
+			listener := s.currentListener()
+			netConn, err := listener.Accept()
 			if err != nil {
 				if !s.running.Load() {
 					return nil
 				}
+				// The old listener is closed once the server is rebound to a new address,
+				// so the new connections are accepted from the new listener.
+				if s.currentListener() != listener {
+					continue
+				}
 				s.Logger.Error().Err(err).Msg("Failed to accept connection")
 				return gerr.ErrAcceptFailed.Wrap(err)
 			}
 
+			// The connections above the budget are closed right away, before using any
+			// other resources, so that the reserved file descriptors are kept.
+			if !s.ConnectionBudget.Acquire() {
+				s.Logger.Debug().Str("from", RemoteAddr(netConn)).Msg(
+					"Rejected the connection, because the connection budget is exhausted")
+				metrics.RejectedConnections.WithLabelValues("connectionBudget").Inc()
+				_ = netConn.Close()
+				continue
+			}
+
+			markDSCP(netConn, s.DSCP, s.Logger)
+
 			conn := NewConnWrapper(ConnWrapper{
 				NetConn:          netConn,
 				TLSConfig:        tlsConfig,
 				HandshakeTimeout: s.HandshakeTimeout,
+				CompressionLevel: compressionLevel,
 			})
 
-			if out, action := s.OnOpen(conn); action != None {
-				if _, err := conn.Write(out); err != nil {
-					s.Logger.Error().Err(err).Msg("Failed to write to connection")
-				}
-				_ = conn.Close()
-				if action == Shutdown {
-					s.OnShutdown()
-					return nil
-				}
-			}
+			// The connection is opened in the background, so that the connections waiting
+			// for a server connection of the exhausted pool don't block the other ones.
+			go s.serve(conn)
+		}
+	}
+}
+
+// serve opens the accepted connection and passes its traffic through until it's
+// closed, or closes it right away if it's rejected.
+func (s *Server) serve(conn *ConnWrapper) {
+	defer s.ConnectionBudget.Release()
+
+	if out, action := s.OnOpen(conn); action != None {
+		if _, err := conn.Write(out); err != nil {
+			s.Logger.Error().Err(err).Msg("Failed to write to connection")
+		}
+		_ = conn.Close()
+		if action == Shutdown {
+			s.OnShutdown()
+		}
+		return
+	}
+	s.mu.Lock()
+	s.connections++
+	s.mu.Unlock()
+
+	// For every new connection, a new channel is created to help stop the
+	// proxy, recycle the server connection and close stale connections.
+	// It's sent to by both pass-through goroutines and on the return of
+	// OnTraffic, but only received from twice, so it's buffered for all
+	// the senders, so that the last one doesn't block and leak forever.
+	stopConnection := make(chan struct{}, 3) //nolint:gomnd
+	go func(server *Server, conn *ConnWrapper, stopConnection chan struct{}) {
+		if action := server.OnTraffic(conn, stopConnection); action == Close {
+			stopConnection <- struct{}{}
+		}
+	}(s, conn, stopConnection)
+
+	for {
+		select {
+		case <-stopConnection:
 			s.mu.Lock()
-			s.connections++
+			s.connections--
 			s.mu.Unlock()
-
-			// For every new connection, a new unbuffered channel is created to help
-			// stop the proxy, recycle the server connection and close stale connections.
-			stopConnection := make(chan struct{})
-			go func(server *Server, conn *ConnWrapper, stopConnection chan struct{}) {
-				if action := server.OnTraffic(conn, stopConnection); action == Close {
-					stopConnection <- struct{}{}
-				}
-			}(s, conn, stopConnection)
-
-			go func(server *Server, conn *ConnWrapper, stopConnection chan struct{}) {
-				for {
-					select {
-					case <-stopConnection:
-						server.mu.Lock()
-						server.connections--
-						server.mu.Unlock()
-						server.OnClose(conn, err)
-						return
-					case <-server.stopServer:
-						return
-					}
-				}
-			}(s, conn, stopConnection)
+			s.OnClose(conn, nil)
+			return
+		case <-s.stopServer:
+			return
 		}
 	}
 }
@@ -582,15 +785,7 @@ func (s *Server) Shutdown() {
 	s.mu.Unlock()
 
 	// Shutdown the server.
-	var err error
-	s.running.Store(false)
-	if s.listener != nil {
-		if err = s.listener.Close(); err != nil {
-			s.Logger.Error().Err(err).Msg("Failed to close listener")
-		}
-	} else {
-		s.Logger.Error().Msg("Listener is not initialized")
-	}
+	err := s.stopListening()
 
 	select {
 	case <-s.stopServer:
@@ -600,12 +795,176 @@ func (s *Server) Shutdown() {
 		close(s.stopServer)
 	}
 
+	// Wait for the OnShutdown hooks to run, so that the plugins are not
+	// stopped while the hooks are still running.
+	s.mu.RLock()
+	shutdownDone := s.shutdownDone
+	s.mu.RUnlock()
+	if shutdownDone != nil {
+		select {
+		case <-shutdownDone:
+		case <-time.After(s.PluginTimeout):
+			s.Logger.Warn().Msg("Timed out waiting for the OnShutdown hooks to run")
+		}
+	}
+
 	if err != nil {
 		s.Logger.Error().Err(err).Msg("Failed to shutdown server")
 		span.RecordError(err)
 	}
 }
 
+// Drain stops accepting new connections and waits for the existing connections
+// to close, up to the shutdown grace period. It returns true if all the
+// connections are closed before the grace period is over.
+func (s *Server) Drain() bool {
+	_, span := otel.Tracer("gatewayd").Start(s.ctx, "Drain")
+	defer span.End()
+
+	// Report not ready first, so that the load balancers stop sending new traffic.
+	s.draining.Store(true)
+
+	if err := s.stopListening(); err != nil {
+		s.Logger.Error().Err(err).Msg("Failed to stop accepting new connections")
+		span.RecordError(err)
+	}
+
+	s.Logger.Info().Fields(
+		map[string]interface{}{
+			"connections": s.CountConnections(),
+			"gracePeriod": s.ShutdownGracePeriod.String(),
+		},
+	).Msg("Waiting for the connections to drain")
+
+	deadline := time.Now().Add(s.ShutdownGracePeriod)
+	for s.CountConnections() > 0 {
+		if time.Now().After(deadline) {
+			s.Logger.Warn().Int("connections", s.CountConnections()).Msg(
+				"Shutdown grace period is over, closing the remaining connections")
+			span.AddEvent("Shutdown grace period is over")
+			return false
+		}
+		time.Sleep(config.DefaultDrainCheckInterval)
+	}
+
+	s.Logger.Info().Msg("All connections are drained")
+	span.AddEvent("All connections are drained")
+	return true
+}
+
+// tooManyConnections returns the error response of PostgreSQL for the clients
+// that are rejected, because there are too many connections.
+func tooManyConnections() []byte {
+	// https://www.postgresql.org/docs/current/errcodes-appendix.html
+	return postgres.ErrorResponse(
+		"sorry, too many clients already", "FATAL", "53300",
+		"GatewayD has no more connections available")
+}
+
+// Pause stops assigning server connections to the new connections, which are
+// rejected until the server is resumed. The existing connections aren't affected.
+func (s *Server) Pause() {
+	if !s.paused.Swap(true) {
+		s.Logger.Info().Int("connections", s.CountConnections()).Msg(
+			"Paused accepting new connections")
+	}
+}
+
+// Resume accepts new connections again after the server is paused.
+func (s *Server) Resume() {
+	if s.paused.Swap(false) {
+		s.Logger.Info().Msg("Resumed accepting new connections")
+	}
+}
+
+// IsDraining returns true if the server is draining its connections, e.g. on shutdown.
+func (s *Server) IsDraining() bool {
+	return s.draining.Load()
+}
+
+// IsPaused returns true if the server is paused.
+func (s *Server) IsPaused() bool {
+	return s.paused.Load()
+}
+
+// listen creates the listener of the server on the address, which is wrapped for the
+// HTTP tunnels, if enabled.
+func (s *Server) listen(addr string) (net.Listener, error) {
+	var listener net.Listener
+	var err error
+	if s.Network == MemoryNetwork {
+		listener, err = ListenMemory(addr)
+	} else {
+		listenConfig := newListenConfig(
+			s.TCPFastOpen, s.TCPFastOpenQueueLength, s.DSCP, s.TCPUserTimeout, s.Logger)
+		listener, err = listenConfig.Listen(s.ctx, s.Network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.EnableHTTPTunnel {
+		// The tunneled connections are handed over to the proxy as raw connections.
+		listener = NewTunnelListener(listener, s.HandshakeTimeout, s.Logger)
+	}
+	return listener, nil
+}
+
+// setHostPort sets the host and the port of the server from its listener.
+func (s *Server) setHostPort(listener net.Listener) *gerr.GatewayDError {
+	// The memory listeners have a name instead of a host and port.
+	if s.Network == MemoryNetwork {
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		s.Logger.Error().Err(err).Msg("Failed to split host and port")
+		return gerr.ErrSplitHostPortFailed.Wrap(err)
+	}
+
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		s.Logger.Error().Err(err).Msg("Failed to convert port to integer")
+		return gerr.ErrCastFailed.Wrap(err)
+	}
+
+	s.mu.Lock()
+	s.host, s.port = host, portNumber
+	s.mu.Unlock()
+	return nil
+}
+
+// currentListener returns the listener the server is accepting the connections from.
+func (s *Server) currentListener() net.Listener {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listener
+}
+
+// stopListening stops accepting new connections by closing the listener.
+func (s *Server) stopListening() error {
+	// This must be set before closing the listener, so that the accept loop
+	// returns without an error.
+	s.running.Store(false)
+
+	s.mu.RLock()
+	listener := s.listener
+	s.mu.RUnlock()
+
+	if listener == nil {
+		s.Logger.Error().Msg("Listener is not initialized")
+		return nil
+	}
+
+	if err := listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.Logger.Error().Err(err).Msg("Failed to close listener")
+		return err
+	}
+
+	return nil
+}
+
 // IsRunning returns true if the server is running.
 func (s *Server) IsRunning() bool {
 	_, span := otel.Tracer("gatewayd").Start(s.ctx, "IsRunning")
@@ -627,25 +986,51 @@ func NewServer(
 
 	// Create the server.
 	server := Server{
-		ctx:              serverCtx,
-		Network:          srv.Network,
-		Address:          srv.Address,
-		Options:          srv.Options,
-		TickInterval:     srv.TickInterval,
-		Status:           config.Stopped,
-		EnableTLS:        srv.EnableTLS,
-		CertFile:         srv.CertFile,
-		KeyFile:          srv.KeyFile,
-		HandshakeTimeout: srv.HandshakeTimeout,
-		Proxy:            srv.Proxy,
-		Logger:           srv.Logger,
-		PluginRegistry:   srv.PluginRegistry,
-		PluginTimeout:    srv.PluginTimeout,
-		mu:               &sync.RWMutex{},
-		connections:      0,
-		running:          &atomic.Bool{},
-		stopServer:       make(chan struct{}),
+		ctx:                          serverCtx,
+		Network:                      srv.Network,
+		Address:                      srv.Address,
+		Options:                      srv.Options,
+		TickInterval:                 srv.TickInterval,
+		Status:                       config.Stopped,
+		EnableTLS:                    srv.EnableTLS,
+		CertFile:                     srv.CertFile,
+		KeyFile:                      srv.KeyFile,
+		HandshakeTimeout:             srv.HandshakeTimeout,
+		EnableHTTPTunnel:             srv.EnableHTTPTunnel,
+		ClientCAFile:                 srv.ClientCAFile,
+		ShutdownGracePeriod:          srv.ShutdownGracePeriod,
+		IdleTimeout:                  srv.IdleTimeout,
+		MaxConnections:               srv.MaxConnections,
+		MaxConnectionsPerIP:          srv.MaxConnectionsPerIP,
+		MaxConnectionsPerIPAllowList: srv.MaxConnectionsPerIPAllowList,
+		DSCP:                         srv.DSCP,
+		TCPUserTimeout:               srv.TCPUserTimeout,
+		TCPFastOpen:                  srv.TCPFastOpen,
+		TCPFastOpenQueueLength: config.If(
+			srv.TCPFastOpenQueueLength > 0, srv.TCPFastOpenQueueLength, config.DefaultTCPFastOpenQueueLength),
+		ConnectionBudget:  srv.ConnectionBudget,
+		EnableCompression: srv.EnableCompression,
+		CompressionLevel: config.If(
+			srv.CompressionLevel != "", srv.CompressionLevel, config.DefaultCompression),
+		KeepAlive:      srv.KeepAlive,
+		Proxy:          srv.Proxy,
+		Logger:         srv.Logger,
+		PluginRegistry: srv.PluginRegistry,
+		PluginTimeout:  srv.PluginTimeout,
+		mu:             &sync.RWMutex{},
+		connections:    0,
+		running:        &atomic.Bool{},
+		paused:         &atomic.Bool{},
+		draining:       &atomic.Bool{},
+		stopServer:     make(chan struct{}),
+	}
+
+	allowList, invalid := ParseCaptureClients(server.MaxConnectionsPerIPAllowList)
+	if len(invalid) > 0 {
+		srv.Logger.Error().Strs("clients", invalid).Msg(
+			"Ignoring the invalid IP addresses and CIDR ranges of the connection limit allow list")
 	}
+	server.connectionsPerIP = NewConnectionsPerIP(allowList)
 
 	// Try to resolve the address and log an error if it can't be resolved.
 	addr, err := Resolve(server.Network, server.Address, srv.Logger)
@@ -673,3 +1058,68 @@ func (s *Server) CountConnections() int {
 	defer s.mu.RUnlock()
 	return int(s.connections)
 }
+
+// Uptime returns the time since the server started listening, or zero if it
+// isn't running.
+func (s *Server) Uptime() time.Duration {
+	if !s.IsRunning() {
+		return 0
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.startedAt.IsZero() {
+		return 0
+	}
+	return time.Since(s.startedAt)
+}
+
+// startupSummary returns what the server is listening on and what it's running with.
+func (s *Server) startupSummary() map[string]interface{} {
+	summary := map[string]interface{}{
+		"network":    s.Network,
+		"address":    s.listener.Addr().String(),
+		"protocol":   "postgres",
+		"tls":        s.EnableTLS,
+		"mtls":       s.EnableTLS && s.ClientCAFile != "",
+		"httpTunnel": s.EnableHTTPTunnel,
+	}
+
+	if s.Proxy != nil {
+		summary["poolSize"] = s.Proxy.PoolSize()
+		summary["backends"] = s.Proxy.BackendAddresses()
+	}
+
+	plugins := []string{}
+	hooks := []string{}
+	if s.PluginRegistry != nil {
+		s.PluginRegistry.ForEach(func(id sdkPlugin.Identifier, _ *plugin.Plugin) {
+			plugins = append(plugins, id.Name+"@"+id.Version)
+		})
+		for hook := range s.PluginRegistry.Hooks() {
+			hooks = append(hooks, hook.String())
+		}
+	}
+	slices.Sort(plugins)
+	slices.Sort(hooks)
+	summary["plugins"] = plugins
+	summary["hooks"] = hooks
+
+	return summary
+}
+
+// startupComplete logs the startup summary of the server in a single line and runs
+// the OnStartupComplete hooks in the background.
+func (s *Server) startupComplete() {
+	summary := s.startupSummary()
+	s.Logger.Info().Fields(summary).Msg("Startup complete")
+
+	if s.PluginRegistry != nil {
+		go func(registry *plugin.Registry, timeout time.Duration) {
+			if err := registry.RunLifecycleHook(
+				plugin.OnStartupCompleteHookName, summary, timeout); err != nil {
+				s.Logger.Error().Err(err).Msg("Failed to run OnStartupComplete hooks")
+			}
+		}(s.PluginRegistry, s.PluginTimeout)
+	}
+}
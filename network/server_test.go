@@ -5,8 +5,10 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -285,3 +287,126 @@ func onOutgoingTraffic(
 
 	return params, nil
 }
+
+// TestServerDrain tests that draining the server stops accepting new connections
+// and waits for the existing connections to close within the grace period.
+func TestServerDrain(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &Server{
+		ctx:                 context.Background(),
+		Logger:              zerolog.Nop(),
+		ShutdownGracePeriod: 500 * time.Millisecond,
+		listener:            listener,
+		mu:                  &sync.RWMutex{},
+		running:             &atomic.Bool{},
+		draining:            &atomic.Bool{},
+		connections:         1,
+	}
+	server.running.Store(true)
+	assert.False(t, server.IsDraining())
+
+	// The connection doesn't close, so the grace period is over.
+	assert.False(t, server.Drain())
+	assert.True(t, server.IsDraining())
+	assert.False(t, server.running.Load())
+	_, err = net.Dial("tcp", listener.Addr().String())
+	assert.Error(t, err)
+
+	// The connection closes within the grace period.
+	go func() {
+		time.Sleep(config.DefaultDrainCheckInterval)
+		server.mu.Lock()
+		server.connections = 0
+		server.mu.Unlock()
+	}()
+	assert.True(t, server.Drain())
+}
+
+// TestServerStartupSummary tests the summary of what the server is listening on
+// and running with.
+func TestServerStartupSummary(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	upstream := newFakeUpstream(t, func(net.Conn) {})
+	proxy := newTestProxy(t, upstream.Address())
+
+	server := &Server{
+		ctx:            context.Background(),
+		Logger:         zerolog.Nop(),
+		Proxy:          proxy,
+		PluginRegistry: proxy.PluginRegistry,
+		Network:        "tcp",
+		listener:       listener,
+	}
+
+	summary := server.startupSummary()
+	assert.Equal(t, listener.Addr().String(), summary["address"])
+	assert.Equal(t, "postgres", summary["protocol"])
+	assert.Equal(t, false, summary["tls"])
+	assert.Equal(t, 1, summary["poolSize"])
+	assert.Equal(t, []string{"tcp://" + upstream.Address()}, summary["backends"])
+	assert.Equal(t, []string{}, summary["plugins"])
+}
+
+// TestServerPause tests that the new connections are rejected while the server
+// is paused, without assigning server connections to them.
+func TestServerPause(t *testing.T) {
+	upstream := newFakeUpstream(t, func(net.Conn) {})
+	proxy := newTestProxy(t, upstream.Address())
+
+	server := &Server{
+		ctx:            context.Background(),
+		Logger:         zerolog.Nop(),
+		Proxy:          proxy,
+		PluginRegistry: proxy.PluginRegistry,
+		PluginTimeout:  config.DefaultPluginTimeout,
+		mu:             &sync.RWMutex{},
+		paused:         &atomic.Bool{},
+	}
+
+	server.Pause()
+	assert.True(t, server.IsPaused())
+	out, action := server.OnOpen(NewConnWrapper(ConnWrapper{NetConn: newMockConn()}))
+	assert.Equal(t, Close, action)
+	assert.Equal(t, byte('E'), out[0], "the client gets an error response")
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+
+	server.Resume()
+	assert.False(t, server.IsPaused())
+	_, action = server.OnOpen(NewConnWrapper(ConnWrapper{NetConn: newMockConn()}))
+	assert.Equal(t, None, action)
+	assert.Equal(t, 0, proxy.AvailableConnections.Size())
+}
+
+// TestServerMaxConnections tests that the new connections above the maximum
+// number of connections are rejected with a "too many clients" error.
+func TestServerMaxConnections(t *testing.T) {
+	upstream := newFakeUpstream(t, func(net.Conn) {})
+	proxy := newTestProxy(t, upstream.Address())
+
+	server := &Server{
+		ctx:            context.Background(),
+		Logger:         zerolog.Nop(),
+		Proxy:          proxy,
+		PluginRegistry: proxy.PluginRegistry,
+		PluginTimeout:  config.DefaultPluginTimeout,
+		MaxConnections: 1,
+		mu:             &sync.RWMutex{},
+		paused:         &atomic.Bool{},
+		connections:    1,
+	}
+
+	out, action := server.OnOpen(NewConnWrapper(ConnWrapper{NetConn: newMockConn()}))
+	assert.Equal(t, Close, action)
+	assert.Contains(t, string(out), "53300")
+	assert.Equal(t, 1, proxy.AvailableConnections.Size())
+
+	server.connections = 0
+	_, action = server.OnOpen(NewConnWrapper(ConnWrapper{NetConn: newMockConn()}))
+	assert.Equal(t, None, action)
+	assert.Equal(t, 0, proxy.AvailableConnections.Size())
+}
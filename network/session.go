@@ -0,0 +1,279 @@
+package network
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"go.opentelemetry.io/otel"
+)
+
+// rollbackTag is the command tag of the ROLLBACK command.
+const rollbackTag = "ROLLBACK"
+
+var (
+	errUnsupportedAuthentication = errors.New("unsupported authentication method")
+	errSessionNotAuthenticated   = errors.New("session is not pre-authenticated")
+	errNoResetQuery              = errors.New("no reset query is configured")
+	errSessionNotIdle            = errors.New("session is not idle after the reset")
+)
+
+// authenticate starts and authenticates the server session with the credentials
+// of the client's backend, so that the session is ready to use when a client is
+// assigned to it. The server's response, up to and including the ReadyForQuery
+// message, is kept for replying to the startup messages of the clients.
+// Only the trust, password and md5 authentication methods are supported.
+func (c *Client) authenticate() *gerr.GatewayDError {
+	_, span := otel.Tracer(config.TracerName).Start(c.ctx, "Authenticate session")
+	defer span.End()
+
+	timeout := config.If(c.DialTimeout > 0, c.DialTimeout, config.DefaultDialTimeout)
+	defer c.restoreDeadlines(c.conn)
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		span.RecordError(err)
+		return gerr.ErrSessionAuthFailed.Wrap(err)
+	}
+
+	frontend := pgproto3.NewFrontend(c.conn, c.conn)
+	frontend.Send(&pgproto3.StartupMessage{
+		ProtocolVersion: pgproto3.ProtocolVersionNumber,
+		Parameters:      c.startupParameters,
+	})
+
+	var response []byte
+	for {
+		if err := frontend.Flush(); err != nil {
+			span.RecordError(err)
+			return gerr.ErrSessionAuthFailed.Wrap(err)
+		}
+
+		message, err := frontend.Receive()
+		if err != nil {
+			span.RecordError(err)
+			return gerr.ErrSessionAuthFailed.Wrap(err)
+		}
+
+		switch message := message.(type) {
+		case *pgproto3.AuthenticationCleartextPassword:
+			frontend.Send(&pgproto3.PasswordMessage{Password: c.config.Password})
+		case *pgproto3.AuthenticationMD5Password:
+			frontend.Send(&pgproto3.PasswordMessage{
+				Password: MD5Password(c.startupParameters["user"], c.config.Password, message.Salt),
+			})
+		case *pgproto3.AuthenticationOk,
+			*pgproto3.ParameterStatus,
+			*pgproto3.BackendKeyData,
+			*pgproto3.NoticeResponse:
+			if response, err = message.Encode(response); err != nil {
+				span.RecordError(err)
+				return gerr.ErrSessionAuthFailed.Wrap(err)
+			}
+		case *pgproto3.ReadyForQuery:
+			if response, err = message.Encode(response); err != nil {
+				span.RecordError(err)
+				return gerr.ErrSessionAuthFailed.Wrap(err)
+			}
+			c.startupResponse = response
+			span.AddEvent("Authenticated the server session")
+			return nil
+		case *pgproto3.ErrorResponse:
+			err := fmt.Errorf("%s: %s (SQLSTATE %s)", message.Severity, message.Message, message.Code)
+			span.RecordError(err)
+			return gerr.ErrSessionAuthFailed.Wrap(err)
+		default:
+			err := fmt.Errorf("%w: %T", errUnsupportedAuthentication, message)
+			span.RecordError(err)
+			return gerr.ErrSessionAuthFailed.Wrap(err)
+		}
+	}
+}
+
+// StartupResponse returns the server's response to the startup message of the
+// pre-authenticated server session, or nil if the session isn't pre-authenticated.
+func (c *Client) StartupResponse() []byte {
+	return c.startupResponse
+}
+
+// Reuse resets the state of the pre-authenticated server session with the reset
+// query, rolling back any open transaction first, so that the session can be reused
+// by another client instead of authenticating a new one. The session is handed over
+// to a new client, since the previous client may still be waiting for a response
+// from the server in another goroutine. This client is disconnected in either case,
+// and the session is closed if it can't be reset.
+func (c *Client) Reuse() (IClient, *gerr.GatewayDError) {
+	_, span := otel.Tracer(config.TracerName).Start(c.ctx, "Reuse")
+	defer span.End()
+
+	if c.startupResponse == nil {
+		span.RecordError(errSessionNotAuthenticated)
+		return nil, gerr.ErrSessionResetFailed.Wrap(errSessionNotAuthenticated)
+	}
+
+	if c.ResetQuery == "" {
+		span.RecordError(errNoResetQuery)
+		return nil, gerr.ErrSessionResetFailed.Wrap(errNoResetQuery)
+	}
+
+	id := c.ID
+	conn := c.detach()
+	if conn == nil {
+		span.RecordError(gerr.ErrClientNotConnected)
+		return nil, gerr.ErrClientNotConnected
+	}
+
+	if err := c.resetSession(conn); err != nil {
+		// The closed connection is accounted for when this client reconnects.
+		if err := conn.Close(); err != nil {
+			c.logger.Debug().Err(err).Msg("Failed to close the server session")
+		}
+		span.RecordError(err)
+		return nil, gerr.ErrSessionResetFailed.Wrap(err)
+	}
+
+	reused := &Client{
+		conn:               conn,
+		logger:             c.logger,
+		ctx:                c.ctx,
+		retry:              c.retry,
+		config:             c.config,
+		idGenerator:        c.idGenerator,
+		startupParameters:  c.startupParameters,
+		startupResponse:    c.startupResponse,
+		chunkSizer:         c.chunkSizer,
+		tlsConfig:          c.tlsConfig,
+		TCPKeepAlive:       c.TCPKeepAlive,
+		TCPKeepAlivePeriod: c.TCPKeepAlivePeriod,
+		TCPFastOpen:        c.TCPFastOpen,
+		DSCP:               c.DSCP,
+		TCPUserTimeout:     c.TCPUserTimeout,
+		ReceiveChunkSize:   c.ReceiveChunkSize,
+		ReceiveDeadline:    c.ReceiveDeadline,
+		SendDeadline:       c.SendDeadline,
+		ReceiveTimeout:     c.ReceiveTimeout,
+		ReceiveStrategy:    c.ReceiveStrategy,
+		ReceiveQuietPeriod: c.ReceiveQuietPeriod,
+		ResetQuery:         c.ResetQuery,
+		DialTimeout:        c.DialTimeout,
+		ID:                 id,
+		Network:            c.Network,
+		Address:            c.Address,
+	}
+	reused.connected.Store(true)
+
+	c.logger.Debug().Str("address", c.Address).Msg("Reset the server session for reuse")
+	span.AddEvent("Reset the server session")
+
+	return reused, nil
+}
+
+// detach takes the connection away from the client and disconnects the client.
+// A pending receive is woken up and waited for, so that it can't read anything
+// sent on the connection afterwards.
+func (c *Client) detach() net.Conn {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+
+	if err := conn.SetReadDeadline(time.Now()); err != nil {
+		c.logger.Debug().Err(err).Msg("Failed to wake up the pending receive")
+	}
+
+	c.receiveMu.Lock()
+	defer c.receiveMu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.connected.Store(false)
+	c.conn = nil
+
+	return conn
+}
+
+// resetSession rolls back any open transaction and runs the reset query, e.g.
+// DISCARD ALL for discarding the prepared statements, the temporary tables and
+// the settings. The responses of the requests the previous client was still
+// waiting for are skipped, up to the response of the ROLLBACK.
+func (c *Client) resetSession(conn net.Conn) error {
+	defer c.restoreDeadlines(conn)
+	if err := conn.SetDeadline(time.Now().Add(config.DefaultSessionResetTimeout)); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	frontend := pgproto3.NewFrontend(conn, conn)
+	// ROLLBACK outside a transaction only emits a warning.
+	frontend.Send(&pgproto3.Query{String: rollbackTag})
+	frontend.Send(&pgproto3.Query{String: c.ResetQuery})
+	if err := frontend.Flush(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	rolledBack, resetting := false, false
+	for {
+		message, err := frontend.Receive()
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		switch message := message.(type) {
+		case *pgproto3.CommandComplete:
+			if !resetting && string(message.CommandTag) == rollbackTag {
+				rolledBack = true
+			}
+		case *pgproto3.ErrorResponse:
+			if resetting {
+				return fmt.Errorf("%s: %s (SQLSTATE %s)", message.Severity, message.Message, message.Code)
+			}
+		case *pgproto3.ReadyForQuery:
+			if !rolledBack {
+				continue
+			}
+			if !resetting {
+				// The next ReadyForQuery is the one of the reset query.
+				resetting = true
+				continue
+			}
+			if TxStatus(message.TxStatus) != TxIdle {
+				return errSessionNotIdle
+			}
+			return nil
+		}
+	}
+}
+
+// restoreDeadlines sets the receive and send deadlines of the connection as
+// configured, after they're replaced for the startup or the reset of the session.
+func (c *Client) restoreDeadlines(conn net.Conn) {
+	readDeadline, writeDeadline := time.Time{}, time.Time{}
+	if c.ReceiveDeadline > 0 {
+		readDeadline = time.Now().Add(c.ReceiveDeadline)
+	}
+	if c.SendDeadline > 0 {
+		writeDeadline = time.Now().Add(c.SendDeadline)
+	}
+
+	if err := conn.SetReadDeadline(readDeadline); err != nil {
+		c.logger.Debug().Err(err).Msg("Failed to restore the receive deadline")
+	}
+	if err := conn.SetWriteDeadline(writeDeadline); err != nil {
+		c.logger.Debug().Err(err).Msg("Failed to restore the send deadline")
+	}
+}
+
+// MD5Password returns the response to the md5 authentication request of PostgreSQL:
+// "md5" followed by md5(md5(password + user) + salt) in hex.
+func MD5Password(user, password string, salt [4]byte) string {
+	//nolint:gosec
+	inner := md5.Sum([]byte(password + user))
+	//nolint:gosec
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt[:]...))
+	return "md5" + hex.EncodeToString(outer[:])
+}
@@ -0,0 +1,132 @@
+package network
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMD5Password tests the response to the md5 authentication request.
+func TestMD5Password(t *testing.T) {
+	assert.Equal(t,
+		"md5bb41a296aab6baccb36ff243a562abff",
+		MD5Password("postgres", "secret", [4]byte{1, 2, 3, 4}))
+}
+
+// newFakePostgres returns a fake PostgreSQL server that requires md5 authentication
+// and answers the queries with their command tags. The queries are sent to the channel.
+func newFakePostgres(t *testing.T, password string, queries chan<- string) *fakeUpstream {
+	t.Helper()
+
+	salt := [4]byte{1, 2, 3, 4}
+	return newFakeUpstream(t, func(conn net.Conn) {
+		backend := pgproto3.NewBackend(conn, conn)
+		startup, err := backend.ReceiveStartupMessage()
+		if err != nil {
+			return
+		}
+		user := startup.(*pgproto3.StartupMessage).Parameters["user"]
+
+		backend.Send(&pgproto3.AuthenticationMD5Password{Salt: salt})
+		if backend.Flush() != nil || backend.SetAuthType(pgproto3.AuthTypeMD5Password) != nil {
+			return
+		}
+		message, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		if message.(*pgproto3.PasswordMessage).Password != MD5Password(user, password, salt) {
+			backend.Send(&pgproto3.ErrorResponse{
+				Severity: "FATAL", Code: "28P01", Message: "password authentication failed",
+			})
+			_ = backend.Flush()
+			return
+		}
+
+		backend.Send(&pgproto3.AuthenticationOk{})
+		backend.Send(&pgproto3.ParameterStatus{Name: "server_version", Value: "16.0"})
+		backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 2})
+		backend.Send(&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)})
+		if backend.Flush() != nil {
+			return
+		}
+
+		for {
+			message, err := backend.Receive()
+			if err != nil {
+				return
+			}
+			if query, ok := message.(*pgproto3.Query); ok {
+				queries <- query.String
+				backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(query.String)})
+				backend.Send(&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)})
+				if backend.Flush() != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// TestPreAuthenticatedSession tests authenticating the server session when the
+// client is created and reusing it after a reset.
+func TestPreAuthenticatedSession(t *testing.T) {
+	queries := make(chan string, 4)
+	upstream := newFakePostgres(t, "secret", queries)
+
+	clientConfig := newTestClientConfig(upstream.Address())
+	clientConfig.User = "postgres"
+	clientConfig.Password = "secret"
+	clientConfig.PreAuthenticate = true
+	clientConfig.ResetQuery = "RESET ALL"
+
+	client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, client)
+
+	startupResponse := client.StartupResponse()
+	require.NotNil(t, startupResponse)
+	status, ok := GetTxStatus(startupResponse)
+	assert.True(t, ok)
+	assert.Equal(t, TxIdle, status)
+	_, ok = NewCancelKeys().Translate(slices.Clone(startupResponse), "tcp", upstream.Address())
+	assert.True(t, ok, "the startup response must have the backend key")
+
+	reused, err := client.Reuse()
+	require.Nil(t, err)
+	assert.False(t, client.IsConnected())
+	assert.True(t, reused.IsConnected())
+	assert.Equal(t, client.GetID(), reused.GetID())
+	assert.Equal(t, "ROLLBACK", <-queries)
+	assert.Equal(t, "RESET ALL", <-queries)
+	assert.Equal(t, 1, upstream.Accepted())
+	reused.Close()
+
+	// The sessions aren't reset without a reset query.
+	clientConfig.ResetQuery = ""
+	unresettable := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, unresettable)
+	defer unresettable.Close()
+	_, err = unresettable.Reuse()
+	assert.NotNil(t, err)
+	assert.True(t, unresettable.IsConnected())
+
+	// The wrong password fails the client.
+	clientConfig.Password = "wrong"
+	assert.Nil(t, NewClient(context.Background(), clientConfig, zerolog.Nop(), nil))
+
+	// The sessions that aren't pre-authenticated can't be reused.
+	clientConfig.PreAuthenticate = false
+	plain := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+	require.NotNil(t, plain)
+	defer plain.Close()
+	assert.Nil(t, plain.StartupResponse())
+	_, err = plain.Reuse()
+	assert.NotNil(t, err)
+	assert.True(t, plain.IsConnected())
+}
@@ -0,0 +1,181 @@
+package network
+
+import (
+	"sync"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+)
+
+// StandbyPool keeps the warm standby connections of the backends, which are connected
+// and pre-authenticated ahead, so that the clients that fail over to a backend are
+// promoted to them right away, instead of waiting for new connections.
+type StandbyPool struct {
+	clients map[string][]IClient
+	mu      sync.Mutex
+}
+
+// NewStandbyPool creates a new empty pool of warm standby connections.
+func NewStandbyPool() *StandbyPool {
+	return &StandbyPool{clients: map[string][]IClient{}}
+}
+
+// Put adds the warm standby connection of the backend to the pool.
+func (sp *StandbyPool) Put(network, address string, client IClient) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	key := network + "://" + address
+	sp.clients[key] = append(sp.clients[key], client)
+}
+
+// Take removes a warm standby connection of the backend from the pool and returns it,
+// or nil if the backend has none. A nil pool has no connections.
+func (sp *StandbyPool) Take(network, address string) IClient {
+	if sp == nil {
+		return nil
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	key := network + "://" + address
+	clients := sp.clients[key]
+	if len(clients) == 0 {
+		return nil
+	}
+	client := clients[len(clients)-1]
+	sp.clients[key] = clients[:len(clients)-1]
+	return client
+}
+
+// TakeAll removes all the warm standby connections of the backend from the pool
+// and returns them.
+func (sp *StandbyPool) TakeAll(network, address string) []IClient {
+	return sp.takeAll(network + "://" + address)
+}
+
+func (sp *StandbyPool) takeAll(key string) []IClient {
+	if sp == nil {
+		return nil
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	clients := sp.clients[key]
+	delete(sp.clients, key)
+	return clients
+}
+
+// Backends returns the backends with warm standby connections, e.g. "tcp://localhost:5432".
+func (sp *StandbyPool) Backends() []string {
+	if sp == nil {
+		return nil
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	backends := make([]string, 0, len(sp.clients))
+	for backend := range sp.clients {
+		backends = append(backends, backend)
+	}
+	return backends
+}
+
+// Size returns the number of the warm standby connections of the backend.
+func (sp *StandbyPool) Size(network, address string) int {
+	if sp == nil {
+		return 0
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return len(sp.clients[network+"://"+address])
+}
+
+// promoteStandby returns a warm standby connection of the backend for a client that
+// fails over to it, or nil if the backend has none left.
+func (pr *Proxy) promoteStandby(backend *config.Client) IClient {
+	for {
+		client := pr.standby.Take(backend.Network, backend.Address)
+		if client == nil {
+			return nil
+		}
+		metrics.WarmStandbyConnections.WithLabelValues(
+			backend.Network + "://" + backend.Address).Dec()
+		if client.IsConnected() {
+			metrics.WarmStandbyPromotions.Inc()
+			pr.Logger.Debug().Str("backend", backend.Network+"://"+backend.Address).Msg(
+				"Promoted a warm standby connection")
+			return client
+		}
+		client.Close()
+	}
+}
+
+// checkStandby health checks the warm standby connections of the backends and refills
+// them up to their warm standby size. The connections of the backends that are down
+// or drained are closed, since they can't be promoted.
+func (pr *Proxy) checkStandby() {
+	if pr.ClientConfig == nil || pr.standby == nil {
+		return
+	}
+
+	clientConfig := *pr.ClientConfig
+	clientConfig.Backends = pr.backendList()
+	for index, backend := range clientConfig.Backends {
+		if backend.WarmStandby <= 0 && pr.standby.Size(backend.Network, backend.Address) == 0 {
+			continue
+		}
+
+		// Keep the warm standby connections that are still open.
+		healthy := pr.backendHealth.IsAvailable(backend.Network, backend.Address)
+		warm := 0
+		for _, client := range pr.standby.TakeAll(backend.Network, backend.Address) {
+			if healthy && warm < backend.WarmStandby && isStandbyAlive(client) {
+				pr.standby.Put(backend.Network, backend.Address, client)
+				warm++
+			} else {
+				client.Close()
+			}
+		}
+
+		// Refill the warm standby connections of the healthy backends.
+		backendConfig := clientConfig.GetBackend(index)
+		for healthy && warm < backend.WarmStandby {
+			client := pr.newClient(backendConfig)
+			if client == nil {
+				pr.Logger.Error().Str("backend", backend.Network+"://"+backend.Address).Msg(
+					"Failed to create a warm standby connection")
+				break
+			}
+			pr.standby.Put(backend.Network, backend.Address, client)
+			warm++
+		}
+
+		metrics.WarmStandbyConnections.WithLabelValues(
+			backend.Network + "://" + backend.Address).Set(float64(warm))
+	}
+}
+
+// closeStandby closes the warm standby connections of the backend, e.g. when it's
+// removed, or of all the backends if the backend is empty, e.g. on shutdown.
+func (pr *Proxy) closeStandby(backend string) {
+	for _, name := range pr.standby.Backends() {
+		if backend != "" && name != backend {
+			continue
+		}
+		for _, client := range pr.standby.takeAll(name) {
+			client.Close()
+		}
+		metrics.WarmStandbyConnections.WithLabelValues(name).Set(0)
+	}
+}
+
+// isStandbyAlive returns true if the idle warm standby connection is still open and
+// its session is clean. The connection that the server sent anything on is closed by
+// the ping, so it's replaced instead of being promoted with a partly read message.
+func isStandbyAlive(client IClient) bool {
+	return client.IsConnected() && client.Ping() == nil
+}
@@ -0,0 +1,74 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWarmStandby tests promoting the warm standby connections of a backend on
+// a failover to it, and health checking and refilling them.
+func TestWarmStandby(t *testing.T) {
+	conns := make(chan net.Conn, 10)
+	standby := newFakeUpstream(t, func(conn net.Conn) { conns <- conn })
+
+	// Get the address of a backend that is down.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	down := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	clientConfig := newTestClientConfig(down)
+	clientConfig.Backends = []config.Backend{
+		{Network: "tcp", Address: down},
+		{Network: "tcp", Address: standby.Address(), WarmStandby: 2},
+	}
+	client, _ := newMemoryClient("client")
+	proxy := newTestProxyWithClients(t, clientConfig, client)
+
+	// The warm standby connections are connected on start.
+	assert.Equal(t, 2, proxy.standby.Size("tcp", standby.Address()))
+	requireAccepted(t, standby, 2)
+	assert.Equal(t, 0, proxy.standby.Size("tcp", down))
+
+	// The client of the backend that is down is promoted to a warm standby connection.
+	promotions := testutil.ToFloat64(metrics.WarmStandbyPromotions)
+	proxy.checkBackends()
+	proxy.recycleClient(client)
+	assert.Equal(t, promotions+1, testutil.ToFloat64(metrics.WarmStandbyPromotions))
+	assert.Equal(t, 1, proxy.standby.Size("tcp", standby.Address()))
+	assert.Equal(t, 2, standby.Accepted(), "no new connection is needed")
+	require.Equal(t, 1, proxy.AvailableConnections.Size())
+	proxy.AvailableConnections.ForEach(func(_, value interface{}) bool {
+		assert.Equal(t, standby.Address(), value.(IClient).GetAddress())
+		return true
+	})
+
+	// The warm standby connections are refilled.
+	proxy.checkStandby()
+	assert.Equal(t, 2, proxy.standby.Size("tcp", standby.Address()))
+	requireAccepted(t, standby, 3)
+	assert.Equal(t, 2.0, testutil.ToFloat64(
+		metrics.WarmStandbyConnections.WithLabelValues("tcp://"+standby.Address())))
+
+	// The warm standby connections closed by the server are replaced, here all the
+	// connections accepted so far, including the promoted one.
+	for range 3 {
+		require.NoError(t, (<-conns).Close())
+	}
+	require.Eventually(t, func() bool {
+		proxy.checkStandby()
+		return standby.Accepted() == 5
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, proxy.standby.Size("tcp", standby.Address()))
+
+	// The warm standby connections of a removed backend are closed.
+	require.NoError(t, proxy.RemoveBackend("tcp://"+standby.Address()))
+	assert.Equal(t, 0, proxy.standby.Size("tcp", standby.Address()))
+}
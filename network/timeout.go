@@ -0,0 +1,91 @@
+package network
+
+import (
+	"errors"
+
+	"github.com/gatewayd-io/gatewayd-plugin-sdk/databases/postgres"
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/gatewayd-io/gatewayd/metrics"
+)
+
+// pgQueryCanceled is the SQLSTATE of a query canceled by a timeout.
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const pgQueryCanceled = "57014"
+
+// handleReceiveTimeout handles a response that didn't arrive within the receive
+// deadline, per the receive timeout policy of the client config. The close policy
+// returns the error as is, so that the client connection is closed. The error policy
+// cancels the request on the server and recycles the server connection, and returns an
+// error response for the client instead. The retry policy resends the request once on
+// the new server connection before doing so, but only if it's a read, since the server
+// may still commit a write before the cancel request reaches it. Either needs a session
+// that can be restored on a new server connection, like the retries on reset, with its
+// prepared statements prepared again, and falls back to the close policy otherwise.
+func (pr *Proxy) handleReceiveTimeout(
+	conn *ConnWrapper, client IClient, stack *Stack,
+	received int, response []byte, err *gerr.GatewayDError,
+) (int, []byte, *gerr.GatewayDError) {
+	// The incomplete messages have their own handling.
+	if err == nil || !IsTimeout(err) || errors.Is(err, errMessageAssemblyTimeout) {
+		return received, response, err
+	}
+
+	policy := config.DefaultReceiveTimeoutPolicy
+	if pr.ClientConfig != nil && pr.ClientConfig.ReceiveTimeoutPolicy != "" {
+		policy = pr.ClientConfig.ReceiveTimeoutPolicy
+	}
+
+	lastRequest := stack.GetLastRequest()
+	if policy == config.CloseOnTimeout || lastRequest == nil || conn.TxStatus().InTransaction() ||
+		client.StartupResponse() == nil {
+		metrics.ReceiveTimeouts.WithLabelValues(config.CloseOnTimeout).Inc()
+		return received, response, err
+	}
+
+	// The response may still arrive, so the request is canceled and the server connection
+	// is recycled right away.
+	pr.Logger.Warn().Err(err).Str("policy", policy).Msg("Server didn't respond within the receive deadline")
+	pr.cancelServerSession(client)
+	if reconnectErr := client.Reconnect(); reconnectErr != nil {
+		pr.Logger.Error().Err(reconnectErr).Msg("Failed to recycle the server connection")
+		metrics.ReceiveTimeouts.WithLabelValues(config.CloseOnTimeout).Inc()
+		return received, response, err
+	}
+	if replayErr := pr.replayPreparedStatements(conn, client, lastRequest.Data); replayErr != nil {
+		pr.Logger.Error().Err(replayErr).Msg("Failed to restore the session on the new server connection")
+		metrics.ReceiveTimeouts.WithLabelValues(config.CloseOnTimeout).Inc()
+		return received, response, err
+	}
+
+	if _, write := RequestWriteCommand(lastRequest.Data, conn.PreparedStatements()); policy == config.RetryOnTimeout && !write {
+		metrics.ReceiveTimeouts.WithLabelValues(config.RetryOnTimeout).Inc()
+		if _, sendErr := pr.sendTrafficToServer(withLabels(pr.Logger, conn), client, lastRequest.Data); sendErr != nil {
+			return 0, nil, sendErr
+		}
+		received, response, err = pr.receiveTrafficFromServer(client)
+		if err == nil || !IsTimeout(err) {
+			return received, response, err
+		}
+
+		pr.Logger.Warn().Err(err).Msg("Server didn't respond to the retried request within the receive deadline")
+		pr.cancelServerSession(client)
+		if reconnectErr := client.Reconnect(); reconnectErr != nil {
+			pr.Logger.Error().Err(reconnectErr).Msg("Failed to recycle the server connection")
+			return received, response, err
+		}
+		if replayErr := pr.replayPreparedStatements(conn, client, lastRequest.Data); replayErr != nil {
+			pr.Logger.Error().Err(replayErr).Msg("Failed to restore the session on the new server connection")
+			return received, response, err
+		}
+	}
+
+	metrics.ReceiveTimeouts.WithLabelValues(config.ErrorOnTimeout).Inc()
+	response = postgres.ErrorResponse(
+		"canceling statement due to the receive deadline of GatewayD", "ERROR", pgQueryCanceled,
+		"The server didn't respond in time")
+	if endsQueryCycle(lastRequest.Data) {
+		response = append(response, pgReadyForQuery, 0, 0, 0, 5, byte(conn.TxStatus()))
+	}
+	return len(response), response, nil
+}
@@ -0,0 +1,143 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStallingPostgres returns a fake PostgreSQL server that trusts the clients and
+// doesn't respond to the queries while stalls is positive, and otherwise answers
+// the queries with their command tags. It counts the cancel requests it receives.
+func newStallingPostgres(t *testing.T, stalls, cancels *atomic.Int32) *fakeUpstream {
+	t.Helper()
+
+	return newFakeUpstream(t, func(conn net.Conn) {
+		defer conn.Close()
+		backend := pgproto3.NewBackend(conn, conn)
+		startup, err := backend.ReceiveStartupMessage()
+		if err != nil {
+			return
+		}
+		if _, ok := startup.(*pgproto3.CancelRequest); ok {
+			cancels.Add(1)
+			return
+		}
+		backend.Send(&pgproto3.AuthenticationOk{})
+		backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 2})
+		backend.Send(&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)})
+		if backend.Flush() != nil {
+			return
+		}
+
+		for {
+			message, err := backend.Receive()
+			if err != nil {
+				return
+			}
+			query, ok := message.(*pgproto3.Query)
+			if !ok || stalls.Add(-1) >= 0 {
+				continue
+			}
+			backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(query.String)})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: byte(TxIdle)})
+			if backend.Flush() != nil {
+				return
+			}
+		}
+	})
+}
+
+// TestProxyReceiveTimeout tests that the requests whose response doesn't arrive
+// within the receive deadline are handled per the receive timeout policy.
+func TestProxyReceiveTimeout(t *testing.T) {
+	read, err := (&pgproto3.Query{String: "SELECT 1"}).Encode(nil)
+	require.NoError(t, err)
+	write, err := (&pgproto3.Query{String: "INSERT INTO users VALUES (1)"}).Encode(nil)
+	require.NoError(t, err)
+
+	// passThrough sends the query through a new proxy, whose server doesn't respond
+	// to the first stalled queries, and returns the number of the cancel requests.
+	passThrough := func(
+		t *testing.T, policy string, query []byte, stalled int32,
+	) (*mockConn, *fakeUpstream, *atomic.Int32, error) {
+		t.Helper()
+
+		stalls, cancels := &atomic.Int32{}, &atomic.Int32{}
+		stalls.Store(stalled)
+		upstream := newStallingPostgres(t, stalls, cancels)
+
+		clientConfig := newTestClientConfig(upstream.Address())
+		clientConfig.User = "postgres"
+		clientConfig.PreAuthenticate = true
+		clientConfig.ReceiveDeadline = 200 * time.Millisecond
+		clientConfig.ReceiveTimeoutPolicy = policy
+		client := NewClient(context.Background(), clientConfig, zerolog.Nop(), nil)
+		require.NotNil(t, client)
+		proxy := newTestProxyWithClients(t, clientConfig, client)
+
+		clientConn := newMockConn(query)
+		conn := NewConnWrapper(ConnWrapper{NetConn: clientConn})
+		require.Nil(t, proxy.Connect(conn))
+		stack := NewStack()
+		require.Nil(t, proxy.PassThroughToServer(conn, stack))
+		if gErr := proxy.PassThroughToClient(conn, stack); gErr != nil {
+			return clientConn, upstream, cancels, gErr
+		}
+		return clientConn, upstream, cancels, nil
+	}
+
+	t.Run("close", func(t *testing.T) {
+		_, upstream, cancels, err := passThrough(t, config.CloseOnTimeout, read, 1)
+		require.Error(t, err)
+		assert.True(t, IsTimeout(err))
+		assert.Equal(t, 1, upstream.Accepted())
+		assert.Equal(t, int32(0), cancels.Load())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		clientConn, upstream, cancels, err := passThrough(t, config.ErrorOnTimeout, read, 1)
+		require.NoError(t, err)
+		assert.Contains(t, string(clientConn.Written()), pgQueryCanceled)
+		status, ok := GetTxStatus(clientConn.Written())
+		assert.True(t, ok)
+		assert.Equal(t, TxIdle, status)
+		// The request is canceled, and the server connection is recycled.
+		assert.Eventually(t, func() bool { return cancels.Load() == 1 }, time.Second, 10*time.Millisecond)
+		assert.Eventually(t, func() bool { return upstream.Accepted() == 3 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		clientConn, upstream, cancels, err := passThrough(t, config.RetryOnTimeout, read, 1)
+		require.NoError(t, err)
+		assert.Contains(t, string(clientConn.Written()), "SELECT 1")
+		assert.NotContains(t, string(clientConn.Written()), pgQueryCanceled)
+		assert.Eventually(t, func() bool { return cancels.Load() == 1 }, time.Second, 10*time.Millisecond)
+		assert.Eventually(t, func() bool { return upstream.Accepted() == 3 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("retry timed out", func(t *testing.T) {
+		clientConn, _, cancels, err := passThrough(t, config.RetryOnTimeout, read, 2)
+		require.NoError(t, err)
+		assert.Contains(t, string(clientConn.Written()), pgQueryCanceled)
+		assert.Eventually(t, func() bool { return cancels.Load() == 2 }, time.Second, 10*time.Millisecond)
+	})
+
+	// The writes aren't retried, since the server may still run them.
+	t.Run("retry write", func(t *testing.T) {
+		clientConn, upstream, cancels, err := passThrough(t, config.RetryOnTimeout, write, 1)
+		require.NoError(t, err)
+		assert.Contains(t, string(clientConn.Written()), pgQueryCanceled)
+		assert.NotContains(t, string(clientConn.Written()), "INSERT")
+		assert.Eventually(t, func() bool { return cancels.Load() == 1 }, time.Second, 10*time.Millisecond)
+		assert.Eventually(t, func() bool { return upstream.Accepted() == 3 }, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,58 @@
+package network
+
+import "encoding/binary"
+
+// TxStatus is the transaction status of a PostgreSQL session, as reported
+// by the server in the ReadyForQuery messages.
+type TxStatus byte
+
+const (
+	TxIdle          TxStatus = 'I' // Not in a transaction block
+	TxInTransaction TxStatus = 'T' // In a transaction block
+	TxFailed        TxStatus = 'E' // In a failed transaction block, awaiting ROLLBACK
+
+	// pgReadyForQuery is the type of the ReadyForQuery backend message.
+	pgReadyForQuery = 'Z'
+)
+
+// InTransaction returns true if the session is in a (possibly failed) transaction block.
+func (s TxStatus) InTransaction() bool {
+	return s == TxInTransaction || s == TxFailed
+}
+
+func (s TxStatus) String() string {
+	switch s {
+	case TxIdle:
+		return "idle"
+	case TxInTransaction:
+		return "transaction"
+	case TxFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// GetTxStatus returns the transaction status from the last ReadyForQuery message
+// in the server response. A response may contain multiple messages.
+func GetTxStatus(response []byte) (TxStatus, bool) {
+	var status TxStatus
+	found := false
+
+	for offset := 0; offset+pgHeaderLength <= len(response); {
+		length := int(binary.BigEndian.Uint32(response[offset+1 : offset+pgHeaderLength]))
+		end := offset + 1 + length
+		if length < 4 || end > len(response) {
+			break
+		}
+
+		if response[offset] == pgReadyForQuery && length == pgHeaderLength {
+			status = TxStatus(response[offset+pgHeaderLength])
+			found = true
+		}
+
+		offset = end
+	}
+
+	return status, found
+}
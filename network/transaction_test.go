@@ -0,0 +1,34 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetTxStatus tests getting the transaction status from the server responses.
+func TestGetTxStatus(t *testing.T) {
+	complete, err := (&pgproto3.CommandComplete{CommandTag: []byte("BEGIN")}).Encode(nil)
+	require.NoError(t, err)
+
+	for _, status := range []TxStatus{TxIdle, TxInTransaction, TxFailed} {
+		ready, err := (&pgproto3.ReadyForQuery{TxStatus: byte(status)}).Encode(nil)
+		require.NoError(t, err)
+
+		txStatus, ok := GetTxStatus(append(append([]byte{}, complete...), ready...))
+		assert.True(t, ok)
+		assert.Equal(t, status, txStatus)
+	}
+
+	_, ok := GetTxStatus(complete)
+	assert.False(t, ok)
+
+	_, ok = GetTxStatus([]byte{'Z', 0, 0})
+	assert.False(t, ok)
+
+	assert.True(t, TxInTransaction.InTransaction())
+	assert.True(t, TxFailed.InTransaction())
+	assert.False(t, TxIdle.InTransaction())
+}
@@ -0,0 +1,154 @@
+package network
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/rs/zerolog"
+)
+
+// TunnelListener is a listener that accepts HTTP CONNECT requests and returns
+// the tunneled connection as if it was a raw TCP connection, so that clients
+// behind HTTP proxies or restrictive firewalls can reach the database.
+// The handshake of each connection is done in its own goroutine, so a slow
+// client doesn't block accepting the other connections.
+type TunnelListener struct {
+	net.Listener
+
+	// HandshakeTimeout bounds the CONNECT handshake of each connection, so that the
+	// clients that never send their request don't hold a goroutine forever.
+	HandshakeTimeout time.Duration
+	Logger           zerolog.Logger
+
+	conns chan net.Conn
+	errs  chan error
+	done  chan struct{}
+	once  sync.Once
+}
+
+var _ net.Listener = (*TunnelListener)(nil)
+
+// NewTunnelListener wraps the listener and starts accepting tunneled connections.
+// The handshakes time out after the default handshake timeout if none is given.
+func NewTunnelListener(
+	listener net.Listener, handshakeTimeout time.Duration, logger zerolog.Logger,
+) *TunnelListener {
+	tunnel := &TunnelListener{
+		Listener:         listener,
+		HandshakeTimeout: config.If(handshakeTimeout > 0, handshakeTimeout, config.DefaultHandshakeTimeout),
+		Logger:           logger,
+		conns:            make(chan net.Conn),
+		errs:             make(chan error, 1),
+		done:             make(chan struct{}),
+	}
+
+	go tunnel.serve()
+
+	return tunnel
+}
+
+// serve accepts the raw connections and performs the tunnel handshake.
+func (t *TunnelListener) serve() {
+	for {
+		conn, err := t.Listener.Accept()
+		if err != nil {
+			select {
+			case t.errs <- err:
+			case <-t.done:
+			}
+			return
+		}
+
+		go func(conn net.Conn) {
+			tunneled, err := t.handshake(conn)
+			if err != nil {
+				t.Logger.Debug().Err(err).Str("remote", RemoteAddr(conn)).Msg(
+					"Failed to establish the HTTP tunnel")
+				_ = conn.Close()
+				return
+			}
+
+			select {
+			case t.conns <- tunneled:
+			case <-t.done:
+				_ = tunneled.Close()
+			}
+		}(conn)
+	}
+}
+
+// handshake reads the HTTP CONNECT request and acknowledges it.
+func (t *TunnelListener) handshake(conn net.Conn) (net.Conn, error) {
+	timeout := config.If(t.HandshakeTimeout > 0, t.HandshakeTimeout, config.DefaultHandshakeTimeout)
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	request, err := http.ReadRequest(reader)
+	if err != nil {
+		return nil, err
+	}
+	_ = request.Body.Close()
+
+	if request.Method != http.MethodConnect {
+		_, _ = conn.Write([]byte("HTTP/1.1 405 Method Not Allowed\r\nConnection: close\r\n\r\n"))
+		return nil, errors.New("unsupported tunnel method: " + request.Method)
+	}
+
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	t.Logger.Debug().Fields(
+		map[string]interface{}{
+			"remote": RemoteAddr(conn),
+			"host":   request.Host,
+		},
+	).Msg("HTTP tunnel established")
+
+	return &tunnelConn{Conn: conn, reader: reader}, nil
+}
+
+// Accept returns the next tunneled connection.
+func (t *TunnelListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-t.conns:
+		return conn, nil
+	case err := <-t.errs:
+		return nil, err
+	case <-t.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting the tunneled connections and closes the listener.
+func (t *TunnelListener) Close() error {
+	t.once.Do(func() { close(t.done) })
+	return t.Listener.Close()
+}
+
+// tunnelConn is a tunneled connection. The client might have sent the first bytes
+// of the database protocol along with the CONNECT request, so they are read from
+// the buffered reader before reading from the connection itself.
+type tunnelConn struct {
+	net.Conn
+
+	reader *bufio.Reader
+}
+
+func (c *tunnelConn) Read(b []byte) (int, error) {
+	if c.reader != nil && c.reader.Buffered() > 0 {
+		return c.reader.Read(b)
+	}
+	return c.Conn.Read(b)
+}
@@ -0,0 +1,97 @@
+package network
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTunnelListener tests accepting a connection through an HTTP CONNECT tunnel.
+func TestTunnelListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	tunnel := NewTunnelListener(listener, config.DefaultHandshakeTimeout, zerolog.Nop())
+	defer tunnel.Close()
+
+	t.Run("CONNECT", func(t *testing.T) {
+		client, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		defer client.Close()
+
+		// The first bytes of the database protocol are sent along with the request.
+		_, err = client.Write([]byte(
+			"CONNECT localhost:5432 HTTP/1.1\r\nHost: localhost:5432\r\n\r\nhello"))
+		require.NoError(t, err)
+
+		response, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+
+		conn, err := tunnel.Accept()
+		require.NoError(t, err)
+		defer conn.Close()
+
+		buffer := make([]byte, 5)
+		read, err := conn.Read(buffer)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(buffer[:read]))
+
+		// The connection is bridged in both directions.
+		_, err = conn.Write([]byte("world"))
+		require.NoError(t, err)
+		require.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+		read, err = client.Read(buffer)
+		require.NoError(t, err)
+		assert.Equal(t, "world", string(buffer[:read]))
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		client, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, err = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		require.NoError(t, err)
+
+		response, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+	})
+
+	require.NoError(t, tunnel.Close())
+	_, err = tunnel.Accept()
+	assert.Error(t, err)
+}
+
+// TestTunnelListenerHandshakeTimeout tests closing the connections that don't send
+// their CONNECT request in time, with the default timeout if none is configured.
+func TestTunnelListenerHandshakeTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unbounded := NewTunnelListener(listener, 0, zerolog.Nop())
+	assert.Equal(t, config.DefaultHandshakeTimeout, unbounded.HandshakeTimeout)
+	require.NoError(t, unbounded.Close())
+
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tunnel := NewTunnelListener(listener, 50*time.Millisecond, zerolog.Nop())
+	defer tunnel.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	// The client never sends its request, so the connection is closed.
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = client.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
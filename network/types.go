@@ -2,5 +2,5 @@ package network
 
 type Field struct {
 	Name  string
-	Value []byte
+	Value interface{}
 }
@@ -0,0 +1,39 @@
+package network
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var errTCPUserTimeoutNotSupported = errors.New("TCP user timeout is not supported on this platform")
+
+// userTimeoutControl returns the control function of a listener or a dialer that sets
+// the TCP user timeout of the TCP sockets, i.e. how long the sent data may stay
+// unacknowledged before the connection is closed. Unlike the TCP keep alive, it detects
+// a dead peer while a request is in flight, e.g. a backend that went down mid-query.
+// The accepted sockets inherit it from the listening socket. It's best effort: if it
+// can't be set, e.g. because the platform doesn't support it, the error is logged and
+// the socket is used without it.
+func userTimeoutControl(timeout time.Duration, logger zerolog.Logger) socketControl {
+	return func(network, _ string, rawConn syscall.RawConn) error {
+		if !strings.HasPrefix(network, "tcp") {
+			return nil
+		}
+
+		var err error
+		if controlErr := rawConn.Control(func(fd uintptr) {
+			err = setTCPUserTimeout(fd, timeout)
+		}); controlErr != nil {
+			err = controlErr
+		}
+		if err != nil {
+			logger.Warn().Err(err).Str("tcpUserTimeout", timeout.String()).Msg(
+				"Failed to set the TCP user timeout, continuing without it")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,16 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// setTCPUserTimeout sets the TCP user timeout of the socket, in milliseconds.
+func setTCPUserTimeout(fd uintptr, timeout time.Duration) error {
+	return unix.SetsockoptInt( //nolint:wrapcheck
+		int(fd), unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, int(timeout.Milliseconds()))
+}
@@ -0,0 +1,68 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// userTimeoutOf returns the TCP user timeout of the connection, in milliseconds.
+func userTimeoutOf(t *testing.T, conn net.Conn) int {
+	t.Helper()
+
+	tcpConn, ok := conn.(*net.TCPConn)
+	require.True(t, ok)
+	rawConn, err := tcpConn.SyscallConn()
+	require.NoError(t, err)
+
+	var timeout int
+	require.NoError(t, rawConn.Control(func(fd uintptr) {
+		timeout, err = unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT)
+	}))
+	require.NoError(t, err)
+	return timeout
+}
+
+// TestTCPUserTimeout tests setting the TCP user timeout of the dialed and accepted sockets.
+func TestTCPUserTimeout(t *testing.T) {
+	listenConfig := newListenConfig(false, 0, 0, 3*time.Second, zerolog.Nop())
+	listener, err := listenConfig.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn
+	}()
+
+	conn, err := newDialer(time.Second, false, 0, 5*time.Second, zerolog.Nop()).Dial(
+		"tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, 5000, userTimeoutOf(t, conn))
+
+	// The accepted socket inherits the TCP user timeout of the listening socket.
+	serverConn := <-accepted
+	defer serverConn.Close()
+	assert.Equal(t, 3000, userTimeoutOf(t, serverConn))
+
+	// The TCP user timeout isn't set unless it's configured.
+	assert.Nil(t, newDialer(time.Second, false, 0, 0, zerolog.Nop()).Control)
+	plain, err := newDialer(time.Second, false, 0, 0, zerolog.Nop()).Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer plain.Close()
+	assert.Equal(t, 0, userTimeoutOf(t, plain))
+}
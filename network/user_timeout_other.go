@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package network
+
+import "time"
+
+// setTCPUserTimeout is not supported on this platform.
+func setTCPUserTimeout(uintptr, time.Duration) error {
+	return errTCPUserTimeoutNotSupported
+}
@@ -4,13 +4,25 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"slices"
+	"syscall"
 
 	gerr "github.com/gatewayd-io/gatewayd/errors"
 	"github.com/rs/zerolog"
 )
 
+var (
+	errEmptyPayload     = errors.New("payload is empty")
+	errPayloadTooLarge  = errors.New("payload is too large")
+	errMalformedPayload = errors.New("payload is malformed")
+	errMessagesChanged  = errors.New("payload has other messages than the original")
+)
+
 // GetID returns a unique ID (hash) for a network connection.
 func GetID(network, address string, seed int, logger zerolog.Logger) string {
 	hash := sha256.New()
@@ -42,6 +54,8 @@ func Resolve(network, address string, logger zerolog.Logger) (string, *gerr.Gate
 			return addr.String(), nil
 		}
 		return "", gerr.ErrResolveFailed.Wrap(err)
+	case MemoryNetwork:
+		return address, nil
 	default:
 		logger.Error().Str("network", network).Msg("Network is not supported")
 		return "", gerr.ErrNetworkNotSupported
@@ -51,7 +65,7 @@ func Resolve(network, address string, logger zerolog.Logger) (string, *gerr.Gate
 // trafficData creates the ingress/egress map for the traffic hooks.
 func trafficData(
 	conn net.Conn,
-	client *Client,
+	client IClient,
 	fields []Field,
 	err interface{},
 ) map[string]interface{} {
@@ -78,6 +92,11 @@ func trafficData(
 	if err != nil {
 		switch typedErr := err.(type) {
 		case *gerr.GatewayDError:
+			// A nil *GatewayDError is not a nil interface.
+			if typedErr != nil {
+				data["error"] = typedErr.Error()
+				data["errorCode"] = typedErr.Code().String()
+			}
 		case error:
 			data["error"] = typedErr.Error()
 		case string:
@@ -107,6 +126,80 @@ func extractFieldValue(result map[string]interface{}, fieldName string) ([]byte,
 	return data, err
 }
 
+// validatePayload validates a request or response modified by the plugins. The
+// payload must not be empty or larger than the max size, if set. If the original
+// payload consists of complete PostgreSQL messages, so must the modified one.
+func validatePayload(payload, original []byte, maxSize int) error {
+	if len(payload) == 0 {
+		return errEmptyPayload
+	}
+
+	if maxSize > 0 && len(payload) > maxSize {
+		return errPayloadTooLarge
+	}
+
+	if IsPostgresMessages(original) && !IsPostgresMessages(payload) {
+		return errMalformedPayload
+	}
+
+	return nil
+}
+
+// VerifyMessageTypes is a request verifier that rejects the requests modified by the
+// plugins into other PostgreSQL messages than the original ones, e.g. a plugin that
+// replaced a query with a parse message, or dropped or appended a message. The
+// contents of the messages can still be modified, e.g. the query.
+func VerifyMessageTypes(modified, original []byte) error {
+	if !IsPostgresMessages(original) {
+		return nil
+	}
+	if !slices.Equal(messageTypes(modified), messageTypes(original)) {
+		return errMessagesChanged
+	}
+	return nil
+}
+
+// messageTypes returns the types of the PostgreSQL messages in the data, in order,
+// or nil for an untyped message, like the startup message.
+func messageTypes(data []byte) []byte {
+	if len(data) >= 4 && int(binary.BigEndian.Uint32(data[0:4])) == len(data) {
+		return nil
+	}
+
+	types := []byte{}
+	for offset := 0; offset+pgHeaderLength <= len(data); {
+		types = append(types, data[offset])
+		offset += 1 + int(binary.BigEndian.Uint32(data[offset+1:offset+pgHeaderLength]))
+	}
+	return types
+}
+
+// IsPostgresMessages returns true if the data consists of complete PostgreSQL
+// messages, either typed messages or a single untyped startup-like message.
+func IsPostgresMessages(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+
+	// Untyped messages, like the startup message, start with their length.
+	if int(binary.BigEndian.Uint32(data[0:4])) == len(data) {
+		return true
+	}
+
+	for offset := 0; offset < len(data); {
+		if offset+pgHeaderLength > len(data) {
+			return false
+		}
+		length := int(binary.BigEndian.Uint32(data[offset+1 : offset+pgHeaderLength]))
+		if length < 4 || offset+1+length > len(data) {
+			return false
+		}
+		offset += 1 + length
+	}
+
+	return true
+}
+
 // LocalAddr returns the local address of the connection.
 func LocalAddr(conn net.Conn) string {
 	if conn != nil && conn.LocalAddr() != nil {
@@ -142,3 +235,20 @@ func IsPostgresSSLRequest(data []byte) bool {
 
 	return true
 }
+
+// IsConnClosed returns true if the error is caused by a connection that is closed
+// by either side.
+func IsConnClosed(err error) bool {
+	return errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// IsTimeout returns true if the error is caused by a deadline of the connection,
+// e.g. the receive deadline of a server connection.
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/big"
 	"net"
+	"slices"
 	"testing"
 	"time"
 
@@ -65,6 +66,37 @@ func TestIsPostgresSSLRequest(t *testing.T) {
 	assert.False(t, IsPostgresSSLRequest(invalidSSLRequest))
 }
 
+// TestValidatePayload tests validating the payloads modified by the plugins.
+func TestValidatePayload(t *testing.T) {
+	query := CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00"))
+	modified := CreatePostgreSQLPacket('Q', []byte("SELECT 2\x00"))
+
+	assert.NoError(t, validatePayload(modified, query, config.DefaultMaxPayloadSize))
+	assert.NoError(t, validatePayload(CreatePgStartupPacket(), CreatePgStartupPacket(), 0))
+	assert.ErrorIs(t, validatePayload([]byte{}, query, 0), errEmptyPayload)
+	assert.ErrorIs(t, validatePayload(modified, query, len(modified)-1), errPayloadTooLarge)
+	assert.ErrorIs(t, validatePayload(modified[:len(modified)-1], query, 0), errMalformedPayload)
+
+	// Payloads of other protocols are only checked for their size.
+	assert.NoError(t, validatePayload([]byte("modified"), []byte("original"), 0))
+}
+
+// TestVerifyMessageTypes tests rejecting the requests modified into other messages.
+func TestVerifyMessageTypes(t *testing.T) {
+	query := CreatePostgreSQLPacket('Q', []byte("SELECT 1\x00"))
+	modified := CreatePostgreSQLPacket('Q', []byte("SELECT 2\x00"))
+	parse := CreatePostgreSQLPacket('P', []byte("\x00SELECT 1\x00\x00\x00"))
+
+	assert.NoError(t, VerifyMessageTypes(modified, query))
+	assert.NoError(t, VerifyMessageTypes(CreatePgStartupPacket(), CreatePgStartupPacket()))
+	assert.ErrorIs(t, VerifyMessageTypes(parse, query), errMessagesChanged)
+	assert.ErrorIs(t, VerifyMessageTypes(append(slices.Clone(query), query...), query), errMessagesChanged)
+	assert.ErrorIs(t, VerifyMessageTypes(query, CreatePgStartupPacket()), errMessagesChanged)
+
+	// Payloads of other protocols aren't verified.
+	assert.NoError(t, VerifyMessageTypes([]byte("modified"), []byte("original")))
+}
+
 var seedValues = []int{1000, 10000, 100000, 1000000, 10000000}
 
 func BenchmarkGetID(b *testing.B) {
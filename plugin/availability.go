@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"time"
+
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Availability is the availability of a plugin, as reported by the admin API.
+type Availability struct {
+	Available bool `json:"available"`
+	// UnavailableSince is when the plugin became unavailable, if it's unavailable.
+	UnavailableSince *time.Time `json:"unavailableSince,omitempty"`
+	// Error is the error of the failed call that made the plugin unavailable.
+	Error string `json:"error,omitempty"`
+	// Breakers are the states of the circuit breakers of the hooks of the plugin,
+	// by the names of the hooks in the config, if the circuit breaker is enabled.
+	Breakers map[string]BreakerState `json:"breakers,omitempty"`
+}
+
+// unavailability is why and since when a plugin is unavailable.
+type unavailability struct {
+	since time.Time
+	err   string
+}
+
+// dispense returns the client of the plugin, which is dispensed once and cached
+// until the plugin is removed, instead of being dispensed on every use.
+func (reg *Registry) dispense(plugin *Plugin) (v1.GatewayDPluginServiceClient, *gerr.GatewayDError) {
+	if reg.clients != nil {
+		if client, ok := reg.clients.Load(plugin.ID.Name); ok {
+			if pluginV1, ok := client.(v1.GatewayDPluginServiceClient); ok {
+				return pluginV1, nil
+			}
+		}
+	}
+
+	pluginV1, err := plugin.Dispense()
+	if err != nil {
+		return nil, err
+	}
+	if reg.clients != nil {
+		reg.clients.Store(plugin.ID.Name, pluginV1)
+	}
+	return pluginV1, nil
+}
+
+// isUnavailableError returns true if the error of a call to a plugin means that the
+// plugin can't be reached, e.g. because its process died.
+func isUnavailableError(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
+
+// unavailableOwner returns the name of the plugin that registered the hook of the
+// priority, and true if the plugin is unavailable.
+func (reg *Registry) unavailableOwner(hookName v1.HookName, priority sdkPlugin.Priority) (string, bool) {
+	owner, ok := reg.hookOwner(hookName, priority)
+	if !ok || owner.Type != HookOwnerPlugin {
+		return "", false
+	}
+	return owner.Name, !reg.IsAvailable(owner.Name)
+}
+
+// markUnavailable marks the plugin as unavailable after a failed call, so that its
+// hooks are skipped, instead of failing on every run, until the health check of the
+// plugins either finds it back or restarts it. The health check is triggered right
+// away through OnUnavailable.
+func (reg *Registry) markUnavailable(name string, err error) {
+	if reg.unavailable == nil {
+		return
+	}
+	if _, loaded := reg.unavailable.LoadOrStore(
+		name, unavailability{since: time.Now(), err: err.Error()}); loaded {
+		return
+	}
+
+	metrics.PluginsUnavailable.Inc()
+	reg.Logger.Warn().Err(err).Str("name", name).Msg(
+		"The plugin is unavailable, skipping its hooks until it's back")
+	if reg.OnUnavailable != nil {
+		go reg.OnUnavailable(name)
+	}
+}
+
+// MarkAvailable marks the plugin as available again, e.g. after it passed the health
+// check, and returns true if it was unavailable.
+func (reg *Registry) MarkAvailable(name string) bool {
+	if reg.unavailable == nil {
+		return false
+	}
+	if _, loaded := reg.unavailable.LoadAndDelete(name); !loaded {
+		return false
+	}
+
+	metrics.PluginsUnavailable.Dec()
+	reg.Logger.Info().Str("name", name).Msg("The plugin is available again")
+	return true
+}
+
+// IsAvailable returns false if the plugin is marked as unavailable.
+func (reg *Registry) IsAvailable(name string) bool {
+	if reg.unavailable == nil {
+		return true
+	}
+	_, unavailable := reg.unavailable.Load(name)
+	return !unavailable
+}
+
+// Availabilities returns the availability of the plugins in the registry, by name.
+func (reg *Registry) Availabilities() map[string]Availability {
+	availabilities := map[string]Availability{}
+	reg.ForEach(func(pluginID sdkPlugin.Identifier, _ *Plugin) {
+		availability := Availability{Available: true}
+		if reg.unavailable != nil {
+			if value, ok := reg.unavailable.Load(pluginID.Name); ok {
+				if state, ok := value.(unavailability); ok {
+					availability = Availability{UnavailableSince: &state.since, Error: state.err}
+				}
+			}
+		}
+		availability.Breakers = reg.breakerStates(pluginID.Name)
+		availabilities[pluginID.Name] = availability
+	})
+	return availabilities
+}
+
+// forget drops the cached client and the availability of the removed plugin.
+func (reg *Registry) forget(name string) {
+	if reg.clients != nil {
+		reg.clients.Delete(name)
+	}
+	if reg.unavailable != nil {
+		if _, loaded := reg.unavailable.LoadAndDelete(name); loaded {
+			metrics.PluginsUnavailable.Dec()
+		}
+	}
+}
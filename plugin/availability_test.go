@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestUnavailablePlugin tests skipping the hooks of a plugin that can't be reached,
+// until it's marked as available again.
+func TestUnavailablePlugin(t *testing.T) {
+	reg := NewPluginRegistry(t)
+	unavailable := make(chan string, 1)
+	reg.OnUnavailable = func(name string) { unavailable <- name }
+	reg.Add(&Plugin{ID: sdkPlugin.Identifier{Name: "cache"}})
+
+	calls := 0
+	reg.AddHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 10, func(
+		_ context.Context,
+		_ *v1.Struct,
+		_ ...grpc.CallOption,
+	) (*v1.Struct, error) {
+		calls++
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	})
+	reg.setHookOwner(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 10, HookInfo{
+		Name: "cache", Type: HookOwnerPlugin,
+	})
+
+	_, err := reg.Run(
+		context.Background(), map[string]any{}, v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.False(t, reg.IsAvailable("cache"))
+	assert.Equal(t, "cache", <-unavailable)
+	availability := reg.Availabilities()["cache"]
+	assert.False(t, availability.Available)
+	assert.NotNil(t, availability.UnavailableSince)
+	assert.Contains(t, availability.Error, "connection refused")
+
+	// The hook is kept, but it's skipped while the plugin is unavailable.
+	assert.Len(t, reg.Hooks()[v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT], 1)
+	_, err = reg.Run(
+		context.Background(), map[string]any{}, v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+
+	assert.True(t, reg.MarkAvailable("cache"))
+	assert.False(t, reg.MarkAvailable("cache"))
+	assert.Equal(t, Availability{Available: true}, reg.Availabilities()["cache"])
+	_, err = reg.Run(
+		context.Background(), map[string]any{}, v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,179 @@
+package plugin
+
+import (
+	"sync"
+	"time"
+
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+)
+
+// BreakerState is the state of the circuit breaker of a hook of a plugin.
+type BreakerState string
+
+const (
+	// BreakerClosed runs the hook.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen skips the hook until the cooldown is over.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen runs the hook once to retest it, and skips it meanwhile.
+	BreakerHalfOpen BreakerState = "halfOpen"
+)
+
+// breakerKey is the hook of a plugin that a circuit breaker is for.
+type breakerKey struct {
+	plugin   string
+	hookName v1.HookName
+}
+
+// circuitBreaker counts the consecutive failed or slow runs of a hook of a plugin,
+// and opens after too many of them.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// probing is true while the single run of the half-open breaker is running.
+	probing bool
+}
+
+// allow returns true if the hook can run. The open breaker becomes half-open once
+// the cooldown is over, and lets a single run through to retest the hook.
+func (b *circuitBreaker) allow(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record records the run of the hook and returns the state of the breaker before
+// and after it. The breaker opens after the failures in a row, or on the failed
+// retest, and closes on any successful run.
+func (b *circuitBreaker) record(failed bool, failures int) (BreakerState, BreakerState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	previous := b.state
+	if !failed {
+		b.failures = 0
+		b.state = BreakerClosed
+		b.probing = false
+		return previous, b.state
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= failures {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+		b.probing = false
+	}
+	return previous, b.state
+}
+
+// State returns the state of the breaker.
+func (b *circuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// breaker returns the circuit breaker of the hook of the priority, if the circuit
+// breaker is enabled and the hook is registered by a plugin.
+func (reg *Registry) breaker(hookName v1.HookName, priority sdkPlugin.Priority) (breakerKey, *circuitBreaker) {
+	if !reg.CircuitBreaker.Enabled || reg.breakers == nil {
+		return breakerKey{}, nil
+	}
+	owner, ok := reg.hookOwner(hookName, priority)
+	if !ok || owner.Type != HookOwnerPlugin {
+		return breakerKey{}, nil
+	}
+
+	key := breakerKey{plugin: owner.Name, hookName: hookName}
+	value, _ := reg.breakers.LoadOrStore(key, &circuitBreaker{state: BreakerClosed})
+	breaker, ok := value.(*circuitBreaker)
+	if !ok {
+		return breakerKey{}, nil
+	}
+	return key, breaker
+}
+
+// recordHookRun records the run of the hook of a plugin in its circuit breaker. The
+// run failed if the hook returned an error or no result, or if it took longer than
+// the slow threshold.
+func (reg *Registry) recordHookRun(
+	key breakerKey, breaker *circuitBreaker, duration time.Duration, failed bool,
+) {
+	slow := reg.CircuitBreaker.SlowThreshold > 0 && duration > reg.CircuitBreaker.SlowThreshold
+	failures := config.If(
+		reg.CircuitBreaker.Failures > 0, reg.CircuitBreaker.Failures, config.DefaultBreakerFailures)
+	previous, state := breaker.record(failed || slow, failures)
+	if previous == state {
+		return
+	}
+
+	hook := ConfigHookName(key.hookName)
+	metrics.PluginCircuitBreakers.WithLabelValues(key.plugin, hook).Set(
+		config.If[float64](state == BreakerClosed, 0, 1))
+	logger := reg.Logger.With().Str("plugin", key.plugin).Str("hook", hook).Logger()
+	if state == BreakerOpen {
+		logger.Warn().Dur("duration", duration).Bool("slow", slow).Msg(
+			"Opened the circuit breaker, skipping the hook of the plugin for the cooldown")
+	} else if state == BreakerClosed {
+		logger.Info().Msg("Closed the circuit breaker of the hook of the plugin")
+	}
+}
+
+// breakerStates returns the states of the circuit breakers of the hooks of the plugin,
+// by the names of the hooks in the config.
+func (reg *Registry) breakerStates(name string) map[string]BreakerState {
+	if reg.breakers == nil {
+		return nil
+	}
+
+	var states map[string]BreakerState
+	reg.breakers.Range(func(key, value any) bool {
+		if key, ok := key.(breakerKey); ok && key.plugin == name {
+			if breaker, ok := value.(*circuitBreaker); ok {
+				if states == nil {
+					states = map[string]BreakerState{}
+				}
+				states[ConfigHookName(key.hookName)] = breaker.State()
+			}
+		}
+		return true
+	})
+	return states
+}
+
+// forgetBreakers drops the circuit breakers of the hooks of the removed plugin.
+func (reg *Registry) forgetBreakers(name string) {
+	if reg.breakers == nil {
+		return
+	}
+
+	reg.breakers.Range(func(key, _ any) bool {
+		if key, ok := key.(breakerKey); ok && key.plugin == name {
+			reg.breakers.Delete(key)
+			metrics.PluginCircuitBreakers.DeleteLabelValues(key.plugin, ConfigHookName(key.hookName))
+		}
+		return true
+	})
+}
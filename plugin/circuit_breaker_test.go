@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// TestCircuitBreaker tests skipping the hook of a plugin that keeps failing for the
+// cooldown, then retesting it with a single run.
+func TestCircuitBreaker(t *testing.T) {
+	reg := NewPluginRegistry(t)
+	reg.CircuitBreaker = config.PluginCircuitBreaker{
+		Enabled: true, Failures: 2, Cooldown: 50 * time.Millisecond,
+	}
+	reg.Add(&Plugin{ID: sdkPlugin.Identifier{Name: "cache"}})
+
+	calls := 0
+	failing := true
+	reg.AddHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 10, func(
+		_ context.Context,
+		args *v1.Struct,
+		_ ...grpc.CallOption,
+	) (*v1.Struct, error) {
+		calls++
+		if failing {
+			return args, errors.New("failed")
+		}
+		return args, nil
+	})
+	reg.setHookOwner(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 10, HookInfo{
+		Name: "cache", Type: HookOwnerPlugin,
+	})
+	run := func() {
+		_, err := reg.Run(
+			context.Background(), map[string]any{}, v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)
+		assert.Nil(t, err)
+	}
+	open := metrics.PluginCircuitBreakers.WithLabelValues("cache", "onTrafficFromClient")
+
+	// The breaker opens after the failures in a row, and the hook is skipped.
+	run()
+	assert.Equal(t, map[string]BreakerState{"onTrafficFromClient": BreakerClosed},
+		reg.Availabilities()["cache"].Breakers)
+	run()
+	assert.Equal(t, map[string]BreakerState{"onTrafficFromClient": BreakerOpen},
+		reg.Availabilities()["cache"].Breakers)
+	assert.Equal(t, float64(1), testutil.ToFloat64(open))
+	run()
+	assert.Equal(t, 2, calls)
+
+	// The failed retest after the cooldown opens the breaker again.
+	time.Sleep(60 * time.Millisecond)
+	run()
+	assert.Equal(t, 3, calls)
+	run()
+	assert.Equal(t, 3, calls)
+
+	// The successful retest closes the breaker.
+	failing = false
+	time.Sleep(60 * time.Millisecond)
+	run()
+	run()
+	assert.Equal(t, 5, calls)
+	assert.Equal(t, map[string]BreakerState{"onTrafficFromClient": BreakerClosed},
+		reg.Availabilities()["cache"].Breakers)
+	assert.Equal(t, float64(0), testutil.ToFloat64(open))
+
+	// The breakers of the removed plugin are dropped.
+	reg.Remove(sdkPlugin.Identifier{Name: "cache"})
+	assert.Nil(t, reg.breakerStates("cache"))
+}
+
+// TestCircuitBreakerSlow tests counting the slow runs of a hook as failed.
+func TestCircuitBreakerSlow(t *testing.T) {
+	breaker := &circuitBreaker{state: BreakerClosed}
+	assert.True(t, breaker.allow(time.Minute))
+
+	reg := NewPluginRegistry(t)
+	reg.CircuitBreaker = config.PluginCircuitBreaker{
+		Enabled: true, Failures: 1, SlowThreshold: time.Millisecond, Cooldown: time.Minute,
+	}
+	key := breakerKey{plugin: "slow", hookName: v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_SERVER}
+	reg.recordHookRun(key, breaker, time.Microsecond, false)
+	assert.Equal(t, BreakerClosed, breaker.State())
+	reg.recordHookRun(key, breaker, time.Second, false)
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.False(t, breaker.allow(time.Minute))
+}
@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/rs/zerolog"
+)
+
+// DisabledHookNames returns the hook names of the disabled hook types by their names
+// in the config, e.g. onTrafficFromClient. The unknown hook names are logged and skipped.
+func DisabledHookNames(names []string, logger zerolog.Logger) []v1.HookName {
+	hookNames := make([]v1.HookName, 0, len(names))
+	for _, name := range names {
+		hookName, ok := HookNameOf(name)
+		if !ok {
+			logger.Warn().Str("hook", name).Msg("Unknown hook in the disabled hooks")
+			continue
+		}
+		hookNames = append(hookNames, hookName)
+	}
+	return hookNames
+}
+
+// SetDisabledHooks replaces the disabled hook types, whose hooks are skipped entirely
+// by Run until they're enabled again, e.g. to mitigate an incident caused by an
+// expensive hook without changing the config of the plugins. It's safe to call
+// while the hooks are running, e.g. on reload.
+func (reg *Registry) SetDisabledHooks(hookNames []v1.HookName) {
+	if reg.disabledHooks == nil {
+		return
+	}
+
+	disabled := make(map[v1.HookName]bool, len(hookNames))
+	for _, hookName := range hookNames {
+		disabled[hookName] = true
+		reg.disabledHooks.Store(hookName, true)
+	}
+	reg.disabledHooks.Range(func(key, _ any) bool {
+		if hookName, ok := key.(v1.HookName); ok && !disabled[hookName] {
+			reg.disabledHooks.Delete(hookName)
+		}
+		return true
+	})
+
+	if len(hookNames) > 0 {
+		names := make([]string, 0, len(hookNames))
+		for _, hookName := range hookNames {
+			names = append(names, ConfigHookName(hookName))
+		}
+		reg.Logger.Warn().Strs("hooks", names).Msg("The hooks of these types are disabled")
+	}
+}
+
+// IsHookDisabled returns true if the hooks of the hook type are disabled.
+func (reg *Registry) IsHookDisabled(hookName v1.HookName) bool {
+	if reg.disabledHooks == nil {
+		return false
+	}
+	_, disabled := reg.disabledHooks.Load(hookName)
+	return disabled
+}
@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// TestDisabledHookNames tests parsing the disabled hook types in the config.
+func TestDisabledHookNames(t *testing.T) {
+	assert.Equal(t,
+		[]v1.HookName{v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT},
+		DisabledHookNames([]string{"onTrafficFromClient", "onUnknown"}, zerolog.Nop()))
+	assert.Empty(t, DisabledHookNames(nil, zerolog.Nop()))
+}
+
+// TestSetDisabledHooks tests skipping the hooks of the disabled hook types, until
+// they're enabled again.
+func TestSetDisabledHooks(t *testing.T) {
+	reg := NewPluginRegistry(t)
+	calls := 0
+	reg.AddHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 0, func(
+		_ context.Context,
+		args *v1.Struct,
+		_ ...grpc.CallOption,
+	) (*v1.Struct, error) {
+		calls++
+		return args, nil
+	})
+	skipped := metrics.PluginHooksSkipped.WithLabelValues("onTrafficFromClient")
+	before := testutil.ToFloat64(skipped)
+
+	reg.SetDisabledHooks([]v1.HookName{v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT})
+	assert.True(t, reg.IsHookDisabled(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT))
+	assert.False(t, reg.IsHookDisabled(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_SERVER))
+	result, err := reg.Run(
+		context.Background(), map[string]any{"request": "test"}, v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, before+1, testutil.ToFloat64(skipped))
+
+	// The hook types that aren't in the new list are enabled again.
+	reg.SetDisabledHooks(nil)
+	assert.False(t, reg.IsHookDisabled(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT))
+	result, err = reg.Run(
+		context.Background(), map[string]any{"request": "test"}, v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)
+	assert.Nil(t, err)
+	assert.Equal(t, "test", result["request"])
+	assert.Equal(t, 1, calls)
+}
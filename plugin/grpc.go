@@ -0,0 +1,35 @@
+package plugin
+
+import (
+	"github.com/gatewayd-io/gatewayd/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// GRPCDialOptions returns the dial options of the gRPC connections to the plugins for
+// the gRPC settings. They're applied after the defaults of go-plugin, which don't limit
+// the size of the messages and don't send keepalive pings, so the unset settings keep
+// the defaults.
+func GRPCDialOptions(settings config.PluginGRPC) []grpc.DialOption {
+	callOptions := []grpc.CallOption{}
+	if settings.MaxRecvMsgSize > 0 {
+		callOptions = append(callOptions, grpc.MaxCallRecvMsgSize(settings.MaxRecvMsgSize))
+	}
+	if settings.MaxSendMsgSize > 0 {
+		callOptions = append(callOptions, grpc.MaxCallSendMsgSize(settings.MaxSendMsgSize))
+	}
+
+	options := []grpc.DialOption{}
+	if len(callOptions) > 0 {
+		options = append(options, grpc.WithDefaultCallOptions(callOptions...))
+	}
+	if settings.KeepAliveTime > 0 {
+		options = append(options, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time: settings.KeepAliveTime,
+			Timeout: config.If(
+				settings.KeepAliveTimeout > 0, settings.KeepAliveTimeout, config.DefaultPluginKeepAliveTimeout),
+			PermitWithoutStream: settings.KeepAlivePermitWithoutStream,
+		}))
+	}
+	return options
+}
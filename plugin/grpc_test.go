@@ -0,0 +1,20 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_GRPCDialOptions tests that only the configured gRPC settings are applied.
+func Test_GRPCDialOptions(t *testing.T) {
+	assert.Empty(t, GRPCDialOptions(config.PluginGRPC{}))
+	assert.Len(t, GRPCDialOptions(config.PluginGRPC{MaxRecvMsgSize: 1024, MaxSendMsgSize: 1024}), 1)
+	assert.Len(t, GRPCDialOptions(config.PluginGRPC{KeepAliveTime: time.Minute}), 1)
+	assert.Len(t, GRPCDialOptions(config.PluginGRPC{
+		MaxRecvMsgSize: 1024,
+		KeepAliveTime:  time.Minute,
+	}), 2)
+}
@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"sort"
+	"strings"
+
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/config"
+	"go.opentelemetry.io/otel"
+)
+
+// The types of the owners of the hooks.
+const (
+	HookOwnerPlugin = "plugin"
+	HookOwnerScript = "script"
+)
+
+// HookInfo describes a hook in the chain of a hook name.
+type HookInfo struct {
+	Priority sdkPlugin.Priority `json:"priority"`
+	// Name is the name of the plugin or the script that registered the hook,
+	// or empty if the hook is registered by GatewayD itself.
+	Name string `json:"name"`
+	// Type is either plugin or script, or empty if the hook is registered by GatewayD itself.
+	Type string `json:"type"`
+}
+
+// setHookOwner records the plugin or the script that registered the hook of the priority.
+func (reg *Registry) setHookOwner(hookName v1.HookName, priority sdkPlugin.Priority, owner HookInfo) {
+	reg.updateHooks(func(table *hookTable) {
+		if table.owners[hookName] == nil {
+			table.owners[hookName] = map[sdkPlugin.Priority]HookInfo{}
+		}
+		owner.Priority = priority
+		table.owners[hookName][priority] = owner
+	})
+}
+
+// Chain returns the hooks of the hook name in the order they run, i.e. by priority,
+// with the plugin or the script that registered each of them.
+func (reg *Registry) Chain(hookName v1.HookName) []HookInfo {
+	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "Chain")
+	defer span.End()
+
+	table := reg.currentHooks()
+	chain := make([]HookInfo, 0, len(table.hooks[hookName]))
+	for priority := range table.hooks[hookName] {
+		info, ok := table.owner(hookName, priority)
+		if !ok {
+			info = HookInfo{Priority: priority}
+		}
+		chain = append(chain, info)
+	}
+	sort.SliceStable(chain, func(i, j int) bool {
+		return chain[i].Priority < chain[j].Priority
+	})
+	return chain
+}
+
+// Chains returns the chains of all the hook names with at least one hook,
+// by the name of the hook in the config, e.g. onTrafficFromClient.
+func (reg *Registry) Chains() map[string][]HookInfo {
+	chains := map[string][]HookInfo{}
+	for hookName, hooks := range reg.currentHooks().hooks {
+		if len(hooks) > 0 {
+			chains[ConfigHookName(hookName)] = reg.Chain(hookName)
+		}
+	}
+	return chains
+}
+
+// ConfigHookName returns the name of the hook in the config, e.g. onTrafficFromClient
+// for HOOK_NAME_ON_TRAFFIC_FROM_CLIENT.
+func ConfigHookName(hookName v1.HookName) string {
+	words := strings.Split(strings.ToLower(strings.TrimPrefix(hookName.String(), "HOOK_NAME_")), "_")
+	for index := 1; index < len(words); index++ {
+		if words[index] != "" {
+			words[index] = strings.ToUpper(words[index][:1]) + words[index][1:]
+		}
+	}
+	return strings.Join(words, "")
+}
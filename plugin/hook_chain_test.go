@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"testing"
+
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestChain tests listing the hooks of a hook name in the order they run.
+func TestChain(t *testing.T) {
+	reg := NewPluginRegistry(t)
+	assert.Empty(t, reg.Chain(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT))
+	assert.Empty(t, reg.Chains())
+
+	reg.AddHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 30, nil)
+	reg.setHookOwner(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 30, HookInfo{
+		Name: "cache", Type: HookOwnerPlugin,
+	})
+	reg.AddHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 5, nil)
+	reg.LoadScripts([]config.Script{
+		{Name: "upper", Enabled: true, Priority: 10, Hooks: []string{"onTrafficFromClient"}, Script: "request = request"},
+	})
+
+	expected := []HookInfo{
+		{Priority: 5},
+		{Priority: 10, Name: "upper", Type: HookOwnerScript},
+		{Priority: 30, Name: "cache", Type: HookOwnerPlugin},
+	}
+	assert.Equal(t, expected, reg.Chain(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT))
+	assert.Equal(t, map[string][]HookInfo{"onTrafficFromClient": expected}, reg.Chains())
+
+	// The hook that replaces another one isn't owned by the owner of the replaced one.
+	reg.AddHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 30, nil)
+	assert.Equal(t, HookInfo{Priority: 30}, reg.Chain(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)[2])
+}
+
+// TestConfigHookName tests the names of the hooks in the config.
+func TestConfigHookName(t *testing.T) {
+	for value := range v1.HookName_name {
+		hookName := v1.HookName(value)
+		if hookName == v1.HookName_HOOK_NAME_UNSPECIFIED {
+			continue
+		}
+		parsed, ok := HookNameOf(ConfigHookName(hookName))
+		assert.True(t, ok)
+		assert.Equal(t, hookName, parsed)
+	}
+	assert.Equal(t, "onTrafficFromClient", ConfigHookName(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT))
+}
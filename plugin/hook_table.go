@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"maps"
+
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+)
+
+// hookTable is the hooks of the registry with the plugins and the scripts that
+// registered them. A published table is never modified: the changes are made to a
+// copy that replaces it at once, so that the hooks are run without a lock while the
+// plugins are loaded, removed or reloaded.
+type hookTable struct {
+	hooks  map[v1.HookName]map[sdkPlugin.Priority]sdkPlugin.Method
+	owners map[v1.HookName]map[sdkPlugin.Priority]HookInfo
+}
+
+// newHookTable returns an empty hook table.
+func newHookTable() *hookTable {
+	return &hookTable{
+		hooks:  map[v1.HookName]map[sdkPlugin.Priority]sdkPlugin.Method{},
+		owners: map[v1.HookName]map[sdkPlugin.Priority]HookInfo{},
+	}
+}
+
+// clone returns a copy of the table that can be modified.
+func (t *hookTable) clone() *hookTable {
+	table := newHookTable()
+	for hookName, hooks := range t.hooks {
+		table.hooks[hookName] = maps.Clone(hooks)
+	}
+	for hookName, owners := range t.owners {
+		table.owners[hookName] = maps.Clone(owners)
+	}
+	return table
+}
+
+// set sets the hook of the priority with its owner, or without one if the owner is nil.
+func (t *hookTable) set(
+	hookName v1.HookName, priority sdkPlugin.Priority, hookMethod sdkPlugin.Method, owner *HookInfo,
+) {
+	if t.hooks[hookName] == nil {
+		t.hooks[hookName] = map[sdkPlugin.Priority]sdkPlugin.Method{}
+	}
+	t.hooks[hookName][priority] = hookMethod
+
+	// The owner of the replaced hook, if any, doesn't own the new one.
+	delete(t.owners[hookName], priority)
+	if owner != nil {
+		if t.owners[hookName] == nil {
+			t.owners[hookName] = map[sdkPlugin.Priority]HookInfo{}
+		}
+		owner.Priority = priority
+		t.owners[hookName][priority] = *owner
+	}
+}
+
+// remove removes the hook of the priority.
+func (t *hookTable) remove(hookName v1.HookName, priority sdkPlugin.Priority) {
+	delete(t.hooks[hookName], priority)
+	delete(t.owners[hookName], priority)
+}
+
+// owner returns the plugin or the script that registered the hook of the priority.
+func (t *hookTable) owner(hookName v1.HookName, priority sdkPlugin.Priority) (HookInfo, bool) {
+	owner, ok := t.owners[hookName][priority]
+	return owner, ok
+}
+
+// currentHooks returns the current hook table, which must not be modified.
+func (reg *Registry) currentHooks() *hookTable {
+	if reg.table == nil {
+		return newHookTable()
+	}
+	if table := reg.table.Load(); table != nil {
+		return table
+	}
+	return newHookTable()
+}
+
+// updateHooks applies the update to a copy of the hook table, and then replaces the
+// table with it. The updates are serialized, so that none of them is lost.
+func (reg *Registry) updateHooks(update func(table *hookTable)) {
+	reg.tableMu.Lock()
+	defer reg.tableMu.Unlock()
+
+	table := reg.currentHooks().clone()
+	update(table)
+	reg.table.Store(table)
+}
+
+// hookOwner returns the plugin or the script that registered the hook of the priority.
+func (reg *Registry) hookOwner(hookName v1.HookName, priority sdkPlugin.Priority) (HookInfo, bool) {
+	return reg.currentHooks().owner(hookName, priority)
+}
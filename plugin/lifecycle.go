@@ -0,0 +1,126 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/config"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// The lifecycle hooks are run through the OnHook hooks, with the name of the
+// lifecycle hook passed as the "hook" argument. On shutdown, GatewayD guarantees that:
+//
+//   - Each run is bounded by the plugin timeout. A plugin that doesn't return
+//     in time is abandoned and the shutdown continues.
+//   - The results of the hooks are ignored, so the plugins can't cancel the shutdown.
+const (
+	// OnStartupCompleteHookName runs once per server, after the server is listening
+	// and before it accepts the first connection, with the startup summary of the
+	// server. It runs in the background, so it doesn't delay accepting the connections.
+	OnStartupCompleteHookName = "onStartupComplete"
+	// OnShutdownStartHookName runs once at the start of the graceful shutdown, after
+	// the OnSignal hooks and before the servers stop accepting new connections. It
+	// isn't the OnShutdown hook of the plugins (HOOK_NAME_ON_SHUTDOWN), which each
+	// server runs when it stops, after its connections are drained.
+	OnShutdownStartHookName = "onShutdownStart"
+	// OnShutdownCompleteHookName runs once after the connections are drained and the
+	// servers are stopped, and before the plugins are stopped and GatewayD exits.
+	OnShutdownCompleteHookName = "onShutdownComplete"
+	// OnConnectionResetHookName runs in the background after a pre-authenticated
+	// server session is reset with the reset query and before it's returned to the
+	// pool. It doesn't run when the server connections are recycled by reconnecting.
+	OnConnectionResetHookName = "onConnectionReset"
+	// OnFailoverHookName runs in the background when the health check finds a backend
+	// of a client config down and fails over to another backend, with the old and new
+	// backends. The clients of the old backend are moved to the new one.
+	OnFailoverHookName = "onFailover"
+
+	// OnBackendHealthChangeHookName runs in the background when the health check finds
+	// a backend of a client config down, or healthy again, with the backend, its new
+	// state and the error of the health check, e.g. for alerting on flapping backends.
+	// It runs before OnFailover.
+	OnBackendHealthChangeHookName = "onBackendHealthChange"
+	// OnRoutingTableHookName runs on every health check of a proxy, with the routing
+	// table of the proxy: its backends with their status, weight and server
+	// connections. The plugins, e.g. of an external orchestrator, can return the
+	// changes to the backends in the "changes" field of the result, which are
+	// validated like the backends in the config and applied in order:
+	// {"action": "add", "network": ..., "address": ..., "user": ..., "database": ...,
+	// "weight": ...}, {"action": "remove", "backend": "tcp://..."} and
+	// {"action": "weight", "backend": "tcp://...", "weight": ...}. The results are
+	// verified like the results of the other hooks, so the changes of a plugin that
+	// fails verification are dropped, unless the verification policy passes them down.
+	OnRoutingTableHookName = "onRoutingTable"
+	// OnPoolAcquireHookName runs in the background when a server connection is taken
+	// from the pool for a client, with the ID and the backend of the server connection
+	// and the time it took to acquire it in seconds ("wait"). It's only run if the pool
+	// events of the proxy are enabled.
+	OnPoolAcquireHookName = "onPoolAcquire"
+	// OnPoolReleaseHookName runs in the background when the client disconnects and the
+	// server connection is recycled, with the time it was held in seconds ("held"). It's
+	// only run if the pool events of the proxy are enabled.
+	OnPoolReleaseHookName = "onPoolRelease"
+	// OnLoadBalanceHookName runs when a client connects, before a server connection is
+	// taken from the pool for it, with the routing table of the proxy ("backends") and
+	// the client connection ("client"): its remote and local addresses, priority,
+	// identity and labels. The plugins return the backend of the server connection in
+	// the "backend" field of the result, e.g. "tcp://localhost:5432", which must be
+	// healthy and have available server connections, or else the built-in strategy is
+	// used. It delays the connections, so it's only run if the load balance hook of the
+	// proxy is enabled.
+	OnLoadBalanceHookName = "onLoadBalance"
+)
+
+// RunLifecycleHook runs the OnHook hooks for the given lifecycle hook and waits
+// for them to return, up to the given timeout.
+func (reg *Registry) RunLifecycleHook(
+	hook string, args map[string]interface{}, timeout time.Duration,
+) *gerr.GatewayDError {
+	_, err := reg.RunLifecycleHookWithResult(hook, args, timeout)
+	return err
+}
+
+// RunLifecycleHookWithResult runs the OnHook hooks for the given lifecycle hook like
+// RunLifecycleHook, and returns the result of the hooks, for the hooks whose results
+// are used, e.g. OnRoutingTable.
+func (reg *Registry) RunLifecycleHookWithResult(
+	hook string, args map[string]interface{}, timeout time.Duration,
+) (map[string]interface{}, *gerr.GatewayDError) {
+	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "RunLifecycleHook")
+	defer span.End()
+	span.SetAttributes(attribute.String("hook", hook))
+
+	pluginTimeoutCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	params := map[string]interface{}{"hook": hook}
+	for key, value := range args {
+		params[key] = value
+	}
+
+	// Run the hooks in the background, so that a hung plugin doesn't block the caller.
+	type hookResult struct {
+		result map[string]interface{}
+		err    *gerr.GatewayDError
+	}
+	done := make(chan hookResult, 1)
+	go func() {
+		result, err := reg.Run(pluginTimeoutCtx, params, v1.HookName_HOOK_NAME_ON_HOOK)
+		done <- hookResult{result, err}
+	}()
+
+	select {
+	case run := <-done:
+		if run.err != nil {
+			span.RecordError(run.err)
+		}
+		return run.result, run.err
+	case <-pluginTimeoutCtx.Done():
+		span.RecordError(pluginTimeoutCtx.Err())
+		return nil, gerr.ErrHookTimeout.Wrap(pluginTimeoutCtx.Err())
+	}
+}
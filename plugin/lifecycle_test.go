@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	gerr "github.com/gatewayd-io/gatewayd/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// Test_PluginRegistry_RunLifecycleHook tests running the lifecycle hooks.
+func Test_PluginRegistry_RunLifecycleHook(t *testing.T) {
+	reg := NewPluginRegistry(t)
+
+	var hook string
+	reg.AddHook(v1.HookName_HOOK_NAME_ON_HOOK, 0, func(
+		_ context.Context,
+		args *v1.Struct,
+		_ ...grpc.CallOption,
+	) (*v1.Struct, error) {
+		hook = args.AsMap()["hook"].(string)
+		return args, nil
+	})
+
+	err := reg.RunLifecycleHook(
+		OnShutdownStartHookName, map[string]interface{}{"signal": "terminated"}, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, OnShutdownStartHookName, hook)
+}
+
+// Test_PluginRegistry_RunLifecycleHook_Timeout tests that a hung plugin doesn't
+// block the lifecycle hooks longer than the timeout.
+func Test_PluginRegistry_RunLifecycleHook_Timeout(t *testing.T) {
+	reg := NewPluginRegistry(t)
+
+	unblock := make(chan struct{})
+	defer close(unblock)
+	reg.AddHook(v1.HookName_HOOK_NAME_ON_HOOK, 0, func(
+		_ context.Context,
+		args *v1.Struct,
+		_ ...grpc.CallOption,
+	) (*v1.Struct, error) {
+		<-unblock
+		return args, nil
+	})
+
+	start := time.Now()
+	err := reg.RunLifecycleHook(OnShutdownCompleteHookName, nil, 100*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, errors.Is(err, gerr.ErrHookTimeout))
+}
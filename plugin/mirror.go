@@ -0,0 +1,182 @@
+package plugin
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	mirrorV1 "github.com/gatewayd-io/gatewayd/plugin/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type MirrorDirection string
+
+const (
+	// MirrorIngress is the traffic received from the client.
+	MirrorIngress MirrorDirection = "ingress"
+	// MirrorEgress is the traffic received from the server.
+	MirrorEgress MirrorDirection = "egress"
+
+	// DefaultMirrorBufferSize is the number of messages buffered per subscriber.
+	DefaultMirrorBufferSize = 1024
+)
+
+// MirrorMessage is a single payload mirrored to the subscribed plugins.
+type MirrorMessage struct {
+	Direction  MirrorDirection
+	LocalAddr  string
+	RemoteAddr string
+	Payload    []byte
+	Timestamp  time.Time
+}
+
+// Proto returns the message as sent on the stream of the traffic mirror service of
+// the plugins, in plugin/v1.
+func (m *MirrorMessage) Proto() *mirrorV1.MirroredTraffic {
+	return &mirrorV1.MirroredTraffic{
+		Direction:  string(m.Direction),
+		LocalAddr:  m.LocalAddr,
+		RemoteAddr: m.RemoteAddr,
+		Payload:    m.Payload,
+		Timestamp:  timestamppb.New(m.Timestamp),
+	}
+}
+
+// Mirror fans out the proxied traffic to per-plugin channels, which are streamed to
+// the plugins over the traffic mirror service, so that they can consume a continuous
+// stream of traffic instead of being called once per message. Publishing never blocks the proxy: if a subscriber is too
+// slow to keep up, the message is dropped for that subscriber.
+type Mirror struct {
+	BufferSize int
+
+	subscribers map[sdkPlugin.Identifier]chan *MirrorMessage
+	mu          sync.RWMutex
+}
+
+// NewMirror creates a new traffic mirror.
+func NewMirror(bufferSize int) *Mirror {
+	if bufferSize <= 0 {
+		bufferSize = DefaultMirrorBufferSize
+	}
+	return &Mirror{
+		BufferSize:  bufferSize,
+		subscribers: map[sdkPlugin.Identifier]chan *MirrorMessage{},
+	}
+}
+
+// Subscribe returns the channel on which the mirrored traffic is sent to the plugin.
+// Subscribing twice returns the same channel.
+func (m *Mirror) Subscribe(pluginID sdkPlugin.Identifier) <-chan *MirrorMessage {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if channel, ok := m.subscribers[pluginID]; ok {
+		return channel
+	}
+
+	channel := make(chan *MirrorMessage, m.BufferSize)
+	m.subscribers[pluginID] = channel
+	return channel
+}
+
+// Unsubscribe removes the plugin from the subscribers and closes its channel.
+func (m *Mirror) Unsubscribe(pluginID sdkPlugin.Identifier) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if channel, ok := m.subscribers[pluginID]; ok {
+		delete(m.subscribers, pluginID)
+		close(channel)
+	}
+}
+
+// Subscribers returns the number of subscribed plugins.
+func (m *Mirror) Subscribers() int {
+	if m == nil {
+		return 0
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.subscribers)
+}
+
+// Publish sends the message to all the subscribers without blocking.
+func (m *Mirror) Publish(msg *MirrorMessage) {
+	if m == nil || msg == nil {
+		return
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, channel := range m.subscribers {
+		select {
+		case channel <- msg:
+			metrics.MirroredMessages.Inc()
+		default:
+			metrics.MirroredMessagesDropped.Inc()
+		}
+	}
+}
+
+// Shutdown unsubscribes all the plugins.
+func (m *Mirror) Shutdown() {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for pluginID, channel := range m.subscribers {
+		delete(m.subscribers, pluginID)
+		close(channel)
+	}
+}
+
+// startMirror subscribes the plugin to the traffic mirror, and streams the mirrored
+// traffic to its traffic mirror service until it's unsubscribed.
+func (reg *Registry) startMirror(plugin *Plugin) {
+	stream, err := plugin.Mirror(reg.ctx)
+	if err != nil {
+		reg.Logger.Error().Str("name", plugin.ID.Name).Err(err).Msg(
+			"Failed to open the traffic mirror stream of the plugin")
+		return
+	}
+
+	go reg.streamMirror(plugin.ID, stream, reg.Mirror.Subscribe(plugin.ID))
+	reg.Logger.Debug().Str("name", plugin.ID.Name).Msg("Streaming the mirrored traffic to the plugin")
+}
+
+// streamMirror sends the mirrored traffic of the channel on the stream, until the
+// channel is closed, or the stream fails, e.g. if the plugin doesn't implement the
+// traffic mirror service, in which case the plugin is unsubscribed.
+func (reg *Registry) streamMirror(
+	pluginID sdkPlugin.Identifier, stream mirrorV1.TrafficMirrorService_MirrorClient, channel <-chan *MirrorMessage,
+) {
+	for msg := range channel {
+		if err := stream.Send(msg.Proto()); err != nil {
+			reg.Logger.Error().Str("name", pluginID.Name).Err(err).Msg(
+				"Failed to stream the mirrored traffic to the plugin, unsubscribing it")
+			reg.Mirror.Unsubscribe(pluginID)
+			break
+		}
+	}
+
+	if _, err := stream.CloseAndRecv(); err != nil && !errors.Is(err, io.EOF) {
+		reg.Logger.Debug().Str("name", pluginID.Name).Err(err).Msg(
+			"Failed to close the traffic mirror stream of the plugin")
+	}
+}
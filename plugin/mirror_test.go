@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	mirrorV1 "github.com/gatewayd-io/gatewayd/plugin/v1"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Test_Mirror tests publishing mirrored traffic to the subscribers.
+func Test_Mirror(t *testing.T) {
+	mirror := NewMirror(1)
+	pluginID := sdkPlugin.Identifier{Name: "test"}
+
+	channel := mirror.Subscribe(pluginID)
+	assert.Equal(t, channel, mirror.Subscribe(pluginID))
+	assert.Equal(t, 1, mirror.Subscribers())
+
+	mirror.Publish(&MirrorMessage{Direction: MirrorIngress, Payload: []byte("test")})
+	// The buffer is full, so the message is dropped instead of blocking.
+	mirror.Publish(&MirrorMessage{Direction: MirrorEgress, Payload: []byte("dropped")})
+
+	msg := <-channel
+	assert.Equal(t, MirrorIngress, msg.Direction)
+	assert.Equal(t, []byte("test"), msg.Payload)
+	assert.Empty(t, channel)
+
+	mirror.Unsubscribe(pluginID)
+	assert.Equal(t, 0, mirror.Subscribers())
+	_, ok := <-channel
+	assert.False(t, ok)
+}
+
+// Test_Mirror_nil tests that a nil mirror is a no-op.
+func Test_Mirror_nil(t *testing.T) {
+	var mirror *Mirror
+	assert.Nil(t, mirror.Subscribe(sdkPlugin.Identifier{Name: "test"}))
+	assert.Equal(t, 0, mirror.Subscribers())
+	mirror.Publish(&MirrorMessage{})
+	mirror.Unsubscribe(sdkPlugin.Identifier{Name: "test"})
+	mirror.Shutdown()
+}
+
+// mirrorStream is the stream of the mirrored traffic to a plugin, which fails to
+// send after the given number of messages.
+type mirrorStream struct {
+	mirrorV1.TrafficMirrorService_MirrorClient
+	sent   []*mirrorV1.MirroredTraffic
+	limit  int
+	closed bool
+}
+
+func (s *mirrorStream) Send(msg *mirrorV1.MirroredTraffic) error {
+	if len(s.sent) == s.limit {
+		return errors.New("unimplemented")
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func (s *mirrorStream) CloseAndRecv() (*emptypb.Empty, error) {
+	s.closed = true
+	return &emptypb.Empty{}, nil
+}
+
+// Test_streamMirror tests streaming the mirrored traffic to the plugin, and
+// unsubscribing it if the stream fails.
+func Test_streamMirror(t *testing.T) {
+	reg := &Registry{Mirror: NewMirror(DefaultMirrorBufferSize), Logger: zerolog.Nop()}
+	pluginID := sdkPlugin.Identifier{Name: "test"}
+	now := time.Now()
+
+	stream := &mirrorStream{limit: 1}
+	done := make(chan struct{})
+	go func() {
+		reg.streamMirror(pluginID, stream, reg.Mirror.Subscribe(pluginID))
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return reg.Mirror.Subscribers() == 1 }, time.Second, time.Millisecond)
+
+	reg.Mirror.Publish(&MirrorMessage{
+		Direction: MirrorIngress, LocalAddr: "local", RemoteAddr: "remote",
+		Payload: []byte("test"), Timestamp: now,
+	})
+	reg.Mirror.Publish(&MirrorMessage{Direction: MirrorEgress, Payload: []byte("failed")})
+	<-done
+
+	// The plugin is unsubscribed once the stream fails, e.g. if it doesn't implement
+	// the traffic mirror service.
+	assert.Equal(t, 0, reg.Mirror.Subscribers())
+	assert.True(t, stream.closed)
+	require.Len(t, stream.sent, 1)
+	sent := stream.sent[0]
+	assert.Equal(t, "ingress", sent.GetDirection())
+	assert.Equal(t, "local", sent.GetLocalAddr())
+	assert.Equal(t, "remote", sent.GetRemoteAddr())
+	assert.Equal(t, []byte("test"), sent.GetPayload())
+	assert.True(t, now.Equal(sent.GetTimestamp().AsTime()))
+}
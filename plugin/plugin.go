@@ -1,11 +1,14 @@
 package plugin
 
 import (
+	"context"
 	"net"
 
 	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
 	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
 	gerr "github.com/gatewayd-io/gatewayd/errors"
+	mirrorV1 "github.com/gatewayd-io/gatewayd/plugin/v1"
+	goplugin "github.com/hashicorp/go-plugin"
 )
 
 type Plugin sdkPlugin.Plugin
@@ -14,6 +17,7 @@ type IPlugin interface {
 	Start() (net.Addr, error)
 	Stop()
 	Dispense() (v1.GatewayDPluginServiceClient, *gerr.GatewayDError)
+	Mirror(ctx context.Context) (mirrorV1.TrafficMirrorService_MirrorClient, *gerr.GatewayDError)
 	Ping() *gerr.GatewayDError
 }
 
@@ -53,6 +57,26 @@ func (p *Plugin) Dispense() (v1.GatewayDPluginServiceClient, *gerr.GatewayDError
 	return nil, gerr.ErrPluginNotReady
 }
 
+// Mirror opens the stream of the mirrored traffic to the traffic mirror service of the
+// plugin, on the gRPC connection to the plugin.
+func (p *Plugin) Mirror(ctx context.Context) (mirrorV1.TrafficMirrorService_MirrorClient, *gerr.GatewayDError) {
+	rpcClient, err := p.Client.Client()
+	if err != nil {
+		return nil, gerr.ErrFailedToGetRPCClient.Wrap(err)
+	}
+
+	grpcClient, ok := rpcClient.(*goplugin.GRPCClient)
+	if !ok {
+		return nil, gerr.ErrPluginNotReady
+	}
+
+	stream, err := mirrorV1.NewTrafficMirrorServiceClient(grpcClient.Conn).Mirror(ctx)
+	if err != nil {
+		return nil, gerr.ErrPluginNotReady.Wrap(err)
+	}
+	return stream, nil
+}
+
 // Ping pings the plugin.
 func (p *Plugin) Ping() *gerr.GatewayDError {
 	rpcClient, err := p.Client.Client()
@@ -5,6 +5,8 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
@@ -29,6 +31,7 @@ import (
 type IHook interface {
 	AddHook(hookName v1.HookName, priority sdkPlugin.Priority, hookMethod sdkPlugin.Method)
 	Hooks() map[v1.HookName]map[sdkPlugin.Priority]sdkPlugin.Method
+	Chain(hookName v1.HookName) []HookInfo
 	Run(
 		ctx context.Context,
 		args map[string]any,
@@ -47,6 +50,8 @@ type IRegistry interface {
 	Exists(name, version, remoteURL string) bool
 	ForEach(f func(sdkPlugin.Identifier, *Plugin))
 	Remove(pluginID sdkPlugin.Identifier)
+	Defaults() map[string]map[string]any
+	Schemas() map[string]map[string]any
 	Shutdown()
 	LoadPlugins(ctx context.Context, plugins []config.Plugin, startTimeout time.Duration)
 	RegisterHooks(ctx context.Context, pluginID sdkPlugin.Identifier)
@@ -59,13 +64,51 @@ type IRegistry interface {
 type Registry struct {
 	plugins     pool.IPool
 	ActRegistry *act.Registry
-	hooks       map[v1.HookName]map[sdkPlugin.Priority]sdkPlugin.Method
+	Mirror      *Mirror
+	defaults    pool.IPool
+	schemas     pool.IPool
 	ctx         context.Context //nolint:containedctx
 	DevMode     bool
 
 	Logger        zerolog.Logger
 	Compatibility config.CompatibilityPolicy
 	StartTimeout  time.Duration
+	// Verification is the policy for the hook results that fail verification,
+	// unless the hook has its own policy in HookVerification.
+	Verification     config.VerificationPolicy
+	HookVerification map[v1.HookName]config.VerificationPolicy
+	// PublicKey is the minisign public key for verifying the signatures of the
+	// plugins, which is required for all the plugins if EnforceSignatures is set.
+	PublicKey         string
+	EnforceSignatures bool
+	// GRPC are the settings of the gRPC connections to the plugins.
+	GRPC config.PluginGRPC
+
+	// scriptHooks are the priorities of the hooks of the loaded scripts.
+	scriptHooks map[v1.HookName][]sdkPlugin.Priority
+	// table is the hooks with the plugins and the scripts that registered them, and
+	// tableMu serializes its updates.
+	table   *atomic.Pointer[hookTable]
+	tableMu *sync.Mutex
+	// staged is set for the registries created by Stage, whose mirrored plugins are
+	// only streamed the mirrored traffic once they're swapped in.
+	staged   bool
+	mirrored []*Plugin
+
+	// OnUnavailable is called when a plugin becomes unavailable after a failed call,
+	// e.g. to health check it right away.
+	OnUnavailable func(name string)
+	// clients are the dispensed clients of the plugins, by name.
+	clients *sync.Map
+	// unavailable are the plugins whose hooks are skipped, by name.
+	unavailable *sync.Map
+	// CircuitBreaker skips the hooks of the plugins that keep failing or being slow,
+	// if enabled, and breakers are their circuit breakers, by the plugin and the hook.
+	CircuitBreaker config.PluginCircuitBreaker
+	breakers       *sync.Map
+	// disabledHooks are the hook types that aren't run at all, whatever registered
+	// them, as set by SetDisabledHooks.
+	disabledHooks *sync.Map
 }
 
 var _ IRegistry = (*Registry)(nil)
@@ -80,15 +123,39 @@ func NewRegistry(
 
 	return &Registry{
 		plugins:       pool.NewPool(regCtx, config.EmptyPoolCapacity),
-		hooks:         map[v1.HookName]map[sdkPlugin.Priority]sdkPlugin.Method{},
+		table:         &atomic.Pointer[hookTable]{},
+		tableMu:       &sync.Mutex{},
 		ActRegistry:   registry.ActRegistry,
+		Mirror:        NewMirror(DefaultMirrorBufferSize),
+		defaults:      pool.NewPool(regCtx, config.EmptyPoolCapacity),
+		schemas:       pool.NewPool(regCtx, config.EmptyPoolCapacity),
 		ctx:           regCtx,
 		DevMode:       registry.DevMode,
 		Logger:        registry.Logger,
 		Compatibility: registry.Compatibility,
+		Verification: config.If(
+			registry.Verification != "", registry.Verification, config.DefaultVerificationPolicy),
+		HookVerification:  registry.HookVerification,
+		PublicKey:         registry.PublicKey,
+		EnforceSignatures: registry.EnforceSignatures,
+		GRPC:              registry.GRPC,
+		OnUnavailable:     registry.OnUnavailable,
+		clients:           &sync.Map{},
+		unavailable:       &sync.Map{},
+		CircuitBreaker:    registry.CircuitBreaker,
+		breakers:          &sync.Map{},
+		disabledHooks:     &sync.Map{},
 	}
 }
 
+// verificationPolicy returns the verification policy of the hook.
+func (reg *Registry) verificationPolicy(hookName v1.HookName) config.VerificationPolicy {
+	if policy, ok := reg.HookVerification[hookName]; ok {
+		return policy
+	}
+	return reg.Verification
+}
+
 // Add adds a plugin to the registry.
 func (reg *Registry) Add(plugin *Plugin) bool {
 	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "Add")
@@ -196,12 +263,54 @@ func (reg *Registry) Remove(pluginID sdkPlugin.Identifier) {
 	defer span.End()
 
 	plugin := reg.Get(pluginID)
-	for _, hooks := range reg.hooks {
-		delete(hooks, plugin.Priority)
-	}
+	reg.updateHooks(func(table *hookTable) {
+		for hookName := range table.hooks {
+			// The hook of the priority may be a script's, which stays.
+			if owner, _ := table.owner(hookName, plugin.Priority); owner.Type == HookOwnerPlugin &&
+				owner.Name == pluginID.Name {
+				table.remove(hookName, plugin.Priority)
+			}
+		}
+	})
+	reg.Mirror.Unsubscribe(pluginID)
+	reg.forget(pluginID.Name)
+	reg.forgetBreakers(pluginID.Name)
+	reg.defaults.Remove(pluginID.Name)
+	reg.schemas.Remove(pluginID.Name)
 	reg.plugins.Remove(pluginID)
 }
 
+// Defaults returns the config defaults contributed by the plugins, keyed by plugin name.
+func (reg *Registry) Defaults() map[string]map[string]any {
+	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "Defaults")
+	defer span.End()
+
+	return pluginMaps(reg.defaults)
+}
+
+// Schemas returns the JSON schemas of the config sections of the plugins, keyed by
+// plugin name, for validating the config sections after the defaults are merged.
+func (reg *Registry) Schemas() map[string]map[string]any {
+	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "Schemas")
+	defer span.End()
+
+	return pluginMaps(reg.schemas)
+}
+
+// pluginMaps returns the maps stored in the pool, keyed by plugin name.
+func pluginMaps(stored pool.IPool) map[string]map[string]any {
+	byName := map[string]map[string]any{}
+	stored.ForEach(func(key, value interface{}) bool {
+		if name, ok := key.(string); ok {
+			if pluginMap, ok := value.(map[string]any); ok {
+				byName[name] = pluginMap
+			}
+		}
+		return true
+	})
+	return byName
+}
+
 // Shutdown shuts down all plugins in the registry.
 func (reg *Registry) Shutdown() {
 	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "Shutdown")
@@ -216,15 +325,16 @@ func (reg *Registry) Shutdown() {
 		}
 		return true
 	})
+	reg.Mirror.Shutdown()
 	goplugin.CleanupClients()
 }
 
-// Hooks returns the hooks map.
+// Hooks returns the hooks map, which must not be modified.
 func (reg *Registry) Hooks() map[v1.HookName]map[sdkPlugin.Priority]sdkPlugin.Method {
 	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "Hooks")
 	defer span.End()
 
-	return reg.hooks
+	return reg.currentHooks().hooks
 }
 
 // AddHook adds a hook with a priority to the hooks map.
@@ -232,10 +342,15 @@ func (reg *Registry) AddHook(hookName v1.HookName, priority sdkPlugin.Priority,
 	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "AddHook")
 	defer span.End()
 
-	if len(reg.hooks[hookName]) == 0 {
-		reg.hooks[hookName] = map[sdkPlugin.Priority]sdkPlugin.Method{priority: hookMethod}
-	} else {
-		if _, ok := reg.hooks[hookName][priority]; ok {
+	reg.addHook(hookName, priority, hookMethod, nil)
+}
+
+// addHook adds a hook with a priority and its owner to the hooks map at once.
+func (reg *Registry) addHook(
+	hookName v1.HookName, priority sdkPlugin.Priority, hookMethod sdkPlugin.Method, owner *HookInfo,
+) {
+	reg.updateHooks(func(table *hookTable) {
+		if _, ok := table.hooks[hookName][priority]; ok {
 			reg.Logger.Warn().Fields(
 				map[string]any{
 					"hookName": hookName.String(),
@@ -243,8 +358,8 @@ func (reg *Registry) AddHook(hookName v1.HookName, priority sdkPlugin.Priority,
 				},
 			).Msg("Hook is replaced")
 		}
-		reg.hooks[hookName][priority] = hookMethod
-	}
+		table.set(hookName, priority, hookMethod, owner)
+	})
 }
 
 // Run runs the hooks of a specific type. The result of the previous hook is passed
@@ -262,12 +377,20 @@ func (reg *Registry) Run(
 	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "Run")
 	defer span.End()
 
-	metrics.PluginHooksExecuted.Inc()
-
 	if ctx == nil {
 		return nil, gerr.ErrNilContext
 	}
 
+	// The disabled hook types are skipped before the params are built for the hooks,
+	// as if no hooks were registered for them.
+	if reg.IsHookDisabled(hookName) {
+		metrics.PluginHooksSkipped.WithLabelValues(ConfigHookName(hookName)).Inc()
+		span.AddEvent("Skipped the disabled hook")
+		return map[string]any{}, nil
+	}
+
+	metrics.PluginHooksExecuted.Inc()
+
 	// Inherit context.
 	inheritedCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -286,9 +409,13 @@ func (reg *Registry) Run(
 		return nil, gerr.ErrCastFailed.Wrap(err)
 	}
 
+	// The hooks are run from the table at the time of the call, so that they can be
+	// changed meanwhile, e.g. on reload.
+	table := reg.currentHooks()
+
 	// Sort hooks by priority.
-	priorities := make([]sdkPlugin.Priority, 0, len(reg.hooks[hookName]))
-	for priority := range reg.hooks[hookName] {
+	priorities := make([]sdkPlugin.Priority, 0, len(table.hooks[hookName]))
+	for priority := range table.hooks[hookName] {
 		priorities = append(priorities, priority)
 	}
 	sort.SliceStable(priorities, func(i, j int) bool {
@@ -298,14 +425,28 @@ func (reg *Registry) Run(
 	// Run hooks, passing the result of the previous hook to the next one.
 	returnVal := &v1.Struct{}
 	var outputs []*sdkAct.Output
+	policy := reg.verificationPolicy(hookName)
+	// The input of the first hook is the params, and of the rest, the last result.
+	input := params
 	// The signature of parameters and args MUST be the same for this to work.
-	for idx, priority := range priorities {
-		var result *v1.Struct
-		var err error
-		if idx == 0 {
-			result, err = reg.hooks[hookName][priority](inheritedCtx, params, opts...)
-		} else {
-			result, err = reg.hooks[hookName][priority](inheritedCtx, returnVal, opts...)
+	for _, priority := range priorities {
+		// The hooks of the unavailable plugins are skipped until they're back.
+		if owner, unavailable := reg.unavailableOwner(hookName, priority); unavailable {
+			span.AddEvent("Skipped the hook of the unavailable plugin " + owner)
+			continue
+		}
+
+		// The hooks of the plugins whose circuit breaker is open are skipped.
+		key, breaker := reg.breaker(hookName, priority)
+		if breaker != nil && !breaker.allow(reg.CircuitBreaker.Cooldown) {
+			span.AddEvent("Skipped the hook of the plugin with an open circuit breaker " + key.plugin)
+			continue
+		}
+
+		start := time.Now()
+		result, err := table.hooks[hookName][priority](inheritedCtx, input, opts...)
+		if breaker != nil {
+			reg.recordHookRun(key, breaker, time.Since(start), err != nil || result == nil)
 		}
 
 		if err != nil {
@@ -319,6 +460,13 @@ func (reg *Registry) Run(
 		}
 
 		if result == nil {
+			// The plugin that can't be reached is unavailable, instead of losing its hook.
+			if owner, _ := table.owner(hookName, priority); owner.Type == HookOwnerPlugin &&
+				isUnavailableError(err) {
+				reg.markUnavailable(owner.Name, err)
+				continue
+			}
+
 			// Remove the hook from the registry, log the error and execute the next hook.
 			reg.Logger.Error().Fields(
 				map[string]any{
@@ -326,10 +474,30 @@ func (reg *Registry) Run(
 					"priority": priority,
 				},
 			).Msg("Hook returned nil result, so it won't work properly")
-			delete(reg.hooks[hookName], priority)
+			reg.removeHook(table, hookName, priority)
 			continue
 		}
 
+		// Apply the verification policy to the results of the failed hooks and
+		// the results that don't have the fields of the params.
+		if err != nil || !Verify(input, result) {
+			reg.verificationFailed(hookName, priority, policy, input, result, err)
+
+			switch policy {
+			case config.PassDown:
+				// The result is passed down to the next hook as is.
+			case config.Ignore:
+				continue
+			case config.Abort:
+				returnMap := returnVal.AsMap()
+				returnMap[sdkAct.Outputs] = outputs
+				return returnMap, nil
+			case config.Remove:
+				reg.removeHook(table, hookName, priority)
+				continue
+			}
+		}
+
 		out, terminal := reg.Apply(
 			sdkAct.Hook{
 				Name:     hookName.String(),
@@ -352,6 +520,7 @@ func (reg *Registry) Run(
 		}
 
 		returnVal = result
+		input = result
 	}
 
 	returnMap := returnVal.AsMap()
@@ -359,6 +528,37 @@ func (reg *Registry) Run(
 	return returnMap, nil
 }
 
+// verificationFailed logs and counts the result of the hook that failed verification,
+// with the plugin that registered the hook and what was rejected: the error of the
+// hook, or the fields of the params that the result dropped.
+func (reg *Registry) verificationFailed(
+	hookName v1.HookName,
+	priority sdkPlugin.Priority,
+	policy config.VerificationPolicy,
+	params, result *v1.Struct,
+	err error,
+) {
+	// The hooks without an owner are registered by GatewayD itself.
+	hookOwner, _ := reg.hookOwner(hookName, priority)
+	owner := config.If(hookOwner.Name != "", hookOwner.Name, "gatewayd")
+
+	fields := map[string]any{
+		"hookName": hookName.String(),
+		"plugin":   owner,
+		"priority": priority,
+		"policy":   policy,
+	}
+	if err != nil {
+		fields["rejected"] = "the result of the failed hook"
+	} else {
+		fields["rejected"] = "the result without the fields of the params"
+		fields["missingFields"] = MissingFields(params, result)
+	}
+	reg.Logger.Warn().Err(err).Fields(fields).Msg("Hook failed verification")
+	metrics.PluginHookVerificationFailures.WithLabelValues(
+		hookName.String(), owner, string(policy)).Inc()
+}
+
 // Apply applies policies to the result.
 func (reg *Registry) Apply(hook sdkAct.Hook) ([]*sdkAct.Output, bool) {
 	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "Apply")
@@ -469,8 +669,23 @@ func (reg *Registry) LoadPlugins(
 			}
 
 			span.AddEvent("Created secure config for validating plugin checksum")
+
+			// Verify the signature of the plugin, which the checksum alone can't
+			// prove, since it's in the same config as the plugin's path.
+			if pCfg.VerifySignature || reg.EnforceSignatures {
+				signature := config.If(
+					pCfg.Signature != "", pCfg.Signature, plugin.LocalPath+SignatureExtension)
+				if err := VerifySignature(plugin.LocalPath, signature, reg.PublicKey); err != nil {
+					reg.Logger.Error().Str("name", plugin.ID.Name).Err(err).Msg(
+						"Failed to verify the signature of the plugin, refusing to load it")
+					span.RecordError(err)
+					continue
+				}
+
+				span.AddEvent("Verified plugin signature")
+			}
 		} else {
-			span.AddEvent("Skipping plugin checksum verification (dev mode)")
+			span.AddEvent("Skipping plugin checksum and signature verification (dev mode)")
 		}
 
 		// Plugin priority is determined by the order in which the plugin is listed
@@ -496,6 +711,8 @@ func (reg *Registry) LoadPlugins(
 				MaxPort:      config.DefaultMaxPort,
 				AutoMTLS:     true,
 				StartTimeout: startTimeout,
+				// The message sizes and the keepalive of the gRPC connections.
+				GRPCDialOptions: GRPCDialOptions(reg.GRPC),
 			},
 		)
 
@@ -512,7 +729,7 @@ func (reg *Registry) LoadPlugins(
 		span.AddEvent("Started plugin")
 
 		// Load metadata from the plugin.
-		pluginV1, err := plugin.Dispense()
+		pluginV1, err := reg.dispense(plugin)
 		if err != nil {
 			reg.Logger.Debug().Str("name", plugin.ID.Name).Err(err).Msg(
 				"Failed to dispense plugin")
@@ -603,6 +820,24 @@ func (reg *Registry) LoadPlugins(
 				"Plugin doesn't attach to any hooks")
 		}
 
+		// The unknown hooks never run, which usually means that the plugin is built
+		// for another version of GatewayD, so the plugin may not work as expected.
+		if unknown := unknownHooks(plugin.Hooks); len(unknown) > 0 {
+			reg.Logger.Warn().Fields(
+				map[string]any{
+					"name":  plugin.ID.Name,
+					"hooks": unknown,
+				},
+			).Msg("The plugin attaches to unknown hooks, which never run, " +
+				"so it may be built for another version of GatewayD")
+			if reg.Compatibility == config.Strict {
+				reg.Logger.Error().Str("name", plugin.ID.Name).Msg(
+					"Registry is in strict compatibility mode, so the plugin won't be loaded")
+				plugin.Stop()
+				continue
+			}
+		}
+
 		// Retrieve plugin config.
 		plugin.Config = make(map[string]string)
 		if metadata.GetFields()["config"] != nil && metadata.GetFields()["config"].GetStructValue() != nil {
@@ -619,6 +854,30 @@ func (reg *Registry) LoadPlugins(
 				"Plugin doesn't have any config")
 		}
 
+		// Retrieve plugin config defaults, which are merged into the global config
+		// under the pluginConfigs.<name> key before the OnConfigLoaded hooks are run.
+		if metadata.GetFields()["defaults"] != nil && metadata.GetFields()["defaults"].GetStructValue() != nil {
+			if err := reg.defaults.Put(
+				plugin.ID.Name, metadata.GetFields()["defaults"].GetStructValue().AsMap()); err != nil {
+				reg.Logger.Debug().Err(err).Msg("Failed to store plugin config defaults")
+			}
+		} else {
+			reg.Logger.Debug().Str("name", plugin.ID.Name).Msg(
+				"Plugin doesn't have any config defaults")
+		}
+
+		// Retrieve the JSON schema of the plugin's config section, which validates it
+		// once the defaults are merged.
+		if metadata.GetFields()["schema"] != nil && metadata.GetFields()["schema"].GetStructValue() != nil {
+			if err := reg.schemas.Put(
+				plugin.ID.Name, metadata.GetFields()["schema"].GetStructValue().AsMap()); err != nil {
+				reg.Logger.Debug().Err(err).Msg("Failed to store plugin config schema")
+			}
+		} else {
+			reg.Logger.Debug().Str("name", plugin.ID.Name).Msg(
+				"Plugin doesn't have any config schema")
+		}
+
 		span.AddEvent("Decoded plugin metadata")
 
 		reg.Logger.Trace().Msgf("Plugin metadata: %+v", plugin)
@@ -633,6 +892,13 @@ func (reg *Registry) LoadPlugins(
 
 		span.AddEvent("Registered plugin hooks")
 
+		if pCfg.Mirror && reg.staged {
+			reg.mirrored = append(reg.mirrored, plugin)
+		} else if pCfg.Mirror {
+			reg.startMirror(plugin)
+			span.AddEvent("Started streaming the mirrored traffic")
+		}
+
 		metrics.PluginsLoaded.Inc()
 		reg.Logger.Info().Str("name", plugin.ID.Name).Msg("Plugin is ready")
 	}
@@ -648,10 +914,11 @@ func (reg *Registry) RegisterHooks(ctx context.Context, pluginID sdkPlugin.Ident
 		"Registering hooks for plugin")
 	var pluginV1 v1.GatewayDPluginServiceClient
 	var err *gerr.GatewayDError
-	if pluginV1, err = pluginImpl.Dispense(); err != nil {
+	if pluginV1, err = reg.dispense(pluginImpl); err != nil {
 		reg.Logger.Debug().Str("name", pluginImpl.ID.Name).Err(err).Msg(
 			"Failed to dispense plugin")
 		span.RecordError(err)
+		reg.markUnavailable(pluginImpl.ID.Name, err)
 		return
 	}
 
@@ -717,6 +984,21 @@ func (reg *Registry) RegisterHooks(ctx context.Context, pluginID sdkPlugin.Ident
 		case v1.HookName_HOOK_NAME_ON_HOOK:
 			hookMethod = pluginV1.OnHook
 		default:
+			reg.Logger.Warn().Fields(map[string]any{
+				"hook": hookName.String(),
+				"name": pluginImpl.ID.Name,
+			}).Msg("Unknown plugin hook, so it won't be registered")
+			continue
+		}
+
+		// The script that took the priority, e.g. while the plugin was restarted, keeps it.
+		if owner, _ := reg.hookOwner(hookName, pluginImpl.Priority); owner.Type == HookOwnerScript {
+			reg.Logger.Error().Fields(map[string]any{
+				"hook":     hookName.String(),
+				"priority": pluginImpl.Priority,
+				"name":     pluginImpl.ID.Name,
+				"script":   owner.Name,
+			}).Msg("Plugin hook isn't registered, since its priority is taken by a script")
 			continue
 		}
 
@@ -726,6 +1008,20 @@ func (reg *Registry) RegisterHooks(ctx context.Context, pluginID sdkPlugin.Ident
 			"name":     pluginImpl.ID.Name,
 		}).Msg("Registering hook")
 		metrics.PluginHooksRegistered.Inc()
-		reg.AddHook(hookName, pluginImpl.Priority, hookMethod)
+		reg.addHook(hookName, pluginImpl.Priority, hookMethod, &HookInfo{
+			Name: pluginImpl.ID.Name,
+			Type: HookOwnerPlugin,
+		})
 	}
 }
+
+// removeHook removes the hook of the priority that was run from the table, unless
+// it was replaced by another owner's hook meanwhile, e.g. on reload.
+func (reg *Registry) removeHook(ran *hookTable, hookName v1.HookName, priority sdkPlugin.Priority) {
+	ranOwner, _ := ran.owner(hookName, priority)
+	reg.updateHooks(func(table *hookTable) {
+		if owner, _ := table.owner(hookName, priority); owner == ranOwner {
+			table.remove(hookName, priority)
+		}
+	})
+}
@@ -5,11 +5,14 @@ import (
 	"testing"
 	"time"
 
+	sdkAct "github.com/gatewayd-io/gatewayd-plugin-sdk/act"
 	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
 	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
 	"github.com/gatewayd-io/gatewayd/act"
 	"github.com/gatewayd-io/gatewayd/config"
 	"github.com/gatewayd-io/gatewayd/logging"
+	"github.com/gatewayd-io/gatewayd/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
@@ -51,7 +54,7 @@ func TestPluginRegistry(t *testing.T) {
 	reg := NewPluginRegistry(t)
 	assert.NotNil(t, reg)
 	assert.NotNil(t, reg.plugins)
-	assert.NotNil(t, reg.hooks)
+	assert.NotNil(t, reg.Hooks())
 	assert.Empty(t, reg.List())
 
 	ident := sdkPlugin.Identifier{
@@ -184,3 +187,62 @@ func BenchmarkHookRun(b *testing.B) {
 		)
 	}
 }
+
+// Test_PluginRegistry_Run_Verification tests the verification policies of the hooks.
+func Test_PluginRegistry_Run_Verification(t *testing.T) {
+	// The first hook drops the fields of the params, the second one adds a field.
+	dropFields := func(
+		_ context.Context,
+		_ *v1.Struct,
+		_ ...grpc.CallOption,
+	) (*v1.Struct, error) {
+		return v1.NewStruct(map[string]interface{}{"dropped": true})
+	}
+	addField := func(
+		_ context.Context,
+		args *v1.Struct,
+		_ ...grpc.CallOption,
+	) (*v1.Struct, error) {
+		fields := args.AsMap()
+		fields["second"] = true
+		return v1.NewStruct(fields)
+	}
+
+	tests := []struct {
+		policy   config.VerificationPolicy
+		expected map[string]interface{}
+		removed  bool
+	}{
+		{config.PassDown, map[string]interface{}{"dropped": true, "second": true}, false},
+		{config.Ignore, map[string]interface{}{"request": "test", "second": true}, false},
+		{config.Abort, map[string]interface{}{}, false},
+		{config.Remove, map[string]interface{}{"request": "test", "second": true}, true},
+	}
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			reg := NewPluginRegistry(t)
+			// The hook policy takes precedence over the global policy.
+			reg.Verification = config.PassDown
+			reg.HookVerification = map[v1.HookName]config.VerificationPolicy{
+				v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT: test.policy,
+			}
+			reg.AddHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 0, dropFields)
+			reg.AddHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 1, addField)
+			failures := metrics.PluginHookVerificationFailures.WithLabelValues(
+				v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT.String(), "gatewayd", string(test.policy))
+			failed := testutil.ToFloat64(failures)
+
+			result, err := reg.Run(
+				context.Background(),
+				map[string]interface{}{"request": "test"},
+				v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)
+			assert.Nil(t, err)
+			// The failed verification is counted by the hook, the plugin and the policy.
+			assert.Equal(t, failed+1, testutil.ToFloat64(failures))
+			delete(result, sdkAct.Outputs)
+			assert.Equal(t, test.expected, result)
+			_, exists := reg.Hooks()[v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT][0]
+			assert.Equal(t, !test.removed, exists)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	"github.com/gatewayd-io/gatewayd/config"
+	"go.opentelemetry.io/otel"
+)
+
+// Stage loads the plugins and the scripts of the new config into a staged registry
+// with the settings of the registry, without touching the running ones, so that
+// their hooks keep running while the new plugins start. The staged registry is
+// either swapped in with Swap, or discarded with Discard, e.g. if the config
+// sections of the new plugins are invalid.
+func (reg *Registry) Stage(
+	ctx context.Context, plugins []config.Plugin, scripts []config.Script, startTimeout time.Duration,
+) *Registry {
+	ctx, span := otel.Tracer(config.TracerName).Start(ctx, "Stage plugins")
+	defer span.End()
+
+	staged := NewRegistry(ctx, Registry{
+		ActRegistry:       reg.ActRegistry,
+		DevMode:           reg.DevMode,
+		Logger:            reg.Logger,
+		Compatibility:     reg.Compatibility,
+		Verification:      reg.Verification,
+		HookVerification:  reg.HookVerification,
+		PublicKey:         reg.PublicKey,
+		EnforceSignatures: reg.EnforceSignatures,
+		GRPC:              reg.GRPC,
+		OnUnavailable:     reg.OnUnavailable,
+		CircuitBreaker:    reg.CircuitBreaker,
+	})
+	// The mirrored traffic is streamed to the staged plugins once they're swapped in.
+	staged.staged = true
+	staged.disabledHooks = reg.disabledHooks
+
+	staged.LoadPlugins(ctx, plugins, startTimeout)
+	staged.LoadScripts(scripts)
+	return staged
+}
+
+// Swap replaces the plugins, the scripts and their hooks with the staged ones. The
+// hooks are replaced at once, so that every run sees either the old hooks or the
+// new ones, and the old plugins are stopped only afterwards, so that the hooks that
+// are still running on them can finish.
+func (reg *Registry) Swap(staged *Registry) {
+	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "Swap plugins")
+	defer span.End()
+
+	old := map[sdkPlugin.Identifier]*Plugin{}
+	reg.ForEach(func(pluginID sdkPlugin.Identifier, plugin *Plugin) {
+		old[pluginID] = plugin
+		reg.Mirror.Unsubscribe(pluginID)
+	})
+
+	reg.tableMu.Lock()
+	reg.table.Store(staged.currentHooks())
+	reg.scriptHooks = staged.scriptHooks
+	reg.tableMu.Unlock()
+
+	for pluginID := range old {
+		reg.forget(pluginID.Name)
+		reg.forgetBreakers(pluginID.Name)
+		reg.defaults.Remove(pluginID.Name)
+		reg.schemas.Remove(pluginID.Name)
+		if staged.Get(pluginID) == nil {
+			reg.plugins.Remove(pluginID)
+		}
+	}
+
+	staged.ForEach(func(pluginID sdkPlugin.Identifier, plugin *Plugin) {
+		if err := reg.plugins.Put(pluginID, plugin); err != nil {
+			reg.Logger.Error().Err(err).Str("name", pluginID.Name).Msg(
+				"Failed to add the reloaded plugin to registry")
+			span.RecordError(err)
+		}
+	})
+	staged.clients.Range(func(name, client any) bool {
+		reg.clients.Store(name, client)
+		return true
+	})
+	staged.unavailable.Range(func(name, state any) bool {
+		reg.unavailable.Store(name, state)
+		return true
+	})
+	for name, defaults := range staged.Defaults() {
+		if err := reg.defaults.Put(name, defaults); err != nil {
+			reg.Logger.Debug().Err(err).Msg("Failed to store plugin config defaults")
+		}
+	}
+	for name, schema := range staged.Schemas() {
+		if err := reg.schemas.Put(name, schema); err != nil {
+			reg.Logger.Debug().Err(err).Msg("Failed to store plugin config schema")
+		}
+	}
+
+	for _, plugin := range staged.mirrored {
+		reg.startMirror(plugin)
+	}
+
+	for _, plugin := range old {
+		plugin.Stop()
+	}
+}
+
+// Discard stops the staged plugins, which were never swapped in.
+func (reg *Registry) Discard() {
+	_, span := otel.Tracer(config.TracerName).Start(reg.ctx, "Discard plugins")
+	defer span.End()
+
+	reg.ForEach(func(pluginID sdkPlugin.Identifier, plugin *Plugin) {
+		plugin.Stop()
+		reg.forget(pluginID.Name)
+	})
+}
@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// TestRegistrySwap tests that the staged hooks replace the running ones at once,
+// while the hooks keep running.
+func TestRegistrySwap(t *testing.T) {
+	hook := func(value string) func(context.Context, *v1.Struct, ...grpc.CallOption) (*v1.Struct, error) {
+		return func(_ context.Context, args *v1.Struct, _ ...grpc.CallOption) (*v1.Struct, error) {
+			args.Fields["hook"] = v1.NewStringValue(value)
+			return args, nil
+		}
+	}
+
+	reg := NewPluginRegistry(t)
+	reg.AddHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 0, hook("old"))
+	reg.AddHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_SERVER, 0, hook("old"))
+
+	staged := NewPluginRegistry(t)
+	staged.AddHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 0, hook("new"))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			result, err := reg.Run(
+				context.Background(), map[string]any{}, v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)
+			// Every run sees either the old hooks or the new ones, never none.
+			assert.Nil(t, err)
+			assert.Contains(t, []any{"old", "new"}, result["hook"])
+		}
+	}()
+	reg.Swap(staged)
+	wg.Wait()
+
+	result, err := reg.Run(
+		context.Background(), map[string]any{}, v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)
+	require.Nil(t, err)
+	assert.Equal(t, "new", result["hook"])
+	assert.Empty(t, reg.Hooks()[v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_SERVER])
+}
@@ -0,0 +1,297 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sdkAct "github.com/gatewayd-io/gatewayd-plugin-sdk/act"
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/rs/zerolog"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+	"google.golang.org/grpc"
+)
+
+const (
+	// The sizes of the call stack and the registry (the value stack) of the scripts,
+	// which bound the memory and the recursion of a script.
+	scriptCallStackSize   = 120
+	scriptRegistrySize    = 1024
+	scriptRegistryMaxSize = 64 * 1024
+	// The max size of the strings built by string.rep and of the request and response
+	// set by the scripts, which bounds the memory of a script along with the timeout.
+	scriptMaxStringSize = config.DefaultMaxHookPayloadSize
+)
+
+var errScriptStringTooLarge = errors.New("the string is too large")
+
+// unsafeScriptGlobals are the functions of the Lua base library that are removed from
+// the sandbox, since they load code from the file system or escape the environment.
+var unsafeScriptGlobals = []string{
+	"dofile", "loadfile", "load", "loadstring", "module", "require", "getfenv", "setfenv",
+}
+
+// ScriptHook runs a Lua script in-process as a hook, for the simple transformations
+// that don't need a plugin. The script sees the fields of the hook's params as globals,
+// e.g. request, response, client and server, and can replace the request or response
+// by assigning to them, or terminate the request by calling terminate with an optional
+// response. The script runs in a sandbox without the file system, the OS or the loading
+// of other code, and is stopped when the timeout is reached. Each run has a new state,
+// so nothing a run assigns, to the globals or the libraries, is seen by the next one.
+type ScriptHook struct {
+	Name    string
+	Timeout time.Duration
+	Logger  zerolog.Logger
+
+	proto *lua.FunctionProto
+}
+
+// NewScriptHook compiles the script of the config into a hook.
+func NewScriptHook(script config.Script, logger zerolog.Logger) (*ScriptHook, error) {
+	chunk, err := parse.Parse(strings.NewReader(script.Script), script.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the script: %w", err)
+	}
+	proto, err := lua.Compile(chunk, script.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile the script: %w", err)
+	}
+
+	return &ScriptHook{
+		Name:    script.Name,
+		Timeout: config.If(script.Timeout > 0, script.Timeout, config.DefaultScriptTimeout),
+		Logger:  logger,
+		proto:   proto,
+	}, nil
+}
+
+// newState creates a sandboxed Lua state with the base, table, string and math libraries.
+func (h *ScriptHook) newState() *lua.LState {
+	state := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   scriptCallStackSize,
+		RegistrySize:    scriptRegistrySize,
+		RegistryMaxSize: scriptRegistryMaxSize,
+	})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		state.Push(state.NewFunction(lib.open))
+		state.Push(lua.LString(lib.name))
+		state.Call(1, 0)
+	}
+	for _, name := range unsafeScriptGlobals {
+		state.SetGlobal(name, lua.LNil)
+	}
+
+	// string.rep builds large strings from small ones, so its result is capped.
+	if stringLib, ok := state.GetGlobal(lua.StringLibName).(*lua.LTable); ok {
+		stringLib.RawSetString("rep", state.NewFunction(func(state *lua.LState) int {
+			str, count := state.CheckString(1), state.CheckInt(2)
+			if count > 0 && len(str)*count > scriptMaxStringSize {
+				state.RaiseError("string.rep: %s", errScriptStringTooLarge)
+			}
+			state.Push(lua.LString(strings.Repeat(str, max(count, 0))))
+			return 1
+		}))
+	}
+
+	// The output of print goes to the logs.
+	state.SetGlobal("print", state.NewFunction(func(state *lua.LState) int {
+		values := make([]string, 0, state.GetTop())
+		for index := 1; index <= state.GetTop(); index++ {
+			values = append(values, state.ToStringMeta(state.Get(index)).String())
+		}
+		h.Logger.Debug().Str("script", h.Name).Msg(strings.Join(values, "\t"))
+		return 0
+	}))
+
+	return state
+}
+
+// Run runs the script with the params and returns the params with the fields modified
+// by the script. On failure, the params are returned as they are with the error, and
+// the verification policy of the hook decides what to do with them.
+func (h *ScriptHook) Run(
+	ctx context.Context, params *v1.Struct, _ ...grpc.CallOption,
+) (*v1.Struct, error) {
+	state := h.newState()
+	defer state.Close()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+	state.SetContext(timeoutCtx)
+
+	args := params.AsMap()
+	env := state.NewTable()
+	for key, value := range args {
+		env.RawSetString(key, toLuaValue(state, value))
+	}
+	terminated := false
+	env.RawSetString("terminate", state.NewFunction(func(state *lua.LState) int {
+		terminated = true
+		if response, ok := state.Get(1).(lua.LString); ok {
+			env.RawSetString("response", response)
+		}
+		return 0
+	}))
+	meta := state.NewTable()
+	meta.RawSetString("__index", state.G.Global)
+	state.SetMetatable(env, meta)
+
+	function := state.NewFunctionFromProto(h.proto)
+	function.Env = env
+	state.Push(function)
+	if err := state.PCall(0, 0, nil); err != nil {
+		return params, fmt.Errorf("failed to run the script %s: %w", h.Name, err)
+	}
+
+	result := args
+	for _, field := range []string{"request", "response"} {
+		if value, ok := env.RawGetString(field).(lua.LString); ok {
+			if len(value) > scriptMaxStringSize {
+				return params, fmt.Errorf(
+					"failed to run the script %s: the %s: %w", h.Name, field, errScriptStringTooLarge)
+			}
+			result[field] = []byte(value)
+		}
+	}
+	if terminated {
+		result[sdkAct.Signals] = []any{sdkAct.Terminate().ToMap()}
+	}
+
+	resultStruct, err := v1.NewStruct(result)
+	if err != nil {
+		return params, fmt.Errorf("failed to convert the result of the script %s: %w", h.Name, err)
+	}
+	return resultStruct, nil
+}
+
+// toLuaValue converts the value of a param to a Lua value.
+func toLuaValue(state *lua.LState, value any) lua.LValue {
+	switch typed := value.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(typed)
+	case string:
+		return lua.LString(typed)
+	case []byte:
+		return lua.LString(typed)
+	case float64:
+		return lua.LNumber(typed)
+	case int64:
+		return lua.LNumber(typed)
+	case map[string]any:
+		table := state.NewTable()
+		for key, item := range typed {
+			table.RawSetString(key, toLuaValue(state, item))
+		}
+		return table
+	case []any:
+		table := state.NewTable()
+		for _, item := range typed {
+			table.Append(toLuaValue(state, item))
+		}
+		return table
+	default:
+		return lua.LString(fmt.Sprint(typed))
+	}
+}
+
+// LoadScripts compiles the enabled scripts and registers them as hooks with their
+// priority, replacing the scripts loaded before. The scripts that fail to compile,
+// and the ones whose priority is taken by a plugin or another script on any of their
+// hooks, are logged and skipped.
+func (reg *Registry) LoadScripts(scripts []config.Script) {
+	reg.updateHooks(func(table *hookTable) {
+		for hookName, priorities := range reg.scriptHooks {
+			for _, priority := range priorities {
+				// The hook of the priority may be a plugin's, which stays.
+				if owner, _ := table.owner(hookName, priority); owner.Type == HookOwnerScript {
+					table.remove(hookName, priority)
+				}
+			}
+		}
+	})
+	reg.scriptHooks = map[v1.HookName][]sdkPlugin.Priority{}
+
+	for _, script := range scripts {
+		if !script.Enabled {
+			continue
+		}
+
+		hook, err := NewScriptHook(script, reg.Logger)
+		if err != nil {
+			reg.Logger.Error().Err(err).Str("script", script.Name).Msg("Failed to load the script")
+			continue
+		}
+
+		hookNames := []v1.HookName{}
+		for _, name := range script.Hooks {
+			if hookName, ok := HookNameOf(name); ok {
+				hookNames = append(hookNames, hookName)
+			} else {
+				reg.Logger.Warn().Str("script", script.Name).Str("hook", name).Msg(
+					"Unknown hook name of the script, skipping")
+			}
+		}
+		if len(hookNames) == 0 {
+			reg.Logger.Error().Str("script", script.Name).Msg(
+				"Failed to load the script, since it has no valid hooks")
+			continue
+		}
+
+		if owner, taken := reg.takenPriority(hookNames, sdkPlugin.Priority(script.Priority)); taken {
+			reg.Logger.Error().Fields(
+				map[string]any{
+					"script":   script.Name,
+					"priority": script.Priority,
+					"owner":    owner.Name,
+				},
+			).Msg("Failed to load the script, since its priority is taken by the hook of another owner")
+			continue
+		}
+
+		for _, hookName := range hookNames {
+			reg.addHook(hookName, sdkPlugin.Priority(script.Priority), hook.Run, &HookInfo{
+				Name: script.Name,
+				Type: HookOwnerScript,
+			})
+			reg.scriptHooks[hookName] = append(
+				reg.scriptHooks[hookName], sdkPlugin.Priority(script.Priority))
+		}
+
+		reg.Logger.Info().Fields(
+			map[string]any{
+				"script":   script.Name,
+				"hooks":    script.Hooks,
+				"priority": script.Priority,
+			},
+		).Msg("Loaded the script")
+	}
+}
+
+// takenPriority returns the owner of the hook of the priority on any of the hook
+// names, if there's one, e.g. a plugin, whose hook would be replaced.
+func (reg *Registry) takenPriority(hookNames []v1.HookName, priority sdkPlugin.Priority) (HookInfo, bool) {
+	table := reg.currentHooks()
+	for _, hookName := range hookNames {
+		if _, ok := table.hooks[hookName][priority]; ok {
+			owner, _ := table.owner(hookName, priority)
+			return owner, true
+		}
+	}
+	return HookInfo{}, false
+}
@@ -0,0 +1,146 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkAct "github.com/gatewayd-io/gatewayd-plugin-sdk/act"
+	sdkPlugin "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin"
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
+	"github.com/gatewayd-io/gatewayd/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// runScript runs the script as a hook with the request.
+func runScript(t *testing.T, script string, timeout time.Duration) (*v1.Struct, error) {
+	t.Helper()
+
+	hook, err := NewScriptHook(
+		config.Script{Name: "test", Script: script, Timeout: timeout}, zerolog.Nop())
+	require.NoError(t, err)
+
+	params, err := v1.NewStruct(map[string]interface{}{
+		"request": []byte("select 1"),
+		"client":  map[string]interface{}{"remote": "127.0.0.1:54321"},
+	})
+	require.NoError(t, err)
+
+	return hook.Run(context.Background(), params)
+}
+
+// TestScriptHook tests modifying the request with a script.
+func TestScriptHook(t *testing.T) {
+	result, err := runScript(t, `request = string.upper(request) .. " -- " .. client.remote`, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("SELECT 1 -- 127.0.0.1:54321"), result.AsMap()["request"])
+	assert.NotContains(t, result.AsMap(), sdkAct.Signals)
+}
+
+// TestScriptHookTerminate tests terminating the request with a script.
+func TestScriptHookTerminate(t *testing.T) {
+	result, err := runScript(t, `terminate("denied")`, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("denied"), result.AsMap()["response"])
+	assert.Contains(t, result.AsMap(), sdkAct.Signals)
+}
+
+// TestScriptHookErrors tests that the params are returned as they are when the script fails.
+func TestScriptHookErrors(t *testing.T) {
+	_, err := NewScriptHook(config.Script{Name: "test", Script: "request = "}, zerolog.Nop())
+	assert.Error(t, err)
+
+	// The script is stopped at the timeout.
+	result, err := runScript(t, `request = "x" while true do end`, 10*time.Millisecond)
+	assert.Error(t, err)
+	assert.Equal(t, []byte("select 1"), result.AsMap()["request"])
+
+	// The sandbox has no access to the file system or the OS.
+	for _, script := range []string{`dofile("/etc/passwd")`, `os.exit(1)`, `io.write("x")`} {
+		_, err = runScript(t, script, 0)
+		assert.Error(t, err, script)
+	}
+}
+
+// TestScriptHookIsolation tests that nothing a run of the script assigns, to the
+// globals or the libraries, is seen by the next run.
+func TestScriptHookIsolation(t *testing.T) {
+	hook, err := NewScriptHook(config.Script{Name: "test", Script: `
+		if _G.seen or string.seen or counter then request = "leaked" end
+		_G.seen = true
+		string.seen = true
+		counter = 1
+		string.upper = string.lower
+	`}, zerolog.Nop())
+	require.NoError(t, err)
+
+	params, err := v1.NewStruct(map[string]interface{}{"request": []byte("select 1")})
+	require.NoError(t, err)
+	for range 3 {
+		result, err := hook.Run(context.Background(), params)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("select 1"), result.AsMap()["request"])
+	}
+
+	// The strings built by the scripts are capped.
+	_, err = runScript(t, `request = string.rep("x", 1024 * 1024 * 1024)`, 0)
+	assert.ErrorContains(t, err, errScriptStringTooLarge.Error())
+}
+
+// TestLoadScripts tests registering the scripts as hooks and replacing them on reload.
+func TestLoadScripts(t *testing.T) {
+	reg := NewPluginRegistry(t)
+	reg.LoadScripts([]config.Script{
+		{Name: "upper", Enabled: true, Hooks: []string{"onTrafficFromClient"}, Script: "request = string.upper(request)"},
+		{Name: "disabled", Enabled: false, Hooks: []string{"onTrafficFromServer"}, Script: "response = ''"},
+		{Name: "invalid", Enabled: true, Hooks: []string{"onTrafficFromServer"}, Script: "response = "},
+		{Name: "unknown", Enabled: true, Hooks: []string{"onUnknown"}, Script: "response = ''"},
+	})
+	assert.Len(t, reg.Hooks()[v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT], 1)
+	assert.Empty(t, reg.Hooks()[v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_SERVER])
+
+	params, err := v1.NewStruct(map[string]interface{}{"request": []byte("select 1")})
+	require.NoError(t, err)
+	result, gErr := reg.Run(
+		context.Background(), params.AsMap(), v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT)
+	require.Nil(t, gErr)
+	assert.Equal(t, []byte("SELECT 1"), result["request"])
+
+	reg.LoadScripts(nil)
+	assert.Empty(t, reg.Hooks()[v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT])
+}
+
+// TestLoadScriptsPriority tests that the scripts and the plugins don't replace or
+// remove each other's hooks of the same priority.
+func TestLoadScriptsPriority(t *testing.T) {
+	reg := NewPluginRegistry(t)
+	pluginID := sdkPlugin.Identifier{Name: "cache"}
+	reg.Add(&Plugin{ID: pluginID, Priority: 0})
+	reg.addHook(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 0, func(
+		_ context.Context, args *v1.Struct, _ ...grpc.CallOption,
+	) (*v1.Struct, error) {
+		return args, nil
+	}, &HookInfo{Name: pluginID.Name, Type: HookOwnerPlugin})
+
+	reg.LoadScripts([]config.Script{
+		{Name: "taken", Enabled: true, Hooks: []string{"onTrafficFromClient"}, Script: "request = ''"},
+		{Name: "server", Enabled: true, Hooks: []string{"onTrafficFromServer"}, Script: "response = ''"},
+	})
+	owner, _ := reg.hookOwner(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, 0)
+	assert.Equal(t, pluginID.Name, owner.Name)
+	owner, _ = reg.hookOwner(v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_SERVER, 0)
+	assert.Equal(t, "server", owner.Name)
+
+	// Reloading the scripts keeps the hook of the plugin, and removing the plugin
+	// keeps the hook of the script.
+	reg.LoadScripts([]config.Script{
+		{Name: "server", Enabled: true, Hooks: []string{"onTrafficFromServer"}, Script: "response = ''"},
+	})
+	assert.Len(t, reg.Hooks()[v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT], 1)
+	reg.Remove(pluginID)
+	assert.Empty(t, reg.Hooks()[v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT])
+	assert.Len(t, reg.Hooks()[v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_SERVER], 1)
+}
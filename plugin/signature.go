@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// SignatureExtension is the extension of the detached minisign signature file,
+	// which is next to the plugin binary, unless its path is set in the config.
+	SignatureExtension = ".minisig"
+
+	trustedCommentPrefix = "trusted comment: "
+)
+
+var (
+	// The signature algorithms of minisign: Ed25519 over the file, and Ed25519 over
+	// the BLAKE2b-512 hash of the file, which the recent versions use by default.
+	minisignAlgorithm       = []byte("Ed")
+	minisignHashedAlgorithm = []byte("ED")
+
+	errInvalidPublicKey = errors.New("invalid minisign public key")
+	errInvalidSignature = errors.New("invalid minisign signature")
+)
+
+// minisignPublicKey is a minisign public key: the key ID and the
+// Ed25519 public key.
+type minisignPublicKey struct {
+	keyID     []byte
+	publicKey ed25519.PublicKey
+}
+
+// parsePublicKey parses the minisign public key, either the base64-encoded key,
+// e.g. the output of minisign -R, or the contents of the public key file.
+func parsePublicKey(key string) (*minisignPublicKey, error) {
+	lines := strings.Split(strings.TrimSpace(key), "\n")
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidPublicKey, err)
+	}
+	if len(decoded) != len(minisignAlgorithm)+8+ed25519.PublicKeySize ||
+		!bytes.Equal(decoded[:2], minisignAlgorithm) {
+		return nil, errInvalidPublicKey
+	}
+
+	return &minisignPublicKey{
+		keyID:     decoded[2:10],
+		publicKey: ed25519.PublicKey(decoded[10:]),
+	}, nil
+}
+
+// VerifySignature verifies the detached minisign signature of the file against the
+// public key. Both the signature of the file and the global signature, which covers
+// the trusted comment of the signature, must be valid.
+func VerifySignature(path, signaturePath, publicKey string) error {
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	contents, err := os.ReadFile(signaturePath)
+	if err != nil {
+		return fmt.Errorf("failed to read the signature file: %w", err)
+	}
+	// The signature file has an untrusted comment, the signature, a trusted comment
+	// and the global signature, each on its own line.
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], trustedCommentPrefix) {
+		return fmt.Errorf("%w: malformed signature file", errInvalidSignature)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(signature) != len(minisignAlgorithm)+8+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed signature", errInvalidSignature)
+	}
+	globalSignature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSignature) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed global signature", errInvalidSignature)
+	}
+
+	if !bytes.Equal(signature[2:10], key.keyID) {
+		return fmt.Errorf("%w: signed by another key", errInvalidSignature)
+	}
+
+	message, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the signed file: %w", err)
+	}
+	switch {
+	case bytes.Equal(signature[:2], minisignHashedAlgorithm):
+		hash := blake2b.Sum512(message)
+		message = hash[:]
+	case !bytes.Equal(signature[:2], minisignAlgorithm):
+		return fmt.Errorf("%w: unsupported algorithm", errInvalidSignature)
+	}
+
+	if !ed25519.Verify(key.publicKey, message, signature[10:]) {
+		return fmt.Errorf("%w: the file doesn't match the signature", errInvalidSignature)
+	}
+
+	trustedComment := strings.TrimPrefix(strings.TrimRight(lines[2], "\r"), trustedCommentPrefix)
+	if !ed25519.Verify(
+		key.publicKey, append(bytes.Clone(signature[10:]), trustedComment...), globalSignature) {
+		return fmt.Errorf("%w: the trusted comment doesn't match the signature", errInvalidSignature)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+// signMinisign returns the minisign public key and the detached signature of the message.
+func signMinisign(t *testing.T, message []byte, algorithm string) (string, string) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	if algorithm == "ED" {
+		hash := blake2b.Sum512(message)
+		message = hash[:]
+	}
+	signature := ed25519.Sign(privateKey, message)
+	trustedComment := "timestamp:1700000000\tfile:plugin"
+	globalSignature := ed25519.Sign(privateKey, append(signature, trustedComment...))
+
+	encodedKey := base64.StdEncoding.EncodeToString(
+		append(append([]byte("Ed"), keyID...), publicKey...))
+	encodedSignature := "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(
+			append(append([]byte(algorithm), keyID...), signature...)) + "\n" +
+		"trusted comment: " + trustedComment + "\n" +
+		base64.StdEncoding.EncodeToString(globalSignature) + "\n"
+	return encodedKey, encodedSignature
+}
+
+// TestVerifySignature tests verifying the minisign signatures of the plugins.
+func TestVerifySignature(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plugin")
+	signaturePath := path + SignatureExtension
+	binary := []byte("plugin binary")
+	require.NoError(t, os.WriteFile(path, binary, 0o600))
+
+	for _, algorithm := range []string{"Ed", "ED"} {
+		t.Run(algorithm, func(t *testing.T) {
+			publicKey, signature := signMinisign(t, binary, algorithm)
+			require.NoError(t, os.WriteFile(signaturePath, []byte(signature), 0o600))
+			assert.NoError(t, VerifySignature(path, signaturePath, publicKey))
+			// The contents of the public key file are accepted too.
+			assert.NoError(t, VerifySignature(
+				path, signaturePath, "untrusted comment: minisign public key\n"+publicKey+"\n"))
+		})
+	}
+
+	publicKey, signature := signMinisign(t, binary, "ED")
+	require.NoError(t, os.WriteFile(signaturePath, []byte(signature), 0o600))
+
+	t.Run("tampered binary", func(t *testing.T) {
+		tampered := filepath.Join(dir, "tampered")
+		require.NoError(t, os.WriteFile(tampered, []byte("tampered binary"), 0o600))
+		assert.ErrorIs(t, VerifySignature(tampered, signaturePath, publicKey), errInvalidSignature)
+	})
+	t.Run("another key", func(t *testing.T) {
+		otherKey, _ := signMinisign(t, binary, "ED")
+		assert.ErrorIs(t, VerifySignature(path, signaturePath, otherKey), errInvalidSignature)
+	})
+	t.Run("invalid public key", func(t *testing.T) {
+		assert.ErrorIs(t, VerifySignature(path, signaturePath, ""), errInvalidPublicKey)
+		assert.ErrorIs(t, VerifySignature(path, signaturePath, "invalid"), errInvalidPublicKey)
+	})
+	t.Run("missing signature", func(t *testing.T) {
+		assert.Error(t, VerifySignature(path, filepath.Join(dir, "missing"), publicKey))
+	})
+}
@@ -2,10 +2,14 @@ package plugin
 
 import (
 	"os/exec"
+	"sort"
+	"strings"
 	"time"
 
 	sdkAct "github.com/gatewayd-io/gatewayd-plugin-sdk/act"
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
 	"github.com/gatewayd-io/gatewayd/act"
+	"github.com/gatewayd-io/gatewayd/config"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cast"
 )
@@ -103,3 +107,85 @@ func applyPolicies(
 
 	return outputs
 }
+
+// Verify returns true if the result of the hook has all the fields of its params,
+// since the result of a hook is passed down to the next hook as its params.
+func Verify(params, result *v1.Struct) bool {
+	if params == nil || result == nil {
+		return params == result
+	}
+
+	for key := range params.GetFields() {
+		if _, ok := result.GetFields()[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// MissingFields returns the fields of the params that the result of the hook doesn't
+// have, sorted, i.e. why the result failed verification.
+func MissingFields(params, result *v1.Struct) []string {
+	missing := []string{}
+	for key := range params.GetFields() {
+		if _, ok := result.GetFields()[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// HookVerificationPolicies returns the verification policies of the hooks by the
+// hook names in the config, e.g. onTrafficFromClient. The unknown hook names and
+// policies are logged and skipped, so that the global policy is used instead.
+func HookVerificationPolicies(
+	policies map[string]string, logger zerolog.Logger,
+) map[v1.HookName]config.VerificationPolicy {
+	hookPolicies := map[v1.HookName]config.VerificationPolicy{}
+	for name, policy := range policies {
+		hookName, ok := HookNameOf(name)
+		if !ok {
+			logger.Warn().Str("hook", name).Msg("Unknown hook in the hook verification policies")
+			continue
+		}
+		verification, ok := config.VerificationPolicies[policy]
+		if !ok {
+			logger.Warn().Fields(
+				map[string]interface{}{
+					"hook":   name,
+					"policy": policy,
+				},
+			).Msg("Unknown verification policy for the hook, using the global policy")
+			continue
+		}
+		hookPolicies[hookName] = verification
+	}
+	return hookPolicies
+}
+
+// unknownHooks returns the hooks that GatewayD doesn't know, e.g. because the plugin is
+// built with a newer SDK, as strings, since the unknown hooks have no names.
+func unknownHooks(hooks []v1.HookName) []string {
+	unknown := []string{}
+	for _, hookName := range hooks {
+		if _, ok := v1.HookName_name[int32(hookName)]; !ok || hookName == v1.HookName_HOOK_NAME_UNSPECIFIED {
+			unknown = append(unknown, hookName.String())
+		}
+	}
+	return unknown
+}
+
+// HookNameOf returns the hook name of the name in the config, e.g. onTrafficFromClient
+// for HOOK_NAME_ON_TRAFFIC_FROM_CLIENT. The name is matched case-insensitively.
+func HookNameOf(name string) (v1.HookName, bool) {
+	for value, hookName := range v1.HookName_name {
+		if strings.EqualFold(
+			strings.ReplaceAll(strings.TrimPrefix(hookName, "HOOK_NAME_"), "_", ""),
+			strings.ReplaceAll(name, "_", ""),
+		) {
+			return v1.HookName(value), true
+		}
+	}
+	return v1.HookName_HOOK_NAME_UNSPECIFIED, false
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	sdkAct "github.com/gatewayd-io/gatewayd-plugin-sdk/act"
+	v1 "github.com/gatewayd-io/gatewayd-plugin-sdk/plugin/v1"
 	"github.com/gatewayd-io/gatewayd/act"
 	"github.com/gatewayd-io/gatewayd/config"
 	"github.com/rs/zerolog"
@@ -123,3 +124,42 @@ func Test_applyPolicies(t *testing.T) {
 	assert.Nil(t, gerr)
 	assert.True(t, cast.ToBool(result))
 }
+
+// Test_MissingFields tests summarizing why the result of a hook failed verification.
+func Test_MissingFields(t *testing.T) {
+	params, err := v1.NewStruct(map[string]any{"request": "test", "client": "test", "server": "test"})
+	assert.NoError(t, err)
+	result, err := v1.NewStruct(map[string]any{"client": "test", "extra": "test"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"request", "server"}, MissingFields(params, result))
+	assert.Empty(t, MissingFields(params, params))
+}
+
+// Test_HookVerificationPolicies tests parsing the verification policies of the hooks.
+func Test_HookVerificationPolicies(t *testing.T) {
+	policies := HookVerificationPolicies(
+		map[string]string{
+			"onTrafficFromClient": "abort",
+			"ON_CLOSED":           "passdown",
+			"onUnknown":           "abort",
+			"onOpened":            "unknown",
+		},
+		zerolog.Nop(),
+	)
+	assert.Equal(t, map[v1.HookName]config.VerificationPolicy{
+		v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT: config.Abort,
+		v1.HookName_HOOK_NAME_ON_CLOSED:              config.PassDown,
+	}, policies)
+}
+
+// Test_unknownHooks tests finding the hooks that GatewayD doesn't know.
+func Test_unknownHooks(t *testing.T) {
+	assert.Empty(t, unknownHooks(nil))
+	assert.Empty(t, unknownHooks([]v1.HookName{
+		v1.HookName_HOOK_NAME_ON_TRAFFIC_FROM_CLIENT, v1.HookName_HOOK_NAME_ON_CLOSED,
+	}))
+	assert.Equal(t, []string{"HOOK_NAME_UNSPECIFIED", "999"}, unknownHooks([]v1.HookName{
+		v1.HookName_HOOK_NAME_UNSPECIFIED, v1.HookName_HOOK_NAME_ON_OPENED, v1.HookName(999),
+	}))
+}
@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// mirrorServer collects the mirrored traffic received by the plugin.
+type mirrorServer struct {
+	UnimplementedTrafficMirrorServiceServer
+	received chan *MirroredTraffic
+}
+
+func (s *mirrorServer) Mirror(stream TrafficMirrorService_MirrorServer) error {
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			close(s.received)
+			return stream.SendAndClose(&emptypb.Empty{})
+		}
+		if err != nil {
+			return err
+		}
+		s.received <- msg
+	}
+}
+
+// TestTrafficMirrorService tests streaming the mirrored traffic to a plugin.
+func TestTrafficMirrorService(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	mirror := &mirrorServer{received: make(chan *MirroredTraffic, 2)}
+	RegisterTrafficMirrorServiceServer(server, mirror)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stream, err := NewTrafficMirrorServiceClient(conn).Mirror(context.Background())
+	require.NoError(t, err)
+	for _, direction := range []string{"ingress", "egress"} {
+		require.NoError(t, stream.Send(&MirroredTraffic{
+			Direction: direction,
+			Payload:   []byte("SELECT 1"),
+		}))
+	}
+	_, err = stream.CloseAndRecv()
+	require.NoError(t, err)
+
+	directions := []string{}
+	for msg := range mirror.received {
+		directions = append(directions, msg.GetDirection())
+		assert.Equal(t, []byte("SELECT 1"), msg.GetPayload())
+	}
+	assert.Equal(t, []string{"ingress", "egress"}, directions)
+}